@@ -59,6 +59,10 @@ func main() {
 	defer stopWorker()
 	go runRecurringWorker(workerCtx, a)
 	go a.AccountDeletionWorker.Start(workerCtx)
+	go runAttachmentPurgeWorker(workerCtx, a)
+	go runBalanceCheckWorker(workerCtx, a)
+	go runNetWorthSnapshotWorker(workerCtx, a)
+	go runCategoryLimitCloseWorker(workerCtx, a)
 
 	go func() {
 		sigCh := make(chan os.Signal, 1)
@@ -100,3 +104,117 @@ func runRecurringWorker(ctx context.Context, a *app.App) {
 		}
 	}
 }
+
+// runAttachmentPurgeWorker removes the stored files of attachments whose rows
+// were deleted, including those cascaded away with a transaction, account or
+// space. It runs once at startup and then every minute until ctx is cancelled.
+func runAttachmentPurgeWorker(ctx context.Context, a *app.App) {
+	tick := func() {
+		if n := a.AttachmentService.PurgeDeleted(); n > 0 {
+			slog.Info("purged deleted attachment files", "count", n)
+		}
+	}
+	tick()
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			tick()
+		}
+	}
+}
+
+// runBalanceCheckWorker recomputes every account balance from its ledger
+// postings and logs the accounts that have drifted, so a lost update shows
+// up in the logs instead of silently in a user's numbers. Nothing is repaired
+// automatically; space owners repair from the space settings page. It runs
+// once at startup and then every hour until ctx is cancelled.
+func runBalanceCheckWorker(ctx context.Context, a *app.App) {
+	tick := func() {
+		drift, err := a.AccountService.CheckBalances()
+		if err != nil {
+			slog.Error("balance check failed", "error", err)
+			return
+		}
+		for _, d := range drift {
+			slog.Warn("account balance drift",
+				"account_id", d.AccountID,
+				"space_id", d.SpaceID,
+				"stored", d.Stored.String(),
+				"computed", d.Computed.String(),
+				"difference", d.Difference().String(),
+			)
+		}
+	}
+	tick()
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			tick()
+		}
+	}
+}
+
+// runNetWorthSnapshotWorker keeps a snapshot of every account for each day,
+// backfilling the history of accounts that don't have one yet and rewriting
+// the days a backdated change touched. Each account is brought up to date
+// once a day, on the first tick after midnight UTC. It runs once
+// at startup and then every hour until ctx is cancelled.
+func runNetWorthSnapshotWorker(ctx context.Context, a *app.App) {
+	tick := func() {
+		n, err := a.NetWorthService.SnapshotAll(time.Now().UTC())
+		if err != nil {
+			slog.Error("net worth snapshot failed", "error", err)
+			return
+		}
+		if n > 0 {
+			slog.Info("updated account snapshots", "count", n)
+		}
+	}
+	tick()
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			tick()
+		}
+	}
+}
+
+// runCategoryLimitCloseWorker records how each finished month went against
+// its category limits, so the history stays put when old transactions are
+// edited later. It runs once at startup and then every hour until ctx is
+// cancelled.
+func runCategoryLimitCloseWorker(ctx context.Context, a *app.App) {
+	tick := func() {
+		n, err := a.CategoryLimitSvc.CloseAll(time.Now().UTC())
+		if err != nil {
+			slog.Error("closing category limit months failed", "error", err)
+			return
+		}
+		if n > 0 {
+			slog.Info("closed category limit months", "count", n)
+		}
+	}
+	tick()
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			tick()
+		}
+	}
+}
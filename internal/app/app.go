@@ -8,6 +8,7 @@ import (
 	"git.juancwu.dev/juancwu/budgit/internal/db"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/storage"
 	"git.juancwu.dev/juancwu/budgit/internal/worker"
 	"github.com/jmoiron/sqlx"
 )
@@ -23,6 +24,10 @@ type App struct {
 	AllocationService     *service.AllocationService
 	TransactionService    *service.TransactionService
 	CategoryService       *service.CategoryService
+	CategoryTemplateSvc   *service.CategoryTemplateService
+	CategoryLimitSvc      *service.CategoryLimitService
+	EnvelopeSvc           *service.EnvelopeService
+	TagService            *service.TagService
 	RecurringEventService *service.RecurringEventService
 	InviteService         *service.InviteService
 	AuditLogService       *service.SpaceAuditLogService
@@ -30,6 +35,17 @@ type App struct {
 	AccountActivitySvc    *service.AccountActivityService
 	InvestmentService     *service.InvestmentService
 	BudgetPlanService     *service.BudgetPlanService
+	ImportService         *service.ImportService
+	ExportService         *service.ExportService
+	ReconciliationService *service.ReconciliationService
+	AttachmentService     *service.AttachmentService
+	CategorizationRuleSvc *service.CategorizationRuleService
+	SearchService         *service.SearchService
+	LedgerService         *service.LedgerService
+	LoanService           *service.LoanService
+	ExchangeRateService   *service.ExchangeRateService
+	CurrencyService       *service.CurrencyService
+	NetWorthService       *service.NetWorthService
 	AccountDeletionWorker *worker.AccountDeletionWorker
 }
 
@@ -54,6 +70,10 @@ func New(cfg *config.Config) (*App, error) {
 	allocationRepository := repository.NewAllocationRepository(database)
 	transactionRepository := repository.NewTransactionRepository(database)
 	categoryRepository := repository.NewCategoryRepository(database)
+	categoryTemplateRepo := repository.NewCategoryTemplateRepository(database)
+	categoryLimitRepo := repository.NewCategoryLimitRepository(database)
+	envelopeRepo := repository.NewEnvelopeRepository(database)
+	tagRepository := repository.NewTagRepository(database)
 	invitationRepository := repository.NewInvitationRepository(database)
 	auditLogRepository := repository.NewSpaceAuditLogRepository(database)
 	txAuditLogRepository := repository.NewTransactionAuditLogRepository(database)
@@ -62,8 +82,35 @@ func New(cfg *config.Config) (*App, error) {
 	contributionRoomRepo := repository.NewInvestmentContributionRoomRepository(database)
 	holdingRepo := repository.NewInvestmentHoldingRepository(database)
 	tradeRepo := repository.NewInvestmentTradeRepository(database)
+	priceRepo := repository.NewInvestmentPriceRepository(database)
+	snapshotRepo := repository.NewAccountSnapshotRepository(database)
 	budgetPlanRepo := repository.NewBudgetPlanRepository(database)
 	budgetPlanLineRepo := repository.NewBudgetPlanLineRepository(database)
+	importBatchRepo := repository.NewImportBatchRepository(database)
+	reconciliationRepo := repository.NewReconciliationRepository(database)
+	attachmentRepo := repository.NewTransactionAttachmentRepository(database)
+	categorizationRuleRepo := repository.NewCategorizationRuleRepository(database)
+	ledgerRepo := repository.NewLedgerRepository(database)
+	loanRepo := repository.NewLoanRepository(database)
+	exchangeRateRepo := repository.NewExchangeRateRepository(database)
+	spaceCurrencyRepo := repository.NewSpaceCurrencyRepository(database)
+
+	// Attachment stores. Both are always available for reading and cleanup;
+	// the config only picks where new uploads go.
+	localStore, err := storage.NewLocalStore(cfg.AttachmentDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize attachment storage: %w", err)
+	}
+	postgresStore := storage.NewPostgresStore(database)
+	var uploadStore storage.Store
+	switch cfg.AttachmentStorage {
+	case storage.BackendLocal:
+		uploadStore = localStore
+	case storage.BackendPostgres:
+		uploadStore = postgresStore
+	default:
+		return nil, fmt.Errorf("unknown attachment storage %q", cfg.AttachmentStorage)
+	}
 
 	// Services
 	emailService := service.NewEmailService(
@@ -77,17 +124,38 @@ func New(cfg *config.Config) (*App, error) {
 	accountDeletionWorker := worker.NewAccountDeletionWorker(userService, 30*time.Second)
 	auditLogService := service.NewSpaceAuditLogService(auditLogRepository)
 	txAuditLogService := service.NewTransactionAuditLogService(txAuditLogRepository)
+	currencyService := service.NewCurrencyService(spaceCurrencyRepo, spaceRepository, accountRepository)
+	currencyService.SetAuditLogger(auditLogService)
 	spaceService := service.NewSpaceService(spaceRepository)
 	spaceService.SetAuditLogger(auditLogService)
+	spaceService.SetCurrencyService(currencyService)
 	accountService := service.NewAccountService(accountRepository)
 	accountService.SetAuditLogger(auditLogService)
+	accountService.SetCurrencyService(currencyService)
 	accountService.SetAllocationRepository(allocationRepository)
 	allocationService := service.NewAllocationService(allocationRepository, accountService)
 	allocationService.SetAuditLogger(auditLogService)
-	transactionService := service.NewTransactionService(transactionRepository, categoryRepository, accountService)
+	allocationService.SetTransactionRepository(transactionRepository)
+	transactionService := service.NewTransactionService(transactionRepository, categoryRepository, tagRepository, accountService)
 	transactionService.SetAuditLogger(txAuditLogService)
 	transactionService.SetAllocationService(allocationService)
-	categoryService := service.NewCategoryService(categoryRepository)
+	exchangeRateService := service.NewExchangeRateService(exchangeRateRepo, transactionRepository)
+	exchangeRateService.SetCurrencyService(currencyService)
+	accountService.SetExchangeRateService(exchangeRateService)
+	transactionService.SetExchangeRateService(exchangeRateService)
+	categoryService := service.NewCategoryService(categoryRepository, accountRepository, transactionRepository)
+	categoryService.SetAuditLogger(auditLogService)
+	categoryService.SetTransactionAuditLogger(txAuditLogService)
+	categoryTemplateService := service.NewCategoryTemplateService(categoryTemplateRepo, accountRepository, categoryService)
+	accountService.SetCategoryTemplateService(categoryTemplateService)
+	categoryLimitService := service.NewCategoryLimitService(categoryLimitRepo, categoryRepository, transactionRepository, accountService)
+	categoryLimitService.SetAuditLogger(auditLogService)
+	envelopeService := service.NewEnvelopeService(envelopeRepo, spaceRepository, categoryRepository, accountService, transactionService, exchangeRateService)
+	envelopeService.SetAuditLogger(auditLogService)
+	categorizationRuleService := service.NewCategorizationRuleService(categorizationRuleRepo, categoryRepository, transactionRepository)
+	categorizationRuleService.SetAuditLogger(txAuditLogService)
+	transactionService.SetCategorizationRuleService(categorizationRuleService)
+	tagService := service.NewTagService(tagRepository)
 	accountActivityService := service.NewAccountActivityService(auditLogService, txAuditLogService)
 	authService := service.NewAuthService(
 		emailService,
@@ -103,8 +171,22 @@ func New(cfg *config.Config) (*App, error) {
 	)
 	inviteService := service.NewInviteService(invitationRepository, spaceRepository, userRepository, emailService, auditLogService)
 	recurringEventService := service.NewRecurringEventService(recurringEventRepository, transactionService, accountService)
-	investmentService := service.NewInvestmentService(accountRepository, contributionRoomRepo, holdingRepo, tradeRepo, transactionRepository)
+	loanService := service.NewLoanService(loanRepo, accountService, transactionService)
+	loanService.SetAuditLogger(auditLogService)
+	loanService.SetRecurringEventService(recurringEventService)
+	transactionService.SetLoanService(loanService)
+	investmentService := service.NewInvestmentService(accountRepository, contributionRoomRepo, holdingRepo, tradeRepo, priceRepo, transactionRepository)
+	netWorthService := service.NewNetWorthService(accountRepository, snapshotRepo, transactionRepository, holdingRepo, tradeRepo, priceRepo, spaceRepository, exchangeRateService)
+	netWorthService.SetCurrencyService(currencyService)
 	budgetPlanService := service.NewBudgetPlanService(budgetPlanRepo, budgetPlanLineRepo)
+	importService := service.NewImportService(importBatchRepo, transactionRepository, categoryRepository, accountService, transactionService)
+	exportService := service.NewExportService(transactionRepository, accountService)
+	searchService := service.NewSearchService(transactionRepository, categoryRepository)
+	ledgerService := service.NewLedgerService(ledgerRepo)
+	reconciliationService := service.NewReconciliationService(reconciliationRepo, transactionRepository, accountService)
+	reconciliationService.SetAuditLogger(auditLogService)
+	attachmentService := service.NewAttachmentService(attachmentRepo, transactionRepository, uploadStore, localStore, postgresStore)
+	userService.SetAttachmentService(attachmentService)
 
 	return &App{
 		Cfg:                   cfg,
@@ -117,6 +199,10 @@ func New(cfg *config.Config) (*App, error) {
 		AllocationService:     allocationService,
 		TransactionService:    transactionService,
 		CategoryService:       categoryService,
+		CategoryTemplateSvc:   categoryTemplateService,
+		CategoryLimitSvc:      categoryLimitService,
+		EnvelopeSvc:           envelopeService,
+		TagService:            tagService,
 		RecurringEventService: recurringEventService,
 		InviteService:         inviteService,
 		AuditLogService:       auditLogService,
@@ -124,6 +210,17 @@ func New(cfg *config.Config) (*App, error) {
 		AccountActivitySvc:    accountActivityService,
 		InvestmentService:     investmentService,
 		BudgetPlanService:     budgetPlanService,
+		ImportService:         importService,
+		ExportService:         exportService,
+		ReconciliationService: reconciliationService,
+		AttachmentService:     attachmentService,
+		CategorizationRuleSvc: categorizationRuleService,
+		SearchService:         searchService,
+		LedgerService:         ledgerService,
+		LoanService:           loanService,
+		ExchangeRateService:   exchangeRateService,
+		CurrencyService:       currencyService,
+		NetWorthService:       netWorthService,
 		AccountDeletionWorker: accountDeletionWorker,
 	}, nil
 }
@@ -37,6 +37,11 @@ type Config struct {
 
 	GoogleMeasuringID string
 
+	// AttachmentStorage picks where new attachment uploads are kept: "local"
+	// (files under AttachmentDir) or "postgres" (database large objects).
+	AttachmentStorage string
+	AttachmentDir     string
+
 	Version string
 }
 
@@ -74,6 +79,9 @@ func Load(version string) *Config {
 
 		GoogleMeasuringID: envString("GOOGLE_MEASURING_ID", ""),
 
+		AttachmentStorage: envString("ATTACHMENT_STORAGE", "local"),
+		AttachmentDir:     envString("ATTACHMENT_DIR", "data/attachments"),
+
 		Version: version,
 	}
 
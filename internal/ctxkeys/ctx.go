@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"git.juancwu.dev/juancwu/budgit/internal/config"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 )
 
@@ -15,6 +16,8 @@ const (
 	CSRFTokenKey        string = "csrf_token"
 	AppVersionKey       string = "app_version"
 	SidebarCollapsedKey string = "sidebar_collapsed"
+	CurrenciesKey       string = "currencies"
+	LocaleKey           string = "locale"
 )
 
 func User(ctx context.Context) *model.User {
@@ -70,3 +73,26 @@ func SidebarCollapsed(ctx context.Context) bool {
 func WithSidebarCollapsed(ctx context.Context, collapsed bool) context.Context {
 	return context.WithValue(ctx, SidebarCollapsedKey, collapsed)
 }
+
+// Currencies returns the current space's currency registry. Outside a space,
+// or before it is loaded, the nil registry holds the ISO list.
+func Currencies(ctx context.Context) *currency.Registry {
+	registry, _ := ctx.Value(CurrenciesKey).(*currency.Registry)
+	return registry
+}
+
+func WithCurrencies(ctx context.Context, registry *currency.Registry) context.Context {
+	return context.WithValue(ctx, CurrenciesKey, registry)
+}
+
+// Locale returns the number conventions to write amounts with.
+func Locale(ctx context.Context) currency.Locale {
+	if loc, ok := ctx.Value(LocaleKey).(currency.Locale); ok {
+		return loc
+	}
+	return currency.DefaultLocale
+}
+
+func WithLocale(ctx context.Context, loc currency.Locale) context.Context {
+	return context.WithValue(ctx, LocaleKey, loc)
+}
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE import_batches (
+    id TEXT PRIMARY KEY NOT NULL,
+    account_id TEXT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    actor_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    source TEXT NOT NULL,
+    filename TEXT NOT NULL DEFAULT '',
+    row_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rolled_back_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_import_batches_account_id_created_at
+    ON import_batches (account_id, created_at DESC);
+
+ALTER TABLE transactions
+    ADD COLUMN import_batch_id TEXT NULL REFERENCES import_batches(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_transactions_import_batch_id ON transactions (import_batch_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transactions_import_batch_id;
+ALTER TABLE transactions DROP COLUMN import_batch_id;
+DROP TABLE import_batches;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- FITID is the bank's identifier for a statement line. It is unique per
+-- account so re-importing an overlapping OFX statement can never insert the
+-- same line twice.
+ALTER TABLE transactions ADD COLUMN fitid TEXT NULL;
+
+CREATE UNIQUE INDEX idx_transactions_account_fitid
+    ON transactions (account_id, fitid)
+    WHERE fitid IS NOT NULL;
+
+ALTER TABLE import_batches
+    ADD COLUMN statement_balance TEXT NULL,
+    ADD COLUMN statement_balance_as_of TIMESTAMP NULL,
+    ADD COLUMN balance_after TEXT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE import_batches
+    DROP COLUMN balance_after,
+    DROP COLUMN statement_balance_as_of,
+    DROP COLUMN statement_balance;
+
+DROP INDEX IF EXISTS idx_transactions_account_fitid;
+ALTER TABLE transactions DROP COLUMN fitid;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tags are space-wide and names are matched case-insensitively, so the unique
+-- index is on the lowered name. transaction_tags' primary key leads with
+-- tag_id; the extra index serves lookups from the transaction side.
+CREATE UNIQUE INDEX idx_tags_space_name ON tags (space_id, lower(name));
+CREATE INDEX idx_transaction_tags_transaction_id ON transaction_tags (transaction_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transaction_tags_transaction_id;
+DROP INDEX IF EXISTS idx_tags_space_name;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A transaction can be split across several categories. Each split's share of
+-- the transaction value lives in amount; NULL means the whole value, which is
+-- how every existing single-category link keeps behaving as a 100% split.
+ALTER TABLE transaction_categories ADD COLUMN amount TEXT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Keep only the largest split of each transaction so the remaining link reads
+-- as the transaction's single category again.
+DELETE FROM transaction_categories tc
+USING transactions t
+WHERE tc.transaction_id = t.id
+  AND tc.amount IS NOT NULL
+  AND EXISTS (
+      SELECT 1 FROM transaction_categories other
+      WHERE other.transaction_id = tc.transaction_id
+        AND (COALESCE(other.amount, t.value)::numeric > tc.amount::numeric
+             OR (COALESCE(other.amount, t.value)::numeric = tc.amount::numeric AND other.category_id < tc.category_id))
+  );
+
+ALTER TABLE transaction_categories DROP COLUMN amount;
+-- +goose StatementEnd
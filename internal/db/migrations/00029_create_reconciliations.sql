@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every transaction starts pending. Ticking it off against a statement marks it
+-- cleared; finalizing the reconciliation turns cleared into reconciled.
+ALTER TABLE transactions
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'cleared', 'reconciled'));
+
+CREATE TABLE reconciliations (
+    id TEXT PRIMARY KEY NOT NULL,
+    account_id TEXT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    actor_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    statement_date TIMESTAMP NOT NULL,
+    statement_balance TEXT NOT NULL,
+    transaction_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finalized_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_reconciliations_account_id_statement_date
+    ON reconciliations (account_id, statement_date DESC);
+
+-- At most one reconciliation per account can be in progress.
+CREATE UNIQUE INDEX idx_reconciliations_account_open
+    ON reconciliations (account_id)
+    WHERE finalized_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE reconciliations;
+ALTER TABLE transactions DROP COLUMN status;
+-- +goose StatementEnd
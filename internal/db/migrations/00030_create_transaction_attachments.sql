@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE transaction_attachments (
+    id TEXT PRIMARY KEY NOT NULL,
+    transaction_id TEXT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    -- storage_backend names the store holding the bytes ('local' or
+    -- 'postgres'); storage_key is that store's handle for them.
+    storage_backend TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    uploaded_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_transaction_attachments_transaction_id
+    ON transaction_attachments (transaction_id, created_at);
+
+-- Attachment rows disappear through cascades from transactions, accounts,
+-- spaces and users, none of which know about the stored bytes. The trigger
+-- below queues every removed blob here, in the same SQL transaction as the
+-- delete, and AttachmentService.PurgeDeleted drains the queue.
+CREATE TABLE attachment_blob_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    storage_backend TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    queued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE FUNCTION queue_attachment_blob_deletion() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO attachment_blob_deletions (storage_backend, storage_key)
+    VALUES (OLD.storage_backend, OLD.storage_key);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transaction_attachments_queue_blob_deletion
+    AFTER DELETE ON transaction_attachments
+    FOR EACH ROW EXECUTE FUNCTION queue_attachment_blob_deletion();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER transaction_attachments_queue_blob_deletion ON transaction_attachments;
+DROP FUNCTION queue_attachment_blob_deletion();
+DROP TABLE attachment_blob_deletions;
+DROP TABLE transaction_attachments;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Rules assign a category to new bills, deposits and imported rows that come
+-- in without one. An account's rules are tried in ascending priority and the
+-- first match wins. Every condition is optional, but a rule needs at least
+-- one of them.
+CREATE TABLE categorization_rules (
+    id TEXT PRIMARY KEY NOT NULL,
+    account_id TEXT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    category_id TEXT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    priority INTEGER NOT NULL DEFAULT 0,
+    -- title_match is 'contains' (case-insensitive substring) or 'regex'.
+    title_match TEXT NOT NULL DEFAULT 'contains' CHECK (title_match IN ('contains', 'regex')),
+    title_pattern TEXT NOT NULL DEFAULT '',
+    min_amount TEXT NULL,
+    max_amount TEXT NULL,
+    transaction_type TEXT NULL CHECK (transaction_type IN ('deposit', 'withdrawal')),
+    -- rename_to, when set, replaces the title of matched transactions.
+    rename_to TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_categorization_rules_account_priority
+    ON categorization_rules (account_id, priority, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE categorization_rules;
+-- +goose StatementEnd
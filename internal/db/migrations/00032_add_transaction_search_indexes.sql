@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Space-wide search matches titles and descriptions with full-text search and
+-- falls back to trigram similarity for partial merchant names ("amzn" for
+-- "AMZN Mktp CA"). pg_trgm is installed into public so its operators resolve
+-- the same way whatever schema the migrations run in; the advisory lock keeps
+-- concurrent migrators from racing on CREATE EXTENSION.
+SELECT pg_advisory_xact_lock(hashtext('budgit_pg_trgm'));
+CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
+
+CREATE INDEX idx_transactions_search_fts ON transactions
+    USING GIN (to_tsvector('simple', title || ' ' || COALESCE(description, '')));
+CREATE INDEX idx_transactions_title_trgm ON transactions USING GIN (title public.gin_trgm_ops);
+CREATE INDEX idx_transactions_description_trgm ON transactions USING GIN (description public.gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The extension is left installed: other databases or schemas on the server
+-- may rely on it.
+DROP INDEX IF EXISTS idx_transactions_description_trgm;
+DROP INDEX IF EXISTS idx_transactions_title_trgm;
+DROP INDEX IF EXISTS idx_transactions_search_fts;
+-- +goose StatementEnd
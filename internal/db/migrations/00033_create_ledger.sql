@@ -0,0 +1,95 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every money movement is a journal entry whose postings sum to zero.
+-- Postings are debit-positive. Each budgit account has an asset ledger
+-- account; bills and deposits post their other side to the space's Expenses
+-- and Income system accounts, and a transfer is one entry across both
+-- accounts.
+CREATE TABLE ledger_accounts (
+    id TEXT PRIMARY KEY NOT NULL,
+    space_id TEXT NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    account_id TEXT UNIQUE REFERENCES accounts(id) ON DELETE CASCADE,
+    system_key TEXT,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('asset', 'liability', 'equity', 'income', 'expense')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (space_id, system_key)
+);
+
+CREATE TABLE journal_entries (
+    id TEXT PRIMARY KEY NOT NULL,
+    space_id TEXT NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_journal_entries_space_id_occurred_at ON journal_entries (space_id, occurred_at);
+
+CREATE TABLE postings (
+    id TEXT PRIMARY KEY NOT NULL,
+    entry_id TEXT NOT NULL REFERENCES journal_entries(id) ON DELETE CASCADE,
+    ledger_account_id TEXT NOT NULL REFERENCES ledger_accounts(id) ON DELETE CASCADE,
+    transaction_id TEXT REFERENCES transactions(id) ON DELETE CASCADE,
+    amount TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_postings_entry_id ON postings (entry_id);
+CREATE INDEX idx_postings_ledger_account_id ON postings (ledger_account_id);
+CREATE INDEX idx_postings_transaction_id ON postings (transaction_id);
+
+-- Convert existing data: one asset ledger account per account, the two
+-- system accounts per space, then one entry per transaction or transfer pair.
+INSERT INTO ledger_accounts (id, space_id, account_id, name, kind, created_at)
+SELECT gen_random_uuid()::text, space_id, id, name, 'asset', created_at FROM accounts;
+
+INSERT INTO ledger_accounts (id, space_id, system_key, name, kind)
+SELECT gen_random_uuid()::text, id, 'income', 'Income', 'income' FROM spaces
+UNION ALL
+SELECT gen_random_uuid()::text, id, 'expenses', 'Expenses', 'expense' FROM spaces;
+
+CREATE TEMP TABLE ledger_groups ON COMMIT DROP AS
+SELECT t.id AS transaction_id,
+       COALESCE(r.transaction_one_id, t.id) AS group_key,
+       a.space_id,
+       t.occurred_at,
+       t.created_at,
+       CASE WHEN t.type = 'deposit' THEN t.value::numeric ELSE -t.value::numeric END AS amount
+FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+LEFT JOIN related_transactions r ON t.id IN (r.transaction_one_id, r.transaction_two_id);
+
+CREATE TEMP TABLE ledger_entries ON COMMIT DROP AS
+SELECT gen_random_uuid()::text AS entry_id, group_key, MIN(space_id) AS space_id,
+       MIN(occurred_at) AS occurred_at, MIN(created_at) AS created_at, SUM(amount) AS imbalance,
+       MIN(transaction_id) AS transaction_id
+FROM ledger_groups
+GROUP BY group_key;
+
+INSERT INTO journal_entries (id, space_id, occurred_at, created_at)
+SELECT entry_id, space_id, occurred_at, created_at FROM ledger_entries;
+
+INSERT INTO postings (id, entry_id, ledger_account_id, transaction_id, amount, created_at)
+SELECT gen_random_uuid()::text, e.entry_id, la.id, g.transaction_id, g.amount::text, g.created_at
+FROM ledger_groups g
+JOIN ledger_entries e ON e.group_key = g.group_key
+JOIN transactions t ON t.id = g.transaction_id
+JOIN ledger_accounts la ON la.account_id = t.account_id;
+
+-- Bills and deposits (and the rare unequal transfer) balance against the
+-- space's Expenses or Income account.
+INSERT INTO postings (id, entry_id, ledger_account_id, transaction_id, amount, created_at)
+SELECT gen_random_uuid()::text, e.entry_id, la.id, e.transaction_id, (-e.imbalance)::text, e.created_at
+FROM ledger_entries e
+JOIN ledger_accounts la
+  ON la.space_id = e.space_id
+ AND la.system_key = CASE WHEN e.imbalance > 0 THEN 'income' ELSE 'expenses' END
+WHERE e.imbalance <> 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE postings;
+DROP TABLE journal_entries;
+DROP TABLE ledger_accounts;
+-- +goose StatementEnd
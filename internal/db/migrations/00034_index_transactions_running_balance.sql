@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Serves the running-balance window (PARTITION BY account_id ORDER BY
+-- occurred_at, created_at, id) and balance-as-of lookups without a sort.
+CREATE INDEX idx_transactions_account_id_occurred_at
+    ON transactions (account_id, occurred_at, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transactions_account_id_occurred_at;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Credit cards and lines of credit are liabilities: their balance goes
+-- negative as charges are made and a transfer into them pays the debt down.
+-- The credit terms are only set on those kinds.
+ALTER TABLE accounts
+    ADD COLUMN kind TEXT NOT NULL DEFAULT 'cash'
+        CONSTRAINT accounts_kind_check CHECK (kind IN ('cash', 'credit_card', 'line_of_credit')),
+    ADD COLUMN credit_limit TEXT NULL,
+    ADD COLUMN statement_closing_day SMALLINT NULL CHECK (statement_closing_day BETWEEN 1 AND 31),
+    ADD COLUMN payment_due_day SMALLINT NULL CHECK (payment_due_day BETWEEN 1 AND 31);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE ledger_accounts SET kind = 'asset' WHERE account_id IS NOT NULL AND kind = 'liability';
+ALTER TABLE accounts
+    DROP COLUMN payment_due_day,
+    DROP COLUMN statement_closing_day,
+    DROP COLUMN credit_limit,
+    DROP COLUMN kind;
+-- +goose StatementEnd
@@ -0,0 +1,64 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A loan is a liability account with amortization terms. The principal is
+-- drawn once when the loan is set up and every transfer into the account is a
+-- payment: the interest that accrued since the previous payment is charged as
+-- a withdrawal on the loan in the same database transaction, and
+-- loan_payments links the payment to that interest charge so the split can be
+-- shown and undone together.
+ALTER TABLE accounts DROP CONSTRAINT accounts_kind_check;
+ALTER TABLE accounts ADD CONSTRAINT accounts_kind_check
+    CHECK (kind IN ('cash', 'credit_card', 'line_of_credit', 'loan'));
+
+CREATE TABLE loan_terms (
+    account_id TEXT PRIMARY KEY NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    principal TEXT NOT NULL,
+    -- annual_rate is the nominal yearly rate as a percentage, e.g. '5.25'.
+    annual_rate TEXT NOT NULL,
+    compounding TEXT NOT NULL CHECK (compounding IN ('monthly', 'semi_annual', 'annual')),
+    payment_frequency TEXT NOT NULL CHECK (payment_frequency IN (
+        'monthly', 'semi_monthly', 'biweekly', 'accelerated_biweekly', 'weekly', 'accelerated_weekly'
+    )),
+    amortization_months INTEGER NOT NULL CHECK (amortization_months > 0),
+    term_months INTEGER NULL CHECK (term_months > 0),
+    start_date DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE loan_payments (
+    payment_transaction_id TEXT PRIMARY KEY NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    interest_transaction_id TEXT NULL REFERENCES transactions(id) ON DELETE SET NULL,
+    account_id TEXT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_loan_payments_account_id ON loan_payments (account_id);
+
+-- Scheduled loan payments are recurring transfers.
+ALTER TABLE recurring_events DROP CONSTRAINT recurring_events_kind_check;
+ALTER TABLE recurring_events ADD CONSTRAINT recurring_events_kind_check
+    CHECK (kind IN ('bill', 'fund', 'transfer'));
+ALTER TABLE recurring_events
+    ADD COLUMN dest_account_id TEXT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    ADD CONSTRAINT recurring_events_transfer_dest_check
+        CHECK ((kind = 'transfer') = (dest_account_id IS NOT NULL));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM recurring_events WHERE kind = 'transfer';
+ALTER TABLE recurring_events
+    DROP CONSTRAINT recurring_events_transfer_dest_check,
+    DROP COLUMN dest_account_id;
+ALTER TABLE recurring_events DROP CONSTRAINT recurring_events_kind_check;
+ALTER TABLE recurring_events ADD CONSTRAINT recurring_events_kind_check
+    CHECK (kind IN ('bill', 'fund'));
+
+DROP TABLE loan_payments;
+DROP TABLE loan_terms;
+
+UPDATE accounts SET kind = 'line_of_credit' WHERE kind = 'loan';
+ALTER TABLE accounts DROP CONSTRAINT accounts_kind_check;
+ALTER TABLE accounts ADD CONSTRAINT accounts_kind_check
+    CHECK (kind IN ('cash', 'credit_card', 'line_of_credit'));
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- An archived account is closed: it keeps its history for reports, search and
+-- exports but takes no new transactions. NULL while the account is open.
+ALTER TABLE accounts ADD COLUMN archived_at TIMESTAMP NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE accounts DROP COLUMN archived_at;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Rates are kept per space and per day: one unit of base_currency is worth
+-- rate units of quote_currency on rate_date. Rates typed in by hand or used by
+-- a transfer or currency change win over imported reference rates for the
+-- same day.
+CREATE TABLE exchange_rates (
+    id TEXT PRIMARY KEY NOT NULL,
+    space_id TEXT NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    base_currency TEXT NOT NULL,
+    quote_currency TEXT NOT NULL,
+    rate_date DATE NOT NULL,
+    rate TEXT NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('manual', 'transfer', 'currency_change', 'ecb', 'boc')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (base_currency <> quote_currency),
+    UNIQUE (space_id, base_currency, quote_currency, rate_date)
+);
+
+CREATE INDEX idx_exchange_rates_space_date ON exchange_rates (space_id, rate_date DESC);
+
+-- Space-wide totals and reports are shown in the reporting currency.
+ALTER TABLE spaces ADD COLUMN reporting_currency TEXT NOT NULL DEFAULT 'CAD';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE spaces DROP COLUMN reporting_currency;
+DROP TABLE exchange_rates;
+-- +goose StatementEnd
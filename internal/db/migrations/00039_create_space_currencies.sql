@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Currencies a space defines for itself, such as BTC with 8 decimals. ISO
+-- 4217 currencies are built in and never stored here.
+CREATE TABLE space_currencies (
+    id TEXT PRIMARY KEY NOT NULL,
+    space_id TEXT NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    name TEXT NOT NULL,
+    symbol TEXT NOT NULL,
+    minor_units INTEGER NOT NULL CHECK (minor_units BETWEEN 0 AND 18),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (space_id, code)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE space_currencies;
+-- +goose StatementEnd
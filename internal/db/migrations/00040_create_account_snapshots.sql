@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Market prices recorded for a holding. Without one, a holding counts at
+-- cost basis in net worth.
+CREATE TABLE investment_prices (
+    holding_id TEXT NOT NULL REFERENCES investment_holdings(id) ON DELETE CASCADE,
+    price_date DATE NOT NULL,
+    price TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (holding_id, price_date)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- One row per account and day with the account's end-of-day balance and what
+-- it added to net worth, both in the account's currency.
+CREATE TABLE account_snapshots (
+    account_id TEXT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    snapshot_date DATE NOT NULL,
+    balance TEXT NOT NULL,
+    value TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, snapshot_date)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE account_snapshots;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE investment_prices;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Categories nest under a parent in the same account. Deleting a parent is
+-- handled by the application, which either promotes or removes the children.
+ALTER TABLE categories ADD COLUMN parent_id TEXT NULL REFERENCES categories(id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_categories_parent_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE categories DROP COLUMN parent_id;
+-- +goose StatementEnd
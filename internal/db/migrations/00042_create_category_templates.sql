@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Reusable category sets a space can seed its accounts with. The built-in
+-- default template lives in code and has no row here.
+CREATE TABLE category_templates (
+    id TEXT PRIMARY KEY NOT NULL,
+    space_id TEXT NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX idx_category_templates_space_name ON category_templates(space_id, lower(name));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- One category in a template. parent_id nests it under another item of the
+-- same template, mirroring categories.parent_id.
+CREATE TABLE category_template_items (
+    id TEXT PRIMARY KEY NOT NULL,
+    template_id TEXT NOT NULL REFERENCES category_templates(id) ON DELETE CASCADE,
+    parent_id TEXT NULL REFERENCES category_template_items(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX idx_category_template_items_template_name ON category_template_items(template_id, lower(name));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE category_template_items;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE category_templates;
+-- +goose StatementEnd
@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Monthly spending limits on an account's categories. A row applies from its
+-- effective month until a later row for the same category replaces it, so
+-- changing a limit leaves earlier months alone. A NULL amount ends the limit.
+CREATE TABLE category_limits (
+    id TEXT PRIMARY KEY NOT NULL,
+    account_id TEXT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    category_id TEXT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    effective_month DATE NOT NULL,
+    amount TEXT NULL,
+    rollover BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (category_id, effective_month)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX idx_category_limits_account_id ON category_limits(account_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- How each limited category closed out a finished month. Written once and
+-- never recomputed, so later edits to old transactions don't change history.
+CREATE TABLE category_limit_months (
+    account_id TEXT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    category_id TEXT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    month DATE NOT NULL,
+    limit_amount TEXT NOT NULL,
+    carried_in TEXT NOT NULL,
+    spent TEXT NOT NULL,
+    rollover BOOLEAN NOT NULL,
+    closed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (category_id, month)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX idx_category_limit_months_account_month ON category_limit_months(account_id, month);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE category_limit_months;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE category_limits;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The first month of the space's envelope budget. NULL while envelope
+-- budgeting is off.
+ALTER TABLE spaces ADD COLUMN envelope_budget_since DATE NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Money assigned to an envelope for one month. An envelope is a category
+-- name, matched case-insensitively across the space's accounts, so envelope
+-- holds the lowercase name and '' for uncategorized spending.
+CREATE TABLE envelope_assignments (
+    space_id TEXT NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    envelope TEXT NOT NULL,
+    month DATE NOT NULL,
+    amount TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (space_id, envelope, month)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE envelope_assignments;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE spaces DROP COLUMN envelope_budget_since;
+-- +goose StatementEnd
@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every change to an allocation's amount, dated. amount is the signed change;
+-- a transfer is two rows, one on each allocation, pointing at each other
+-- through counterpart_id.
+CREATE TABLE allocation_movements (
+    id TEXT PRIMARY KEY NOT NULL,
+    allocation_id TEXT NOT NULL REFERENCES allocations(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('contribution', 'withdrawal', 'adjustment', 'transfer')),
+    amount TEXT NOT NULL,
+    counterpart_id TEXT NULL REFERENCES allocations(id) ON DELETE SET NULL,
+    actor_id TEXT NULL REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_allocation_movements_allocation_id ON allocation_movements (allocation_id, occurred_at);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Existing allocations get the history the space audit log recorded: the
+-- amount they were created with and every amount change since, at the time
+-- it happened. Each change moves the allocation from the amount the log last
+-- saw to the new one.
+CREATE TEMP TABLE allocation_amounts ON COMMIT DROP AS
+SELECT l.metadata->>'allocation_id' AS allocation_id,
+       l.action,
+       l.actor_id,
+       CASE l.action
+           WHEN 'allocation.created' THEN (l.metadata->>'amount')::numeric
+           ELSE (l.metadata->'changes'->'amount'->>'new')::numeric
+       END AS amount,
+       l.created_at
+FROM space_audit_logs l
+JOIN allocations a ON a.id = l.metadata->>'allocation_id'
+WHERE l.action = 'allocation.created'
+   OR (l.action = 'allocation.updated' AND l.metadata->'changes'->'amount' IS NOT NULL);
+
+INSERT INTO allocation_movements (id, allocation_id, kind, amount, actor_id, occurred_at, created_at)
+SELECT gen_random_uuid()::text, allocation_id,
+       CASE WHEN action = 'allocation.created' THEN 'contribution' ELSE 'adjustment' END,
+       change::text, actor_id, created_at, CURRENT_TIMESTAMP
+FROM (
+    SELECT *, amount - COALESCE(LAG(amount) OVER (PARTITION BY allocation_id ORDER BY created_at), 0) AS change
+    FROM allocation_amounts
+) c
+WHERE change <> 0;
+
+-- Whatever the log doesn't account for (allocations older than the log, or
+-- amounts it rounded) starts at migration time, so earlier dates show no
+-- history for it rather than an invented one.
+INSERT INTO allocation_movements (id, allocation_id, kind, amount, note, occurred_at, created_at)
+SELECT gen_random_uuid()::text, a.id, 'adjustment', (a.amount::numeric - COALESCE(SUM(m.amount::numeric), 0))::text,
+       'Amount when history began', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM allocations a
+LEFT JOIN allocation_movements m ON m.allocation_id = a.id
+GROUP BY a.id, a.amount
+HAVING a.amount::numeric <> COALESCE(SUM(m.amount::numeric), 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE allocation_movements;
+-- +goose StatementEnd
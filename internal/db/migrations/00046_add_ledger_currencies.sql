@@ -0,0 +1,184 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Ledger accounts and postings carry a currency, and an entry balances in
+-- each currency on its own. A budgit account gets a ledger account per
+-- currency it has held money in, and the space's system accounts exist once
+-- per currency. Bills, deposits and same-currency transfers balance against
+-- Income or Expenses as before; an entry across currencies (a transfer
+-- between accounts in different currencies, or an account changing currency)
+-- balances each currency through the space's Currency exchange account.
+ALTER TABLE ledger_accounts ADD COLUMN currency TEXT;
+ALTER TABLE postings ADD COLUMN currency TEXT;
+
+ALTER TABLE ledger_accounts DROP CONSTRAINT ledger_accounts_account_id_key;
+ALTER TABLE ledger_accounts DROP CONSTRAINT ledger_accounts_space_id_system_key_key;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_account_id_currency_key UNIQUE (account_id, currency);
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_space_id_system_key_currency_key UNIQUE (space_id, system_key, currency);
+
+-- Currency changes so far, from the space audit log.
+CREATE TEMP TABLE currency_changes ON COMMIT DROP AS
+SELECT l.metadata->>'account_id' AS account_id,
+       l.metadata->>'old_currency' AS old_currency,
+       l.metadata->>'new_currency' AS new_currency,
+       (l.metadata->>'old_balance')::numeric AS old_balance,
+       (l.metadata->>'new_balance')::numeric AS new_balance,
+       l.created_at
+FROM space_audit_logs l
+JOIN accounts a ON a.id = l.metadata->>'account_id'
+WHERE l.action = 'account.currency_changed';
+
+-- A transaction is in the currency its account had when it was recorded:
+-- the one the account next changed away from, or its currency today.
+CREATE TEMP TABLE transaction_currencies ON COMMIT DROP AS
+SELECT t.id AS transaction_id,
+       COALESCE((
+           SELECT c.old_currency FROM currency_changes c
+           WHERE c.account_id = t.account_id AND c.created_at > t.created_at
+           ORDER BY c.created_at
+           LIMIT 1
+       ), a.currency) AS currency
+FROM transactions t
+JOIN accounts a ON a.id = t.account_id;
+
+UPDATE ledger_accounts la SET currency = a.currency FROM accounts a WHERE a.id = la.account_id;
+
+INSERT INTO ledger_accounts (id, space_id, account_id, name, kind, currency, created_at)
+SELECT gen_random_uuid()::text, a.space_id, a.id, a.name,
+       CASE WHEN a.kind IN ('credit_card', 'line_of_credit', 'loan') THEN 'liability' ELSE 'asset' END, c.currency, a.created_at
+FROM accounts a
+JOIN (
+    SELECT account_id, old_currency AS currency FROM currency_changes
+    UNION
+    SELECT account_id, new_currency FROM currency_changes
+) c ON c.account_id = a.id
+ON CONFLICT (account_id, currency) DO NOTHING;
+
+UPDATE postings p SET ledger_account_id = target.id, currency = tc.currency
+FROM ledger_accounts la, transaction_currencies tc, ledger_accounts target
+WHERE la.id = p.ledger_account_id
+  AND la.account_id IS NOT NULL
+  AND tc.transaction_id = p.transaction_id
+  AND target.account_id = la.account_id
+  AND target.currency = tc.currency;
+
+-- The system side of every entry is booked again per currency, which takes
+-- the fake income or expense out of cross-currency transfers.
+DELETE FROM ledger_accounts WHERE system_key IS NOT NULL;
+
+CREATE TEMP TABLE entry_imbalances ON COMMIT DROP AS
+SELECT p.entry_id, je.space_id, p.currency,
+       SUM(p.amount::numeric) AS imbalance,
+       MIN(p.transaction_id) AS transaction_id,
+       MIN(p.created_at) AS created_at,
+       COUNT(*) OVER (PARTITION BY p.entry_id) AS currencies
+FROM postings p
+JOIN journal_entries je ON je.id = p.entry_id
+GROUP BY p.entry_id, je.space_id, p.currency;
+
+CREATE TEMP TABLE system_accounts (key, name, kind) ON COMMIT DROP AS
+VALUES ('income', 'Income', 'income'),
+       ('expenses', 'Expenses', 'expense'),
+       ('exchange', 'Currency exchange', 'equity');
+
+ALTER TABLE entry_imbalances ADD COLUMN system_key TEXT;
+UPDATE entry_imbalances SET system_key = CASE
+    WHEN currencies > 1 THEN 'exchange'
+    WHEN imbalance > 0 THEN 'income'
+    ELSE 'expenses'
+END;
+
+-- Conversions recorded before now become entries of their own: the old
+-- balance leaves the account in the old currency and the new one arrives in
+-- the new currency.
+CREATE TEMP TABLE conversion_postings ON COMMIT DROP AS
+SELECT c.entry_id, c.space_id, c.created_at, x.account_id, x.system_key, x.currency, x.amount
+FROM (
+    SELECT gen_random_uuid()::text AS entry_id, a.space_id, c.*
+    FROM currency_changes c
+    JOIN accounts a ON a.id = c.account_id
+    WHERE c.old_balance <> 0 OR c.new_balance <> 0
+) c
+CROSS JOIN LATERAL (VALUES
+    (c.account_id, NULL, c.old_currency, -c.old_balance),
+    (NULL, 'exchange', c.old_currency, c.old_balance),
+    (NULL, 'exchange', c.new_currency, -c.new_balance),
+    (c.account_id, NULL, c.new_currency, c.new_balance)
+) AS x(account_id, system_key, currency, amount)
+WHERE x.amount <> 0;
+
+INSERT INTO ledger_accounts (id, space_id, system_key, name, kind, currency)
+SELECT gen_random_uuid()::text, k.space_id, k.system_key, s.name, s.kind, k.currency
+FROM (
+    SELECT space_id, system_key, currency FROM entry_imbalances WHERE imbalance <> 0
+    UNION
+    SELECT space_id, system_key, currency FROM conversion_postings WHERE system_key IS NOT NULL
+) k
+JOIN system_accounts s ON s.key = k.system_key;
+
+INSERT INTO postings (id, entry_id, ledger_account_id, transaction_id, amount, currency, created_at)
+SELECT gen_random_uuid()::text, e.entry_id, la.id, e.transaction_id, (-e.imbalance)::text, e.currency, e.created_at
+FROM entry_imbalances e
+JOIN ledger_accounts la
+  ON la.space_id = e.space_id AND la.system_key = e.system_key AND la.currency = e.currency
+WHERE e.imbalance <> 0;
+
+INSERT INTO journal_entries (id, space_id, occurred_at, created_at)
+SELECT DISTINCT entry_id, space_id, created_at, created_at FROM conversion_postings;
+
+INSERT INTO postings (id, entry_id, ledger_account_id, amount, currency, created_at)
+SELECT gen_random_uuid()::text, cp.entry_id, la.id, cp.amount::text, cp.currency, cp.created_at
+FROM conversion_postings cp
+JOIN ledger_accounts la
+  ON la.currency = cp.currency
+ AND (la.account_id = cp.account_id OR (la.space_id = cp.space_id AND la.system_key = cp.system_key));
+
+ALTER TABLE ledger_accounts ALTER COLUMN currency SET NOT NULL;
+ALTER TABLE postings ALTER COLUMN currency SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Back to one ledger account per budgit account: conversion entries go, the
+-- postings of other currencies move onto the account's own ledger account,
+-- and the system side is booked again without regard to currency.
+DELETE FROM journal_entries je
+WHERE NOT EXISTS (SELECT 1 FROM postings p WHERE p.entry_id = je.id AND p.transaction_id IS NOT NULL);
+
+DELETE FROM ledger_accounts WHERE system_key IS NOT NULL;
+
+UPDATE postings p SET ledger_account_id = own.id
+FROM ledger_accounts la, accounts a, ledger_accounts own
+WHERE la.id = p.ledger_account_id
+  AND a.id = la.account_id
+  AND la.currency <> a.currency
+  AND own.account_id = a.id
+  AND own.currency = a.currency;
+
+DELETE FROM ledger_accounts la USING accounts a WHERE a.id = la.account_id AND la.currency <> a.currency;
+
+ALTER TABLE ledger_accounts DROP CONSTRAINT ledger_accounts_space_id_system_key_currency_key;
+ALTER TABLE ledger_accounts DROP CONSTRAINT ledger_accounts_account_id_currency_key;
+ALTER TABLE postings DROP COLUMN currency;
+ALTER TABLE ledger_accounts DROP COLUMN currency;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_account_id_key UNIQUE (account_id);
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_space_id_system_key_key UNIQUE (space_id, system_key);
+
+INSERT INTO ledger_accounts (id, space_id, system_key, name, kind)
+SELECT gen_random_uuid()::text, id, 'income', 'Income', 'income' FROM spaces
+UNION ALL
+SELECT gen_random_uuid()::text, id, 'expenses', 'Expenses', 'expense' FROM spaces;
+
+INSERT INTO postings (id, entry_id, ledger_account_id, transaction_id, amount, created_at)
+SELECT gen_random_uuid()::text, e.entry_id, la.id, e.transaction_id, (-e.imbalance)::text, e.created_at
+FROM (
+    SELECT p.entry_id, je.space_id, SUM(p.amount::numeric) AS imbalance,
+           MIN(p.transaction_id) AS transaction_id, MIN(p.created_at) AS created_at
+    FROM postings p
+    JOIN journal_entries je ON je.id = p.entry_id
+    GROUP BY p.entry_id, je.space_id
+) e
+JOIN ledger_accounts la
+  ON la.space_id = e.space_id
+ AND la.system_key = CASE WHEN e.imbalance > 0 THEN 'income' ELSE 'expenses' END
+WHERE e.imbalance <> 0;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A closed month outlives its category. Deleting the category keeps the
+-- month under the name it had, and merging moves it onto the target.
+ALTER TABLE category_limit_months ADD COLUMN category_name TEXT NOT NULL DEFAULT '';
+UPDATE category_limit_months m SET category_name = c.name FROM categories c WHERE c.id = m.category_id;
+ALTER TABLE category_limit_months ALTER COLUMN category_name DROP DEFAULT;
+
+ALTER TABLE category_limit_months DROP CONSTRAINT category_limit_months_pkey;
+ALTER TABLE category_limit_months DROP CONSTRAINT category_limit_months_category_id_fkey;
+ALTER TABLE category_limit_months ALTER COLUMN category_id DROP NOT NULL;
+ALTER TABLE category_limit_months ADD CONSTRAINT category_limit_months_category_id_fkey
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL;
+ALTER TABLE category_limit_months ADD CONSTRAINT category_limit_months_category_id_month_key UNIQUE (category_id, month);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM category_limit_months WHERE category_id IS NULL;
+
+ALTER TABLE category_limit_months DROP CONSTRAINT category_limit_months_category_id_month_key;
+ALTER TABLE category_limit_months DROP CONSTRAINT category_limit_months_category_id_fkey;
+ALTER TABLE category_limit_months ALTER COLUMN category_id SET NOT NULL;
+ALTER TABLE category_limit_months ADD CONSTRAINT category_limit_months_category_id_fkey
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE;
+ALTER TABLE category_limit_months ADD PRIMARY KEY (category_id, month);
+ALTER TABLE category_limit_months DROP COLUMN category_name;
+-- +goose StatementEnd
@@ -5,22 +5,27 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"git.juancwu.dev/juancwu/budgit/internal/service"
 	"git.juancwu.dev/juancwu/budgit/internal/ui"
 	"git.juancwu.dev/juancwu/budgit/internal/ui/blocks"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/forms"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
 	"github.com/shopspring/decimal"
 )
 
 type allocationHandler struct {
 	allocationService *service.AllocationService
 	accountService    *service.AccountService
+	spaceService      *service.SpaceService
 }
 
-func NewAllocationHandler(allocation *service.AllocationService, account *service.AccountService) *allocationHandler {
-	return &allocationHandler{allocationService: allocation, accountService: account}
+func NewAllocationHandler(allocation *service.AllocationService, account *service.AccountService, space *service.SpaceService) *allocationHandler {
+	return &allocationHandler{allocationService: allocation, accountService: account, spaceService: space}
 }
 
 // ensureAccess validates that the account exists and lives in the requested
@@ -34,6 +39,16 @@ func (h *allocationHandler) ensureAccess(w http.ResponseWriter, r *http.Request,
 	return true
 }
 
+// accountCurrency returns the account's currency code, or "" when it can't be
+// loaded; the section then shows plain two-decimal amounts.
+func (h *allocationHandler) accountCurrency(accountID string) string {
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil {
+		return ""
+	}
+	return account.Currency
+}
+
 func (h *allocationHandler) renderSection(w http.ResponseWriter, r *http.Request, spaceID, accountID string) {
 	summary, err := h.allocationService.SummaryForAccount(accountID)
 	if err != nil {
@@ -42,7 +57,7 @@ func (h *allocationHandler) renderSection(w http.ResponseWriter, r *http.Request
 		return
 	}
 	ui.Render(w, r, blocks.AllocationsSection(blocks.AllocationsSectionProps{
-		SpaceID: spaceID, AccountID: accountID, Summary: summary,
+		SpaceID: spaceID, AccountID: accountID, Currency: h.accountCurrency(accountID), Summary: summary,
 	}))
 }
 
@@ -190,12 +205,233 @@ func (h *allocationHandler) renderSectionWithCreateError(w http.ResponseWriter,
 		return
 	}
 	ui.Render(w, r, blocks.AllocationsSection(blocks.AllocationsSectionProps{
-		SpaceID: spaceID, AccountID: accountID, Summary: summary,
+		SpaceID: spaceID, AccountID: accountID, Currency: h.accountCurrency(accountID), Summary: summary,
 		CreateForm:     &state,
 		ShowCreateForm: true,
 	}))
 }
 
+// loadAllocation loads the account and allocation named in the path, making
+// sure they belong to the space. Returns false (and renders a 404) otherwise.
+func (h *allocationHandler) loadAllocation(w http.ResponseWriter, r *http.Request) (*model.Account, *model.Allocation, bool) {
+	account, err := h.accountService.GetAccount(r.PathValue("accountID"))
+	if err != nil || account.SpaceID != r.PathValue("spaceID") {
+		ui.Render(w, r, pages.NotFound())
+		return nil, nil, false
+	}
+	allocation, err := h.allocationService.Get(r.PathValue("allocationID"))
+	if err != nil || allocation.AccountID != account.ID {
+		ui.Render(w, r, pages.NotFound())
+		return nil, nil, false
+	}
+	return account, allocation, true
+}
+
+// DetailPage shows a savings goal's history of movements, a chart of how it
+// grew, and the forms to move money in, out, or to another goal.
+func (h *allocationHandler) DetailPage(w http.ResponseWriter, r *http.Request) {
+	account, allocation, ok := h.loadAllocation(w, r)
+	if !ok {
+		return
+	}
+	space, err := h.spaceService.GetSpace(account.SpaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	history, err := h.allocationService.History(allocation.ID)
+	if err != nil {
+		slog.Error("failed to load allocation history", "error", err, "allocation_id", allocation.ID)
+		ui.RenderError(w, r, "Failed to load savings goal", http.StatusInternalServerError)
+		return
+	}
+	targets, err := h.transferTargets(account.ID, allocation.ID)
+	if err != nil {
+		slog.Error("failed to list allocations", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load savings goal", http.StatusInternalServerError)
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	ui.Render(w, r, pages.SpaceAccountAllocationPage(pages.SpaceAccountAllocationPageProps{
+		SpaceID:     space.ID,
+		SpaceName:   space.Name,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		Currency:    account.Currency,
+		History:     history,
+		Points:      history.Points(time.Now()),
+		MovementForm: forms.AllocationMovementProps{
+			SpaceID: space.ID, AccountID: account.ID, AllocationID: allocation.ID, MaxDate: today, Date: today,
+		},
+		TransferForm: forms.AllocationTransferProps{
+			SpaceID: space.ID, AccountID: account.ID, AllocationID: allocation.ID, Targets: targets, MaxDate: today, Date: today,
+		},
+	}))
+}
+
+// transferTargets lists the account's savings goals other than the given one.
+func (h *allocationHandler) transferTargets(accountID, allocationID string) ([]*model.Allocation, error) {
+	summary, err := h.allocationService.SummaryForAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var targets []*model.Allocation
+	for _, a := range summary.Allocations {
+		if a.ID != allocationID {
+			targets = append(targets, a)
+		}
+	}
+	return targets, nil
+}
+
+// parseMovementFields reads the amount and date shared by the movement and
+// transfer forms, returning the messages to show for each.
+func parseMovementFields(r *http.Request, account *model.Account, amountInput, dateInput string) (amount decimal.Decimal, occurredAt time.Time, amountErr, dateErr string) {
+	cur := ctxkeys.Currencies(r.Context()).Get(account.Currency)
+	parsed, err := decimal.NewFromString(amountInput)
+	switch {
+	case amountInput == "":
+		amountErr = "Amount is required."
+	case err != nil:
+		amountErr = "Enter a valid amount (e.g. 250.00)."
+	case !parsed.IsPositive():
+		amountErr = "Amount must be greater than zero."
+	case !cur.Fits(parsed):
+		amountErr = decimalsErr("Amount", cur)
+	default:
+		amount = parsed
+	}
+
+	now := time.Now()
+	day, err := time.Parse("2006-01-02", dateInput)
+	switch {
+	case err != nil:
+		dateErr = "Pick the date it happened."
+	case day.Format("2006-01-02") == now.Format("2006-01-02"):
+		occurredAt = now
+	case day.After(now):
+		dateErr = "The date can't be in the future."
+	default:
+		occurredAt = day
+	}
+	return amount, occurredAt, amountErr, dateErr
+}
+
+func (h *allocationHandler) HandleRecordMovement(w http.ResponseWriter, r *http.Request) {
+	account, allocation, ok := h.loadAllocation(w, r)
+	if !ok {
+		return
+	}
+	formProps := forms.AllocationMovementProps{
+		SpaceID:      account.SpaceID,
+		AccountID:    account.ID,
+		AllocationID: allocation.ID,
+		MaxDate:      time.Now().Format("2006-01-02"),
+		Kind:         r.FormValue("kind"),
+		Amount:       strings.TrimSpace(r.FormValue("amount")),
+		Date:         strings.TrimSpace(r.FormValue("date")),
+		Note:         strings.TrimSpace(r.FormValue("note")),
+	}
+	amount, occurredAt, amountErr, dateErr := parseMovementFields(r, account, formProps.Amount, formProps.Date)
+	if amountErr != "" || dateErr != "" {
+		formProps.AmountErr = amountErr
+		formProps.DateErr = dateErr
+		ui.Render(w, r, forms.AllocationMovement(formProps))
+		return
+	}
+
+	actorID := ""
+	if user := ctxkeys.User(r.Context()); user != nil {
+		actorID = user.ID
+	}
+	_, err := h.allocationService.RecordMovement(service.RecordAllocationMovementInput{
+		AllocationID: allocation.ID,
+		Kind:         model.AllocationMovementKind(formProps.Kind),
+		Amount:       amount,
+		Note:         formProps.Note,
+		OccurredAt:   occurredAt,
+		ActorID:      actorID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAllocationOverdrawn):
+			formProps.AmountErr = "That's more than the goal holds."
+		case errors.Is(err, service.ErrInvalidAllocationMovement):
+			formProps.GeneralErr = "Pick a contribution or a withdrawal."
+		default:
+			slog.Error("failed to record allocation movement", "error", err, "allocation_id", allocation.ID)
+			formProps.GeneralErr = friendlyAllocationError(err)
+		}
+		ui.Render(w, r, forms.AllocationMovement(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *allocationHandler) HandleTransfer(w http.ResponseWriter, r *http.Request) {
+	account, allocation, ok := h.loadAllocation(w, r)
+	if !ok {
+		return
+	}
+	targets, err := h.transferTargets(account.ID, allocation.ID)
+	if err != nil {
+		slog.Error("failed to list allocations", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to transfer", http.StatusInternalServerError)
+		return
+	}
+	formProps := forms.AllocationTransferProps{
+		SpaceID:      account.SpaceID,
+		AccountID:    account.ID,
+		AllocationID: allocation.ID,
+		Targets:      targets,
+		MaxDate:      time.Now().Format("2006-01-02"),
+		ToID:         r.FormValue("to_id"),
+		Amount:       strings.TrimSpace(r.FormValue("amount")),
+		Date:         strings.TrimSpace(r.FormValue("date")),
+		Note:         strings.TrimSpace(r.FormValue("note")),
+	}
+	amount, occurredAt, amountErr, dateErr := parseMovementFields(r, account, formProps.Amount, formProps.Date)
+	if amountErr != "" || dateErr != "" {
+		formProps.AmountErr = amountErr
+		formProps.DateErr = dateErr
+		ui.Render(w, r, forms.AllocationTransfer(formProps))
+		return
+	}
+
+	actorID := ""
+	if user := ctxkeys.User(r.Context()); user != nil {
+		actorID = user.ID
+	}
+	err = h.allocationService.Transfer(service.TransferAllocationInput{
+		FromAllocationID: allocation.ID,
+		ToAllocationID:   formProps.ToID,
+		Amount:           amount,
+		Note:             formProps.Note,
+		OccurredAt:       occurredAt,
+		ActorID:          actorID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAllocationOverdrawn):
+			formProps.AmountErr = "That's more than the goal holds."
+		case errors.Is(err, service.ErrInvalidAllocationTransfer), errors.Is(err, repository.ErrAllocationNotFound):
+			formProps.ToErr = "Pick another savings goal on this account."
+		default:
+			slog.Error("failed to transfer between allocations", "error", err, "allocation_id", allocation.ID)
+			formProps.GeneralErr = friendlyAllocationError(err)
+		}
+		ui.Render(w, r, forms.AllocationTransfer(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
 func friendlyAllocationError(err error) string {
 	if err == nil {
 		return ""
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+)
+
+// maxAttachmentUploadFiles bounds how many files one upload request carries,
+// which in turn bounds the request body.
+const maxAttachmentUploadFiles = 5
+
+type attachmentHandler struct {
+	attachmentService  *service.AttachmentService
+	accountService     *service.AccountService
+	transactionService *service.TransactionService
+}
+
+func NewAttachmentHandler(attachmentService *service.AttachmentService, accountService *service.AccountService, transactionService *service.TransactionService) *attachmentHandler {
+	return &attachmentHandler{
+		attachmentService:  attachmentService,
+		accountService:     accountService,
+		transactionService: transactionService,
+	}
+}
+
+// loadTransaction resolves the transaction in the path, checking it belongs
+// to the account, and the account to the space. Space membership itself is
+// enforced by RequireSpaceAccess on the route group.
+func (h *attachmentHandler) loadTransaction(r *http.Request) (*model.Transaction, bool) {
+	account, err := h.accountService.GetAccount(r.PathValue("accountID"))
+	if err != nil || account.SpaceID != r.PathValue("spaceID") {
+		return nil, false
+	}
+	txn, err := h.transactionService.GetTransaction(r.PathValue("transactionID"))
+	if err != nil || txn.AccountID != account.ID {
+		return nil, false
+	}
+	return txn, true
+}
+
+func (h *attachmentHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	txn, ok := h.loadTransaction(r)
+	if !ok {
+		ui.RenderError(w, r, "Transaction not found", http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentUploadFiles*service.MaxAttachmentSize+(1<<20))
+	if err := r.ParseMultipartForm(service.MaxAttachmentSize); err != nil {
+		ui.RenderError(w, r, "Upload is too large.", http.StatusRequestEntityTooLarge)
+		return
+	}
+	files := r.MultipartForm.File["files"]
+	if len(files) == 0 {
+		ui.RenderError(w, r, "Choose a file to attach.", http.StatusBadRequest)
+		return
+	}
+	if len(files) > maxAttachmentUploadFiles {
+		ui.RenderError(w, r, fmt.Sprintf("Attach at most %d files at a time.", maxAttachmentUploadFiles), http.StatusBadRequest)
+		return
+	}
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+	for _, header := range files {
+		f, err := header.Open()
+		if err != nil {
+			slog.Error("failed to open uploaded attachment", "error", err, "transaction_id", txn.ID)
+			ui.RenderError(w, r, "We couldn't read "+header.Filename+".", http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(f, service.MaxAttachmentSize+1))
+		f.Close()
+		if err != nil {
+			slog.Error("failed to read uploaded attachment", "error", err, "transaction_id", txn.ID)
+			ui.RenderError(w, r, "We couldn't read "+header.Filename+".", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := h.attachmentService.Upload(service.UploadAttachmentInput{
+			TransactionID: txn.ID,
+			ActorID:       actorID,
+			Filename:      header.Filename,
+			Data:          data,
+		}); err != nil {
+			switch {
+			case errors.Is(err, service.ErrAttachmentEmpty):
+				ui.RenderError(w, r, header.Filename+" is empty.", http.StatusBadRequest)
+			case errors.Is(err, service.ErrAttachmentTooLarge):
+				ui.RenderError(w, r, fmt.Sprintf("%s is larger than %d MB.", header.Filename, service.MaxAttachmentSize>>20), http.StatusRequestEntityTooLarge)
+			case errors.Is(err, service.ErrAttachmentTypeNotAllowed):
+				ui.RenderError(w, r, header.Filename+" isn't an image or PDF.", http.StatusUnsupportedMediaType)
+			case errors.Is(err, service.ErrAttachmentLimitReached):
+				ui.RenderError(w, r, fmt.Sprintf("A transaction can hold at most %d attachments.", service.MaxAttachmentsPerTransaction), http.StatusConflict)
+			default:
+				slog.Error("failed to upload attachment", "error", err, "transaction_id", txn.ID)
+				ui.RenderError(w, r, "Failed to upload attachment", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// ServeAttachment streams an attachment. Images and PDFs open in the browser;
+// ?download=1 forces a download.
+func (h *attachmentHandler) ServeAttachment(w http.ResponseWriter, r *http.Request) {
+	txn, ok := h.loadTransaction(r)
+	if !ok {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+	att, err := h.attachmentService.Get(txn.ID, r.PathValue("attachmentID"))
+	if err != nil {
+		if !errors.Is(err, service.ErrAttachmentNotFound) {
+			slog.Error("failed to load attachment", "error", err, "transaction_id", txn.ID)
+		}
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+	rc, err := h.attachmentService.Open(att)
+	if err != nil {
+		if !errors.Is(err, service.ErrAttachmentNotFound) {
+			slog.Error("failed to open attachment", "error", err, "attachment_id", att.ID)
+			ui.RenderError(w, r, "Failed to load attachment", http.StatusInternalServerError)
+			return
+		}
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+	defer rc.Close()
+
+	disposition := "inline"
+	if r.URL.Query().Get("download") == "1" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", att.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(att.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": att.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if _, err := io.Copy(w, rc); err != nil {
+		slog.Error("failed to stream attachment", "error", err, "attachment_id", att.ID)
+	}
+}
+
+func (h *attachmentHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	txn, ok := h.loadTransaction(r)
+	if !ok {
+		ui.RenderError(w, r, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	attachmentID := r.PathValue("attachmentID")
+	if err := h.attachmentService.Delete(txn.ID, attachmentID); err != nil {
+		if errors.Is(err, service.ErrAttachmentNotFound) {
+			ui.RenderError(w, r, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete attachment", "error", err, "attachment_id", attachmentID)
+		ui.RenderError(w, r, "Failed to delete attachment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
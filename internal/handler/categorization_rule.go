@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/blocks"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/forms"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+	"github.com/shopspring/decimal"
+)
+
+type categorizationRuleHandler struct {
+	ruleService     *service.CategorizationRuleService
+	categoryService *service.CategoryService
+	accountService  *service.AccountService
+	spaceService    *service.SpaceService
+}
+
+func NewCategorizationRuleHandler(ruleService *service.CategorizationRuleService, categoryService *service.CategoryService, accountService *service.AccountService, spaceService *service.SpaceService) *categorizationRuleHandler {
+	return &categorizationRuleHandler{
+		ruleService:     ruleService,
+		categoryService: categoryService,
+		accountService:  accountService,
+		spaceService:    spaceService,
+	}
+}
+
+func (h *categorizationRuleHandler) loadAccount(w http.ResponseWriter, r *http.Request) (*model.Account, bool) {
+	account, err := h.accountService.GetAccount(r.PathValue("accountID"))
+	if err != nil || account.SpaceID != r.PathValue("spaceID") {
+		ui.Render(w, r, pages.NotFound())
+		return nil, false
+	}
+	return account, true
+}
+
+func (h *categorizationRuleHandler) RulesPage(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	space, err := h.spaceService.GetSpace(account.SpaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	rules, err := h.ruleService.List(account.ID)
+	if err != nil {
+		slog.Error("failed to list categorization rules", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load rules", http.StatusInternalServerError)
+		return
+	}
+	categories, err := h.categoryService.ListByAccount(account.ID)
+	if err != nil {
+		slog.Error("failed to list categories", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load rules", http.StatusInternalServerError)
+		return
+	}
+
+	// New rules go to the end of the list unless the user says otherwise.
+	nextPriority := 0
+	if len(rules) > 0 {
+		nextPriority = rules[len(rules)-1].Priority + 10
+	}
+	ui.Render(w, r, pages.SpaceAccountRulesPage(pages.SpaceAccountRulesPageProps{
+		SpaceID:         space.ID,
+		SpaceName:       space.Name,
+		AccountID:       account.ID,
+		AccountName:     account.Name,
+		AccountCurrency: account.Currency,
+		Rules:           rules,
+		Categories:      categories,
+		CreateForm: forms.CategorizationRuleProps{
+			SpaceID:    space.ID,
+			AccountID:  account.ID,
+			Categories: categories,
+			Priority:   strconv.Itoa(nextPriority),
+		},
+	}))
+}
+
+// parseRuleForm reads the rule form. Field errors are set on the returned
+// props; the input is only usable when none are.
+func parseRuleForm(r *http.Request, account *model.Account) (service.CategorizationRuleInput, forms.CategorizationRuleProps) {
+	props := forms.CategorizationRuleProps{
+		SpaceID:         account.SpaceID,
+		AccountID:       account.ID,
+		RuleID:          r.PathValue("ruleID"),
+		CategoryID:      strings.TrimSpace(r.FormValue("category")),
+		Priority:        strings.TrimSpace(r.FormValue("priority")),
+		TitleMatch:      strings.TrimSpace(r.FormValue("title_match")),
+		TitlePattern:    strings.TrimSpace(r.FormValue("title_pattern")),
+		MinAmount:       strings.TrimSpace(r.FormValue("min_amount")),
+		MaxAmount:       strings.TrimSpace(r.FormValue("max_amount")),
+		TransactionType: strings.TrimSpace(r.FormValue("transaction_type")),
+		RenameTo:        strings.TrimSpace(r.FormValue("rename_to")),
+	}
+	input := service.CategorizationRuleInput{
+		AccountID:       account.ID,
+		CategoryID:      props.CategoryID,
+		TitleMatch:      model.RuleTitleMatch(props.TitleMatch),
+		TitlePattern:    props.TitlePattern,
+		TransactionType: model.TransactionType(props.TransactionType),
+		RenameTo:        props.RenameTo,
+	}
+
+	if props.CategoryID == "" {
+		props.CategoryErr = "Pick a category."
+	}
+	if props.Priority != "" {
+		p, err := strconv.Atoi(props.Priority)
+		if err != nil {
+			props.PriorityErr = "Enter a whole number."
+		}
+		input.Priority = p
+	}
+	cur := ctxkeys.Currencies(r.Context()).Get(account.Currency)
+	for _, bound := range []struct {
+		value string
+		dst   **decimal.Decimal
+	}{{props.MinAmount, &input.MinAmount}, {props.MaxAmount, &input.MaxAmount}} {
+		if bound.value == "" {
+			continue
+		}
+		d, err := decimal.NewFromString(bound.value)
+		if err != nil {
+			props.AmountErr = "Enter valid amounts."
+			continue
+		}
+		if !cur.Fits(d) {
+			props.AmountErr = decimalsErr("Amounts", cur)
+			continue
+		}
+		*bound.dst = &d
+	}
+	return input, props
+}
+
+func ruleFormHasErrors(p forms.CategorizationRuleProps) bool {
+	return p.CategoryErr != "" || p.PriorityErr != "" || p.AmountErr != ""
+}
+
+// renderRuleFormError re-renders the submitted form with the service error
+// mapped onto the relevant field.
+func (h *categorizationRuleHandler) renderRuleFormError(w http.ResponseWriter, r *http.Request, props forms.CategorizationRuleProps, err error) {
+	switch {
+	case errors.Is(err, service.ErrCategoryNotFound):
+		props.CategoryErr = "Pick a category."
+	case errors.Is(err, service.ErrRuleInvalidPattern):
+		props.PatternErr = "That isn't a valid regular expression."
+	case errors.Is(err, service.ErrRuleInvalidAmountRange):
+		props.AmountErr = "Amounts can't be negative, and the minimum can't be above the maximum."
+	case errors.Is(err, service.ErrRuleWithoutConditions):
+		props.GeneralErr = "Set at least one condition: a title, an amount or a transaction type."
+	case err != nil:
+		slog.Error("failed to save categorization rule", "error", err, "account_id", props.AccountID)
+		props.GeneralErr = "Something went wrong. Please try again."
+	}
+	ui.Render(w, r, forms.CategorizationRule(props))
+}
+
+func (h *categorizationRuleHandler) withCategories(props forms.CategorizationRuleProps) forms.CategorizationRuleProps {
+	categories, err := h.categoryService.ListByAccount(props.AccountID)
+	if err != nil {
+		slog.Error("failed to list categories", "error", err, "account_id", props.AccountID)
+	}
+	props.Categories = categories
+	return props
+}
+
+func (h *categorizationRuleHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	input, props := parseRuleForm(r, account)
+	props = h.withCategories(props)
+	if ruleFormHasErrors(props) {
+		h.renderRuleFormError(w, r, props, nil)
+		return
+	}
+	if _, err := h.ruleService.Create(input); err != nil {
+		h.renderRuleFormError(w, r, props, err)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *categorizationRuleHandler) HandleEdit(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	input, props := parseRuleForm(r, account)
+	props = h.withCategories(props)
+	if ruleFormHasErrors(props) {
+		h.renderRuleFormError(w, r, props, nil)
+		return
+	}
+	if _, err := h.ruleService.Update(props.RuleID, input); err != nil {
+		if errors.Is(err, service.ErrCategorizationRuleNotFound) {
+			ui.RenderError(w, r, "Rule not found", http.StatusNotFound)
+			return
+		}
+		h.renderRuleFormError(w, r, props, err)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *categorizationRuleHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	ruleID := r.PathValue("ruleID")
+	if err := h.ruleService.Delete(account.ID, ruleID); err != nil {
+		if errors.Is(err, service.ErrCategorizationRuleNotFound) {
+			ui.RenderError(w, r, "Rule not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete categorization rule", "error", err, "rule_id", ruleID)
+		ui.RenderError(w, r, "Failed to delete rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandlePreview is the dry run: it lists what applying the rules would change.
+func (h *categorizationRuleHandler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	h.renderPreview(w, r, account, 0)
+}
+
+func (h *categorizationRuleHandler) HandleApply(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+	applied, err := h.ruleService.Apply(account.ID, actorID)
+	if err != nil {
+		slog.Error("failed to apply categorization rules", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to apply rules", http.StatusInternalServerError)
+		return
+	}
+	h.renderPreview(w, r, account, applied)
+}
+
+func (h *categorizationRuleHandler) renderPreview(w http.ResponseWriter, r *http.Request, account *model.Account, applied int) {
+	apps, err := h.ruleService.Preview(account.ID)
+	if err != nil {
+		slog.Error("failed to preview categorization rules", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to preview rules", http.StatusInternalServerError)
+		return
+	}
+	categories, err := h.categoryService.ListByAccount(account.ID)
+	if err != nil {
+		slog.Error("failed to list categories", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to preview rules", http.StatusInternalServerError)
+		return
+	}
+	ui.Render(w, r, blocks.CategorizationRulePreview(blocks.CategorizationRulePreviewProps{
+		SpaceID:      account.SpaceID,
+		AccountID:    account.ID,
+		Applications: apps,
+		Categories:   categories,
+		Applied:      applied,
+		Currency:     account.Currency,
+	}))
+}
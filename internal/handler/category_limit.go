@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/forms"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+	"github.com/shopspring/decimal"
+)
+
+// budgetHistoryMonths is how many finished months the budget page lists.
+const budgetHistoryMonths = 12
+
+type categoryLimitHandler struct {
+	limitService    *service.CategoryLimitService
+	categoryService *service.CategoryService
+	accountService  *service.AccountService
+	spaceService    *service.SpaceService
+}
+
+func NewCategoryLimitHandler(limitService *service.CategoryLimitService, categoryService *service.CategoryService, accountService *service.AccountService, spaceService *service.SpaceService) *categoryLimitHandler {
+	return &categoryLimitHandler{
+		limitService:    limitService,
+		categoryService: categoryService,
+		accountService:  accountService,
+		spaceService:    spaceService,
+	}
+}
+
+func (h *categoryLimitHandler) loadAccount(w http.ResponseWriter, r *http.Request) (*model.Account, bool) {
+	account, err := h.accountService.GetAccount(r.PathValue("accountID"))
+	if err != nil || account.SpaceID != r.PathValue("spaceID") {
+		ui.Render(w, r, pages.NotFound())
+		return nil, false
+	}
+	return account, true
+}
+
+// BudgetPage shows one month's spending against the account's category
+// limits, the form to change them, and how past months closed. The month
+// comes from ?month=YYYY-MM and defaults to the current one.
+func (h *categoryLimitHandler) BudgetPage(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	space, err := h.spaceService.GetSpace(account.SpaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	current := model.MonthStart(time.Now())
+	month := current
+	if v := r.URL.Query().Get("month"); v != "" {
+		if parsed, err := time.Parse("2006-01", v); err == nil {
+			month = parsed
+		}
+	}
+
+	budget, err := h.limitService.Budget(account.ID, month)
+	if err != nil {
+		slog.Error("failed to load budget", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load budget", http.StatusInternalServerError)
+		return
+	}
+	tree, err := h.categoryService.Tree(account.ID)
+	if err != nil {
+		slog.Error("failed to list categories", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load budget", http.StatusInternalServerError)
+		return
+	}
+	limits, err := h.limitService.Limits(account.ID, month)
+	if err != nil {
+		slog.Error("failed to load category limits", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load budget", http.StatusInternalServerError)
+		return
+	}
+	// Changes already in effect for a future month aren't upcoming there.
+	upcomingAfter := current
+	if month.After(current) {
+		upcomingAfter = month
+	}
+	upcoming, err := h.limitService.Upcoming(account.ID, upcomingAfter)
+	if err != nil {
+		slog.Error("failed to load upcoming category limits", "error", err, "account_id", account.ID)
+	}
+	history, err := h.limitService.History(account.ID, budgetHistoryMonths)
+	if err != nil {
+		slog.Error("failed to load budget history", "error", err, "account_id", account.ID)
+	}
+
+	names := make(map[string]string, len(tree))
+	var inEffect []*model.CategoryLimit
+	for _, n := range tree {
+		names[n.ID] = n.Name
+		if l, ok := limits[n.ID]; ok {
+			inEffect = append(inEffect, l)
+		}
+	}
+
+	formProps := h.formProps(account, tree)
+	if !month.Before(current) {
+		formProps.From = month.Format("2006-01")
+	}
+
+	ui.Render(w, r, pages.SpaceAccountBudgetPage(pages.SpaceAccountBudgetPageProps{
+		SpaceID:       space.ID,
+		SpaceName:     space.Name,
+		AccountID:     account.ID,
+		AccountName:   account.Name,
+		Currency:      account.Currency,
+		Month:         month.Format("2006-01"),
+		PrevMonth:     month.AddDate(0, -1, 0).Format("2006-01"),
+		NextMonth:     month.AddDate(0, 1, 0).Format("2006-01"),
+		Budget:        budget,
+		Limits:        inEffect,
+		Upcoming:      upcoming,
+		CategoryNames: names,
+		History:       history,
+		HasCategories: len(tree) > 0,
+		Form:          formProps,
+	}))
+}
+
+func (h *categoryLimitHandler) formProps(account *model.Account, tree []model.CategoryNode) forms.CategoryLimitProps {
+	current := model.MonthStart(time.Now()).Format("2006-01")
+	return forms.CategoryLimitProps{
+		SpaceID:    account.SpaceID,
+		AccountID:  account.ID,
+		Categories: tree,
+		MinFrom:    current,
+		From:       current,
+	}
+}
+
+// HandleSetLimit sets or, with remove=1, removes a category's limit from the
+// submitted month on.
+func (h *categoryLimitHandler) HandleSetLimit(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+
+	categoryID := r.FormValue("category_id")
+	amountInput := strings.TrimSpace(r.FormValue("amount"))
+	fromInput := r.FormValue("from")
+	remove := r.FormValue("remove") == "1"
+
+	tree, err := h.categoryService.Tree(account.ID)
+	if err != nil {
+		slog.Error("failed to list categories", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to save limit", http.StatusInternalServerError)
+		return
+	}
+	formProps := h.formProps(account, tree)
+	formProps.CategoryID = categoryID
+	formProps.Amount = amountInput
+	formProps.Rollover = r.FormValue("rollover") == "1"
+	formProps.From = fromInput
+
+	hasErr := false
+	from, err := time.Parse("2006-01", fromInput)
+	if err != nil {
+		formProps.FromErr = "Pick the month the limit starts."
+		hasErr = true
+	}
+	var amount *decimal.Decimal
+	if !remove {
+		cur := ctxkeys.Currencies(r.Context()).Get(account.Currency)
+		amt, err := decimal.NewFromString(amountInput)
+		switch {
+		case amountInput == "":
+			formProps.AmountErr = "Limit is required."
+			hasErr = true
+		case err != nil:
+			formProps.AmountErr = "Enter a valid amount (e.g. 250.00)."
+			hasErr = true
+		case !amt.IsPositive():
+			formProps.AmountErr = "Limit must be greater than zero."
+			hasErr = true
+		case !cur.Fits(amt):
+			formProps.AmountErr = decimalsErr("Limit", cur)
+			hasErr = true
+		default:
+			amount = &amt
+		}
+	}
+	if hasErr {
+		if remove {
+			ui.RenderError(w, r, "Failed to remove limit", http.StatusUnprocessableEntity)
+			return
+		}
+		ui.Render(w, r, forms.CategoryLimit(formProps))
+		return
+	}
+
+	_, err = h.limitService.SetLimit(service.SetCategoryLimitInput{
+		AccountID:  account.ID,
+		CategoryID: categoryID,
+		From:       from,
+		Amount:     amount,
+		Rollover:   formProps.Rollover,
+		ActorID:    actorID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCategoryNotFound):
+			formProps.CategoryErr = "That category no longer exists."
+		case errors.Is(err, service.ErrCategoryLimitInPast):
+			formProps.FromErr = "Past months keep their limits. Pick this month or a later one."
+		case errors.Is(err, service.ErrInvalidCategoryLimit):
+			formProps.AmountErr = "Limit must be greater than zero."
+		default:
+			slog.Error("failed to set category limit", "error", err, "account_id", account.ID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		if remove {
+			ui.RenderError(w, r, "Failed to remove limit", http.StatusUnprocessableEntity)
+			return
+		}
+		ui.Render(w, r, forms.CategoryLimit(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
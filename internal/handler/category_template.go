@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/forms"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+)
+
+type categoryTemplateHandler struct {
+	templateService *service.CategoryTemplateService
+	accountService  *service.AccountService
+	spaceService    *service.SpaceService
+}
+
+func NewCategoryTemplateHandler(
+	templateService *service.CategoryTemplateService,
+	accountService *service.AccountService,
+	spaceService *service.SpaceService,
+) *categoryTemplateHandler {
+	return &categoryTemplateHandler{
+		templateService: templateService,
+		accountService:  accountService,
+		spaceService:    spaceService,
+	}
+}
+
+// ListPage lists the built-in template and the space's own, each with its
+// categories.
+func (h *categoryTemplateHandler) ListPage(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+	sets, err := h.templateService.Sets(spaceID)
+	if err != nil {
+		slog.Error("failed to list category templates", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load category templates", http.StatusInternalServerError)
+		return
+	}
+	ui.Render(w, r, pages.SpaceCategoryTemplatesPage(pages.SpaceCategoryTemplatesPageProps{
+		SpaceID:    spaceID,
+		SpaceName:  space.Name,
+		Templates:  sets,
+		CreateForm: h.createFormProps(spaceID),
+	}))
+}
+
+func (h *categoryTemplateHandler) createFormProps(spaceID string) forms.CreateCategoryTemplateProps {
+	props := forms.CreateCategoryTemplateProps{SpaceID: spaceID}
+	accounts, err := h.accountService.GetAccountsForSpace(spaceID)
+	if err != nil {
+		slog.Error("failed to load accounts", "error", err, "space_id", spaceID)
+		return props
+	}
+	props.Accounts = accounts
+	return props
+}
+
+func (h *categoryTemplateHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	name := strings.TrimSpace(r.FormValue("name"))
+	accountID := r.FormValue("account_id")
+
+	var err error
+	if accountID == "" {
+		_, err = h.templateService.Create(spaceID, name)
+	} else {
+		_, err = h.templateService.SaveFromAccount(spaceID, accountID, name)
+	}
+	if err != nil {
+		formProps := h.createFormProps(spaceID)
+		formProps.Name = name
+		formProps.AccountID = accountID
+		switch {
+		case errors.Is(err, service.ErrCategoryTemplateNameTaken):
+			formProps.NameErr = "A template with this name already exists."
+		case errors.Is(err, repository.ErrAccountNotFound):
+			formProps.AccountErr = "That account no longer exists."
+		case name == "":
+			formProps.NameErr = "Name is required."
+		case len(name) > 60:
+			formProps.NameErr = "Name must be at most 60 characters."
+		default:
+			slog.Error("failed to create category template", "error", err, "space_id", spaceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.CreateCategoryTemplate(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *categoryTemplateHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	templateID := r.PathValue("templateID")
+
+	if err := h.templateService.Delete(spaceID, templateID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCategoryTemplateNotFound):
+			ui.RenderError(w, r, "Template not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrCategoryTemplateBuiltIn):
+			ui.RenderError(w, r, "The default template can't be deleted.", http.StatusUnprocessableEntity)
+		default:
+			slog.Error("failed to delete category template", "error", err, "template_id", templateID)
+			ui.RenderError(w, r, "Failed to delete template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *categoryTemplateHandler) HandleAddItem(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	templateID := r.PathValue("templateID")
+	name := strings.TrimSpace(r.FormValue("name"))
+	parentID := r.FormValue("parent_id")
+
+	if _, err := h.templateService.AddItem(spaceID, templateID, parentID, name); err != nil {
+		formProps := forms.CategoryTemplateItemProps{
+			SpaceID:    spaceID,
+			TemplateID: templateID,
+			Name:       name,
+			ParentID:   parentID,
+		}
+		if cats, catsErr := h.templateService.Categories(spaceID, templateID); catsErr == nil {
+			formProps.Categories = model.CategoryTree(cats)
+		}
+		switch {
+		case errors.Is(err, service.ErrCategoryTemplateNotFound):
+			ui.RenderError(w, r, "Template not found", http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrCategoryTemplateBuiltIn):
+			ui.RenderError(w, r, "The default template can't be changed.", http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, service.ErrCategoryNameTaken):
+			formProps.NameErr = "The template already has a category with this name."
+		case errors.Is(err, service.ErrCategoryNotFound):
+			formProps.ParentErr = "The parent category is no longer in the template."
+		case errors.Is(err, service.ErrCategoryTooDeep):
+			formProps.ParentErr = "That category can't have subcategories."
+		case name == "":
+			formProps.NameErr = "Name is required."
+		case len(name) > 60:
+			formProps.NameErr = "Name must be at most 60 characters."
+		default:
+			slog.Error("failed to add category to template", "error", err, "template_id", templateID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.CategoryTemplateItem(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *categoryTemplateHandler) HandleDeleteItem(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	templateID := r.PathValue("templateID")
+	itemID := r.PathValue("itemID")
+
+	if err := h.templateService.DeleteItem(spaceID, templateID, itemID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCategoryTemplateNotFound):
+			ui.RenderError(w, r, "Category not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrCategoryTemplateBuiltIn):
+			ui.RenderError(w, r, "The default template can't be changed.", http.StatusUnprocessableEntity)
+		default:
+			slog.Error("failed to remove category from template", "error", err, "item_id", itemID)
+			ui.RenderError(w, r, "Failed to remove category", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
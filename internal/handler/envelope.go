@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/forms"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+	"github.com/shopspring/decimal"
+)
+
+type envelopeHandler struct {
+	envelopeService *service.EnvelopeService
+	spaceService    *service.SpaceService
+}
+
+func NewEnvelopeHandler(envelopeService *service.EnvelopeService, spaceService *service.SpaceService) *envelopeHandler {
+	return &envelopeHandler{envelopeService: envelopeService, spaceService: spaceService}
+}
+
+func envelopeActorID(r *http.Request) string {
+	if u := ctxkeys.User(r.Context()); u != nil {
+		return u.ID
+	}
+	return ""
+}
+
+// Page shows one month of the space's envelope budget, or how to turn
+// envelope budgeting on. The month comes from ?month=YYYY-MM and defaults to
+// the current one.
+func (h *envelopeHandler) Page(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+	props := pages.SpaceEnvelopesPageProps{SpaceID: space.ID, SpaceName: space.Name}
+	if space.EnvelopeBudgetSince == nil {
+		ui.Render(w, r, pages.SpaceEnvelopesPage(props))
+		return
+	}
+
+	since := model.MonthStart(*space.EnvelopeBudgetSince)
+	month := model.MonthStart(time.Now())
+	if v := r.URL.Query().Get("month"); v != "" {
+		if parsed, err := time.Parse("2006-01", v); err == nil {
+			month = parsed
+		}
+	}
+	if month.Before(since) {
+		month = since
+	}
+
+	budget, err := h.envelopeService.Budget(space.ID, month)
+	if err != nil {
+		slog.Error("failed to load envelope budget", "error", err, "space_id", space.ID)
+		ui.RenderError(w, r, "Failed to load envelopes", http.StatusInternalServerError)
+		return
+	}
+
+	props.Enabled = true
+	props.Month = month.Format("2006-01")
+	props.PrevMonth = month.AddDate(0, -1, 0).Format("2006-01")
+	props.NextMonth = month.AddDate(0, 1, 0).Format("2006-01")
+	props.HasPrev = month.After(since)
+	props.Budget = budget
+	props.MoveForm = forms.MoveEnvelopeMoneyProps{SpaceID: space.ID, Month: props.Month, Envelopes: budget.Envelopes}
+	ui.Render(w, r, pages.SpaceEnvelopesPage(props))
+}
+
+func (h *envelopeHandler) HandleEnable(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	if err := h.envelopeService.Enable(spaceID, envelopeActorID(r)); err != nil {
+		slog.Error("failed to enable envelope budgeting", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to turn on envelope budgeting", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *envelopeHandler) HandleDisable(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	if err := h.envelopeService.Disable(spaceID, envelopeActorID(r)); err != nil {
+		slog.Error("failed to disable envelope budgeting", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to turn off envelope budgeting", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseEnvelopeAmount reads an amount in the space's reporting currency,
+// returning the message to show when it isn't one.
+func (h *envelopeHandler) parseEnvelopeAmount(r *http.Request, spaceID, input string) (decimal.Decimal, string) {
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		return decimal.Zero, "Something went wrong. Please try again."
+	}
+	cur := ctxkeys.Currencies(r.Context()).Get(space.ReportingCurrency)
+	amount, err := decimal.NewFromString(input)
+	switch {
+	case input == "":
+		return decimal.Zero, "Amount is required."
+	case err != nil:
+		return decimal.Zero, "Enter a valid amount (e.g. 250.00)."
+	case amount.IsNegative():
+		return decimal.Zero, "Amount can't be negative."
+	case !cur.Fits(amount):
+		return decimal.Zero, decimalsErr("Amount", cur)
+	}
+	return amount, ""
+}
+
+// HandleAssign sets what an envelope is assigned for the month.
+func (h *envelopeHandler) HandleAssign(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	month, err := time.Parse("2006-01", r.FormValue("month"))
+	if err != nil {
+		ui.RenderError(w, r, "Pick a month to assign money in.", http.StatusUnprocessableEntity)
+		return
+	}
+	amount, msg := h.parseEnvelopeAmount(r, spaceID, strings.TrimSpace(r.FormValue("amount")))
+	if msg != "" {
+		ui.RenderError(w, r, msg, http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = h.envelopeService.Assign(service.AssignEnvelopeInput{
+		SpaceID:  spaceID,
+		Envelope: r.FormValue("envelope"),
+		Month:    month,
+		Amount:   amount,
+		ActorID:  envelopeActorID(r),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrEnvelopeNotFound):
+			ui.RenderError(w, r, "That envelope no longer exists.", http.StatusNotFound)
+		case errors.Is(err, service.ErrEnvelopeBudgetingOff):
+			ui.RenderError(w, r, "Envelope budgeting is off for this space.", http.StatusUnprocessableEntity)
+		case errors.Is(err, service.ErrEnvelopeMonthBeforeStart):
+			ui.RenderError(w, r, "The envelope budget starts after that month.", http.StatusUnprocessableEntity)
+		default:
+			slog.Error("failed to assign envelope money", "error", err, "space_id", spaceID)
+			ui.RenderError(w, r, "Failed to assign money", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleMove moves money assigned to one envelope into another.
+func (h *envelopeHandler) HandleMove(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	amountInput := strings.TrimSpace(r.FormValue("amount"))
+	formProps := forms.MoveEnvelopeMoneyProps{
+		SpaceID: spaceID,
+		Month:   r.FormValue("month"),
+		From:    r.FormValue("from"),
+		To:      r.FormValue("to"),
+		Amount:  amountInput,
+	}
+	month, err := time.Parse("2006-01", formProps.Month)
+	if err != nil {
+		ui.RenderError(w, r, "Pick a month to move money in.", http.StatusUnprocessableEntity)
+		return
+	}
+	budget, err := h.envelopeService.Budget(spaceID, month)
+	if err != nil {
+		slog.Error("failed to load envelope budget", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to move money", http.StatusInternalServerError)
+		return
+	}
+	formProps.Envelopes = budget.Envelopes
+
+	amount, msg := h.parseEnvelopeAmount(r, spaceID, amountInput)
+	if msg == "" && amount.IsZero() {
+		msg = "Amount must be greater than zero."
+	}
+	if msg != "" {
+		formProps.AmountErr = msg
+		ui.Render(w, r, forms.MoveEnvelopeMoney(formProps))
+		return
+	}
+
+	err = h.envelopeService.Move(service.MoveEnvelopeMoneyInput{
+		SpaceID: spaceID,
+		From:    formProps.From,
+		To:      formProps.To,
+		Month:   month,
+		Amount:  amount,
+		ActorID: envelopeActorID(r),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidEnvelopeMove):
+			formProps.ToErr = "Pick a different envelope to move money into."
+		case errors.Is(err, service.ErrEnvelopeNotFound):
+			formProps.GeneralErr = "One of those envelopes no longer exists."
+		default:
+			slog.Error("failed to move envelope money", "error", err, "space_id", spaceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.MoveEnvelopeMoney(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
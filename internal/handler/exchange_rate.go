@@ -0,0 +1,303 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/forms"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+	"github.com/shopspring/decimal"
+)
+
+// maxRateFileSize bounds rate uploads. The ECB's full history since 1999 is
+// a little over 2 MB.
+const maxRateFileSize = 8 << 20
+
+const exchangeRatesPerPage = 50
+
+type exchangeRateHandler struct {
+	rateService     *service.ExchangeRateService
+	spaceService    *service.SpaceService
+	currencyService *service.CurrencyService
+}
+
+func NewExchangeRateHandler(rateService *service.ExchangeRateService, spaceService *service.SpaceService, currencyService *service.CurrencyService) *exchangeRateHandler {
+	return &exchangeRateHandler{rateService: rateService, spaceService: spaceService, currencyService: currencyService}
+}
+
+// RatesPage lists the space's own currencies and exchange rates, newest
+// first, with the forms to set the reporting currency, add a currency, add a
+// rate and import a rate file.
+func (h *exchangeRateHandler) RatesPage(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+
+	page := 1
+	if p := strings.TrimSpace(r.URL.Query().Get("page")); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+	total, err := h.rateService.Count(spaceID)
+	if err != nil {
+		slog.Error("failed to count exchange rates", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load exchange rates", http.StatusInternalServerError)
+		return
+	}
+	totalPages := (total + exchangeRatesPerPage - 1) / exchangeRatesPerPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	rates, err := h.rateService.List(spaceID, exchangeRatesPerPage, (page-1)*exchangeRatesPerPage)
+	if err != nil {
+		slog.Error("failed to list exchange rates", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load exchange rates", http.StatusInternalServerError)
+		return
+	}
+	currencies, err := h.currencyService.List(spaceID)
+	if err != nil {
+		slog.Error("failed to list space currencies", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	ui.Render(w, r, pages.SpaceExchangeRatesPage(pages.SpaceExchangeRatesPageProps{
+		SpaceID:      space.ID,
+		SpaceName:    space.Name,
+		CurrencyForm: forms.ReportingCurrencyProps{SpaceID: space.ID, Currency: space.ReportingCurrency},
+		RateForm: forms.ExchangeRateProps{
+			SpaceID: space.ID,
+			Base:    "USD",
+			Quote:   space.ReportingCurrency,
+			Date:    time.Now().Format("2006-01-02"),
+		},
+		ImportForm:  forms.ImportExchangeRatesProps{SpaceID: space.ID},
+		Currencies:  currencies,
+		CustomForm:  forms.SpaceCurrencyProps{SpaceID: space.ID, MinorUnits: "2"},
+		Rates:       rates,
+		CurrentPage: page,
+		TotalPages:  totalPages,
+		TotalCount:  total,
+	}))
+}
+
+func (h *exchangeRateHandler) HandleSetReportingCurrency(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	code := currency.Normalize(r.FormValue("currency"))
+	formProps := forms.ReportingCurrencyProps{SpaceID: spaceID, Currency: code}
+
+	actorID := ""
+	if user := ctxkeys.User(r.Context()); user != nil {
+		actorID = user.ID
+	}
+	if err := h.spaceService.SetReportingCurrency(spaceID, code, actorID); err != nil {
+		if errors.Is(err, service.ErrUnsupportedCurrency) {
+			formProps.GeneralErr = "Choose one of the listed currencies."
+		} else {
+			slog.Error("failed to set reporting currency", "error", err, "space_id", spaceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.ReportingCurrency(formProps))
+		return
+	}
+
+	formProps.SuccessMsg = "Totals and reports are now shown in " + code + "."
+	ui.Render(w, r, forms.ReportingCurrency(formProps))
+}
+
+func (h *exchangeRateHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	formProps := forms.ExchangeRateProps{
+		SpaceID: spaceID,
+		Base:    currency.Normalize(r.FormValue("base")),
+		Quote:   currency.Normalize(r.FormValue("quote")),
+		Date:    strings.TrimSpace(r.FormValue("date")),
+		Rate:    strings.TrimSpace(r.FormValue("rate")),
+	}
+
+	date, err := time.Parse("2006-01-02", formProps.Date)
+	if err != nil {
+		formProps.DateErr = "Enter a valid date."
+	}
+	rate, err := decimal.NewFromString(formProps.Rate)
+	if err != nil || !rate.IsPositive() {
+		formProps.RateErr = "Enter a rate greater than zero."
+	}
+	if formProps.DateErr != "" || formProps.RateErr != "" {
+		ui.Render(w, r, forms.ExchangeRate(formProps))
+		return
+	}
+
+	_, err = h.rateService.SetRate(service.SetRateInput{
+		SpaceID: spaceID,
+		Base:    formProps.Base,
+		Quote:   formProps.Quote,
+		Date:    date,
+		Rate:    rate,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSameCurrency):
+			formProps.PairErr = "Choose two different currencies."
+		case errors.Is(err, service.ErrUnsupportedCurrency):
+			formProps.PairErr = "Choose one of the listed currencies."
+		default:
+			slog.Error("failed to save exchange rate", "error", err, "space_id", spaceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.ExchangeRate(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *exchangeRateHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	formProps := forms.ImportExchangeRatesProps{SpaceID: spaceID}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRateFileSize+(1<<20))
+	if err := r.ParseMultipartForm(maxRateFileSize); err != nil {
+		formProps.GeneralErr = "Choose a file of at most 8 MB."
+		ui.Render(w, r, forms.ImportExchangeRates(formProps))
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		formProps.GeneralErr = "Choose a file to import."
+		ui.Render(w, r, forms.ImportExchangeRates(formProps))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxRateFileSize+1))
+	if err != nil || len(data) > maxRateFileSize {
+		formProps.GeneralErr = "Choose a file of at most 8 MB."
+		ui.Render(w, r, forms.ImportExchangeRates(formProps))
+		return
+	}
+
+	if _, err := h.rateService.Import(spaceID, data); err != nil {
+		if errors.Is(err, service.ErrUnrecognizedRateFile) {
+			formProps.GeneralErr = "Couldn't read that file. Upload an ECB reference-rate XML file or a Bank of Canada CSV file."
+		} else {
+			slog.Error("failed to import exchange rates", "error", err, "space_id", spaceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.ImportExchangeRates(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *exchangeRateHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	rateID := r.PathValue("rateID")
+
+	if err := h.rateService.Delete(spaceID, rateID); err != nil {
+		if errors.Is(err, service.ErrExchangeRateNotFound) {
+			ui.RenderError(w, r, "Exchange rate not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete exchange rate", "error", err, "rate_id", rateID)
+		ui.RenderError(w, r, "Failed to delete exchange rate", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *exchangeRateHandler) HandleAddCurrency(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	formProps := forms.SpaceCurrencyProps{
+		SpaceID:    spaceID,
+		Code:       currency.Normalize(r.FormValue("code")),
+		Name:       strings.TrimSpace(r.FormValue("name")),
+		Symbol:     strings.TrimSpace(r.FormValue("symbol")),
+		MinorUnits: strings.TrimSpace(r.FormValue("minor_units")),
+	}
+
+	minorUnits, err := strconv.ParseInt(formProps.MinorUnits, 10, 32)
+	if err != nil {
+		formProps.MinorUnitsErr = "Enter a whole number of decimals."
+		ui.Render(w, r, forms.SpaceCurrency(formProps))
+		return
+	}
+
+	actorID := ""
+	if user := ctxkeys.User(r.Context()); user != nil {
+		actorID = user.ID
+	}
+	_, err = h.currencyService.AddCurrency(service.AddCurrencyInput{
+		SpaceID:    spaceID,
+		Code:       formProps.Code,
+		Name:       formProps.Name,
+		Symbol:     formProps.Symbol,
+		MinorUnits: int32(minorUnits),
+		ActorID:    actorID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, currency.ErrInvalidCode):
+			formProps.CodeErr = "Use 2 to 10 letters or digits."
+		case errors.Is(err, currency.ErrISOCode):
+			formProps.CodeErr = formProps.Code + " is already available; pick it from any currency list."
+		case errors.Is(err, service.ErrDuplicateCurrency):
+			formProps.CodeErr = "This space already has " + formProps.Code + "."
+		case errors.Is(err, currency.ErrInvalidMinorUnits):
+			formProps.MinorUnitsErr = "Decimals must be between 0 and 18."
+		default:
+			slog.Error("failed to add currency", "error", err, "space_id", spaceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.SpaceCurrency(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *exchangeRateHandler) HandleRemoveCurrency(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	currencyID := r.PathValue("currencyID")
+
+	actorID := ""
+	if user := ctxkeys.User(r.Context()); user != nil {
+		actorID = user.ID
+	}
+	if err := h.currencyService.RemoveCurrency(spaceID, currencyID, actorID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrSpaceCurrencyNotFound):
+			ui.RenderError(w, r, "Currency not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrCurrencyInUse):
+			ui.RenderError(w, r, "An account or the reporting currency still uses this currency", http.StatusConflict)
+		default:
+			slog.Error("failed to remove currency", "error", err, "currency_id", currencyID)
+			ui.RenderError(w, r, "Failed to remove currency", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+)
+
+type exportHandler struct {
+	exportService  *service.ExportService
+	accountService *service.AccountService
+	spaceService   *service.SpaceService
+}
+
+func NewExportHandler(exportService *service.ExportService, accountService *service.AccountService, spaceService *service.SpaceService) *exportHandler {
+	return &exportHandler{
+		exportService:  exportService,
+		accountService: accountService,
+		spaceService:   spaceService,
+	}
+}
+
+// ExportAccountTransactions downloads the account's transactions, narrowed by
+// the same query params as the transactions page.
+func (h *exportHandler) ExportAccountTransactions(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+	format, err := service.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		ui.RenderError(w, r, "Unsupported export format", http.StatusBadRequest)
+		return
+	}
+	filter, _ := parseTransactionFilter(r)
+
+	setExportHeaders(w, account.Name, format)
+	if err := h.exportService.ExportAccount(w, account.ID, format, filter); err != nil {
+		// Headers and part of the body may already be on the wire, so the
+		// best we can do is log; the client sees a truncated file.
+		slog.Error("failed to export transactions", "error", err, "account_id", account.ID)
+	}
+}
+
+// ExportSpaceTransactions downloads the transactions of every account in the
+// space.
+func (h *exportHandler) ExportSpaceTransactions(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to export transactions", http.StatusInternalServerError)
+		return
+	}
+	format, err := service.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		ui.RenderError(w, r, "Unsupported export format", http.StatusBadRequest)
+		return
+	}
+	filter, _ := parseTransactionFilter(r)
+
+	setExportHeaders(w, space.Name, format)
+	if err := h.exportService.ExportSpace(w, space.ID, format, filter); err != nil {
+		slog.Error("failed to export transactions", "error", err, "space_id", space.ID)
+	}
+}
+
+func setExportHeaders(w http.ResponseWriter, name string, format service.ExportFormat) {
+	filename := fmt.Sprintf("%s-transactions-%s.%s", exportFilenamePart(name), time.Now().Format("20060102"), format.Extension())
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// exportFilenamePart reduces a display name to a safe, lower-case slug for
+// use in a download filename.
+func exportFilenamePart(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return "budgit"
+	}
+	return slug
+}
@@ -0,0 +1,400 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/ofx"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/routeurl"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/blocks"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+)
+
+// maxImportFileSize bounds statement uploads. Bank CSV and OFX exports for a
+// few thousand rows are well under this.
+const maxImportFileSize = 5 << 20
+
+// recentImportBatches is how many past imports the import page lists.
+const recentImportBatches = 20
+
+type importHandler struct {
+	importService  *service.ImportService
+	accountService *service.AccountService
+	spaceService   *service.SpaceService
+}
+
+func NewImportHandler(importService *service.ImportService, accountService *service.AccountService, spaceService *service.SpaceService) *importHandler {
+	return &importHandler{
+		importService:  importService,
+		accountService: accountService,
+		spaceService:   spaceService,
+	}
+}
+
+func (h *importHandler) loadAccount(w http.ResponseWriter, r *http.Request) (*model.Account, bool) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.Render(w, r, pages.NotFound())
+		return nil, false
+	}
+	return account, true
+}
+
+func (h *importHandler) ImportPage(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	space, err := h.spaceService.GetSpace(account.SpaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	batches, err := h.importService.ListBatches(account.ID, recentImportBatches)
+	if err != nil {
+		slog.Error("failed to list import batches", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	ui.Render(w, r, pages.SpaceAccountImportPage(pages.SpaceAccountImportPageProps{
+		SpaceID:     space.ID,
+		SpaceName:   space.Name,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		Batches:     batches,
+	}))
+}
+
+// readImportUpload returns the statement contents and filename. The first step
+// posts the file itself; later steps round-trip it through statement_data.
+func readImportUpload(w http.ResponseWriter, r *http.Request) (data []byte, filename string, fromUpload bool, err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize+(1<<20))
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+			return nil, "", false, err
+		}
+		file, header, err := r.FormFile("file")
+		if err == nil {
+			defer file.Close()
+			data, err := io.ReadAll(io.LimitReader(file, maxImportFileSize+1))
+			if err != nil {
+				return nil, "", false, err
+			}
+			if len(data) > maxImportFileSize {
+				return nil, "", false, errImportFileTooLarge
+			}
+			return data, header.Filename, true, nil
+		}
+	}
+	return []byte(r.FormValue("statement_data")), strings.TrimSpace(r.FormValue("filename")), false, nil
+}
+
+var errImportFileTooLarge = errors.New("import file too large")
+
+// importMappingMessage turns a CSVMapping validation error into a sentence.
+func importMappingMessage(err error) string {
+	msg := err.Error()
+	return strings.ToUpper(msg[:1]) + msg[1:] + "."
+}
+
+func parseImportMapping(r *http.Request) service.CSVMapping {
+	col := func(name string) int {
+		i, err := strconv.Atoi(strings.TrimSpace(r.FormValue(name)))
+		if err != nil {
+			return -1
+		}
+		return i
+	}
+	return service.CSVMapping{
+		Date:         col("map_date"),
+		Title:        col("map_title"),
+		Amount:       col("map_amount"),
+		Debit:        col("map_debit"),
+		Credit:       col("map_credit"),
+		Description:  col("map_description"),
+		Category:     col("map_category"),
+		DateFormat:   r.FormValue("date_format"),
+		InvertAmount: r.FormValue("invert_amount") == "true",
+	}
+}
+
+// HandlePreview parses the uploaded statement and renders the column mapping
+// plus a preview of the rows. Nothing is written.
+func (h *importHandler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	uploadProps := blocks.ImportUploadProps{SpaceID: account.SpaceID, AccountID: account.ID}
+
+	data, filename, fromUpload, err := readImportUpload(w, r)
+	if err != nil {
+		if errors.Is(err, errImportFileTooLarge) {
+			uploadProps.Err = "That file is too large. Split the statement and import it in parts."
+		} else {
+			slog.Error("failed to read import upload", "error", err, "account_id", account.ID)
+			uploadProps.Err = "We couldn't read that file. Please try again."
+		}
+		ui.Render(w, r, blocks.ImportUpload(uploadProps))
+		return
+	}
+	if ofx.Looks(data) {
+		h.previewOFX(w, r, account, data, filename, uploadProps)
+		return
+	}
+	file, err := service.ParseCSV(data)
+	if err != nil {
+		uploadProps.Err = "We couldn't read that file as CSV: " + err.Error() + "."
+		ui.Render(w, r, blocks.ImportUpload(uploadProps))
+		return
+	}
+
+	mapping := parseImportMapping(r)
+	if fromUpload {
+		mapping = service.GuessCSVMapping(file.Header)
+	}
+
+	props := blocks.ImportPreviewProps{
+		SpaceID:   account.SpaceID,
+		AccountID: account.ID,
+		Filename:  filename,
+		Source:    model.ImportSourceCSV,
+		Currency:  account.Currency,
+		Data:      string(data),
+		Header:    file.Header,
+		Mapping:   mapping,
+	}
+	if err := mapping.Validate(len(file.Header)); err != nil {
+		// A fresh upload whose headers we couldn't guess isn't an error yet;
+		// only flag it once the user has submitted a mapping.
+		if !fromUpload {
+			props.MappingErr = importMappingMessage(err)
+		}
+		ui.Render(w, r, blocks.ImportPreview(props))
+		return
+	}
+
+	preview, err := h.importService.PreviewCSV(account.ID, file, mapping)
+	if err != nil {
+		slog.Error("failed to preview import", "error", err, "account_id", account.ID)
+		props.MappingErr = "Something went wrong. Please try again."
+		ui.Render(w, r, blocks.ImportPreview(props))
+		return
+	}
+	props.Preview = preview
+	ui.Render(w, r, blocks.ImportPreview(props))
+}
+
+// previewOFX renders the preview for an OFX/QFX statement. There is no
+// mapping step; rows already imported by FITID are shown but can't be
+// selected.
+func (h *importHandler) previewOFX(w http.ResponseWriter, r *http.Request, account *model.Account, data []byte, filename string, uploadProps blocks.ImportUploadProps) {
+	stmt, err := ofx.Parse(data)
+	if err != nil {
+		uploadProps.Err = "We couldn't read that file as OFX: " + err.Error() + "."
+		ui.Render(w, r, blocks.ImportUpload(uploadProps))
+		return
+	}
+
+	props := blocks.ImportPreviewProps{
+		SpaceID:   account.SpaceID,
+		AccountID: account.ID,
+		Filename:  filename,
+		Source:    model.ImportSourceOFX,
+		Currency:  account.Currency,
+		Data:      string(data),
+	}
+	preview, err := h.importService.PreviewOFX(account.ID, stmt)
+	if err != nil {
+		if errors.Is(err, service.ErrImportCurrencyMismatch) {
+			uploadProps.Err = "This statement is in " + stmt.Currency + " but the account is in " + account.Currency + "."
+			ui.Render(w, r, blocks.ImportUpload(uploadProps))
+			return
+		}
+		slog.Error("failed to preview import", "error", err, "account_id", account.ID)
+		props.MappingErr = "Something went wrong. Please try again."
+		ui.Render(w, r, blocks.ImportPreview(props))
+		return
+	}
+	props.Preview = preview
+	ui.Render(w, r, blocks.ImportPreview(props))
+}
+
+// importSelection turns the preview's include checkboxes into the set of rows
+// to skip, given every row number the preview offered.
+func importSelection(r *http.Request, rows []int) map[int]bool {
+	include := map[int]bool{}
+	for _, v := range r.Form["include"] {
+		if line, err := strconv.Atoi(v); err == nil {
+			include[line] = true
+		}
+	}
+	skip := map[int]bool{}
+	for _, line := range rows {
+		if !include[line] {
+			skip[line] = true
+		}
+	}
+	return skip
+}
+
+// HandleCommit imports the rows the user kept selected in the preview as a
+// single batch, then redirects to the batch's review page.
+func (h *importHandler) HandleCommit(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+
+	data, filename, _, err := readImportUpload(w, r)
+	if err != nil {
+		slog.Error("failed to read import payload", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "We couldn't read that import. Please start over.", http.StatusBadRequest)
+		return
+	}
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+
+	var batch *model.ImportBatch
+	if ofx.Looks(data) {
+		var stmt *ofx.Statement
+		if stmt, err = ofx.Parse(data); err != nil {
+			ui.RenderError(w, r, "We couldn't read that import. Please start over.", http.StatusBadRequest)
+			return
+		}
+		rows := make([]int, len(stmt.Transactions))
+		for i := range stmt.Transactions {
+			rows[i] = i + 1
+		}
+		batch, err = h.importService.CommitOFX(service.CommitOFXImportInput{
+			AccountID: account.ID,
+			ActorID:   actorID,
+			Filename:  filename,
+			Statement: stmt,
+			Skip:      importSelection(r, rows),
+		})
+	} else {
+		var file *service.CSVFile
+		if file, err = service.ParseCSV(data); err != nil {
+			ui.RenderError(w, r, "We couldn't read that import. Please start over.", http.StatusBadRequest)
+			return
+		}
+		mapping := parseImportMapping(r)
+		if err := mapping.Validate(len(file.Header)); err != nil {
+			ui.RenderError(w, r, importMappingMessage(err), http.StatusBadRequest)
+			return
+		}
+		batch, err = h.importService.CommitCSV(service.CommitCSVImportInput{
+			AccountID: account.ID,
+			ActorID:   actorID,
+			Filename:  filename,
+			File:      file,
+			Mapping:   mapping,
+			Skip:      importSelection(r, file.Lines),
+		})
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrImportNoRows) {
+			ui.RenderError(w, r, "Select at least one row to import.", http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, service.ErrImportCurrencyMismatch) {
+			ui.RenderError(w, r, "This statement's currency doesn't match the account.", http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, service.ErrAccountArchived) {
+			ui.RenderError(w, r, "This account is archived. Unarchive it from its settings to import transactions.", http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to commit import", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to import transactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", routeurl.URL(
+		"page.app.spaces.space.accounts.account.import.batch",
+		"spaceID", account.SpaceID,
+		"accountID", account.ID,
+		"batchID", batch.ID,
+	))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *importHandler) ImportBatchPage(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	batch, err := h.importService.GetBatch(account.ID, r.PathValue("batchID"))
+	if err != nil {
+		if !errors.Is(err, service.ErrImportBatchNotFound) {
+			slog.Error("failed to load import batch", "error", err, "account_id", account.ID)
+		}
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+	space, err := h.spaceService.GetSpace(account.SpaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	txns, err := h.importService.BatchTransactions(batch.ID)
+	if err != nil {
+		slog.Error("failed to list import batch transactions", "error", err, "batch_id", batch.ID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	ui.Render(w, r, pages.SpaceImportBatchPage(pages.SpaceImportBatchPageProps{
+		SpaceID:         space.ID,
+		SpaceName:       space.Name,
+		AccountID:       account.ID,
+		AccountName:     account.Name,
+		AccountCurrency: account.Currency,
+		Batch:           batch,
+		Transactions:    txns,
+	}))
+}
+
+func (h *importHandler) HandleRollbackBatch(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	batchID := r.PathValue("batchID")
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+	if _, err := h.importService.RollbackBatch(account.ID, batchID, actorID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrImportBatchNotFound):
+			ui.RenderError(w, r, "Import not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrImportBatchRolledBack):
+			ui.RenderError(w, r, "This import was already rolled back.", http.StatusConflict)
+		default:
+			slog.Error("failed to roll back import", "error", err, "batch_id", batchID)
+			ui.RenderError(w, r, "Failed to roll back import", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -11,6 +12,7 @@ import (
 
 	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
 	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"git.juancwu.dev/juancwu/budgit/internal/routeurl"
 	"git.juancwu.dev/juancwu/budgit/internal/service"
 	"git.juancwu.dev/juancwu/budgit/internal/ui"
@@ -165,6 +167,11 @@ func (h *investmentHandler) HoldingDetailPage(w http.ResponseWriter, r *http.Req
 		slog.Error("failed to load trades", "error", err)
 		trades = nil
 	}
+	prices, err := h.investmentService.ListPrices(holdingID)
+	if err != nil {
+		slog.Error("failed to load prices", "error", err)
+		prices = nil
+	}
 	space, err := h.spaceService.GetSpace(account.SpaceID)
 	if err != nil {
 		ui.Render(w, r, pages.NotFound())
@@ -178,6 +185,7 @@ func (h *investmentHandler) HoldingDetailPage(w http.ResponseWriter, r *http.Req
 		Currency:    account.Currency,
 		Position:    *pos,
 		Trades:      trades,
+		Prices:      prices,
 	}))
 }
 
@@ -305,6 +313,73 @@ func (h *investmentHandler) HandleDeleteTrade(w http.ResponseWriter, r *http.Req
 	w.WriteHeader(http.StatusOK)
 }
 
+// HandleRecordPrice stores the holding's market price for a day. Net worth
+// values the holding at its latest price instead of its cost basis.
+func (h *investmentHandler) HandleRecordPrice(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadInvestmentAccount(w, r)
+	if !ok {
+		return
+	}
+	holdingID := r.PathValue("holdingID")
+	holding, err := h.investmentService.GetHolding(holdingID)
+	if err != nil || holding.AccountID != account.ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	price, err := decimal.NewFromString(strings.TrimSpace(r.FormValue("price")))
+	if err != nil || price.IsNegative() {
+		http.Error(w, "invalid price", http.StatusBadRequest)
+		return
+	}
+	day, err := time.Parse("2006-01-02", strings.TrimSpace(r.FormValue("price_date")))
+	if err != nil {
+		http.Error(w, "invalid date", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.investmentService.RecordPrice(holdingID, day, price); err != nil {
+		slog.Error("failed to record price", "error", err)
+		http.Error(w, "could not record price", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Redirect", routeurl.URL(
+		"page.app.spaces.space.accounts.account.investments.holdings.holding",
+		"spaceID", account.SpaceID, "accountID", account.ID, "holdingID", holdingID,
+	))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *investmentHandler) HandleDeletePrice(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadInvestmentAccount(w, r)
+	if !ok {
+		return
+	}
+	holdingID := r.PathValue("holdingID")
+	holding, err := h.investmentService.GetHolding(holdingID)
+	if err != nil || holding.AccountID != account.ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	day, err := time.Parse("2006-01-02", r.PathValue("priceDate"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := h.investmentService.DeletePrice(holdingID, day); err != nil {
+		if errors.Is(err, repository.ErrInvestmentPriceNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete price", "error", err)
+		http.Error(w, "could not delete price", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Redirect", routeurl.URL(
+		"page.app.spaces.space.accounts.account.investments.holdings.holding",
+		"spaceID", account.SpaceID, "accountID", account.ID, "holdingID", holdingID,
+	))
+	w.WriteHeader(http.StatusOK)
+}
+
 // ---------- Top-level /app/investments page ----------
 
 func (h *investmentHandler) InvestmentsOverviewPage(w http.ResponseWriter, r *http.Request) {
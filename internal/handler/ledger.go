@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+)
+
+type ledgerHandler struct {
+	ledgerService  *service.LedgerService
+	spaceService   *service.SpaceService
+	accountService *service.AccountService
+	rateService    *service.ExchangeRateService
+}
+
+func NewLedgerHandler(ledgerService *service.LedgerService, spaceService *service.SpaceService, accountService *service.AccountService, rateService *service.ExchangeRateService) *ledgerHandler {
+	return &ledgerHandler{
+		ledgerService:  ledgerService,
+		spaceService:   spaceService,
+		accountService: accountService,
+		rateService:    rateService,
+	}
+}
+
+// LedgerPage shows the space's trial balance and balance sheet in each
+// currency as of the as_of date (YYYY-MM-DD), defaulting to today, along with
+// the space's net worth on that date in its reporting currency.
+func (h *ledgerHandler) LedgerPage(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load ledger", http.StatusInternalServerError)
+		return
+	}
+
+	asOf := time.Now().UTC()
+	if v := strings.TrimSpace(r.URL.Query().Get("as_of")); v != "" {
+		if parsed, err := time.Parse("2006-01-02", v); err == nil {
+			asOf = parsed
+		}
+	}
+
+	trials, err := h.ledgerService.TrialBalances(spaceID, asOf)
+	if err != nil {
+		slog.Error("failed to load trial balance", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load ledger", http.StatusInternalServerError)
+		return
+	}
+	sheets, err := h.ledgerService.BalanceSheets(spaceID, asOf)
+	if err != nil {
+		slog.Error("failed to load balance sheet", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load ledger", http.StatusInternalServerError)
+		return
+	}
+
+	accounts, err := h.accountService.GetAccountsForSpace(spaceID)
+	if err != nil {
+		slog.Error("failed to load accounts", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load ledger", http.StatusInternalServerError)
+		return
+	}
+	endOfDay := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, asOf.Location()).AddDate(0, 0, 1)
+	netWorth, err := h.rateService.ConvertedTotals(spaceID, accounts, space.ReportingCurrency, endOfDay)
+	if err != nil {
+		slog.Error("failed to convert net worth", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load ledger", http.StatusInternalServerError)
+		return
+	}
+
+	ui.Render(w, r, pages.SpaceLedgerPage(pages.SpaceLedgerPageProps{
+		SpaceID:       space.ID,
+		SpaceName:     space.Name,
+		AsOf:          asOf.Format("2006-01-02"),
+		TrialBalances: trials,
+		BalanceSheets: sheets,
+		NetWorth:      netWorth,
+	}))
+}
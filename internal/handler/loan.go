@@ -0,0 +1,389 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/timezone"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/blocks"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/forms"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+	"github.com/shopspring/decimal"
+)
+
+// loanPaymentFireHour is when on a due date scheduled loan payments are made.
+const loanPaymentFireHour = 9
+
+type loanHandler struct {
+	loanService      *service.LoanService
+	accountService   *service.AccountService
+	spaceService     *service.SpaceService
+	recurringService *service.RecurringEventService
+}
+
+func NewLoanHandler(loanService *service.LoanService, accountService *service.AccountService, spaceService *service.SpaceService, recurringService *service.RecurringEventService) *loanHandler {
+	return &loanHandler{
+		loanService:      loanService,
+		accountService:   accountService,
+		spaceService:     spaceService,
+		recurringService: recurringService,
+	}
+}
+
+func (h *loanHandler) loadAccount(w http.ResponseWriter, r *http.Request) (*model.Account, bool) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.Render(w, r, pages.NotFound())
+		return nil, false
+	}
+	return account, true
+}
+
+// otherAccounts are the open accounts in the space besides the loan, in the
+// same currency, that money can move between.
+func (h *loanHandler) otherAccounts(account *model.Account) ([]*model.Account, error) {
+	accounts, err := h.accountService.ActiveAccountsForSpace(account.SpaceID)
+	if err != nil {
+		return nil, err
+	}
+	others := make([]*model.Account, 0, len(accounts))
+	for _, a := range accounts {
+		if a.ID != account.ID && a.Currency == account.Currency {
+			others = append(others, a)
+		}
+	}
+	return others, nil
+}
+
+// scheduledPayments are the recurring transfers paying into the loan.
+func (h *loanHandler) scheduledPayments(account *model.Account) ([]*model.RecurringEvent, error) {
+	events, err := h.recurringService.ListByAccount(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	var scheduled []*model.RecurringEvent
+	for _, ev := range events {
+		if ev.Kind == model.RecurringEventKindTransfer && ev.DestAccountID != nil && *ev.DestAccountID == account.ID {
+			scheduled = append(scheduled, ev)
+		}
+	}
+	return scheduled, nil
+}
+
+func (h *loanHandler) LoanPage(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	space, err := h.spaceService.GetSpace(account.SpaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	accounts, err := h.otherAccounts(account)
+	if err != nil {
+		slog.Error("failed to load accounts", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	props := pages.SpaceAccountLoanPageProps{
+		SpaceID:     space.ID,
+		SpaceName:   space.Name,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+	}
+
+	terms, err := h.loanService.Terms(account.ID)
+	if errors.Is(err, service.ErrNotALoan) {
+		props.TermsForm = forms.LoanTermsProps{
+			SpaceID:          space.ID,
+			AccountID:        account.ID,
+			IsNew:            true,
+			Accounts:         accounts,
+			Compounding:      string(model.LoanCompoundingMonthly),
+			PaymentFrequency: string(model.LoanPaymentMonthly),
+			StartDate:        time.Now().Format("2006-01-02"),
+		}
+		ui.Render(w, r, pages.SpaceAccountLoanPage(props))
+		return
+	}
+	if err != nil {
+		slog.Error("failed to load loan terms", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	if props.Summary, err = h.loanService.Summary(account, time.Now()); err != nil {
+		slog.Error("failed to summarize loan", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	scheduled, err := h.scheduledPayments(account)
+	if err != nil {
+		slog.Error("failed to load scheduled loan payments", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	props.TermsForm = loanTermsFormProps(account, ctxkeys.Currencies(r.Context()).Get(account.Currency), terms)
+	props.Prepayment = blocks.LoanPrepaymentProps{
+		SpaceID:   space.ID,
+		AccountID: account.ID,
+	}
+	props.Schedule = blocks.LoanSchedulePaymentsProps{
+		SpaceID:   space.ID,
+		AccountID: account.ID,
+		Accounts:  accounts,
+		Timezones: timezone.CommonTimezones(),
+		Scheduled: scheduled,
+		Timezone:  "UTC",
+	}
+	ui.Render(w, r, pages.SpaceAccountLoanPage(props))
+}
+
+// loanTermsFormProps fills the terms form with the loan's saved terms.
+func loanTermsFormProps(account *model.Account, cur currency.Currency, terms *model.LoanTerms) forms.LoanTermsProps {
+	props := forms.LoanTermsProps{
+		SpaceID:            account.SpaceID,
+		AccountID:          account.ID,
+		Principal:          cur.Fixed(terms.Principal),
+		AnnualRate:         terms.AnnualRate.String(),
+		Compounding:        string(terms.Compounding),
+		PaymentFrequency:   string(terms.PaymentFrequency),
+		AmortizationMonths: strconv.Itoa(terms.AmortizationMonths),
+		StartDate:          terms.StartDate.Format("2006-01-02"),
+	}
+	if terms.TermMonths != nil {
+		props.TermMonths = strconv.Itoa(*terms.TermMonths)
+	}
+	return props
+}
+
+func (h *loanHandler) HandleSetTerms(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	_, err := h.loanService.Terms(account.ID)
+	isNew := errors.Is(err, service.ErrNotALoan)
+	if err != nil && !isNew {
+		slog.Error("failed to load loan terms", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to save loan terms", http.StatusInternalServerError)
+		return
+	}
+
+	input, props := parseLoanTermsForm(r)
+	props.SpaceID = account.SpaceID
+	props.AccountID = account.ID
+	props.IsNew = isNew
+	if isNew {
+		if props.Accounts, err = h.otherAccounts(account); err != nil {
+			slog.Error("failed to load accounts", "error", err, "space_id", account.SpaceID)
+			ui.RenderError(w, r, "Failed to save loan terms", http.StatusInternalServerError)
+			return
+		}
+		switch props.Disbursement {
+		case forms.LoanDisbursementNone:
+		case forms.LoanDisbursementPurchase:
+			input.RecordPrincipal = true
+		default:
+			input.RecordPrincipal = true
+			input.DisburseToAccountID = props.Disbursement
+			known := false
+			for _, a := range props.Accounts {
+				if a.ID == props.Disbursement {
+					known = true
+					break
+				}
+			}
+			if !known {
+				props.GeneralErr = "Choose where the principal was paid out to."
+			}
+		}
+	}
+	if props.HasError() || props.GeneralErr != "" {
+		ui.Render(w, r, forms.LoanTerms(props))
+		return
+	}
+
+	input.AccountID = account.ID
+	if u := ctxkeys.User(r.Context()); u != nil {
+		input.ActorID = u.ID
+	}
+	if _, err := h.loanService.SetUpLoan(input); err != nil {
+		switch {
+		case errors.Is(err, service.ErrAccountHasAllocations):
+			props.GeneralErr = "Remove this account's savings goals before turning it into a loan."
+		case account.IsInvestment:
+			props.GeneralErr = "An investment account can't be a loan."
+		default:
+			slog.Error("failed to set loan terms", "error", err, "account_id", account.ID)
+			props.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.LoanTerms(props))
+		return
+	}
+
+	// The summary and schedules all depend on the terms.
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseLoanTermsForm reads the loan terms form, returning the service input
+// and the form props with any field errors set.
+func parseLoanTermsForm(r *http.Request) (service.SetUpLoanInput, forms.LoanTermsProps) {
+	props := forms.LoanTermsProps{
+		Principal:          strings.TrimSpace(r.FormValue("principal")),
+		AnnualRate:         strings.TrimSpace(r.FormValue("annual_rate")),
+		Compounding:        r.FormValue("compounding"),
+		PaymentFrequency:   r.FormValue("payment_frequency"),
+		AmortizationMonths: strings.TrimSpace(r.FormValue("amortization_months")),
+		TermMonths:         strings.TrimSpace(r.FormValue("term_months")),
+		StartDate:          strings.TrimSpace(r.FormValue("start_date")),
+		Disbursement:       r.FormValue("disbursement"),
+	}
+	var input service.SetUpLoanInput
+
+	if p, err := decimal.NewFromString(props.Principal); err != nil || !p.IsPositive() {
+		props.PrincipalErr = "Enter an amount greater than zero."
+	} else {
+		input.Principal = p
+	}
+	if rate, err := decimal.NewFromString(props.AnnualRate); err != nil || rate.IsNegative() {
+		props.RateErr = "Enter a rate of zero or more."
+	} else {
+		input.AnnualRate = rate
+	}
+	if !model.IsValidLoanCompounding(props.Compounding) {
+		props.GeneralErr = "Choose how the rate compounds."
+	}
+	input.Compounding = model.LoanCompounding(props.Compounding)
+	if !model.IsValidLoanPaymentFrequency(props.PaymentFrequency) {
+		props.GeneralErr = "Choose a payment frequency."
+	}
+	input.PaymentFrequency = model.LoanPaymentFrequency(props.PaymentFrequency)
+	if n, err := strconv.Atoi(props.AmortizationMonths); err != nil || n < 1 {
+		props.AmortizationErr = "Enter a whole number of months."
+	} else {
+		input.AmortizationMonths = n
+	}
+	if props.TermMonths != "" {
+		if n, err := strconv.Atoi(props.TermMonths); err != nil || n < 1 {
+			props.TermErr = "Enter a whole number of months, or leave it empty."
+		} else {
+			input.TermMonths = &n
+		}
+	}
+	if d, err := time.Parse("2006-01-02", props.StartDate); err != nil {
+		props.StartDateErr = "Enter a valid date."
+	} else {
+		input.StartDate = d
+	}
+	return input, props
+}
+
+func (h *loanHandler) HandlePrepayment(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	props := blocks.LoanPrepaymentProps{
+		SpaceID:   account.SpaceID,
+		AccountID: account.ID,
+		Amount:    strings.TrimSpace(r.FormValue("amount")),
+	}
+	amount, err := decimal.NewFromString(props.Amount)
+	if err != nil || !amount.IsPositive() {
+		props.AmountErr = "Enter an amount greater than zero."
+		ui.Render(w, r, blocks.LoanPrepayment(props))
+		return
+	}
+
+	props.Effect, err = h.loanService.Prepayment(account, amount, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPrepaymentExceedsBalance):
+			props.AmountErr = "That is more than what is owed on the loan."
+		case errors.Is(err, service.ErrNotALoan):
+			props.AmountErr = "Set up the loan terms first."
+		default:
+			slog.Error("failed to compare prepayment", "error", err, "account_id", account.ID)
+			props.AmountErr = "Something went wrong. Please try again."
+		}
+	}
+	ui.Render(w, r, blocks.LoanPrepayment(props))
+}
+
+func (h *loanHandler) HandleSchedulePayments(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	accounts, err := h.otherAccounts(account)
+	if err != nil {
+		slog.Error("failed to load accounts", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to schedule payments", http.StatusInternalServerError)
+		return
+	}
+	props := blocks.LoanSchedulePaymentsProps{
+		SpaceID:       account.SpaceID,
+		AccountID:     account.ID,
+		Accounts:      accounts,
+		Timezones:     timezone.CommonTimezones(),
+		FromAccountID: r.FormValue("from_account"),
+		Timezone:      r.FormValue("timezone"),
+	}
+
+	validFrom := false
+	for _, a := range accounts {
+		if a.ID == props.FromAccountID {
+			validFrom = true
+			break
+		}
+	}
+	if !validFrom {
+		props.FromErr = "Choose an account in " + account.Currency + " to pay from."
+	}
+	if _, err := time.LoadLocation(props.Timezone); props.Timezone == "" || err != nil {
+		props.GeneralErr = "Unknown timezone."
+	}
+
+	if props.FromErr == "" && props.GeneralErr == "" {
+		events, err := h.loanService.SchedulePayments(service.ScheduleLoanPaymentsInput{
+			AccountID:     account.ID,
+			FromAccountID: props.FromAccountID,
+			Timezone:      props.Timezone,
+			FireHour:      loanPaymentFireHour,
+			Now:           time.Now(),
+		})
+		switch {
+		case errors.Is(err, service.ErrNotALoan):
+			props.GeneralErr = "Set up the loan terms first."
+		case err != nil:
+			slog.Error("failed to schedule loan payments", "error", err, "account_id", account.ID)
+			props.GeneralErr = "Something went wrong. Please try again."
+		case len(events) == 1:
+			props.SuccessMsg = "Payment scheduled."
+		default:
+			props.SuccessMsg = "Payments scheduled."
+		}
+	}
+
+	if props.Scheduled, err = h.scheduledPayments(account); err != nil {
+		slog.Error("failed to load scheduled loan payments", "error", err, "account_id", account.ID)
+	}
+	ui.Render(w, r, blocks.LoanSchedulePayments(props))
+}
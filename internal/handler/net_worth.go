@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+)
+
+type netWorthHandler struct {
+	netWorthService *service.NetWorthService
+	spaceService    *service.SpaceService
+}
+
+func NewNetWorthHandler(netWorthService *service.NetWorthService, spaceService *service.SpaceService) *netWorthHandler {
+	return &netWorthHandler{
+		netWorthService: netWorthService,
+		spaceService:    spaceService,
+	}
+}
+
+// NetWorthPage shows the user's net worth across every space they are a
+// member of, in the currency picked with ?currency= (by default the first
+// space's reporting currency), over the range picked with ?range=.
+func (h *netWorthHandler) NetWorthPage(w http.ResponseWriter, r *http.Request) {
+	user := ctxkeys.User(r.Context())
+	q := r.URL.Query()
+
+	target := currency.Normalize(q.Get("currency"))
+	if !currency.IsValid(target) {
+		target = currency.Default
+		spaces, err := h.spaceService.GetSpacesForUser(user.ID)
+		if err != nil {
+			slog.Error("failed to list spaces", "error", err, "user_id", user.ID)
+			ui.RenderError(w, r, "Failed to load net worth", http.StatusInternalServerError)
+			return
+		}
+		if len(spaces) > 0 && spaces[0].ReportingCurrency != "" {
+			target = spaces[0].ReportingCurrency
+		}
+	}
+
+	rangeKey := q.Get("range")
+	now := time.Now()
+	var from time.Time
+	switch rangeKey {
+	case "3m":
+		from = now.AddDate(0, -3, 0)
+	case "5y":
+		from = now.AddDate(-5, 0, 0)
+	case "all":
+		// Zero starts at the oldest snapshot.
+	default:
+		rangeKey = "1y"
+		from = now.AddDate(-1, 0, 0)
+	}
+
+	breakdown := q.Get("by")
+	if breakdown != "space" && breakdown != "account" {
+		breakdown = "total"
+	}
+
+	report, err := h.netWorthService.Report(user.ID, target, from, now)
+	if err != nil {
+		slog.Error("failed to build net worth report", "error", err, "user_id", user.ID)
+		ui.RenderError(w, r, "Failed to load net worth", http.StatusInternalServerError)
+		return
+	}
+	ui.Render(w, r, pages.NetWorthPage(pages.NetWorthPageProps{
+		Report:    report,
+		Range:     rangeKey,
+		Breakdown: breakdown,
+	}))
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/blocks"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+	"github.com/shopspring/decimal"
+)
+
+// reconciliationHistoryLimit is how many past reconciliations the reconcile
+// page lists.
+const reconciliationHistoryLimit = 20
+
+type reconciliationHandler struct {
+	reconciliationService *service.ReconciliationService
+	accountService        *service.AccountService
+	spaceService          *service.SpaceService
+}
+
+func NewReconciliationHandler(reconciliationService *service.ReconciliationService, accountService *service.AccountService, spaceService *service.SpaceService) *reconciliationHandler {
+	return &reconciliationHandler{
+		reconciliationService: reconciliationService,
+		accountService:        accountService,
+		spaceService:          spaceService,
+	}
+}
+
+func (h *reconciliationHandler) loadAccount(w http.ResponseWriter, r *http.Request) (*model.Account, bool) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.Render(w, r, pages.NotFound())
+		return nil, false
+	}
+	return account, true
+}
+
+func (h *reconciliationHandler) ReconcilePage(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	space, err := h.spaceService.GetSpace(account.SpaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", account.SpaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	props := pages.SpaceAccountReconcilePageProps{
+		SpaceID:         space.ID,
+		SpaceName:       space.Name,
+		AccountID:       account.ID,
+		AccountName:     account.Name,
+		AccountCurrency: account.Currency,
+	}
+	open, err := h.reconciliationService.Open(account.ID)
+	if err != nil {
+		slog.Error("failed to load open reconciliation", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	if open != nil {
+		if props.Worksheet, err = h.reconciliationService.Worksheet(open); err != nil {
+			slog.Error("failed to build reconciliation worksheet", "error", err, "reconciliation_id", open.ID)
+			ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+			return
+		}
+	}
+	if props.History, err = h.reconciliationService.History(account.ID, reconciliationHistoryLimit); err != nil {
+		slog.Error("failed to list reconciliations", "error", err, "account_id", account.ID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	ui.Render(w, r, pages.SpaceAccountReconcilePage(props))
+}
+
+func (h *reconciliationHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+
+	dateInput := strings.TrimSpace(r.FormValue("statement_date"))
+	balanceInput := strings.TrimSpace(r.FormValue("statement_balance"))
+	formProps := blocks.ReconciliationStartProps{
+		SpaceID:          account.SpaceID,
+		AccountID:        account.ID,
+		StatementDate:    dateInput,
+		StatementBalance: balanceInput,
+	}
+
+	statementDate, err := time.Parse("2006-01-02", dateInput)
+	if err != nil {
+		formProps.DateErr = "Enter a valid date."
+	}
+	balance, err := decimal.NewFromString(balanceInput)
+	if err != nil {
+		formProps.BalanceErr = "Enter a valid amount."
+	}
+	if formProps.DateErr != "" || formProps.BalanceErr != "" {
+		ui.Render(w, r, blocks.ReconciliationStart(formProps))
+		return
+	}
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+	rec, err := h.reconciliationService.Start(service.StartReconciliationInput{
+		AccountID:        account.ID,
+		ActorID:          actorID,
+		StatementDate:    statementDate,
+		StatementBalance: balance,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrReconciliationInProgress) {
+			// Another tab got there first; show the session that's open.
+			w.Header().Set("HX-Refresh", "true")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		slog.Error("failed to start reconciliation", "error", err, "account_id", account.ID)
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, blocks.ReconciliationStart(formProps))
+		return
+	}
+
+	h.renderWorksheet(w, r, account, rec, "")
+}
+
+func (h *reconciliationHandler) HandleToggle(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	reconciliationID := r.PathValue("reconciliationID")
+	transactionID := r.PathValue("transactionID")
+	cleared := r.FormValue("cleared") == "true"
+
+	errMsg := ""
+	if _, err := h.reconciliationService.SetCleared(account.ID, reconciliationID, transactionID, cleared); err != nil {
+		switch {
+		case errors.Is(err, service.ErrReconciliationNotFound):
+			w.Header().Set("HX-Refresh", "true")
+			w.WriteHeader(http.StatusOK)
+			return
+		case errors.Is(err, service.ErrTransactionOutsideStatement):
+			errMsg = "That transaction isn't covered by this statement."
+		case errors.Is(err, service.ErrTransactionReconciled):
+			errMsg = "That transaction is already reconciled."
+		default:
+			slog.Error("failed to toggle cleared status", "error", err, "transaction_id", transactionID)
+			errMsg = "Something went wrong. Please try again."
+		}
+	}
+
+	rec, err := h.reconciliationService.Get(account.ID, reconciliationID)
+	if err != nil {
+		ui.RenderError(w, r, "Reconciliation not found", http.StatusNotFound)
+		return
+	}
+	h.renderWorksheet(w, r, account, rec, errMsg)
+}
+
+func (h *reconciliationHandler) HandleFinalize(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	reconciliationID := r.PathValue("reconciliationID")
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+	if _, err := h.reconciliationService.Finalize(account.ID, reconciliationID, actorID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrReconciliationNotFound):
+			ui.RenderError(w, r, "Reconciliation not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrReconciliationUnbalanced):
+			rec, err := h.reconciliationService.Get(account.ID, reconciliationID)
+			if err != nil {
+				ui.RenderError(w, r, "Reconciliation not found", http.StatusNotFound)
+				return
+			}
+			h.renderWorksheet(w, r, account, rec, "The cleared balance doesn't match the statement yet.")
+		default:
+			slog.Error("failed to finalize reconciliation", "error", err, "reconciliation_id", reconciliationID)
+			ui.RenderError(w, r, "Failed to finalize reconciliation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *reconciliationHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.loadAccount(w, r)
+	if !ok {
+		return
+	}
+	reconciliationID := r.PathValue("reconciliationID")
+
+	if err := h.reconciliationService.Cancel(account.ID, reconciliationID); err != nil {
+		if errors.Is(err, service.ErrReconciliationNotFound) {
+			ui.RenderError(w, r, "Reconciliation not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to cancel reconciliation", "error", err, "reconciliation_id", reconciliationID)
+		ui.RenderError(w, r, "Failed to cancel reconciliation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *reconciliationHandler) renderWorksheet(w http.ResponseWriter, r *http.Request, account *model.Account, rec *model.Reconciliation, errMsg string) {
+	sheet, err := h.reconciliationService.Worksheet(rec)
+	if err != nil {
+		slog.Error("failed to build reconciliation worksheet", "error", err, "reconciliation_id", rec.ID)
+		ui.RenderError(w, r, "Failed to load reconciliation", http.StatusInternalServerError)
+		return
+	}
+	ui.Render(w, r, blocks.ReconciliationWorksheet(blocks.ReconciliationWorksheetProps{
+		SpaceID:   account.SpaceID,
+		AccountID: account.ID,
+		Currency:  account.Currency,
+		Worksheet: sheet,
+		Err:       errMsg,
+	}))
+}
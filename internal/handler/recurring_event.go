@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
 	"git.juancwu.dev/juancwu/budgit/internal/misc/timezone"
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
@@ -50,14 +52,17 @@ func (h *recurringEventHandler) ListPage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	accountByID := map[string]string{}
+	currencyByAccountID := map[string]string{}
 	for _, a := range accounts {
 		accountByID[a.ID] = a.Name
+		currencyByAccountID[a.ID] = a.Currency
 	}
 	ui.Render(w, r, pages.SpaceRecurringEventsPage(pages.SpaceRecurringEventsPageProps{
-		SpaceID:     spaceID,
-		SpaceName:   space.Name,
-		Events:      events,
-		AccountByID: accountByID,
+		SpaceID:             spaceID,
+		SpaceName:           space.Name,
+		Events:              events,
+		AccountByID:         accountByID,
+		CurrencyByAccountID: currencyByAccountID,
 	}))
 }
 
@@ -69,7 +74,7 @@ func (h *recurringEventHandler) CreatePage(w http.ResponseWriter, r *http.Reques
 		ui.Render(w, r, pages.NotFound())
 		return
 	}
-	accounts, err := h.accountService.GetAccountsForSpace(spaceID)
+	accounts, err := h.accountService.ActiveAccountsForSpace(spaceID)
 	if err != nil {
 		slog.Error("failed to load accounts", "error", err, "space_id", spaceID)
 		ui.RenderError(w, r, "Failed to load form", http.StatusInternalServerError)
@@ -118,7 +123,7 @@ func (h *recurringEventHandler) EditPage(w http.ResponseWriter, r *http.Request)
 		ui.Render(w, r, pages.NotFound())
 		return
 	}
-	accounts, err := h.accountService.GetAccountsForSpace(spaceID)
+	accounts, err := h.accountService.ActiveAccountsForSpace(spaceID)
 	if err != nil {
 		slog.Error("failed to load accounts", "error", err, "space_id", spaceID)
 		ui.RenderError(w, r, "Failed to load form", http.StatusInternalServerError)
@@ -135,7 +140,7 @@ func (h *recurringEventHandler) EditPage(w http.ResponseWriter, r *http.Request)
 		Title:            ev.Title,
 		Kind:             string(ev.Kind),
 		SourceAccountID:  ev.SourceAccountID,
-		Amount:           ev.Amount.StringFixedBank(2),
+		Amount:           sourceCurrency(r, accounts, ev.SourceAccountID).Fixed(ev.Amount),
 		Frequency:        string(ev.Frequency),
 		IntervalCount:    strconv.Itoa(ev.IntervalCount),
 		FireTime:         formatTimeOfDay(ev.FireHour, ev.FireMinute),
@@ -146,6 +151,9 @@ func (h *recurringEventHandler) EditPage(w http.ResponseWriter, r *http.Request)
 	if ev.Description != nil {
 		formProps.Description = *ev.Description
 	}
+	if ev.DestAccountID != nil {
+		formProps.DestAccountID = *ev.DestAccountID
+	}
 	if ev.DayOfWeek != nil {
 		formProps.DayOfWeek = strconv.Itoa(*ev.DayOfWeek)
 	}
@@ -218,6 +226,7 @@ func (h *recurringEventHandler) HandleEdit(w http.ResponseWriter, r *http.Reques
 		ID:               eventID,
 		Kind:             parsed.Kind,
 		SourceAccountID:  parsed.SourceAccountID,
+		DestAccountID:    parsed.DestAccountID,
 		Title:            parsed.Title,
 		Amount:           parsed.Amount,
 		Description:      parsed.Description,
@@ -276,6 +285,10 @@ func (h *recurringEventHandler) setPaused(w http.ResponseWriter, r *http.Request
 		return
 	}
 	if err := h.recurringService.SetPaused(eventID, paused); err != nil {
+		if errors.Is(err, service.ErrAccountArchived) {
+			ui.RenderError(w, r, "This event moves money in or out of an archived account.", http.StatusBadRequest)
+			return
+		}
 		slog.Error("failed to toggle pause", "error", err, "event_id", eventID)
 		ui.RenderError(w, r, "Failed to update", http.StatusInternalServerError)
 		return
@@ -287,11 +300,12 @@ func (h *recurringEventHandler) setPaused(w http.ResponseWriter, r *http.Request
 // parseForm reads the recurring-event form, returns a populated CreateRecurringEventInput
 // alongside form props echoed back to the user with field-level errors.
 func (h *recurringEventHandler) parseForm(r *http.Request, spaceID string) (service.CreateRecurringEventInput, forms.RecurringEventFormProps) {
-	accounts, _ := h.accountService.GetAccountsForSpace(spaceID)
+	accounts, _ := h.accountService.ActiveAccountsForSpace(spaceID)
 
 	title := strings.TrimSpace(r.FormValue("title"))
 	kind := strings.TrimSpace(r.FormValue("kind"))
 	sourceID := strings.TrimSpace(r.FormValue("source_account"))
+	destID := strings.TrimSpace(r.FormValue("dest_account"))
 	amountStr := strings.TrimSpace(r.FormValue("amount"))
 	descriptionStr := strings.TrimSpace(r.FormValue("description"))
 	frequency := strings.TrimSpace(r.FormValue("frequency"))
@@ -311,6 +325,7 @@ func (h *recurringEventHandler) parseForm(r *http.Request, spaceID string) (serv
 		Title:            title,
 		Kind:             kind,
 		SourceAccountID:  sourceID,
+		DestAccountID:    destID,
 		Amount:           amountStr,
 		Description:      descriptionStr,
 		Frequency:        frequency,
@@ -328,6 +343,7 @@ func (h *recurringEventHandler) parseForm(r *http.Request, spaceID string) (serv
 		SpaceID:          spaceID,
 		Kind:             model.RecurringEventKind(kind),
 		SourceAccountID:  sourceID,
+		DestAccountID:    destID,
 		Title:            title,
 		Description:      descriptionStr,
 		Frequency:        model.RecurringFrequency(frequency),
@@ -341,18 +357,25 @@ func (h *recurringEventHandler) parseForm(r *http.Request, spaceID string) (serv
 	switch model.RecurringEventKind(kind) {
 	case model.RecurringEventKindBill, model.RecurringEventKindFund:
 		// ok
+	case model.RecurringEventKindTransfer:
+		if destID == "" {
+			props.DestErr = "Choose the account to transfer to."
+		} else if destID == sourceID {
+			props.DestErr = "Choose a different account than the source."
+		}
 	default:
 		props.KindErr = "Choose a kind."
 	}
 	if sourceID == "" {
 		props.SourceErr = "Source account is required."
 	}
+	cur := sourceCurrency(r, accounts, sourceID)
 	if amount, err := decimal.NewFromString(amountStr); err != nil {
 		props.AmountErr = "Enter a valid amount (e.g. 12.34)."
 	} else if !amount.IsPositive() {
 		props.AmountErr = "Amount must be greater than zero."
-	} else if amount.Exponent() < -2 {
-		props.AmountErr = "Amount can have at most 2 decimal places."
+	} else if !cur.Fits(amount) {
+		props.AmountErr = decimalsErr("Amount", cur)
 	} else {
 		input.Amount = amount
 	}
@@ -417,6 +440,17 @@ func (h *recurringEventHandler) parseForm(r *http.Request, spaceID string) (serv
 	return input, props
 }
 
+// sourceCurrency returns the currency of the event's source account, which
+// its amount is in. An account that isn't listed gets two decimals.
+func sourceCurrency(r *http.Request, accounts []*model.Account, sourceID string) currency.Currency {
+	for _, a := range accounts {
+		if a.ID == sourceID {
+			return ctxkeys.Currencies(r.Context()).Get(a.Currency)
+		}
+	}
+	return currency.Currency{MinorUnits: 2}
+}
+
 func parseTimeOfDay(s string) (int, int, bool) {
 	parts := strings.Split(s, ":")
 	if len(parts) != 2 {
@@ -456,5 +490,8 @@ func friendlyRecurringError(err error) string {
 	if errors.Is(err, repository.ErrRecurringEventNotFound) {
 		return "Recurring event not found."
 	}
+	if errors.Is(err, service.ErrAccountArchived) {
+		return "That account is archived. Choose an open account."
+	}
 	return err.Error()
 }
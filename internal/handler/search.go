@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+)
+
+const searchPerPage = 25
+
+type searchHandler struct {
+	searchService *service.SearchService
+	spaceService  *service.SpaceService
+}
+
+func NewSearchHandler(searchService *service.SearchService, spaceService *service.SpaceService) *searchHandler {
+	return &searchHandler{
+		searchService: searchService,
+		spaceService:  spaceService,
+	}
+}
+
+// SpaceSearchPage searches every account in the space.
+func (h *searchHandler) SpaceSearchPage(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load search", http.StatusInternalServerError)
+		return
+	}
+	names, err := h.searchService.SpaceCategoryNames(spaceID)
+	if err != nil {
+		slog.Error("failed to list category names", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load search", http.StatusInternalServerError)
+		return
+	}
+
+	filter, vals := parseSearchFilter(r)
+	props := pages.SearchPageProps{
+		SpaceID:       space.ID,
+		SpaceName:     space.Name,
+		CategoryNames: names,
+		CurrentPage:   1,
+		TotalPages:    1,
+		PerPage:       searchPerPage,
+		Filter:        vals,
+		FilterQuery:   vals.QueryString(),
+	}
+	if vals.Active {
+		result, err := h.searchService.SearchSpace(spaceID, filter, searchPage(r), searchPerPage)
+		if err != nil {
+			slog.Error("failed to search transactions", "error", err, "space_id", spaceID)
+			ui.RenderError(w, r, "Failed to search transactions", http.StatusInternalServerError)
+			return
+		}
+		withSearchPage(&props, result)
+	}
+	ui.Render(w, r, pages.SearchPage(props))
+}
+
+// SearchPage searches every account in every space the user is a member of.
+func (h *searchHandler) SearchPage(w http.ResponseWriter, r *http.Request) {
+	user := ctxkeys.User(r.Context())
+	names, err := h.searchService.MemberCategoryNames(user.ID)
+	if err != nil {
+		slog.Error("failed to list category names", "error", err, "user_id", user.ID)
+		ui.RenderError(w, r, "Failed to load search", http.StatusInternalServerError)
+		return
+	}
+
+	filter, vals := parseSearchFilter(r)
+	props := pages.SearchPageProps{
+		CategoryNames: names,
+		CurrentPage:   1,
+		TotalPages:    1,
+		PerPage:       searchPerPage,
+		Filter:        vals,
+		FilterQuery:   vals.QueryString(),
+	}
+	if vals.Active {
+		result, err := h.searchService.SearchMember(user.ID, filter, searchPage(r), searchPerPage)
+		if err != nil {
+			slog.Error("failed to search transactions", "error", err, "user_id", user.ID)
+			ui.RenderError(w, r, "Failed to search transactions", http.StatusInternalServerError)
+			return
+		}
+		withSearchPage(&props, result)
+	}
+	ui.Render(w, r, pages.SearchPage(props))
+}
+
+func withSearchPage(props *pages.SearchPageProps, result *service.SearchPage) {
+	props.Results = result.Results
+	props.CurrentPage = result.Page
+	props.TotalPages = result.TotalPages
+	props.TotalCount = result.TotalCount
+}
+
+func searchPage(r *http.Request) int {
+	if p, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get("page"))); err == nil && p > 0 {
+		return p
+	}
+	return 1
+}
+
+// parseSearchFilter is parseTransactionFilter for the search page: the text
+// is a full search over titles and descriptions rather than a title
+// substring, and type and category can narrow it further. Unknown types are
+// ignored.
+func parseSearchFilter(r *http.Request) (model.TransactionFilter, pages.SearchFilterValues) {
+	filter, base := parseTransactionFilter(r)
+	filter.Query, filter.Title = filter.Title, ""
+
+	q := r.URL.Query()
+	vals := pages.SearchFilterValues{
+		TransactionFilterValues: base,
+		Type:                    strings.TrimSpace(q.Get("type")),
+		Category:                strings.TrimSpace(q.Get("category")),
+	}
+	switch model.TransactionType(vals.Type) {
+	case model.TransactionTypeDeposit, model.TransactionTypeWithdrawal:
+		filter.Type = model.TransactionType(vals.Type)
+	default:
+		vals.Type = ""
+	}
+	filter.CategoryName = vals.Category
+
+	vals.Active = !filter.IsZero()
+	return filter, vals
+}
@@ -11,6 +11,7 @@ import (
 	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
 	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
 	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"git.juancwu.dev/juancwu/budgit/internal/routeurl"
 	"git.juancwu.dev/juancwu/budgit/internal/service"
 	"git.juancwu.dev/juancwu/budgit/internal/ui"
@@ -26,12 +27,18 @@ type spaceHandler struct {
 	accountService     *service.AccountService
 	transactionService *service.TransactionService
 	categoryService    *service.CategoryService
+	templateService    *service.CategoryTemplateService
+	limitService       *service.CategoryLimitService
+	tagService         *service.TagService
 	allocationService  *service.AllocationService
 	inviteService      *service.InviteService
 	auditLogService    *service.SpaceAuditLogService
 	txAuditLogService  *service.TransactionAuditLogService
 	accountActivitySvc *service.AccountActivityService
 	investmentService  *service.InvestmentService
+	reconciliationSvc  *service.ReconciliationService
+	attachmentService  *service.AttachmentService
+	rateService        *service.ExchangeRateService
 }
 
 func NewSpaceHandler(
@@ -39,24 +46,36 @@ func NewSpaceHandler(
 	accountService *service.AccountService,
 	transactionService *service.TransactionService,
 	categoryService *service.CategoryService,
+	templateService *service.CategoryTemplateService,
+	limitService *service.CategoryLimitService,
+	tagService *service.TagService,
 	allocationService *service.AllocationService,
 	inviteService *service.InviteService,
 	auditLogService *service.SpaceAuditLogService,
 	txAuditLogService *service.TransactionAuditLogService,
 	accountActivitySvc *service.AccountActivityService,
 	investmentService *service.InvestmentService,
+	reconciliationSvc *service.ReconciliationService,
+	attachmentService *service.AttachmentService,
+	rateService *service.ExchangeRateService,
 ) *spaceHandler {
 	return &spaceHandler{
 		spaceService:       spaceService,
 		accountService:     accountService,
 		transactionService: transactionService,
 		categoryService:    categoryService,
+		templateService:    templateService,
+		limitService:       limitService,
+		tagService:         tagService,
 		allocationService:  allocationService,
 		inviteService:      inviteService,
 		auditLogService:    auditLogService,
 		txAuditLogService:  txAuditLogService,
 		accountActivitySvc: accountActivitySvc,
 		investmentService:  investmentService,
+		reconciliationSvc:  reconciliationSvc,
+		attachmentService:  attachmentService,
+		rateService:        rateService,
 	}
 }
 
@@ -198,24 +217,52 @@ func (h *spaceHandler) SpaceOverviewPage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	accountCards := make([]blocks.AccountCardInfo, 0, len(accounts))
+	// Archived accounts are listed apart and left out of the totals.
+	var accountCards, archivedCards []blocks.AccountCardInfo
+	active := make([]*model.Account, 0, len(accounts))
 	for _, a := range accounts {
-		accountCards = append(accountCards, blocks.AccountCardInfo{
-			SpaceID:  space.ID,
-			ID:       a.ID,
-			Name:     a.Name,
-			Balance:  a.Balance,
-			Currency: a.Currency,
-		})
+		card := blocks.AccountCardInfo{
+			SpaceID:   space.ID,
+			ID:        a.ID,
+			Name:      a.Name,
+			Balance:   a.Balance,
+			Currency:  a.Currency,
+			Liability: a.IsLiability(),
+		}
+		if a.IsArchived() {
+			archivedCards = append(archivedCards, card)
+			continue
+		}
+		accountCards = append(accountCards, card)
+		active = append(active, a)
 	}
 
+	totals := model.TotalsByCurrency(active)
 	ui.Render(w, r, pages.SpaceOverview(pages.SpaceOverviewProps{
 		SpaceID:   space.ID,
 		SpaceName: space.Name,
 		Accounts:  accountCards,
+		Archived:  archivedCards,
+		Totals:    totals,
+		Converted: h.convertedTotals(space, active, totals),
 	}))
 }
 
+// convertedTotals adds the accounts up in the space's reporting currency when
+// any of them is in another currency. A failure only logs: the overview
+// still shows the per-currency totals.
+func (h *spaceHandler) convertedTotals(space *model.Space, accounts []*model.Account, totals []*model.AccountTotals) *service.ConvertedTotals {
+	if len(totals) == 0 || (len(totals) == 1 && totals[0].Currency == space.ReportingCurrency) {
+		return nil
+	}
+	converted, err := h.rateService.ConvertedTotals(space.ID, accounts, space.ReportingCurrency, time.Now())
+	if err != nil {
+		slog.Error("failed to convert space totals", "error", err, "space_id", space.ID)
+		return nil
+	}
+	return converted
+}
+
 func (h *spaceHandler) SpaceCreateAccountPage(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 
@@ -230,11 +277,24 @@ func (h *spaceHandler) SpaceCreateAccountPage(w http.ResponseWriter, r *http.Req
 		SpaceID:   space.ID,
 		SpaceName: space.Name,
 		Form: forms.CreateAccountProps{
-			SpaceID: space.ID,
+			SpaceID:            space.ID,
+			Templates:          h.categoryTemplates(space.ID),
+			CategoryTemplateID: model.DefaultCategoryTemplateID,
 		},
 	}))
 }
 
+// categoryTemplates lists the templates a new account can start with. A
+// failure only costs the choice of the space's own templates.
+func (h *spaceHandler) categoryTemplates(spaceID string) []*model.CategoryTemplate {
+	templates, err := h.templateService.List(spaceID)
+	if err != nil {
+		slog.Error("failed to list category templates", "error", err, "space_id", spaceID)
+		return []*model.CategoryTemplate{{ID: model.DefaultCategoryTemplateID, SpaceID: spaceID, Name: model.DefaultCategoryTemplateName}}
+	}
+	return templates
+}
+
 func (h *spaceHandler) HandleCreateAccount(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 	nameInput := strings.TrimSpace(r.FormValue("name"))
@@ -244,16 +304,25 @@ func (h *spaceHandler) HandleCreateAccount(w http.ResponseWriter, r *http.Reques
 	}
 	isInvestment := r.FormValue("is_investment") == "1"
 	subtypeInput := strings.ToLower(strings.TrimSpace(r.FormValue("investment_subtype")))
+	kind, terms, kindFields := parseAccountKindForm(r, "")
+	templateID := r.FormValue("category_template_id")
 
 	formProps := forms.CreateAccountProps{
-		SpaceID:           spaceID,
-		Name:              nameInput,
-		Currency:          currencyInput,
-		IsInvestment:      isInvestment,
-		InvestmentSubtype: subtypeInput,
+		SpaceID:            spaceID,
+		Name:               nameInput,
+		Currency:           currencyInput,
+		IsInvestment:       isInvestment,
+		InvestmentSubtype:  subtypeInput,
+		Kind:               kindFields,
+		Templates:          h.categoryTemplates(spaceID),
+		CategoryTemplateID: templateID,
+	}
+
+	hasErr := kindFields.KindErr != "" || kindFields.TermsErr != ""
+	if isInvestment && kind.IsLiability() {
+		formProps.Kind.KindErr = "An investment account can't track a debt."
+		hasErr = true
 	}
-
-	hasErr := false
 	if nameInput == "" {
 		formProps.NameErr = "Account name is required."
 		hasErr = true
@@ -292,13 +361,21 @@ func (h *spaceHandler) HandleCreateAccount(w http.ResponseWriter, r *http.Reques
 		actorID = user.ID
 	}
 	account, err := h.accountService.CreateAccount(service.CreateAccountInput{
-		SpaceID:           spaceID,
-		Name:              nameInput,
-		CurrencyCode:      currencyInput,
-		IsInvestment:      isInvestment,
-		InvestmentSubtype: subtypeInput,
-		ActorID:           actorID,
+		SpaceID:            spaceID,
+		Name:               nameInput,
+		CurrencyCode:       currencyInput,
+		IsInvestment:       isInvestment,
+		InvestmentSubtype:  subtypeInput,
+		Kind:               kind,
+		Credit:             terms,
+		CategoryTemplateID: templateID,
+		ActorID:            actorID,
 	})
+	if errors.Is(err, service.ErrCategoryTemplateNotFound) {
+		formProps.TemplateErr = "That template no longer exists. Pick another."
+		ui.Render(w, r, forms.CreateAccount(formProps))
+		return
+	}
 	if err != nil {
 		slog.Error("failed to create account", "error", err, "space_id", spaceID)
 		formProps.GeneralErr = "Something went wrong. Please try again."
@@ -315,6 +392,73 @@ func (h *spaceHandler) HandleCreateAccount(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusOK)
 }
 
+// parseAccountKindForm reads the account kind and credit terms fields. Field
+// errors are set on the returned props; the kind and terms are only usable
+// when none are. Terms are ignored for cash accounts.
+func parseAccountKindForm(r *http.Request, idPrefix string) (model.AccountKind, service.CreditTerms, forms.AccountKindFieldsProps) {
+	fields := forms.AccountKindFieldsProps{
+		IDPrefix:    idPrefix,
+		Kind:        strings.TrimSpace(r.FormValue("kind")),
+		CreditLimit: strings.TrimSpace(r.FormValue("credit_limit")),
+		ClosingDay:  strings.TrimSpace(r.FormValue("statement_closing_day")),
+		DueDay:      strings.TrimSpace(r.FormValue("payment_due_day")),
+	}
+	var terms service.CreditTerms
+	if fields.Kind == "" {
+		fields.Kind = string(model.AccountKindCash)
+	}
+	kind := model.AccountKind(fields.Kind)
+	if !model.IsValidAccountKind(fields.Kind) {
+		fields.KindErr = "Choose an account kind."
+		return kind, terms, fields
+	}
+	if kind == model.AccountKindLoan {
+		fields.KindErr = "Set up loans from the account's Loan page."
+		return kind, terms, fields
+	}
+	if !kind.IsLiability() {
+		return kind, terms, fields
+	}
+	if fields.CreditLimit != "" {
+		limit, err := decimal.NewFromString(fields.CreditLimit)
+		if err != nil || limit.IsNegative() {
+			fields.TermsErr = "Enter a credit limit of zero or more."
+			return kind, terms, fields
+		}
+		terms.CreditLimit = &limit
+	}
+	for _, day := range []struct {
+		value string
+		dst   **int
+	}{{fields.ClosingDay, &terms.StatementClosingDay}, {fields.DueDay, &terms.PaymentDueDay}} {
+		if day.value == "" {
+			continue
+		}
+		d, err := strconv.Atoi(day.value)
+		if err != nil || d < 1 || d > 31 {
+			fields.TermsErr = "Days must be between 1 and 31."
+			return kind, terms, fields
+		}
+		*day.dst = &d
+	}
+	return kind, terms, fields
+}
+
+// accountKindFields fills the kind form from the account's current settings.
+func accountKindFields(account *model.Account, cur currency.Currency, idPrefix string) forms.AccountKindFieldsProps {
+	fields := forms.AccountKindFieldsProps{IDPrefix: idPrefix, Kind: string(account.Kind)}
+	if account.CreditLimit != nil {
+		fields.CreditLimit = cur.Fixed(*account.CreditLimit)
+	}
+	if account.StatementClosingDay != nil {
+		fields.ClosingDay = strconv.Itoa(*account.StatementClosingDay)
+	}
+	if account.PaymentDueDay != nil {
+		fields.DueDay = strconv.Itoa(*account.PaymentDueDay)
+	}
+	return fields
+}
+
 func (h *spaceHandler) SpaceAccountPage(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 	accountID := r.PathValue("accountID")
@@ -344,10 +488,13 @@ func (h *spaceHandler) SpaceAccountPage(w http.ResponseWriter, r *http.Request)
 		recent = nil
 	}
 
-	allocSummary, err := h.allocationService.SummaryForAccount(accountID)
-	if err != nil {
-		slog.Error("failed to load allocation summary", "error", err, "account_id", accountID)
-		allocSummary = nil
+	var allocSummary *service.AllocationSummary
+	if !account.IsLiability() {
+		allocSummary, err = h.allocationService.SummaryForAccount(accountID)
+		if err != nil {
+			slog.Error("failed to load allocation summary", "error", err, "account_id", accountID)
+			allocSummary = nil
+		}
 	}
 
 	props := pages.SpaceAccountPageProps{
@@ -357,10 +504,33 @@ func (h *spaceHandler) SpaceAccountPage(w http.ResponseWriter, r *http.Request)
 		AccountName:               account.Name,
 		AccountBalance:            account.Balance,
 		AccountCurrency:           account.Currency,
+		Archived:                  account.IsArchived(),
 		RecentTransactions:        recent,
 		NonEditableTransactionIDs: h.nonEditableTransactionIDs(recent),
+		TransactionTags:           h.transactionTags(recent),
 		AllocationSummary:         allocSummary,
 	}
+	if budget, err := h.limitService.Budget(accountID, time.Now()); err != nil {
+		slog.Error("failed to load budget", "error", err, "account_id", accountID)
+	} else {
+		props.Budget = budget
+	}
+	if recs, err := h.reconciliationSvc.History(accountID, 5); err != nil {
+		slog.Error("failed to load reconciliation history", "error", err, "account_id", accountID)
+	} else {
+		props.Reconciliations = recs
+	}
+	if account.IsLiability() {
+		props.Liability = account
+		if account.StatementClosingDay != nil {
+			cycles, err := h.transactionService.StatementCycles(account, time.Now(), 5)
+			if err != nil {
+				slog.Error("failed to load statement cycles", "error", err, "account_id", accountID)
+			} else {
+				props.StatementCycles = cycles
+			}
+		}
+	}
 	if account.IsInvestment {
 		year := time.Now().Year()
 		summary, err := h.investmentService.SummarizeAccount(accountID, year)
@@ -412,6 +582,13 @@ func (h *spaceHandler) SpaceAccountTransactionsPage(w http.ResponseWriter, r *ht
 
 	filter, filterValues := parseTransactionFilter(r)
 
+	tags, err := h.tagService.List(spaceID)
+	if err != nil {
+		slog.Error("failed to load tags", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load transactions", http.StatusInternalServerError)
+		return
+	}
+
 	total, err := h.transactionService.CountByAccountFiltered(accountID, filter)
 	if err != nil {
 		slog.Error("failed to count transactions", "error", err, "account_id", accountID)
@@ -435,19 +612,46 @@ func (h *spaceHandler) SpaceAccountTransactionsPage(w http.ResponseWriter, r *ht
 		return
 	}
 
+	var categories []*model.Category
+	if filterValues.Active {
+		categories, err = h.categoryService.ListByAccount(accountID)
+		if err != nil {
+			slog.Error("failed to load categories", "error", err, "account_id", accountID)
+			ui.RenderError(w, r, "Failed to load transactions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// With an end date, also answer "what was the balance then?".
+	var balanceAsOf *decimal.Decimal
+	if filter.DateTo != nil {
+		balance, err := h.transactionService.BalanceAsOf(accountID, *filter.DateTo)
+		if err != nil {
+			slog.Error("failed to compute balance", "error", err, "account_id", accountID)
+			ui.RenderError(w, r, "Failed to load transactions", http.StatusInternalServerError)
+			return
+		}
+		balanceAsOf = &balance
+	}
+
 	ui.Render(w, r, pages.SpaceAccountTransactionsPage(pages.SpaceAccountTransactionsPageProps{
 		SpaceID:                   spaceID,
 		SpaceName:                 space.Name,
 		AccountID:                 accountID,
 		AccountName:               account.Name,
+		AccountCurrency:           account.Currency,
 		Transactions:              txns,
 		NonEditableTransactionIDs: h.nonEditableTransactionIDs(txns),
+		TransactionTags:           h.transactionTags(txns),
 		CurrentPage:               page,
 		TotalPages:                totalPages,
 		TotalCount:                total,
 		PerPage:                   perPage,
 		Filter:                    filterValues,
 		FilterQuery:               filterValues.QueryString(),
+		Tags:                      tags,
+		BalanceAsOf:               balanceAsOf,
+		Categories:                categories,
 	}))
 }
 
@@ -467,6 +671,11 @@ func parseTransactionFilter(r *http.Request) (model.TransactionFilter, pages.Tra
 		AmountMin:  strings.TrimSpace(q.Get("amount_min")),
 		AmountMax:  strings.TrimSpace(q.Get("amount_max")),
 	}
+	for _, id := range q["tag"] {
+		if id = strings.TrimSpace(id); id != "" {
+			vals.TagIDs = append(vals.TagIDs, id)
+		}
+	}
 	if vals.AmountMode != "exact" && vals.AmountMode != "range" {
 		vals.AmountMode = ""
 	}
@@ -482,6 +691,9 @@ func parseTransactionFilter(r *http.Request) (model.TransactionFilter, pages.Tra
 		to := t.Add(24*time.Hour - time.Nanosecond)
 		filter.DateTo = &to
 	}
+	// Tag IDs are matched against the account's own transactions, so an ID
+	// from another space simply matches nothing.
+	filter.TagIDs = vals.TagIDs
 	switch vals.AmountMode {
 	case "exact":
 		if d, err := decimal.NewFromString(vals.Amount); err == nil {
@@ -524,6 +736,25 @@ func (h *spaceHandler) nonEditableTransactionIDs(txns []*model.Transaction) map[
 	return hits
 }
 
+// transactionTags returns the tags of each given transaction keyed by its ID.
+// Like nonEditableTransactionIDs, a failure only logs: the list still renders,
+// just without tags.
+func (h *spaceHandler) transactionTags(txns []*model.Transaction) map[string][]*model.Tag {
+	if len(txns) == 0 {
+		return nil
+	}
+	ids := make([]string, len(txns))
+	for i, t := range txns {
+		ids[i] = t.ID
+	}
+	tags, err := h.tagService.ListByTransactions(ids)
+	if err != nil {
+		slog.Error("failed to load transaction tags", "error", err)
+		return nil
+	}
+	return tags
+}
+
 func (h *spaceHandler) SpaceSettingsPage(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 
@@ -535,19 +766,65 @@ func (h *spaceHandler) SpaceSettingsPage(w http.ResponseWriter, r *http.Request)
 	}
 
 	user := ctxkeys.User(r.Context())
-	canDelete := user != nil && user.ID == space.OwnerID
+	isOwner := user != nil && user.ID == space.OwnerID
+
+	var drift []*model.BalanceDrift
+	if isOwner {
+		drift, err = h.accountService.BalanceDriftForSpace(space.ID)
+		if err != nil {
+			slog.Error("failed to check balances", "error", err, "space_id", spaceID)
+			ui.RenderError(w, r, "Failed to load settings", http.StatusInternalServerError)
+			return
+		}
+	}
 
 	ui.Render(w, r, pages.SpaceSettingsPage(pages.SpaceSettingsPageProps{
 		SpaceID:   space.ID,
 		SpaceName: space.Name,
-		CanDelete: canDelete,
+		CanDelete: isOwner,
 		UpdateForm: forms.UpdateSpaceProps{
 			SpaceID: space.ID,
 			Name:    space.Name,
 		},
+		CanRepairBalances: isOwner,
+		BalanceDrift:      drift,
 	}))
 }
 
+// HandleRepairBalance resets an account's balance to the sum of its ledger
+// postings. Only the space owner may repair balances.
+func (h *spaceHandler) HandleRepairBalance(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		ui.RenderError(w, r, "Space not found", http.StatusNotFound)
+		return
+	}
+
+	user := ctxkeys.User(r.Context())
+	if user == nil || user.ID != space.OwnerID {
+		ui.RenderError(w, r, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	accountID := r.PathValue("accountID")
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.accountService.RepairBalance(accountID, user.ID); err != nil {
+		slog.Error("failed to repair balance", "error", err, "account_id", accountID)
+		ui.RenderError(w, r, "Failed to repair balance", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
 func (h *spaceHandler) HandleRenameSpace(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 
@@ -795,7 +1072,7 @@ func (h *spaceHandler) SpaceCategoriesPage(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	categories, err := h.categoryService.ListByAccount(accountID)
+	tree, err := h.categoryService.Tree(accountID)
 	if err != nil {
 		slog.Error("failed to load categories", "error", err, "account_id", accountID)
 		ui.RenderError(w, r, "Failed to load categories", http.StatusInternalServerError)
@@ -807,11 +1084,99 @@ func (h *spaceHandler) SpaceCategoriesPage(w http.ResponseWriter, r *http.Reques
 		SpaceName:   space.Name,
 		AccountID:   accountID,
 		AccountName: account.Name,
-		Categories:  categories,
-		CreateForm:  forms.CreateCategoryProps{SpaceID: spaceID, AccountID: accountID},
+		Categories:  tree,
+		CreateForm: forms.CreateCategoryProps{
+			SpaceID:    spaceID,
+			AccountID:  accountID,
+			ParentID:   r.URL.Query().Get("parent"),
+			Categories: tree,
+		},
+		ImportForm: h.importCategoriesProps(spaceID, accountID),
 	}))
 }
 
+// importCategoriesProps lists the templates and other accounts an account
+// can take categories from.
+func (h *spaceHandler) importCategoriesProps(spaceID, accountID string) forms.ImportCategoriesProps {
+	props := forms.ImportCategoriesProps{
+		SpaceID:   spaceID,
+		AccountID: accountID,
+		Templates: h.categoryTemplates(spaceID),
+	}
+	accounts, err := h.accountService.GetAccountsForSpace(spaceID)
+	if err != nil {
+		slog.Error("failed to load accounts", "error", err, "space_id", spaceID)
+		return props
+	}
+	for _, a := range accounts {
+		if a.ID != accountID {
+			props.Accounts = append(props.Accounts, a)
+		}
+	}
+	return props
+}
+
+func (h *spaceHandler) HandleApplyCategoryTemplate(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	user := ctxkeys.User(r.Context())
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	templateID := r.FormValue("template_id")
+	if _, err := h.templateService.Apply(spaceID, templateID, accountID, user.ID); err != nil {
+		formProps := h.importCategoriesProps(spaceID, accountID)
+		formProps.TemplateID = templateID
+		if errors.Is(err, service.ErrCategoryTemplateNotFound) {
+			formProps.TemplateErr = "That template no longer exists. Pick another."
+		} else {
+			slog.Error("failed to apply category template", "error", err, "account_id", accountID, "template_id", templateID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.ImportCategories(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *spaceHandler) HandleCopyCategories(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	user := ctxkeys.User(r.Context())
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	sourceID := r.FormValue("source_account_id")
+	if _, err := h.categoryService.CopyFrom(accountID, sourceID, user.ID); err != nil {
+		formProps := h.importCategoriesProps(spaceID, accountID)
+		formProps.SourceAccountID = sourceID
+		switch {
+		case errors.Is(err, service.ErrCopyFromSameAccount):
+			formProps.AccountErr = "Pick a different account."
+		case errors.Is(err, repository.ErrAccountNotFound):
+			formProps.AccountErr = "That account no longer exists. Pick another."
+		default:
+			slog.Error("failed to copy categories", "error", err, "account_id", accountID, "source_account_id", sourceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.ImportCategories(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
 func (h *spaceHandler) HandleCreateCategory(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 	accountID := r.PathValue("accountID")
@@ -824,17 +1189,26 @@ func (h *spaceHandler) HandleCreateCategory(w http.ResponseWriter, r *http.Reque
 
 	name := strings.TrimSpace(r.FormValue("name"))
 	description := strings.TrimSpace(r.FormValue("description"))
+	parentID := r.FormValue("parent_id")
 	formProps := forms.CreateCategoryProps{
 		SpaceID:     spaceID,
 		AccountID:   accountID,
 		Name:        name,
 		Description: description,
+		ParentID:    parentID,
 	}
 
-	if _, err := h.categoryService.Create(accountID, name, description); err != nil {
+	if _, err := h.categoryService.CreateChild(accountID, parentID, name, description); err != nil {
+		if tree, treeErr := h.categoryService.Tree(accountID); treeErr == nil {
+			formProps.Categories = tree
+		}
 		switch {
 		case errors.Is(err, service.ErrCategoryNameTaken):
 			formProps.NameErr = "A category with this name already exists."
+		case errors.Is(err, service.ErrCategoryNotFound):
+			formProps.ParentErr = "The parent category no longer exists."
+		case errors.Is(err, service.ErrCategoryTooDeep):
+			formProps.ParentErr = "That category can't have subcategories."
 		case name == "":
 			formProps.NameErr = "Name is required."
 		case len(name) > 60:
@@ -862,7 +1236,11 @@ func (h *spaceHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.categoryService.Delete(accountID, categoryID); err != nil {
+	remove := h.categoryService.Delete
+	if r.FormValue("children") == "delete" {
+		remove = h.categoryService.DeleteWithChildren
+	}
+	if err := remove(accountID, categoryID); err != nil {
 		if errors.Is(err, service.ErrCategoryNotFound) {
 			ui.RenderError(w, r, "Category not found", http.StatusNotFound)
 			return
@@ -876,85 +1254,354 @@ func (h *spaceHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusOK)
 }
 
-func (h *spaceHandler) SpaceReportsPage(w http.ResponseWriter, r *http.Request) {
+func (h *spaceHandler) HandleEditCategory(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 	accountID := r.PathValue("accountID")
+	categoryID := r.PathValue("categoryID")
+	user := ctxkeys.User(r.Context())
 
 	account, err := h.accountService.GetAccount(accountID)
 	if err != nil || account.SpaceID != spaceID {
-		ui.Render(w, r, pages.NotFound())
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
 		return
 	}
 
-	space, err := h.spaceService.GetSpace(spaceID)
+	tree, err := h.categoryService.Tree(accountID)
 	if err != nil {
-		slog.Error("failed to load space", "error", err, "space_id", spaceID)
-		ui.RenderError(w, r, "Failed to load reports", http.StatusInternalServerError)
+		slog.Error("failed to load categories", "error", err, "account_id", accountID)
+		ui.RenderError(w, r, "Failed to update category", http.StatusInternalServerError)
 		return
 	}
 
-	q := r.URL.Query()
+	name := strings.TrimSpace(r.FormValue("name"))
+	parentID := r.FormValue("parent_id")
+	formProps := forms.EditCategoryProps{
+		SpaceID:    spaceID,
+		AccountID:  accountID,
+		CategoryID: categoryID,
+		Categories: tree,
+		Name:       name,
+		ParentID:   parentID,
+	}
 
-	typeParam := q.Get("type")
-	txType := model.TransactionTypeWithdrawal
-	if typeParam == "income" {
-		txType = model.TransactionTypeDeposit
-	} else {
-		typeParam = "spending"
+	if _, err := h.categoryService.Rename(accountID, categoryID, name, user.ID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCategoryNotFound):
+			ui.RenderError(w, r, "Category not found", http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrCategoryNameTaken):
+			formProps.NameErr = "A category with this name already exists."
+		case name == "":
+			formProps.NameErr = "Name is required."
+		case len(name) > 60:
+			formProps.NameErr = "Name must be at most 60 characters."
+		default:
+			slog.Error("failed to rename category", "error", err, "category_id", categoryID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.EditCategory(formProps))
+		return
 	}
 
-	granularity := strings.TrimSpace(q.Get("granularity"))
-	if granularity != "day" && granularity != "year" {
-		granularity = "month"
+	if _, err := h.categoryService.Move(accountID, categoryID, parentID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCategoryNotFound):
+			formProps.ParentErr = "The parent category no longer exists."
+		case errors.Is(err, service.ErrCategoryCycle):
+			formProps.ParentErr = "A category can't go under itself or its own subcategories."
+		case errors.Is(err, service.ErrCategoryTooDeep):
+			formProps.ParentErr = "Categories can only be nested " + strconv.Itoa(model.MaxCategoryDepth) + " levels deep."
+		default:
+			slog.Error("failed to move category", "error", err, "category_id", categoryID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.EditCategory(formProps))
+		return
 	}
 
-	includeUncategorized := q.Get("include_uncategorized") != ""
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
 
-	now := time.Now().UTC()
-	fromDate, toDate := defaultReportRange(now, granularity)
-	if v := strings.TrimSpace(q.Get("from")); v != "" {
-		if parsed, err := time.Parse("2006-01-02", v); err == nil {
-			fromDate = parsed
-		}
+func (h *spaceHandler) HandleMergeCategory(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	categoryID := r.PathValue("categoryID")
+	user := ctxkeys.User(r.Context())
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
 	}
-	if v := strings.TrimSpace(q.Get("to")); v != "" {
-		if parsed, err := time.Parse("2006-01-02", v); err == nil {
-			toDate = parsed
+
+	targetID := r.FormValue("target_id")
+	if _, err := h.categoryService.Merge(accountID, categoryID, targetID, user.ID); err != nil {
+		tree, treeErr := h.categoryService.Tree(accountID)
+		if treeErr != nil {
+			slog.Error("failed to load categories", "error", treeErr, "account_id", accountID)
+		}
+		formProps := forms.MergeCategoryProps{
+			SpaceID:    spaceID,
+			AccountID:  accountID,
+			CategoryID: categoryID,
+			Categories: tree,
+			TargetID:   targetID,
+		}
+		switch {
+		case errors.Is(err, service.ErrCategoryNotFound):
+			formProps.TargetErr = "Pick a category to merge into."
+		case errors.Is(err, service.ErrCategoryMergeIntoSelf):
+			formProps.TargetErr = "Pick a different category."
+		case errors.Is(err, service.ErrCategoryTooDeep):
+			formProps.TargetErr = "Its subcategories would be nested more than " + strconv.Itoa(model.MaxCategoryDepth) + " levels deep there."
+		default:
+			slog.Error("failed to merge categories", "error", err, "category_id", categoryID, "target_id", targetID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
 		}
+		ui.Render(w, r, forms.MergeCategory(formProps))
+		return
 	}
-	// The query bound is inclusive of the whole "to" day.
-	toBound := toDate.Add(24*time.Hour - time.Nanosecond)
 
-	props := pages.SpaceReportsPageProps{
-		SpaceID:              spaceID,
-		SpaceName:            space.Name,
-		AccountID:            accountID,
-		AccountName:          account.Name,
-		Type:                 typeParam,
-		Granularity:          granularity,
-		From:                 fromDate.Format("2006-01-02"),
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleRecategorizeTransactions gives every transaction matching the
+// transactions page's filter, passed in the query string, one category.
+func (h *spaceHandler) HandleRecategorizeTransactions(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	user := ctxkeys.User(r.Context())
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	filter, _ := parseTransactionFilter(r)
+	_, err = h.categoryService.Recategorize(service.RecategorizeInput{
+		AccountID:  accountID,
+		Filter:     filter,
+		CategoryID: r.FormValue("category_id"),
+		ActorID:    user.ID,
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrCategoryNotFound):
+		ui.RenderError(w, r, "Category not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrTooManyToRecategorize):
+		ui.RenderError(w, r, "Too many transactions match. Narrow the filter and try again.", http.StatusUnprocessableEntity)
+		return
+	default:
+		slog.Error("failed to re-categorize transactions", "error", err, "account_id", accountID)
+		ui.RenderError(w, r, "Failed to re-categorize transactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *spaceHandler) SpaceReportsPage(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load reports", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+
+	typeParam := q.Get("type")
+	txType := model.TransactionTypeWithdrawal
+	if typeParam == "income" {
+		txType = model.TransactionTypeDeposit
+	} else {
+		typeParam = "spending"
+	}
+
+	granularity := strings.TrimSpace(q.Get("granularity"))
+	if granularity != "day" && granularity != "year" {
+		granularity = "month"
+	}
+
+	includeUncategorized := q.Get("include_uncategorized") != ""
+	level := q.Get("level")
+	if level != "top" {
+		level = "leaf"
+	}
+	var parent *model.Category
+	if v := q.Get("parent"); v != "" {
+		if cat, err := h.categoryService.Get(accountID, v); err == nil {
+			parent = cat
+		}
+	}
+	// Converting only means something when the account is in another
+	// currency than the space reports in.
+	converted := q.Get("converted") != "" && account.Currency != space.ReportingCurrency
+
+	now := time.Now().UTC()
+	fromDate, toDate := defaultReportRange(now, granularity)
+	if v := strings.TrimSpace(q.Get("from")); v != "" {
+		if parsed, err := time.Parse("2006-01-02", v); err == nil {
+			fromDate = parsed
+		}
+	}
+	if v := strings.TrimSpace(q.Get("to")); v != "" {
+		if parsed, err := time.Parse("2006-01-02", v); err == nil {
+			toDate = parsed
+		}
+	}
+	// The query bound is inclusive of the whole "to" day.
+	toBound := toDate.Add(24*time.Hour - time.Nanosecond)
+
+	props := pages.SpaceReportsPageProps{
+		SpaceID:              spaceID,
+		SpaceName:            space.Name,
+		AccountID:            accountID,
+		AccountName:          account.Name,
+		Type:                 typeParam,
+		Granularity:          granularity,
+		From:                 fromDate.Format("2006-01-02"),
 		To:                   toDate.Format("2006-01-02"),
 		IncludeUncategorized: includeUncategorized,
+		Level:                level,
+		Parent:               parent,
+		AccountCurrency:      account.Currency,
+		ReportingCurrency:    space.ReportingCurrency,
+		Converted:            converted,
 	}
 
-	series, err := h.transactionService.CategoryTimeSeries(service.CategorySeriesInput{
+	seriesInput := service.CategorySeriesInput{
 		AccountID:            accountID,
 		Type:                 txType,
 		From:                 fromDate,
 		To:                   toBound,
 		Granularity:          granularity,
 		IncludeUncategorized: includeUncategorized,
-	})
-	if err != nil {
+		RollUp:               level == "top",
+	}
+	if parent != nil {
+		seriesInput.ParentID = parent.ID
+	}
+	if converted {
+		seriesInput.Currency = space.ReportingCurrency
+	}
+	series, err := h.transactionService.CategoryTimeSeries(seriesInput)
+	if errors.Is(err, service.ErrNoExchangeRate) {
+		props.ErrorMsg = "There's no exchange rate between " + account.Currency + " and " + space.ReportingCurrency + " yet. Add one on the exchange rates page."
+	} else if err != nil {
 		slog.Error("failed to build report", "error", err, "account_id", accountID)
 		props.ErrorMsg = "Couldn't build the report for this range. Try a smaller range or a coarser grouping."
 	} else {
 		props.Series = series
 	}
 
+	tagSeries, err := h.tagService.TagTimeSeries(service.TagSeriesInput{
+		AccountID:   accountID,
+		Type:        txType,
+		From:        fromDate,
+		To:          toBound,
+		Granularity: granularity,
+	})
+	if err != nil {
+		slog.Error("failed to build tag report", "error", err, "account_id", accountID)
+	} else {
+		props.TagSeries = tagSeries
+	}
+
 	ui.Render(w, r, pages.SpaceReportsPage(props))
 }
 
+// SpaceCategoryReportPage charts spending or income by category across every
+// account in the space, in its reporting currency.
+func (h *spaceHandler) SpaceCategoryReportPage(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+
+	q := r.URL.Query()
+
+	typeParam := q.Get("type")
+	txType := model.TransactionTypeWithdrawal
+	if typeParam == "income" {
+		txType = model.TransactionTypeDeposit
+	} else {
+		typeParam = "spending"
+	}
+
+	granularity := strings.TrimSpace(q.Get("granularity"))
+	if granularity != "day" && granularity != "year" {
+		granularity = "month"
+	}
+
+	includeUncategorized := q.Get("include_uncategorized") != ""
+	level := q.Get("level")
+	if level != "top" {
+		level = "leaf"
+	}
+
+	now := time.Now().UTC()
+	fromDate, toDate := defaultReportRange(now, granularity)
+	if v := strings.TrimSpace(q.Get("from")); v != "" {
+		if parsed, err := time.Parse("2006-01-02", v); err == nil {
+			fromDate = parsed
+		}
+	}
+	if v := strings.TrimSpace(q.Get("to")); v != "" {
+		if parsed, err := time.Parse("2006-01-02", v); err == nil {
+			toDate = parsed
+		}
+	}
+
+	props := pages.SpaceCategoryReportPageProps{
+		SpaceID:              spaceID,
+		SpaceName:            space.Name,
+		Type:                 typeParam,
+		Granularity:          granularity,
+		From:                 fromDate.Format("2006-01-02"),
+		To:                   toDate.Format("2006-01-02"),
+		IncludeUncategorized: includeUncategorized,
+		Level:                level,
+		Currency:             space.ReportingCurrency,
+	}
+
+	series, err := h.transactionService.SpaceCategoryTimeSeries(service.SpaceCategorySeriesInput{
+		SpaceID:              spaceID,
+		Type:                 txType,
+		From:                 fromDate,
+		To:                   toDate.Add(24*time.Hour - time.Nanosecond),
+		Granularity:          granularity,
+		IncludeUncategorized: includeUncategorized,
+		Currency:             space.ReportingCurrency,
+		RollUp:               level == "top",
+	})
+	if err != nil {
+		slog.Error("failed to build space report", "error", err, "space_id", spaceID)
+		props.ErrorMsg = "Couldn't build the report for this range. Try a smaller range or a coarser grouping."
+	} else {
+		props.Series = series
+	}
+
+	ui.Render(w, r, pages.SpaceCategoryReportPage(props))
+}
+
 // defaultReportRange returns the default [from, to] date window for a report at
 // the given granularity: last 30 days (day), last 12 months (month), or last 5
 // years (year).
@@ -1046,6 +1693,7 @@ func (h *spaceHandler) SpaceAccountSettingsPage(w http.ResponseWriter, r *http.R
 	if account.InvestmentSubtype != nil {
 		subtype = *account.InvestmentSubtype
 	}
+	cur := ctxkeys.Currencies(r.Context()).Get(account.Currency)
 	ui.Render(w, r, pages.SpaceAccountSettingsPage(pages.SpaceAccountSettingsPageProps{
 		SpaceID:           spaceID,
 		SpaceName:         space.Name,
@@ -1054,6 +1702,7 @@ func (h *spaceHandler) SpaceAccountSettingsPage(w http.ResponseWriter, r *http.R
 		AccountCurrency:   account.Currency,
 		IsInvestment:      account.IsInvestment,
 		InvestmentSubtype: subtype,
+		IsLoan:            account.Kind == model.AccountKindLoan,
 		UpdateForm: forms.UpdateAccountProps{
 			SpaceID:   spaceID,
 			AccountID: accountID,
@@ -1064,9 +1713,137 @@ func (h *spaceHandler) SpaceAccountSettingsPage(w http.ResponseWriter, r *http.R
 			AccountID:       accountID,
 			CurrentCurrency: account.Currency,
 		},
+		KindForm: forms.AccountKindProps{
+			SpaceID:   spaceID,
+			AccountID: accountID,
+			Fields:    accountKindFields(account, cur, "settings-"),
+		},
+		ArchiveForm: archiveAccountProps(account, cur),
 	}))
 }
 
+// archiveAccountProps fills the archive form for the account's current state.
+func archiveAccountProps(account *model.Account, cur currency.Currency) forms.ArchiveAccountProps {
+	props := forms.ArchiveAccountProps{
+		SpaceID:    account.SpaceID,
+		AccountID:  account.ID,
+		ArchivedAt: account.ArchivedAt,
+		Currency:   account.Currency,
+	}
+	if !account.Balance.IsZero() {
+		props.Balance = cur.Fixed(account.Balance)
+	}
+	return props
+}
+
+func (h *spaceHandler) HandleArchiveAccount(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+	acknowledged := r.FormValue("acknowledge_balance") == "1"
+	if err := h.accountService.ArchiveAccount(accountID, actorID, acknowledged); err != nil {
+		formProps := archiveAccountProps(account, ctxkeys.Currencies(r.Context()).Get(account.Currency))
+		if errors.Is(err, service.ErrArchiveNonZeroBalance) {
+			formProps.BalanceErr = "Confirm that the account is archived with its balance."
+			ui.Render(w, r, forms.ArchiveAccount(formProps))
+			return
+		}
+		slog.Error("failed to archive account", "error", err, "account_id", accountID)
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, forms.ArchiveAccount(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Redirect", routeurl.URL(
+		"page.app.spaces.space.accounts.account.settings",
+		"spaceID", spaceID, "accountID", accountID,
+	))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *spaceHandler) HandleUnarchiveAccount(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+	if err := h.accountService.UnarchiveAccount(accountID, actorID); err != nil {
+		slog.Error("failed to unarchive account", "error", err, "account_id", accountID)
+		formProps := archiveAccountProps(account, ctxkeys.Currencies(r.Context()).Get(account.Currency))
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, forms.ArchiveAccount(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Redirect", routeurl.URL(
+		"page.app.spaces.space.accounts.account.settings",
+		"spaceID", spaceID, "accountID", accountID,
+	))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *spaceHandler) HandleSetAccountKind(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	kind, terms, fields := parseAccountKindForm(r, "settings-")
+	formProps := forms.AccountKindProps{SpaceID: spaceID, AccountID: accountID, Fields: fields}
+	if fields.KindErr != "" || fields.TermsErr != "" {
+		ui.Render(w, r, forms.AccountKind(formProps))
+		return
+	}
+	if kind.IsLiability() && account.IsInvestment {
+		formProps.Fields.KindErr = "Turn off investment tracking before making this account track a debt."
+		ui.Render(w, r, forms.AccountKind(formProps))
+		return
+	}
+
+	user := ctxkeys.User(r.Context())
+	actorID := ""
+	if user != nil {
+		actorID = user.ID
+	}
+	if err := h.accountService.SetKind(accountID, kind, terms, actorID); err != nil {
+		if errors.Is(err, service.ErrAccountHasAllocations) {
+			formProps.Fields.KindErr = "Remove this account's savings goals before making it track a debt."
+			ui.Render(w, r, forms.AccountKind(formProps))
+			return
+		}
+		if errors.Is(err, service.ErrLoanAccountKind) {
+			formProps.Fields.KindErr = "This account is a loan. Its terms are managed from the Loan page."
+			ui.Render(w, r, forms.AccountKind(formProps))
+			return
+		}
+		slog.Error("failed to update account kind", "error", err, "account_id", accountID)
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, forms.AccountKind(formProps))
+		return
+	}
+	formProps.SuccessMsg = "Account kind updated."
+	ui.Render(w, r, forms.AccountKind(formProps))
+}
+
 func (h *spaceHandler) HandleSetInvestmentFlag(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 	accountID := r.PathValue("accountID")
@@ -1290,10 +2067,11 @@ func (h *spaceHandler) SpaceCreateBillPage(w http.ResponseWriter, r *http.Reques
 		AccountID:   accountID,
 		AccountName: account.Name,
 		Form: forms.CreateBillProps{
-			SpaceID:    spaceID,
-			AccountID:  accountID,
-			Categories: categories,
-			Date:       time.Now().Format("2006-01-02"),
+			SpaceID:        spaceID,
+			AccountID:      accountID,
+			Categories:     categories,
+			Date:           time.Now().Format("2006-01-02"),
+			TagSuggestions: h.tagSuggestions(spaceID),
 		},
 	}))
 }
@@ -1333,10 +2111,11 @@ func (h *spaceHandler) SpaceCreateDepositPage(w http.ResponseWriter, r *http.Req
 		AccountID:   accountID,
 		AccountName: account.Name,
 		Form: forms.CreateDepositProps{
-			SpaceID:    spaceID,
-			AccountID:  accountID,
-			Categories: categories,
-			Date:       time.Now().Format("2006-01-02"),
+			SpaceID:        spaceID,
+			AccountID:      accountID,
+			Categories:     categories,
+			Date:           time.Now().Format("2006-01-02"),
+			TagSuggestions: h.tagSuggestions(spaceID),
 		},
 	}))
 }
@@ -1350,6 +2129,7 @@ func (h *spaceHandler) HandleCreateDeposit(w http.ResponseWriter, r *http.Reques
 	dateInput := strings.TrimSpace(r.FormValue("date"))
 	descriptionInput := strings.TrimSpace(r.FormValue("description"))
 	categoryInput := strings.TrimSpace(r.FormValue("category"))
+	tagNames, tagsErr := formTags(r)
 
 	categories, err := h.categoryService.ListByAccount(accountID)
 	if err != nil {
@@ -1359,22 +2139,29 @@ func (h *spaceHandler) HandleCreateDeposit(w http.ResponseWriter, r *http.Reques
 	}
 
 	formProps := forms.CreateDepositProps{
-		SpaceID:     spaceID,
-		AccountID:   accountID,
-		Categories:  categories,
-		Title:       titleInput,
-		Amount:      amountInput,
-		Date:        dateInput,
-		Description: descriptionInput,
-		CategoryID:  categoryInput,
+		SpaceID:        spaceID,
+		AccountID:      accountID,
+		Categories:     categories,
+		Title:          titleInput,
+		Amount:         amountInput,
+		Date:           dateInput,
+		Description:    descriptionInput,
+		CategoryID:     categoryInput,
+		Tags:           tagNames,
+		TagSuggestions: h.tagSuggestions(spaceID),
 	}
 
 	hasErr := false
+	if tagsErr != "" {
+		formProps.TagsErr = tagsErr
+		hasErr = true
+	}
 	if titleInput == "" {
 		formProps.TitleErr = "Title is required."
 		hasErr = true
 	}
 
+	cur := h.accountCurrency(r, accountID)
 	var amount decimal.Decimal
 	if amountInput == "" {
 		formProps.AmountErr = "Amount is required."
@@ -1387,8 +2174,8 @@ func (h *spaceHandler) HandleCreateDeposit(w http.ResponseWriter, r *http.Reques
 		} else if !amt.IsPositive() {
 			formProps.AmountErr = "Amount must be greater than zero."
 			hasErr = true
-		} else if amt.Exponent() < -2 {
-			formProps.AmountErr = "Amount can have at most 2 decimal places."
+		} else if !cur.Fits(amt) {
+			formProps.AmountErr = decimalsErr("Amount", cur)
 			hasErr = true
 		} else {
 			amount = amt
@@ -1414,6 +2201,14 @@ func (h *spaceHandler) HandleCreateDeposit(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	tagIDs, err := h.resolveTagIDs(spaceID, tagNames)
+	if err != nil {
+		slog.Error("failed to resolve tags", "error", err, "space_id", spaceID)
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, forms.CreateDeposit(formProps))
+		return
+	}
+
 	actorID := ""
 	if u := ctxkeys.User(r.Context()); u != nil {
 		actorID = u.ID
@@ -1425,10 +2220,16 @@ func (h *spaceHandler) HandleCreateDeposit(w http.ResponseWriter, r *http.Reques
 		OccurredAt:  occurredAt,
 		Description: descriptionInput,
 		CategoryID:  categoryInput,
+		TagIDs:      tagIDs,
 		ActorID:     actorID,
 	})
 	if err != nil {
-		slog.Error("failed to create deposit", "error", err, "account_id", accountID)
+		if errors.Is(err, service.ErrAccountArchived) {
+			formProps.GeneralErr = "This account is archived. Unarchive it from its settings to add transactions."
+			ui.Render(w, r, forms.CreateDeposit(formProps))
+			return
+		}
+		slog.Error("failed to create deposit", "error", err, "account_id", accountID)
 		formProps.GeneralErr = "Something went wrong. Please try again."
 		ui.Render(w, r, forms.CreateDeposit(formProps))
 		return
@@ -1468,22 +2269,36 @@ func (h *spaceHandler) SpaceTransactionPage(w http.ResponseWriter, r *http.Reque
 	}
 
 	categoryName := ""
-	if categoryID, err := h.transactionService.GetTransactionCategoryID(transactionID); err != nil {
-		slog.Error("failed to load transaction category", "error", err, "transaction_id", transactionID)
-	} else if categoryID != "" {
+	var splitLines []pages.TransactionSplitLine
+	if splits, err := h.transactionService.GetTransactionSplits(transactionID); err != nil {
+		slog.Error("failed to load transaction splits", "error", err, "transaction_id", transactionID)
+	} else if len(splits) > 0 {
 		categories, err := h.categoryService.ListByAccount(accountID)
 		if err != nil {
 			slog.Error("failed to load categories", "error", err)
 		} else {
+			nameByID := make(map[string]string, len(categories))
 			for _, c := range categories {
-				if c.ID == categoryID {
-					categoryName = c.Name
-					break
+				nameByID[c.ID] = c.Name
+			}
+			categoryName = nameByID[splits[0].CategoryID]
+			if len(splits) > 1 {
+				for _, split := range splits {
+					splitLines = append(splitLines, pages.TransactionSplitLine{
+						CategoryName: nameByID[split.CategoryID],
+						Amount:       split.Amount,
+					})
 				}
 			}
 		}
 	}
 
+	tags, err := h.tagService.ListByTransaction(transactionID)
+	if err != nil {
+		slog.Error("failed to load transaction tags", "error", err, "transaction_id", transactionID)
+		tags = nil
+	}
+
 	relatedID, err := h.transactionService.GetRelatedTransactionID(transactionID)
 	if err != nil {
 		slog.Error("failed to load related transaction", "error", err, "transaction_id", transactionID)
@@ -1515,17 +2330,27 @@ func (h *spaceHandler) SpaceTransactionPage(w http.ResponseWriter, r *http.Reque
 		logCount = len(recentLogs)
 	}
 
+	attachments, err := h.attachmentService.List(transactionID)
+	if err != nil {
+		slog.Error("failed to load transaction attachments", "error", err, "transaction_id", transactionID)
+		attachments = nil
+	}
+
 	ui.Render(w, r, pages.SpaceTransactionPage(pages.SpaceTransactionPageProps{
 		SpaceID:            spaceID,
 		SpaceName:          space.Name,
 		AccountID:          accountID,
 		AccountName:        account.Name,
+		AccountCurrency:    account.Currency,
 		Transaction:        txn,
 		CategoryName:       categoryName,
+		Splits:             splitLines,
+		Tags:               tags,
 		RecentAuditLogs:    recentLogs,
 		AuditLogCount:      logCount,
 		RelatedTransaction: relatedTxn,
 		RelatedAccount:     relatedAccount,
+		Attachments:        attachments,
 	}))
 }
 
@@ -1675,14 +2500,14 @@ func (h *spaceHandler) SpaceEditTransactionPage(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Transfers must be edited as a pair; refuse the edit page entirely.
+	// Transfers must be edited as a pair; send them to the transfer editor.
 	relatedID, err := h.transactionService.GetRelatedTransactionID(transactionID)
 	if err != nil {
 		slog.Error("failed to check transfer linkage", "error", err, "transaction_id", transactionID)
 	}
 	if relatedID != "" {
 		redirectTo := routeurl.URL(
-			"page.app.spaces.space.accounts.account.transactions.transaction",
+			"page.app.spaces.space.accounts.account.transactions.transaction.transfer.edit",
 			"spaceID", spaceID,
 			"accountID", accountID,
 			"transactionID", transactionID,
@@ -1698,6 +2523,7 @@ func (h *spaceHandler) SpaceEditTransactionPage(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	cur := ctxkeys.Currencies(r.Context()).Get(account.Currency)
 	description := ""
 	if txn.Description != nil {
 		description = *txn.Description
@@ -1722,30 +2548,56 @@ func (h *spaceHandler) SpaceEditTransactionPage(w http.ResponseWriter, r *http.R
 		slog.Error("failed to load transaction category", "error", err, "transaction_id", transactionID)
 		categoryID = ""
 	}
+	splits, err := h.transactionService.GetTransactionSplits(transactionID)
+	if err != nil {
+		slog.Error("failed to load transaction splits", "error", err, "transaction_id", transactionID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	// A single split is just the category select; only open the split editor
+	// for transactions that are actually split.
+	var splitRows []forms.SplitRow
+	if len(splits) > 1 {
+		for _, split := range splits {
+			splitRows = append(splitRows, forms.SplitRow{CategoryID: split.CategoryID, Amount: cur.Fixed(split.Amount)})
+		}
+	}
+	tags, err := h.tagService.ListByTransaction(transactionID)
+	if err != nil {
+		slog.Error("failed to load transaction tags", "error", err, "transaction_id", transactionID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
 
 	if txn.Type == model.TransactionTypeDeposit {
 		pageProps.DepositForm = forms.EditDepositProps{
-			SpaceID:       spaceID,
-			AccountID:     accountID,
-			TransactionID: transactionID,
-			Categories:    categories,
-			Title:         txn.Title,
-			Amount:        txn.Value.StringFixedBank(2),
-			Date:          txn.OccurredAt.Format("2006-01-02"),
-			Description:   description,
-			CategoryID:    categoryID,
+			SpaceID:        spaceID,
+			AccountID:      accountID,
+			TransactionID:  transactionID,
+			Categories:     categories,
+			Title:          txn.Title,
+			Amount:         cur.Fixed(txn.Value),
+			Date:           txn.OccurredAt.Format("2006-01-02"),
+			Description:    description,
+			CategoryID:     categoryID,
+			Splits:         splitRows,
+			Tags:           forms.TagNames(tags),
+			TagSuggestions: h.tagSuggestions(spaceID),
 		}
 	} else {
 		pageProps.BillForm = forms.EditBillProps{
-			SpaceID:       spaceID,
-			AccountID:     accountID,
-			TransactionID: transactionID,
-			Categories:    categories,
-			Title:         txn.Title,
-			Amount:        txn.Value.StringFixedBank(2),
-			Date:          txn.OccurredAt.Format("2006-01-02"),
-			Description:   description,
-			CategoryID:    categoryID,
+			SpaceID:        spaceID,
+			AccountID:      accountID,
+			TransactionID:  transactionID,
+			Categories:     categories,
+			Title:          txn.Title,
+			Amount:         cur.Fixed(txn.Value),
+			Date:           txn.OccurredAt.Format("2006-01-02"),
+			Description:    description,
+			CategoryID:     categoryID,
+			Splits:         splitRows,
+			Tags:           forms.TagNames(tags),
+			TagSuggestions: h.tagSuggestions(spaceID),
 		}
 	}
 
@@ -1783,6 +2635,7 @@ func (h *spaceHandler) HandleEditTransaction(w http.ResponseWriter, r *http.Requ
 	dateInput := strings.TrimSpace(r.FormValue("date"))
 	descriptionInput := strings.TrimSpace(r.FormValue("description"))
 	categoryInput := strings.TrimSpace(r.FormValue("category"))
+	tagNames, tagsErr := formTags(r)
 
 	categories, err := h.categoryService.ListByAccount(accountID)
 	if err != nil {
@@ -1792,13 +2645,14 @@ func (h *spaceHandler) HandleEditTransaction(w http.ResponseWriter, r *http.Requ
 	}
 
 	titleErr, amountErr, dateErr := "", "", ""
-	hasErr := false
+	hasErr := tagsErr != ""
 
 	if titleInput == "" {
 		titleErr = "Title is required."
 		hasErr = true
 	}
 
+	cur := ctxkeys.Currencies(r.Context()).Get(account.Currency)
 	var amount decimal.Decimal
 	if amountInput == "" {
 		amountErr = "Amount is required."
@@ -1811,8 +2665,8 @@ func (h *spaceHandler) HandleEditTransaction(w http.ResponseWriter, r *http.Requ
 		} else if !amt.IsPositive() {
 			amountErr = "Amount must be greater than zero."
 			hasErr = true
-		} else if amt.Exponent() < -2 {
-			amountErr = "Amount can have at most 2 decimal places."
+		} else if !cur.Fits(amt) {
+			amountErr = decimalsErr("Amount", cur)
 			hasErr = true
 		} else {
 			amount = amt
@@ -1833,25 +2687,42 @@ func (h *spaceHandler) HandleEditTransaction(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	splitRows, splits, splitsErr := formSplits(r, amount, cur)
+	if splitsErr != "" {
+		hasErr = true
+	}
+
 	if txn.Type == model.TransactionTypeDeposit {
 		formProps := forms.EditDepositProps{
-			SpaceID:       spaceID,
-			AccountID:     accountID,
-			TransactionID: transactionID,
-			Categories:    categories,
-			Title:         titleInput,
-			Amount:        amountInput,
-			Date:          dateInput,
-			Description:   descriptionInput,
-			CategoryID:    categoryInput,
-			TitleErr:      titleErr,
-			AmountErr:     amountErr,
-			DateErr:       dateErr,
+			SpaceID:        spaceID,
+			AccountID:      accountID,
+			TransactionID:  transactionID,
+			Categories:     categories,
+			Title:          titleInput,
+			Amount:         amountInput,
+			Date:           dateInput,
+			Description:    descriptionInput,
+			CategoryID:     categoryInput,
+			Splits:         splitRows,
+			Tags:           tagNames,
+			TagSuggestions: h.tagSuggestions(spaceID),
+			TitleErr:       titleErr,
+			AmountErr:      amountErr,
+			DateErr:        dateErr,
+			TagsErr:        tagsErr,
+			SplitsErr:      splitsErr,
 		}
 		if hasErr {
 			ui.Render(w, r, forms.EditDeposit(formProps))
 			return
 		}
+		tagIDs, err := h.resolveTagIDs(spaceID, tagNames)
+		if err != nil {
+			slog.Error("failed to resolve tags", "error", err, "space_id", spaceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+			ui.Render(w, r, forms.EditDeposit(formProps))
+			return
+		}
 		actorID := ""
 		if u := ctxkeys.User(r.Context()); u != nil {
 			actorID = u.ID
@@ -1863,8 +2734,20 @@ func (h *spaceHandler) HandleEditTransaction(w http.ResponseWriter, r *http.Requ
 			OccurredAt:    occurredAt,
 			Description:   descriptionInput,
 			CategoryID:    categoryInput,
+			Splits:        splits,
+			TagIDs:        tagIDs,
 			ActorID:       actorID,
 		}); err != nil {
+			if errors.Is(err, service.ErrSplitsDoNotSum) {
+				formProps.SplitsErr = "Splits must add up to the amount."
+				ui.Render(w, r, forms.EditDeposit(formProps))
+				return
+			}
+			if errors.Is(err, service.ErrTransactionReconciled) {
+				formProps.GeneralErr = "This deposit is reconciled. Unlock it before editing."
+				ui.Render(w, r, forms.EditDeposit(formProps))
+				return
+			}
 			slog.Error("failed to update deposit", "error", err, "transaction_id", transactionID)
 			formProps.GeneralErr = "Something went wrong. Please try again."
 			ui.Render(w, r, forms.EditDeposit(formProps))
@@ -1882,23 +2765,35 @@ func (h *spaceHandler) HandleEditTransaction(w http.ResponseWriter, r *http.Requ
 	}
 
 	formProps := forms.EditBillProps{
-		SpaceID:       spaceID,
-		AccountID:     accountID,
-		TransactionID: transactionID,
-		Categories:    categories,
-		Title:         titleInput,
-		Amount:        amountInput,
-		Date:          dateInput,
-		Description:   descriptionInput,
-		CategoryID:    categoryInput,
-		TitleErr:      titleErr,
-		AmountErr:     amountErr,
-		DateErr:       dateErr,
+		SpaceID:        spaceID,
+		AccountID:      accountID,
+		TransactionID:  transactionID,
+		Categories:     categories,
+		Title:          titleInput,
+		Amount:         amountInput,
+		Date:           dateInput,
+		Description:    descriptionInput,
+		CategoryID:     categoryInput,
+		Splits:         splitRows,
+		Tags:           tagNames,
+		TagSuggestions: h.tagSuggestions(spaceID),
+		TitleErr:       titleErr,
+		AmountErr:      amountErr,
+		DateErr:        dateErr,
+		TagsErr:        tagsErr,
+		SplitsErr:      splitsErr,
 	}
 	if hasErr {
 		ui.Render(w, r, forms.EditBill(formProps))
 		return
 	}
+	tagIDs, err := h.resolveTagIDs(spaceID, tagNames)
+	if err != nil {
+		slog.Error("failed to resolve tags", "error", err, "space_id", spaceID)
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, forms.EditBill(formProps))
+		return
+	}
 	actorID := ""
 	if u := ctxkeys.User(r.Context()); u != nil {
 		actorID = u.ID
@@ -1910,8 +2805,20 @@ func (h *spaceHandler) HandleEditTransaction(w http.ResponseWriter, r *http.Requ
 		OccurredAt:    occurredAt,
 		Description:   descriptionInput,
 		CategoryID:    categoryInput,
+		Splits:        splits,
+		TagIDs:        tagIDs,
 		ActorID:       actorID,
 	}); err != nil {
+		if errors.Is(err, service.ErrSplitsDoNotSum) {
+			formProps.SplitsErr = "Splits must add up to the amount."
+			ui.Render(w, r, forms.EditBill(formProps))
+			return
+		}
+		if errors.Is(err, service.ErrTransactionReconciled) {
+			formProps.GeneralErr = "This bill is reconciled. Unlock it before editing."
+			ui.Render(w, r, forms.EditBill(formProps))
+			return
+		}
 		slog.Error("failed to update bill", "error", err, "transaction_id", transactionID)
 		formProps.GeneralErr = "Something went wrong. Please try again."
 		ui.Render(w, r, forms.EditBill(formProps))
@@ -1927,6 +2834,296 @@ func (h *spaceHandler) HandleEditTransaction(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusOK)
 }
 
+func (h *spaceHandler) SpaceEditTransferPage(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	transactionID := r.PathValue("transactionID")
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+
+	txn, err := h.transactionService.GetTransaction(transactionID)
+	if err != nil || txn.AccountID != accountID {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+
+	pair, err := h.transactionService.GetTransferPair(transactionID)
+	if err != nil {
+		if !errors.Is(err, service.ErrNotATransfer) {
+			slog.Error("failed to load transfer", "error", err, "transaction_id", transactionID)
+		}
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		slog.Error("failed to load space", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	formProps, err := h.editTransferProps(spaceID, accountID, transactionID, pair)
+	if err != nil {
+		slog.Error("failed to load transfer accounts", "error", err, "transaction_id", transactionID)
+		ui.RenderError(w, r, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+	formProps.Title = pair.Withdrawal.Title
+	formProps.Amount = ctxkeys.Currencies(r.Context()).Get(account.Currency).Fixed(pair.Withdrawal.Value)
+	formProps.Date = pair.Withdrawal.OccurredAt.Format("2006-01-02")
+	if pair.Withdrawal.Description != nil {
+		formProps.Description = *pair.Withdrawal.Description
+	}
+	// The rate isn't stored; recover it from the two amounts. Full precision
+	// means re-saving unchanged reproduces the same converted amount.
+	if formProps.SourceCurrency != formProps.DestCurrency {
+		formProps.ConversionRate = pair.Deposit.Value.Div(pair.Withdrawal.Value).String()
+	}
+
+	ui.Render(w, r, pages.SpaceEditTransactionPage(pages.SpaceEditTransactionPageProps{
+		SpaceID:         spaceID,
+		SpaceName:       space.Name,
+		AccountID:       accountID,
+		AccountName:     account.Name,
+		TransactionType: txn.Type,
+		IsTransfer:      true,
+		TransferForm:    formProps,
+	}))
+}
+
+func (h *spaceHandler) HandleEditTransfer(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	transactionID := r.PathValue("transactionID")
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	txn, err := h.transactionService.GetTransaction(transactionID)
+	if err != nil || txn.AccountID != accountID {
+		ui.RenderError(w, r, "Transaction not found", http.StatusNotFound)
+		return
+	}
+
+	pair, err := h.transactionService.GetTransferPair(transactionID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotATransfer) {
+			ui.RenderError(w, r, "Transaction is not a transfer.", http.StatusBadRequest)
+			return
+		}
+		slog.Error("failed to load transfer", "error", err, "transaction_id", transactionID)
+		ui.RenderError(w, r, "Failed to load transfer", http.StatusInternalServerError)
+		return
+	}
+
+	formProps, err := h.editTransferProps(spaceID, accountID, transactionID, pair)
+	if err != nil {
+		slog.Error("failed to load transfer accounts", "error", err, "transaction_id", transactionID)
+		ui.RenderError(w, r, "Failed to load transfer", http.StatusInternalServerError)
+		return
+	}
+
+	titleInput := strings.TrimSpace(r.FormValue("title"))
+	amountInput := strings.TrimSpace(r.FormValue("amount"))
+	rateInput := strings.TrimSpace(r.FormValue("rate"))
+	dateInput := strings.TrimSpace(r.FormValue("date"))
+	descriptionInput := strings.TrimSpace(r.FormValue("description"))
+
+	formProps.Title = titleInput
+	formProps.Amount = amountInput
+	formProps.ConversionRate = rateInput
+	formProps.Date = dateInput
+	formProps.Description = descriptionInput
+
+	hasErr := false
+	if titleInput == "" {
+		formProps.TitleErr = "Title is required."
+		hasErr = true
+	}
+
+	cur := ctxkeys.Currencies(r.Context()).Get(account.Currency)
+	var amount decimal.Decimal
+	if amountInput == "" {
+		formProps.AmountErr = "Amount is required."
+		hasErr = true
+	} else {
+		amt, err := decimal.NewFromString(amountInput)
+		if err != nil {
+			formProps.AmountErr = "Enter a valid amount (e.g. 12.34)."
+			hasErr = true
+		} else if !amt.IsPositive() {
+			formProps.AmountErr = "Amount must be greater than zero."
+			hasErr = true
+		} else if !cur.Fits(amt) {
+			formProps.AmountErr = decimalsErr("Amount", cur)
+			hasErr = true
+		} else {
+			amount = amt
+		}
+	}
+
+	var rate decimal.Decimal
+	if formProps.SourceCurrency != formProps.DestCurrency {
+		if rateInput == "" {
+			formProps.RateErr = "Conversion rate is required for cross-currency transfers."
+			hasErr = true
+		} else {
+			r, err := decimal.NewFromString(rateInput)
+			if err != nil {
+				formProps.RateErr = "Enter a valid rate (e.g. 1.2345)."
+				hasErr = true
+			} else if !r.IsPositive() {
+				formProps.RateErr = "Rate must be greater than zero."
+				hasErr = true
+			} else {
+				rate = r
+			}
+		}
+	}
+
+	var occurredAt time.Time
+	if dateInput == "" {
+		formProps.DateErr = "Date is required."
+		hasErr = true
+	} else {
+		parsed, err := time.Parse("2006-01-02", dateInput)
+		if err != nil {
+			formProps.DateErr = "Enter a valid date."
+			hasErr = true
+		} else {
+			occurredAt = parsed
+		}
+	}
+
+	if hasErr {
+		ui.Render(w, r, forms.EditTransfer(formProps))
+		return
+	}
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+
+	if _, err := h.transactionService.UpdateTransfer(service.UpdateTransferInput{
+		TransactionID:  transactionID,
+		Title:          titleInput,
+		Amount:         amount,
+		ConversionRate: rate,
+		OccurredAt:     occurredAt,
+		Description:    descriptionInput,
+		ActorID:        actorID,
+	}); err != nil {
+		if errors.Is(err, service.ErrTransferExceedsAvailable) {
+			formProps.AmountErr = "Amount exceeds the available balance of " + formProps.SourceName + "."
+			ui.Render(w, r, forms.EditTransfer(formProps))
+			return
+		}
+		if errors.Is(err, service.ErrTransactionReconciled) {
+			formProps.GeneralErr = "One side of this transfer is reconciled. Unlock it before editing."
+			ui.Render(w, r, forms.EditTransfer(formProps))
+			return
+		}
+		if errors.Is(err, service.ErrLoanPaymentChanged) {
+			formProps.GeneralErr = "This transfer is a loan payment, so its amount and date can't change. Undo it and record it again."
+			ui.Render(w, r, forms.EditTransfer(formProps))
+			return
+		}
+		slog.Error("failed to update transfer", "error", err, "transaction_id", transactionID)
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, forms.EditTransfer(formProps))
+		return
+	}
+
+	redirectTo := routeurl.URL(
+		"page.app.spaces.space.accounts.account.transactions.transaction",
+		"spaceID", spaceID,
+		"accountID", accountID,
+		"transactionID", transactionID,
+	)
+	w.Header().Set("HX-Redirect", redirectTo)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *spaceHandler) HandleUndoTransfer(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	transactionID := r.PathValue("transactionID")
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	txn, err := h.transactionService.GetTransaction(transactionID)
+	if err != nil || txn.AccountID != accountID {
+		ui.RenderError(w, r, "Transaction not found", http.StatusNotFound)
+		return
+	}
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+
+	if _, err := h.transactionService.UndoTransfer(service.UndoTransferInput{
+		TransactionID: transactionID,
+		ActorID:       actorID,
+	}); err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotATransfer):
+			ui.RenderError(w, r, "Transaction is not a transfer.", http.StatusBadRequest)
+		case errors.Is(err, service.ErrTransferExceedsAvailable):
+			ui.RenderError(w, r, "The receiving account no longer has enough unallocated funds to undo this transfer.", http.StatusUnprocessableEntity)
+		case errors.Is(err, service.ErrTransactionReconciled):
+			ui.RenderError(w, r, "One side of this transfer is reconciled. Unlock it before undoing.", http.StatusConflict)
+		default:
+			slog.Error("failed to undo transfer", "error", err, "transaction_id", transactionID)
+			ui.RenderError(w, r, "Failed to undo transfer", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	redirectTo := routeurl.URL(
+		"page.app.spaces.space.accounts.account.overview",
+		"spaceID", spaceID,
+		"accountID", accountID,
+	)
+	w.Header().Set("HX-Redirect", redirectTo)
+	w.WriteHeader(http.StatusOK)
+}
+
+// editTransferProps fills the parts of the transfer edit form that come from
+// the pair's accounts rather than from user input.
+func (h *spaceHandler) editTransferProps(spaceID, accountID, transactionID string, pair *service.TransferResult) (forms.EditTransferProps, error) {
+	source, err := h.accountService.GetAccount(pair.Withdrawal.AccountID)
+	if err != nil {
+		return forms.EditTransferProps{}, err
+	}
+	dest, err := h.accountService.GetAccount(pair.Deposit.AccountID)
+	if err != nil {
+		return forms.EditTransferProps{}, err
+	}
+	return forms.EditTransferProps{
+		SpaceID:        spaceID,
+		AccountID:      accountID,
+		TransactionID:  transactionID,
+		SourceName:     source.Name,
+		SourceCurrency: source.Currency,
+		DestName:       dest.Name,
+		DestCurrency:   dest.Currency,
+	}, nil
+}
+
 func (h *spaceHandler) HandleDeleteTransaction(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 	accountID := r.PathValue("accountID")
@@ -1957,6 +3154,10 @@ func (h *spaceHandler) HandleDeleteTransaction(w http.ResponseWriter, r *http.Re
 			ui.RenderError(w, r, "Transfer transactions cannot be deleted.", http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, service.ErrTransactionReconciled) {
+			ui.RenderError(w, r, "This transaction is reconciled. Unlock it before deleting.", http.StatusConflict)
+			return
+		}
 		slog.Error("failed to delete transaction", "error", err, "transaction_id", transactionID)
 		ui.RenderError(w, r, "Failed to delete transaction", http.StatusInternalServerError)
 		return
@@ -1971,6 +3172,41 @@ func (h *spaceHandler) HandleDeleteTransaction(w http.ResponseWriter, r *http.Re
 	w.WriteHeader(http.StatusOK)
 }
 
+func (h *spaceHandler) HandleUnlockTransaction(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	accountID := r.PathValue("accountID")
+	transactionID := r.PathValue("transactionID")
+
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil || account.SpaceID != spaceID {
+		ui.RenderError(w, r, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	txn, err := h.transactionService.GetTransaction(transactionID)
+	if err != nil || txn.AccountID != accountID {
+		ui.RenderError(w, r, "Transaction not found", http.StatusNotFound)
+		return
+	}
+
+	actorID := ""
+	if u := ctxkeys.User(r.Context()); u != nil {
+		actorID = u.ID
+	}
+
+	if _, err := h.transactionService.UnlockTransaction(service.UnlockTransactionInput{
+		TransactionID: transactionID,
+		ActorID:       actorID,
+	}); err != nil {
+		slog.Error("failed to unlock transaction", "error", err, "transaction_id", transactionID)
+		ui.RenderError(w, r, "Failed to unlock transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
 func (h *spaceHandler) SpaceCreateTransferPage(w http.ResponseWriter, r *http.Request) {
 	spaceID := r.PathValue("spaceID")
 	accountID := r.PathValue("accountID")
@@ -2002,6 +3238,7 @@ func (h *spaceHandler) SpaceCreateTransferPage(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	cur := ctxkeys.Currencies(r.Context()).Get(account.Currency)
 	ui.Render(w, r, pages.SpaceCreateTransferPage(pages.SpaceCreateTransferPageProps{
 		SpaceID:     spaceID,
 		SpaceName:   space.Name,
@@ -2012,10 +3249,11 @@ func (h *spaceHandler) SpaceCreateTransferPage(w http.ResponseWriter, r *http.Re
 			SourceAccountID: accountID,
 			SourceCurrency:  account.Currency,
 			DestAccounts:    dests,
-			SourceAvailable: allocSummary.Available.StringFixedBank(2),
-			SourceAllocated: allocSummary.Allocated.StringFixedBank(2),
+			SourceAvailable: cur.Fixed(allocSummary.Available),
+			SourceAllocated: cur.Fixed(allocSummary.Allocated),
 			SourceOverflow:  allocSummary.Overflow,
 			Date:            time.Now().Format("2006-01-02"),
+			TagSuggestions:  h.tagSuggestions(spaceID),
 		},
 	}))
 }
@@ -2043,6 +3281,8 @@ func (h *spaceHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Reque
 	rateInput := strings.TrimSpace(r.FormValue("rate"))
 	dateInput := strings.TrimSpace(r.FormValue("date"))
 	descriptionInput := strings.TrimSpace(r.FormValue("description"))
+	tagNames, tagsErr := formTags(r)
+	cur := ctxkeys.Currencies(r.Context()).Get(source.Currency)
 
 	formProps := forms.CreateTransferProps{
 		SpaceID:         spaceID,
@@ -2055,17 +3295,23 @@ func (h *spaceHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Reque
 		ConversionRate:  rateInput,
 		Date:            dateInput,
 		Description:     descriptionInput,
+		Tags:            tagNames,
+		TagSuggestions:  h.tagSuggestions(spaceID),
 	}
 
 	if allocSummary, err := h.allocationService.SummaryForAccount(accountID); err != nil {
 		slog.Error("failed to load allocation summary", "error", err, "account_id", accountID)
 	} else {
-		formProps.SourceAvailable = allocSummary.Available.StringFixedBank(2)
-		formProps.SourceAllocated = allocSummary.Allocated.StringFixedBank(2)
+		formProps.SourceAvailable = cur.Fixed(allocSummary.Available)
+		formProps.SourceAllocated = cur.Fixed(allocSummary.Allocated)
 		formProps.SourceOverflow = allocSummary.Overflow
 	}
 
 	hasErr := false
+	if tagsErr != "" {
+		formProps.TagsErr = tagsErr
+		hasErr = true
+	}
 	if titleInput == "" {
 		formProps.TitleErr = "Title is required."
 		hasErr = true
@@ -2083,8 +3329,8 @@ func (h *spaceHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Reque
 		} else if !amt.IsPositive() {
 			formProps.AmountErr = "Amount must be greater than zero."
 			hasErr = true
-		} else if amt.Exponent() < -2 {
-			formProps.AmountErr = "Amount can have at most 2 decimal places."
+		} else if !cur.Fits(amt) {
+			formProps.AmountErr = decimalsErr("Amount", cur)
 			hasErr = true
 		} else {
 			amount = amt
@@ -2147,6 +3393,14 @@ func (h *spaceHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	tagIDs, err := h.resolveTagIDs(spaceID, tagNames)
+	if err != nil {
+		slog.Error("failed to resolve tags", "error", err, "space_id", spaceID)
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, forms.CreateTransfer(formProps))
+		return
+	}
+
 	actorID := ""
 	if u := ctxkeys.User(r.Context()); u != nil {
 		actorID = u.ID
@@ -2160,6 +3414,7 @@ func (h *spaceHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Reque
 		ConversionRate:  rate,
 		OccurredAt:      occurredAt,
 		Description:     descriptionInput,
+		TagIDs:          tagIDs,
 		ActorID:         actorID,
 	}); err != nil {
 		if errors.Is(err, service.ErrTransferExceedsAvailable) {
@@ -2167,6 +3422,11 @@ func (h *spaceHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Reque
 			ui.Render(w, r, forms.CreateTransfer(formProps))
 			return
 		}
+		if errors.Is(err, service.ErrAccountArchived) {
+			formProps.GeneralErr = "One of these accounts is archived. Unarchive it from its settings to transfer money."
+			ui.Render(w, r, forms.CreateTransfer(formProps))
+			return
+		}
 		slog.Error("failed to create transfer", "error", err, "source", accountID, "dest", destInput)
 		formProps.GeneralErr = "Something went wrong. Please try again."
 		ui.Render(w, r, forms.CreateTransfer(formProps))
@@ -2182,9 +3442,131 @@ func (h *spaceHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusOK)
 }
 
-// transferDestinations returns every account in the space except the source.
+// formSplits returns the category splits posted by an edit form's split
+// editor as both the rows to re-render and the parsed splits. Rows left fully
+// blank are dropped. The message describes the first unusable row, or a total
+// that doesn't match amount when amount is known. Split amounts are checked
+// against cur's decimals.
+func formSplits(r *http.Request, amount decimal.Decimal, cur currency.Currency) ([]forms.SplitRow, []model.CategorySplit, string) {
+	categoryIDs := r.PostForm["split_category"]
+	amounts := r.PostForm["split_amount"]
+	var rows []forms.SplitRow
+	var splits []model.CategorySplit
+	errMsg := ""
+	sum := decimal.Zero
+	for i, categoryID := range categoryIDs {
+		categoryID = strings.TrimSpace(categoryID)
+		amountInput := ""
+		if i < len(amounts) {
+			amountInput = strings.TrimSpace(amounts[i])
+		}
+		if categoryID == "" && amountInput == "" {
+			continue
+		}
+		rows = append(rows, forms.SplitRow{CategoryID: categoryID, Amount: amountInput})
+		if errMsg != "" {
+			continue
+		}
+		amt, err := decimal.NewFromString(amountInput)
+		switch {
+		case categoryID == "":
+			errMsg = "Choose a category for every split."
+		case err != nil:
+			errMsg = "Enter a valid amount for every split (e.g. 12.34)."
+		case !amt.IsPositive():
+			errMsg = "Split amounts must be greater than zero."
+		case !cur.Fits(amt):
+			errMsg = decimalsErr("Split amounts", cur)
+		default:
+			for _, split := range splits {
+				if split.CategoryID == categoryID {
+					errMsg = "Each split needs a different category."
+				}
+			}
+			splits = append(splits, model.CategorySplit{CategoryID: categoryID, Amount: amt})
+			sum = sum.Add(amt)
+		}
+	}
+	if errMsg == "" && len(splits) > 0 && amount.IsPositive() && !sum.Equal(amount) {
+		errMsg = "Splits add up to " + cur.Fixed(sum) + " but the amount is " + cur.Fixed(amount) + "."
+	}
+	return rows, splits, errMsg
+}
+
+// accountCurrency returns the currency of the account amounts are posted to.
+// An account that can't be loaded gets two decimals; the service rejects the
+// write anyway.
+func (h *spaceHandler) accountCurrency(r *http.Request, accountID string) currency.Currency {
+	account, err := h.accountService.GetAccount(accountID)
+	if err != nil {
+		return currency.Currency{MinorUnits: 2}
+	}
+	return ctxkeys.Currencies(r.Context()).Get(account.Currency)
+}
+
+// decimalsErr is the message for an amount with more decimals than cur has,
+// e.g. "Amount can have at most 2 decimal places."
+func decimalsErr(subject string, cur currency.Currency) string {
+	switch cur.MinorUnits {
+	case 0:
+		return subject + " must be a whole number of " + cur.Code + "."
+	case 1:
+		return subject + " can have at most 1 decimal place."
+	default:
+		return subject + " can have at most " + strconv.Itoa(int(cur.MinorUnits)) + " decimal places."
+	}
+}
+
+// formTags returns the tag names posted by a transaction form's tags field,
+// along with the message to show under the field when a name is unusable.
+func formTags(r *http.Request) ([]string, string) {
+	var names []string
+	errMsg := ""
+	for _, raw := range r.PostForm["tags"] {
+		name := strings.Join(strings.Fields(raw), " ")
+		if name == "" {
+			continue
+		}
+		if len(name) > 40 {
+			errMsg = "Tags must be at most 40 characters."
+		}
+		names = append(names, name)
+	}
+	return names, errMsg
+}
+
+// tagSuggestions returns the space's tag names for a tags field. Suggestions
+// are a convenience, so a failure only logs.
+func (h *spaceHandler) tagSuggestions(spaceID string) []string {
+	tags, err := h.tagService.List(spaceID)
+	if err != nil {
+		slog.Error("failed to load tags", "error", err, "space_id", spaceID)
+		return nil
+	}
+	return forms.TagNames(tags)
+}
+
+// resolveTagIDs maps posted tag names to tag IDs, creating tags the space does
+// not have yet.
+func (h *spaceHandler) resolveTagIDs(spaceID string, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	tags, err := h.tagService.EnsureByNames(spaceID, names)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(tags))
+	for i, t := range tags {
+		ids[i] = t.ID
+	}
+	return ids, nil
+}
+
+// transferDestinations returns every open account in the space except the
+// source.
 func (h *spaceHandler) transferDestinations(spaceID, sourceAccountID string) ([]*model.Account, error) {
-	all, err := h.accountService.GetAccountsForSpace(spaceID)
+	all, err := h.accountService.ActiveAccountsForSpace(spaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -2207,6 +3589,7 @@ func (h *spaceHandler) HandleCreateBill(w http.ResponseWriter, r *http.Request)
 	dateInput := strings.TrimSpace(r.FormValue("date"))
 	descriptionInput := strings.TrimSpace(r.FormValue("description"))
 	categoryInput := strings.TrimSpace(r.FormValue("category"))
+	tagNames, tagsErr := formTags(r)
 
 	categories, err := h.categoryService.ListByAccount(accountID)
 	if err != nil {
@@ -2216,22 +3599,29 @@ func (h *spaceHandler) HandleCreateBill(w http.ResponseWriter, r *http.Request)
 	}
 
 	formProps := forms.CreateBillProps{
-		SpaceID:     spaceID,
-		AccountID:   accountID,
-		Categories:  categories,
-		Title:       titleInput,
-		Amount:      amountInput,
-		Date:        dateInput,
-		Description: descriptionInput,
-		CategoryID:  categoryInput,
+		SpaceID:        spaceID,
+		AccountID:      accountID,
+		Categories:     categories,
+		Title:          titleInput,
+		Amount:         amountInput,
+		Date:           dateInput,
+		Description:    descriptionInput,
+		CategoryID:     categoryInput,
+		Tags:           tagNames,
+		TagSuggestions: h.tagSuggestions(spaceID),
 	}
 
 	hasErr := false
+	if tagsErr != "" {
+		formProps.TagsErr = tagsErr
+		hasErr = true
+	}
 	if titleInput == "" {
 		formProps.TitleErr = "Title is required."
 		hasErr = true
 	}
 
+	cur := h.accountCurrency(r, accountID)
 	var amount decimal.Decimal
 	if amountInput == "" {
 		formProps.AmountErr = "Amount is required."
@@ -2244,8 +3634,8 @@ func (h *spaceHandler) HandleCreateBill(w http.ResponseWriter, r *http.Request)
 		} else if !amt.IsPositive() {
 			formProps.AmountErr = "Amount must be greater than zero."
 			hasErr = true
-		} else if amt.Exponent() < -2 {
-			formProps.AmountErr = "Amount can have at most 2 decimal places."
+		} else if !cur.Fits(amt) {
+			formProps.AmountErr = decimalsErr("Amount", cur)
 			hasErr = true
 		} else {
 			amount = amt
@@ -2271,6 +3661,14 @@ func (h *spaceHandler) HandleCreateBill(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	tagIDs, err := h.resolveTagIDs(spaceID, tagNames)
+	if err != nil {
+		slog.Error("failed to resolve tags", "error", err, "space_id", spaceID)
+		formProps.GeneralErr = "Something went wrong. Please try again."
+		ui.Render(w, r, forms.CreateBill(formProps))
+		return
+	}
+
 	actorID := ""
 	if u := ctxkeys.User(r.Context()); u != nil {
 		actorID = u.ID
@@ -2282,9 +3680,15 @@ func (h *spaceHandler) HandleCreateBill(w http.ResponseWriter, r *http.Request)
 		OccurredAt:  occurredAt,
 		Description: descriptionInput,
 		CategoryID:  categoryInput,
+		TagIDs:      tagIDs,
 		ActorID:     actorID,
 	})
 	if err != nil {
+		if errors.Is(err, service.ErrAccountArchived) {
+			formProps.GeneralErr = "This account is archived. Unarchive it from its settings to add transactions."
+			ui.Render(w, r, forms.CreateBill(formProps))
+			return
+		}
 		slog.Error("failed to create bill", "error", err, "account_id", accountID)
 		formProps.GeneralErr = "Something went wrong. Please try again."
 		ui.Render(w, r, forms.CreateBill(formProps))
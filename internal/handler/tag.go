@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+	"git.juancwu.dev/juancwu/budgit/internal/ui"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/forms"
+	"git.juancwu.dev/juancwu/budgit/internal/ui/pages"
+)
+
+type tagHandler struct {
+	tagService   *service.TagService
+	spaceService *service.SpaceService
+}
+
+func NewTagHandler(tagService *service.TagService, spaceService *service.SpaceService) *tagHandler {
+	return &tagHandler{tagService: tagService, spaceService: spaceService}
+}
+
+// ListPage lists the space's tags with how many transactions carry each.
+func (h *tagHandler) ListPage(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	space, err := h.spaceService.GetSpace(spaceID)
+	if err != nil {
+		ui.Render(w, r, pages.NotFound())
+		return
+	}
+	tags, err := h.tagService.List(spaceID)
+	if err != nil {
+		slog.Error("failed to list tags", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load tags", http.StatusInternalServerError)
+		return
+	}
+	counts, err := h.tagService.UsageCounts(spaceID)
+	if err != nil {
+		slog.Error("failed to count tag usage", "error", err, "space_id", spaceID)
+		ui.RenderError(w, r, "Failed to load tags", http.StatusInternalServerError)
+		return
+	}
+	ui.Render(w, r, pages.SpaceTagsPage(pages.SpaceTagsPageProps{
+		SpaceID:     spaceID,
+		SpaceName:   space.Name,
+		Tags:        tags,
+		UsageCounts: counts,
+		CreateForm:  forms.CreateTagProps{SpaceID: spaceID},
+	}))
+}
+
+func (h *tagHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	name := strings.TrimSpace(r.FormValue("name"))
+	formProps := forms.CreateTagProps{SpaceID: spaceID, Name: name}
+
+	if _, err := h.tagService.Create(spaceID, name); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTagNameTaken):
+			formProps.NameErr = "A tag with this name already exists."
+		case name == "":
+			formProps.NameErr = "Name is required."
+		case len(name) > 40:
+			formProps.NameErr = "Name must be at most 40 characters."
+		default:
+			slog.Error("failed to create tag", "error", err, "space_id", spaceID)
+			formProps.GeneralErr = "Something went wrong. Please try again."
+		}
+		ui.Render(w, r, forms.CreateTag(formProps))
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *tagHandler) HandleRename(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	tagID := r.PathValue("tagID")
+	name := strings.TrimSpace(r.FormValue("name"))
+
+	if _, err := h.tagService.Rename(spaceID, tagID, name); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTagNotFound):
+			ui.RenderError(w, r, "Tag not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrTagNameTaken):
+			ui.RenderError(w, r, "A tag with this name already exists.", http.StatusUnprocessableEntity)
+		case name == "":
+			ui.RenderError(w, r, "Name is required.", http.StatusUnprocessableEntity)
+		case len(name) > 40:
+			ui.RenderError(w, r, "Name must be at most 40 characters.", http.StatusUnprocessableEntity)
+		default:
+			slog.Error("failed to rename tag", "error", err, "tag_id", tagID)
+			ui.RenderError(w, r, "Failed to rename tag", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *tagHandler) HandleMerge(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	tagID := r.PathValue("tagID")
+	targetID := strings.TrimSpace(r.FormValue("target"))
+
+	if err := h.tagService.Merge(spaceID, tagID, targetID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTagNotFound):
+			ui.RenderError(w, r, "Tag not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrTagMergeIntoSelf):
+			ui.RenderError(w, r, "Pick a different tag to merge into.", http.StatusUnprocessableEntity)
+		default:
+			slog.Error("failed to merge tags", "error", err, "source_id", tagID, "target_id", targetID)
+			ui.RenderError(w, r, "Failed to merge tags", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *tagHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	spaceID := r.PathValue("spaceID")
+	tagID := r.PathValue("tagID")
+
+	if err := h.tagService.Delete(spaceID, tagID); err != nil {
+		if errors.Is(err, service.ErrTagNotFound) {
+			ui.RenderError(w, r, "Tag not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete tag", "error", err, "tag_id", tagID)
+		ui.RenderError(w, r, "Failed to delete tag", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/service"
+)
+
+// WithLocale picks the number conventions for amounts from the request's
+// Accept-Language header.
+func WithLocale(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc := currency.ParseLocale(r.Header.Get("Accept-Language"))
+		ctx := ctxkeys.WithLocale(r.Context(), loc)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// WithSpaceCurrencies adds the space's currency registry, its own currencies
+// included, to the request context. It must run after RequireSpaceAccess. If
+// the registry can't be loaded the request goes on with the ISO list.
+func WithSpaceCurrencies(currencyService *service.CurrencyService) func(http.Handler) http.HandlerFunc {
+	return func(next http.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			spaceID := r.PathValue("spaceID")
+			registry, err := currencyService.Registry(spaceID)
+			if err != nil {
+				slog.Error("failed to load space currencies", "error", err, "space_id", spaceID)
+			}
+			ctx := ctxkeys.WithCurrencies(r.Context(), registry)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+	}
+}
@@ -1,15 +1,39 @@
-// Package currency provides ISO 4217 currency code validation and a small
-// curated list of supported codes for UI selection.
+// Package currency describes the currencies budgit can hold money in: the
+// ISO 4217 list, with each currency's minor units, symbol and name, plus any
+// currencies a space defines for itself. It also rounds and formats amounts
+// by those rules.
 package currency
 
-import "strings"
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
 
 const Default = "CAD"
 
-// supported is the curated list of ISO 4217 codes shown in the account creation
-// UI. Add codes here as users request them. Validation accepts any 3-letter
-// uppercase code in this list.
-var supported = []string{
+// MaxMinorUnits bounds the decimals of a user-defined currency. 18 covers
+// ether's wei.
+const MaxMinorUnits = 18
+
+var (
+	// ErrInvalidCode is returned for a user-defined code that isn't 2 to 10
+	// letters and digits.
+	ErrInvalidCode = errors.New("currency code must be 2 to 10 letters or digits")
+	// ErrISOCode is returned when a user-defined currency reuses an ISO 4217
+	// code.
+	ErrISOCode = errors.New("currency code is already an ISO 4217 currency")
+	// ErrInvalidMinorUnits is returned when decimals are out of range.
+	ErrInvalidMinorUnits = errors.New("currency decimals must be between 0 and 18")
+)
+
+var customCode = regexp.MustCompile(`^[A-Z0-9]{2,10}$`)
+
+// common is the short list shown first in currency pickers.
+var common = []string{
 	"CAD",
 	"USD",
 	"EUR",
@@ -28,9 +52,92 @@ var supported = []string{
 	"TWD",
 }
 
+var isoByCode = func() map[string]Currency {
+	m := make(map[string]Currency, len(iso))
+	for _, c := range iso {
+		m[c.Code] = c
+	}
+	return m
+}()
+
+// Currency is one unit of account and the rules for writing amounts in it.
+type Currency struct {
+	Code   string
+	Name   string
+	Symbol string
+	// MinorUnits is the number of decimals amounts are kept to: 2 for
+	// dollars, 0 for yen, 3 for dinars, 8 for bitcoin.
+	MinorUnits int32
+	// Custom is set on currencies a space defined itself.
+	Custom bool
+}
+
+// Round rounds amount to the currency's minor units, half away from zero.
+func (c Currency) Round(amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(c.MinorUnits)
+}
+
+// Fixed writes amount with exactly the currency's minor units and no
+// grouping, as stored in audit metadata and form fields.
+func (c Currency) Fixed(amount decimal.Decimal) string {
+	return amount.StringFixedBank(c.MinorUnits)
+}
+
+// Fits reports whether amount has no more decimals than the currency allows.
+func (c Currency) Fits(amount decimal.Decimal) bool {
+	return amount.Equal(amount.Round(c.MinorUnits))
+}
+
+// Format writes amount with the currency's symbol in the default locale,
+// e.g. "US$1,234.50" or "¥1,235".
+func (c Currency) Format(amount decimal.Decimal) string {
+	return c.FormatIn(amount, DefaultLocale)
+}
+
+// FormatIn writes amount with the currency's symbol by the locale's
+// conventions.
+func (c Currency) FormatIn(amount decimal.Decimal, loc Locale) string {
+	number := loc.Number(c.Fixed(amount.Abs()))
+	symbol := c.Symbol
+	if symbol == "" {
+		symbol = c.Code
+	}
+	sign := ""
+	if amount.Round(c.MinorUnits).IsNegative() {
+		sign = "-"
+	}
+	if loc.SymbolAfter {
+		return sign + number + "\u00a0" + symbol
+	}
+	if last := symbol[len(symbol)-1]; last >= 'A' && last <= 'Z' {
+		return sign + symbol + "\u00a0" + number
+	}
+	return sign + symbol + number
+}
+
+// Label is the code followed by the name, for pickers.
+func (c Currency) Label() string {
+	if c.Name == "" || c.Name == c.Code {
+		return c.Code
+	}
+	return c.Code + " · " + c.Name
+}
+
+// Supported returns every ISO 4217 code budgit knows, sorted.
 func Supported() []string {
-	out := make([]string, len(supported))
-	copy(out, supported)
+	out := make([]string, len(iso))
+	for i, c := range iso {
+		out[i] = c.Code
+	}
+	return out
+}
+
+// Common returns the short list of currencies shown first in pickers.
+func Common() []Currency {
+	out := make([]Currency, len(common))
+	for i, code := range common {
+		out[i] = isoByCode[code]
+	}
 	return out
 }
 
@@ -39,13 +146,112 @@ func Normalize(code string) string {
 	return strings.ToUpper(strings.TrimSpace(code))
 }
 
-// IsValid reports whether code is a supported ISO 4217 code (case-insensitive).
+// IsValid reports whether code is an ISO 4217 code (case-insensitive). Use a
+// Registry to also accept a space's own currencies.
 func IsValid(code string) bool {
-	c := Normalize(code)
-	for _, s := range supported {
-		if s == c {
-			return true
+	_, ok := isoByCode[Normalize(code)]
+	return ok
+}
+
+// Lookup returns the ISO 4217 currency with the given code.
+func Lookup(code string) (Currency, bool) {
+	c, ok := isoByCode[Normalize(code)]
+	return c, ok
+}
+
+// Get returns the ISO 4217 currency with the given code, or a two-decimal
+// stand-in named after the code when there is none.
+func Get(code string) Currency {
+	return (*Registry)(nil).Get(code)
+}
+
+// NewCustom checks and normalizes a user-defined currency. The symbol
+// defaults to the code and the name to the symbol.
+func NewCustom(code, name, symbol string, minorUnits int32) (Currency, error) {
+	code = Normalize(code)
+	if !customCode.MatchString(code) {
+		return Currency{}, ErrInvalidCode
+	}
+	if IsValid(code) {
+		return Currency{}, ErrISOCode
+	}
+	if minorUnits < 0 || minorUnits > MaxMinorUnits {
+		return Currency{}, ErrInvalidMinorUnits
+	}
+	symbol = strings.TrimSpace(symbol)
+	if symbol == "" {
+		symbol = code
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = code
+	}
+	return Currency{Code: code, Name: name, Symbol: symbol, MinorUnits: minorUnits, Custom: true}, nil
+}
+
+// Registry is the set of currencies one space can use: the ISO list plus the
+// space's own. A nil Registry holds the ISO list only.
+type Registry struct {
+	custom map[string]Currency
+}
+
+// NewRegistry returns a registry with the ISO list and the given
+// user-defined currencies.
+func NewRegistry(custom ...Currency) *Registry {
+	r := &Registry{custom: make(map[string]Currency, len(custom))}
+	for _, c := range custom {
+		c.Custom = true
+		r.custom[c.Code] = c
+	}
+	return r
+}
+
+// Lookup returns the currency with the given code.
+func (r *Registry) Lookup(code string) (Currency, bool) {
+	code = Normalize(code)
+	if c, ok := isoByCode[code]; ok {
+		return c, true
+	}
+	if r != nil {
+		if c, ok := r.custom[code]; ok {
+			return c, true
 		}
 	}
-	return false
+	return Currency{}, false
+}
+
+// Get is Lookup with a two-decimal stand-in for unknown codes, so an amount
+// in a currency that has since been removed still renders.
+func (r *Registry) Get(code string) Currency {
+	if c, ok := r.Lookup(code); ok {
+		return c
+	}
+	code = Normalize(code)
+	return Currency{Code: code, Name: code, Symbol: code, MinorUnits: 2}
+}
+
+// IsValid reports whether code is an ISO code or one of the space's own.
+func (r *Registry) IsValid(code string) bool {
+	_, ok := r.Lookup(code)
+	return ok
+}
+
+// Custom returns the space's own currencies, sorted by code.
+func (r *Registry) Custom() []Currency {
+	if r == nil {
+		return nil
+	}
+	out := make([]Currency, 0, len(r.custom))
+	for _, c := range r.custom {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+// All returns every currency in the registry: the space's own first, then
+// the ISO list.
+func (r *Registry) All() []Currency {
+	out := r.Custom()
+	return append(out, iso...)
 }
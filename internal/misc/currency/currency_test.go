@@ -0,0 +1,58 @@
+package currency
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinorUnits(t *testing.T) {
+	for code, units := range map[string]int32{"CAD": 2, "JPY": 0, "KRW": 0, "BHD": 3, "KWD": 3, "CLF": 4} {
+		c, ok := Lookup(code)
+		require.True(t, ok, code)
+		assert.Equal(t, units, c.MinorUnits, code)
+	}
+	assert.False(t, IsValid("BTC"))
+}
+
+func TestFormat(t *testing.T) {
+	amount := decimal.RequireFromString("-1234567.555")
+	assert.Equal(t, "-$1,234,567.56", Get("CAD").Format(amount))
+	assert.Equal(t, "-¥1,234,568", Get("JPY").Format(amount))
+	assert.Equal(t, "-KWD\u00a01,234,567.555", Get("KWD").Format(amount))
+	assert.Equal(t, "-1\u202f234\u202f567,56\u00a0€", Get("EUR").FormatIn(amount, ParseLocale("fr-CA,fr;q=0.9,en;q=0.8")))
+	assert.Equal(t, "$0.00", Get("CAD").Format(decimal.RequireFromString("-0.001")))
+}
+
+func TestParseLocale(t *testing.T) {
+	assert.Equal(t, "de-CH", ParseLocale("de-CH").Tag)
+	assert.Equal(t, "de", ParseLocale("de-AT,en").Tag)
+	assert.Equal(t, "en", ParseLocale("xx-YY, *").Tag)
+	assert.Equal(t, "en", ParseLocale("").Tag)
+}
+
+func TestRegistry(t *testing.T) {
+	btc, err := NewCustom(" btc ", "Bitcoin", "₿", 8)
+	require.NoError(t, err)
+	r := NewRegistry(btc)
+
+	c, ok := r.Lookup("BTC")
+	require.True(t, ok)
+	assert.True(t, c.Custom)
+	assert.Equal(t, "₿0.00000001", c.Format(decimal.RequireFromString("0.00000001")))
+	assert.True(t, r.IsValid("usd"))
+	assert.False(t, (*Registry)(nil).IsValid("BTC"))
+	assert.Equal(t, "BTC", r.All()[0].Code)
+
+	_, err = NewCustom("USD", "", "", 2)
+	assert.ErrorIs(t, err, ErrISOCode)
+	_, err = NewCustom("B", "", "", 2)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	_, err = NewCustom("BTC", "", "", 19)
+	assert.ErrorIs(t, err, ErrInvalidMinorUnits)
+
+	// Unknown codes still render, with two decimals.
+	assert.Equal(t, "DOGE\u00a01.50", r.Get("DOGE").Format(decimal.RequireFromString("1.5")))
+}
@@ -0,0 +1,174 @@
+package currency
+
+// iso is the ISO 4217 list of active currencies and funds, with the number of
+// minor units each one has. Precious metals, testing codes and the SDR carry
+// no minor units and are left out. Symbols follow Canadian English: the
+// dollar sign alone means CAD and other dollars are prefixed. Currencies
+// without a widely used symbol show their code.
+var iso = []Currency{
+	{Code: "AED", Name: "UAE dirham", Symbol: "AED", MinorUnits: 2},
+	{Code: "AFN", Name: "Afghan afghani", Symbol: "AFN", MinorUnits: 2},
+	{Code: "ALL", Name: "Albanian lek", Symbol: "ALL", MinorUnits: 2},
+	{Code: "AMD", Name: "Armenian dram", Symbol: "AMD", MinorUnits: 2},
+	{Code: "AOA", Name: "Angolan kwanza", Symbol: "AOA", MinorUnits: 2},
+	{Code: "ARS", Name: "Argentine peso", Symbol: "ARS", MinorUnits: 2},
+	{Code: "AUD", Name: "Australian dollar", Symbol: "A$", MinorUnits: 2},
+	{Code: "AWG", Name: "Aruban florin", Symbol: "AWG", MinorUnits: 2},
+	{Code: "AZN", Name: "Azerbaijani manat", Symbol: "AZN", MinorUnits: 2},
+	{Code: "BAM", Name: "Bosnia-Herzegovina convertible mark", Symbol: "BAM", MinorUnits: 2},
+	{Code: "BBD", Name: "Barbadian dollar", Symbol: "BBD", MinorUnits: 2},
+	{Code: "BDT", Name: "Bangladeshi taka", Symbol: "BDT", MinorUnits: 2},
+	{Code: "BGN", Name: "Bulgarian lev", Symbol: "BGN", MinorUnits: 2},
+	{Code: "BHD", Name: "Bahraini dinar", Symbol: "BHD", MinorUnits: 3},
+	{Code: "BIF", Name: "Burundian franc", Symbol: "BIF", MinorUnits: 0},
+	{Code: "BMD", Name: "Bermudan dollar", Symbol: "BMD", MinorUnits: 2},
+	{Code: "BND", Name: "Brunei dollar", Symbol: "BND", MinorUnits: 2},
+	{Code: "BOB", Name: "Bolivian boliviano", Symbol: "BOB", MinorUnits: 2},
+	{Code: "BOV", Name: "Bolivian mvdol", Symbol: "BOV", MinorUnits: 2},
+	{Code: "BRL", Name: "Brazilian real", Symbol: "R$", MinorUnits: 2},
+	{Code: "BSD", Name: "Bahamian dollar", Symbol: "BSD", MinorUnits: 2},
+	{Code: "BTN", Name: "Bhutanese ngultrum", Symbol: "BTN", MinorUnits: 2},
+	{Code: "BWP", Name: "Botswanan pula", Symbol: "BWP", MinorUnits: 2},
+	{Code: "BYN", Name: "Belarusian ruble", Symbol: "BYN", MinorUnits: 2},
+	{Code: "BZD", Name: "Belize dollar", Symbol: "BZD", MinorUnits: 2},
+	{Code: "CAD", Name: "Canadian dollar", Symbol: "$", MinorUnits: 2},
+	{Code: "CDF", Name: "Congolese franc", Symbol: "CDF", MinorUnits: 2},
+	{Code: "CHE", Name: "WIR euro", Symbol: "CHE", MinorUnits: 2},
+	{Code: "CHF", Name: "Swiss franc", Symbol: "CHF", MinorUnits: 2},
+	{Code: "CHW", Name: "WIR franc", Symbol: "CHW", MinorUnits: 2},
+	{Code: "CLF", Name: "Chilean unit of account (UF)", Symbol: "CLF", MinorUnits: 4},
+	{Code: "CLP", Name: "Chilean peso", Symbol: "CLP", MinorUnits: 0},
+	{Code: "CNY", Name: "Chinese yuan", Symbol: "CN¥", MinorUnits: 2},
+	{Code: "COP", Name: "Colombian peso", Symbol: "COP", MinorUnits: 2},
+	{Code: "COU", Name: "Colombian real value unit", Symbol: "COU", MinorUnits: 2},
+	{Code: "CRC", Name: "Costa Rican colón", Symbol: "CRC", MinorUnits: 2},
+	{Code: "CUP", Name: "Cuban peso", Symbol: "CUP", MinorUnits: 2},
+	{Code: "CVE", Name: "Cape Verdean escudo", Symbol: "CVE", MinorUnits: 2},
+	{Code: "CZK", Name: "Czech koruna", Symbol: "CZK", MinorUnits: 2},
+	{Code: "DJF", Name: "Djiboutian franc", Symbol: "DJF", MinorUnits: 0},
+	{Code: "DKK", Name: "Danish krone", Symbol: "DKK", MinorUnits: 2},
+	{Code: "DOP", Name: "Dominican peso", Symbol: "DOP", MinorUnits: 2},
+	{Code: "DZD", Name: "Algerian dinar", Symbol: "DZD", MinorUnits: 2},
+	{Code: "EGP", Name: "Egyptian pound", Symbol: "EGP", MinorUnits: 2},
+	{Code: "ERN", Name: "Eritrean nakfa", Symbol: "ERN", MinorUnits: 2},
+	{Code: "ETB", Name: "Ethiopian birr", Symbol: "ETB", MinorUnits: 2},
+	{Code: "EUR", Name: "Euro", Symbol: "€", MinorUnits: 2},
+	{Code: "FJD", Name: "Fijian dollar", Symbol: "FJD", MinorUnits: 2},
+	{Code: "FKP", Name: "Falkland Islands pound", Symbol: "FKP", MinorUnits: 2},
+	{Code: "GBP", Name: "British pound", Symbol: "£", MinorUnits: 2},
+	{Code: "GEL", Name: "Georgian lari", Symbol: "GEL", MinorUnits: 2},
+	{Code: "GHS", Name: "Ghanaian cedi", Symbol: "GHS", MinorUnits: 2},
+	{Code: "GIP", Name: "Gibraltar pound", Symbol: "GIP", MinorUnits: 2},
+	{Code: "GMD", Name: "Gambian dalasi", Symbol: "GMD", MinorUnits: 2},
+	{Code: "GNF", Name: "Guinean franc", Symbol: "GNF", MinorUnits: 0},
+	{Code: "GTQ", Name: "Guatemalan quetzal", Symbol: "GTQ", MinorUnits: 2},
+	{Code: "GYD", Name: "Guyanaese dollar", Symbol: "GYD", MinorUnits: 2},
+	{Code: "HKD", Name: "Hong Kong dollar", Symbol: "HK$", MinorUnits: 2},
+	{Code: "HNL", Name: "Honduran lempira", Symbol: "HNL", MinorUnits: 2},
+	{Code: "HTG", Name: "Haitian gourde", Symbol: "HTG", MinorUnits: 2},
+	{Code: "HUF", Name: "Hungarian forint", Symbol: "HUF", MinorUnits: 2},
+	{Code: "IDR", Name: "Indonesian rupiah", Symbol: "IDR", MinorUnits: 2},
+	{Code: "ILS", Name: "Israeli new shekel", Symbol: "₪", MinorUnits: 2},
+	{Code: "INR", Name: "Indian rupee", Symbol: "₹", MinorUnits: 2},
+	{Code: "IQD", Name: "Iraqi dinar", Symbol: "IQD", MinorUnits: 3},
+	{Code: "IRR", Name: "Iranian rial", Symbol: "IRR", MinorUnits: 2},
+	{Code: "ISK", Name: "Icelandic króna", Symbol: "ISK", MinorUnits: 0},
+	{Code: "JMD", Name: "Jamaican dollar", Symbol: "JMD", MinorUnits: 2},
+	{Code: "JOD", Name: "Jordanian dinar", Symbol: "JOD", MinorUnits: 3},
+	{Code: "JPY", Name: "Japanese yen", Symbol: "¥", MinorUnits: 0},
+	{Code: "KES", Name: "Kenyan shilling", Symbol: "KES", MinorUnits: 2},
+	{Code: "KGS", Name: "Kyrgystani som", Symbol: "KGS", MinorUnits: 2},
+	{Code: "KHR", Name: "Cambodian riel", Symbol: "KHR", MinorUnits: 2},
+	{Code: "KMF", Name: "Comorian franc", Symbol: "KMF", MinorUnits: 0},
+	{Code: "KPW", Name: "North Korean won", Symbol: "KPW", MinorUnits: 2},
+	{Code: "KRW", Name: "South Korean won", Symbol: "₩", MinorUnits: 0},
+	{Code: "KWD", Name: "Kuwaiti dinar", Symbol: "KWD", MinorUnits: 3},
+	{Code: "KYD", Name: "Cayman Islands dollar", Symbol: "KYD", MinorUnits: 2},
+	{Code: "KZT", Name: "Kazakhstani tenge", Symbol: "KZT", MinorUnits: 2},
+	{Code: "LAK", Name: "Laotian kip", Symbol: "LAK", MinorUnits: 2},
+	{Code: "LBP", Name: "Lebanese pound", Symbol: "LBP", MinorUnits: 2},
+	{Code: "LKR", Name: "Sri Lankan rupee", Symbol: "LKR", MinorUnits: 2},
+	{Code: "LRD", Name: "Liberian dollar", Symbol: "LRD", MinorUnits: 2},
+	{Code: "LSL", Name: "Lesotho loti", Symbol: "LSL", MinorUnits: 2},
+	{Code: "LYD", Name: "Libyan dinar", Symbol: "LYD", MinorUnits: 3},
+	{Code: "MAD", Name: "Moroccan dirham", Symbol: "MAD", MinorUnits: 2},
+	{Code: "MDL", Name: "Moldovan leu", Symbol: "MDL", MinorUnits: 2},
+	{Code: "MGA", Name: "Malagasy ariary", Symbol: "MGA", MinorUnits: 2},
+	{Code: "MKD", Name: "Macedonian denar", Symbol: "MKD", MinorUnits: 2},
+	{Code: "MMK", Name: "Myanmar kyat", Symbol: "MMK", MinorUnits: 2},
+	{Code: "MNT", Name: "Mongolian tugrik", Symbol: "MNT", MinorUnits: 2},
+	{Code: "MOP", Name: "Macanese pataca", Symbol: "MOP", MinorUnits: 2},
+	{Code: "MRU", Name: "Mauritanian ouguiya", Symbol: "MRU", MinorUnits: 2},
+	{Code: "MUR", Name: "Mauritian rupee", Symbol: "MUR", MinorUnits: 2},
+	{Code: "MVR", Name: "Maldivian rufiyaa", Symbol: "MVR", MinorUnits: 2},
+	{Code: "MWK", Name: "Malawian kwacha", Symbol: "MWK", MinorUnits: 2},
+	{Code: "MXN", Name: "Mexican peso", Symbol: "MX$", MinorUnits: 2},
+	{Code: "MXV", Name: "Mexican investment unit", Symbol: "MXV", MinorUnits: 2},
+	{Code: "MYR", Name: "Malaysian ringgit", Symbol: "MYR", MinorUnits: 2},
+	{Code: "MZN", Name: "Mozambican metical", Symbol: "MZN", MinorUnits: 2},
+	{Code: "NAD", Name: "Namibian dollar", Symbol: "NAD", MinorUnits: 2},
+	{Code: "NGN", Name: "Nigerian naira", Symbol: "NGN", MinorUnits: 2},
+	{Code: "NIO", Name: "Nicaraguan córdoba", Symbol: "NIO", MinorUnits: 2},
+	{Code: "NOK", Name: "Norwegian krone", Symbol: "NOK", MinorUnits: 2},
+	{Code: "NPR", Name: "Nepalese rupee", Symbol: "NPR", MinorUnits: 2},
+	{Code: "NZD", Name: "New Zealand dollar", Symbol: "NZ$", MinorUnits: 2},
+	{Code: "OMR", Name: "Omani rial", Symbol: "OMR", MinorUnits: 3},
+	{Code: "PAB", Name: "Panamanian balboa", Symbol: "PAB", MinorUnits: 2},
+	{Code: "PEN", Name: "Peruvian sol", Symbol: "PEN", MinorUnits: 2},
+	{Code: "PGK", Name: "Papua New Guinean kina", Symbol: "PGK", MinorUnits: 2},
+	{Code: "PHP", Name: "Philippine peso", Symbol: "₱", MinorUnits: 2},
+	{Code: "PKR", Name: "Pakistani rupee", Symbol: "PKR", MinorUnits: 2},
+	{Code: "PLN", Name: "Polish złoty", Symbol: "PLN", MinorUnits: 2},
+	{Code: "PYG", Name: "Paraguayan guarani", Symbol: "PYG", MinorUnits: 0},
+	{Code: "QAR", Name: "Qatari riyal", Symbol: "QAR", MinorUnits: 2},
+	{Code: "RON", Name: "Romanian leu", Symbol: "RON", MinorUnits: 2},
+	{Code: "RSD", Name: "Serbian dinar", Symbol: "RSD", MinorUnits: 2},
+	{Code: "RUB", Name: "Russian ruble", Symbol: "RUB", MinorUnits: 2},
+	{Code: "RWF", Name: "Rwandan franc", Symbol: "RWF", MinorUnits: 0},
+	{Code: "SAR", Name: "Saudi riyal", Symbol: "SAR", MinorUnits: 2},
+	{Code: "SBD", Name: "Solomon Islands dollar", Symbol: "SBD", MinorUnits: 2},
+	{Code: "SCR", Name: "Seychellois rupee", Symbol: "SCR", MinorUnits: 2},
+	{Code: "SDG", Name: "Sudanese pound", Symbol: "SDG", MinorUnits: 2},
+	{Code: "SEK", Name: "Swedish krona", Symbol: "SEK", MinorUnits: 2},
+	{Code: "SGD", Name: "Singapore dollar", Symbol: "SGD", MinorUnits: 2},
+	{Code: "SHP", Name: "St. Helena pound", Symbol: "SHP", MinorUnits: 2},
+	{Code: "SLE", Name: "Sierra Leonean leone", Symbol: "SLE", MinorUnits: 2},
+	{Code: "SOS", Name: "Somali shilling", Symbol: "SOS", MinorUnits: 2},
+	{Code: "SRD", Name: "Surinamese dollar", Symbol: "SRD", MinorUnits: 2},
+	{Code: "SSP", Name: "South Sudanese pound", Symbol: "SSP", MinorUnits: 2},
+	{Code: "STN", Name: "São Tomé and Príncipe dobra", Symbol: "STN", MinorUnits: 2},
+	{Code: "SVC", Name: "Salvadoran colón", Symbol: "SVC", MinorUnits: 2},
+	{Code: "SYP", Name: "Syrian pound", Symbol: "SYP", MinorUnits: 2},
+	{Code: "SZL", Name: "Swazi lilangeni", Symbol: "SZL", MinorUnits: 2},
+	{Code: "THB", Name: "Thai baht", Symbol: "฿", MinorUnits: 2},
+	{Code: "TJS", Name: "Tajikistani somoni", Symbol: "TJS", MinorUnits: 2},
+	{Code: "TMT", Name: "Turkmenistani manat", Symbol: "TMT", MinorUnits: 2},
+	{Code: "TND", Name: "Tunisian dinar", Symbol: "TND", MinorUnits: 3},
+	{Code: "TOP", Name: "Tongan paʻanga", Symbol: "TOP", MinorUnits: 2},
+	{Code: "TRY", Name: "Turkish lira", Symbol: "TRY", MinorUnits: 2},
+	{Code: "TTD", Name: "Trinidad and Tobago dollar", Symbol: "TTD", MinorUnits: 2},
+	{Code: "TWD", Name: "New Taiwan dollar", Symbol: "NT$", MinorUnits: 2},
+	{Code: "TZS", Name: "Tanzanian shilling", Symbol: "TZS", MinorUnits: 2},
+	{Code: "UAH", Name: "Ukrainian hryvnia", Symbol: "UAH", MinorUnits: 2},
+	{Code: "UGX", Name: "Ugandan shilling", Symbol: "UGX", MinorUnits: 0},
+	{Code: "USD", Name: "US dollar", Symbol: "US$", MinorUnits: 2},
+	{Code: "USN", Name: "US dollar (next day)", Symbol: "USN", MinorUnits: 2},
+	{Code: "UYI", Name: "Uruguayan peso (indexed units)", Symbol: "UYI", MinorUnits: 0},
+	{Code: "UYU", Name: "Uruguayan peso", Symbol: "UYU", MinorUnits: 2},
+	{Code: "UYW", Name: "Uruguayan nominal wage index unit", Symbol: "UYW", MinorUnits: 4},
+	{Code: "UZS", Name: "Uzbekistani som", Symbol: "UZS", MinorUnits: 2},
+	{Code: "VED", Name: "Venezuelan bolívar digital", Symbol: "VED", MinorUnits: 2},
+	{Code: "VES", Name: "Venezuelan bolívar", Symbol: "VES", MinorUnits: 2},
+	{Code: "VND", Name: "Vietnamese dong", Symbol: "₫", MinorUnits: 0},
+	{Code: "VUV", Name: "Vanuatu vatu", Symbol: "VUV", MinorUnits: 0},
+	{Code: "WST", Name: "Samoan tala", Symbol: "WST", MinorUnits: 2},
+	{Code: "XAF", Name: "Central African CFA franc", Symbol: "FCFA", MinorUnits: 0},
+	{Code: "XCD", Name: "East Caribbean dollar", Symbol: "EC$", MinorUnits: 2},
+	{Code: "XCG", Name: "Caribbean guilder", Symbol: "XCG", MinorUnits: 2},
+	{Code: "XOF", Name: "West African CFA franc", Symbol: "F CFA", MinorUnits: 0},
+	{Code: "XPF", Name: "CFP franc", Symbol: "CFPF", MinorUnits: 0},
+	{Code: "YER", Name: "Yemeni rial", Symbol: "YER", MinorUnits: 2},
+	{Code: "ZAR", Name: "South African rand", Symbol: "ZAR", MinorUnits: 2},
+	{Code: "ZMW", Name: "Zambian kwacha", Symbol: "ZMW", MinorUnits: 2},
+	{Code: "ZWG", Name: "Zimbabwean gold", Symbol: "ZWG", MinorUnits: 2},
+}
@@ -0,0 +1,78 @@
+package currency
+
+import "strings"
+
+// Locale holds the number conventions amounts are written with.
+type Locale struct {
+	Tag     string
+	Decimal string
+	Group   string
+	// SymbolAfter puts the currency symbol after the number, as in
+	// "1 234,50 $". The space before the symbol never breaks.
+	SymbolAfter bool
+}
+
+// DefaultLocale is English: "$1,234.50".
+var DefaultLocale = Locale{Tag: "en", Decimal: ".", Group: ","}
+
+// locales are keyed by primary language subtag, with regional overrides
+// where a region writes numbers differently from the language default.
+var locales = map[string]Locale{
+	"en":    DefaultLocale,
+	"fr":    {Tag: "fr", Decimal: ",", Group: "\u202f", SymbolAfter: true},
+	"fr-ch": {Tag: "fr-CH", Decimal: ".", Group: "’", SymbolAfter: true},
+	"de":    {Tag: "de", Decimal: ",", Group: ".", SymbolAfter: true},
+	"de-ch": {Tag: "de-CH", Decimal: ".", Group: "’"},
+	"es":    {Tag: "es", Decimal: ",", Group: ".", SymbolAfter: true},
+	"es-mx": {Tag: "es-MX", Decimal: ".", Group: ","},
+	"it":    {Tag: "it", Decimal: ",", Group: ".", SymbolAfter: true},
+	"nl":    {Tag: "nl", Decimal: ",", Group: "."},
+	"pt":    {Tag: "pt", Decimal: ",", Group: "."},
+	"ja":    {Tag: "ja", Decimal: ".", Group: ","},
+	"ko":    {Tag: "ko", Decimal: ".", Group: ","},
+	"zh":    {Tag: "zh", Decimal: ".", Group: ","},
+}
+
+// ParseLocale picks the first locale of an Accept-Language header that
+// budgit has conventions for, or DefaultLocale.
+func ParseLocale(acceptLanguage string) Locale {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if tag == "" {
+			continue
+		}
+		if loc, ok := locales[tag]; ok {
+			return loc
+		}
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			if loc, ok := locales[tag[:i]]; ok {
+				return loc
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+// Number groups the integer part of a plain decimal string ("-1234.5") and
+// swaps in the locale's separators.
+func (l Locale) Number(plain string) string {
+	negative := strings.HasPrefix(plain, "-")
+	plain = strings.TrimPrefix(plain, "-")
+	intPart, frac, hasFrac := strings.Cut(plain, ".")
+
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(c)
+	}
+	if hasFrac {
+		b.WriteString(l.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
@@ -0,0 +1,181 @@
+// Package fxrates reads published exchange-rate files: the European Central
+// Bank's euro foreign exchange reference rates (eurofxref XML, daily or
+// historical) and the Bank of Canada's Valet observations CSV.
+package fxrates
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Format names where a rate file came from.
+type Format string
+
+const (
+	FormatECB          Format = "ecb"
+	FormatBankOfCanada Format = "boc"
+)
+
+// Rate says one unit of Base was worth Rate units of Quote on Date.
+type Rate struct {
+	Date  time.Time
+	Base  string
+	Quote string
+	Rate  decimal.Decimal
+}
+
+// Detect guesses the format of a rate file from its contents.
+func Detect(data []byte) (Format, bool) {
+	head := data
+	if len(head) > 2048 {
+		head = head[:2048]
+	}
+	lower := bytes.ToLower(head)
+	switch {
+	case bytes.Contains(lower, []byte("eurofxref")) || bytes.Contains(lower, []byte("gesmes:envelope")):
+		return FormatECB, true
+	case bytes.Contains(lower, []byte(`"observations"`)) || bocSeriesInText.Match(head):
+		return FormatBankOfCanada, true
+	}
+	return "", false
+}
+
+// Parse reads a rate file in whichever supported format it is in.
+func Parse(data []byte) (Format, []Rate, error) {
+	format, ok := Detect(data)
+	if !ok {
+		return "", nil, fmt.Errorf("not an ECB reference-rate or Bank of Canada rate file")
+	}
+	var (
+		rates []Rate
+		err   error
+	)
+	if format == FormatECB {
+		rates, err = ParseECB(data)
+	} else {
+		rates, err = ParseBankOfCanada(data)
+	}
+	return format, rates, err
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ParseECB reads an ECB eurofxref XML file. Every rate is quoted against
+// the euro: Base is EUR and Quote the listed currency.
+func ParseECB(data []byte) ([]Rate, error) {
+	var env ecbEnvelope
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid ECB rate file: %w", err)
+	}
+	var rates []Rate
+	for _, day := range env.Days {
+		date, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ECB rate date %q", day.Time)
+		}
+		for _, r := range day.Rates {
+			rate, err := decimal.NewFromString(r.Rate)
+			if err != nil || !rate.IsPositive() {
+				return nil, fmt.Errorf("invalid ECB rate %q for %s on %s", r.Rate, r.Currency, day.Time)
+			}
+			rates = append(rates, Rate{
+				Date:  date,
+				Base:  "EUR",
+				Quote: strings.ToUpper(strings.TrimSpace(r.Currency)),
+				Rate:  rate,
+			})
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("ECB rate file has no rates")
+	}
+	return rates, nil
+}
+
+// bocSeries matches Bank of Canada series IDs such as FXUSDCAD: one unit of
+// the first currency in the second.
+var (
+	bocSeries       = regexp.MustCompile(`^FX([A-Z]{3})([A-Z]{3})$`)
+	bocSeriesInText = regexp.MustCompile(`"FX[A-Z]{6}"`)
+)
+
+// ParseBankOfCanada reads a Bank of Canada Valet CSV file, either the full
+// download with its TERMS, SERIES and OBSERVATIONS sections or just the
+// observations table. Columns that aren't FX series are ignored, as are the
+// blank cells the Bank leaves on holidays.
+func ParseBankOfCanada(data []byte) ([]Rate, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Bank of Canada rate file: %w", err)
+	}
+
+	type column struct {
+		index       int
+		base, quote string
+	}
+	var (
+		columns []column
+		rates   []Rate
+	)
+	for _, rec := range records {
+		if len(rec) == 0 {
+			continue
+		}
+		first := strings.TrimSpace(rec[0])
+		if strings.EqualFold(first, "date") {
+			columns = columns[:0]
+			for i, name := range rec[1:] {
+				if m := bocSeries.FindStringSubmatch(strings.TrimSpace(name)); m != nil {
+					columns = append(columns, column{index: i + 1, base: m[1], quote: m[2]})
+				}
+			}
+			continue
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", first)
+		if err != nil {
+			// Anything after the observations that isn't a dated row, such
+			// as a trailing ERRORS section, ends the table.
+			columns = columns[:0]
+			continue
+		}
+		for _, c := range columns {
+			if c.index >= len(rec) {
+				continue
+			}
+			cell := strings.TrimSpace(rec[c.index])
+			if cell == "" {
+				continue
+			}
+			rate, err := decimal.NewFromString(cell)
+			if err != nil || !rate.IsPositive() {
+				return nil, fmt.Errorf("invalid Bank of Canada rate %q for FX%s%s on %s", cell, c.base, c.quote, first)
+			}
+			rates = append(rates, Rate{Date: date, Base: c.base, Quote: c.quote, Rate: rate})
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("Bank of Canada rate file has no FX observations")
+	}
+	return rates, nil
+}
@@ -0,0 +1,86 @@
+package fxrates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ecbDaily = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender>
+		<gesmes:name>European Central Bank</gesmes:name>
+	</gesmes:Sender>
+	<Cube>
+		<Cube time='2024-03-05'>
+			<Cube currency='USD' rate='1.0845'/>
+			<Cube currency='CAD' rate='1.4730'/>
+		</Cube>
+		<Cube time='2024-03-04'>
+			<Cube currency='USD' rate='1.0857'/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+const bocValet = `"TERMS AND CONDITIONS"
+"https://www.bankofcanada.ca/terms/"
+
+"SERIES"
+"id","label","description"
+"FXUSDCAD","USD/CAD","US dollar to Canadian dollar daily exchange rate"
+"FXEURCAD","EUR/CAD","European euro to Canadian dollar daily exchange rate"
+
+"OBSERVATIONS"
+"date","FXUSDCAD","FXEURCAD"
+"2024-03-04","1.3566","1.4720"
+"2024-03-05","1.3580",""
+`
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseECB(t *testing.T) {
+	format, rates, err := Parse([]byte(ecbDaily))
+	require.NoError(t, err)
+	assert.Equal(t, FormatECB, format)
+	require.Len(t, rates, 3)
+
+	assert.Equal(t, Rate{Date: day(2024, 3, 5), Base: "EUR", Quote: "USD", Rate: decimal.RequireFromString("1.0845")}, rates[0])
+	assert.Equal(t, "CAD", rates[1].Quote)
+	assert.Equal(t, day(2024, 3, 4), rates[2].Date)
+}
+
+func TestParseBankOfCanada(t *testing.T) {
+	format, rates, err := Parse([]byte(bocValet))
+	require.NoError(t, err)
+	assert.Equal(t, FormatBankOfCanada, format)
+	// The blank EUR cell on the 5th is skipped.
+	require.Len(t, rates, 3)
+
+	assert.Equal(t, Rate{Date: day(2024, 3, 4), Base: "USD", Quote: "CAD", Rate: decimal.RequireFromString("1.3566")}, rates[0])
+	assert.Equal(t, "EUR", rates[1].Base)
+	assert.Equal(t, day(2024, 3, 5), rates[2].Date)
+}
+
+func TestParseBankOfCanada_ObservationsOnly(t *testing.T) {
+	rates, err := ParseBankOfCanada([]byte("date,FXGBPCAD,AVG.INTWO\n2024-01-02,1.6900,4.1\n"))
+	require.NoError(t, err)
+	require.Len(t, rates, 1)
+	assert.Equal(t, "GBP", rates[0].Base)
+}
+
+func TestParse_Rejects(t *testing.T) {
+	_, _, err := Parse([]byte("Date,Description,Amount\n2024-01-02,Coffee,-4.50\n"))
+	assert.Error(t, err)
+
+	_, err = ParseBankOfCanada([]byte(`"date","FXUSDCAD"` + "\n" + `"2024-03-04","abc"` + "\n"))
+	assert.Error(t, err)
+
+	_, err = ParseECB([]byte(`<gesmes:Envelope xmlns:gesmes="x"><Cube></Cube></gesmes:Envelope>`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,269 @@
+// Package ofx reads bank and credit card statements in OFX/QFX format. Both
+// the 1.x SGML dialect (unclosed leaf elements, colon-separated header) and
+// the 2.x XML dialect are accepted; only the parts budgit imports are kept.
+package ofx
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Transaction is a single STMTTRN record. Amount is signed as the bank
+// reports it: negative for money leaving the account.
+type Transaction struct {
+	FITID   string
+	Type    string
+	Posted  time.Time
+	Amount  decimal.Decimal
+	Name    string
+	Memo    string
+	CheckNo string
+}
+
+// Statement is the flattened content of every statement response in a file.
+// LedgerBalance is nil when the file carries no LEDGERBAL aggregate.
+type Statement struct {
+	Currency          string
+	AccountID         string
+	Start             time.Time
+	End               time.Time
+	LedgerBalance     *decimal.Decimal
+	LedgerBalanceAsOf time.Time
+	Transactions      []Transaction
+}
+
+// Looks reports whether data appears to be an OFX/QFX document, so callers
+// can route an upload without trusting its file extension.
+func Looks(data []byte) bool {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	upper := bytes.ToUpper(head)
+	return bytes.Contains(upper, []byte("OFXHEADER")) || bytes.Contains(upper, []byte("<OFX>"))
+}
+
+// Parse reads an OFX 1.x or 2.x document.
+func Parse(data []byte) (*Statement, error) {
+	start := bytes.Index(bytes.ToUpper(data), []byte("<OFX>"))
+	if start == -1 {
+		return nil, fmt.Errorf("no <OFX> element found")
+	}
+
+	stmt := &Statement{}
+	var (
+		path    []string
+		current *Transaction
+		sawTxn  bool
+	)
+	inside := func(name string) bool {
+		for _, p := range path {
+			if p == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	err := tokenize(data[start:], func(kind tokenKind, name, value string) error {
+		switch kind {
+		case tokenOpen:
+			path = append(path, name)
+			if name == "STMTTRN" {
+				current = &Transaction{}
+			}
+		case tokenClose:
+			// SGML leaves are never closed, so an explicit close may pop
+			// several levels; XML leaf closes never reach here (see tokenize).
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == name {
+					path = path[:i]
+					break
+				}
+			}
+			if name == "STMTTRN" && current != nil {
+				if current.FITID == "" {
+					return fmt.Errorf("transaction without FITID")
+				}
+				if current.Posted.IsZero() {
+					return fmt.Errorf("transaction %s has no DTPOSTED", current.FITID)
+				}
+				stmt.Transactions = append(stmt.Transactions, *current)
+				current = nil
+				sawTxn = true
+			}
+		case tokenLeaf:
+			if current != nil {
+				return setTransactionField(current, name, value)
+			}
+			return setStatementField(stmt, name, value, inside)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !sawTxn && stmt.LedgerBalance == nil {
+		return nil, fmt.Errorf("no statement found")
+	}
+	return stmt, nil
+}
+
+func setTransactionField(t *Transaction, name, value string) error {
+	switch name {
+	case "TRNTYPE":
+		t.Type = strings.ToUpper(value)
+	case "FITID":
+		t.FITID = value
+	case "DTPOSTED":
+		posted, err := parseDate(value)
+		if err != nil {
+			return fmt.Errorf("invalid DTPOSTED %q: %w", value, err)
+		}
+		t.Posted = posted
+	case "TRNAMT":
+		amt, err := parseAmount(value)
+		if err != nil {
+			return fmt.Errorf("invalid TRNAMT %q: %w", value, err)
+		}
+		t.Amount = amt
+	case "NAME":
+		// PAYEE aggregates also carry NAME; the first one wins.
+		if t.Name == "" {
+			t.Name = value
+		}
+	case "MEMO":
+		t.Memo = value
+	case "CHECKNUM":
+		t.CheckNo = value
+	}
+	return nil
+}
+
+func setStatementField(s *Statement, name, value string, inside func(string) bool) error {
+	switch name {
+	case "CURDEF":
+		if s.Currency == "" {
+			s.Currency = strings.ToUpper(value)
+		}
+	case "ACCTID":
+		if s.AccountID == "" {
+			s.AccountID = value
+		}
+	case "DTSTART":
+		if d, err := parseDate(value); err == nil && (s.Start.IsZero() || d.Before(s.Start)) {
+			s.Start = d
+		}
+	case "DTEND":
+		if d, err := parseDate(value); err == nil && d.After(s.End) {
+			s.End = d
+		}
+	case "BALAMT":
+		if !inside("LEDGERBAL") || s.LedgerBalance != nil {
+			return nil
+		}
+		amt, err := parseAmount(value)
+		if err != nil {
+			return fmt.Errorf("invalid LEDGERBAL %q: %w", value, err)
+		}
+		s.LedgerBalance = &amt
+	case "DTASOF":
+		if inside("LEDGERBAL") && s.LedgerBalanceAsOf.IsZero() {
+			if d, err := parseDate(value); err == nil {
+				s.LedgerBalanceAsOf = d
+			}
+		}
+	}
+	return nil
+}
+
+// parseDate reads the OFX datetime format YYYYMMDD[HHMMSS[.XXX]][[offset:TZ]].
+// Only the calendar date is kept; budgit transactions are dated, not timed.
+func parseDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("too short")
+	}
+	return time.Parse("20060102", value[:8])
+}
+
+// parseAmount accepts a signed decimal, tolerating a decimal comma as some
+// European institutions emit.
+func parseAmount(value string) (decimal.Decimal, error) {
+	v := strings.TrimSpace(value)
+	if !strings.Contains(v, ".") {
+		v = strings.Replace(v, ",", ".", 1)
+	}
+	return decimal.NewFromString(strings.TrimPrefix(v, "+"))
+}
+
+type tokenKind int
+
+const (
+	tokenOpen tokenKind = iota
+	tokenClose
+	tokenLeaf
+)
+
+// tokenize walks the element stream. An element immediately followed by text
+// is reported as a leaf (its closing tag, if any, is swallowed); anything
+// else is an aggregate open. Processing instructions and comments are
+// skipped.
+func tokenize(data []byte, emit func(kind tokenKind, name, value string) error) error {
+	s := string(data)
+	for {
+		lt := strings.IndexByte(s, '<')
+		if lt == -1 {
+			return nil
+		}
+		s = s[lt+1:]
+		gt := strings.IndexByte(s, '>')
+		if gt == -1 {
+			return fmt.Errorf("unterminated tag")
+		}
+		tag := strings.TrimSpace(s[:gt])
+		s = s[gt+1:]
+
+		if tag == "" || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+		if tag[0] == '/' {
+			if err := emit(tokenClose, strings.ToUpper(strings.TrimSpace(tag[1:])), ""); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasSuffix(tag, "/") {
+			// Self-closing XML element: an empty leaf.
+			continue
+		}
+		name := strings.ToUpper(strings.Fields(tag)[0])
+
+		next := strings.IndexByte(s, '<')
+		text := s
+		if next != -1 {
+			text = s[:next]
+		}
+		value := strings.TrimSpace(text)
+		if value == "" {
+			if err := emit(tokenOpen, name, ""); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := emit(tokenLeaf, name, html.UnescapeString(value)); err != nil {
+			return err
+		}
+		s = s[len(text):]
+		// Swallow the matching XML close so it isn't mistaken for an
+		// aggregate close.
+		closing := "</" + name + ">"
+		if len(s) >= len(closing) && strings.EqualFold(s[:len(closing)], closing) {
+			s = s[len(closing):]
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package ofx
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20240305120000<LANGUAGE>ENG</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STATUS><CODE>0<SEVERITY>INFO</STATUS>
+<STMTRS>
+<CURDEF>CAD
+<BANKACCTFROM><BANKID>0001<ACCTID>12345<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20240201
+<DTEND>20240229
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240203120000[-5:EST]
+<TRNAMT>-42.17
+<FITID>9001
+<NAME>LOBLAWS #123
+<MEMO>Groceries &amp; more
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240215
+<TRNAMT>2000.00
+<FITID>9002
+<NAME>PAYROLL
+</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>1957.83<DTASOF>20240229</LEDGERBAL>
+<AVAILBAL><BALAMT>1900.00<DTASOF>20240229</AVAILBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <CCSTMTRS>
+        <CURDEF>USD</CURDEF>
+        <CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <DTSTART>20240301</DTSTART>
+          <DTEND>20240331</DTEND>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20240310</DTPOSTED>
+            <TRNAMT>-15.00</TRNAMT>
+            <FITID>abc-1</FITID>
+            <PAYEE><NAME>Streaming Co</NAME></PAYEE>
+            <MEMO/>
+          </STMTTRN>
+        </BANKTRANLIST>
+        <LEDGERBAL>
+          <BALAMT>-15.00</BALAMT>
+          <DTASOF>20240331</DTASOF>
+        </LEDGERBAL>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestParse_SGML(t *testing.T) {
+	require.True(t, Looks([]byte(sgmlStatement)))
+
+	stmt, err := Parse([]byte(sgmlStatement))
+	require.NoError(t, err)
+
+	assert.Equal(t, "CAD", stmt.Currency)
+	assert.Equal(t, "12345", stmt.AccountID)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), stmt.Start)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), stmt.End)
+
+	require.Len(t, stmt.Transactions, 2)
+	first := stmt.Transactions[0]
+	assert.Equal(t, "9001", first.FITID)
+	assert.Equal(t, "DEBIT", first.Type)
+	assert.Equal(t, time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC), first.Posted)
+	assert.True(t, decimal.RequireFromString("-42.17").Equal(first.Amount))
+	assert.Equal(t, "LOBLAWS #123", first.Name)
+	assert.Equal(t, "Groceries & more", first.Memo)
+
+	// LEDGERBAL wins over AVAILBAL even though both carry BALAMT.
+	require.NotNil(t, stmt.LedgerBalance)
+	assert.True(t, decimal.RequireFromString("1957.83").Equal(*stmt.LedgerBalance))
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), stmt.LedgerBalanceAsOf)
+}
+
+func TestParse_XML(t *testing.T) {
+	require.True(t, Looks([]byte(xmlStatement)))
+
+	stmt, err := Parse([]byte(xmlStatement))
+	require.NoError(t, err)
+
+	assert.Equal(t, "USD", stmt.Currency)
+	assert.Equal(t, "4111", stmt.AccountID)
+	require.Len(t, stmt.Transactions, 1)
+	assert.Equal(t, "abc-1", stmt.Transactions[0].FITID)
+	assert.Equal(t, "Streaming Co", stmt.Transactions[0].Name)
+	require.NotNil(t, stmt.LedgerBalance)
+	assert.True(t, decimal.RequireFromString("-15").Equal(*stmt.LedgerBalance))
+}
+
+func TestParse_RejectsNonOFX(t *testing.T) {
+	assert.False(t, Looks([]byte("Date,Description,Amount\n")))
+	_, err := Parse([]byte("Date,Description,Amount\n"))
+	assert.Error(t, err)
+}
+
+func TestParse_RequiresFITID(t *testing.T) {
+	_, err := Parse([]byte("<OFX><STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240101<TRNAMT>-1</STMTTRN></OFX>"))
+	assert.Error(t, err)
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	balance := decimal.RequireFromString("-57.50")
+	require.NoError(t, w.Begin(StatementInfo{
+		Currency:      currency.Get("CAD"),
+		AccountID:     "acct-1",
+		Start:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:           time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		LedgerBalance: &balance,
+	}))
+	require.NoError(t, w.WriteTransaction(Transaction{
+		FITID:  "t1",
+		Posted: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+		Amount: decimal.RequireFromString("-57.5"),
+		Name:   "A very long merchant name & co that overflows",
+		Memo:   "note",
+	}))
+	require.NoError(t, w.Close())
+
+	stmt, err := Parse(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "CAD", stmt.Currency)
+	assert.Equal(t, "acct-1", stmt.AccountID)
+	require.Len(t, stmt.Transactions, 1)
+	got := stmt.Transactions[0]
+	assert.Equal(t, "DEBIT", got.Type)
+	assert.Equal(t, "t1", got.FITID)
+	assert.Len(t, []rune(got.Name), maxNameLen)
+	assert.Equal(t, "A very long merchant name & co that overflows - note", got.Memo)
+	require.NotNil(t, stmt.LedgerBalance)
+	assert.True(t, balance.Equal(*stmt.LedgerBalance))
+}
+
+func TestWriter_UsesCurrencyMinorUnits(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	balance := decimal.NewFromInt(8800)
+	require.NoError(t, w.Begin(StatementInfo{Currency: currency.Get("JPY"), AccountID: "acct-1", LedgerBalance: &balance}))
+	require.NoError(t, w.WriteTransaction(Transaction{FITID: "t1", Amount: decimal.NewFromInt(-1200), Name: "Ramen"}))
+	require.NoError(t, w.Close())
+
+	assert.Contains(t, buf.String(), "<CURDEF>JPY</CURDEF>")
+	assert.Contains(t, buf.String(), "<TRNAMT>-1200</TRNAMT>")
+	assert.Contains(t, buf.String(), "<BALAMT>8800</BALAMT>")
+}
@@ -0,0 +1,164 @@
+package ofx
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"github.com/shopspring/decimal"
+)
+
+// maxNameLen is the OFX limit on a transaction NAME. Longer titles are cut
+// and the full text is kept in MEMO.
+const maxNameLen = 32
+
+// StatementInfo describes one statement block of an export.
+type StatementInfo struct {
+	// Currency is the statement's currency; amounts are written to its minor
+	// units.
+	Currency  currency.Currency
+	AccountID string
+	Start     time.Time
+	End       time.Time
+	// LedgerBalance is written as LEDGERBAL when set.
+	LedgerBalance     *decimal.Decimal
+	LedgerBalanceAsOf time.Time
+}
+
+// Writer produces an OFX 2.x (XML) document one transaction at a time, so a
+// statement of any length can be streamed. Call Begin for each statement,
+// WriteTransaction for its records, End to close it, and Close once done.
+type Writer struct {
+	w      *bufio.Writer
+	opened bool
+	inStmt bool
+	info   StatementInfo
+	stmts  int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+func (wr *Writer) header() {
+	if wr.opened {
+		return
+	}
+	wr.opened = true
+	fmt.Fprint(wr.w, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>`+"\n")
+	fmt.Fprint(wr.w, `<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`+"\n")
+	fmt.Fprint(wr.w, "<OFX>\n")
+	fmt.Fprintf(wr.w, "<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", formatDateTime(time.Now()))
+	fmt.Fprint(wr.w, "<BANKMSGSRSV1>\n")
+}
+
+// Begin opens a statement.
+func (wr *Writer) Begin(info StatementInfo) error {
+	if wr.inStmt {
+		return fmt.Errorf("previous statement was not ended")
+	}
+	wr.header()
+	wr.inStmt = true
+	wr.info = info
+	wr.stmts++
+
+	fmt.Fprintf(wr.w, "<STMTTRNRS><TRNUID>%d</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n", wr.stmts)
+	fmt.Fprint(wr.w, "<STMTRS>\n")
+	fmt.Fprintf(wr.w, "<CURDEF>%s</CURDEF>\n", escape(info.Currency.Code))
+	fmt.Fprintf(wr.w, "<BANKACCTFROM><BANKID>0</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", escape(info.AccountID))
+	fmt.Fprintf(wr.w, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", formatDate(info.Start), formatDate(info.End))
+	return wr.w.Flush()
+}
+
+// WriteTransaction appends a STMTTRN to the open statement. Type defaults to
+// DEBIT or CREDIT from the amount's sign.
+func (wr *Writer) WriteTransaction(t Transaction) error {
+	if !wr.inStmt {
+		return fmt.Errorf("no open statement")
+	}
+	trnType := t.Type
+	if trnType == "" {
+		trnType = "CREDIT"
+		if t.Amount.IsNegative() {
+			trnType = "DEBIT"
+		}
+	}
+	name, memo := t.Name, t.Memo
+	if r := []rune(name); len(r) > maxNameLen {
+		if memo == "" {
+			memo = name
+		} else {
+			memo = name + " - " + memo
+		}
+		name = string(r[:maxNameLen])
+	}
+
+	fmt.Fprint(wr.w, "<STMTTRN>")
+	fmt.Fprintf(wr.w, "<TRNTYPE>%s</TRNTYPE>", escape(trnType))
+	fmt.Fprintf(wr.w, "<DTPOSTED>%s</DTPOSTED>", formatDate(t.Posted))
+	fmt.Fprintf(wr.w, "<TRNAMT>%s</TRNAMT>", wr.info.Currency.Fixed(t.Amount))
+	fmt.Fprintf(wr.w, "<FITID>%s</FITID>", escape(t.FITID))
+	if t.CheckNo != "" {
+		fmt.Fprintf(wr.w, "<CHECKNUM>%s</CHECKNUM>", escape(t.CheckNo))
+	}
+	if name != "" {
+		fmt.Fprintf(wr.w, "<NAME>%s</NAME>", escape(name))
+	}
+	if memo != "" {
+		fmt.Fprintf(wr.w, "<MEMO>%s</MEMO>", escape(memo))
+	}
+	fmt.Fprint(wr.w, "</STMTTRN>\n")
+	// Flush per record so the response streams instead of buffering.
+	return wr.w.Flush()
+}
+
+// End closes the open statement, writing its ledger balance if known.
+func (wr *Writer) End() error {
+	if !wr.inStmt {
+		return fmt.Errorf("no open statement")
+	}
+	wr.inStmt = false
+	fmt.Fprint(wr.w, "</BANKTRANLIST>\n")
+	if wr.info.LedgerBalance != nil {
+		asOf := wr.info.LedgerBalanceAsOf
+		if asOf.IsZero() {
+			asOf = time.Now()
+		}
+		fmt.Fprintf(wr.w, "<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n", wr.info.Currency.Fixed(*wr.info.LedgerBalance), formatDateTime(asOf))
+	}
+	fmt.Fprint(wr.w, "</STMTRS></STMTTRNRS>\n")
+	return wr.w.Flush()
+}
+
+// Close finishes the document. A document with no statements is still valid.
+func (wr *Writer) Close() error {
+	if wr.inStmt {
+		if err := wr.End(); err != nil {
+			return err
+		}
+	}
+	wr.header()
+	fmt.Fprint(wr.w, "</BANKMSGSRSV1>\n</OFX>\n")
+	return wr.w.Flush()
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "19700101"
+	}
+	return t.Format("20060102")
+}
+
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format("20060102150405")
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AllocationMovementKind says why an allocation's amount changed.
+type AllocationMovementKind string
+
+const (
+	// AllocationMovementContribution puts money into the allocation.
+	AllocationMovementContribution AllocationMovementKind = "contribution"
+	// AllocationMovementWithdrawal takes money out for what it was saved for.
+	AllocationMovementWithdrawal AllocationMovementKind = "withdrawal"
+	// AllocationMovementAdjustment corrects the amount, e.g. when it is
+	// edited directly.
+	AllocationMovementAdjustment AllocationMovementKind = "adjustment"
+	// AllocationMovementTransfer moves money between two allocations of the
+	// same account.
+	AllocationMovementTransfer AllocationMovementKind = "transfer"
+)
+
+func (k AllocationMovementKind) Label() string {
+	switch k {
+	case AllocationMovementContribution:
+		return "Contribution"
+	case AllocationMovementWithdrawal:
+		return "Withdrawal"
+	case AllocationMovementAdjustment:
+		return "Adjustment"
+	case AllocationMovementTransfer:
+		return "Transfer"
+	}
+	return string(k)
+}
+
+// AllocationMovement is one dated change to an allocation's amount.
+type AllocationMovement struct {
+	ID           string                 `db:"id"`
+	AllocationID string                 `db:"allocation_id"`
+	Kind         AllocationMovementKind `db:"kind"`
+	// Amount is the signed change: positive when money went in.
+	Amount decimal.Decimal `db:"amount"`
+	// CounterpartID is the other allocation of a transfer, nil for other
+	// kinds or once that allocation is deleted.
+	CounterpartID *string   `db:"counterpart_id"`
+	ActorID       *string   `db:"actor_id"`
+	Note          string    `db:"note"`
+	OccurredAt    time.Time `db:"occurred_at"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+type AllocationMovementWithActor struct {
+	AllocationMovement
+	ActorName       *string `db:"actor_name"`
+	CounterpartName *string `db:"counterpart_name"`
+}
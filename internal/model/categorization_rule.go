@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// RuleTitleMatch is how a categorization rule compares its pattern with a
+// transaction title.
+type RuleTitleMatch string
+
+const (
+	// RuleTitleMatchContains matches titles containing the pattern, ignoring
+	// case.
+	RuleTitleMatchContains RuleTitleMatch = "contains"
+	// RuleTitleMatchRegex matches titles against the pattern as a
+	// case-insensitive regular expression.
+	RuleTitleMatchRegex RuleTitleMatch = "regex"
+)
+
+// CategorizationRule assigns a category to transactions entered or imported
+// without one. Empty conditions are ignored; a transaction matches when it
+// meets every condition that is set. An account's rules are tried in
+// ascending Priority and the first match wins.
+type CategorizationRule struct {
+	ID           string         `db:"id"`
+	AccountID    string         `db:"account_id"`
+	CategoryID   string         `db:"category_id"`
+	Priority     int            `db:"priority"`
+	TitleMatch   RuleTitleMatch `db:"title_match"`
+	TitlePattern string         `db:"title_pattern"`
+	// MinAmount and MaxAmount bound the transaction value, inclusive.
+	MinAmount       *decimal.Decimal `db:"min_amount"`
+	MaxAmount       *decimal.Decimal `db:"max_amount"`
+	TransactionType *TransactionType `db:"transaction_type"`
+	// RenameTo, when set, replaces the title of matched transactions.
+	RenameTo  *string   `db:"rename_to"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CategoryLimit caps what an account spends in one of its categories each
+// month, from EffectiveMonth until a later CategoryLimit for the same
+// category takes over. A nil Amount ends an earlier limit. Rollover carries
+// what is left over, or overspent, into the next month.
+type CategoryLimit struct {
+	ID             string           `db:"id"`
+	AccountID      string           `db:"account_id"`
+	CategoryID     string           `db:"category_id"`
+	EffectiveMonth time.Time        `db:"effective_month"`
+	Amount         *decimal.Decimal `db:"amount"`
+	Rollover       bool             `db:"rollover"`
+	CreatedAt      time.Time        `db:"created_at"`
+	UpdatedAt      time.Time        `db:"updated_at"`
+}
+
+// CategoryMonth is how a limited category did in one month. Spent includes
+// bills filed under the category's subcategories. ClosedAt is set once the
+// month is over and its numbers are frozen. A closed month keeps
+// CategoryName after its category is deleted, when CategoryID becomes nil.
+type CategoryMonth struct {
+	AccountID    string          `db:"account_id"`
+	CategoryID   *string         `db:"category_id"`
+	CategoryName string          `db:"category_name"`
+	Month        time.Time       `db:"month"`
+	Limit        decimal.Decimal `db:"limit_amount"`
+	// CarriedIn is what the previous month rolled over: positive when it was
+	// under its limit, negative when it went over.
+	CarriedIn decimal.Decimal `db:"carried_in"`
+	Spent     decimal.Decimal `db:"spent"`
+	Rollover  bool            `db:"rollover"`
+	ClosedAt  *time.Time      `db:"closed_at"`
+}
+
+// Available is the month's limit plus whatever rolled over into it.
+func (m *CategoryMonth) Available() decimal.Decimal {
+	return m.Limit.Add(m.CarriedIn)
+}
+
+// Remaining is what is left to spend this month, negative once over.
+func (m *CategoryMonth) Remaining() decimal.Decimal {
+	return m.Available().Sub(m.Spent)
+}
+
+// Over reports whether the month's spending went past what was available.
+func (m *CategoryMonth) Over() bool {
+	return m.Remaining().IsNegative()
+}
+
+// CarriedOut is what rolls into the next month: the remainder when the
+// limit rolls over, zero otherwise.
+func (m *CategoryMonth) CarriedOut() decimal.Decimal {
+	if !m.Rollover {
+		return decimal.Zero
+	}
+	return m.Remaining()
+}
+
+// PercentSpent is Spent as a whole percentage of Available, capped at 100.
+// A month with nothing available is at 100 as soon as anything is spent.
+func (m *CategoryMonth) PercentSpent() int {
+	available := m.Available()
+	if !available.IsPositive() {
+		if m.Spent.IsPositive() {
+			return 100
+		}
+		return 0
+	}
+	pct := m.Spent.Div(available).Mul(decimal.NewFromInt(100)).IntPart()
+	if pct > 100 {
+		return 100
+	}
+	if pct < 0 {
+		return 0
+	}
+	return int(pct)
+}
+
+// MonthStart returns the first instant of t's month in UTC, the key category
+// limits and their months are stored under.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// DefaultCategoryTemplateID identifies the built-in template every space can
+// use. It isn't stored; DefaultCategoryTemplateItems holds its categories.
+const DefaultCategoryTemplateID = "default"
+
+// DefaultCategoryTemplateName is the display name of the built-in template.
+const DefaultCategoryTemplateName = "Default"
+
+// CategoryTemplate is a named set of categories a space can copy into any of
+// its accounts.
+type CategoryTemplate struct {
+	ID        string    `db:"id"`
+	SpaceID   string    `db:"space_id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// CategoryTemplateItem is one category in a template.
+type CategoryTemplateItem struct {
+	ID         string `db:"id"`
+	TemplateID string `db:"template_id"`
+	// ParentID nests the item under another item of the same template. Nil
+	// for a top-level category.
+	ParentID    *string   `db:"parent_id"`
+	Name        string    `db:"name"`
+	Description *string   `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// DefaultCategoryTemplateItems returns the categories of the built-in
+// template, the set accounts were seeded with before categories moved to
+// account scope.
+func DefaultCategoryTemplateItems() []*CategoryTemplateItem {
+	seed := []struct{ id, name, description string }{
+		{"housing", "Housing", "rent/mortgage, utilities, maintenance"},
+		{"food", "Food", "groceries and dining out"},
+		{"transport", "Transport", "fuel, transit, car payments, parking"},
+		{"health", "Health", "medical, pharmacy, gym"},
+		{"lifestyle", "Lifestyle", "entertainment, hobbies, subscriptions"},
+		{"shopping", "Shopping", "clothing, electronics, household goods"},
+		{"personal", "Personal", "haircuts, gifts, donations"},
+		{"savings_debt", "Savings & Debt", "loan payments, savings contributions"},
+	}
+	items := make([]*CategoryTemplateItem, len(seed))
+	for i, s := range seed {
+		description := s.description
+		items[i] = &CategoryTemplateItem{
+			ID:          DefaultCategoryTemplateID + "-" + s.id,
+			TemplateID:  DefaultCategoryTemplateID,
+			Name:        s.name,
+			Description: &description,
+		}
+	}
+	return items
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// EnvelopeAssignment is money put into one envelope of a space's envelope
+// budget for a month. Envelopes are categories matched by name across the
+// space's accounts, so Envelope is the lowercase category name, or "" for
+// spending without a category.
+type EnvelopeAssignment struct {
+	SpaceID   string          `db:"space_id"`
+	Envelope  string          `db:"envelope"`
+	Month     time.Time       `db:"month"`
+	Amount    decimal.Decimal `db:"amount"`
+	UpdatedAt time.Time       `db:"updated_at"`
+}
+
+// EnvelopeMonth is one envelope in one month of an envelope budget.
+type EnvelopeMonth struct {
+	// Envelope is the envelope's key and Name what it is shown as.
+	Envelope string
+	Name     string
+	// CarriedIn is what was left in the envelope at the end of last month.
+	// Overspending is never carried; it comes out of the money to assign.
+	CarriedIn decimal.Decimal
+	Assigned  decimal.Decimal
+	// Activity is the month's bills in the envelope's categories, negative
+	// when money was spent.
+	Activity decimal.Decimal
+}
+
+// Available is what is in the envelope: what it carried in and was assigned,
+// less what was spent.
+func (m *EnvelopeMonth) Available() decimal.Decimal {
+	return m.CarriedIn.Add(m.Assigned).Add(m.Activity)
+}
+
+// Overspent reports whether more was spent than the envelope held.
+func (m *EnvelopeMonth) Overspent() bool {
+	return m.Available().IsNegative()
+}
+
+// CarriedOut is what the envelope takes into next month.
+func (m *EnvelopeMonth) CarriedOut() decimal.Decimal {
+	if m.Overspent() {
+		return decimal.Zero
+	}
+	return m.Available()
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRateSource is where a stored exchange rate came from.
+type ExchangeRateSource string
+
+const (
+	ExchangeRateSourceManual         ExchangeRateSource = "manual"
+	ExchangeRateSourceTransfer       ExchangeRateSource = "transfer"
+	ExchangeRateSourceCurrencyChange ExchangeRateSource = "currency_change"
+	ExchangeRateSourceECB            ExchangeRateSource = "ecb"
+	ExchangeRateSourceBankOfCanada   ExchangeRateSource = "boc"
+)
+
+// Imported reports whether the rate came from a published reference-rate
+// file rather than from something done in the space. Imported rates never
+// replace a rate the space recorded itself for the same day.
+func (s ExchangeRateSource) Imported() bool {
+	return s == ExchangeRateSourceECB || s == ExchangeRateSourceBankOfCanada
+}
+
+func (s ExchangeRateSource) Label() string {
+	switch s {
+	case ExchangeRateSourceTransfer:
+		return "Transfer"
+	case ExchangeRateSourceCurrencyChange:
+		return "Currency change"
+	case ExchangeRateSourceECB:
+		return "ECB"
+	case ExchangeRateSourceBankOfCanada:
+		return "Bank of Canada"
+	default:
+		return "Manual"
+	}
+}
+
+// ExchangeRate says one unit of BaseCurrency was worth Rate units of
+// QuoteCurrency on RateDate.
+type ExchangeRate struct {
+	ID            string             `db:"id"`
+	SpaceID       string             `db:"space_id"`
+	BaseCurrency  string             `db:"base_currency"`
+	QuoteCurrency string             `db:"quote_currency"`
+	RateDate      time.Time          `db:"rate_date"`
+	Rate          decimal.Decimal    `db:"rate"`
+	Source        ExchangeRateSource `db:"source"`
+	CreatedAt     time.Time          `db:"created_at"`
+	UpdatedAt     time.Time          `db:"updated_at"`
+}
@@ -14,8 +14,163 @@ type Account struct {
 	Currency          string          `db:"currency"`
 	IsInvestment      bool            `db:"is_investment"`
 	InvestmentSubtype *string         `db:"investment_subtype"`
-	CreatedAt         time.Time       `db:"created_at"`
-	UpdatedAt         time.Time       `db:"updated_at"`
+	Kind              AccountKind     `db:"kind"`
+	// CreditLimit, StatementClosingDay and PaymentDueDay are the credit terms
+	// of a liability account; nil when unknown and always nil on cash
+	// accounts. The days are days of the month, clamped to its last day.
+	CreditLimit         *decimal.Decimal `db:"credit_limit"`
+	StatementClosingDay *int             `db:"statement_closing_day"`
+	PaymentDueDay       *int             `db:"payment_due_day"`
+	// ArchivedAt is when the account was closed, or nil while it is open.
+	ArchivedAt *time.Time `db:"archived_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+	UpdatedAt  time.Time  `db:"updated_at"`
+}
+
+// IsArchived reports whether the account is closed to new transactions.
+func (a *Account) IsArchived() bool {
+	return a.ArchivedAt != nil
+}
+
+// IsLiability reports whether the account tracks money owed.
+func (a *Account) IsLiability() bool {
+	return a.Kind.IsLiability()
+}
+
+// Owed is what is owed on a liability account: its balance with the sign
+// flipped, so charges make it grow. A negative amount is a credit balance.
+func (a *Account) Owed() decimal.Decimal {
+	return a.Balance.Neg()
+}
+
+// AvailableCredit is the credit limit less what is owed, or nil when the
+// account has no limit.
+func (a *Account) AvailableCredit() *decimal.Decimal {
+	if a.CreditLimit == nil {
+		return nil
+	}
+	available := a.CreditLimit.Sub(a.Owed())
+	return &available
+}
+
+// Utilization is what is owed as a percentage of the credit limit, or nil
+// when the account has no positive limit.
+func (a *Account) Utilization() *decimal.Decimal {
+	if a.CreditLimit == nil || !a.CreditLimit.IsPositive() {
+		return nil
+	}
+	pct := a.Owed().Div(*a.CreditLimit).Mul(decimal.NewFromInt(100)).Round(1)
+	return &pct
+}
+
+// AccountKind decides how an account's balance is read. Cash accounts
+// (chequing, savings, wallets) hold money; the rest are liabilities whose
+// negative balance is the amount owed.
+type AccountKind string
+
+const (
+	AccountKindCash         AccountKind = "cash"
+	AccountKindCreditCard   AccountKind = "credit_card"
+	AccountKindLineOfCredit AccountKind = "line_of_credit"
+	// AccountKindLoan is an amortizing loan or mortgage. Its terms live in
+	// LoanTerms and it is only set up through them.
+	AccountKindLoan AccountKind = "loan"
+)
+
+func IsValidAccountKind(s string) bool {
+	switch AccountKind(s) {
+	case AccountKindCash, AccountKindCreditCard, AccountKindLineOfCredit, AccountKindLoan:
+		return true
+	}
+	return false
+}
+
+func (k AccountKind) IsLiability() bool {
+	return k == AccountKindCreditCard || k == AccountKindLineOfCredit || k == AccountKindLoan
+}
+
+// LedgerKind is the kind of the account's ledger account.
+func (k AccountKind) LedgerKind() LedgerAccountKind {
+	if k.IsLiability() {
+		return LedgerAccountKindLiability
+	}
+	return LedgerAccountKindAsset
+}
+
+func (k AccountKind) Label() string {
+	switch k {
+	case AccountKindCreditCard:
+		return "Credit card"
+	case AccountKindLineOfCredit:
+		return "Line of credit"
+	case AccountKindLoan:
+		return "Loan"
+	default:
+		return "Cash"
+	}
+}
+
+// AccountTotals sums accounts that share a currency. Liabilities is what is
+// owed, as a positive amount, so net worth subtracts it.
+type AccountTotals struct {
+	Currency    string
+	Assets      decimal.Decimal
+	Liabilities decimal.Decimal
+}
+
+func (t *AccountTotals) NetWorth() decimal.Decimal {
+	return t.Assets.Sub(t.Liabilities)
+}
+
+// TotalsByCurrency groups accounts by currency, in order of first
+// appearance. Balances in different currencies are never added together.
+func TotalsByCurrency(accounts []*Account) []*AccountTotals {
+	var totals []*AccountTotals
+	byCurrency := map[string]*AccountTotals{}
+	for _, a := range accounts {
+		t, ok := byCurrency[a.Currency]
+		if !ok {
+			t = &AccountTotals{Currency: a.Currency}
+			byCurrency[a.Currency] = t
+			totals = append(totals, t)
+		}
+		if a.IsLiability() {
+			t.Liabilities = t.Liabilities.Add(a.Owed())
+		} else {
+			t.Assets = t.Assets.Add(a.Balance)
+		}
+	}
+	return totals
+}
+
+// StatementCycle is one billing cycle of a liability account, from the day
+// after the previous statement closed through the closing date. Balances are
+// amounts owed.
+type StatementCycle struct {
+	Start          time.Time
+	ClosingDate    time.Time
+	DueDate        *time.Time
+	OpeningBalance decimal.Decimal
+	Charges        decimal.Decimal
+	Payments       decimal.Decimal
+	// StatementBalance is what was owed when the cycle closed.
+	StatementBalance decimal.Decimal
+}
+
+// BalanceDrift is an account whose stored balance disagrees with the balance
+// recomputed from its ledger postings.
+type BalanceDrift struct {
+	AccountID   string          `db:"account_id"`
+	AccountName string          `db:"account_name"`
+	SpaceID     string          `db:"space_id"`
+	Currency    string          `db:"currency"`
+	Stored      decimal.Decimal `db:"stored"`
+	Computed    decimal.Decimal `db:"computed"`
+}
+
+// Difference is how far the stored balance is off: stored minus computed.
+func (d *BalanceDrift) Difference() decimal.Decimal {
+	return d.Stored.Sub(d.Computed)
 }
 
 type InvestmentSubtype string
@@ -44,16 +199,47 @@ const (
 	TransactionTypeWithdrawal TransactionType = "withdrawal"
 )
 
+// TransactionStatus tracks a transaction's progress against the bank
+// statement: pending until it shows up on the statement, cleared once ticked
+// off, and reconciled when a reconciliation covering it is finalized.
+type TransactionStatus string
+
+const (
+	TransactionStatusPending    TransactionStatus = "pending"
+	TransactionStatusCleared    TransactionStatus = "cleared"
+	TransactionStatusReconciled TransactionStatus = "reconciled"
+)
+
 type Transaction struct {
-	ID          string          `db:"id"`
-	Value       decimal.Decimal `db:"value"`
-	Type        TransactionType `db:"type"`
-	AccountID   string          `db:"account_id"`
-	Title       string          `db:"title"`
-	Description *string         `db:"description"`
-	OccurredAt  time.Time       `db:"occurred_at"`
-	CreatedAt   time.Time       `db:"created_at"`
-	UpdatedAt   time.Time       `db:"updated_at"`
+	ID          string            `db:"id"`
+	Value       decimal.Decimal   `db:"value"`
+	Type        TransactionType   `db:"type"`
+	AccountID   string            `db:"account_id"`
+	Title       string            `db:"title"`
+	Description *string           `db:"description"`
+	Status      TransactionStatus `db:"status"`
+	OccurredAt  time.Time         `db:"occurred_at"`
+	CreatedAt   time.Time         `db:"created_at"`
+	UpdatedAt   time.Time         `db:"updated_at"`
+	// RunningBalance is the account balance right after this transaction, in
+	// occurred_at then created_at order, in the account's current currency.
+	// Only set by queries that compute it.
+	RunningBalance *decimal.Decimal `db:"running_balance"`
+}
+
+// IsReconciled reports whether the transaction is locked by a finalized
+// reconciliation.
+func (t *Transaction) IsReconciled() bool {
+	return t.Status == TransactionStatusReconciled
+}
+
+// SignedValue returns the value with its direction applied: negative for
+// withdrawals, positive for deposits.
+func (t *Transaction) SignedValue() decimal.Decimal {
+	if t.Type == TransactionTypeWithdrawal {
+		return t.Value.Neg()
+	}
+	return t.Value
 }
 
 // TransactionFilter describes optional criteria for narrowing a transaction
@@ -63,6 +249,16 @@ type Transaction struct {
 type TransactionFilter struct {
 	// Title matches transactions whose title contains this text (case-insensitive).
 	Title string
+	// Query matches the title or description by full-text search, by
+	// substring, or by trigram similarity to the title, so partial merchant
+	// names still find their transactions.
+	Query string
+	// Type restricts matches to deposits or withdrawals.
+	Type TransactionType
+	// CategoryName matches transactions with a category of this name
+	// (case-insensitive). Categories belong to an account, so matching by name
+	// lets one filter span accounts.
+	CategoryName string
 	// DateFrom / DateTo bound occurred_at (inclusive).
 	DateFrom *time.Time
 	DateTo   *time.Time
@@ -70,15 +266,31 @@ type TransactionFilter struct {
 	// to the same value.
 	AmountMin *decimal.Decimal
 	AmountMax *decimal.Decimal
+	// TagIDs matches transactions carrying any of these tags.
+	TagIDs []string
 }
 
 // IsZero reports whether the filter has no active criteria.
 func (f TransactionFilter) IsZero() bool {
 	return f.Title == "" &&
+		f.Query == "" &&
+		f.Type == "" &&
+		f.CategoryName == "" &&
 		f.DateFrom == nil &&
 		f.DateTo == nil &&
 		f.AmountMin == nil &&
-		f.AmountMax == nil
+		f.AmountMax == nil &&
+		len(f.TagIDs) == 0
+}
+
+// TransactionSearchResult is a transaction found by a search that spans
+// accounts, carrying the account and space it belongs to.
+type TransactionSearchResult struct {
+	Transaction
+	AccountName string `db:"account_name"`
+	Currency    string `db:"currency"`
+	SpaceID     string `db:"space_id"`
+	SpaceName   string `db:"space_name"`
 }
 
 // CategoryTimeSeries is a bucketed breakdown of transaction totals by category
@@ -93,15 +305,44 @@ type CategoryTimeSeries struct {
 	Series []CategorySeriesData
 	// Total is the grand total across every bucket and series.
 	Total decimal.Decimal
+	// Currency is the currency the totals were converted into, empty when
+	// they are in the account's own currency.
+	Currency string
+	// Skipped names the accounts a space-wide report left out for lack of
+	// a rate into Currency.
+	Skipped []string
 }
 
 // CategorySeriesData is a single category's values aligned to
 // CategoryTimeSeries.Buckets (same length, zero-filled for empty buckets).
 type CategorySeriesData struct {
-	CategoryID   string // "" for the uncategorized series
+	// CategoryID is "" for the uncategorized series. Space-wide reports
+	// match categories by name and use the lowercase name instead.
+	CategoryID   string
 	CategoryName string
-	Values       []decimal.Decimal
-	Total        decimal.Decimal
+	// HasChildren marks a category with subcategories the series can be
+	// drilled into.
+	HasChildren bool
+	Values      []decimal.Decimal
+	Total       decimal.Decimal
+}
+
+// TagTimeSeries is CategoryTimeSeries keyed by tag. A transaction carrying
+// several tags counts toward each of them, so series can overlap and are not
+// meant to be stacked.
+type TagTimeSeries struct {
+	Buckets []time.Time
+	Series  []TagSeriesData
+	// Total is the sum of tagged transactions, each counted once.
+	Total decimal.Decimal
+}
+
+// TagSeriesData is a single tag's values aligned to TagTimeSeries.Buckets.
+type TagSeriesData struct {
+	TagID   string
+	TagName string
+	Values  []decimal.Decimal
+	Total   decimal.Decimal
 }
 
 type Tag struct {
@@ -126,8 +367,9 @@ type Allocation struct {
 type RecurringEventKind string
 
 const (
-	RecurringEventKindBill RecurringEventKind = "bill"
-	RecurringEventKindFund RecurringEventKind = "fund"
+	RecurringEventKindBill     RecurringEventKind = "bill"
+	RecurringEventKindFund     RecurringEventKind = "fund"
+	RecurringEventKindTransfer RecurringEventKind = "transfer"
 )
 
 type RecurringFrequency string
@@ -144,9 +386,12 @@ type RecurringEvent struct {
 	SpaceID         string             `db:"space_id"`
 	Kind            RecurringEventKind `db:"kind"`
 	SourceAccountID string             `db:"source_account_id"`
-	Title           string             `db:"title"`
-	Amount          decimal.Decimal    `db:"amount"`
-	Description     *string            `db:"description"`
+	// DestAccountID is the account a transfer event pays into; nil for bills
+	// and funds.
+	DestAccountID *string         `db:"dest_account_id"`
+	Title         string          `db:"title"`
+	Amount        decimal.Decimal `db:"amount"`
+	Description   *string         `db:"description"`
 
 	Frequency     RecurringFrequency `db:"frequency"`
 	IntervalCount int                `db:"interval_count"`
@@ -168,10 +413,92 @@ type RecurringEvent struct {
 }
 
 type Category struct {
-	ID          string    `db:"id"`
-	AccountID   string    `db:"account_id"`
+	ID        string `db:"id"`
+	AccountID string `db:"account_id"`
+	// ParentID nests the category under another of the account's
+	// categories. Nil for a top-level category.
+	ParentID    *string   `db:"parent_id"`
 	Name        string    `db:"name"`
 	Description *string   `db:"description"`
 	CreatedAt   time.Time `db:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at"`
 }
+
+// MaxCategoryDepth is how many levels a category tree can have: a top-level
+// category, its children and their children.
+const MaxCategoryDepth = 3
+
+// CategoryNode is a category placed in its account's tree.
+type CategoryNode struct {
+	*Category
+	// Depth is 0 for a top-level category, 1 for its children, and so on.
+	Depth       int
+	HasChildren bool
+}
+
+// CategoryTree orders categories depth first, each parent followed by its
+// children, keeping the given order among siblings. A category whose parent
+// is not in the list is treated as top-level.
+func CategoryTree(categories []*Category) []CategoryNode {
+	byID := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = true
+	}
+	children := map[string][]*Category{}
+	var roots []*Category
+	for _, c := range categories {
+		if c.ParentID != nil && byID[*c.ParentID] && *c.ParentID != c.ID {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+			continue
+		}
+		roots = append(roots, c)
+	}
+
+	nodes := make([]CategoryNode, 0, len(categories))
+	seen := make(map[string]bool, len(categories))
+	var walk func(c *Category, depth int)
+	walk = func(c *Category, depth int) {
+		if seen[c.ID] {
+			return
+		}
+		seen[c.ID] = true
+		nodes = append(nodes, CategoryNode{Category: c, Depth: depth, HasChildren: len(children[c.ID]) > 0})
+		for _, child := range children[c.ID] {
+			walk(child, depth+1)
+		}
+	}
+	for _, c := range roots {
+		walk(c, 0)
+	}
+	return nodes
+}
+
+// CategoryAncestors maps each category's ID to its ancestors' IDs, nearest
+// parent first. Top-level categories map to an empty slice.
+func CategoryAncestors(categories []*Category) map[string][]string {
+	parent := make(map[string]string, len(categories))
+	for _, c := range categories {
+		if c.ParentID != nil {
+			parent[c.ID] = *c.ParentID
+		}
+	}
+	ancestors := make(map[string][]string, len(categories))
+	for _, c := range categories {
+		path := []string{}
+		seen := map[string]bool{c.ID: true}
+		for id, ok := parent[c.ID]; ok && !seen[id]; id, ok = parent[id] {
+			seen[id] = true
+			path = append(path, id)
+		}
+		ancestors[c.ID] = path
+	}
+	return ancestors
+}
+
+// CategorySplit attributes part of a transaction's value to one of its
+// account's categories. A transaction's splits sum to its value; a transaction
+// with a single category is one split for the whole value.
+type CategorySplit struct {
+	CategoryID string          `db:"category_id"`
+	Amount     decimal.Decimal `db:"amount"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ImportSource identifies the file format an import batch was read from.
+type ImportSource string
+
+const (
+	ImportSourceCSV ImportSource = "csv"
+	ImportSourceOFX ImportSource = "ofx"
+)
+
+// ImportBatch groups the transactions created by a single statement import so
+// the whole import can be reviewed or rolled back as one unit.
+type ImportBatch struct {
+	ID           string       `db:"id"`
+	AccountID    string       `db:"account_id"`
+	ActorID      *string      `db:"actor_id"`
+	Source       ImportSource `db:"source"`
+	Filename     string       `db:"filename"`
+	RowCount     int          `db:"row_count"`
+	CreatedAt    time.Time    `db:"created_at"`
+	RolledBackAt *time.Time   `db:"rolled_back_at"`
+	// StatementBalance is the closing (ledger) balance the bank reported, when
+	// the source carries one. BalanceAfter is the account balance right after
+	// the import; comparing the two surfaces drift.
+	StatementBalance     *decimal.Decimal `db:"statement_balance"`
+	StatementBalanceAsOf *time.Time       `db:"statement_balance_as_of"`
+	BalanceAfter         *decimal.Decimal `db:"balance_after"`
+}
+
+// IsRolledBack reports whether the batch's transactions have been removed.
+func (b *ImportBatch) IsRolledBack() bool {
+	return b.RolledBackAt != nil
+}
+
+// BalanceDrift returns the statement balance minus the account balance after
+// the import. ok is false when the source had no statement balance.
+func (b *ImportBatch) BalanceDrift() (drift decimal.Decimal, ok bool) {
+	if b.StatementBalance == nil || b.BalanceAfter == nil {
+		return decimal.Zero, false
+	}
+	return b.StatementBalance.Sub(*b.BalanceAfter), true
+}
@@ -50,12 +50,24 @@ type InvestmentTrade struct {
 	CreatedAt    time.Time           `db:"created_at"`
 }
 
+// InvestmentPrice is a holding's market price per unit on PriceDate, in the
+// account's currency.
+type InvestmentPrice struct {
+	HoldingID string          `db:"holding_id"`
+	PriceDate time.Time       `db:"price_date"`
+	Price     decimal.Decimal `db:"price"`
+	CreatedAt time.Time       `db:"created_at"`
+	UpdatedAt time.Time       `db:"updated_at"`
+}
+
 // HoldingPosition aggregates a holding with its derived figures across all
 // trades. Quantity is the net of buys minus sells. AvgCost is the weighted
 // average per-unit cost of remaining shares (reduced proportionally on sells).
-// RealizedPL is the cumulative realized profit/loss from sells.
+// RealizedPL is the cumulative realized profit/loss from sells. LatestPrice
+// is the newest recorded market price, or nil when none was recorded.
 type HoldingPosition struct {
 	Holding       InvestmentHolding
+	LatestPrice   *InvestmentPrice
 	Quantity      decimal.Decimal
 	AvgCost       decimal.Decimal
 	CostBasis     decimal.Decimal
@@ -67,6 +79,15 @@ type HoldingPosition struct {
 	TotalFees     decimal.Decimal
 }
 
+// Value is what the remaining quantity is worth: at the latest market price
+// when one was recorded, at cost basis otherwise.
+func (p *HoldingPosition) Value() decimal.Decimal {
+	if p.LatestPrice == nil {
+		return p.CostBasis
+	}
+	return p.Quantity.Mul(p.LatestPrice.Price)
+}
+
 // InvestmentAccountSummary is the rolled-up view for an investment-flagged
 // account: contribution room and YTD cash flow plus aggregate cost basis across
 // holdings.
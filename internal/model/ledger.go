@@ -0,0 +1,202 @@
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// LedgerAccountKind is where a ledger account sits in the accounting
+// equation: assets = liabilities + equity + income - expenses.
+type LedgerAccountKind string
+
+const (
+	LedgerAccountKindAsset     LedgerAccountKind = "asset"
+	LedgerAccountKindLiability LedgerAccountKind = "liability"
+	LedgerAccountKindEquity    LedgerAccountKind = "equity"
+	LedgerAccountKindIncome    LedgerAccountKind = "income"
+	LedgerAccountKindExpense   LedgerAccountKind = "expense"
+)
+
+// DebitNormal reports whether accounts of this kind grow with debits. Assets
+// and expenses do; liabilities, equity and income grow with credits.
+func (k LedgerAccountKind) DebitNormal() bool {
+	return k == LedgerAccountKindAsset || k == LedgerAccountKindExpense
+}
+
+// LedgerSystemKey names the ledger accounts every space gets on first use,
+// one per currency: Income and Expenses hold the other side of bills and
+// deposits, and Exchange the other side of each currency in a conversion.
+type LedgerSystemKey string
+
+const (
+	LedgerSystemKeyIncome   LedgerSystemKey = "income"
+	LedgerSystemKeyExpenses LedgerSystemKey = "expenses"
+	LedgerSystemKeyExchange LedgerSystemKey = "exchange"
+)
+
+// LedgerAccount is one account in a space's ledger, holding amounts in a
+// single currency. A budgit account has one per currency it has held money
+// in, linked through AccountID; system accounts have a SystemKey instead.
+type LedgerAccount struct {
+	ID        string            `db:"id"`
+	SpaceID   string            `db:"space_id"`
+	AccountID *string           `db:"account_id"`
+	SystemKey *LedgerSystemKey  `db:"system_key"`
+	Name      string            `db:"name"`
+	Kind      LedgerAccountKind `db:"kind"`
+	Currency  string            `db:"currency"`
+	CreatedAt time.Time         `db:"created_at"`
+}
+
+// JournalEntry is one balanced money movement: in each currency it touches,
+// its postings sum to zero.
+type JournalEntry struct {
+	ID         string     `db:"id"`
+	SpaceID    string     `db:"space_id"`
+	OccurredAt time.Time  `db:"occurred_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+	Postings   []*Posting `db:"-"`
+}
+
+// Imbalances is the sum of the entry's postings in each of its currencies.
+func (e *JournalEntry) Imbalances() map[string]decimal.Decimal {
+	sums := map[string]decimal.Decimal{}
+	for _, p := range e.Postings {
+		sums[p.Currency] = sums[p.Currency].Add(p.Amount)
+	}
+	return sums
+}
+
+// Balanced reports whether the postings sum to zero in every currency.
+func (e *JournalEntry) Balanced() bool {
+	for _, sum := range e.Imbalances() {
+		if !sum.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// Balance adds the postings that make the entry balance in each of its
+// currencies, against the space's system accounts. An entry in one currency
+// is a bill, a deposit or a transfer that didn't arrive whole, so what's left
+// over goes to Income or Expenses. An entry across currencies is a
+// conversion, so each currency's side goes to Currency exchange in that
+// currency. Each added posting belongs to the first transaction posted in its
+// currency.
+func (e *JournalEntry) Balance() {
+	imbalances := e.Imbalances()
+	currencies := make([]string, 0, len(imbalances))
+	for c := range imbalances {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	for _, c := range currencies {
+		imbalance := imbalances[c]
+		if imbalance.IsZero() {
+			continue
+		}
+		key := LedgerSystemKeyExchange
+		if len(imbalances) == 1 {
+			key = LedgerSystemKeyExpenses
+			if imbalance.IsPositive() {
+				key = LedgerSystemKeyIncome
+			}
+		}
+		var transactionID *string
+		for _, p := range e.Postings {
+			if p.Currency == c && p.TransactionID != nil {
+				transactionID = p.TransactionID
+				break
+			}
+		}
+		e.Postings = append(e.Postings, &Posting{
+			SystemKey: key, TransactionID: transactionID, Amount: imbalance.Neg(), Currency: c,
+		})
+	}
+}
+
+// Posting moves Amount into a ledger account, in the ledger account's
+// currency: positive amounts are debits, negative amounts credits.
+// TransactionID is the transaction the posting was written for, so editing or
+// deleting it rewrites the posting; it is nil for conversions.
+type Posting struct {
+	ID              string          `db:"id"`
+	EntryID         string          `db:"entry_id"`
+	LedgerAccountID string          `db:"ledger_account_id"`
+	TransactionID   *string         `db:"transaction_id"`
+	Amount          decimal.Decimal `db:"amount"`
+	Currency        string          `db:"currency"`
+	CreatedAt       time.Time       `db:"created_at"`
+	// AccountID or SystemKey name the ledger account of a posting that hasn't
+	// been saved yet: the budgit account's, or the space's system account,
+	// in Currency. The repository looks up LedgerAccountID from them.
+	AccountID string          `db:"-"`
+	SystemKey LedgerSystemKey `db:"-"`
+}
+
+// TransactionPosting is a transaction's own side of its journal entry: its
+// signed value on its account, in the currency the account holds it in.
+func TransactionPosting(t *Transaction, currency string) *Posting {
+	return &Posting{AccountID: t.AccountID, TransactionID: &t.ID, Amount: t.SignedValue(), Currency: currency}
+}
+
+// TransactionEntry is the journal entry of a bill or deposit: its posting on
+// its account, in the given currency, balanced against the space's Expenses
+// or Income.
+func TransactionEntry(spaceID string, t *Transaction, currency string) *JournalEntry {
+	entry := &JournalEntry{SpaceID: spaceID, OccurredAt: t.OccurredAt, Postings: []*Posting{TransactionPosting(t, currency)}}
+	entry.Balance()
+	return entry
+}
+
+// TransferEntry is the journal entry of a transfer: the withdrawal leaving
+// its account in from and the deposit arriving in to. Between currencies
+// each side balances through Currency exchange; within one, whatever didn't
+// arrive whole goes to Income or Expenses.
+func TransferEntry(spaceID string, withdrawal *Transaction, from string, deposit *Transaction, to string) *JournalEntry {
+	entry := &JournalEntry{SpaceID: spaceID, OccurredAt: withdrawal.OccurredAt, Postings: []*Posting{
+		TransactionPosting(withdrawal, from), TransactionPosting(deposit, to),
+	}}
+	entry.Balance()
+	return entry
+}
+
+// LedgerBalance is a ledger account with the sum of its postings as of some
+// date. Balance is debit-positive like Posting.Amount.
+type LedgerBalance struct {
+	LedgerAccountID string            `db:"ledger_account_id"`
+	AccountID       *string           `db:"account_id"`
+	Name            string            `db:"name"`
+	Kind            LedgerAccountKind `db:"kind"`
+	Currency        string            `db:"currency"`
+	Balance         decimal.Decimal   `db:"balance"`
+}
+
+// Debit is the balance when it is a debit balance, otherwise zero.
+func (b *LedgerBalance) Debit() decimal.Decimal {
+	if b.Balance.IsPositive() {
+		return b.Balance
+	}
+	return decimal.Zero
+}
+
+// Credit is the balance when it is a credit balance, as a positive amount,
+// otherwise zero.
+func (b *LedgerBalance) Credit() decimal.Decimal {
+	if b.Balance.IsNegative() {
+		return b.Balance.Neg()
+	}
+	return decimal.Zero
+}
+
+// Natural is the balance signed the way the account's kind normally reads:
+// debit-positive for assets and expenses, credit-positive for the rest.
+func (b *LedgerBalance) Natural() decimal.Decimal {
+	if b.Kind.DebitNormal() {
+		return b.Balance
+	}
+	return b.Balance.Neg()
+}
@@ -0,0 +1,300 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// LoanCompounding is how often a loan's nominal annual rate compounds.
+// Canadian fixed-rate mortgages compound semi-annually by law; most other
+// loans compound monthly.
+type LoanCompounding string
+
+const (
+	LoanCompoundingMonthly    LoanCompounding = "monthly"
+	LoanCompoundingSemiAnnual LoanCompounding = "semi_annual"
+	LoanCompoundingAnnual     LoanCompounding = "annual"
+)
+
+func IsValidLoanCompounding(s string) bool {
+	switch LoanCompounding(s) {
+	case LoanCompoundingMonthly, LoanCompoundingSemiAnnual, LoanCompoundingAnnual:
+		return true
+	}
+	return false
+}
+
+// PerYear is the number of compounding periods in a year.
+func (c LoanCompounding) PerYear() int {
+	switch c {
+	case LoanCompoundingSemiAnnual:
+		return 2
+	case LoanCompoundingAnnual:
+		return 1
+	default:
+		return 12
+	}
+}
+
+func (c LoanCompounding) Label() string {
+	switch c {
+	case LoanCompoundingSemiAnnual:
+		return "Semi-annually"
+	case LoanCompoundingAnnual:
+		return "Annually"
+	default:
+		return "Monthly"
+	}
+}
+
+// LoanPaymentFrequency is how often a loan is paid. The accelerated
+// frequencies pay half (or a quarter) of the monthly payment every two weeks
+// (or every week), which works out to one extra monthly payment a year.
+type LoanPaymentFrequency string
+
+const (
+	LoanPaymentMonthly             LoanPaymentFrequency = "monthly"
+	LoanPaymentSemiMonthly         LoanPaymentFrequency = "semi_monthly"
+	LoanPaymentBiweekly            LoanPaymentFrequency = "biweekly"
+	LoanPaymentAcceleratedBiweekly LoanPaymentFrequency = "accelerated_biweekly"
+	LoanPaymentWeekly              LoanPaymentFrequency = "weekly"
+	LoanPaymentAcceleratedWeekly   LoanPaymentFrequency = "accelerated_weekly"
+)
+
+var LoanPaymentFrequencies = []LoanPaymentFrequency{
+	LoanPaymentMonthly,
+	LoanPaymentSemiMonthly,
+	LoanPaymentBiweekly,
+	LoanPaymentAcceleratedBiweekly,
+	LoanPaymentWeekly,
+	LoanPaymentAcceleratedWeekly,
+}
+
+func IsValidLoanPaymentFrequency(s string) bool {
+	for _, f := range LoanPaymentFrequencies {
+		if LoanPaymentFrequency(s) == f {
+			return true
+		}
+	}
+	return false
+}
+
+// PerYear is the number of payments in a year.
+func (f LoanPaymentFrequency) PerYear() int {
+	switch f {
+	case LoanPaymentSemiMonthly:
+		return 24
+	case LoanPaymentBiweekly, LoanPaymentAcceleratedBiweekly:
+		return 26
+	case LoanPaymentWeekly, LoanPaymentAcceleratedWeekly:
+		return 52
+	default:
+		return 12
+	}
+}
+
+// MonthlyDivisor is what the monthly payment is divided by for the
+// accelerated frequencies, or 0 when the payment is amortized directly.
+func (f LoanPaymentFrequency) MonthlyDivisor() int {
+	switch f {
+	case LoanPaymentAcceleratedBiweekly:
+		return 2
+	case LoanPaymentAcceleratedWeekly:
+		return 4
+	}
+	return 0
+}
+
+// Next is the due date of the payment after one due on t. Monthly and
+// semi-monthly payments stay on the day of the month the loan started on,
+// startDay: semi-monthly ones fall on that day and fifteen days after it
+// (the 5th and the 20th for a loan started on either), and a day past the
+// end of a month falls on its last day instead.
+func (f LoanPaymentFrequency) Next(t time.Time, startDay int) time.Time {
+	switch f {
+	case LoanPaymentSemiMonthly:
+		days := f.DueDays(startDay)
+		if t.Day() <= days[0] {
+			return dayOfMonth(t, 0, days[1])
+		}
+		return dayOfMonth(t, 1, days[0])
+	case LoanPaymentBiweekly, LoanPaymentAcceleratedBiweekly:
+		return t.AddDate(0, 0, 14)
+	case LoanPaymentWeekly, LoanPaymentAcceleratedWeekly:
+		return t.AddDate(0, 0, 7)
+	default:
+		return dayOfMonth(t, 1, startDay)
+	}
+}
+
+// DueDays are the days of the month monthly and semi-monthly payments fall
+// on for a loan started on startDay, in order; a month too short for one
+// moves it to its last day. Weekly payments have none.
+func (f LoanPaymentFrequency) DueDays(startDay int) []int {
+	switch f {
+	case LoanPaymentSemiMonthly:
+		first := startDay
+		if first > 15 {
+			first -= 15
+		}
+		return []int{first, first + 15}
+	case LoanPaymentMonthly:
+		return []int{startDay}
+	default:
+		return nil
+	}
+}
+
+// dayOfMonth is the given day of the month months after t's, or that
+// month's last day when it is shorter, at t's time of day.
+func dayOfMonth(t time.Time, months, day int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func (f LoanPaymentFrequency) Label() string {
+	switch f {
+	case LoanPaymentSemiMonthly:
+		return "Semi-monthly"
+	case LoanPaymentBiweekly:
+		return "Bi-weekly"
+	case LoanPaymentAcceleratedBiweekly:
+		return "Accelerated bi-weekly"
+	case LoanPaymentWeekly:
+		return "Weekly"
+	case LoanPaymentAcceleratedWeekly:
+		return "Accelerated weekly"
+	default:
+		return "Monthly"
+	}
+}
+
+// LoanTerms are the amortization terms of a loan account.
+type LoanTerms struct {
+	AccountID string          `db:"account_id"`
+	Principal decimal.Decimal `db:"principal"`
+	// AnnualRate is the nominal yearly rate as a percentage.
+	AnnualRate         decimal.Decimal      `db:"annual_rate"`
+	Compounding        LoanCompounding      `db:"compounding"`
+	PaymentFrequency   LoanPaymentFrequency `db:"payment_frequency"`
+	AmortizationMonths int                  `db:"amortization_months"`
+	// TermMonths is how long the rate is locked in before the loan renews;
+	// nil when the term runs for the whole amortization.
+	TermMonths *int      `db:"term_months"`
+	StartDate  time.Time `db:"start_date"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// FirstPaymentDate is when the first payment is due: one payment period
+// after the loan started.
+func (t *LoanTerms) FirstPaymentDate() time.Time {
+	return t.NextPaymentDate(t.StartDate)
+}
+
+// NextPaymentDate is the due date of the payment after one due on due.
+func (t *LoanTerms) NextPaymentDate(due time.Time) time.Time {
+	return t.PaymentFrequency.Next(due, t.StartDate.Day())
+}
+
+// TermEnd is when the term runs out, or nil when it runs for the whole
+// amortization.
+func (t *LoanTerms) TermEnd() *time.Time {
+	if t.TermMonths == nil {
+		return nil
+	}
+	end := t.StartDate.AddDate(0, *t.TermMonths, 0)
+	return &end
+}
+
+// LoanPayment links a payment into a loan account to the interest charged on
+// the loan when it was made.
+type LoanPayment struct {
+	PaymentTransactionID  string  `db:"payment_transaction_id"`
+	InterestTransactionID *string `db:"interest_transaction_id"`
+	AccountID             string  `db:"account_id"`
+}
+
+// AmortizationRow is one payment in an amortization schedule.
+type AmortizationRow struct {
+	Number    int
+	DueDate   time.Time
+	Payment   decimal.Decimal
+	Interest  decimal.Decimal
+	Principal decimal.Decimal
+	// Balance is what is still owed after the payment.
+	Balance decimal.Decimal
+}
+
+// AmortizationSchedule is the payment plan of a loan from some opening
+// balance until it is paid off.
+type AmortizationSchedule struct {
+	Payment       decimal.Decimal
+	Rows          []*AmortizationRow
+	TotalInterest decimal.Decimal
+}
+
+// PayoffDate is the due date of the last payment, or the zero time when the
+// schedule is empty.
+func (s *AmortizationSchedule) PayoffDate() time.Time {
+	if len(s.Rows) == 0 {
+		return time.Time{}
+	}
+	return s.Rows[len(s.Rows)-1].DueDate
+}
+
+// BalanceAfter is what is owed once every payment due on or before t has
+// been made, starting from opening.
+func (s *AmortizationSchedule) BalanceAfter(opening decimal.Decimal, t time.Time) decimal.Decimal {
+	balance := opening
+	for _, row := range s.Rows {
+		if row.DueDate.After(t) {
+			break
+		}
+		balance = row.Balance
+	}
+	return balance
+}
+
+// LoanSplit is how one payment into a loan divided between interest and
+// principal.
+type LoanSplit struct {
+	Payment   *Transaction
+	Interest  decimal.Decimal
+	Principal decimal.Decimal
+}
+
+// LoanSummary is where a loan stands today.
+type LoanSummary struct {
+	Terms *LoanTerms
+	// Remaining is what is owed on the account now.
+	Remaining decimal.Decimal
+	// InterestPaid is the interest charged by every payment so far.
+	InterestPaid  decimal.Decimal
+	PrincipalPaid decimal.Decimal
+	// Original is the schedule from the start of the loan; Projected is the
+	// schedule for what is owed today at the regular payment.
+	Original  *AmortizationSchedule
+	Projected *AmortizationSchedule
+	// TermBalance is what the original schedule leaves owing at the end of
+	// the term, or nil when the term runs for the whole amortization.
+	TermBalance *decimal.Decimal
+	Payments    []*LoanSplit
+}
+
+// PrepaymentEffect compares the projected schedule with and without a
+// one-off extra payment made today.
+type PrepaymentEffect struct {
+	Amount          decimal.Decimal
+	Without         *AmortizationSchedule
+	With            *AmortizationSchedule
+	InterestSaved   decimal.Decimal
+	PaymentsSaved   int
+	PayoffWithout   time.Time
+	PayoffWith      time.Time
+	RemainingBefore decimal.Decimal
+}
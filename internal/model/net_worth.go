@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AccountSnapshot is an account at the end of one day, in its own currency.
+// Value is what the account added to net worth that day: the balance for
+// cash and liability accounts (negative while money is owed), and uninvested
+// cash plus holdings for investment accounts.
+type AccountSnapshot struct {
+	AccountID    string          `db:"account_id"`
+	SnapshotDate time.Time       `db:"snapshot_date"`
+	Balance      decimal.Decimal `db:"balance"`
+	Value        decimal.Decimal `db:"value"`
+	CreatedAt    time.Time       `db:"created_at"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Reconciliation is one pass of matching an account against a bank statement.
+// While open, transactions are ticked off as cleared; finalizing marks every
+// cleared transaction up to the statement date reconciled and locks it.
+type Reconciliation struct {
+	ID               string          `db:"id"`
+	AccountID        string          `db:"account_id"`
+	ActorID          *string         `db:"actor_id"`
+	StatementDate    time.Time       `db:"statement_date"`
+	StatementBalance decimal.Decimal `db:"statement_balance"`
+	// TransactionCount is how many transactions finalizing reconciled. Zero
+	// while the reconciliation is open.
+	TransactionCount int        `db:"transaction_count"`
+	CreatedAt        time.Time  `db:"created_at"`
+	FinalizedAt      *time.Time `db:"finalized_at"`
+}
+
+// IsFinalized reports whether the reconciliation has been completed.
+func (r *Reconciliation) IsFinalized() bool {
+	return r.FinalizedAt != nil
+}
+
+// ClearedThrough returns the exclusive upper bound for transactions the
+// statement covers: the start of the day after the statement date.
+func (r *Reconciliation) ClearedThrough() time.Time {
+	return r.StatementDate.AddDate(0, 0, 1)
+}
@@ -5,20 +5,41 @@ import "time"
 type SpaceAuditAction string
 
 const (
-	SpaceAuditActionRenamed                SpaceAuditAction = "space.renamed"
-	SpaceAuditActionDeleted                SpaceAuditAction = "space.deleted"
-	SpaceAuditActionMemberInvited          SpaceAuditAction = "member.invited"
-	SpaceAuditActionMemberJoined           SpaceAuditAction = "member.joined"
-	SpaceAuditActionMemberRemoved          SpaceAuditAction = "member.removed"
-	SpaceAuditActionInviteCancelled        SpaceAuditAction = "invite.cancelled"
-	SpaceAuditActionAccountCreated         SpaceAuditAction = "account.created"
-	SpaceAuditActionAccountRenamed         SpaceAuditAction = "account.renamed"
-	SpaceAuditActionAccountDeleted         SpaceAuditAction = "account.deleted"
-	SpaceAuditActionAccountCurrencyChanged SpaceAuditAction = "account.currency_changed"
-	SpaceAuditActionAccountInvestmentFlag  SpaceAuditAction = "account.investment_flag_changed"
-	SpaceAuditActionAllocationCreated      SpaceAuditAction = "allocation.created"
-	SpaceAuditActionAllocationUpdated      SpaceAuditAction = "allocation.updated"
-	SpaceAuditActionAllocationDeleted      SpaceAuditAction = "allocation.deleted"
+	SpaceAuditActionRenamed                   SpaceAuditAction = "space.renamed"
+	SpaceAuditActionDeleted                   SpaceAuditAction = "space.deleted"
+	SpaceAuditActionMemberInvited             SpaceAuditAction = "member.invited"
+	SpaceAuditActionMemberJoined              SpaceAuditAction = "member.joined"
+	SpaceAuditActionMemberRemoved             SpaceAuditAction = "member.removed"
+	SpaceAuditActionInviteCancelled           SpaceAuditAction = "invite.cancelled"
+	SpaceAuditActionAccountCreated            SpaceAuditAction = "account.created"
+	SpaceAuditActionAccountRenamed            SpaceAuditAction = "account.renamed"
+	SpaceAuditActionAccountDeleted            SpaceAuditAction = "account.deleted"
+	SpaceAuditActionAccountCurrencyChanged    SpaceAuditAction = "account.currency_changed"
+	SpaceAuditActionAccountInvestmentFlag     SpaceAuditAction = "account.investment_flag_changed"
+	SpaceAuditActionAccountKindChanged        SpaceAuditAction = "account.kind_changed"
+	SpaceAuditActionLoanTermsSet              SpaceAuditAction = "account.loan_terms_set"
+	SpaceAuditActionAccountReconciled         SpaceAuditAction = "account.reconciled"
+	SpaceAuditActionAccountBalanceRepaired    SpaceAuditAction = "account.balance_repaired"
+	SpaceAuditActionAccountArchived           SpaceAuditAction = "account.archived"
+	SpaceAuditActionAccountUnarchived         SpaceAuditAction = "account.unarchived"
+	SpaceAuditActionReportingCurrencyChanged  SpaceAuditAction = "space.reporting_currency_changed"
+	SpaceAuditActionCurrencyAdded             SpaceAuditAction = "space.currency_added"
+	SpaceAuditActionCurrencyRemoved           SpaceAuditAction = "space.currency_removed"
+	SpaceAuditActionAllocationCreated         SpaceAuditAction = "allocation.created"
+	SpaceAuditActionAllocationUpdated         SpaceAuditAction = "allocation.updated"
+	SpaceAuditActionAllocationDeleted         SpaceAuditAction = "allocation.deleted"
+	SpaceAuditActionAllocationMoneyMoved      SpaceAuditAction = "allocation.money_moved"
+	SpaceAuditActionAllocationTransferred     SpaceAuditAction = "allocation.transferred"
+	SpaceAuditActionCategoryRenamed           SpaceAuditAction = "category.renamed"
+	SpaceAuditActionCategoryMoved             SpaceAuditAction = "category.moved"
+	SpaceAuditActionCategoryMerged            SpaceAuditAction = "category.merged"
+	SpaceAuditActionTransactionsRecategorized SpaceAuditAction = "category.transactions_recategorized"
+	SpaceAuditActionCategoriesImported        SpaceAuditAction = "category.imported"
+	SpaceAuditActionCategoryLimitSet          SpaceAuditAction = "category.limit_set"
+	SpaceAuditActionEnvelopeBudgetingEnabled  SpaceAuditAction = "space.envelope_budgeting_enabled"
+	SpaceAuditActionEnvelopeBudgetingDisabled SpaceAuditAction = "space.envelope_budgeting_disabled"
+	SpaceAuditActionEnvelopeAssigned          SpaceAuditAction = "envelope.assigned"
+	SpaceAuditActionEnvelopeMoneyMoved        SpaceAuditAction = "envelope.money_moved"
 )
 
 type SpaceAuditLog struct {
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// SpaceCurrency is a currency a space defined for itself, on top of the ISO
+// 4217 list.
+type SpaceCurrency struct {
+	ID         string    `db:"id"`
+	SpaceID    string    `db:"space_id"`
+	Code       string    `db:"code"`
+	Name       string    `db:"name"`
+	Symbol     string    `db:"symbol"`
+	MinorUnits int32     `db:"minor_units"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// TransactionAttachment is a file (receipt, invoice, ...) kept alongside a
+// transaction. The bytes live in the store named by StorageBackend under
+// StorageKey; the row only holds metadata.
+type TransactionAttachment struct {
+	ID             string    `db:"id"`
+	TransactionID  string    `db:"transaction_id"`
+	Filename       string    `db:"filename"`
+	ContentType    string    `db:"content_type"`
+	SizeBytes      int64     `db:"size_bytes"`
+	StorageBackend string    `db:"storage_backend"`
+	StorageKey     string    `db:"storage_key"`
+	UploadedBy     *string   `db:"uploaded_by"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// IsImage reports whether the attachment can be previewed as an image.
+func (a *TransactionAttachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// AttachmentBlobDeletion is a stored object whose attachment row is gone and
+// which still has to be removed from its backend.
+type AttachmentBlobDeletion struct {
+	ID             int64     `db:"id"`
+	StorageBackend string    `db:"storage_backend"`
+	StorageKey     string    `db:"storage_key"`
+	QueuedAt       time.Time `db:"queued_at"`
+}
@@ -0,0 +1,17 @@
+package model
+
+// TransactionExportRow is a transaction joined with everything an export
+// needs to stand on its own: the owning account and its currency, the
+// category name, and the other half of a transfer, if any.
+type TransactionExportRow struct {
+	Transaction
+	FITID        *string `db:"fitid"`
+	AccountName  string  `db:"account_name"`
+	Currency     string  `db:"currency"`
+	CategoryName *string `db:"category_name"`
+	// TransferPairID is the other transaction of a transfer pair; nil for
+	// standalone bills and deposits.
+	TransferPairID      *string `db:"transfer_pair_id"`
+	TransferAccountID   *string `db:"transfer_account_id"`
+	TransferAccountName *string `db:"transfer_account_name"`
+}
@@ -10,11 +10,17 @@ const (
 )
 
 type Space struct {
-	ID        string    `db:"id"`
-	Name      string    `db:"name"`
-	OwnerID   string    `db:"owner_id"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID      string `db:"id"`
+	Name    string `db:"name"`
+	OwnerID string `db:"owner_id"`
+	// ReportingCurrency is the currency space-wide totals and reports are
+	// converted into.
+	ReportingCurrency string `db:"reporting_currency"`
+	// EnvelopeBudgetSince is the first month of the space's envelope budget,
+	// nil while envelope budgeting is off.
+	EnvelopeBudgetSince *time.Time `db:"envelope_budget_since"`
+	CreatedAt           time.Time  `db:"created_at"`
+	UpdatedAt           time.Time  `db:"updated_at"`
 }
 
 type SpaceMember struct {
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/model"
@@ -20,21 +21,54 @@ type AllocationConversion struct {
 
 var ErrAccountNotFound = errors.New("account not found")
 
+// ErrAccountHasBalance is returned by Archive when the account still holds
+// money and the caller didn't allow archiving it anyway.
+var ErrAccountHasBalance = errors.New("account has a balance")
+
 type AccountRepository interface {
 	Create(account *model.Account) error
 	ByID(id string) (*model.Account, error)
 	BySpaceID(spaceID string) ([]*model.Account, error)
 	Rename(id, name string) error
 	Delete(id string) error
-	// ChangeCurrency atomically updates an account's currency and balance and
-	// rewrites each provided allocation's amount/target in the new currency.
-	ChangeCurrency(accountID, newCurrency string, newBalance decimal.Decimal, allocationConversions []AllocationConversion) error
+	// ChangeCurrency atomically switches an account's currency, multiplies its
+	// balance by rate (rounded to minorUnits decimals), posts the conversion
+	// to the ledger, and rewrites each provided allocation's amount/target in
+	// the new currency. The balance is read under a row lock so a concurrent
+	// transaction can't be lost; the balance before and after conversion is
+	// returned.
+	ChangeCurrency(accountID, newCurrency string, rate decimal.Decimal, minorUnits int32, allocationConversions []AllocationConversion) (oldBalance, newBalance decimal.Decimal, err error)
+	// SetKind writes the account's kind and credit terms, and moves its
+	// ledger account between assets and liabilities to match.
+	SetKind(account *model.Account) error
+	// Archive closes the account at the given time and pauses the recurring
+	// events that move money in or out of it. Unless allowBalance is set, an
+	// account that isn't at zero fails with ErrAccountHasBalance. The balance
+	// is read under a row lock and returned.
+	Archive(id string, at time.Time, allowBalance bool) (decimal.Decimal, error)
+	// Unarchive reopens the account. Its recurring events stay paused.
+	Unarchive(id string) error
 	// SetInvestment toggles the investment flag and subtype for an account.
 	// subtype is the canonical lowercase string (e.g. "tfsa"); pass nil to clear.
 	SetInvestment(id string, isInvestment bool, subtype *string) error
 	// InvestmentAccountsByUserID returns all investment-flagged accounts the
 	// user owns, across every space the user owns.
 	InvestmentAccountsByUserID(userID string) ([]*model.Account, error)
+	// ByUserID returns every account, archived ones included, in the spaces
+	// the user is a member of, ordered by space then account name.
+	ByUserID(userID string) ([]*model.Account, error)
+	// All returns every account in every space.
+	All() ([]*model.Account, error)
+	// ListBalanceDrift returns every account whose stored balance differs from
+	// the sum of the postings on its ledger account in the account's currency,
+	// ordered by space then account name.
+	ListBalanceDrift() ([]*model.BalanceDrift, error)
+	// ListBalanceDriftBySpace is ListBalanceDrift for one space's accounts.
+	ListBalanceDriftBySpace(spaceID string) ([]*model.BalanceDrift, error)
+	// RepairBalance sets the account's balance to the sum of the postings on
+	// its ledger account in the account's currency and returns the balance
+	// before and after.
+	RepairBalance(accountID string) (oldBalance, newBalance decimal.Decimal, err error)
 }
 
 type accountRepository struct {
@@ -46,10 +80,12 @@ func NewAccountRepository(db *sqlx.DB) AccountRepository {
 }
 
 func (r *accountRepository) Create(account *model.Account) error {
-	query := `INSERT INTO accounts (id, name, space_id, currency, is_investment, investment_subtype, created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+	query := `INSERT INTO accounts (id, name, space_id, currency, is_investment, investment_subtype,
+	                                kind, credit_limit, statement_closing_day, payment_due_day, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);`
 	_, err := r.db.Exec(query, account.ID, account.Name, account.SpaceID, account.Currency,
-		account.IsInvestment, account.InvestmentSubtype, account.CreatedAt, account.UpdatedAt)
+		account.IsInvestment, account.InvestmentSubtype, account.Kind, account.CreditLimit,
+		account.StatementClosingDay, account.PaymentDueDay, account.CreatedAt, account.UpdatedAt)
 	return err
 }
 
@@ -79,10 +115,88 @@ func (r *accountRepository) Rename(id, name string) error {
 	return err
 }
 
+// Delete removes the account with its transactions and ledger account. The
+// other half of each of its transfers survives, so those journal entries are
+// rebalanced afterwards.
 func (r *accountRepository) Delete(id string) error {
-	query := `DELETE FROM accounts WHERE id = $1;`
-	_, err := r.db.Exec(query, id)
-	return err
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		var entryIDs []string
+		if err := tx.Select(&entryIDs, `
+			SELECT DISTINCT p.entry_id FROM postings p
+			JOIN ledger_accounts la ON la.id = p.ledger_account_id
+			WHERE la.account_id = $1;
+		`, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM accounts WHERE id = $1;`, id); err != nil {
+			return err
+		}
+		return rebalanceEntries(tx, entryIDs)
+	})
+}
+
+func (r *accountRepository) SetKind(account *model.Account) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(`
+			UPDATE accounts
+			SET kind = $1, credit_limit = $2, statement_closing_day = $3, payment_due_day = $4, updated_at = $5
+			WHERE id = $6;
+		`, account.Kind, account.CreditLimit, account.StatementClosingDay, account.PaymentDueDay, time.Now(), account.ID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrAccountNotFound
+		}
+		_, err = tx.Exec(`UPDATE ledger_accounts SET kind = $1 WHERE account_id = $2;`, account.Kind.LedgerKind(), account.ID)
+		return err
+	})
+}
+
+// Archive locks the account row before checking its balance, the same as
+// RepairBalance, so a transaction landing at the same time either commits
+// first and is seen, or waits until the account is archived.
+func (r *accountRepository) Archive(id string, at time.Time, allowBalance bool) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	err := WithTx(r.db, func(tx *sqlx.Tx) error {
+		if err := tx.Get(&balance, `SELECT balance FROM accounts WHERE id = $1 FOR UPDATE;`, id); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrAccountNotFound
+			}
+			return err
+		}
+		if !balance.IsZero() && !allowBalance {
+			return ErrAccountHasBalance
+		}
+		if _, err := tx.Exec(`UPDATE accounts SET archived_at = $1, updated_at = $1 WHERE id = $2;`, at, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			UPDATE recurring_events SET paused = TRUE, updated_at = $1
+			WHERE (source_account_id = $2 OR dest_account_id = $2) AND NOT paused;
+		`, at, id)
+		return err
+	})
+	return balance, err
+}
+
+func (r *accountRepository) Unarchive(id string) error {
+	res, err := r.db.Exec(`UPDATE accounts SET archived_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAccountNotFound
+	}
+	return nil
 }
 
 func (r *accountRepository) SetInvestment(id string, isInvestment bool, subtype *string) error {
@@ -115,9 +229,41 @@ func (r *accountRepository) InvestmentAccountsByUserID(userID string) ([]*model.
 	return accounts, nil
 }
 
-func (r *accountRepository) ChangeCurrency(accountID, newCurrency string, newBalance decimal.Decimal, allocationConversions []AllocationConversion) error {
-	return WithTx(r.db, func(tx *sqlx.Tx) error {
+func (r *accountRepository) ByUserID(userID string) ([]*model.Account, error) {
+	var accounts []*model.Account
+	query := `SELECT a.* FROM accounts a
+	          JOIN space_members sm ON sm.space_id = a.space_id
+	          WHERE sm.user_id = $1
+	          ORDER BY a.space_id, a.name ASC;`
+	if err := r.db.Select(&accounts, query, userID); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (r *accountRepository) All() ([]*model.Account, error) {
+	var accounts []*model.Account
+	if err := r.db.Select(&accounts, `SELECT * FROM accounts ORDER BY created_at ASC;`); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (r *accountRepository) ChangeCurrency(accountID, newCurrency string, rate decimal.Decimal, minorUnits int32, allocationConversions []AllocationConversion) (oldBalance, newBalance decimal.Decimal, err error) {
+	err = WithTx(r.db, func(tx *sqlx.Tx) error {
+		var account struct {
+			Balance  decimal.Decimal `db:"balance"`
+			Currency string          `db:"currency"`
+		}
+		if err := tx.Get(&account, `SELECT balance, currency FROM accounts WHERE id = $1 FOR UPDATE;`, accountID); err != nil {
+			return err
+		}
+		oldBalance = account.Balance
+		newBalance = oldBalance.Mul(rate).Round(minorUnits)
 		now := time.Now()
+		if err := postConversion(tx, accountID, account.Currency, newCurrency, oldBalance, newBalance, now); err != nil {
+			return err
+		}
 		if _, err := tx.Exec(
 			`UPDATE accounts SET currency = $1, balance = $2, updated_at = $3 WHERE id = $4;`,
 			newCurrency, newBalance, now, accountID,
@@ -132,6 +278,80 @@ func (r *accountRepository) ChangeCurrency(accountID, newCurrency string, newBal
 				return err
 			}
 		}
-		return nil
+		// Allocation history moves into the new currency with its allocations.
+		_, err := tx.Exec(
+			`UPDATE allocation_movements m
+			 SET amount = ROUND(m.amount::numeric * $1::numeric, $2)::text
+			 FROM allocations a
+			 WHERE a.id = m.allocation_id AND a.account_id = $3;`,
+			rate, minorUnits, accountID,
+		)
+		return err
+	})
+	return oldBalance, newBalance, err
+}
+
+// balanceDriftQuery recomputes every account's balance from the postings on
+// its ledger account in its current currency and keeps the ones that
+// disagree. Postings in a currency the account has since left were converted
+// when it changed, so they don't count. %s is an extra condition on accounts a.
+const balanceDriftQuery = `
+	SELECT a.id AS account_id, a.name AS account_name, a.space_id, a.currency,
+	       a.balance AS stored, c.computed
+	FROM accounts a
+	CROSS JOIN LATERAL (
+		SELECT COALESCE(SUM(p.amount::numeric), 0)::text AS computed
+		FROM postings p
+		JOIN ledger_accounts la ON la.id = p.ledger_account_id
+		WHERE la.account_id = a.id AND la.currency = a.currency
+	) c
+	WHERE a.balance::numeric <> c.computed::numeric AND %s
+	ORDER BY a.space_id, a.name;`
+
+func (r *accountRepository) ListBalanceDrift() ([]*model.BalanceDrift, error) {
+	drift := []*model.BalanceDrift{}
+	if err := r.db.Select(&drift, fmt.Sprintf(balanceDriftQuery, "TRUE")); err != nil {
+		return nil, err
+	}
+	return drift, nil
+}
+
+func (r *accountRepository) ListBalanceDriftBySpace(spaceID string) ([]*model.BalanceDrift, error) {
+	drift := []*model.BalanceDrift{}
+	if err := r.db.Select(&drift, fmt.Sprintf(balanceDriftQuery, "a.space_id = $1"), spaceID); err != nil {
+		return nil, err
+	}
+	return drift, nil
+}
+
+// RepairBalance locks the account row before summing. A writer that has
+// already adjusted the balance holds that lock, so the sum waits for it and
+// sees its rows; a writer that hasn't gets the lock after the repair commits
+// and applies its delta on top of the repaired balance. Either way nothing is
+// counted twice or lost.
+func (r *accountRepository) RepairBalance(accountID string) (oldBalance, newBalance decimal.Decimal, err error) {
+	err = WithTx(r.db, func(tx *sqlx.Tx) error {
+		var account struct {
+			Balance  decimal.Decimal `db:"balance"`
+			Currency string          `db:"currency"`
+		}
+		if err := tx.Get(&account, `SELECT balance, currency FROM accounts WHERE id = $1 FOR UPDATE;`, accountID); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrAccountNotFound
+			}
+			return err
+		}
+		oldBalance = account.Balance
+		if err := tx.Get(&newBalance, `
+			SELECT COALESCE(SUM(p.amount::numeric), 0)::text
+			FROM postings p
+			JOIN ledger_accounts la ON la.id = p.ledger_account_id
+			WHERE la.account_id = $1 AND la.currency = $2;
+		`, accountID, account.Currency); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE accounts SET balance = $1, updated_at = $2 WHERE id = $3;`, newBalance, time.Now(), accountID)
+		return err
 	})
+	return oldBalance, newBalance, err
 }
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type AccountSnapshotRepository interface {
+	// ReplaceFrom swaps the account's snapshots from the given day on for the
+	// given ones, keeping the older ones.
+	ReplaceFrom(accountID string, from time.Time, snapshots []*model.AccountSnapshot) error
+	// LatestDates returns the day of each account's newest snapshot, keyed
+	// by account ID. Accounts without snapshots are left out.
+	LatestDates() (map[string]time.Time, error)
+	// ByAccountIDs returns the accounts' snapshots from one day through
+	// another, ordered by account and then day.
+	ByAccountIDs(accountIDs []string, from, to time.Time) ([]*model.AccountSnapshot, error)
+}
+
+type accountSnapshotRepository struct {
+	db *sqlx.DB
+}
+
+func NewAccountSnapshotRepository(db *sqlx.DB) AccountSnapshotRepository {
+	return &accountSnapshotRepository{db: db}
+}
+
+func (r *accountSnapshotRepository) ReplaceFrom(accountID string, from time.Time, snapshots []*model.AccountSnapshot) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM account_snapshots WHERE account_id = $1 AND snapshot_date >= $2;`, accountID, from); err != nil {
+			return err
+		}
+		stmt, err := tx.Preparex(`
+			INSERT INTO account_snapshots (account_id, snapshot_date, balance, value, created_at)
+			VALUES ($1, $2, $3, $4, $5);`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, s := range snapshots {
+			if _, err := stmt.Exec(accountID, s.SnapshotDate, s.Balance, s.Value, s.CreatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *accountSnapshotRepository) LatestDates() (map[string]time.Time, error) {
+	var rows []struct {
+		AccountID string    `db:"account_id"`
+		Latest    time.Time `db:"latest"`
+	}
+	query := `SELECT account_id, MAX(snapshot_date) AS latest FROM account_snapshots GROUP BY account_id;`
+	if err := r.db.Select(&rows, query); err != nil {
+		return nil, err
+	}
+	latest := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		latest[row.AccountID] = row.Latest
+	}
+	return latest, nil
+}
+
+func (r *accountSnapshotRepository) ByAccountIDs(accountIDs []string, from, to time.Time) ([]*model.AccountSnapshot, error) {
+	snapshots := []*model.AccountSnapshot{}
+	if len(accountIDs) == 0 {
+		return snapshots, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT * FROM account_snapshots
+		WHERE account_id IN (?) AND snapshot_date >= ? AND snapshot_date <= ?
+		ORDER BY account_id, snapshot_date;`, accountIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.Select(&snapshots, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
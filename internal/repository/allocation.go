@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"github.com/jmoiron/sqlx"
@@ -12,12 +13,24 @@ import (
 var ErrAllocationNotFound = errors.New("allocation not found")
 
 type AllocationRepository interface {
-	Create(allocation *model.Allocation) error
+	// Create inserts the allocation along with the movements that explain
+	// its starting amount.
+	Create(allocation *model.Allocation, movements ...*model.AllocationMovement) error
 	ByID(id string) (*model.Allocation, error)
 	ByAccountID(accountID string) ([]*model.Allocation, error)
 	SumByAccountID(accountID string) (decimal.Decimal, error)
-	Update(id, name string, amount decimal.Decimal, target *decimal.Decimal) error
+	// Update overwrites the allocation, recording movements that explain a
+	// change of amount alongside.
+	Update(id, name string, amount decimal.Decimal, target *decimal.Decimal, movements ...*model.AllocationMovement) error
 	Delete(id string) error
+	// Move records movements and adds each one's amount to its allocation,
+	// all or none.
+	Move(movements ...*model.AllocationMovement) error
+	// Movements returns an allocation's history, newest first.
+	Movements(allocationID string) ([]*model.AllocationMovementWithActor, error)
+	// MovementsAfter returns the movements on an account's allocations that
+	// occurred after the given time.
+	MovementsAfter(accountID string, after time.Time) ([]*model.AllocationMovement, error)
 }
 
 type allocationRepository struct {
@@ -28,11 +41,15 @@ func NewAllocationRepository(db *sqlx.DB) AllocationRepository {
 	return &allocationRepository{db: db}
 }
 
-func (r *allocationRepository) Create(a *model.Allocation) error {
+func (r *allocationRepository) Create(a *model.Allocation, movements ...*model.AllocationMovement) error {
 	query := `INSERT INTO allocations (id, account_id, name, amount, target_amount, sort_order, created_at, updated_at)
 	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
-	_, err := r.db.Exec(query, a.ID, a.AccountID, a.Name, a.Amount, a.TargetAmount, a.SortOrder, a.CreatedAt, a.UpdatedAt)
-	return err
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(query, a.ID, a.AccountID, a.Name, a.Amount, a.TargetAmount, a.SortOrder, a.CreatedAt, a.UpdatedAt); err != nil {
+			return err
+		}
+		return insertAllocationMovements(tx, movements)
+	})
 }
 
 func (r *allocationRepository) ByID(id string) (*model.Allocation, error) {
@@ -61,22 +78,24 @@ func (r *allocationRepository) SumByAccountID(accountID string) (decimal.Decimal
 	return sum, nil
 }
 
-func (r *allocationRepository) Update(id, name string, amount decimal.Decimal, target *decimal.Decimal) error {
+func (r *allocationRepository) Update(id, name string, amount decimal.Decimal, target *decimal.Decimal, movements ...*model.AllocationMovement) error {
 	query := `UPDATE allocations
 	          SET name = $1, amount = $2, target_amount = $3, updated_at = CURRENT_TIMESTAMP
 	          WHERE id = $4;`
-	res, err := r.db.Exec(query, name, amount, target, id)
-	if err != nil {
-		return err
-	}
-	n, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if n == 0 {
-		return ErrAllocationNotFound
-	}
-	return nil
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(query, name, amount, target, id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrAllocationNotFound
+		}
+		return insertAllocationMovements(tx, movements)
+	})
 }
 
 func (r *allocationRepository) Delete(id string) error {
@@ -93,3 +112,61 @@ func (r *allocationRepository) Delete(id string) error {
 	}
 	return nil
 }
+
+func (r *allocationRepository) Move(movements ...*model.AllocationMovement) error {
+	query := `UPDATE allocations
+	          SET amount = (amount::numeric + $1::numeric)::text, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $2;`
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		for _, m := range movements {
+			res, err := tx.Exec(query, m.Amount, m.AllocationID)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return ErrAllocationNotFound
+			}
+		}
+		return insertAllocationMovements(tx, movements)
+	})
+}
+
+func (r *allocationRepository) Movements(allocationID string) ([]*model.AllocationMovementWithActor, error) {
+	var out []*model.AllocationMovementWithActor
+	query := `
+		SELECT m.*, u.name AS actor_name, c.name AS counterpart_name
+		FROM allocation_movements m
+		LEFT JOIN users u ON u.id = m.actor_id
+		LEFT JOIN allocations c ON c.id = m.counterpart_id
+		WHERE m.allocation_id = $1
+		ORDER BY m.occurred_at DESC, m.created_at DESC;`
+	err := r.db.Select(&out, query, allocationID)
+	return out, err
+}
+
+func (r *allocationRepository) MovementsAfter(accountID string, after time.Time) ([]*model.AllocationMovement, error) {
+	var out []*model.AllocationMovement
+	query := `
+		SELECT m.*
+		FROM allocation_movements m
+		JOIN allocations a ON a.id = m.allocation_id
+		WHERE a.account_id = $1 AND m.occurred_at > $2
+		ORDER BY m.occurred_at ASC;`
+	err := r.db.Select(&out, query, accountID, after)
+	return out, err
+}
+
+func insertAllocationMovements(tx *sqlx.Tx, movements []*model.AllocationMovement) error {
+	query := `INSERT INTO allocation_movements (id, allocation_id, kind, amount, counterpart_id, actor_id, note, occurred_at, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+	for _, m := range movements {
+		if _, err := tx.Exec(query, m.ID, m.AllocationID, m.Kind, m.Amount, m.CounterpartID, m.ActorID, m.Note, m.OccurredAt, m.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
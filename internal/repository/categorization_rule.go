@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrCategorizationRuleNotFound = errors.New("categorization rule not found")
+
+type CategorizationRuleRepository interface {
+	Create(rule *model.CategorizationRule) error
+	Update(rule *model.CategorizationRule) error
+	ByID(id string) (*model.CategorizationRule, error)
+	// ListByAccount returns the account's rules in evaluation order: ascending
+	// priority, then oldest first.
+	ListByAccount(accountID string) ([]*model.CategorizationRule, error)
+	Delete(id string) error
+}
+
+type categorizationRuleRepository struct {
+	db *sqlx.DB
+}
+
+func NewCategorizationRuleRepository(db *sqlx.DB) CategorizationRuleRepository {
+	return &categorizationRuleRepository{db: db}
+}
+
+func (r *categorizationRuleRepository) Create(rule *model.CategorizationRule) error {
+	query := `
+		INSERT INTO categorization_rules
+			(id, account_id, category_id, priority, title_match, title_pattern,
+			 min_amount, max_amount, transaction_type, rename_to, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+	`
+	_, err := r.db.Exec(query,
+		rule.ID, rule.AccountID, rule.CategoryID, rule.Priority, rule.TitleMatch, rule.TitlePattern,
+		rule.MinAmount, rule.MaxAmount, rule.TransactionType, rule.RenameTo, rule.CreatedAt, rule.UpdatedAt,
+	)
+	return err
+}
+
+func (r *categorizationRuleRepository) Update(rule *model.CategorizationRule) error {
+	query := `
+		UPDATE categorization_rules
+		SET category_id = $1, priority = $2, title_match = $3, title_pattern = $4,
+		    min_amount = $5, max_amount = $6, transaction_type = $7, rename_to = $8, updated_at = $9
+		WHERE id = $10;
+	`
+	res, err := r.db.Exec(query,
+		rule.CategoryID, rule.Priority, rule.TitleMatch, rule.TitlePattern,
+		rule.MinAmount, rule.MaxAmount, rule.TransactionType, rule.RenameTo, rule.UpdatedAt, rule.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrCategorizationRuleNotFound
+	}
+	return nil
+}
+
+func (r *categorizationRuleRepository) ByID(id string) (*model.CategorizationRule, error) {
+	rule := &model.CategorizationRule{}
+	err := r.db.Get(rule, `SELECT * FROM categorization_rules WHERE id = $1;`, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrCategorizationRuleNotFound
+	}
+	return rule, err
+}
+
+func (r *categorizationRuleRepository) ListByAccount(accountID string) ([]*model.CategorizationRule, error) {
+	rules := []*model.CategorizationRule{}
+	query := `SELECT * FROM categorization_rules WHERE account_id = $1 ORDER BY priority ASC, created_at ASC;`
+	if err := r.db.Select(&rules, query, accountID); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *categorizationRuleRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM categorization_rules WHERE id = $1;`, id)
+	return err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"github.com/jmoiron/sqlx"
@@ -14,8 +15,33 @@ type CategoryRepository interface {
 	ByID(id string) (*model.Category, error)
 	// Create inserts a fully-populated category.
 	Create(c *model.Category) error
-	// Delete removes a category by ID. Its transaction links cascade.
+	// CreateMany inserts several categories in one SQL transaction. Parents
+	// must come before their children.
+	CreateMany(cats []*model.Category) error
+	// Update saves a category's name, description and parent. A new name
+	// takes the envelope budget's assignments with it (see rekeyEnvelope).
+	Update(c *model.Category) error
+	// Merge moves every transaction link and categorization rule from source
+	// to target, re-parents source's children under target and deletes
+	// source, all in one SQL transaction. A transaction split between both
+	// keeps one split with their amounts added, and source's envelope
+	// assignments fold into target's (see rekeyEnvelope). Source's limits and
+	// closed months move to target where target has none of its own.
+	// Returns the IDs of the transactions that were linked to source.
+	Merge(sourceID, targetID string, updatedAt time.Time) ([]string, error)
+	// Delete removes a category by ID, moving its children up to its parent,
+	// and unlinks its transactions as unlinkCategories describes. Its closed
+	// limit months stay behind under its name.
 	Delete(id string) error
+	// DeleteTree removes a category together with every category beneath it,
+	// unlinking their transactions as unlinkCategories describes.
+	DeleteTree(id string) error
+	// ListNamesBySpace returns the distinct names of the categories across a
+	// space's accounts, compared case-insensitively and ordered by name.
+	ListNamesBySpace(spaceID string) ([]string, error)
+	// ListNamesByMember is ListNamesBySpace across every space the user is a
+	// member of.
+	ListNamesByMember(userID string) ([]string, error)
 }
 
 type categoryRepository struct {
@@ -48,14 +74,253 @@ func (r *categoryRepository) ByID(id string) (*model.Category, error) {
 
 func (r *categoryRepository) Create(c *model.Category) error {
 	_, err := r.db.Exec(
-		`INSERT INTO categories (id, account_id, name, description, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6);`,
-		c.ID, c.AccountID, c.Name, c.Description, c.CreatedAt, c.UpdatedAt,
+		`INSERT INTO categories (id, account_id, parent_id, name, description, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7);`,
+		c.ID, c.AccountID, c.ParentID, c.Name, c.Description, c.CreatedAt, c.UpdatedAt,
 	)
 	return err
 }
 
+func (r *categoryRepository) CreateMany(cats []*model.Category) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		for _, c := range cats {
+			if _, err := tx.Exec(
+				`INSERT INTO categories (id, account_id, parent_id, name, description, created_at, updated_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7);`,
+				c.ID, c.AccountID, c.ParentID, c.Name, c.Description, c.CreatedAt, c.UpdatedAt,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *categoryRepository) Update(c *model.Category) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		var oldName string
+		if err := tx.Get(&oldName, `SELECT name FROM categories WHERE id = $1 FOR UPDATE;`, c.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`UPDATE categories SET parent_id = $1, name = $2, description = $3, updated_at = $4 WHERE id = $5;`,
+			c.ParentID, c.Name, c.Description, c.UpdatedAt, c.ID,
+		); err != nil {
+			return err
+		}
+		return rekeyEnvelope(tx, c.AccountID, oldName, c.Name, c.UpdatedAt)
+	})
+}
+
+func (r *categoryRepository) Merge(sourceID, targetID string, updatedAt time.Time) ([]string, error) {
+	var moved []string
+	err := WithTx(r.db, func(tx *sqlx.Tx) error {
+		var source, target model.Category
+		if err := tx.Get(&source, `SELECT * FROM categories WHERE id = $1 FOR UPDATE;`, sourceID); err != nil {
+			return err
+		}
+		if err := tx.Get(&target, `SELECT * FROM categories WHERE id = $1;`, targetID); err != nil {
+			return err
+		}
+		moved = []string{}
+		if err := tx.Select(&moved, `SELECT transaction_id FROM transaction_categories WHERE category_id = $1;`, sourceID); err != nil {
+			return err
+		}
+		// A transaction split between both categories folds the source's
+		// share into the target's split.
+		if _, err := tx.Exec(`
+			UPDATE transaction_categories t
+			SET amount = (t.amount::numeric + s.amount::numeric)::text, updated_at = $3
+			FROM transaction_categories s
+			WHERE t.category_id = $2 AND s.category_id = $1 AND s.transaction_id = t.transaction_id;`,
+			sourceID, targetID, updatedAt,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			DELETE FROM transaction_categories
+			WHERE category_id = $1
+			  AND transaction_id IN (SELECT transaction_id FROM transaction_categories WHERE category_id = $2);`,
+			sourceID, targetID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`UPDATE transaction_categories SET category_id = $2, updated_at = $3 WHERE category_id = $1;`,
+			sourceID, targetID, updatedAt,
+		); err != nil {
+			return err
+		}
+		// A split left on its own covers the whole value again, the same as
+		// linkSplits stores a lone split.
+		if _, err := tx.Exec(`
+			UPDATE transaction_categories tc SET amount = NULL
+			WHERE category_id = $1 AND amount IS NOT NULL
+			  AND NOT EXISTS (
+			      SELECT 1 FROM transaction_categories o
+			      WHERE o.transaction_id = tc.transaction_id AND o.category_id <> tc.category_id
+			  );`,
+			targetID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`UPDATE categorization_rules SET category_id = $2, updated_at = $3 WHERE category_id = $1;`,
+			sourceID, targetID, updatedAt,
+		); err != nil {
+			return err
+		}
+		// The target takes the source's place if it sat beneath it.
+		if _, err := tx.Exec(
+			`UPDATE categories SET parent_id = (SELECT parent_id FROM categories WHERE id = $1), updated_at = $3
+			 WHERE id = $2 AND parent_id = $1;`,
+			sourceID, targetID, updatedAt,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`UPDATE categories SET parent_id = $2, updated_at = $3 WHERE parent_id = $1;`,
+			sourceID, targetID, updatedAt,
+		); err != nil {
+			return err
+		}
+		// Source's limits cover the months before target's first limit, and
+		// its closed months move over unless target closed the same month.
+		// Target's own record wins there, since its spending already counts
+		// source's when source sat beneath it.
+		if _, err := tx.Exec(`
+			UPDATE category_limits s SET category_id = $2, updated_at = $3
+			WHERE s.category_id = $1
+			  AND NOT EXISTS (
+			      SELECT 1 FROM category_limits t
+			      WHERE t.category_id = $2 AND t.effective_month <= s.effective_month
+			  );`,
+			sourceID, targetID, updatedAt,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE category_limit_months s SET category_id = $2, category_name = $3
+			WHERE s.category_id = $1
+			  AND NOT EXISTS (
+			      SELECT 1 FROM category_limit_months t WHERE t.category_id = $2 AND t.month = s.month
+			  );`,
+			sourceID, targetID, target.Name,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM category_limit_months WHERE category_id = $1;`, sourceID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM categories WHERE id = $1;`, sourceID); err != nil {
+			return err
+		}
+		return rekeyEnvelope(tx, source.AccountID, source.Name, target.Name, updatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
 func (r *categoryRepository) Delete(id string) error {
-	_, err := r.db.Exec(`DELETE FROM categories WHERE id = $1;`, id)
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		if err := unlinkCategories(tx, []string{id}); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`UPDATE categories SET parent_id = (SELECT parent_id FROM categories WHERE id = $1), updated_at = $2
+			 WHERE parent_id = $1;`,
+			id, time.Now(),
+		); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM categories WHERE id = $1;`, id)
+		return err
+	})
+}
+
+func (r *categoryRepository) DeleteTree(id string) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		var ids []string
+		if err := tx.Select(&ids, `
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE id = $1
+				UNION ALL
+				SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+			)
+			SELECT id FROM subtree;`, id); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := unlinkCategories(tx, ids); err != nil {
+			return err
+		}
+		query, args, err := sqlx.In(`DELETE FROM categories WHERE id IN (?);`, ids)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(tx.Rebind(query), args...)
+		return err
+	})
+}
+
+// unlinkCategories removes the transaction links of categories about to be
+// deleted. A transaction filed only under them becomes uncategorized. A split
+// transaction left with a single split has it cover the whole value again,
+// the same as Merge leaves a lone split; one left with several keeps their
+// amounts, and the share that was removed counts as uncategorized.
+func unlinkCategories(tx *sqlx.Tx, categoryIDs []string) error {
+	query, args, err := sqlx.In(`
+		DELETE FROM transaction_categories WHERE category_id IN (?)
+		RETURNING transaction_id;`, categoryIDs)
+	if err != nil {
+		return err
+	}
+	var unlinked []string
+	if err := tx.Select(&unlinked, tx.Rebind(query), args...); err != nil {
+		return err
+	}
+	if len(unlinked) == 0 {
+		return nil
+	}
+	query, args, err = sqlx.In(`
+		UPDATE transaction_categories tc SET amount = NULL, updated_at = ?
+		WHERE tc.transaction_id IN (?) AND tc.amount IS NOT NULL
+		  AND NOT EXISTS (
+		      SELECT 1 FROM transaction_categories o
+		      WHERE o.transaction_id = tc.transaction_id AND o.category_id <> tc.category_id
+		  );`, time.Now(), unlinked)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(tx.Rebind(query), args...)
 	return err
 }
+
+func (r *categoryRepository) ListNamesBySpace(spaceID string) ([]string, error) {
+	return r.listNames(`a.space_id = $1`, spaceID)
+}
+
+func (r *categoryRepository) ListNamesByMember(userID string) ([]string, error) {
+	return r.listNames(`a.space_id IN (SELECT space_id FROM space_members WHERE user_id = $1)`, userID)
+}
+
+func (r *categoryRepository) listNames(scope string, arg any) ([]string, error) {
+	names := []string{}
+	query := `
+		SELECT name FROM (
+			SELECT DISTINCT ON (lower(c.name)) c.name
+			FROM categories c
+			JOIN accounts a ON a.id = c.account_id
+			WHERE ` + scope + `
+			ORDER BY lower(c.name), c.name
+		) n
+		ORDER BY lower(name);`
+	if err := r.db.Select(&names, query, arg); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type CategoryLimitRepository interface {
+	// Set stores a limit, replacing the one the category already has for the
+	// same effective month.
+	Set(limit *model.CategoryLimit) error
+	// ByAccountID returns every limit of the account, past and future,
+	// ordered by category and then effective month.
+	ByAccountID(accountID string) ([]*model.CategoryLimit, error)
+	// AccountIDs returns the accounts that have at least one limit.
+	AccountIDs() ([]string, error)
+	// CloseMonths records finished months. Months already recorded are left
+	// untouched.
+	CloseMonths(months []*model.CategoryMonth) error
+	// ClosedMonths returns the account's recorded months from one month
+	// through another, newest month first. Months of deleted categories are
+	// included with a nil CategoryID.
+	ClosedMonths(accountID string, from, to time.Time) ([]*model.CategoryMonth, error)
+}
+
+type categoryLimitRepository struct {
+	db *sqlx.DB
+}
+
+func NewCategoryLimitRepository(db *sqlx.DB) CategoryLimitRepository {
+	return &categoryLimitRepository{db: db}
+}
+
+func (r *categoryLimitRepository) Set(limit *model.CategoryLimit) error {
+	query := `
+		INSERT INTO category_limits (id, account_id, category_id, effective_month, amount, rollover, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (category_id, effective_month) DO UPDATE
+		SET amount = EXCLUDED.amount, rollover = EXCLUDED.rollover, updated_at = EXCLUDED.updated_at;`
+	_, err := r.db.Exec(query, limit.ID, limit.AccountID, limit.CategoryID, limit.EffectiveMonth, limit.Amount, limit.Rollover, limit.CreatedAt, limit.UpdatedAt)
+	return err
+}
+
+func (r *categoryLimitRepository) ByAccountID(accountID string) ([]*model.CategoryLimit, error) {
+	limits := []*model.CategoryLimit{}
+	query := `SELECT * FROM category_limits WHERE account_id = $1 ORDER BY category_id, effective_month;`
+	if err := r.db.Select(&limits, query, accountID); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func (r *categoryLimitRepository) AccountIDs() ([]string, error) {
+	ids := []string{}
+	if err := r.db.Select(&ids, `SELECT DISTINCT account_id FROM category_limits ORDER BY account_id;`); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *categoryLimitRepository) CloseMonths(months []*model.CategoryMonth) error {
+	if len(months) == 0 {
+		return nil
+	}
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		stmt, err := tx.Preparex(`
+			INSERT INTO category_limit_months (account_id, category_id, category_name, month, limit_amount, carried_in, spent, rollover, closed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (category_id, month) DO NOTHING;`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, m := range months {
+			if _, err := stmt.Exec(m.AccountID, m.CategoryID, m.CategoryName, m.Month, m.Limit, m.CarriedIn, m.Spent, m.Rollover, m.ClosedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *categoryLimitRepository) ClosedMonths(accountID string, from, to time.Time) ([]*model.CategoryMonth, error) {
+	months := []*model.CategoryMonth{}
+	query := `
+		SELECT * FROM category_limit_months
+		WHERE account_id = $1 AND month >= $2 AND month <= $3
+		ORDER BY month DESC, category_id, category_name;`
+	if err := r.db.Select(&months, query, accountID, from, to); err != nil {
+		return nil, err
+	}
+	return months, nil
+}
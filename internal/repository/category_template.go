@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type CategoryTemplateRepository interface {
+	// Create inserts a template together with its items in one SQL
+	// transaction. Items must come parents first.
+	Create(t *model.CategoryTemplate, items []*model.CategoryTemplateItem) error
+	// ByID returns a single template, or (nil, nil) if it does not exist.
+	ByID(id string) (*model.CategoryTemplate, error)
+	// BySpaceID returns the space's templates, ordered by name.
+	BySpaceID(spaceID string) ([]*model.CategoryTemplate, error)
+	// Items returns a template's items, ordered by name.
+	Items(templateID string) ([]*model.CategoryTemplateItem, error)
+	// ItemsBySpaceID returns the items of every template in the space,
+	// ordered by name.
+	ItemsBySpaceID(spaceID string) ([]*model.CategoryTemplateItem, error)
+	// ItemByID returns a single item, or (nil, nil) if it does not exist.
+	ItemByID(id string) (*model.CategoryTemplateItem, error)
+	// AddItem inserts an item into its template.
+	AddItem(item *model.CategoryTemplateItem) error
+	// DeleteItem removes an item and every item nested beneath it.
+	DeleteItem(id string) error
+	// Delete removes a template and its items.
+	Delete(id string) error
+}
+
+const insertCategoryTemplateItemQuery = `
+	INSERT INTO category_template_items (id, template_id, parent_id, name, description, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6);`
+
+type categoryTemplateRepository struct {
+	db *sqlx.DB
+}
+
+func NewCategoryTemplateRepository(db *sqlx.DB) CategoryTemplateRepository {
+	return &categoryTemplateRepository{db: db}
+}
+
+func (r *categoryTemplateRepository) Create(t *model.CategoryTemplate, items []*model.CategoryTemplateItem) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(
+			`INSERT INTO category_templates (id, space_id, name, created_at, updated_at) VALUES ($1, $2, $3, $4, $5);`,
+			t.ID, t.SpaceID, t.Name, t.CreatedAt, t.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if _, err := tx.Exec(
+				insertCategoryTemplateItemQuery,
+				item.ID, item.TemplateID, item.ParentID, item.Name, item.Description, item.CreatedAt,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *categoryTemplateRepository) ByID(id string) (*model.CategoryTemplate, error) {
+	t := &model.CategoryTemplate{}
+	if err := r.db.Get(t, `SELECT * FROM category_templates WHERE id = $1;`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *categoryTemplateRepository) BySpaceID(spaceID string) ([]*model.CategoryTemplate, error) {
+	templates := []*model.CategoryTemplate{}
+	query := `SELECT * FROM category_templates WHERE space_id = $1 ORDER BY lower(name);`
+	if err := r.db.Select(&templates, query, spaceID); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *categoryTemplateRepository) Items(templateID string) ([]*model.CategoryTemplateItem, error) {
+	items := []*model.CategoryTemplateItem{}
+	query := `SELECT * FROM category_template_items WHERE template_id = $1 ORDER BY name;`
+	if err := r.db.Select(&items, query, templateID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *categoryTemplateRepository) ItemsBySpaceID(spaceID string) ([]*model.CategoryTemplateItem, error) {
+	items := []*model.CategoryTemplateItem{}
+	query := `
+		SELECT i.* FROM category_template_items i
+		JOIN category_templates t ON t.id = i.template_id
+		WHERE t.space_id = $1
+		ORDER BY i.name;`
+	if err := r.db.Select(&items, query, spaceID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *categoryTemplateRepository) ItemByID(id string) (*model.CategoryTemplateItem, error) {
+	item := &model.CategoryTemplateItem{}
+	if err := r.db.Get(item, `SELECT * FROM category_template_items WHERE id = $1;`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *categoryTemplateRepository) AddItem(item *model.CategoryTemplateItem) error {
+	_, err := r.db.Exec(
+		insertCategoryTemplateItemQuery,
+		item.ID, item.TemplateID, item.ParentID, item.Name, item.Description, item.CreatedAt,
+	)
+	return err
+}
+
+func (r *categoryTemplateRepository) DeleteItem(id string) error {
+	_, err := r.db.Exec(`DELETE FROM category_template_items WHERE id = $1;`, id)
+	return err
+}
+
+func (r *categoryTemplateRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM category_templates WHERE id = $1;`, id)
+	return err
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+type EnvelopeRepository interface {
+	// Assignments returns the space's assignments for the months from
+	// through to, oldest first.
+	Assignments(spaceID string, from, to time.Time) ([]*model.EnvelopeAssignment, error)
+	// Assign sets what is assigned to an envelope for a month.
+	Assign(a *model.EnvelopeAssignment) error
+	// Move takes amount out of one envelope's assignment for the month and
+	// adds it to another's, together.
+	Move(spaceID, from, to string, month time.Time, amount decimal.Decimal) error
+}
+
+type envelopeRepository struct {
+	db *sqlx.DB
+}
+
+func NewEnvelopeRepository(db *sqlx.DB) EnvelopeRepository {
+	return &envelopeRepository{db: db}
+}
+
+func (r *envelopeRepository) Assignments(spaceID string, from, to time.Time) ([]*model.EnvelopeAssignment, error) {
+	var assignments []*model.EnvelopeAssignment
+	query := `
+		SELECT * FROM envelope_assignments
+		WHERE space_id = $1 AND month >= $2 AND month <= $3
+		ORDER BY month, envelope;`
+	err := r.db.Select(&assignments, query, spaceID, from, to)
+	return assignments, err
+}
+
+func (r *envelopeRepository) Assign(a *model.EnvelopeAssignment) error {
+	query := `
+		INSERT INTO envelope_assignments (space_id, envelope, month, amount, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (space_id, envelope, month)
+		DO UPDATE SET amount = EXCLUDED.amount, updated_at = EXCLUDED.updated_at;`
+	_, err := r.db.Exec(query, a.SpaceID, a.Envelope, a.Month, a.Amount, a.UpdatedAt)
+	return err
+}
+
+func (r *envelopeRepository) Move(spaceID, from, to string, month time.Time, amount decimal.Decimal) error {
+	query := `
+		INSERT INTO envelope_assignments (space_id, envelope, month, amount, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (space_id, envelope, month)
+		DO UPDATE SET amount = (envelope_assignments.amount::numeric + EXCLUDED.amount::numeric)::text,
+		              updated_at = EXCLUDED.updated_at;`
+	now := time.Now()
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(query, spaceID, from, month, amount.Neg(), now); err != nil {
+			return err
+		}
+		_, err := tx.Exec(query, spaceID, to, month, amount, now)
+		return err
+	})
+}
+
+// rekeyEnvelope folds what is assigned to the envelope named from into the
+// one named to, after a category on the account was renamed from one to the
+// other or merged away. An envelope is a name across the space, so nothing
+// moves while another category, or an investment or loan account, still
+// goes by the old name.
+func rekeyEnvelope(tx *sqlx.Tx, accountID, from, to string, at time.Time) error {
+	from, to = strings.ToLower(strings.TrimSpace(from)), strings.ToLower(strings.TrimSpace(to))
+	if from == to {
+		return nil
+	}
+	var spaceID string
+	if err := tx.Get(&spaceID, `SELECT space_id FROM accounts WHERE id = $1;`, accountID); err != nil {
+		return err
+	}
+	var taken bool
+	if err := tx.Get(&taken, `
+		SELECT EXISTS (
+			SELECT 1 FROM accounts a
+			LEFT JOIN categories c ON c.account_id = a.id
+			WHERE a.space_id = $1 AND CASE
+				WHEN a.is_investment OR a.kind = 'loan' THEN lower(btrim(a.name)) = $2
+				ELSE lower(btrim(c.name)) = $2
+			END
+		);`, spaceID, from); err != nil {
+		return err
+	}
+	if taken {
+		return nil
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO envelope_assignments (space_id, envelope, month, amount, updated_at)
+		SELECT space_id, $3, month, amount, $4 FROM envelope_assignments
+		WHERE space_id = $1 AND envelope = $2
+		ON CONFLICT (space_id, envelope, month)
+		DO UPDATE SET amount = (envelope_assignments.amount::numeric + EXCLUDED.amount::numeric)::text,
+		              updated_at = EXCLUDED.updated_at;`,
+		spaceID, from, to, at,
+	); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM envelope_assignments WHERE space_id = $1 AND envelope = $2;`, spaceID, from)
+	return err
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+type ExchangeRateRepository interface {
+	// Upsert stores rates, one per space, pair and day. An existing rate for
+	// the same day is replaced unless it was recorded in the space and the
+	// new one is imported. Returns how many rates were written.
+	Upsert(rates []*model.ExchangeRate) (int, error)
+	ByID(id string) (*model.ExchangeRate, error)
+	// List returns the space's rates, newest day first.
+	List(spaceID string, limit, offset int) ([]*model.ExchangeRate, error)
+	Count(spaceID string) (int, error)
+	// ForCurrencies returns every rate of the space with at least one side in
+	// currencies, ordered by pair and then day. That covers the direct pairs
+	// between them and every one-step path through another currency.
+	ForCurrencies(spaceID string, currencies []string) ([]*model.ExchangeRate, error)
+	Delete(id string) error
+}
+
+type exchangeRateRepository struct {
+	db *sqlx.DB
+}
+
+func NewExchangeRateRepository(db *sqlx.DB) ExchangeRateRepository {
+	return &exchangeRateRepository{db: db}
+}
+
+func (r *exchangeRateRepository) Upsert(rates []*model.ExchangeRate) (int, error) {
+	written := 0
+	err := WithTx(r.db, func(tx *sqlx.Tx) error {
+		stmt, err := tx.Preparex(`
+			INSERT INTO exchange_rates (id, space_id, base_currency, quote_currency, rate_date, rate, source, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (space_id, base_currency, quote_currency, rate_date) DO UPDATE
+			SET rate = EXCLUDED.rate, source = EXCLUDED.source, updated_at = EXCLUDED.updated_at
+			WHERE EXCLUDED.source NOT IN ('ecb', 'boc') OR exchange_rates.source IN ('ecb', 'boc');`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, rate := range rates {
+			res, err := stmt.Exec(rate.ID, rate.SpaceID, rate.BaseCurrency, rate.QuoteCurrency, rate.RateDate,
+				rate.Rate, rate.Source, rate.CreatedAt, rate.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			written += int(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+func (r *exchangeRateRepository) ByID(id string) (*model.ExchangeRate, error) {
+	rate := &model.ExchangeRate{}
+	err := r.db.Get(rate, `SELECT * FROM exchange_rates WHERE id = $1;`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExchangeRateNotFound
+	}
+	return rate, err
+}
+
+func (r *exchangeRateRepository) List(spaceID string, limit, offset int) ([]*model.ExchangeRate, error) {
+	rates := []*model.ExchangeRate{}
+	query := `
+		SELECT * FROM exchange_rates
+		WHERE space_id = $1
+		ORDER BY rate_date DESC, base_currency, quote_currency
+		LIMIT $2 OFFSET $3;`
+	if err := r.db.Select(&rates, query, spaceID, limit, offset); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (r *exchangeRateRepository) Count(spaceID string) (int, error) {
+	var count int
+	err := r.db.Get(&count, `SELECT COUNT(*) FROM exchange_rates WHERE space_id = $1;`, spaceID)
+	return count, err
+}
+
+func (r *exchangeRateRepository) ForCurrencies(spaceID string, currencies []string) ([]*model.ExchangeRate, error) {
+	rates := []*model.ExchangeRate{}
+	if len(currencies) == 0 {
+		return rates, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT * FROM exchange_rates
+		WHERE space_id = ? AND (base_currency IN (?) OR quote_currency IN (?))
+		ORDER BY base_currency, quote_currency, rate_date;`, spaceID, currencies, currencies)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.Select(&rates, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (r *exchangeRateRepository) Delete(id string) error {
+	res, err := r.db.Exec(`DELETE FROM exchange_rates WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrExchangeRateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrImportBatchNotFound = errors.New("import batch not found")
+
+// ImportBatchRepository reads import batches. Batches are written alongside
+// their transactions by TransactionRepository.ImportAtomic so the batch row and
+// its rows land (or fail) together.
+type ImportBatchRepository interface {
+	ByID(id string) (*model.ImportBatch, error)
+	ListByAccount(accountID string, limit int) ([]*model.ImportBatch, error)
+}
+
+type importBatchRepository struct {
+	db *sqlx.DB
+}
+
+func NewImportBatchRepository(db *sqlx.DB) ImportBatchRepository {
+	return &importBatchRepository{db: db}
+}
+
+func (r *importBatchRepository) ByID(id string) (*model.ImportBatch, error) {
+	b := &model.ImportBatch{}
+	err := r.db.Get(b, `SELECT * FROM import_batches WHERE id = $1;`, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrImportBatchNotFound
+	}
+	return b, err
+}
+
+func (r *importBatchRepository) ListByAccount(accountID string, limit int) ([]*model.ImportBatch, error) {
+	var out []*model.ImportBatch
+	query := `SELECT * FROM import_batches WHERE account_id = $1 ORDER BY created_at DESC LIMIT $2;`
+	err := r.db.Select(&out, query, accountID, limit)
+	return out, err
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrInvestmentPriceNotFound = errors.New("investment price not found")
+
+type InvestmentPriceRepository interface {
+	// Upsert stores the price, replacing one already recorded for the same
+	// holding and day.
+	Upsert(p *model.InvestmentPrice) error
+	// ByHoldingID returns the holding's prices, oldest day first.
+	ByHoldingID(holdingID string) ([]*model.InvestmentPrice, error)
+	Delete(holdingID string, day time.Time) error
+}
+
+type investmentPriceRepository struct {
+	db *sqlx.DB
+}
+
+func NewInvestmentPriceRepository(db *sqlx.DB) InvestmentPriceRepository {
+	return &investmentPriceRepository{db: db}
+}
+
+func (r *investmentPriceRepository) Upsert(p *model.InvestmentPrice) error {
+	query := `
+		INSERT INTO investment_prices (holding_id, price_date, price, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (holding_id, price_date) DO UPDATE
+		SET price = EXCLUDED.price, updated_at = EXCLUDED.updated_at;`
+	_, err := r.db.Exec(query, p.HoldingID, p.PriceDate, p.Price, p.CreatedAt, p.UpdatedAt)
+	return err
+}
+
+func (r *investmentPriceRepository) ByHoldingID(holdingID string) ([]*model.InvestmentPrice, error) {
+	prices := []*model.InvestmentPrice{}
+	query := `SELECT * FROM investment_prices WHERE holding_id = $1 ORDER BY price_date ASC;`
+	if err := r.db.Select(&prices, query, holdingID); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (r *investmentPriceRepository) Delete(holdingID string, day time.Time) error {
+	res, err := r.db.Exec(`DELETE FROM investment_prices WHERE holding_id = $1 AND price_date = $2;`, holdingID, day)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvestmentPriceNotFound
+	}
+	return nil
+}
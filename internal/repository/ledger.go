@@ -0,0 +1,392 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+type LedgerRepository interface {
+	// Balances returns every ledger account in the space with the sum of the
+	// postings on entries that occurred before the given time, ordered by
+	// currency, then assets, liabilities, equity, income, expenses, then by
+	// name. Accounts without postings are included with a zero balance,
+	// except an account's ledger accounts in currencies it no longer holds.
+	Balances(spaceID string, before time.Time) ([]*model.LedgerBalance, error)
+}
+
+type ledgerRepository struct {
+	db *sqlx.DB
+}
+
+func NewLedgerRepository(db *sqlx.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+func (r *ledgerRepository) Balances(spaceID string, before time.Time) ([]*model.LedgerBalance, error) {
+	balances := []*model.LedgerBalance{}
+	query := `
+		SELECT la.id AS ledger_account_id, la.account_id, COALESCE(a.name, la.name) AS name, la.kind, la.currency,
+		       COALESCE(SUM(p.amount::numeric), 0)::text AS balance
+		FROM ledger_accounts la
+		LEFT JOIN accounts a ON a.id = la.account_id
+		LEFT JOIN (
+			SELECT p.ledger_account_id, p.amount
+			FROM postings p
+			JOIN journal_entries je ON je.id = p.entry_id
+			WHERE je.space_id = $1 AND je.occurred_at < $2
+		) p ON p.ledger_account_id = la.id
+		WHERE la.space_id = $1
+		GROUP BY la.id, a.name, a.currency
+		HAVING la.account_id IS NULL OR la.currency = a.currency OR COALESCE(SUM(p.amount::numeric), 0) <> 0
+		ORDER BY la.currency, CASE la.kind
+			WHEN 'asset' THEN 1 WHEN 'liability' THEN 2 WHEN 'equity' THEN 3 WHEN 'income' THEN 4 ELSE 5
+		END, lower(COALESCE(a.name, la.name));`
+	if err := r.db.Select(&balances, query, spaceID, before); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// ErrUnbalancedEntry is returned when a journal entry's postings would not
+// sum to zero in each of its currencies.
+var ErrUnbalancedEntry = errors.New("journal entry does not balance")
+
+// ErrAccountCurrencyChanged is returned when an account's currency changes
+// while money is being posted to it. Trying again posts in the new currency.
+var ErrAccountCurrencyChanged = errors.New("account currency changed")
+
+// saveEntry writes an entry the service built, looking up the ledger account
+// each posting names. An entry for transactions that were already posted
+// replaces the one they were posted under and keeps its ID. A transaction
+// stays in the currency it was first posted in, and a new one is posted in
+// its account's currency; a posting in any other currency means the account
+// changed currency after the service read it, and ErrAccountCurrencyChanged
+// is returned.
+func saveEntry(tx *sqlx.Tx, deltas balanceDeltas, entry *model.JournalEntry) error {
+	var ids []string
+	for _, p := range entry.Postings {
+		if p.AccountID != "" && p.TransactionID != nil {
+			ids = append(ids, *p.TransactionID)
+		}
+	}
+	posted, err := postedTransactions(tx, ids)
+	if err != nil {
+		return err
+	}
+	currencies := map[string]string{}
+	for _, p := range posted {
+		if entry.ID == "" {
+			entry.ID = p.EntryID
+		}
+		currencies[p.TransactionID] = p.Currency
+	}
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	for _, p := range entry.Postings {
+		if p.LedgerAccountID != "" {
+			continue
+		}
+		if p.AccountID == "" {
+			if p.LedgerAccountID, err = systemLedgerAccount(tx, entry.SpaceID, p.SystemKey, p.Currency); err != nil {
+				return err
+			}
+			continue
+		}
+		var currency string
+		if p.TransactionID != nil {
+			currency = currencies[*p.TransactionID]
+		}
+		la, err := accountLedgerAccount(tx, p.AccountID, currency)
+		if err != nil {
+			return err
+		}
+		if la.Currency != p.Currency {
+			return ErrAccountCurrencyChanged
+		}
+		p.LedgerAccountID = la.ID
+	}
+	return insertEntry(tx, deltas, entry)
+}
+
+// postedTransaction is a transaction's posting on its account: the entry it
+// was posted under and the currency it was posted in.
+type postedTransaction struct {
+	EntryID       string `db:"entry_id"`
+	TransactionID string `db:"transaction_id"`
+	Currency      string `db:"currency"`
+}
+
+func postedTransactions(q sqlx.Queryer, transactionIDs []string) ([]postedTransaction, error) {
+	posted := []postedTransaction{}
+	if len(transactionIDs) == 0 {
+		return posted, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT p.entry_id, p.transaction_id, p.currency
+		FROM postings p
+		JOIN ledger_accounts la ON la.id = p.ledger_account_id
+		WHERE la.account_id IS NOT NULL AND p.transaction_id IN (?);`, transactionIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlx.Select(q, &posted, sqlx.Rebind(sqlx.DOLLAR, query), args...); err != nil {
+		return nil, err
+	}
+	return posted, nil
+}
+
+// insertEntry writes entry and replaces its postings, counting both the
+// postings it removes and the ones it writes into deltas. The entry is
+// upserted so a rewrite keeps its ID. deltas may be nil when the caller sets
+// the account balances itself.
+func insertEntry(tx *sqlx.Tx, deltas balanceDeltas, entry *model.JournalEntry) error {
+	if !entry.Balanced() {
+		return ErrUnbalancedEntry
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO journal_entries (id, space_id, occurred_at, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET occurred_at = EXCLUDED.occurred_at;
+	`, entry.ID, entry.SpaceID, entry.OccurredAt, entry.CreatedAt); err != nil {
+		return err
+	}
+	if err := deltas.count(tx, true, entry.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM postings WHERE entry_id = $1;`, entry.ID); err != nil {
+		return err
+	}
+	for _, p := range entry.Postings {
+		if err := insertPosting(tx, entry, p); err != nil {
+			return err
+		}
+	}
+	return deltas.count(tx, false, entry.ID)
+}
+
+func insertPosting(tx *sqlx.Tx, entry *model.JournalEntry, p *model.Posting) error {
+	p.ID = uuid.NewString()
+	p.EntryID = entry.ID
+	p.CreatedAt = entry.CreatedAt
+	_, err := tx.Exec(
+		`INSERT INTO postings (id, entry_id, ledger_account_id, transaction_id, amount, currency, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7);`,
+		p.ID, p.EntryID, p.LedgerAccountID, p.TransactionID, p.Amount, p.Currency, p.CreatedAt,
+	)
+	return err
+}
+
+// deleteEntries removes the entries the transactions matching where (a
+// condition on transactions t) were posted under, with all their postings,
+// and reverses them in deltas. The transactions are locked first, so a
+// concurrent delete of the same rows waits and then finds nothing left to
+// reverse. Call it before deleting the transactions themselves.
+func deleteEntries(tx *sqlx.Tx, deltas balanceDeltas, where string, args ...any) error {
+	if _, err := tx.Exec(`SELECT t.id FROM transactions t WHERE `+where+` FOR UPDATE;`, args...); err != nil {
+		return err
+	}
+	var entryIDs []string
+	if err := tx.Select(&entryIDs, `
+		SELECT DISTINCT p.entry_id FROM postings p
+		JOIN transactions t ON t.id = p.transaction_id
+		WHERE `+where+`;`, args...); err != nil {
+		return err
+	}
+	if len(entryIDs) == 0 {
+		return nil
+	}
+	if err := deltas.count(tx, true, entryIDs...); err != nil {
+		return err
+	}
+	query, args, err := sqlx.In(`DELETE FROM journal_entries WHERE id IN (?);`, entryIDs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(tx.Rebind(query), args...)
+	return err
+}
+
+// rebalanceEntries restores the balance of entries that lost postings, which
+// happens when one account of a transfer is deleted: the system side of each
+// entry is booked again for the postings that survive, so the remaining half
+// reads like a plain deposit or bill. Entries left without postings are
+// removed.
+func rebalanceEntries(tx *sqlx.Tx, entryIDs []string) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+		DELETE FROM postings p USING ledger_accounts la
+		WHERE la.id = p.ledger_account_id AND la.system_key IS NOT NULL AND p.entry_id IN (?);`, entryIDs)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	query, args, err = sqlx.In(`
+		DELETE FROM journal_entries je
+		WHERE je.id IN (?) AND NOT EXISTS (SELECT 1 FROM postings p WHERE p.entry_id = je.id);`, entryIDs)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	var entries []*model.JournalEntry
+	query, args, err = sqlx.In(`SELECT * FROM journal_entries WHERE id IN (?) ORDER BY id;`, entryIDs)
+	if err != nil {
+		return err
+	}
+	if err := tx.Select(&entries, tx.Rebind(query), args...); err != nil {
+		return err
+	}
+	var postings []*model.Posting
+	query, args, err = sqlx.In(`SELECT * FROM postings WHERE entry_id IN (?) ORDER BY created_at, id;`, entryIDs)
+	if err != nil {
+		return err
+	}
+	if err := tx.Select(&postings, tx.Rebind(query), args...); err != nil {
+		return err
+	}
+	byEntry := map[string]*model.JournalEntry{}
+	for _, e := range entries {
+		byEntry[e.ID] = e
+	}
+	for _, p := range postings {
+		e := byEntry[p.EntryID]
+		e.Postings = append(e.Postings, p)
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		kept := len(e.Postings)
+		e.Balance()
+		e.CreatedAt = now
+		for _, p := range e.Postings[kept:] {
+			if p.LedgerAccountID, err = systemLedgerAccount(tx, e.SpaceID, p.SystemKey, p.Currency); err != nil {
+				return err
+			}
+			if err := insertPosting(tx, e, p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// postConversion records an account changing currency as one journal entry:
+// the old balance leaves its ledger account in the old currency and the
+// converted balance arrives in the one for the new currency, each side
+// balanced through the space's Exchange account in that currency. The new
+// ledger account ends up holding exactly newBalance, so the stored balance
+// the caller writes agrees with the postings.
+func postConversion(tx *sqlx.Tx, accountID, from, to string, oldBalance, newBalance decimal.Decimal, at time.Time) error {
+	source, err := accountLedgerAccount(tx, accountID, from)
+	if err != nil {
+		return err
+	}
+	target, err := accountLedgerAccount(tx, accountID, to)
+	if err != nil {
+		return err
+	}
+	var held decimal.Decimal
+	if err := tx.Get(&held,
+		`SELECT COALESCE(SUM(amount::numeric), 0)::text FROM postings WHERE ledger_account_id = $1;`, target.ID,
+	); err != nil {
+		return err
+	}
+
+	entry := &model.JournalEntry{ID: uuid.NewString(), SpaceID: source.SpaceID, OccurredAt: at, CreatedAt: at}
+	for _, side := range []struct {
+		la     *model.LedgerAccount
+		amount decimal.Decimal
+	}{{source, oldBalance.Neg()}, {target, newBalance.Sub(held)}} {
+		if side.amount.IsZero() {
+			continue
+		}
+		exchangeID, err := systemLedgerAccount(tx, entry.SpaceID, model.LedgerSystemKeyExchange, side.la.Currency)
+		if err != nil {
+			return err
+		}
+		entry.Postings = append(entry.Postings,
+			&model.Posting{LedgerAccountID: side.la.ID, Amount: side.amount, Currency: side.la.Currency},
+			&model.Posting{LedgerAccountID: exchangeID, Amount: side.amount.Neg(), Currency: side.la.Currency},
+		)
+	}
+	if len(entry.Postings) == 0 {
+		return nil
+	}
+	return insertEntry(tx, nil, entry)
+}
+
+// accountLedgerAccount returns the ledger account holding a budgit account's
+// money in the given currency, or in the account's own currency when currency
+// is empty, creating it on first use as an asset or liability to match the
+// account's kind. The insert doesn't lock an existing row, so concurrent
+// writers to the account don't queue on it.
+func accountLedgerAccount(tx *sqlx.Tx, accountID, currency string) (*model.LedgerAccount, error) {
+	var account struct {
+		SpaceID  string            `db:"space_id"`
+		Name     string            `db:"name"`
+		Kind     model.AccountKind `db:"kind"`
+		Currency string            `db:"currency"`
+	}
+	if err := tx.Get(&account, `SELECT space_id, name, kind, currency FROM accounts WHERE id = $1;`, accountID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	if currency == "" {
+		currency = account.Currency
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO ledger_accounts (id, space_id, account_id, name, kind, currency, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (account_id, currency) DO NOTHING;
+	`, uuid.NewString(), account.SpaceID, accountID, account.Name, account.Kind.LedgerKind(), currency, time.Now()); err != nil {
+		return nil, err
+	}
+	la := &model.LedgerAccount{}
+	err := tx.Get(la, `SELECT * FROM ledger_accounts WHERE account_id = $1 AND currency = $2;`, accountID, currency)
+	return la, err
+}
+
+// systemLedgerAccount returns one of the space's system ledger accounts in
+// the given currency, creating it on first use. The insert doesn't lock an
+// existing row, so concurrent writers in the same space don't queue on it.
+func systemLedgerAccount(tx *sqlx.Tx, spaceID string, key model.LedgerSystemKey, currency string) (string, error) {
+	name, kind := "Income", model.LedgerAccountKindIncome
+	switch key {
+	case model.LedgerSystemKeyExpenses:
+		name, kind = "Expenses", model.LedgerAccountKindExpense
+	case model.LedgerSystemKeyExchange:
+		name, kind = "Currency exchange", model.LedgerAccountKindEquity
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO ledger_accounts (id, space_id, system_key, name, kind, currency, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (space_id, system_key, currency) DO NOTHING;
+	`, uuid.NewString(), spaceID, key, name, kind, currency, time.Now()); err != nil {
+		return "", err
+	}
+	var id string
+	err := tx.Get(&id,
+		`SELECT id FROM ledger_accounts WHERE space_id = $1 AND system_key = $2 AND currency = $3;`,
+		spaceID, key, currency,
+	)
+	return id, err
+}
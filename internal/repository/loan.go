@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+var ErrLoanTermsNotFound = errors.New("loan terms not found")
+
+type LoanRepository interface {
+	// SetTerms saves the terms and turns their account into a loan, clearing
+	// any credit terms, in a single SQL transaction.
+	SetTerms(terms *model.LoanTerms) error
+	TermsByAccountID(accountID string) (*model.LoanTerms, error)
+	// LastPaymentAt returns when the latest payment into the loan at or
+	// before at was made, or nil when there is none.
+	LastPaymentAt(accountID string, at time.Time) (*time.Time, error)
+	// Splits returns the payments into the loan with the interest each one
+	// charged, newest first.
+	Splits(accountID string) ([]*model.LoanSplit, error)
+}
+
+type loanRepository struct {
+	db *sqlx.DB
+}
+
+func NewLoanRepository(db *sqlx.DB) LoanRepository {
+	return &loanRepository{db: db}
+}
+
+func (r *loanRepository) SetTerms(terms *model.LoanTerms) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(`
+			UPDATE accounts
+			SET kind = $1, credit_limit = NULL, statement_closing_day = NULL, payment_due_day = NULL, updated_at = $2
+			WHERE id = $3;
+		`, model.AccountKindLoan, terms.UpdatedAt, terms.AccountID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrAccountNotFound
+		}
+		if _, err := tx.Exec(
+			`UPDATE ledger_accounts SET kind = $1 WHERE account_id = $2;`,
+			model.AccountKindLoan.LedgerKind(), terms.AccountID,
+		); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO loan_terms
+				(account_id, principal, annual_rate, compounding, payment_frequency,
+				 amortization_months, term_months, start_date, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (account_id) DO UPDATE SET
+				principal = EXCLUDED.principal,
+				annual_rate = EXCLUDED.annual_rate,
+				compounding = EXCLUDED.compounding,
+				payment_frequency = EXCLUDED.payment_frequency,
+				amortization_months = EXCLUDED.amortization_months,
+				term_months = EXCLUDED.term_months,
+				start_date = EXCLUDED.start_date,
+				updated_at = EXCLUDED.updated_at;
+		`,
+			terms.AccountID, terms.Principal, terms.AnnualRate, terms.Compounding, terms.PaymentFrequency,
+			terms.AmortizationMonths, terms.TermMonths, terms.StartDate, terms.CreatedAt, terms.UpdatedAt,
+		)
+		return err
+	})
+}
+
+func (r *loanRepository) TermsByAccountID(accountID string) (*model.LoanTerms, error) {
+	terms := &model.LoanTerms{}
+	err := r.db.Get(terms, `SELECT * FROM loan_terms WHERE account_id = $1;`, accountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLoanTermsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+func (r *loanRepository) LastPaymentAt(accountID string, at time.Time) (*time.Time, error) {
+	var last *time.Time
+	err := r.db.Get(&last, `
+		SELECT MAX(t.occurred_at)
+		FROM loan_payments lp
+		JOIN transactions t ON t.id = lp.payment_transaction_id
+		WHERE lp.account_id = $1 AND t.occurred_at <= $2;
+	`, accountID, at)
+	return last, err
+}
+
+func (r *loanRepository) Splits(accountID string) ([]*model.LoanSplit, error) {
+	var rows []struct {
+		model.Transaction
+		Interest decimal.Decimal `db:"interest"`
+	}
+	err := r.db.Select(&rows, `
+		SELECT t.id, t.value, t.type, t.account_id, t.title, t.description, t.status,
+		       t.occurred_at, t.created_at, t.updated_at,
+		       COALESCE(i.value, '0') AS interest
+		FROM loan_payments lp
+		JOIN transactions t ON t.id = lp.payment_transaction_id
+		LEFT JOIN transactions i ON i.id = lp.interest_transaction_id
+		WHERE lp.account_id = $1
+		ORDER BY t.occurred_at DESC, t.created_at DESC;
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	splits := make([]*model.LoanSplit, 0, len(rows))
+	for i := range rows {
+		payment := rows[i].Transaction
+		splits = append(splits, &model.LoanSplit{
+			Payment:   &payment,
+			Interest:  rows[i].Interest,
+			Principal: payment.Value.Sub(rows[i].Interest),
+		})
+	}
+	return splits, nil
+}
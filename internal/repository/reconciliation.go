@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrReconciliationNotFound = errors.New("reconciliation not found")
+
+// ReconciliationRepository stores reconciliation sessions. Finalizing is done
+// by TransactionRepository.ReconcileAtomic so the session and the transactions
+// it locks change together.
+type ReconciliationRepository interface {
+	Create(rec *model.Reconciliation) error
+	ByID(id string) (*model.Reconciliation, error)
+	// OpenByAccount returns the account's in-progress reconciliation, or
+	// ErrReconciliationNotFound when there is none.
+	OpenByAccount(accountID string) (*model.Reconciliation, error)
+	// ListFinalizedByAccount returns the account's completed reconciliations,
+	// latest statement first.
+	ListFinalizedByAccount(accountID string, limit int) ([]*model.Reconciliation, error)
+	// DeleteOpen removes an in-progress reconciliation. Finalized ones are
+	// history and are left untouched.
+	DeleteOpen(id string) error
+}
+
+type reconciliationRepository struct {
+	db *sqlx.DB
+}
+
+func NewReconciliationRepository(db *sqlx.DB) ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+func (r *reconciliationRepository) Create(rec *model.Reconciliation) error {
+	query := `
+		INSERT INTO reconciliations
+			(id, account_id, actor_id, statement_date, statement_balance, transaction_count, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+	_, err := r.db.Exec(query,
+		rec.ID, rec.AccountID, rec.ActorID, rec.StatementDate, rec.StatementBalance, rec.TransactionCount, rec.CreatedAt,
+	)
+	return err
+}
+
+func (r *reconciliationRepository) ByID(id string) (*model.Reconciliation, error) {
+	rec := &model.Reconciliation{}
+	err := r.db.Get(rec, `SELECT * FROM reconciliations WHERE id = $1;`, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrReconciliationNotFound
+	}
+	return rec, err
+}
+
+func (r *reconciliationRepository) OpenByAccount(accountID string) (*model.Reconciliation, error) {
+	rec := &model.Reconciliation{}
+	err := r.db.Get(rec, `SELECT * FROM reconciliations WHERE account_id = $1 AND finalized_at IS NULL;`, accountID)
+	if err == sql.ErrNoRows {
+		return nil, ErrReconciliationNotFound
+	}
+	return rec, err
+}
+
+func (r *reconciliationRepository) ListFinalizedByAccount(accountID string, limit int) ([]*model.Reconciliation, error) {
+	var out []*model.Reconciliation
+	query := `
+		SELECT * FROM reconciliations
+		WHERE account_id = $1 AND finalized_at IS NOT NULL
+		ORDER BY statement_date DESC, finalized_at DESC
+		LIMIT $2;
+	`
+	err := r.db.Select(&out, query, accountID, limit)
+	return out, err
+}
+
+func (r *reconciliationRepository) DeleteOpen(id string) error {
+	_, err := r.db.Exec(`DELETE FROM reconciliations WHERE id = $1 AND finalized_at IS NULL;`, id)
+	return err
+}
@@ -13,6 +13,8 @@ var ErrRecurringEventNotFound = errors.New("recurring event not found")
 
 type RecurringEventRepository interface {
 	Create(e *model.RecurringEvent) error
+	// CreateMany inserts several events in one SQL transaction.
+	CreateMany(events []*model.RecurringEvent) error
 	ByID(id string) (*model.RecurringEvent, error)
 	BySpaceID(spaceID string) ([]*model.RecurringEvent, error)
 	ByAccountID(accountID string) ([]*model.RecurringEvent, error)
@@ -32,22 +34,37 @@ func NewRecurringEventRepository(db *sqlx.DB) RecurringEventRepository {
 }
 
 func (r *recurringEventRepository) Create(e *model.RecurringEvent) error {
+	return insertRecurringEvent(r.db, e)
+}
+
+func (r *recurringEventRepository) CreateMany(events []*model.RecurringEvent) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		for _, e := range events {
+			if err := insertRecurringEvent(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func insertRecurringEvent(db sqlx.Execer, e *model.RecurringEvent) error {
 	query := `INSERT INTO recurring_events (
         id, space_id, kind, source_account_id, title, amount, description,
         frequency, interval_count, day_of_week, day_of_month, month_of_year,
         fire_hour, fire_minute, timezone, business_days_only,
-        next_run_at, last_run_at, paused, created_at, updated_at
+        next_run_at, last_run_at, paused, created_at, updated_at, dest_account_id
     ) VALUES (
         $1, $2, $3, $4, $5, $6, $7,
         $8, $9, $10, $11, $12,
         $13, $14, $15, $16,
-        $17, $18, $19, $20, $21
+        $17, $18, $19, $20, $21, $22
     );`
-	_, err := r.db.Exec(query,
+	_, err := db.Exec(query,
 		e.ID, e.SpaceID, e.Kind, e.SourceAccountID, e.Title, e.Amount, e.Description,
 		e.Frequency, e.IntervalCount, e.DayOfWeek, e.DayOfMonth, e.MonthOfYear,
 		e.FireHour, e.FireMinute, e.Timezone, e.BusinessDaysOnly,
-		e.NextRunAt, e.LastRunAt, e.Paused, e.CreatedAt, e.UpdatedAt,
+		e.NextRunAt, e.LastRunAt, e.Paused, e.CreatedAt, e.UpdatedAt, e.DestAccountID,
 	)
 	return err
 }
@@ -70,7 +87,7 @@ func (r *recurringEventRepository) BySpaceID(spaceID string) ([]*model.Recurring
 func (r *recurringEventRepository) ByAccountID(accountID string) ([]*model.RecurringEvent, error) {
 	var out []*model.RecurringEvent
 	query := `SELECT * FROM recurring_events
-	          WHERE source_account_id = $1
+	          WHERE source_account_id = $1 OR dest_account_id = $1
 	          ORDER BY created_at DESC;`
 	err := r.db.Select(&out, query, accountID)
 	return out, err
@@ -90,13 +107,13 @@ func (r *recurringEventRepository) Update(e *model.RecurringEvent) error {
         kind = $1, source_account_id = $2, title = $3, amount = $4, description = $5,
         frequency = $6, interval_count = $7, day_of_week = $8, day_of_month = $9, month_of_year = $10,
         fire_hour = $11, fire_minute = $12, timezone = $13, business_days_only = $14,
-        next_run_at = $15, paused = $16, updated_at = CURRENT_TIMESTAMP
-        WHERE id = $17;`
+        next_run_at = $15, paused = $16, dest_account_id = $17, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $18;`
 	res, err := r.db.Exec(query,
 		e.Kind, e.SourceAccountID, e.Title, e.Amount, e.Description,
 		e.Frequency, e.IntervalCount, e.DayOfWeek, e.DayOfMonth, e.MonthOfYear,
 		e.FireHour, e.FireMinute, e.Timezone, e.BusinessDaysOnly,
-		e.NextRunAt, e.Paused, e.ID,
+		e.NextRunAt, e.Paused, e.DestAccountID, e.ID,
 	)
 	if err != nil {
 		return err
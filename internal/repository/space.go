@@ -25,6 +25,10 @@ type SpaceRepository interface {
 	GetMembers(spaceID string) ([]*model.SpaceMemberWithProfile, error)
 	GetMember(spaceID string, userID string) (*model.SpaceMember, error)
 	UpdateName(spaceID, name string) error
+	SetReportingCurrency(spaceID, currency string) error
+	// SetEnvelopeBudgetSince turns envelope budgeting on from the given
+	// month, or off when since is nil.
+	SetEnvelopeBudgetSince(spaceID string, since *time.Time) error
 	GetMemberCount(spaceID string) (int, error)
 
 	Delete(spaceID string) error
@@ -46,8 +50,8 @@ func (r *spaceRepository) Create(space *model.Space) error {
 	defer tx.Rollback()
 
 	// Insert Space
-	querySpace := `INSERT INTO spaces (id, name, owner_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5);`
-	_, err = tx.Exec(querySpace, space.ID, space.Name, space.OwnerID, space.CreatedAt, space.UpdatedAt)
+	querySpace := `INSERT INTO spaces (id, name, owner_id, reporting_currency, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err = tx.Exec(querySpace, space.ID, space.Name, space.OwnerID, space.ReportingCurrency, space.CreatedAt, space.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -177,6 +181,18 @@ func (r *spaceRepository) UpdateName(spaceID, name string) error {
 	return err
 }
 
+func (r *spaceRepository) SetReportingCurrency(spaceID, currency string) error {
+	query := `UPDATE spaces SET reporting_currency = $1, updated_at = $2 WHERE id = $3;`
+	_, err := r.db.Exec(query, currency, time.Now(), spaceID)
+	return err
+}
+
+func (r *spaceRepository) SetEnvelopeBudgetSince(spaceID string, since *time.Time) error {
+	query := `UPDATE spaces SET envelope_budget_since = $1, updated_at = $2 WHERE id = $3;`
+	_, err := r.db.Exec(query, since, time.Now(), spaceID)
+	return err
+}
+
 func (r *spaceRepository) Delete(spaceID string) error {
 	query := `DELETE FROM spaces WHERE id = $1;`
 	_, err := r.db.Exec(query, spaceID)
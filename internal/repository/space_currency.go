@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrSpaceCurrencyNotFound  = errors.New("space currency not found")
+	ErrDuplicateSpaceCurrency = errors.New("space already has a currency with this code")
+)
+
+type SpaceCurrencyRepository interface {
+	Create(c *model.SpaceCurrency) error
+	ByID(id string) (*model.SpaceCurrency, error)
+	// BySpaceID returns the space's own currencies, sorted by code.
+	BySpaceID(spaceID string) ([]*model.SpaceCurrency, error)
+	Delete(id string) error
+}
+
+type spaceCurrencyRepository struct {
+	db *sqlx.DB
+}
+
+func NewSpaceCurrencyRepository(db *sqlx.DB) SpaceCurrencyRepository {
+	return &spaceCurrencyRepository{db: db}
+}
+
+func (r *spaceCurrencyRepository) Create(c *model.SpaceCurrency) error {
+	query := `
+		INSERT INTO space_currencies (id, space_id, code, name, symbol, minor_units, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+	_, err := r.db.Exec(query, c.ID, c.SpaceID, c.Code, c.Name, c.Symbol, c.MinorUnits, c.CreatedAt, c.UpdatedAt)
+	if err != nil && strings.Contains(err.Error(), "duplicate key value") {
+		return ErrDuplicateSpaceCurrency
+	}
+	return err
+}
+
+func (r *spaceCurrencyRepository) ByID(id string) (*model.SpaceCurrency, error) {
+	c := &model.SpaceCurrency{}
+	err := r.db.Get(c, `SELECT * FROM space_currencies WHERE id = $1;`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSpaceCurrencyNotFound
+	}
+	return c, err
+}
+
+func (r *spaceCurrencyRepository) BySpaceID(spaceID string) ([]*model.SpaceCurrency, error) {
+	currencies := []*model.SpaceCurrency{}
+	err := r.db.Select(&currencies, `SELECT * FROM space_currencies WHERE space_id = $1 ORDER BY code;`, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	return currencies, nil
+}
+
+func (r *spaceCurrencyRepository) Delete(id string) error {
+	res, err := r.db.Exec(`DELETE FROM space_currencies WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSpaceCurrencyNotFound
+	}
+	return nil
+}
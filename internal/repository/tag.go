@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+type TagRepository interface {
+	// ListBySpace returns the space's tags, ordered by name.
+	ListBySpace(spaceID string) ([]*model.Tag, error)
+	// ByID returns a single tag, or (nil, nil) if it does not exist.
+	ByID(id string) (*model.Tag, error)
+	// ByIDs returns the tags with the given IDs that exist, ordered by name.
+	ByIDs(ids []string) ([]*model.Tag, error)
+	// Create inserts a fully-populated tag.
+	Create(t *model.Tag) error
+	// Rename changes a tag's name.
+	Rename(id, name string, updatedAt time.Time) error
+	// Merge moves every transaction link from source to target and deletes
+	// source, in a single SQL transaction. Transactions already carrying both
+	// keep a single link to target.
+	Merge(sourceID, targetID string) error
+	// Delete removes a tag by ID. Its transaction links cascade.
+	Delete(id string) error
+	// CountTransactionsBySpace returns how many transactions carry each tag in
+	// the space, keyed by tag ID. Unused tags are absent.
+	CountTransactionsBySpace(spaceID string) (map[string]int, error)
+	// ListByTransaction returns the tags on a transaction, ordered by name.
+	ListByTransaction(transactionID string) ([]*model.Tag, error)
+	// ListByTransactions returns the tags on each of the given transactions,
+	// keyed by transaction ID, so list pages avoid a query per row.
+	ListByTransactions(transactionIDs []string) (map[string][]*model.Tag, error)
+	// SumByTagBucket aggregates an account's transaction values by time bucket
+	// and tag, with the same transfer exclusion and granularity rules as
+	// SumByCategoryBucket. Untagged transactions are dropped.
+	SumByTagBucket(accountID string, txType model.TransactionType, from, to time.Time, granularity string) ([]TagBucketRow, error)
+	// SumTagged totals an account's tagged transactions in [from, to], counting
+	// each transaction once however many tags it carries.
+	SumTagged(accountID string, txType model.TransactionType, from, to time.Time) (decimal.Decimal, error)
+}
+
+// TagBucketRow is one (time bucket, tag) aggregate of transaction values.
+type TagBucketRow struct {
+	Bucket time.Time       `db:"bucket"`
+	TagID  string          `db:"tag_id"`
+	Total  decimal.Decimal `db:"total"`
+}
+
+type tagRepository struct {
+	db *sqlx.DB
+}
+
+func NewTagRepository(db *sqlx.DB) TagRepository {
+	return &tagRepository{db: db}
+}
+
+func (r *tagRepository) ListBySpace(spaceID string) ([]*model.Tag, error) {
+	var tags []*model.Tag
+	query := `SELECT * FROM tags WHERE space_id = $1 ORDER BY lower(name) ASC;`
+	if err := r.db.Select(&tags, query, spaceID); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *tagRepository) ByID(id string) (*model.Tag, error) {
+	t := &model.Tag{}
+	if err := r.db.Get(t, `SELECT * FROM tags WHERE id = $1;`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *tagRepository) ByIDs(ids []string) ([]*model.Tag, error) {
+	tags := []*model.Tag{}
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	query, args, err := sqlx.In(`SELECT * FROM tags WHERE id IN (?) ORDER BY lower(name) ASC;`, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.Select(&tags, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *tagRepository) Create(t *model.Tag) error {
+	_, err := r.db.Exec(
+		`INSERT INTO tags (id, name, space_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5);`,
+		t.ID, t.Name, t.SpaceID, t.CreatedAt, t.UpdatedAt,
+	)
+	return err
+}
+
+func (r *tagRepository) Rename(id, name string, updatedAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE tags SET name = $1, updated_at = $2 WHERE id = $3;`, name, updatedAt, id)
+	return err
+}
+
+func (r *tagRepository) Merge(sourceID, targetID string) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		moveLinks := `
+			INSERT INTO transaction_tags (tag_id, transaction_id, created_at)
+			SELECT $2, transaction_id, created_at FROM transaction_tags WHERE tag_id = $1
+			ON CONFLICT DO NOTHING;
+		`
+		if _, err := tx.Exec(moveLinks, sourceID, targetID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE tags SET updated_at = $1 WHERE id = $2;`, time.Now(), targetID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM tags WHERE id = $1;`, sourceID)
+		return err
+	})
+}
+
+func (r *tagRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM tags WHERE id = $1;`, id)
+	return err
+}
+
+func (r *tagRepository) CountTransactionsBySpace(spaceID string) (map[string]int, error) {
+	var rows []struct {
+		TagID string `db:"tag_id"`
+		Count int    `db:"count"`
+	}
+	query := `
+		SELECT tt.tag_id, COUNT(*) AS count
+		FROM transaction_tags tt
+		JOIN tags t ON t.id = tt.tag_id
+		WHERE t.space_id = $1
+		GROUP BY tt.tag_id;
+	`
+	if err := r.db.Select(&rows, query, spaceID); err != nil {
+		return nil, err
+	}
+	out := make(map[string]int, len(rows))
+	for _, row := range rows {
+		out[row.TagID] = row.Count
+	}
+	return out, nil
+}
+
+func (r *tagRepository) ListByTransaction(transactionID string) ([]*model.Tag, error) {
+	tags := []*model.Tag{}
+	query := `
+		SELECT t.* FROM tags t
+		JOIN transaction_tags tt ON tt.tag_id = t.id
+		WHERE tt.transaction_id = $1
+		ORDER BY lower(t.name) ASC;
+	`
+	if err := r.db.Select(&tags, query, transactionID); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *tagRepository) ListByTransactions(transactionIDs []string) (map[string][]*model.Tag, error) {
+	out := map[string][]*model.Tag{}
+	if len(transactionIDs) == 0 {
+		return out, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT tt.transaction_id, t.id, t.name, t.space_id, t.created_at, t.updated_at
+		FROM tags t
+		JOIN transaction_tags tt ON tt.tag_id = t.id
+		WHERE tt.transaction_id IN (?)
+		ORDER BY lower(t.name) ASC;
+	`, transactionIDs)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		TransactionID string `db:"transaction_id"`
+		model.Tag
+	}
+	if err := r.db.Select(&rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		tag := rows[i].Tag
+		out[rows[i].TransactionID] = append(out[rows[i].TransactionID], &tag)
+	}
+	return out, nil
+}
+
+func (r *tagRepository) SumByTagBucket(accountID string, txType model.TransactionType, from, to time.Time, granularity string) ([]TagBucketRow, error) {
+	query := `
+		SELECT date_trunc($5, t.occurred_at) AS bucket,
+		       tt.tag_id AS tag_id,
+		       COALESCE(SUM(t.value::numeric), 0)::text AS total
+		FROM transactions t
+		JOIN transaction_tags tt ON tt.transaction_id = t.id
+		WHERE t.account_id = $1
+		  AND t.type = $2
+		  AND t.occurred_at >= $3
+		  AND t.occurred_at <= $4
+		  AND NOT EXISTS (
+		      SELECT 1 FROM related_transactions r
+		      WHERE r.transaction_one_id = t.id OR r.transaction_two_id = t.id
+		  )
+		GROUP BY bucket, tt.tag_id
+		ORDER BY bucket ASC;
+	`
+	rows := []TagBucketRow{}
+	if err := r.db.Select(&rows, query, accountID, txType, from, to, granularity); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *tagRepository) SumTagged(accountID string, txType model.TransactionType, from, to time.Time) (decimal.Decimal, error) {
+	var sum decimal.Decimal
+	query := `
+		SELECT COALESCE(SUM(t.value::numeric), 0)::text
+		FROM transactions t
+		WHERE t.account_id = $1
+		  AND t.type = $2
+		  AND t.occurred_at >= $3
+		  AND t.occurred_at <= $4
+		  AND EXISTS (SELECT 1 FROM transaction_tags tt WHERE tt.transaction_id = t.id)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM related_transactions r
+		      WHERE r.transaction_one_id = t.id OR r.transaction_two_id = t.id
+		  );
+	`
+	if err := r.db.Get(&sum, query, accountID, txType, from, to); err != nil {
+		return decimal.Zero, err
+	}
+	return sum, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestTag(t *testing.T, repo TagRepository, spaceID, name string) *model.Tag {
+	t.Helper()
+	now := time.Now()
+	tag := &model.Tag{ID: uuid.NewString(), Name: name, SpaceID: spaceID, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, repo.Create(tag))
+	return tag
+}
+
+func tagTransaction(t *testing.T, repo TagRepository, txnID string, tags ...*model.Tag) {
+	t.Helper()
+	r := repo.(*tagRepository)
+	require.NoError(t, WithTx(r.db, func(tx *sqlx.Tx) error {
+		ids := make([]string, len(tags))
+		for i, tag := range tags {
+			ids[i] = tag.ID
+		}
+		return linkTags(tx, txnID, ids)
+	}))
+}
+
+func TestTagRepository_Create_EnforcesCaseInsensitiveUniqueness(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		repo := NewTagRepository(dbi.DB)
+		user := testutil.CreateTestUser(t, dbi.DB, "tag-unique@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		other := testutil.CreateTestSpace(t, dbi.DB, user.ID, "Other")
+
+		createTestTag(t, repo, space.ID, "Vacation")
+		now := time.Now()
+		err := repo.Create(&model.Tag{ID: uuid.NewString(), Name: "vacation", SpaceID: space.ID, CreatedAt: now, UpdatedAt: now})
+		assert.Error(t, err, "same name in a different case collides")
+
+		// Names are only unique within a space.
+		createTestTag(t, repo, other.ID, "Vacation")
+	})
+}
+
+func TestTagRepository_Merge_MovesLinksWithoutDuplicates(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		repo := NewTagRepository(dbi.DB)
+		user := testutil.CreateTestUser(t, dbi.DB, "tag-merge@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Acct")
+
+		trip := createTestTag(t, repo, space.ID, "Trip")
+		travel := createTestTag(t, repo, space.ID, "Travel")
+		onlyTrip := testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Hotel", model.TransactionTypeWithdrawal, decimal.NewFromInt(200))
+		both := testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Flight", model.TransactionTypeWithdrawal, decimal.NewFromInt(500))
+		tagTransaction(t, repo, onlyTrip.ID, trip)
+		tagTransaction(t, repo, both.ID, trip, travel)
+
+		require.NoError(t, repo.Merge(trip.ID, travel.ID))
+
+		gone, err := repo.ByID(trip.ID)
+		require.NoError(t, err)
+		assert.Nil(t, gone, "source tag is deleted")
+
+		byTxn, err := repo.ListByTransactions([]string{onlyTrip.ID, both.ID})
+		require.NoError(t, err)
+		require.Len(t, byTxn[onlyTrip.ID], 1)
+		assert.Equal(t, travel.ID, byTxn[onlyTrip.ID][0].ID)
+		require.Len(t, byTxn[both.ID], 1, "a transaction with both tags keeps one link")
+		assert.Equal(t, travel.ID, byTxn[both.ID][0].ID)
+
+		counts, err := repo.CountTransactionsBySpace(space.ID)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{travel.ID: 2}, counts)
+	})
+}
+
+func TestTransactionRepository_ListByAccountFiltered_ByTag(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		repo := NewTransactionRepository(dbi.DB)
+		tagRepo := NewTagRepository(dbi.DB)
+		user := testutil.CreateTestUser(t, dbi.DB, "tag-filter@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Acct")
+
+		work := createTestTag(t, tagRepo, space.ID, "Work")
+		home := createTestTag(t, tagRepo, space.ID, "Home")
+		lunch := testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Lunch", model.TransactionTypeWithdrawal, decimal.NewFromInt(15))
+		lamp := testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Lamp", model.TransactionTypeWithdrawal, decimal.NewFromInt(40))
+		testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Untagged", model.TransactionTypeWithdrawal, decimal.NewFromInt(1))
+		tagTransaction(t, tagRepo, lunch.ID, work)
+		tagTransaction(t, tagRepo, lamp.ID, home, work)
+
+		txns, err := repo.ListByAccountFiltered(account.ID, model.TransactionFilter{TagIDs: []string{home.ID}}, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, txns, 1)
+		assert.Equal(t, lamp.ID, txns[0].ID)
+
+		// Any of the given tags matches, and a doubly-tagged row appears once.
+		count, err := repo.CountByAccountFiltered(account.ID, model.TransactionFilter{TagIDs: []string{home.ID, work.ID}})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,45 +13,206 @@ import (
 )
 
 type TransactionRepository interface {
-	CreateBillAtomic(t *model.Transaction, newBalance decimal.Decimal, categoryID *string) error
-	CreateDepositAtomic(t *model.Transaction, newBalance decimal.Decimal, categoryID *string) error
-	UpdateBillAtomic(t *model.Transaction, newBalance decimal.Decimal, categoryID *string) error
-	UpdateDepositAtomic(t *model.Transaction, newBalance decimal.Decimal, categoryID *string) error
-	DeleteAtomic(transactionID, accountID string, newBalance decimal.Decimal) error
-	TransferAtomic(withdrawal, deposit *model.Transaction, sourceNewBalance, destNewBalance decimal.Decimal) error
+	// The *Atomic writers below take the journal entry the service built for
+	// each bill, deposit, transfer and imported row, and save it alongside
+	// the transactions (see saveEntry). Account balances then move by exactly
+	// the postings
+	// written and removed, computed and applied inside the same SQL
+	// transaction (see balanceDeltas), so the stored balance is a running sum
+	// of the ledger rather than a second record of it. Callers never pass a
+	// balance in, so two writers racing on one account cannot overwrite each
+	// other's update.
+
+	CreateBillAtomic(t *model.Transaction, entry *model.JournalEntry, splits []model.CategorySplit, tagIDs []string) error
+	CreateDepositAtomic(t *model.Transaction, entry *model.JournalEntry, splits []model.CategorySplit, tagIDs []string) error
+	UpdateBillAtomic(t *model.Transaction, entry *model.JournalEntry, splits []model.CategorySplit, tagIDs []string) error
+	UpdateDepositAtomic(t *model.Transaction, entry *model.JournalEntry, splits []model.CategorySplit, tagIDs []string) error
+	DeleteAtomic(transactionID string) error
+	TransferAtomic(withdrawal, deposit *model.Transaction, entry *model.JournalEntry, tagIDs []string) error
+	// LoanPaymentAtomic records a transfer into a loan account and the
+	// interest it charges as one SQL transaction. interest and interestEntry
+	// are nil when nothing accrued.
+	LoanPaymentAtomic(withdrawal, deposit *model.Transaction, entry *model.JournalEntry, interest *model.Transaction, interestEntry *model.JournalEntry, tagIDs []string) error
+	// UpdateTransferAtomic rewrites both halves of a transfer and both account
+	// balances in a single SQL transaction.
+	UpdateTransferAtomic(withdrawal, deposit *model.Transaction, entry *model.JournalEntry) error
+	// UndoTransferAtomic deletes both halves of a transfer and reverses them on
+	// both account balances in a single SQL transaction.
+	UndoTransferAtomic(withdrawal, deposit *model.Transaction) error
+	// ImportAtomic records an import batch, inserts every row tagged with the
+	// batch ID, links categories, and adjusts the account balance once, all in
+	// a single SQL transaction. The resulting balance is stored on the batch
+	// and set on batch.BalanceAfter.
+	ImportAtomic(batch *model.ImportBatch, rows []ImportedTransaction) error
+	// PostedCurrency returns the currency the transaction is posted in on its
+	// account, or an empty string for one recorded before the ledger existed.
+	PostedCurrency(transactionID string) (string, error)
+	// RollbackImportAtomic deletes every transaction still tagged with the
+	// batch, reverses them on the account balance, and marks the batch rolled
+	// back.
+	RollbackImportAtomic(batchID, accountID string, rolledBackAt time.Time) error
+	// ReconcileAtomic marks every cleared transaction on the reconciliation's
+	// account up to its statement date as reconciled and finalizes the
+	// reconciliation with the number of transactions it locked, in a single SQL
+	// transaction.
+	ReconcileAtomic(rec *model.Reconciliation, finalizedAt time.Time) (int, error)
+	// ListUncategorized returns the account's bills and deposits that have no
+	// category, oldest first. Transfer halves and reconciled transactions are
+	// left out.
+	ListUncategorized(accountID string) ([]*model.Transaction, error)
+	// CategorizeAtomic gives each transaction its category and title in one SQL
+	// transaction. Transactions categorized in the meantime are skipped; the IDs
+	// actually changed are returned.
+	CategorizeAtomic(updates []CategorizationUpdate, updatedAt time.Time) ([]string, error)
+	// RecategorizeAtomic replaces the categories of every given transaction
+	// with a single category covering its whole value, or with none when
+	// categoryID is empty, in one SQL transaction.
+	RecategorizeAtomic(transactionIDs []string, categoryID string, updatedAt time.Time) error
+	// SetStatus changes a transaction's reconciliation status.
+	SetStatus(transactionID string, status model.TransactionStatus) error
 	GetByID(id string) (*model.Transaction, error)
+	// GetCategoryID returns the category with the largest share of the
+	// transaction, or nil when it is uncategorized.
 	GetCategoryID(transactionID string) (*string, error)
+	// GetSplits returns the transaction's category splits, largest first. A
+	// single-category transaction yields one split for its whole value.
+	GetSplits(transactionID string) ([]model.CategorySplit, error)
 	GetRelatedID(transactionID string) (*string, error)
 	TransferIDsIn(ids []string) (map[string]bool, error)
-	ListByAccount(accountID string, limit, offset int) ([]*model.Transaction, error)
+	// ListByAccountBetween returns every transaction on the account whose
+	// occurred_at falls within [from, to], oldest first. Used for duplicate
+	// detection, so it is not paginated.
+	ListByAccountBetween(accountID string, from, to time.Time) ([]*model.Transaction, error)
+	// ListByImportBatch returns the transactions created by an import batch that
+	// still exist, oldest first.
+	ListByImportBatch(batchID string) ([]*model.Transaction, error)
+	// ExistingFITIDs returns the subset of fitids already recorded on the
+	// account, so a re-imported statement skips what it has already created.
+	ExistingFITIDs(accountID string, fitids []string) (map[string]bool, error)
+	// ListUnreconciledBefore returns the account's pending and cleared
+	// transactions that occurred before the given time, oldest first. These are
+	// the rows a reconciliation lets the user tick off.
+	ListUnreconciledBefore(accountID string, before time.Time) ([]*model.Transaction, error)
+	// SumReconciliation returns the signed total of the account's reconciled
+	// transactions and of its cleared transactions that occurred before the
+	// given time.
+	SumReconciliation(accountID string, before time.Time) (reconciled, cleared decimal.Decimal, err error)
+	// BalanceAsOf returns the account balance after every transaction that
+	// occurred at or before the given time, in the account's current
+	// currency: money held before a currency change counts from the
+	// conversion on.
+	BalanceAsOf(accountID string, at time.Time) (decimal.Decimal, error)
 	CountByAccount(accountID string) (int, error)
+	// ListByAccount lists the account's transactions newest first, paginated
+	// by limit/offset, each with its RunningBalance set.
+	ListByAccount(accountID string, limit, offset int) ([]*model.Transaction, error)
 	// ListByAccountFiltered lists transactions for an account narrowed by the
-	// given filter, ordered newest first, paginated by limit/offset.
+	// given filter, ordered newest first, paginated by limit/offset. Each
+	// carries the running balance of the whole account, not of the filtered
+	// rows.
 	ListByAccountFiltered(accountID string, filter model.TransactionFilter, limit, offset int) ([]*model.Transaction, error)
 	// CountByAccountFiltered counts transactions for an account matching the filter.
 	CountByAccountFiltered(accountID string, filter model.TransactionFilter) (int, error)
+	// ExportByAccount streams the account's transactions matching the filter,
+	// oldest first, calling fn once per row. Rows are read from the database
+	// as fn consumes them; an error from fn stops the stream and is returned.
+	ExportByAccount(accountID string, filter model.TransactionFilter, fn func(*model.TransactionExportRow) error) error
+	// ExportBySpace is ExportByAccount across every account in the space.
+	ExportBySpace(spaceID string, filter model.TransactionFilter, fn func(*model.TransactionExportRow) error) error
+	// SearchBySpace lists transactions across every account in the space that
+	// match the filter, newest first, paginated by limit/offset. Ties on date
+	// break on id so pages never overlap.
+	SearchBySpace(spaceID string, filter model.TransactionFilter, limit, offset int) ([]*model.TransactionSearchResult, error)
+	// CountBySpaceFiltered counts the transactions SearchBySpace would return.
+	CountBySpaceFiltered(spaceID string, filter model.TransactionFilter) (int, error)
+	// SearchByMember is SearchBySpace across every space the user is a member of.
+	SearchByMember(userID string, filter model.TransactionFilter, limit, offset int) ([]*model.TransactionSearchResult, error)
+	// CountByMemberFiltered counts the transactions SearchByMember would return.
+	CountByMemberFiltered(userID string, filter model.TransactionFilter) (int, error)
 	// SumByAccountYearType totals transaction values for an account, year,
 	// and type (deposit or withdrawal). Returns zero when no rows match.
 	SumByAccountYearType(accountID string, year int, txType model.TransactionType) (decimal.Decimal, error)
 	// SumLifetimeByAccountType totals transaction values for an account over
 	// its full history, restricted to one type.
 	SumLifetimeByAccountType(accountID string, txType model.TransactionType) (decimal.Decimal, error)
+	// SumByAccountTypeBetween totals the values of the account's transactions
+	// of one type that occurred after `after` and at or before `through`.
+	SumByAccountTypeBetween(accountID string, txType model.TransactionType, after, through time.Time) (decimal.Decimal, error)
 	// SumByCategoryBucket aggregates an account's transaction values, grouped by a
-	// time bucket (day/month/year via date_trunc) and category. Transfer halves
+	// time bucket (day/month/year via date_trunc) and category. A split
+	// transaction adds each split's amount to its own category, and whatever
+	// its splits leave uncovered (a deleted category's share) to the
+	// uncategorized row. Transfer halves
 	// are excluded (internal moves aren't spending or income). When
 	// includeUncategorized is false, rows with no category are dropped.
+	// Rows are split by the currency each transaction was posted in.
 	// Granularity must be one of "day", "month", "year".
 	SumByCategoryBucket(accountID string, txType model.TransactionType, from, to time.Time, granularity string, includeUncategorized bool) ([]CategoryBucketRow, error)
+	// SumTransfersByCounterpart aggregates the values of an account's transfer
+	// halves, grouped by a time bucket, direction (withdrawal for money sent,
+	// deposit for money received) and the account on the other side, and
+	// split by the currency each half was posted in. Granularity must be one
+	// of "day", "month", "year".
+	SumTransfersByCounterpart(accountID string, from, to time.Time, granularity string) ([]TransferBucketRow, error)
+	// DailyNetByAccounts returns the signed sum of each account's
+	// transactions per day, for the transactions that occurred before the
+	// given time, oldest day first. Days without transactions are left out.
+	DailyNetByAccounts(accountIDs []string, before time.Time) ([]AccountDayNet, error)
+	// DailyNetByAccountCurrency is DailyNetByAccounts from the accounts'
+	// ledger postings, split by the currency each amount was posted in. Money
+	// an account held before changing currency stays in the old currency
+	// until the conversion moves it into the new one.
+	DailyNetByAccountCurrency(accountIDs []string, before time.Time) ([]AccountDayNet, error)
 }
 
-// CategoryBucketRow is one (time bucket, category) aggregate of transaction
-// values. CategoryID is nil for the uncategorized group.
+// CategoryBucketRow is one (time bucket, category, currency) aggregate of
+// transaction values. CategoryID is nil for the uncategorized group.
 type CategoryBucketRow struct {
 	Bucket     time.Time       `db:"bucket"`
 	CategoryID *string         `db:"category_id"`
+	Currency   string          `db:"currency"`
 	Total      decimal.Decimal `db:"total"`
 }
 
+// TransferBucketRow is one (time bucket, direction, counterpart account,
+// currency) aggregate of an account's transfer halves.
+type TransferBucketRow struct {
+	Bucket               time.Time             `db:"bucket"`
+	Type                 model.TransactionType `db:"type"`
+	CounterpartAccountID string                `db:"counterpart_account_id"`
+	Currency             string                `db:"currency"`
+	Total                decimal.Decimal       `db:"total"`
+}
+
+// AccountDayNet is the signed total of one account's transactions on Day.
+type AccountDayNet struct {
+	AccountID string          `db:"account_id"`
+	Day       time.Time       `db:"day"`
+	Net       decimal.Decimal `db:"net"`
+	// Currency is only set by DailyNetByAccountCurrency.
+	Currency string `db:"currency"`
+}
+
+// ImportedTransaction is one row of an import batch: the transaction to insert
+// with its journal entry, plus its optional category link and the bank's
+// transaction identifier.
+type ImportedTransaction struct {
+	Transaction *model.Transaction
+	Entry       *model.JournalEntry
+	CategoryID  *string
+	// FITID is the financial institution's transaction ID from an OFX
+	// statement. Unique per account, so re-importing a statement is a no-op.
+	FITID *string
+}
+
+// CategorizationUpdate assigns a category, and possibly a new title, to an
+// uncategorized transaction.
+type CategorizationUpdate struct {
+	TransactionID string
+	CategoryID    string
+	Title         string
+}
+
 type transactionRepository struct {
 	db *sqlx.DB
 }
@@ -59,7 +221,7 @@ func NewTransactionRepository(db *sqlx.DB) TransactionRepository {
 	return &transactionRepository{db: db}
 }
 
-func (r *transactionRepository) CreateBillAtomic(t *model.Transaction, newBalance decimal.Decimal, categoryID *string) error {
+func (r *transactionRepository) CreateBillAtomic(t *model.Transaction, entry *model.JournalEntry, splits []model.CategorySplit, tagIDs []string) error {
 	return WithTx(r.db, func(tx *sqlx.Tx) error {
 		insertTxn := `
 			INSERT INTO transactions
@@ -75,23 +237,23 @@ func (r *transactionRepository) CreateBillAtomic(t *model.Transaction, newBalanc
 			return err
 		}
 
-		updateBalance := `UPDATE accounts SET balance = $1, updated_at = $2 WHERE id = $3;`
-		if _, err := tx.Exec(updateBalance, newBalance, time.Now(), t.AccountID); err != nil {
+		deltas := balanceDeltas{}
+		if err := saveEntry(tx, deltas, entry); err != nil {
+			return err
+		}
+		if err := deltas.apply(tx); err != nil {
 			return err
 		}
 
-		if categoryID != nil && *categoryID != "" {
-			linkCategory := `INSERT INTO transaction_categories (category_id, transaction_id) VALUES ($1, $2);`
-			if _, err := tx.Exec(linkCategory, *categoryID, t.ID); err != nil {
-				return err
-			}
+		if err := linkSplits(tx, t.ID, splits); err != nil {
+			return err
 		}
 
-		return nil
+		return linkTags(tx, t.ID, tagIDs)
 	})
 }
 
-func (r *transactionRepository) CreateDepositAtomic(t *model.Transaction, newBalance decimal.Decimal, categoryID *string) error {
+func (r *transactionRepository) CreateDepositAtomic(t *model.Transaction, entry *model.JournalEntry, splits []model.CategorySplit, tagIDs []string) error {
 	return WithTx(r.db, func(tx *sqlx.Tx) error {
 		insertTxn := `
 			INSERT INTO transactions
@@ -107,22 +269,23 @@ func (r *transactionRepository) CreateDepositAtomic(t *model.Transaction, newBal
 			return err
 		}
 
-		updateBalance := `UPDATE accounts SET balance = $1, updated_at = $2 WHERE id = $3;`
-		if _, err := tx.Exec(updateBalance, newBalance, time.Now(), t.AccountID); err != nil {
+		deltas := balanceDeltas{}
+		if err := saveEntry(tx, deltas, entry); err != nil {
+			return err
+		}
+		if err := deltas.apply(tx); err != nil {
 			return err
 		}
 
-		if categoryID != nil && *categoryID != "" {
-			linkCategory := `INSERT INTO transaction_categories (category_id, transaction_id) VALUES ($1, $2);`
-			if _, err := tx.Exec(linkCategory, *categoryID, t.ID); err != nil {
-				return err
-			}
+		if err := linkSplits(tx, t.ID, splits); err != nil {
+			return err
 		}
-		return nil
+
+		return linkTags(tx, t.ID, tagIDs)
 	})
 }
 
-func (r *transactionRepository) UpdateBillAtomic(t *model.Transaction, newBalance decimal.Decimal, categoryID *string) error {
+func (r *transactionRepository) UpdateBillAtomic(t *model.Transaction, entry *model.JournalEntry, splits []model.CategorySplit, tagIDs []string) error {
 	return WithTx(r.db, func(tx *sqlx.Tx) error {
 		updateTxn := `
 			UPDATE transactions
@@ -136,25 +299,29 @@ func (r *transactionRepository) UpdateBillAtomic(t *model.Transaction, newBalanc
 			return err
 		}
 
-		updateBalance := `UPDATE accounts SET balance = $1, updated_at = $2 WHERE id = $3;`
-		if _, err := tx.Exec(updateBalance, newBalance, time.Now(), t.AccountID); err != nil {
+		deltas := balanceDeltas{}
+		if err := saveEntry(tx, deltas, entry); err != nil {
+			return err
+		}
+		if err := deltas.apply(tx); err != nil {
 			return err
 		}
 
 		if _, err := tx.Exec(`DELETE FROM transaction_categories WHERE transaction_id = $1;`, t.ID); err != nil {
 			return err
 		}
-		if categoryID != nil && *categoryID != "" {
-			linkCategory := `INSERT INTO transaction_categories (category_id, transaction_id) VALUES ($1, $2);`
-			if _, err := tx.Exec(linkCategory, *categoryID, t.ID); err != nil {
-				return err
-			}
+		if err := linkSplits(tx, t.ID, splits); err != nil {
+			return err
 		}
-		return nil
+
+		if _, err := tx.Exec(`DELETE FROM transaction_tags WHERE transaction_id = $1;`, t.ID); err != nil {
+			return err
+		}
+		return linkTags(tx, t.ID, tagIDs)
 	})
 }
 
-func (r *transactionRepository) UpdateDepositAtomic(t *model.Transaction, newBalance decimal.Decimal, categoryID *string) error {
+func (r *transactionRepository) UpdateDepositAtomic(t *model.Transaction, entry *model.JournalEntry, splits []model.CategorySplit, tagIDs []string) error {
 	return WithTx(r.db, func(tx *sqlx.Tx) error {
 		updateTxn := `
 			UPDATE transactions
@@ -168,38 +335,43 @@ func (r *transactionRepository) UpdateDepositAtomic(t *model.Transaction, newBal
 			return err
 		}
 
-		updateBalance := `UPDATE accounts SET balance = $1, updated_at = $2 WHERE id = $3;`
-		if _, err := tx.Exec(updateBalance, newBalance, time.Now(), t.AccountID); err != nil {
+		deltas := balanceDeltas{}
+		if err := saveEntry(tx, deltas, entry); err != nil {
+			return err
+		}
+		if err := deltas.apply(tx); err != nil {
 			return err
 		}
 
 		if _, err := tx.Exec(`DELETE FROM transaction_categories WHERE transaction_id = $1;`, t.ID); err != nil {
 			return err
 		}
-		if categoryID != nil && *categoryID != "" {
-			linkCategory := `INSERT INTO transaction_categories (category_id, transaction_id) VALUES ($1, $2);`
-			if _, err := tx.Exec(linkCategory, *categoryID, t.ID); err != nil {
-				return err
-			}
+		if err := linkSplits(tx, t.ID, splits); err != nil {
+			return err
 		}
-		return nil
+
+		if _, err := tx.Exec(`DELETE FROM transaction_tags WHERE transaction_id = $1;`, t.ID); err != nil {
+			return err
+		}
+		return linkTags(tx, t.ID, tagIDs)
 	})
 }
 
-// DeleteAtomic removes a standalone (non-transfer) transaction and reverses
-// its effect on the account balance in a single SQL transaction. The caller is
-// responsible for computing the new balance — bills credit it back, deposits
-// debit it. transaction_categories is removed via ON DELETE CASCADE.
-func (r *transactionRepository) DeleteAtomic(transactionID, accountID string, newBalance decimal.Decimal) error {
+// DeleteAtomic removes a standalone (non-transfer) transaction with its
+// journal entry and reverses the entry's postings on the account balance in
+// a single SQL transaction: bills are credited back, deposits debited;
+// transaction_categories and transaction_tags are removed via ON DELETE
+// CASCADE.
+func (r *transactionRepository) DeleteAtomic(transactionID string) error {
 	return WithTx(r.db, func(tx *sqlx.Tx) error {
-		if _, err := tx.Exec(`DELETE FROM transactions WHERE id = $1;`, transactionID); err != nil {
+		deltas := balanceDeltas{}
+		if err := deleteEntries(tx, deltas, `t.id = $1`, transactionID); err != nil {
 			return err
 		}
-		updateBalance := `UPDATE accounts SET balance = $1, updated_at = $2 WHERE id = $3;`
-		if _, err := tx.Exec(updateBalance, newBalance, time.Now(), accountID); err != nil {
+		if _, err := tx.Exec(`DELETE FROM transactions WHERE id = $1;`, transactionID); err != nil {
 			return err
 		}
-		return nil
+		return deltas.apply(tx)
 	})
 }
 
@@ -207,54 +379,463 @@ func (r *transactionRepository) DeleteAtomic(transactionID, accountID string, ne
 // account balances, and links the two via related_transactions in a single SQL
 // transaction. Negative balances are allowed — overdraft enforcement is a product
 // decision left to the service layer.
-func (r *transactionRepository) TransferAtomic(withdrawal, deposit *model.Transaction, sourceNewBalance, destNewBalance decimal.Decimal) error {
+func (r *transactionRepository) TransferAtomic(withdrawal, deposit *model.Transaction, entry *model.JournalEntry, tagIDs []string) error {
 	return WithTx(r.db, func(tx *sqlx.Tx) error {
-		insertTxn := `
-			INSERT INTO transactions
-				(id, value, type, account_id, title, description, occurred_at, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+		deltas := balanceDeltas{}
+		if err := insertTransfer(tx, deltas, withdrawal, deposit, entry, tagIDs); err != nil {
+			return err
+		}
+		return deltas.apply(tx)
+	})
+}
+
+// LoanPaymentAtomic records a transfer into a loan account together with the
+// interest charged on the loan since the previous payment, and links the two
+// in loan_payments. interest is nil when nothing accrued; the payment is
+// still linked so it counts as the latest payment.
+func (r *transactionRepository) LoanPaymentAtomic(withdrawal, deposit *model.Transaction, entry *model.JournalEntry, interest *model.Transaction, interestEntry *model.JournalEntry, tagIDs []string) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		deltas := balanceDeltas{}
+		var interestID *string
+		if interest != nil {
+			if _, err := tx.Exec(`
+				INSERT INTO transactions
+					(id, value, type, account_id, title, description, occurred_at, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+			`,
+				interest.ID, interest.Value, interest.Type, interest.AccountID, interest.Title,
+				interest.Description, interest.OccurredAt, interest.CreatedAt, interest.UpdatedAt,
+			); err != nil {
+				return err
+			}
+			if err := saveEntry(tx, deltas, interestEntry); err != nil {
+				return err
+			}
+			interestID = &interest.ID
+		}
+		if err := insertTransfer(tx, deltas, withdrawal, deposit, entry, tagIDs); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO loan_payments (payment_transaction_id, interest_transaction_id, account_id)
+			VALUES ($1, $2, $3);
+		`, deposit.ID, interestID, deposit.AccountID); err != nil {
+			return err
+		}
+		return deltas.apply(tx)
+	})
+}
+
+// insertTransfer writes both halves of a transfer with their journal entry
+// and links them. The caller applies deltas.
+func insertTransfer(tx *sqlx.Tx, deltas balanceDeltas, withdrawal, deposit *model.Transaction, entry *model.JournalEntry, tagIDs []string) error {
+	insertTxn := `
+		INSERT INTO transactions
+			(id, value, type, account_id, title, description, occurred_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`
+	if _, err := tx.Exec(insertTxn,
+		withdrawal.ID, withdrawal.Value, withdrawal.Type, withdrawal.AccountID, withdrawal.Title,
+		withdrawal.Description, withdrawal.OccurredAt, withdrawal.CreatedAt, withdrawal.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(insertTxn,
+		deposit.ID, deposit.Value, deposit.Type, deposit.AccountID, deposit.Title,
+		deposit.Description, deposit.OccurredAt, deposit.CreatedAt, deposit.UpdatedAt,
+	); err != nil {
+		return err
+	}
+
+	if err := saveEntry(tx, deltas, entry); err != nil {
+		return err
+	}
+
+	// related_transactions has CHECK (transaction_one_id < transaction_two_id);
+	// order the IDs to satisfy it.
+	one, two := withdrawal.ID, deposit.ID
+	if one > two {
+		one, two = two, one
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO related_transactions (transaction_one_id, transaction_two_id) VALUES ($1, $2);`,
+		one, two,
+	); err != nil {
+		return err
+	}
+
+	// Both halves carry the same tags so the transfer shows up under a tag
+	// filter from either account.
+	if err := linkTags(tx, withdrawal.ID, tagIDs); err != nil {
+		return err
+	}
+	return linkTags(tx, deposit.ID, tagIDs)
+}
+
+func (r *transactionRepository) UpdateTransferAtomic(withdrawal, deposit *model.Transaction, entry *model.JournalEntry) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		updateTxn := `
+			UPDATE transactions
+			SET value = $1, title = $2, description = $3, occurred_at = $4, updated_at = $5
+			WHERE id = $6;
 		`
-		if _, err := tx.Exec(insertTxn,
-			withdrawal.ID, withdrawal.Value, withdrawal.Type, withdrawal.AccountID, withdrawal.Title,
-			withdrawal.Description, withdrawal.OccurredAt, withdrawal.CreatedAt, withdrawal.UpdatedAt,
+		for _, t := range []*model.Transaction{withdrawal, deposit} {
+			if _, err := tx.Exec(
+				updateTxn,
+				t.Value, t.Title, t.Description, t.OccurredAt, t.UpdatedAt, t.ID,
+			); err != nil {
+				return err
+			}
+		}
+		deltas := balanceDeltas{}
+		if err := saveEntry(tx, deltas, entry); err != nil {
+			return err
+		}
+		return deltas.apply(tx)
+	})
+}
+
+// UndoTransferAtomic removes both halves, and the interest charged when the
+// transfer was a loan payment; the related_transactions link, category links
+// and tags go with them via ON DELETE CASCADE.
+func (r *transactionRepository) UndoTransferAtomic(withdrawal, deposit *model.Transaction) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		// A loan payment takes the interest it charged with it.
+		ids := []string{withdrawal.ID, deposit.ID}
+		var interestID *string
+		err := tx.Get(&interestID, `SELECT interest_transaction_id FROM loan_payments WHERE payment_transaction_id = $1;`, deposit.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if interestID != nil {
+			ids = append(ids, *interestID)
+		}
+		query, args, err := sqlx.In(`t.id IN (?)`, ids)
+		if err != nil {
+			return err
+		}
+		deltas := balanceDeltas{}
+		if err := deleteEntries(tx, deltas, tx.Rebind(query), args...); err != nil {
+			return err
+		}
+		query, args, err = sqlx.In(`DELETE FROM transactions WHERE id IN (?);`, ids)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+			return err
+		}
+		return deltas.apply(tx)
+	})
+}
+
+// balanceKey is an account in the currency its balance was counted in.
+type balanceKey struct {
+	AccountID string
+	Currency  string
+}
+
+// balanceDeltas accumulates, per account, the signed change a SQL
+// transaction's postings make to account balances: the postings on each
+// account's ledger account in the account's own currency, as written or as
+// about to be removed. apply adds them in the UPDATE itself, so a concurrent
+// writer waits on the row lock and then builds on the committed balance
+// instead of overwriting it with one computed from a stale read.
+type balanceDeltas map[balanceKey]decimal.Decimal
+
+// count adds the postings of the given entries that move account balances,
+// reversed when negate is set for postings about to be removed. A nil
+// balanceDeltas counts nothing.
+func (d balanceDeltas) count(tx *sqlx.Tx, negate bool, entryIDs ...string) error {
+	if d == nil {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT a.id AS account_id, a.currency, SUM(p.amount::numeric)::text AS amount
+		FROM postings p
+		JOIN ledger_accounts la ON la.id = p.ledger_account_id
+		JOIN accounts a ON a.id = la.account_id AND a.currency = la.currency
+		WHERE p.entry_id IN (?)
+		GROUP BY a.id, a.currency;`, entryIDs)
+	if err != nil {
+		return err
+	}
+	var sums []struct {
+		AccountID string          `db:"account_id"`
+		Currency  string          `db:"currency"`
+		Amount    decimal.Decimal `db:"amount"`
+	}
+	if err := tx.Select(&sums, tx.Rebind(query), args...); err != nil {
+		return err
+	}
+	for _, s := range sums {
+		amount := s.Amount
+		if negate {
+			amount = amount.Neg()
+		}
+		key := balanceKey{AccountID: s.AccountID, Currency: s.Currency}
+		d[key] = d[key].Add(amount)
+	}
+	return nil
+}
+
+// apply adds the deltas to the account balances. Accounts are updated in ID
+// order so two transactions touching the same accounts (say, opposite
+// transfers) take the row locks in the same order and cannot deadlock.
+func (d balanceDeltas) apply(tx *sqlx.Tx) error {
+	keys := make([]balanceKey, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].AccountID < keys[j].AccountID })
+	now := time.Now()
+	for _, k := range keys {
+		if _, err := adjustBalance(tx, k, d[k], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adjustBalance adds delta to the account's balance and returns the result.
+// The account must still be in the currency the delta was counted in.
+func adjustBalance(tx *sqlx.Tx, key balanceKey, delta decimal.Decimal, at time.Time) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	err := tx.Get(&balance, `
+		UPDATE accounts SET balance = (balance::numeric + $1::numeric)::text, updated_at = $2
+		WHERE id = $3 AND currency = $4
+		RETURNING balance;`,
+		delta.String(), at, key.AccountID, key.Currency,
+	)
+	if err == sql.ErrNoRows {
+		return balance, ErrAccountCurrencyChanged
+	}
+	return balance, err
+}
+
+// linkSplits records a transaction's category splits inside an open SQL
+// transaction. A lone split is stored without an amount so it keeps covering
+// the whole value, the same as links written before splits existed.
+func linkSplits(tx *sqlx.Tx, transactionID string, splits []model.CategorySplit) error {
+	linkCategory := `INSERT INTO transaction_categories (category_id, transaction_id, amount) VALUES ($1, $2, $3);`
+	for _, split := range splits {
+		var amount *decimal.Decimal
+		if len(splits) > 1 {
+			amount = &split.Amount
+		}
+		if _, err := tx.Exec(linkCategory, split.CategoryID, transactionID, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkTags attaches tags to a transaction inside an open SQL transaction.
+func linkTags(tx *sqlx.Tx, transactionID string, tagIDs []string) error {
+	now := time.Now()
+	for _, tagID := range tagIDs {
+		if _, err := tx.Exec(
+			`INSERT INTO transaction_tags (tag_id, transaction_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING;`,
+			tagID, transactionID, now,
 		); err != nil {
 			return err
 		}
-		if _, err := tx.Exec(insertTxn,
-			deposit.ID, deposit.Value, deposit.Type, deposit.AccountID, deposit.Title,
-			deposit.Description, deposit.OccurredAt, deposit.CreatedAt, deposit.UpdatedAt,
+	}
+	return nil
+}
+
+func (r *transactionRepository) ImportAtomic(batch *model.ImportBatch, rows []ImportedTransaction) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		insertBatch := `
+			INSERT INTO import_batches
+				(id, account_id, actor_id, source, filename, row_count, created_at,
+				 statement_balance, statement_balance_as_of, balance_after)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+		`
+		if _, err := tx.Exec(insertBatch,
+			batch.ID, batch.AccountID, batch.ActorID, batch.Source, batch.Filename, batch.RowCount, batch.CreatedAt,
+			batch.StatementBalance, batch.StatementBalanceAsOf, batch.BalanceAfter,
 		); err != nil {
 			return err
 		}
 
-		updateBalance := `UPDATE accounts SET balance = $1, updated_at = $2 WHERE id = $3;`
-		now := time.Now()
-		if _, err := tx.Exec(updateBalance, sourceNewBalance, now, withdrawal.AccountID); err != nil {
+		insertTxn := `
+			INSERT INTO transactions
+				(id, value, type, account_id, title, description, occurred_at, created_at, updated_at, import_batch_id, fitid)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);
+		`
+		linkCategory := `INSERT INTO transaction_categories (category_id, transaction_id) VALUES ($1, $2);`
+		deltas := balanceDeltas{}
+		for _, row := range rows {
+			t := row.Transaction
+			if _, err := tx.Exec(insertTxn,
+				t.ID, t.Value, t.Type, t.AccountID, t.Title, t.Description,
+				t.OccurredAt, t.CreatedAt, t.UpdatedAt, batch.ID, row.FITID,
+			); err != nil {
+				return err
+			}
+			if err := saveEntry(tx, deltas, row.Entry); err != nil {
+				return err
+			}
+			if row.CategoryID != nil && *row.CategoryID != "" {
+				if _, err := tx.Exec(linkCategory, *row.CategoryID, t.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		// The batch records the balance its rows left the account at.
+		if err := deltas.apply(tx); err != nil {
 			return err
 		}
-		if _, err := tx.Exec(updateBalance, destNewBalance, now, deposit.AccountID); err != nil {
+		var balance decimal.Decimal
+		if err := tx.Get(&balance, `SELECT balance FROM accounts WHERE id = $1;`, batch.AccountID); err != nil {
 			return err
 		}
+		batch.BalanceAfter = &balance
+		_, err := tx.Exec(`UPDATE import_batches SET balance_after = $1 WHERE id = $2;`, batch.BalanceAfter, batch.ID)
+		return err
+	})
+}
+
+func (r *transactionRepository) PostedCurrency(transactionID string) (string, error) {
+	posted, err := postedTransactions(r.db, []string{transactionID})
+	if err != nil || len(posted) == 0 {
+		return "", err
+	}
+	return posted[0].Currency, nil
+}
 
-		// related_transactions has CHECK (transaction_one_id < transaction_two_id);
-		// order the IDs to satisfy it.
-		one, two := withdrawal.ID, deposit.ID
-		if one > two {
-			one, two = two, one
+func (r *transactionRepository) RollbackImportAtomic(batchID, accountID string, rolledBackAt time.Time) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		deltas := balanceDeltas{}
+		if err := deleteEntries(tx, deltas, `t.import_batch_id = $1 AND t.account_id = $2`, batchID, accountID); err != nil {
+			return err
 		}
-		if _, err := tx.Exec(
-			`INSERT INTO related_transactions (transaction_one_id, transaction_two_id) VALUES ($1, $2);`,
-			one, two,
-		); err != nil {
+		if _, err := tx.Exec(`DELETE FROM transactions WHERE import_batch_id = $1 AND account_id = $2;`, batchID, accountID); err != nil {
+			return err
+		}
+		if err := deltas.apply(tx); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE import_batches SET rolled_back_at = $1 WHERE id = $2;`, rolledBackAt, batchID); err != nil {
 			return err
 		}
 		return nil
 	})
 }
 
+func (r *transactionRepository) ReconcileAtomic(rec *model.Reconciliation, finalizedAt time.Time) (int, error) {
+	var count int
+	err := WithTx(r.db, func(tx *sqlx.Tx) error {
+		res, err := tx.Exec(`
+			UPDATE transactions SET status = $1
+			WHERE account_id = $2 AND status = $3 AND occurred_at < $4;
+		`, model.TransactionStatusReconciled, rec.AccountID, model.TransactionStatusCleared, rec.ClearedThrough())
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		count = int(affected)
+
+		res, err = tx.Exec(`
+			UPDATE reconciliations SET transaction_count = $1, finalized_at = $2
+			WHERE id = $3 AND finalized_at IS NULL;
+		`, count, finalizedAt, rec.ID)
+		if err != nil {
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return ErrReconciliationNotFound
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *transactionRepository) ListUncategorized(accountID string) ([]*model.Transaction, error) {
+	query := `
+		SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at
+		FROM transactions t
+		WHERE account_id = $1 AND status <> $2
+		  AND NOT EXISTS (SELECT 1 FROM transaction_categories tc WHERE tc.transaction_id = t.id)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM related_transactions rt
+		      WHERE rt.transaction_one_id = t.id OR rt.transaction_two_id = t.id
+		  )
+		ORDER BY occurred_at ASC, created_at ASC;
+	`
+	txns := []*model.Transaction{}
+	if err := r.db.Select(&txns, query, accountID, model.TransactionStatusReconciled); err != nil {
+		return nil, err
+	}
+	return txns, nil
+}
+
+func (r *transactionRepository) CategorizeAtomic(updates []CategorizationUpdate, updatedAt time.Time) ([]string, error) {
+	applied := make([]string, 0, len(updates))
+	err := WithTx(r.db, func(tx *sqlx.Tx) error {
+		linkCategory := `
+			INSERT INTO transaction_categories (category_id, transaction_id)
+			SELECT $1, $2
+			WHERE NOT EXISTS (SELECT 1 FROM transaction_categories WHERE transaction_id = $2);
+		`
+		updateTitle := `UPDATE transactions SET title = $1, updated_at = $2 WHERE id = $3;`
+		for _, u := range updates {
+			res, err := tx.Exec(linkCategory, u.CategoryID, u.TransactionID)
+			if err != nil {
+				return err
+			}
+			if affected, err := res.RowsAffected(); err != nil {
+				return err
+			} else if affected == 0 {
+				continue
+			}
+			if _, err := tx.Exec(updateTitle, u.Title, updatedAt, u.TransactionID); err != nil {
+				return err
+			}
+			applied = append(applied, u.TransactionID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (r *transactionRepository) RecategorizeAtomic(transactionIDs []string, categoryID string, updatedAt time.Time) error {
+	return WithTx(r.db, func(tx *sqlx.Tx) error {
+		for _, id := range transactionIDs {
+			if _, err := tx.Exec(`DELETE FROM transaction_categories WHERE transaction_id = $1;`, id); err != nil {
+				return err
+			}
+			if categoryID != "" {
+				if err := linkSplits(tx, id, []model.CategorySplit{{CategoryID: categoryID}}); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.Exec(`UPDATE transactions SET updated_at = $1 WHERE id = $2;`, updatedAt, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *transactionRepository) SetStatus(transactionID string, status model.TransactionStatus) error {
+	_, err := r.db.Exec(`UPDATE transactions SET status = $1 WHERE id = $2;`, status, transactionID)
+	return err
+}
+
 func (r *transactionRepository) GetByID(id string) (*model.Transaction, error) {
 	query := `
-		SELECT id, value, type, account_id, title, description, occurred_at, created_at, updated_at
+		SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at
 		FROM transactions
 		WHERE id = $1;
 	`
@@ -316,7 +897,14 @@ func (r *transactionRepository) TransferIDsIn(ids []string) (map[string]bool, er
 
 func (r *transactionRepository) GetCategoryID(transactionID string) (*string, error) {
 	var id string
-	err := r.db.Get(&id, `SELECT category_id FROM transaction_categories WHERE transaction_id = $1 LIMIT 1;`, transactionID)
+	err := r.db.Get(&id, `
+		SELECT tc.category_id
+		FROM transaction_categories tc
+		JOIN transactions t ON t.id = tc.transaction_id
+		WHERE tc.transaction_id = $1
+		ORDER BY COALESCE(tc.amount, t.value)::numeric DESC, tc.category_id ASC
+		LIMIT 1;
+	`, transactionID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -326,12 +914,62 @@ func (r *transactionRepository) GetCategoryID(transactionID string) (*string, er
 	return &id, nil
 }
 
+func (r *transactionRepository) GetSplits(transactionID string) ([]model.CategorySplit, error) {
+	splits := []model.CategorySplit{}
+	query := `
+		SELECT tc.category_id, COALESCE(tc.amount, t.value) AS amount
+		FROM transaction_categories tc
+		JOIN transactions t ON t.id = tc.transaction_id
+		WHERE tc.transaction_id = $1
+		ORDER BY COALESCE(tc.amount, t.value)::numeric DESC, tc.category_id ASC;
+	`
+	if err := r.db.Select(&splits, query, transactionID); err != nil {
+		return nil, err
+	}
+	return splits, nil
+}
+
+// transactionsWithRunningBalance stands in for the transactions table, adding
+// running_balance: the account balance right after each row. The window runs
+// over every transaction on the account, oldest first by occurred_at, so a
+// backdated entry shifts the balances after it, and filters applied outside
+// narrow the rows shown without changing their balances. Conditions on
+// account_id alone are pushed into the derived table, so only the accounts
+// asked for are summed.
+//
+// Balances come from the postings in the account's current currency, the
+// same ones its stored balance adds up. A row posted before the account
+// changed currency adds nothing there; the money it moved arrives with the
+// conversion, counted from the day the change happened.
+const transactionsWithRunningBalance = `(
+	SELECT transactions.*,
+	       (SUM(COALESCE(own.amount, 0)) OVER (PARTITION BY account_id ORDER BY occurred_at, created_at, id)
+	           + COALESCE(converted.amount, 0))::text AS running_balance
+	FROM transactions
+	LEFT JOIN LATERAL (
+		SELECT SUM(p.amount::numeric) AS amount
+		FROM postings p
+		JOIN ledger_accounts la ON la.id = p.ledger_account_id
+		JOIN accounts a ON a.id = la.account_id AND a.currency = la.currency
+		WHERE p.transaction_id = transactions.id AND la.account_id = transactions.account_id
+	) own ON true
+	LEFT JOIN LATERAL (
+		SELECT SUM(p.amount::numeric) AS amount
+		FROM postings p
+		JOIN ledger_accounts la ON la.id = p.ledger_account_id
+		JOIN accounts a ON a.id = la.account_id AND a.currency = la.currency
+		JOIN journal_entries je ON je.id = p.entry_id
+		WHERE la.account_id = transactions.account_id AND p.transaction_id IS NULL
+		  AND je.occurred_at <= transactions.occurred_at
+	) converted ON true
+) AS transactions`
+
 func (r *transactionRepository) ListByAccount(accountID string, limit, offset int) ([]*model.Transaction, error) {
 	query := `
-		SELECT id, value, type, account_id, title, description, occurred_at, created_at, updated_at
-		FROM transactions
+		SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at, running_balance
+		FROM ` + transactionsWithRunningBalance + `
 		WHERE account_id = $1
-		ORDER BY occurred_at DESC, created_at DESC
+		ORDER BY occurred_at DESC, created_at DESC, id DESC
 		LIMIT $2 OFFSET $3;
 	`
 	txns := []*model.Transaction{}
@@ -341,6 +979,103 @@ func (r *transactionRepository) ListByAccount(accountID string, limit, offset in
 	return txns, nil
 }
 
+func (r *transactionRepository) ListByAccountBetween(accountID string, from, to time.Time) ([]*model.Transaction, error) {
+	query := `
+		SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at
+		FROM transactions
+		WHERE account_id = $1 AND occurred_at >= $2 AND occurred_at <= $3
+		ORDER BY occurred_at ASC, created_at ASC;
+	`
+	txns := []*model.Transaction{}
+	if err := r.db.Select(&txns, query, accountID, from, to); err != nil {
+		return nil, err
+	}
+	return txns, nil
+}
+
+func (r *transactionRepository) ListByImportBatch(batchID string) ([]*model.Transaction, error) {
+	query := `
+		SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at
+		FROM transactions
+		WHERE import_batch_id = $1
+		ORDER BY occurred_at ASC, created_at ASC;
+	`
+	txns := []*model.Transaction{}
+	if err := r.db.Select(&txns, query, batchID); err != nil {
+		return nil, err
+	}
+	return txns, nil
+}
+
+func (r *transactionRepository) ExistingFITIDs(accountID string, fitids []string) (map[string]bool, error) {
+	if len(fitids) == 0 {
+		return map[string]bool{}, nil
+	}
+	query, args, err := sqlx.In(`SELECT fitid FROM transactions WHERE account_id = ? AND fitid IN (?);`, accountID, fitids)
+	if err != nil {
+		return nil, err
+	}
+	query = r.db.Rebind(query)
+	var hits []string
+	if err := r.db.Select(&hits, query, args...); err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(hits))
+	for _, id := range hits {
+		out[id] = true
+	}
+	return out, nil
+}
+
+func (r *transactionRepository) ListUnreconciledBefore(accountID string, before time.Time) ([]*model.Transaction, error) {
+	query := `
+		SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at
+		FROM transactions
+		WHERE account_id = $1 AND status <> $2 AND occurred_at < $3
+		ORDER BY occurred_at ASC, created_at ASC;
+	`
+	txns := []*model.Transaction{}
+	if err := r.db.Select(&txns, query, accountID, model.TransactionStatusReconciled, before); err != nil {
+		return nil, err
+	}
+	return txns, nil
+}
+
+func (r *transactionRepository) SumReconciliation(accountID string, before time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	var sums struct {
+		Reconciled decimal.Decimal `db:"reconciled"`
+		Cleared    decimal.Decimal `db:"cleared"`
+	}
+	query := `
+		SELECT
+			COALESCE(SUM(signed) FILTER (WHERE status = 'reconciled'), 0)::text AS reconciled,
+			COALESCE(SUM(signed) FILTER (WHERE status = 'cleared' AND occurred_at < $2), 0)::text AS cleared
+		FROM (
+			SELECT status, occurred_at,
+			       CASE WHEN type = 'withdrawal' THEN -value::numeric ELSE value::numeric END AS signed
+			FROM transactions
+			WHERE account_id = $1
+		) t;
+	`
+	if err := r.db.Get(&sums, query, accountID, before); err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	return sums.Reconciled, sums.Cleared, nil
+}
+
+func (r *transactionRepository) BalanceAsOf(accountID string, at time.Time) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	err := r.db.Get(&balance, `
+		SELECT COALESCE(SUM(p.amount::numeric), 0)::text
+		FROM postings p
+		JOIN ledger_accounts la ON la.id = p.ledger_account_id
+		JOIN accounts a ON a.id = la.account_id AND a.currency = la.currency
+		JOIN journal_entries je ON je.id = p.entry_id
+		WHERE la.account_id = $1 AND je.occurred_at <= $2;
+	`, accountID, at)
+	return balance, err
+}
+
 func (r *transactionRepository) CountByAccount(accountID string) (int, error) {
 	var count int
 	if err := r.db.Get(&count, `SELECT COUNT(*) FROM transactions WHERE account_id = $1;`, accountID); err != nil {
@@ -354,8 +1089,14 @@ func (r *transactionRepository) CountByAccount(accountID string) (int, error) {
 // always present, so the returned clause is never empty. Amount comparisons cast
 // the TEXT value column to numeric so they order numerically, not lexically.
 func transactionFilterClause(accountID string, filter model.TransactionFilter) (string, []any) {
-	conds := []string{"account_id = $1"}
-	args := []any{accountID}
+	return scopedTransactionFilterClause("account_id = $1", accountID, filter)
+}
+
+// scopedTransactionFilterClause is transactionFilterClause with a caller-chosen
+// scope condition, which must reference its argument as $1.
+func scopedTransactionFilterClause(scope string, scopeArg any, filter model.TransactionFilter) (string, []any) {
+	conds := []string{scope}
+	args := []any{scopeArg}
 	add := func(format string, val any) {
 		args = append(args, val)
 		conds = append(conds, fmt.Sprintf(format, len(args)))
@@ -363,6 +1104,25 @@ func transactionFilterClause(accountID string, filter model.TransactionFilter) (
 	if title := strings.TrimSpace(filter.Title); title != "" {
 		add("title ILIKE $%d", "%"+title+"%")
 	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		// The tsvector expression matches idx_transactions_search_fts; the
+		// ILIKE and <% arms are served by the trigram indexes and catch partial
+		// words that full-text search would miss.
+		args = append(args, q, "%"+escapeLike(q)+"%")
+		qi, li := len(args)-1, len(args)
+		conds = append(conds, fmt.Sprintf(
+			"(to_tsvector('simple', title || ' ' || COALESCE(description, '')) @@ websearch_to_tsquery('simple', $%[1]d)"+
+				" OR title ILIKE $%[2]d OR description ILIKE $%[2]d"+
+				" OR $%[1]d OPERATOR(public.<%%) title)",
+			qi, li,
+		))
+	}
+	if filter.Type != "" {
+		add("type = $%d", string(filter.Type))
+	}
+	if name := strings.TrimSpace(filter.CategoryName); name != "" {
+		add("EXISTS (SELECT 1 FROM transaction_categories tc JOIN categories c ON c.id = tc.category_id WHERE tc.transaction_id = transactions.id AND lower(c.name) = lower($%d))", name)
+	}
 	if filter.DateFrom != nil {
 		add("occurred_at >= $%d", *filter.DateFrom)
 	}
@@ -375,18 +1135,34 @@ func transactionFilterClause(accountID string, filter model.TransactionFilter) (
 	if filter.AmountMax != nil {
 		add("value::numeric <= $%d::numeric", filter.AmountMax.String())
 	}
+	if len(filter.TagIDs) > 0 {
+		placeholders := make([]string, len(filter.TagIDs))
+		for i, id := range filter.TagIDs {
+			args = append(args, id)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conds = append(conds, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM transaction_tags tt WHERE tt.transaction_id = transactions.id AND tt.tag_id IN (%s))",
+			strings.Join(placeholders, ", "),
+		))
+	}
 	return strings.Join(conds, " AND "), args
 }
 
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 func (r *transactionRepository) ListByAccountFiltered(accountID string, filter model.TransactionFilter, limit, offset int) ([]*model.Transaction, error) {
 	where, args := transactionFilterClause(accountID, filter)
 	query := fmt.Sprintf(`
-		SELECT id, value, type, account_id, title, description, occurred_at, created_at, updated_at
-		FROM transactions
+		SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at, running_balance
+		FROM %s
 		WHERE %s
-		ORDER BY occurred_at DESC, created_at DESC
+		ORDER BY occurred_at DESC, created_at DESC, id DESC
 		LIMIT $%d OFFSET $%d;
-	`, where, len(args)+1, len(args)+2)
+	`, transactionsWithRunningBalance, where, len(args)+1, len(args)+2)
 	args = append(args, limit, offset)
 
 	txns := []*model.Transaction{}
@@ -406,6 +1182,120 @@ func (r *transactionRepository) CountByAccountFiltered(accountID string, filter
 	return count, nil
 }
 
+func (r *transactionRepository) ExportByAccount(accountID string, filter model.TransactionFilter, fn func(*model.TransactionExportRow) error) error {
+	where, args := transactionFilterClause(accountID, filter)
+	return r.streamExport(where, args, fn)
+}
+
+func (r *transactionRepository) ExportBySpace(spaceID string, filter model.TransactionFilter, fn func(*model.TransactionExportRow) error) error {
+	where, args := scopedTransactionFilterClause(spaceAccountsScope, spaceID, filter)
+	return r.streamExport(where, args, fn)
+}
+
+const (
+	spaceAccountsScope  = "account_id IN (SELECT id FROM accounts WHERE space_id = $1)"
+	memberAccountsScope = "account_id IN (SELECT a.id FROM accounts a JOIN space_members sm ON sm.space_id = a.space_id WHERE sm.user_id = $1)"
+)
+
+func (r *transactionRepository) SearchBySpace(spaceID string, filter model.TransactionFilter, limit, offset int) ([]*model.TransactionSearchResult, error) {
+	where, args := scopedTransactionFilterClause(spaceAccountsScope, spaceID, filter)
+	return r.search(where, args, limit, offset)
+}
+
+func (r *transactionRepository) CountBySpaceFiltered(spaceID string, filter model.TransactionFilter) (int, error) {
+	where, args := scopedTransactionFilterClause(spaceAccountsScope, spaceID, filter)
+	return r.countWhere(where, args)
+}
+
+func (r *transactionRepository) SearchByMember(userID string, filter model.TransactionFilter, limit, offset int) ([]*model.TransactionSearchResult, error) {
+	where, args := scopedTransactionFilterClause(memberAccountsScope, userID, filter)
+	return r.search(where, args, limit, offset)
+}
+
+func (r *transactionRepository) CountByMemberFiltered(userID string, filter model.TransactionFilter) (int, error) {
+	where, args := scopedTransactionFilterClause(memberAccountsScope, userID, filter)
+	return r.countWhere(where, args)
+}
+
+// search runs a filtered query that spans accounts. Like streamExport, the
+// filter is applied in a derived table so its column names stay unambiguous
+// next to the joined accounts and spaces.
+func (r *transactionRepository) search(where string, args []any, limit, offset int) ([]*model.TransactionSearchResult, error) {
+	query := fmt.Sprintf(`
+		SELECT t.id, t.value, t.type, t.account_id, t.title, t.description, t.status, t.occurred_at, t.created_at, t.updated_at,
+		       a.name AS account_name, a.currency, s.id AS space_id, s.name AS space_name
+		FROM (
+			SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at
+			FROM transactions
+			WHERE %s
+		) t
+		JOIN accounts a ON a.id = t.account_id
+		JOIN spaces s ON s.id = a.space_id
+		ORDER BY t.occurred_at DESC, t.created_at DESC, t.id DESC
+		LIMIT $%d OFFSET $%d;
+	`, where, len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	results := []*model.TransactionSearchResult{}
+	if err := r.db.Select(&results, query, args...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *transactionRepository) countWhere(where string, args []any) (int, error) {
+	var count int
+	if err := r.db.Get(&count, fmt.Sprintf(`SELECT COUNT(*) FROM transactions WHERE %s;`, where), args...); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// streamExport runs the export query and hands rows to fn one at a time as
+// they arrive, so memory stays flat regardless of history length. The filter
+// is applied in a derived table so its unqualified column names stay
+// unambiguous next to the joined tables.
+func (r *transactionRepository) streamExport(where string, args []any, fn func(*model.TransactionExportRow) error) error {
+	query := fmt.Sprintf(`
+		SELECT t.id, t.value, t.type, t.account_id, t.title, t.description, t.status, t.occurred_at, t.created_at, t.updated_at, t.fitid,
+		       t.running_balance, a.name AS account_name, a.currency,
+		       cat.category_name,
+		       pt.id AS transfer_pair_id, pa.id AS transfer_account_id, pa.name AS transfer_account_name
+		FROM (
+			SELECT id, value, type, account_id, title, description, status, occurred_at, created_at, updated_at, fitid, running_balance
+			FROM %s
+			WHERE %s
+		) t
+		JOIN accounts a ON a.id = t.account_id
+		LEFT JOIN LATERAL (
+			SELECT string_agg(c.name, '; ' ORDER BY c.name) AS category_name
+			FROM transaction_categories tc
+			JOIN categories c ON c.id = tc.category_id
+			WHERE tc.transaction_id = t.id
+		) cat ON true
+		LEFT JOIN related_transactions rt ON rt.transaction_one_id = t.id OR rt.transaction_two_id = t.id
+		LEFT JOIN transactions pt ON pt.id = CASE WHEN rt.transaction_one_id = t.id THEN rt.transaction_two_id ELSE rt.transaction_one_id END
+		LEFT JOIN accounts pa ON pa.id = pt.account_id
+		ORDER BY t.occurred_at ASC, t.created_at ASC, t.id ASC;
+	`, transactionsWithRunningBalance, where)
+
+	rows, err := r.db.Queryx(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := &model.TransactionExportRow{}
+		if err := rows.StructScan(row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (r *transactionRepository) SumByAccountYearType(accountID string, year int, txType model.TransactionType) (decimal.Decimal, error) {
 	var sum decimal.Decimal
 	query := `SELECT COALESCE(SUM(value::numeric), 0)::text FROM transactions
@@ -418,6 +1308,28 @@ func (r *transactionRepository) SumByAccountYearType(accountID string, year int,
 	return sum, nil
 }
 
+func (r *transactionRepository) SumByAccountTypeBetween(accountID string, txType model.TransactionType, after, through time.Time) (decimal.Decimal, error) {
+	var sum decimal.Decimal
+	query := `SELECT COALESCE(SUM(value::numeric), 0)::text FROM transactions
+	          WHERE account_id = $1
+	            AND type = $2
+	            AND occurred_at > $3
+	            AND occurred_at <= $4;`
+	if err := r.db.Get(&sum, query, accountID, txType, after, through); err != nil {
+		return decimal.Zero, err
+	}
+	return sum, nil
+}
+
+// postedCurrencySQL selects the currency transaction t was posted in on its
+// account, for a lateral join. Transactions recorded before the ledger
+// existed have no posting; callers fall back to the account's currency.
+const postedCurrencySQL = `
+	SELECT p.currency FROM postings p
+	JOIN ledger_accounts la ON la.id = p.ledger_account_id
+	WHERE p.transaction_id = t.id AND la.account_id = t.account_id
+	LIMIT 1`
+
 func (r *transactionRepository) SumByCategoryBucket(accountID string, txType model.TransactionType, from, to time.Time, granularity string, includeUncategorized bool) ([]CategoryBucketRow, error) {
 	uncategorizedFilter := ""
 	if !includeUncategorized {
@@ -426,9 +1338,18 @@ func (r *transactionRepository) SumByCategoryBucket(accountID string, txType mod
 	query := fmt.Sprintf(`
 		SELECT date_trunc($5, t.occurred_at) AS bucket,
 		       tc.category_id AS category_id,
-		       COALESCE(SUM(t.value::numeric), 0)::text AS total
+		       COALESCE(pc.currency, a.currency) AS currency,
+		       COALESCE(SUM(COALESCE(tc.amount, t.value)::numeric), 0)::text AS total
 		FROM transactions t
-		LEFT JOIN transaction_categories tc ON tc.transaction_id = t.id
+		JOIN accounts a ON a.id = t.account_id
+		LEFT JOIN LATERAL (%s) pc ON true
+		LEFT JOIN LATERAL (
+			SELECT category_id, amount FROM transaction_categories WHERE transaction_id = t.id
+			UNION ALL
+			SELECT NULL, (t.value::numeric - SUM(amount::numeric))::text
+			FROM transaction_categories WHERE transaction_id = t.id
+			HAVING SUM(amount::numeric) < t.value::numeric
+		) tc ON true
 		WHERE t.account_id = $1
 		  AND t.type = $2
 		  AND t.occurred_at >= $3
@@ -438,9 +1359,9 @@ func (r *transactionRepository) SumByCategoryBucket(accountID string, txType mod
 		      SELECT 1 FROM related_transactions r
 		      WHERE r.transaction_one_id = t.id OR r.transaction_two_id = t.id
 		  )
-		GROUP BY bucket, tc.category_id
+		GROUP BY bucket, tc.category_id, COALESCE(pc.currency, a.currency)
 		ORDER BY bucket ASC;
-	`, uncategorizedFilter)
+	`, postedCurrencySQL, uncategorizedFilter)
 
 	rows := []CategoryBucketRow{}
 	if err := r.db.Select(&rows, query, accountID, txType, from, to, granularity); err != nil {
@@ -449,6 +1370,76 @@ func (r *transactionRepository) SumByCategoryBucket(accountID string, txType mod
 	return rows, nil
 }
 
+func (r *transactionRepository) SumTransfersByCounterpart(accountID string, from, to time.Time, granularity string) ([]TransferBucketRow, error) {
+	query := fmt.Sprintf(`
+		SELECT date_trunc($4, t.occurred_at) AS bucket,
+		       t.type AS type,
+		       o.account_id AS counterpart_account_id,
+		       COALESCE(pc.currency, a.currency) AS currency,
+		       SUM(t.value::numeric)::text AS total
+		FROM transactions t
+		JOIN accounts a ON a.id = t.account_id
+		LEFT JOIN LATERAL (%s) pc ON true
+		JOIN related_transactions r ON t.id IN (r.transaction_one_id, r.transaction_two_id)
+		JOIN transactions o
+		  ON o.id = CASE WHEN r.transaction_one_id = t.id THEN r.transaction_two_id ELSE r.transaction_one_id END
+		WHERE t.account_id = $1
+		  AND t.occurred_at >= $2
+		  AND t.occurred_at <= $3
+		GROUP BY bucket, t.type, o.account_id, COALESCE(pc.currency, a.currency)
+		ORDER BY bucket ASC;
+	`, postedCurrencySQL)
+	rows := []TransferBucketRow{}
+	if err := r.db.Select(&rows, query, accountID, from, to, granularity); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *transactionRepository) DailyNetByAccounts(accountIDs []string, before time.Time) ([]AccountDayNet, error) {
+	rows := []AccountDayNet{}
+	if len(accountIDs) == 0 {
+		return rows, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT account_id, date_trunc('day', occurred_at) AS day,
+		       SUM(CASE WHEN type = 'deposit' THEN value::numeric ELSE -value::numeric END)::text AS net
+		FROM transactions
+		WHERE account_id IN (?) AND occurred_at < ?
+		GROUP BY account_id, day
+		ORDER BY day ASC;`, accountIDs, before)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.Select(&rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *transactionRepository) DailyNetByAccountCurrency(accountIDs []string, before time.Time) ([]AccountDayNet, error) {
+	rows := []AccountDayNet{}
+	if len(accountIDs) == 0 {
+		return rows, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT la.account_id, la.currency, date_trunc('day', je.occurred_at) AS day,
+		       SUM(p.amount::numeric)::text AS net
+		FROM postings p
+		JOIN ledger_accounts la ON la.id = p.ledger_account_id
+		JOIN journal_entries je ON je.id = p.entry_id
+		WHERE la.account_id IN (?) AND je.occurred_at < ?
+		GROUP BY la.account_id, la.currency, day
+		ORDER BY day ASC;`, accountIDs, before)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.Select(&rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
 func (r *transactionRepository) SumLifetimeByAccountType(accountID string, txType model.TransactionType) (decimal.Decimal, error) {
 	var sum decimal.Decimal
 	query := `SELECT COALESCE(SUM(value::numeric), 0)::text FROM transactions
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// TransactionAttachmentRepository stores attachment metadata. Deleting a row,
+// directly or through a cascade, queues its stored bytes in
+// attachment_blob_deletions via a database trigger.
+type TransactionAttachmentRepository interface {
+	Create(att *model.TransactionAttachment) error
+	ByID(id string) (*model.TransactionAttachment, error)
+	// ListByTransaction returns a transaction's attachments, oldest first.
+	ListByTransaction(transactionID string) ([]*model.TransactionAttachment, error)
+	// CountByTransaction returns how many attachments a transaction has.
+	CountByTransaction(transactionID string) (int, error)
+	Delete(id string) error
+	// PendingBlobDeletions returns queued blob deletions, oldest first.
+	PendingBlobDeletions(limit int) ([]*model.AttachmentBlobDeletion, error)
+	// ClearBlobDeletion drops a queue entry once its blob is gone.
+	ClearBlobDeletion(id int64) error
+}
+
+type transactionAttachmentRepository struct {
+	db *sqlx.DB
+}
+
+func NewTransactionAttachmentRepository(db *sqlx.DB) TransactionAttachmentRepository {
+	return &transactionAttachmentRepository{db: db}
+}
+
+func (r *transactionAttachmentRepository) Create(att *model.TransactionAttachment) error {
+	query := `
+		INSERT INTO transaction_attachments
+			(id, transaction_id, filename, content_type, size_bytes, storage_backend, storage_key, uploaded_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`
+	_, err := r.db.Exec(query,
+		att.ID, att.TransactionID, att.Filename, att.ContentType, att.SizeBytes,
+		att.StorageBackend, att.StorageKey, att.UploadedBy, att.CreatedAt,
+	)
+	return err
+}
+
+func (r *transactionAttachmentRepository) ByID(id string) (*model.TransactionAttachment, error) {
+	att := &model.TransactionAttachment{}
+	err := r.db.Get(att, `SELECT * FROM transaction_attachments WHERE id = $1;`, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrAttachmentNotFound
+	}
+	return att, err
+}
+
+func (r *transactionAttachmentRepository) ListByTransaction(transactionID string) ([]*model.TransactionAttachment, error) {
+	atts := []*model.TransactionAttachment{}
+	err := r.db.Select(&atts, `
+		SELECT * FROM transaction_attachments
+		WHERE transaction_id = $1
+		ORDER BY created_at ASC, id ASC;
+	`, transactionID)
+	return atts, err
+}
+
+func (r *transactionAttachmentRepository) CountByTransaction(transactionID string) (int, error) {
+	var count int
+	err := r.db.Get(&count, `SELECT COUNT(*) FROM transaction_attachments WHERE transaction_id = $1;`, transactionID)
+	return count, err
+}
+
+func (r *transactionAttachmentRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM transaction_attachments WHERE id = $1;`, id)
+	return err
+}
+
+func (r *transactionAttachmentRepository) PendingBlobDeletions(limit int) ([]*model.AttachmentBlobDeletion, error) {
+	out := []*model.AttachmentBlobDeletion{}
+	err := r.db.Select(&out, `
+		SELECT * FROM attachment_blob_deletions
+		ORDER BY id ASC
+		LIMIT $1;
+	`, limit)
+	return out, err
+}
+
+func (r *transactionAttachmentRepository) ClearBlobDeletion(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM attachment_blob_deletions WHERE id = $1;`, id)
+	return err
+}
@@ -31,7 +31,7 @@ func TestTransactionRepository_TransferAtomic_LinksPairAndUpdatesBalances(t *tes
 			AccountID: dst.ID, Title: "Move", OccurredAt: now, CreatedAt: now, UpdatedAt: now,
 		}
 
-		err := repo.TransferAtomic(withdrawal, deposit, decimal.NewFromInt(-40), decimal.NewFromInt(40))
+		err := repo.TransferAtomic(withdrawal, deposit, model.TransferEntry(space.ID, withdrawal, src.Currency, deposit, dst.Currency), nil)
 		require.NoError(t, err)
 
 		// Both transactions exist.
@@ -77,7 +77,7 @@ func TestTransactionRepository_TransferIDsIn_ReturnsOnlyTransferHalves(t *testin
 		now := time.Now()
 		w := &model.Transaction{ID: uuid.NewString(), Value: decimal.NewFromInt(5), Type: model.TransactionTypeWithdrawal, AccountID: src.ID, Title: "T-w", OccurredAt: now, CreatedAt: now, UpdatedAt: now}
 		d := &model.Transaction{ID: uuid.NewString(), Value: decimal.NewFromInt(5), Type: model.TransactionTypeDeposit, AccountID: dst.ID, Title: "T-d", OccurredAt: now, CreatedAt: now, UpdatedAt: now}
-		require.NoError(t, repo.TransferAtomic(w, d, decimal.NewFromInt(-5), decimal.NewFromInt(5)))
+		require.NoError(t, repo.TransferAtomic(w, d, model.TransferEntry(space.ID, w, src.Currency, d, dst.Currency), nil))
 		standalone := testutil.CreateTestTransaction(t, dbi.DB, src.ID, "solo", model.TransactionTypeDeposit, decimal.NewFromInt(1))
 
 		hits, err := repo.TransferIDsIn([]string{w.ID, d.ID, standalone.ID})
@@ -182,3 +182,153 @@ func TestTransactionRepository_FilterByTitleAmountAndDate(t *testing.T) {
 		assert.Equal(t, map[string]bool{coffee.ID: true}, got)
 	})
 }
+
+func TestTransactionRepository_ImportAtomic_TagsRowsAndRollsBack(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		repo := NewTransactionRepository(dbi.DB)
+		batchRepo := NewImportBatchRepository(dbi.DB)
+		accountRepo := NewAccountRepository(dbi.DB)
+
+		user := testutil.CreateTestUser(t, dbi.DB, "import-repo@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+		category := testutil.CreateTestCategory(t, dbi.DB, account.ID, "Groceries")
+
+		now := time.Now()
+		batch := &model.ImportBatch{
+			ID: uuid.NewString(), AccountID: account.ID, ActorID: &user.ID,
+			Source: model.ImportSourceCSV, Filename: "stmt.csv", RowCount: 2, CreatedAt: now,
+		}
+		bill := &model.Transaction{
+			ID: uuid.NewString(), Value: decimal.NewFromInt(30), Type: model.TransactionTypeWithdrawal,
+			AccountID: account.ID, Title: "Market", OccurredAt: now, CreatedAt: now, UpdatedAt: now,
+		}
+		pay := &model.Transaction{
+			ID: uuid.NewString(), Value: decimal.NewFromInt(100), Type: model.TransactionTypeDeposit,
+			AccountID: account.ID, Title: "Pay", OccurredAt: now, CreatedAt: now, UpdatedAt: now,
+		}
+		rows := []ImportedTransaction{
+			{Transaction: bill, Entry: model.TransactionEntry(space.ID, bill, account.Currency), CategoryID: &category.ID},
+			{Transaction: pay, Entry: model.TransactionEntry(space.ID, pay, account.Currency)},
+		}
+		require.NoError(t, repo.ImportAtomic(batch, rows))
+
+		stored, err := batchRepo.ByID(batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stored.RowCount)
+		assert.False(t, stored.IsRolledBack())
+
+		inBatch, err := repo.ListByImportBatch(batch.ID)
+		require.NoError(t, err)
+		assert.Len(t, inBatch, 2)
+
+		catID, err := repo.GetCategoryID(bill.ID)
+		require.NoError(t, err)
+		require.NotNil(t, catID)
+		assert.Equal(t, category.ID, *catID)
+
+		acct, err := accountRepo.ByID(account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(70).Equal(acct.Balance))
+
+		require.NoError(t, repo.RollbackImportAtomic(batch.ID, account.ID, time.Now()))
+
+		inBatch, err = repo.ListByImportBatch(batch.ID)
+		require.NoError(t, err)
+		assert.Empty(t, inBatch)
+
+		stored, err = batchRepo.ByID(batch.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.IsRolledBack())
+
+		acct, err = accountRepo.ByID(account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.Zero.Equal(acct.Balance))
+	})
+}
+
+func TestTransactionRepository_ExistingFITIDs(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		repo := NewTransactionRepository(dbi.DB)
+
+		user := testutil.CreateTestUser(t, dbi.DB, "fitid-repo@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+		other := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Savings")
+
+		now := time.Now()
+		statementBalance := decimal.RequireFromString("12.50")
+		batch := &model.ImportBatch{
+			ID: uuid.NewString(), AccountID: account.ID, Source: model.ImportSourceOFX,
+			RowCount: 1, CreatedAt: now, StatementBalance: &statementBalance,
+		}
+		fitid := "F-1"
+		txn := &model.Transaction{
+			ID: uuid.NewString(), Value: decimal.RequireFromString("12.50"), Type: model.TransactionTypeDeposit,
+			AccountID: account.ID, Title: "Refund", OccurredAt: now, CreatedAt: now, UpdatedAt: now,
+		}
+		require.NoError(t, repo.ImportAtomic(batch, []ImportedTransaction{{
+			Transaction: txn, Entry: model.TransactionEntry(space.ID, txn, account.Currency), FITID: &fitid,
+		}}))
+
+		got, err := repo.ExistingFITIDs(account.ID, []string{"F-1", "F-2"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"F-1": true}, got)
+
+		// FITIDs are scoped to the account they were imported into.
+		got, err = repo.ExistingFITIDs(other.ID, []string{"F-1"})
+		require.NoError(t, err)
+		assert.Empty(t, got)
+
+		stored, err := NewImportBatchRepository(dbi.DB).ByID(batch.ID)
+		require.NoError(t, err)
+		drift, ok := stored.BalanceDrift()
+		require.True(t, ok)
+		assert.True(t, drift.IsZero())
+	})
+}
+
+func TestTransactionRepository_RunningBalanceAfterCurrencyChange(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		repo := NewTransactionRepository(dbi.DB)
+		accountRepo := NewAccountRepository(dbi.DB)
+
+		user := testutil.CreateTestUser(t, dbi.DB, "running-currency@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+
+		now := time.Now()
+		pay := &model.Transaction{
+			ID: uuid.NewString(), Value: decimal.NewFromInt(200), Type: model.TransactionTypeDeposit,
+			AccountID: account.ID, Title: "Pay", OccurredAt: now.AddDate(0, 0, -5), CreatedAt: now, UpdatedAt: now,
+		}
+		require.NoError(t, repo.CreateDepositAtomic(pay, model.TransactionEntry(space.ID, pay, "CAD"), nil, nil))
+		_, _, err := accountRepo.ChangeCurrency(account.ID, "USD", decimal.RequireFromString("0.75"), 2, nil)
+		require.NoError(t, err)
+		bill := &model.Transaction{
+			ID: uuid.NewString(), Value: decimal.NewFromInt(10), Type: model.TransactionTypeWithdrawal,
+			AccountID: account.ID, Title: "Coffee", OccurredAt: now.Add(time.Minute), CreatedAt: now, UpdatedAt: now,
+		}
+		require.NoError(t, repo.CreateBillAtomic(bill, model.TransactionEntry(space.ID, bill, "USD"), nil, nil))
+
+		txns, err := repo.ListByAccount(account.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, txns, 2)
+		assert.Equal(t, bill.ID, txns[0].ID)
+		require.NotNil(t, txns[0].RunningBalance)
+		assert.True(t, decimal.NewFromInt(140).Equal(*txns[0].RunningBalance), "150 USD converted less the 10 USD bill: %s", txns[0].RunningBalance)
+		require.NotNil(t, txns[1].RunningBalance)
+		assert.True(t, txns[1].RunningBalance.IsZero(), "the CAD deposit held nothing in USD yet: %s", txns[1].RunningBalance)
+
+		balance, err := repo.BalanceAsOf(account.ID, now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(140).Equal(balance), "not 200 CAD less 10 USD: %s", balance)
+		acct, err := accountRepo.ByID(account.ID)
+		require.NoError(t, err)
+		assert.True(t, acct.Balance.Equal(balance))
+
+		balance, err = repo.BalanceAsOf(account.ID, now.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		assert.True(t, balance.IsZero(), "before the change the account held nothing in USD: %s", balance)
+	})
+}
@@ -19,11 +19,25 @@ func SetupRoutes(a *app.App) http.Handler {
 	authH := handler.NewAuthHandler(a.AuthService, a.InviteService, a.SpaceService)
 	homeH := handler.NewHomeHandler()
 	settingsH := handler.NewSettingsHandler(a.AuthService, a.UserService)
-	spaceH := handler.NewSpaceHandler(a.SpaceService, a.AccountService, a.TransactionService, a.CategoryService, a.AllocationService, a.InviteService, a.AuditLogService, a.TxAuditLogService, a.AccountActivitySvc, a.InvestmentService)
-	allocationH := handler.NewAllocationHandler(a.AllocationService, a.AccountService)
+	spaceH := handler.NewSpaceHandler(a.SpaceService, a.AccountService, a.TransactionService, a.CategoryService, a.CategoryTemplateSvc, a.CategoryLimitSvc, a.TagService, a.AllocationService, a.InviteService, a.AuditLogService, a.TxAuditLogService, a.AccountActivitySvc, a.InvestmentService, a.ReconciliationService, a.AttachmentService, a.ExchangeRateService)
+	allocationH := handler.NewAllocationHandler(a.AllocationService, a.AccountService, a.SpaceService)
 	recurringH := handler.NewRecurringEventHandler(a.RecurringEventService, a.AccountService, a.SpaceService)
 	investmentH := handler.NewInvestmentHandler(a.AccountService, a.SpaceService, a.InvestmentService)
 	planH := handler.NewBudgetPlanHandler(a.BudgetPlanService, a.SpaceService)
+	tagH := handler.NewTagHandler(a.TagService, a.SpaceService)
+	templateH := handler.NewCategoryTemplateHandler(a.CategoryTemplateSvc, a.AccountService, a.SpaceService)
+	importH := handler.NewImportHandler(a.ImportService, a.AccountService, a.SpaceService)
+	exportH := handler.NewExportHandler(a.ExportService, a.AccountService, a.SpaceService)
+	reconciliationH := handler.NewReconciliationHandler(a.ReconciliationService, a.AccountService, a.SpaceService)
+	attachmentH := handler.NewAttachmentHandler(a.AttachmentService, a.AccountService, a.TransactionService)
+	limitH := handler.NewCategoryLimitHandler(a.CategoryLimitSvc, a.CategoryService, a.AccountService, a.SpaceService)
+	envelopeH := handler.NewEnvelopeHandler(a.EnvelopeSvc, a.SpaceService)
+	ruleH := handler.NewCategorizationRuleHandler(a.CategorizationRuleSvc, a.CategoryService, a.AccountService, a.SpaceService)
+	searchH := handler.NewSearchHandler(a.SearchService, a.SpaceService)
+	netWorthH := handler.NewNetWorthHandler(a.NetWorthService, a.SpaceService)
+	ledgerH := handler.NewLedgerHandler(a.LedgerService, a.SpaceService, a.AccountService, a.ExchangeRateService)
+	rateH := handler.NewExchangeRateHandler(a.ExchangeRateService, a.SpaceService, a.CurrencyService)
+	loanH := handler.NewLoanHandler(a.LoanService, a.AccountService, a.SpaceService, a.RecurringEventService)
 	redirectH := handler.NewRedirectHandler()
 
 	r := router.New()
@@ -39,6 +53,7 @@ func SetupRoutes(a *app.App) http.Handler {
 		middleware.BlockPendingDeletion,
 		middleware.WithURLPath,
 		middleware.WithSidebarState,
+		middleware.WithLocale,
 	)
 
 	// Static assets (bypass router groups — registered directly on mux)
@@ -99,23 +114,39 @@ func SetupRoutes(a *app.App) http.Handler {
 
 		g.Get("/investments", investmentH.InvestmentsOverviewPage).Name("page.app.investments")
 
+		g.Get("/net-worth", netWorthH.NetWorthPage).Name("page.app.net-worth")
+
+		g.Get("/search", searchH.SearchPage).Name("page.app.search")
+
 		g.SubGroup("/spaces", func(g *router.Group) {
 			g.Get("", spaceH.SpacesPage).Name("page.app.spaces")
 			g.Get("/create", spaceH.CreateSpacePage).Name("page.app.spaces.create")
 			g.Post("/create", spaceH.HandleCreateSpace).Name("action.app.spaces.create")
 			g.SubGroup("/{spaceID}", func(g *router.Group) {
 				spaceAccessMw := middleware.RequireSpaceAccess(a.SpaceService)
-				g.Use(spaceAccessMw)
+				g.Use(spaceAccessMw, middleware.WithSpaceCurrencies(a.CurrencyService))
 				g.Get("/overview", spaceH.SpaceOverviewPage).Name("page.app.spaces.space.overview")
 				g.Get("/settings", spaceH.SpaceSettingsPage).Name("page.app.spaces.space.settings")
 				g.Post("/settings/rename", spaceH.HandleRenameSpace).Name("action.app.spaces.space.settings.rename")
 				g.Post("/settings/delete", spaceH.HandleDeleteSpace).Name("action.app.spaces.space.settings.delete")
+				g.Post("/settings/balances/{accountID}/repair", spaceH.HandleRepairBalance).Name("action.app.spaces.space.settings.balances.repair")
 				g.Get("/activity", spaceH.SpaceActivityPage).Name("page.app.spaces.space.activity")
+				g.Get("/search", searchH.SpaceSearchPage).Name("page.app.spaces.space.search")
+				g.Get("/ledger", ledgerH.LedgerPage).Name("page.app.spaces.space.ledger")
+				g.Get("/rates", rateH.RatesPage).Name("page.app.spaces.space.rates")
+				g.Post("/rates", rateH.HandleCreate).Name("action.app.spaces.space.rates.create")
+				g.Post("/rates/import", rateH.HandleImport).Name("action.app.spaces.space.rates.import")
+				g.Post("/rates/reporting-currency", rateH.HandleSetReportingCurrency).Name("action.app.spaces.space.rates.reporting-currency")
+				g.Post("/rates/{rateID}/delete", rateH.HandleDelete).Name("action.app.spaces.space.rates.rate.delete")
+				g.Post("/rates/currencies", rateH.HandleAddCurrency).Name("action.app.spaces.space.rates.currencies.create")
+				g.Post("/rates/currencies/{currencyID}/delete", rateH.HandleRemoveCurrency).Name("action.app.spaces.space.rates.currencies.currency.delete")
 				g.Get("/members", spaceH.SpaceMembersPage).Name("page.app.spaces.space.members")
 				g.Post("/members/invite", spaceH.HandleInviteMember).Name("action.app.spaces.space.members.invite")
 				g.Post("/members/{userID}/remove", spaceH.HandleRemoveMember).Name("action.app.spaces.space.members.remove")
 				g.Post("/invitations/{token}/cancel", spaceH.HandleCancelInvite).Name("action.app.spaces.space.invitations.cancel")
 
+				g.Get("/transactions/export", exportH.ExportSpaceTransactions).Name("page.app.spaces.space.transactions.export")
+
 				g.Get("/accounts/create", spaceH.SpaceCreateAccountPage).Name("page.app.spaces.space.accounts.create")
 				g.Post("/accounts/create", spaceH.HandleCreateAccount).Name("action.app.spaces.space.accounts.create")
 
@@ -137,20 +168,52 @@ func SetupRoutes(a *app.App) http.Handler {
 				g.Post("/plans/{planID}/lines/{lineID}", planH.HandleUpdateLine).Name("action.app.spaces.space.plans.plan.lines.line.update")
 				g.Post("/plans/{planID}/lines/{lineID}/delete", planH.HandleDeleteLine).Name("action.app.spaces.space.plans.plan.lines.line.delete")
 
+				g.Get("/tags", tagH.ListPage).Name("page.app.spaces.space.tags")
+				g.Post("/tags", tagH.HandleCreate).Name("action.app.spaces.space.tags.create")
+				g.Post("/tags/{tagID}/rename", tagH.HandleRename).Name("action.app.spaces.space.tags.tag.rename")
+				g.Post("/tags/{tagID}/merge", tagH.HandleMerge).Name("action.app.spaces.space.tags.tag.merge")
+				g.Post("/tags/{tagID}/delete", tagH.HandleDelete).Name("action.app.spaces.space.tags.tag.delete")
+
+				g.Get("/category-templates", templateH.ListPage).Name("page.app.spaces.space.category-templates")
+				g.Post("/category-templates", templateH.HandleCreate).Name("action.app.spaces.space.category-templates.create")
+				g.Post("/category-templates/{templateID}/delete", templateH.HandleDelete).Name("action.app.spaces.space.category-templates.template.delete")
+				g.Post("/category-templates/{templateID}/items", templateH.HandleAddItem).Name("action.app.spaces.space.category-templates.template.items.create")
+				g.Post("/category-templates/{templateID}/items/{itemID}/delete", templateH.HandleDeleteItem).Name("action.app.spaces.space.category-templates.template.items.item.delete")
+
+				g.Get("/reports", spaceH.SpaceCategoryReportPage).Name("page.app.spaces.space.reports")
+
+				g.Get("/envelopes", envelopeH.Page).Name("page.app.spaces.space.envelopes")
+				g.Post("/envelopes/enable", envelopeH.HandleEnable).Name("action.app.spaces.space.envelopes.enable")
+				g.Post("/envelopes/disable", envelopeH.HandleDisable).Name("action.app.spaces.space.envelopes.disable")
+				g.Post("/envelopes/assign", envelopeH.HandleAssign).Name("action.app.spaces.space.envelopes.assign")
+				g.Post("/envelopes/move", envelopeH.HandleMove).Name("action.app.spaces.space.envelopes.move")
+
 				g.SubGroup("/accounts/{accountID}", func(g *router.Group) {
 					g.Get("/overview", spaceH.SpaceAccountPage).Name("page.app.spaces.space.accounts.account.overview")
 					g.Get("/activity", spaceH.SpaceAccountActivityPage).Name("page.app.spaces.space.accounts.account.activity")
 					g.Get("/transactions", spaceH.SpaceAccountTransactionsPage).Name("page.app.spaces.space.accounts.account.transactions")
+					g.Get("/transactions/export", exportH.ExportAccountTransactions).Name("page.app.spaces.space.accounts.account.transactions.export")
+					g.Post("/transactions/recategorize", spaceH.HandleRecategorizeTransactions).Name("action.app.spaces.space.accounts.account.transactions.recategorize")
 					g.Get("/transactions/{transactionID}", spaceH.SpaceTransactionPage).Name("page.app.spaces.space.accounts.account.transactions.transaction")
 					g.Get("/transactions/{transactionID}/edit", spaceH.SpaceEditTransactionPage).Name("page.app.spaces.space.accounts.account.transactions.transaction.edit")
 					g.Post("/transactions/{transactionID}/edit", spaceH.HandleEditTransaction).Name("action.app.spaces.space.accounts.account.transactions.transaction.edit")
 					g.Post("/transactions/{transactionID}/delete", spaceH.HandleDeleteTransaction).Name("action.app.spaces.space.accounts.account.transactions.transaction.delete")
+					g.Post("/transactions/{transactionID}/unlock", spaceH.HandleUnlockTransaction).Name("action.app.spaces.space.accounts.account.transactions.transaction.unlock")
+					g.Post("/transactions/{transactionID}/attachments", attachmentH.HandleUpload).Name("action.app.spaces.space.accounts.account.transactions.transaction.attachments.upload")
+					g.Get("/transactions/{transactionID}/attachments/{attachmentID}", attachmentH.ServeAttachment).Name("page.app.spaces.space.accounts.account.transactions.transaction.attachments.attachment")
+					g.Post("/transactions/{transactionID}/attachments/{attachmentID}/delete", attachmentH.HandleDelete).Name("action.app.spaces.space.accounts.account.transactions.transaction.attachments.attachment.delete")
+					g.Get("/transactions/{transactionID}/transfer/edit", spaceH.SpaceEditTransferPage).Name("page.app.spaces.space.accounts.account.transactions.transaction.transfer.edit")
+					g.Post("/transactions/{transactionID}/transfer/edit", spaceH.HandleEditTransfer).Name("action.app.spaces.space.accounts.account.transactions.transaction.transfer.edit")
+					g.Post("/transactions/{transactionID}/transfer/undo", spaceH.HandleUndoTransfer).Name("action.app.spaces.space.accounts.account.transactions.transaction.transfer.undo")
 					g.Get("/transactions/{transactionID}/activity", spaceH.SpaceTransactionActivityPage).Name("page.app.spaces.space.accounts.account.transactions.transaction.activity")
 					g.Get("/settings", spaceH.SpaceAccountSettingsPage).Name("page.app.spaces.space.accounts.account.settings")
 					g.Post("/settings/rename", spaceH.HandleRenameAccount).Name("action.app.spaces.space.accounts.account.settings.rename")
 					g.Post("/settings/currency", spaceH.HandleChangeAccountCurrency).Name("action.app.spaces.space.accounts.account.settings.currency")
 					g.Post("/settings/delete", spaceH.HandleDeleteAccount).Name("action.app.spaces.space.accounts.account.settings.delete")
+					g.Post("/settings/archive", spaceH.HandleArchiveAccount).Name("action.app.spaces.space.accounts.account.settings.archive")
+					g.Post("/settings/unarchive", spaceH.HandleUnarchiveAccount).Name("action.app.spaces.space.accounts.account.settings.unarchive")
 					g.Post("/settings/investment", spaceH.HandleSetInvestmentFlag).Name("action.app.spaces.space.accounts.account.settings.investment")
+					g.Post("/settings/kind", spaceH.HandleSetAccountKind).Name("action.app.spaces.space.accounts.account.settings.kind")
 					g.Get("/bills/create", spaceH.SpaceCreateBillPage).Name("page.app.spaces.space.accounts.account.bills.create")
 					g.Post("/bills/create", spaceH.HandleCreateBill).Name("action.app.spaces.space.accounts.account.bills.create")
 					g.Get("/deposits/create", spaceH.SpaceCreateDepositPage).Name("page.app.spaces.space.accounts.account.deposits.create")
@@ -158,14 +221,48 @@ func SetupRoutes(a *app.App) http.Handler {
 					g.Get("/transfers/create", spaceH.SpaceCreateTransferPage).Name("page.app.spaces.space.accounts.account.transfers.create")
 					g.Post("/transfers/create", spaceH.HandleCreateTransfer).Name("action.app.spaces.space.accounts.account.transfers.create")
 
+					g.Get("/import", importH.ImportPage).Name("page.app.spaces.space.accounts.account.import")
+					g.Post("/import/preview", importH.HandlePreview).Name("action.app.spaces.space.accounts.account.import.preview")
+					g.Post("/import/commit", importH.HandleCommit).Name("action.app.spaces.space.accounts.account.import.commit")
+					g.Get("/import/batches/{batchID}", importH.ImportBatchPage).Name("page.app.spaces.space.accounts.account.import.batch")
+					g.Post("/import/batches/{batchID}/rollback", importH.HandleRollbackBatch).Name("action.app.spaces.space.accounts.account.import.batch.rollback")
+
+					g.Get("/reconcile", reconciliationH.ReconcilePage).Name("page.app.spaces.space.accounts.account.reconcile")
+					g.Post("/reconcile/start", reconciliationH.HandleStart).Name("action.app.spaces.space.accounts.account.reconcile.start")
+					g.Post("/reconcile/{reconciliationID}/transactions/{transactionID}/toggle", reconciliationH.HandleToggle).Name("action.app.spaces.space.accounts.account.reconcile.toggle")
+					g.Post("/reconcile/{reconciliationID}/finalize", reconciliationH.HandleFinalize).Name("action.app.spaces.space.accounts.account.reconcile.finalize")
+					g.Post("/reconcile/{reconciliationID}/cancel", reconciliationH.HandleCancel).Name("action.app.spaces.space.accounts.account.reconcile.cancel")
+
+					g.Get("/loan", loanH.LoanPage).Name("page.app.spaces.space.accounts.account.loan")
+					g.Post("/loan/terms", loanH.HandleSetTerms).Name("action.app.spaces.space.accounts.account.loan.terms")
+					g.Post("/loan/prepayment", loanH.HandlePrepayment).Name("action.app.spaces.space.accounts.account.loan.prepayment")
+					g.Post("/loan/schedule", loanH.HandleSchedulePayments).Name("action.app.spaces.space.accounts.account.loan.schedule")
+
 					g.Get("/categories", spaceH.SpaceCategoriesPage).Name("page.app.spaces.space.accounts.account.categories")
 					g.Post("/categories", spaceH.HandleCreateCategory).Name("action.app.spaces.space.accounts.account.categories.create")
+					g.Post("/categories/apply-template", spaceH.HandleApplyCategoryTemplate).Name("action.app.spaces.space.accounts.account.categories.apply-template")
+					g.Post("/categories/copy", spaceH.HandleCopyCategories).Name("action.app.spaces.space.accounts.account.categories.copy")
 					g.Post("/categories/{categoryID}/delete", spaceH.HandleDeleteCategory).Name("action.app.spaces.space.accounts.account.categories.delete")
+					g.Post("/categories/{categoryID}/edit", spaceH.HandleEditCategory).Name("action.app.spaces.space.accounts.account.categories.category.edit")
+					g.Post("/categories/{categoryID}/merge", spaceH.HandleMergeCategory).Name("action.app.spaces.space.accounts.account.categories.category.merge")
+
+					g.Get("/budget", limitH.BudgetPage).Name("page.app.spaces.space.accounts.account.budget")
+					g.Post("/budget/limits", limitH.HandleSetLimit).Name("action.app.spaces.space.accounts.account.budget.limits")
+
+					g.Get("/rules", ruleH.RulesPage).Name("page.app.spaces.space.accounts.account.rules")
+					g.Post("/rules", ruleH.HandleCreate).Name("action.app.spaces.space.accounts.account.rules.create")
+					g.Post("/rules/preview", ruleH.HandlePreview).Name("action.app.spaces.space.accounts.account.rules.preview")
+					g.Post("/rules/apply", ruleH.HandleApply).Name("action.app.spaces.space.accounts.account.rules.apply")
+					g.Post("/rules/{ruleID}/edit", ruleH.HandleEdit).Name("action.app.spaces.space.accounts.account.rules.rule.edit")
+					g.Post("/rules/{ruleID}/delete", ruleH.HandleDelete).Name("action.app.spaces.space.accounts.account.rules.rule.delete")
 
 					g.Get("/reports", spaceH.SpaceReportsPage).Name("page.app.spaces.space.accounts.account.reports")
 
 					g.Post("/allocations/create", allocationH.HandleCreate).Name("action.app.spaces.space.accounts.account.allocations.create")
+					g.Get("/allocations/{allocationID}", allocationH.DetailPage).Name("page.app.spaces.space.accounts.account.allocations.allocation")
 					g.Post("/allocations/{allocationID}/edit", allocationH.HandleEdit).Name("action.app.spaces.space.accounts.account.allocations.allocation.edit")
+					g.Post("/allocations/{allocationID}/movements", allocationH.HandleRecordMovement).Name("action.app.spaces.space.accounts.account.allocations.allocation.movements.create")
+					g.Post("/allocations/{allocationID}/transfer", allocationH.HandleTransfer).Name("action.app.spaces.space.accounts.account.allocations.allocation.transfer")
 					g.Post("/allocations/{allocationID}/delete", allocationH.HandleDelete).Name("action.app.spaces.space.accounts.account.allocations.allocation.delete")
 
 					g.Post("/investments/contribution-room", investmentH.HandleSetContributionRoom).Name("action.app.spaces.space.accounts.account.investments.contribution-room")
@@ -175,6 +272,8 @@ func SetupRoutes(a *app.App) http.Handler {
 					g.Post("/investments/holdings/{holdingID}/delete", investmentH.HandleDeleteHolding).Name("action.app.spaces.space.accounts.account.investments.holdings.holding.delete")
 					g.Post("/investments/holdings/{holdingID}/trades/create", investmentH.HandleCreateTrade).Name("action.app.spaces.space.accounts.account.investments.holdings.holding.trades.create")
 					g.Post("/investments/holdings/{holdingID}/trades/{tradeID}/delete", investmentH.HandleDeleteTrade).Name("action.app.spaces.space.accounts.account.investments.holdings.holding.trades.trade.delete")
+					g.Post("/investments/holdings/{holdingID}/prices", investmentH.HandleRecordPrice).Name("action.app.spaces.space.accounts.account.investments.holdings.holding.prices.create")
+					g.Post("/investments/holdings/{holdingID}/prices/{priceDate}/delete", investmentH.HandleDeletePrice).Name("action.app.spaces.space.accounts.account.investments.holdings.holding.prices.price.delete")
 				})
 			})
 		})
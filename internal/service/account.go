@@ -1,7 +1,10 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
@@ -13,10 +16,30 @@ import (
 
 const DefaultAccountName = "Money Account"
 
+// ErrAccountHasAllocations is returned when an account with savings goals
+// would become a liability. Goals set money aside, which a debt doesn't have.
+var ErrAccountHasAllocations = errors.New("account has savings goals")
+
+// ErrLoanAccountKind is returned when an account would be switched to or from
+// the loan kind through SetKind. Loans are set up with their terms through
+// LoanService.SetUpLoan and stay loans.
+var ErrLoanAccountKind = errors.New("loan accounts are set up through their loan terms")
+
+// ErrAccountArchived is returned when money would move in or out of an
+// archived account.
+var ErrAccountArchived = errors.New("account is archived")
+
+// ErrArchiveNonZeroBalance is returned when an account with money in it (or
+// owing money) is archived without acknowledging the balance.
+var ErrArchiveNonZeroBalance = errors.New("account balance is not zero")
+
 type AccountService struct {
 	accountRepo    repository.AccountRepository
 	allocationRepo repository.AllocationRepository
 	auditSvc       *SpaceAuditLogService
+	rateSvc        *ExchangeRateService
+	currencySvc    *CurrencyService
+	templateSvc    *CategoryTemplateService
 }
 
 func NewAccountService(accountRepo repository.AccountRepository) *AccountService {
@@ -34,16 +57,90 @@ func (s *AccountService) SetAuditLogger(audit *SpaceAuditLogService) {
 	s.auditSvc = audit
 }
 
+// SetExchangeRateService wires the rate store so currency changes remember
+// the rate they used.
+func (s *AccountService) SetExchangeRateService(rates *ExchangeRateService) {
+	s.rateSvc = rates
+}
+
+// currencyOf returns the currency the account is held in, for rounding and
+// audit metadata.
+func (s *AccountService) currencyOf(account *model.Account) currency.Currency {
+	return s.currencySvc.Get(account.SpaceID, account.Currency)
+}
+
+// SetCurrencyService wires the space currency registry so accounts can be
+// held in a space's own currencies. Without it only ISO 4217 codes are
+// accepted.
+func (s *AccountService) SetCurrencyService(currencies *CurrencyService) {
+	s.currencySvc = currencies
+}
+
+// SetCategoryTemplateService wires the category templates new accounts can
+// be seeded from.
+func (s *AccountService) SetCategoryTemplateService(templates *CategoryTemplateService) {
+	s.templateSvc = templates
+}
+
 // CreateAccountInput captures all the fields the caller can set when creating
 // an account. isInvestment + investmentSubtype are optional; if isInvestment is
-// false the subtype is forced to nil.
+// false the subtype is forced to nil. Kind defaults to cash; the credit terms
+// only apply to liability kinds.
 type CreateAccountInput struct {
 	SpaceID           string
 	Name              string
 	CurrencyCode      string
 	IsInvestment      bool
 	InvestmentSubtype string // canonical lowercase string; ignored if IsInvestment is false
-	ActorID           string
+	Kind              model.AccountKind
+	Credit            CreditTerms
+	// CategoryTemplateID seeds the account's categories from one of the
+	// space's templates, or model.DefaultCategoryTemplateID for the built-in
+	// one. Empty starts the account without categories.
+	CategoryTemplateID string
+	ActorID            string
+}
+
+// CreditTerms are the optional details of a credit card or line of credit.
+// Days are days of the month, 1 to 31.
+type CreditTerms struct {
+	CreditLimit         *decimal.Decimal
+	StatementClosingDay *int
+	PaymentDueDay       *int
+}
+
+// applyKind validates kind and terms and sets them on account. Cash accounts
+// carry no credit terms, and a liability can't be an investment account.
+func applyKind(account *model.Account, kind model.AccountKind, terms CreditTerms) error {
+	if kind == "" {
+		kind = model.AccountKindCash
+	}
+	if !model.IsValidAccountKind(string(kind)) {
+		return fmt.Errorf("invalid account kind: %s", kind)
+	}
+	if kind == model.AccountKindLoan {
+		return ErrLoanAccountKind
+	}
+	account.Kind = kind
+	account.CreditLimit, account.StatementClosingDay, account.PaymentDueDay = nil, nil, nil
+	if !kind.IsLiability() {
+		return nil
+	}
+	if account.IsInvestment {
+		return fmt.Errorf("an investment account can't be a %s", strings.ToLower(kind.Label()))
+	}
+	if terms.CreditLimit != nil && terms.CreditLimit.IsNegative() {
+		return fmt.Errorf("credit limit cannot be negative")
+	}
+	for _, day := range []*int{terms.StatementClosingDay, terms.PaymentDueDay} {
+		if day != nil && (*day < 1 || *day > 31) {
+			return fmt.Errorf("day of month must be between 1 and 31")
+		}
+	}
+	account.CreditLimit = terms.CreditLimit
+	account.StatementClosingDay = terms.StatementClosingDay
+	account.PaymentDueDay = terms.PaymentDueDay
+	return nil
 }
 
 func (s *AccountService) CreateAccount(input CreateAccountInput) (*model.Account, error) {
@@ -58,8 +155,11 @@ func (s *AccountService) CreateAccount(input CreateAccountInput) (*model.Account
 	if code == "" {
 		code = currency.Default
 	}
-	if !currency.IsValid(code) {
-		return nil, fmt.Errorf("unsupported currency code: %s", input.CurrencyCode)
+	if _, err := s.currencySvc.Lookup(input.SpaceID, code); err != nil {
+		if errors.Is(err, ErrUnsupportedCurrency) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, input.CurrencyCode)
+		}
+		return nil, err
 	}
 
 	var subtypePtr *string
@@ -82,6 +182,25 @@ func (s *AccountService) CreateAccount(input CreateAccountInput) (*model.Account
 		CreatedAt:         now,
 		UpdatedAt:         now,
 	}
+	if err := applyKind(account, input.Kind, input.Credit); err != nil {
+		return nil, err
+	}
+	var (
+		template   *model.CategoryTemplate
+		categories []*model.Category
+	)
+	if input.CategoryTemplateID != "" {
+		if s.templateSvc == nil {
+			return nil, ErrCategoryTemplateNotFound
+		}
+		var err error
+		if template, err = s.templateSvc.Get(input.SpaceID, input.CategoryTemplateID); err != nil {
+			return nil, err
+		}
+		if categories, err = s.templateSvc.Categories(input.SpaceID, input.CategoryTemplateID); err != nil {
+			return nil, err
+		}
+	}
 	if err := s.accountRepo.Create(account); err != nil {
 		return nil, fmt.Errorf("failed to create account: %w", err)
 	}
@@ -96,15 +215,89 @@ func (s *AccountService) CreateAccount(input CreateAccountInput) (*model.Account
 			meta["investment_subtype"] = *subtypePtr
 		}
 	}
+	if account.IsLiability() {
+		meta["kind"] = string(account.Kind)
+	}
 	s.auditSvc.Record(RecordOptions{
 		SpaceID:  input.SpaceID,
 		ActorID:  input.ActorID,
 		Action:   model.SpaceAuditActionAccountCreated,
 		Metadata: meta,
 	})
+	if template != nil {
+		if _, err := s.templateSvc.categories.Import(account.ID, categories, template.Name, input.ActorID); err != nil {
+			if delErr := s.accountRepo.Delete(account.ID); delErr != nil {
+				slog.Error("failed to roll back account after seeding its categories failed",
+					"account_id", account.ID, "error", delErr)
+			}
+			return nil, fmt.Errorf("failed to seed categories: %w", err)
+		}
+	}
 	return account, nil
 }
 
+// SetKind changes the account's kind and credit terms. The balance is kept
+// as is: a cash account turned into a credit card reads its negative balance
+// as the amount owed. Savings goals only make sense on cash, so an account
+// that still has some can't become a liability.
+func (s *AccountService) SetKind(accountID string, kind model.AccountKind, terms CreditTerms, actorID string) error {
+	if accountID == "" {
+		return fmt.Errorf("account id is required")
+	}
+	account, err := s.accountRepo.ByID(accountID)
+	if err != nil {
+		return fmt.Errorf("failed to load account: %w", err)
+	}
+	oldKind := account.Kind
+	if oldKind == model.AccountKindLoan {
+		return ErrLoanAccountKind
+	}
+	if err := applyKind(account, kind, terms); err != nil {
+		return err
+	}
+	if account.IsLiability() {
+		hasAllocations, err := s.hasAllocations(accountID)
+		if err != nil {
+			return err
+		}
+		if hasAllocations {
+			return ErrAccountHasAllocations
+		}
+	}
+	if err := s.accountRepo.SetKind(account); err != nil {
+		return fmt.Errorf("failed to update account kind: %w", err)
+	}
+	meta := map[string]any{
+		"account_id":   accountID,
+		"account_name": account.Name,
+		"old_kind":     string(oldKind),
+		"new_kind":     string(account.Kind),
+	}
+	if account.CreditLimit != nil {
+		meta["credit_limit"] = s.currencyOf(account).Fixed(*account.CreditLimit)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID:  account.SpaceID,
+		ActorID:  actorID,
+		Action:   model.SpaceAuditActionAccountKindChanged,
+		Metadata: meta,
+	})
+	return nil
+}
+
+// hasAllocations reports whether the account has savings goals, which a
+// liability can't carry.
+func (s *AccountService) hasAllocations(accountID string) (bool, error) {
+	if s.allocationRepo == nil {
+		return false, nil
+	}
+	allocations, err := s.allocationRepo.ByAccountID(accountID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load allocations: %w", err)
+	}
+	return len(allocations) > 0, nil
+}
+
 // SetInvestmentFlag toggles the investment flag on an existing account. When
 // turning the flag off, the subtype is cleared.
 func (s *AccountService) SetInvestmentFlag(accountID string, isInvestment bool, subtype string, actorID string) error {
@@ -118,6 +311,9 @@ func (s *AccountService) SetInvestmentFlag(accountID string, isInvestment bool,
 
 	var subtypePtr *string
 	if isInvestment {
+		if account.IsLiability() {
+			return fmt.Errorf("a %s can't be an investment account", strings.ToLower(account.Kind.Label()))
+		}
 		if !model.IsValidInvestmentSubtype(subtype) {
 			return fmt.Errorf("invalid investment subtype: %s", subtype)
 		}
@@ -216,9 +412,72 @@ func (s *AccountService) DeleteAccount(id, actorID string) error {
 	return nil
 }
 
+// ArchiveAccount closes an account. It disappears from the space overview and
+// account pickers and takes no new transactions, but its history stays in
+// reports, search and exports. Its recurring events are paused. An account
+// with a balance is only archived when acknowledgeBalance is set.
+func (s *AccountService) ArchiveAccount(id, actorID string, acknowledgeBalance bool) error {
+	if id == "" {
+		return fmt.Errorf("account id is required")
+	}
+	account, err := s.accountRepo.ByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to load account: %w", err)
+	}
+	if account.IsArchived() {
+		return nil
+	}
+	balance, err := s.accountRepo.Archive(id, time.Now(), acknowledgeBalance)
+	if errors.Is(err, repository.ErrAccountHasBalance) {
+		return ErrArchiveNonZeroBalance
+	}
+	if err != nil {
+		return fmt.Errorf("failed to archive account: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: account.SpaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionAccountArchived,
+		Metadata: map[string]any{
+			"account_id":   id,
+			"account_name": account.Name,
+			"balance":      s.currencyOf(account).Fixed(balance),
+		},
+	})
+	return nil
+}
+
+// UnarchiveAccount reopens an archived account. Recurring events paused by
+// archiving are left for the user to resume.
+func (s *AccountService) UnarchiveAccount(id, actorID string) error {
+	if id == "" {
+		return fmt.Errorf("account id is required")
+	}
+	account, err := s.accountRepo.ByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to load account: %w", err)
+	}
+	if !account.IsArchived() {
+		return nil
+	}
+	if err := s.accountRepo.Unarchive(id); err != nil {
+		return fmt.Errorf("failed to unarchive account: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: account.SpaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionAccountUnarchived,
+		Metadata: map[string]any{
+			"account_id":   id,
+			"account_name": account.Name,
+		},
+	})
+	return nil
+}
+
 // ChangeCurrency converts the account's currency. Every value held in the old
 // currency (account balance, allocation amounts and targets) is multiplied by
-// rate and rounded to 2 decimals. The whole change is applied in a single SQL
+// rate and rounded to the new currency's minor units. The whole change is applied in a single SQL
 // transaction so the account never appears in a half-converted state.
 //
 // rate is "1 oldCurrency = rate newCurrency". Same-currency changes are
@@ -228,9 +487,6 @@ func (s *AccountService) ChangeCurrency(accountID, newCurrencyCode string, rate
 		return fmt.Errorf("account id is required")
 	}
 	code := currency.Normalize(newCurrencyCode)
-	if !currency.IsValid(code) {
-		return fmt.Errorf("unsupported currency code: %s", newCurrencyCode)
-	}
 	if !rate.IsPositive() {
 		return fmt.Errorf("conversion rate must be greater than zero")
 	}
@@ -239,6 +495,14 @@ func (s *AccountService) ChangeCurrency(accountID, newCurrencyCode string, rate
 	if err != nil {
 		return fmt.Errorf("failed to load account: %w", err)
 	}
+	target, err := s.currencySvc.Lookup(account.SpaceID, code)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedCurrency) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedCurrency, newCurrencyCode)
+		}
+		return err
+	}
+	source := s.currencyOf(account)
 	if account.Currency == code {
 		return fmt.Errorf("account is already in %s", code)
 	}
@@ -248,23 +512,24 @@ func (s *AccountService) ChangeCurrency(accountID, newCurrencyCode string, rate
 		return fmt.Errorf("failed to load allocations: %w", err)
 	}
 
-	newBalance := account.Balance.Mul(rate).Round(2)
 	conversions := make([]repository.AllocationConversion, 0, len(allocations))
 	for _, a := range allocations {
 		c := repository.AllocationConversion{
 			ID:     a.ID,
-			Amount: a.Amount.Mul(rate).Round(2),
+			Amount: target.Round(a.Amount.Mul(rate)),
 		}
 		if a.TargetAmount != nil {
-			t := a.TargetAmount.Mul(rate).Round(2)
+			t := target.Round(a.TargetAmount.Mul(rate))
 			c.TargetAmount = &t
 		}
 		conversions = append(conversions, c)
 	}
 
-	if err := s.accountRepo.ChangeCurrency(accountID, code, newBalance, conversions); err != nil {
+	oldBalance, newBalance, err := s.accountRepo.ChangeCurrency(accountID, code, rate, target.MinorUnits, conversions)
+	if err != nil {
 		return fmt.Errorf("failed to change currency: %w", err)
 	}
+	s.rateSvc.Record(account.SpaceID, account.Currency, code, time.Now(), rate, model.ExchangeRateSourceCurrencyChange)
 
 	s.auditSvc.Record(RecordOptions{
 		SpaceID: account.SpaceID,
@@ -276,13 +541,15 @@ func (s *AccountService) ChangeCurrency(accountID, newCurrencyCode string, rate
 			"old_currency":    account.Currency,
 			"new_currency":    code,
 			"conversion_rate": rate.String(),
-			"old_balance":     account.Balance.StringFixedBank(2),
-			"new_balance":     newBalance.StringFixedBank(2),
+			"old_balance":     source.Fixed(oldBalance),
+			"new_balance":     target.Fixed(newBalance),
 		},
 	})
 	return nil
 }
 
+// GetAccountsForSpace returns every account in the space, archived ones
+// included, for reports and history.
 func (s *AccountService) GetAccountsForSpace(spaceID string) ([]*model.Account, error) {
 	accounts, err := s.accountRepo.BySpaceID(spaceID)
 	if err != nil {
@@ -290,3 +557,68 @@ func (s *AccountService) GetAccountsForSpace(spaceID string) ([]*model.Account,
 	}
 	return accounts, nil
 }
+
+// ActiveAccountsForSpace returns the space's open accounts, the ones money
+// can still move in and out of.
+func (s *AccountService) ActiveAccountsForSpace(spaceID string) ([]*model.Account, error) {
+	accounts, err := s.GetAccountsForSpace(spaceID)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]*model.Account, 0, len(accounts))
+	for _, a := range accounts {
+		if !a.IsArchived() {
+			active = append(active, a)
+		}
+	}
+	return active, nil
+}
+
+// CheckBalances recomputes every account's balance from its ledger postings
+// in the account's currency and returns the accounts whose stored balance
+// disagrees. Changing currency posts a conversion entry, so a converted
+// account only shows up here if it has really drifted.
+func (s *AccountService) CheckBalances() ([]*model.BalanceDrift, error) {
+	drift, err := s.accountRepo.ListBalanceDrift()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check balances: %w", err)
+	}
+	return drift, nil
+}
+
+// BalanceDriftForSpace is CheckBalances for one space's accounts.
+func (s *AccountService) BalanceDriftForSpace(spaceID string) ([]*model.BalanceDrift, error) {
+	drift, err := s.accountRepo.ListBalanceDriftBySpace(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check balances: %w", err)
+	}
+	return drift, nil
+}
+
+// RepairBalance resets the account's balance to the sum of its ledger postings
+// in its current currency and records the correction in the space audit log.
+func (s *AccountService) RepairBalance(accountID, actorID string) error {
+	account, err := s.accountRepo.ByID(accountID)
+	if err != nil {
+		return fmt.Errorf("failed to load account: %w", err)
+	}
+	oldBalance, newBalance, err := s.accountRepo.RepairBalance(accountID)
+	if err != nil {
+		return fmt.Errorf("failed to repair balance: %w", err)
+	}
+	if oldBalance.Equal(newBalance) {
+		return nil
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: account.SpaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionAccountBalanceRepaired,
+		Metadata: map[string]any{
+			"account_id":   accountID,
+			"account_name": account.Name,
+			"old_balance":  s.currencyOf(account).Fixed(oldBalance),
+			"new_balance":  s.currencyOf(account).Fixed(newBalance),
+		},
+	})
+	return nil
+}
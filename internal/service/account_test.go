@@ -3,10 +3,12 @@ package service
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -110,3 +112,245 @@ func TestAccountService_NoAuditLoggerSet_DoesNotPanic(t *testing.T) {
 		require.NoError(t, svc.DeleteAccount(account.ID, user.ID))
 	})
 }
+
+func TestAccountService_RepairBalance_FixesDriftAndRecordsAudit(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		accountRepo := repository.NewAccountRepository(dbi.DB)
+		auditRepo := repository.NewSpaceAuditLogRepository(dbi.DB)
+		svc := NewAccountService(accountRepo)
+		svc.SetAuditLogger(NewSpaceAuditLogService(auditRepo))
+
+		user := testutil.CreateTestUser(t, dbi.DB, "acct-repair-balance@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Checking")
+		testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Pay", model.TransactionTypeDeposit, decimal.NewFromInt(100))
+		testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Rent", model.TransactionTypeWithdrawal, decimal.RequireFromString("40.50"))
+		_, err := dbi.DB.Exec(`UPDATE accounts SET balance = '999' WHERE id = $1`, account.ID)
+		require.NoError(t, err)
+
+		drift, err := svc.BalanceDriftForSpace(space.ID)
+		require.NoError(t, err)
+		require.Len(t, drift, 1)
+		assert.Equal(t, account.ID, drift[0].AccountID)
+		assert.True(t, decimal.NewFromInt(999).Equal(drift[0].Stored))
+		assert.True(t, decimal.RequireFromString("59.50").Equal(drift[0].Computed))
+
+		require.NoError(t, svc.RepairBalance(account.ID, user.ID))
+		// A second repair finds nothing to change and records nothing.
+		require.NoError(t, svc.RepairBalance(account.ID, user.ID))
+
+		repaired, err := accountRepo.ByID(account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.RequireFromString("59.50").Equal(repaired.Balance))
+
+		drift, err = svc.CheckBalances()
+		require.NoError(t, err)
+		assert.Empty(t, drift)
+
+		logs, err := auditRepo.ListAccountEvents(account.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, model.SpaceAuditActionAccountBalanceRepaired, logs[0].Action)
+		var meta map[string]any
+		require.NoError(t, json.Unmarshal(logs[0].Metadata, &meta))
+		assert.Equal(t, "999.00", meta["old_balance"])
+		assert.Equal(t, "59.50", meta["new_balance"])
+	})
+}
+
+func TestAccountService_ChangeCurrency_LeavesNoDrift(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		svc := NewAccountService(f.accounts)
+		now := time.Now()
+
+		_, err := f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(200), OccurredAt: now, ActorID: f.user.ID})
+		require.NoError(t, err)
+		require.NoError(t, svc.ChangeCurrency(f.account.ID, "USD", decimal.RequireFromString("0.75"), f.user.ID))
+		_, err = f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(10), OccurredAt: now, ActorID: f.user.ID})
+		require.NoError(t, err)
+
+		drift, err := svc.BalanceDriftForSpace(f.account.SpaceID)
+		require.NoError(t, err)
+		assert.Empty(t, drift, "the conversion is posted, so the new balance agrees with the ledger")
+
+		require.NoError(t, svc.RepairBalance(f.account.ID, f.user.ID))
+		account, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(160).Equal(account.Balance), "repair leaves a converted balance alone")
+	})
+}
+
+func TestAccountService_CreditCard_IsALiability(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		accounts := NewAccountService(f.accounts)
+		ledger := NewLedgerService(repository.NewLedgerRepository(dbi.DB))
+		limit := decimal.NewFromInt(1000)
+		closing, due := 15, 5
+		card, err := accounts.CreateAccount(CreateAccountInput{
+			SpaceID: f.account.SpaceID, Name: "Visa", CurrencyCode: "CAD", Kind: model.AccountKindCreditCard,
+			Credit: CreditTerms{CreditLimit: &limit, StatementClosingDay: &closing, PaymentDueDay: &due},
+		})
+		require.NoError(t, err)
+
+		day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 12, 0, 0, 0, time.UTC) }
+		_, err = f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(500), OccurredAt: day(3, 1)})
+		require.NoError(t, err)
+		_, err = f.svc.PayBill(PayBillInput{AccountID: card.ID, Title: "Groceries", Amount: decimal.NewFromInt(300), OccurredAt: day(3, 10)})
+		require.NoError(t, err)
+		_, err = f.svc.Transfer(TransferInput{
+			SourceAccountID: f.account.ID, DestAccountID: card.ID, Title: "Card payment",
+			Amount: decimal.NewFromInt(200), OccurredAt: day(3, 20),
+		})
+		require.NoError(t, err)
+
+		card, err = accounts.GetAccount(card.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "100", card.Owed().String(), "the payment reduces what is owed")
+		assert.Equal(t, "900", card.AvailableCredit().String())
+		assert.Equal(t, "10", card.Utilization().String())
+
+		all, err := accounts.GetAccountsForSpace(f.account.SpaceID)
+		require.NoError(t, err)
+		totals := model.TotalsByCurrency(all)
+		require.Len(t, totals, 1)
+		assert.Equal(t, "300", totals[0].Assets.String())
+		assert.Equal(t, "100", totals[0].Liabilities.String())
+		assert.Equal(t, "200", totals[0].NetWorth().String())
+
+		sheets, err := ledger.BalanceSheets(f.account.SpaceID, day(3, 31))
+		require.NoError(t, err)
+		require.Len(t, sheets, 1)
+		bs := sheets[0]
+		assert.Equal(t, "300", bs.TotalAssets.String())
+		assert.Equal(t, "100", bs.TotalLiabilities.String())
+		require.Len(t, bs.Liabilities, 1)
+		assert.Equal(t, "Visa", bs.Liabilities[0].Name)
+
+		_, err = f.svc.Transfer(TransferInput{
+			SourceAccountID: card.ID, DestAccountID: f.account.ID, Title: "Cash advance",
+			Amount: decimal.NewFromInt(950), OccurredAt: day(3, 21),
+		})
+		assert.ErrorIs(t, err, ErrTransferExceedsAvailable, "transfers out of a card stop at the credit limit")
+
+		cycles, err := f.svc.StatementCycles(card, day(3, 25), 1)
+		require.NoError(t, err)
+		require.Len(t, cycles, 2)
+		open, closed := cycles[0], cycles[1]
+		assert.Equal(t, day(4, 15).Truncate(24*time.Hour), open.ClosingDate)
+		assert.Equal(t, "300", open.OpeningBalance.String())
+		assert.Equal(t, "200", open.Payments.String())
+		assert.Equal(t, "100", open.StatementBalance.String())
+		assert.Equal(t, day(2, 16).Truncate(24*time.Hour), closed.Start)
+		assert.Equal(t, "300", closed.Charges.String())
+		assert.Equal(t, "300", closed.StatementBalance.String())
+		require.NotNil(t, closed.DueDate)
+		assert.Equal(t, day(4, 5).Truncate(24*time.Hour), *closed.DueDate)
+	})
+}
+
+func TestAccountService_SetKind_MovesLedgerAccountAndGuardsGoals(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		accounts := NewAccountService(f.accounts)
+		accounts.SetAllocationRepository(repository.NewAllocationRepository(dbi.DB))
+		ledger := NewLedgerService(repository.NewLedgerRepository(dbi.DB))
+		line := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Line")
+		now := time.Now()
+
+		_, err := f.svc.PayBill(PayBillInput{AccountID: line.ID, Title: "Draw", Amount: decimal.NewFromInt(250), OccurredAt: now})
+		require.NoError(t, err)
+		require.NoError(t, accounts.SetKind(line.ID, model.AccountKindLineOfCredit, CreditTerms{}, f.user.ID))
+
+		sheets, err := ledger.BalanceSheets(f.account.SpaceID, now)
+		require.NoError(t, err)
+		require.Len(t, sheets, 1)
+		bs := sheets[0]
+		assert.Equal(t, "250", bs.TotalLiabilities.String(), "an existing ledger account moves to liabilities")
+		assert.True(t, bs.TotalAssets.IsZero())
+
+		_, err = NewAllocationService(repository.NewAllocationRepository(dbi.DB), accounts).Create(CreateAllocationInput{
+			AccountID: f.account.ID, Name: "Trip", Amount: decimal.Zero,
+		})
+		require.NoError(t, err)
+		err = accounts.SetKind(f.account.ID, model.AccountKindCreditCard, CreditTerms{}, f.user.ID)
+		assert.ErrorIs(t, err, ErrAccountHasAllocations)
+	})
+}
+
+func TestAccountService_ArchiveAccount(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		accountRepo := repository.NewAccountRepository(dbi.DB)
+		auditRepo := repository.NewSpaceAuditLogRepository(dbi.DB)
+		svc := NewAccountService(accountRepo)
+		svc.SetAuditLogger(NewSpaceAuditLogService(auditRepo))
+		txnSvc := NewTransactionService(repository.NewTransactionRepository(dbi.DB), repository.NewCategoryRepository(dbi.DB), repository.NewTagRepository(dbi.DB), svc)
+		recurringRepo := repository.NewRecurringEventRepository(dbi.DB)
+		recurring := NewRecurringEventService(recurringRepo, txnSvc, svc)
+
+		user := testutil.CreateTestUser(t, dbi.DB, "acct-archive@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Old chequing")
+		other := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Savings")
+		testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Paycheque", model.TransactionTypeDeposit, decimal.NewFromInt(25))
+
+		day := 1
+		ev, err := recurring.Create(CreateRecurringEventInput{
+			SpaceID:         space.ID,
+			Kind:            model.RecurringEventKindBill,
+			SourceAccountID: account.ID,
+			Title:           "Phone",
+			Amount:          decimal.NewFromInt(40),
+			Frequency:       model.RecurringFrequencyMonthly,
+			IntervalCount:   1,
+			DayOfMonth:      &day,
+			FireHour:        9,
+			Timezone:        "UTC",
+			StartDate:       time.Now(),
+		})
+		require.NoError(t, err)
+
+		// A balance has to be acknowledged.
+		err = svc.ArchiveAccount(account.ID, user.ID, false)
+		assert.ErrorIs(t, err, ErrArchiveNonZeroBalance)
+		require.NoError(t, svc.ArchiveAccount(account.ID, user.ID, true))
+
+		archived, err := svc.GetAccount(account.ID)
+		require.NoError(t, err)
+		assert.True(t, archived.IsArchived())
+
+		active, err := svc.ActiveAccountsForSpace(space.ID)
+		require.NoError(t, err)
+		require.Len(t, active, 1)
+		assert.Equal(t, other.ID, active[0].ID)
+		all, err := svc.GetAccountsForSpace(space.ID)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		// Its recurring events are paused and can't be resumed.
+		paused, err := recurringRepo.ByID(ev.ID)
+		require.NoError(t, err)
+		assert.True(t, paused.Paused)
+		assert.ErrorIs(t, recurring.SetPaused(ev.ID, false), ErrAccountArchived)
+
+		// No new money moves in or out.
+		_, err = txnSvc.Deposit(DepositInput{AccountID: account.ID, Title: "Refund", Amount: decimal.NewFromInt(5), OccurredAt: time.Now(), ActorID: user.ID})
+		assert.ErrorIs(t, err, ErrAccountArchived)
+		_, err = txnSvc.Transfer(TransferInput{SourceAccountID: other.ID, DestAccountID: account.ID, Title: "Top up", Amount: decimal.NewFromInt(5), OccurredAt: time.Now(), ActorID: user.ID})
+		assert.ErrorIs(t, err, ErrAccountArchived)
+
+		require.NoError(t, svc.UnarchiveAccount(account.ID, user.ID))
+		_, err = txnSvc.Deposit(DepositInput{AccountID: account.ID, Title: "Refund", Amount: decimal.NewFromInt(5), OccurredAt: time.Now(), ActorID: user.ID})
+		require.NoError(t, err)
+
+		logs, err := auditRepo.ListAccountEvents(account.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 2)
+		assert.Equal(t, model.SpaceAuditActionAccountUnarchived, logs[0].Action)
+		assert.Equal(t, model.SpaceAuditActionAccountArchived, logs[1].Action)
+		var meta map[string]any
+		require.NoError(t, json.Unmarshal(logs[1].Metadata, &meta))
+		assert.Equal(t, "25.00", meta["balance"])
+	})
+}
@@ -1,20 +1,35 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
+// ErrAllocationOverdrawn is returned when a withdrawal or transfer would take
+// more out of an allocation than it holds.
+var ErrAllocationOverdrawn = errors.New("the savings goal doesn't hold that much")
+
+// ErrInvalidAllocationMovement is returned for a movement that isn't a
+// contribution or withdrawal, moves nothing, or is dated in the future.
+var ErrInvalidAllocationMovement = errors.New("invalid savings goal movement")
+
+// ErrInvalidAllocationTransfer is returned when transferring between an
+// allocation and itself or one on another account.
+var ErrInvalidAllocationTransfer = errors.New("transfers are between two savings goals of the same account")
+
 type AllocationService struct {
-	repo           repository.AllocationRepository
-	accountService *AccountService
-	auditSvc       *SpaceAuditLogService
+	repo            repository.AllocationRepository
+	accountService  *AccountService
+	transactionRepo repository.TransactionRepository
+	auditSvc        *SpaceAuditLogService
 }
 
 func NewAllocationService(repo repository.AllocationRepository, accountService *AccountService) *AllocationService {
@@ -25,6 +40,12 @@ func (s *AllocationService) SetAuditLogger(audit *SpaceAuditLogService) {
 	s.auditSvc = audit
 }
 
+// SetTransactionRepository wires account balance history, which summaries
+// as of a past date need.
+func (s *AllocationService) SetTransactionRepository(repo repository.TransactionRepository) {
+	s.transactionRepo = repo
+}
+
 // AllocationSummary bundles the allocations for an account with derived totals
 // the UI cares about (Available cash, over-allocation flag).
 type AllocationSummary struct {
@@ -61,6 +82,9 @@ func (s *AllocationService) Create(input CreateAllocationInput) (*model.Allocati
 	if err != nil {
 		return nil, fmt.Errorf("failed to load account: %w", err)
 	}
+	if account.IsLiability() {
+		return nil, fmt.Errorf("savings goals can only be set on cash accounts")
+	}
 
 	now := time.Now()
 	a := &model.Allocation{
@@ -72,10 +96,15 @@ func (s *AllocationService) Create(input CreateAllocationInput) (*model.Allocati
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
-	if err := s.repo.Create(a); err != nil {
+	var opening []*model.AllocationMovement
+	if a.Amount.IsPositive() {
+		opening = append(opening, newAllocationMovement(a.ID, model.AllocationMovementContribution, a.Amount, input.ActorID, "", now))
+	}
+	if err := s.repo.Create(a, opening...); err != nil {
 		return nil, fmt.Errorf("failed to create allocation: %w", err)
 	}
 
+	cur := s.accountService.currencyOf(account)
 	s.auditSvc.Record(RecordOptions{
 		SpaceID: account.SpaceID,
 		ActorID: input.ActorID,
@@ -84,8 +113,8 @@ func (s *AllocationService) Create(input CreateAllocationInput) (*model.Allocati
 			"account_id":    a.AccountID,
 			"allocation_id": a.ID,
 			"name":          a.Name,
-			"amount":        a.Amount.StringFixedBank(2),
-			"target":        targetString(a.TargetAmount),
+			"amount":        cur.Fixed(a.Amount),
+			"target":        targetString(a.TargetAmount, cur),
 		},
 	})
 	return a, nil
@@ -123,24 +152,30 @@ func (s *AllocationService) Update(input UpdateAllocationInput) (*model.Allocati
 		return nil, fmt.Errorf("failed to load account: %w", err)
 	}
 
+	cur := s.accountService.currencyOf(account)
 	changes := map[string]any{}
 	if existing.Name != name {
 		changes["name"] = map[string]any{"old": existing.Name, "new": name}
 	}
 	if !existing.Amount.Equal(input.Amount) {
 		changes["amount"] = map[string]any{
-			"old": existing.Amount.StringFixedBank(2),
-			"new": input.Amount.StringFixedBank(2),
+			"old": cur.Fixed(existing.Amount),
+			"new": cur.Fixed(input.Amount),
 		}
 	}
 	if !decimalPtrEq(existing.TargetAmount, input.TargetAmount) {
 		changes["target"] = map[string]any{
-			"old": targetString(existing.TargetAmount),
-			"new": targetString(input.TargetAmount),
+			"old": targetString(existing.TargetAmount, cur),
+			"new": targetString(input.TargetAmount, cur),
 		}
 	}
 
-	if err := s.repo.Update(input.AllocationID, name, input.Amount, input.TargetAmount); err != nil {
+	// Editing the amount directly is an adjustment in the history.
+	var adjustments []*model.AllocationMovement
+	if delta := input.Amount.Sub(existing.Amount); !delta.IsZero() {
+		adjustments = append(adjustments, newAllocationMovement(existing.ID, model.AllocationMovementAdjustment, delta, input.ActorID, "", time.Now()))
+	}
+	if err := s.repo.Update(input.AllocationID, name, input.Amount, input.TargetAmount, adjustments...); err != nil {
 		return nil, fmt.Errorf("failed to update allocation: %w", err)
 	}
 
@@ -185,7 +220,7 @@ func (s *AllocationService) Delete(allocationID, actorID string) error {
 			"account_id":    existing.AccountID,
 			"allocation_id": existing.ID,
 			"name":          existing.Name,
-			"amount":        existing.Amount.StringFixedBank(2),
+			"amount":        s.accountService.currencyOf(account).Fixed(existing.Amount),
 		},
 	})
 	if err := s.repo.Delete(allocationID); err != nil {
@@ -226,11 +261,243 @@ func (s *AllocationService) SummaryForAccount(accountID string) (*AllocationSumm
 	}, nil
 }
 
-func targetString(t *decimal.Decimal) string {
+// SummaryForAccountAsOf is SummaryForAccount as it stood at the given time:
+// each allocation's amount then, against the account's balance then.
+// Allocations created later are left out unless money was backdated into
+// them; deleted ones are gone from the past too, their history with them.
+func (s *AllocationService) SummaryForAccountAsOf(accountID string, asOf time.Time) (*AllocationSummary, error) {
+	if s.transactionRepo == nil {
+		return nil, fmt.Errorf("balance history is not available")
+	}
+	balance, err := s.transactionRepo.BalanceAsOf(accountID, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load balance: %w", err)
+	}
+	allocs, err := s.repo.ByAccountID(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load allocations: %w", err)
+	}
+	later, err := s.repo.MovementsAfter(accountID, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load allocation history: %w", err)
+	}
+	undo := map[string]decimal.Decimal{}
+	for _, m := range later {
+		undo[m.AllocationID] = undo[m.AllocationID].Add(m.Amount)
+	}
+
+	var past []*model.Allocation
+	allocated := decimal.Zero
+	for _, a := range allocs {
+		then := *a
+		then.Amount = a.Amount.Sub(undo[a.ID])
+		if a.CreatedAt.After(asOf) && then.Amount.IsZero() {
+			continue
+		}
+		past = append(past, &then)
+		allocated = allocated.Add(then.Amount)
+	}
+	available := balance.Sub(allocated)
+	return &AllocationSummary{
+		Allocations: past,
+		Allocated:   allocated,
+		Available:   available,
+		Overflow:    available.IsNegative(),
+	}, nil
+}
+
+type RecordAllocationMovementInput struct {
+	AllocationID string
+	// Kind is a contribution or a withdrawal.
+	Kind model.AllocationMovementKind
+	// Amount is how much goes in or comes out; always positive.
+	Amount     decimal.Decimal
+	Note       string
+	OccurredAt time.Time
+	ActorID    string
+}
+
+// RecordMovement puts money into an allocation or takes it out, dated when
+// it happened.
+func (s *AllocationService) RecordMovement(input RecordAllocationMovementInput) (*model.AllocationMovement, error) {
+	if input.Kind != model.AllocationMovementContribution && input.Kind != model.AllocationMovementWithdrawal {
+		return nil, ErrInvalidAllocationMovement
+	}
+	if !input.Amount.IsPositive() || input.OccurredAt.After(time.Now()) {
+		return nil, ErrInvalidAllocationMovement
+	}
+	existing, err := s.repo.ByID(input.AllocationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load allocation: %w", err)
+	}
+	account, err := s.accountService.GetAccount(existing.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	amount := input.Amount
+	if input.Kind == model.AllocationMovementWithdrawal {
+		if amount.GreaterThan(existing.Amount) {
+			return nil, ErrAllocationOverdrawn
+		}
+		amount = amount.Neg()
+	}
+
+	m := newAllocationMovement(existing.ID, input.Kind, amount, input.ActorID, input.Note, input.OccurredAt)
+	if err := s.repo.Move(m); err != nil {
+		return nil, fmt.Errorf("failed to record movement: %w", err)
+	}
+
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: account.SpaceID,
+		ActorID: input.ActorID,
+		Action:  model.SpaceAuditActionAllocationMoneyMoved,
+		Metadata: map[string]any{
+			"account_id":    existing.AccountID,
+			"allocation_id": existing.ID,
+			"name":          existing.Name,
+			"kind":          string(input.Kind),
+			"amount":        s.accountService.currencyOf(account).Fixed(input.Amount),
+		},
+	})
+	return m, nil
+}
+
+type TransferAllocationInput struct {
+	FromAllocationID string
+	ToAllocationID   string
+	Amount           decimal.Decimal
+	Note             string
+	OccurredAt       time.Time
+	ActorID          string
+}
+
+// Transfer moves money from one allocation to another on the same account.
+// Each side gets a transfer movement pointing at the other.
+func (s *AllocationService) Transfer(input TransferAllocationInput) error {
+	if !input.Amount.IsPositive() || input.OccurredAt.After(time.Now()) {
+		return ErrInvalidAllocationMovement
+	}
+	if input.FromAllocationID == input.ToAllocationID {
+		return ErrInvalidAllocationTransfer
+	}
+	from, err := s.repo.ByID(input.FromAllocationID)
+	if err != nil {
+		return fmt.Errorf("failed to load allocation: %w", err)
+	}
+	to, err := s.repo.ByID(input.ToAllocationID)
+	if err != nil {
+		return fmt.Errorf("failed to load allocation: %w", err)
+	}
+	if from.AccountID != to.AccountID {
+		return ErrInvalidAllocationTransfer
+	}
+	if input.Amount.GreaterThan(from.Amount) {
+		return ErrAllocationOverdrawn
+	}
+	account, err := s.accountService.GetAccount(from.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to load account: %w", err)
+	}
+
+	out := newAllocationMovement(from.ID, model.AllocationMovementTransfer, input.Amount.Neg(), input.ActorID, input.Note, input.OccurredAt)
+	out.CounterpartID = &to.ID
+	in := newAllocationMovement(to.ID, model.AllocationMovementTransfer, input.Amount, input.ActorID, input.Note, input.OccurredAt)
+	in.CounterpartID = &from.ID
+	if err := s.repo.Move(out, in); err != nil {
+		return fmt.Errorf("failed to transfer between allocations: %w", err)
+	}
+
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: account.SpaceID,
+		ActorID: input.ActorID,
+		Action:  model.SpaceAuditActionAllocationTransferred,
+		Metadata: map[string]any{
+			"account_id": from.AccountID,
+			"from_id":    from.ID,
+			"from_name":  from.Name,
+			"to_id":      to.ID,
+			"to_name":    to.Name,
+			"amount":     s.accountService.currencyOf(account).Fixed(input.Amount),
+		},
+	})
+	return nil
+}
+
+// AllocationHistory is an allocation with every movement that shaped it.
+type AllocationHistory struct {
+	Allocation *model.Allocation
+	// Movements are newest first.
+	Movements []*model.AllocationMovementWithActor
+}
+
+// AllocationPoint is what an allocation held at the end of a day.
+type AllocationPoint struct {
+	Day    time.Time
+	Amount decimal.Decimal
+}
+
+// Points walks the history back from the current amount and returns what
+// the allocation held at the end of each day something moved, oldest first,
+// ending today.
+func (h *AllocationHistory) Points(now time.Time) []AllocationPoint {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	points := []AllocationPoint{{Day: today, Amount: h.Allocation.Amount}}
+	amount := h.Allocation.Amount
+	for _, m := range h.Movements {
+		at := m.OccurredAt
+		day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+		if last := points[len(points)-1]; !last.Day.Equal(day) {
+			points = append(points, AllocationPoint{Day: day, Amount: amount})
+		}
+		amount = amount.Sub(m.Amount)
+	}
+	// Start from what it held before its first movement, usually nothing.
+	if n := len(h.Movements); n > 0 {
+		first := h.Movements[n-1].OccurredAt
+		day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+		points = append(points, AllocationPoint{Day: day.AddDate(0, 0, -1), Amount: amount})
+	}
+	// Reverse into oldest first.
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	return points
+}
+
+// History returns an allocation and its movements.
+func (s *AllocationService) History(allocationID string) (*AllocationHistory, error) {
+	a, err := s.repo.ByID(allocationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load allocation: %w", err)
+	}
+	movements, err := s.repo.Movements(allocationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load allocation history: %w", err)
+	}
+	return &AllocationHistory{Allocation: a, Movements: movements}, nil
+}
+
+func newAllocationMovement(allocationID string, kind model.AllocationMovementKind, amount decimal.Decimal, actorID, note string, occurredAt time.Time) *model.AllocationMovement {
+	m := &model.AllocationMovement{
+		ID:           uuid.NewString(),
+		AllocationID: allocationID,
+		Kind:         kind,
+		Amount:       amount,
+		Note:         strings.TrimSpace(note),
+		OccurredAt:   occurredAt,
+		CreatedAt:    time.Now(),
+	}
+	if actorID != "" {
+		m.ActorID = &actorID
+	}
+	return m
+}
+
+func targetString(t *decimal.Decimal, cur currency.Currency) string {
 	if t == nil {
 		return ""
 	}
-	return t.StringFixedBank(2)
+	return cur.Fixed(*t)
 }
 
 func decimalPtrEq(a, b *decimal.Decimal) bool {
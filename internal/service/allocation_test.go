@@ -0,0 +1,108 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAllocationFixture(t *testing.T, dbi testutil.DBInfo) (*txnFixture, *AllocationService) {
+	t.Helper()
+	f := newTxnFixture(t, dbi)
+	svc := NewAllocationService(repository.NewAllocationRepository(dbi.DB), NewAccountService(f.accounts))
+	svc.SetTransactionRepository(repository.NewTransactionRepository(dbi.DB))
+	return f, svc
+}
+
+func TestAllocationService_RecordsMovements(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc := newAllocationFixture(t, dbi)
+
+		fund, err := svc.Create(CreateAllocationInput{AccountID: f.account.ID, Name: "Emergency", Amount: decimal.NewFromInt(100), ActorID: f.user.ID})
+		require.NoError(t, err)
+		trip, err := svc.Create(CreateAllocationInput{AccountID: f.account.ID, Name: "Trip", Amount: decimal.Zero, ActorID: f.user.ID})
+		require.NoError(t, err)
+
+		_, err = svc.Update(UpdateAllocationInput{AllocationID: fund.ID, Name: "Emergency", Amount: decimal.NewFromInt(150), ActorID: f.user.ID})
+		require.NoError(t, err)
+
+		_, err = svc.RecordMovement(RecordAllocationMovementInput{AllocationID: fund.ID, Kind: model.AllocationMovementContribution, Amount: decimal.NewFromInt(50), Note: "Bonus", OccurredAt: time.Now(), ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = svc.RecordMovement(RecordAllocationMovementInput{AllocationID: fund.ID, Kind: model.AllocationMovementWithdrawal, Amount: decimal.NewFromInt(500), OccurredAt: time.Now()})
+		assert.ErrorIs(t, err, ErrAllocationOverdrawn)
+		_, err = svc.RecordMovement(RecordAllocationMovementInput{AllocationID: fund.ID, Kind: model.AllocationMovementTransfer, Amount: decimal.NewFromInt(5), OccurredAt: time.Now()})
+		assert.ErrorIs(t, err, ErrInvalidAllocationMovement)
+
+		err = svc.Transfer(TransferAllocationInput{FromAllocationID: fund.ID, ToAllocationID: fund.ID, Amount: decimal.NewFromInt(10), OccurredAt: time.Now()})
+		assert.ErrorIs(t, err, ErrInvalidAllocationTransfer)
+		require.NoError(t, svc.Transfer(TransferAllocationInput{FromAllocationID: fund.ID, ToAllocationID: trip.ID, Amount: decimal.NewFromInt(40), OccurredAt: time.Now(), ActorID: f.user.ID}))
+
+		h, err := svc.History(fund.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(160).Equal(h.Allocation.Amount))
+		require.Len(t, h.Movements, 4)
+		kinds := []model.AllocationMovementKind{}
+		for _, m := range h.Movements {
+			kinds = append(kinds, m.Kind)
+		}
+		assert.ElementsMatch(t, []model.AllocationMovementKind{
+			model.AllocationMovementContribution,
+			model.AllocationMovementAdjustment,
+			model.AllocationMovementContribution,
+			model.AllocationMovementTransfer,
+		}, kinds)
+
+		h, err = svc.History(trip.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(40).Equal(h.Allocation.Amount))
+		require.Len(t, h.Movements, 1)
+		require.NotNil(t, h.Movements[0].CounterpartName)
+		assert.Equal(t, "Emergency", *h.Movements[0].CounterpartName)
+	})
+}
+
+func TestAllocationService_SummaryForAccountAsOf(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc := newAllocationFixture(t, dbi)
+		now := time.Now()
+		monthAgo := now.AddDate(0, -1, 0)
+		weekAgo := now.AddDate(0, 0, -7)
+
+		_, err := f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(1000), OccurredAt: monthAgo.AddDate(0, 0, -1), ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(500), OccurredAt: now, ActorID: f.user.ID})
+		require.NoError(t, err)
+
+		fund, err := svc.Create(CreateAllocationInput{AccountID: f.account.ID, Name: "Emergency", Amount: decimal.Zero, ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = svc.RecordMovement(RecordAllocationMovementInput{AllocationID: fund.ID, Kind: model.AllocationMovementContribution, Amount: decimal.NewFromInt(300), OccurredAt: weekAgo, ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = svc.RecordMovement(RecordAllocationMovementInput{AllocationID: fund.ID, Kind: model.AllocationMovementContribution, Amount: decimal.NewFromInt(200), OccurredAt: now, ActorID: f.user.ID})
+		require.NoError(t, err)
+
+		s, err := svc.SummaryForAccountAsOf(f.account.ID, now.AddDate(0, 0, -3))
+		require.NoError(t, err)
+		require.Len(t, s.Allocations, 1)
+		assert.True(t, decimal.NewFromInt(300).Equal(s.Allocated))
+		assert.True(t, decimal.NewFromInt(700).Equal(s.Available))
+
+		s, err = svc.SummaryForAccountAsOf(f.account.ID, now.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(500).Equal(s.Allocated))
+		assert.True(t, decimal.NewFromInt(1000).Equal(s.Available))
+
+		h, err := svc.History(fund.ID)
+		require.NoError(t, err)
+		points := h.Points(now)
+		require.Len(t, points, 3)
+		assert.True(t, points[0].Amount.IsZero())
+		assert.True(t, decimal.NewFromInt(300).Equal(points[1].Amount))
+		assert.True(t, decimal.NewFromInt(500).Equal(points[2].Amount))
+	})
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/storage"
+	"github.com/google/uuid"
+)
+
+// MaxAttachmentSize caps a single uploaded file.
+const MaxAttachmentSize = 10 << 20
+
+// MaxAttachmentsPerTransaction caps how many files one transaction can hold.
+const MaxAttachmentsPerTransaction = 20
+
+// attachmentPurgeBatch is how many queued blob deletions one PurgeDeleted
+// pass works through.
+const attachmentPurgeBatch = 500
+
+// allowedAttachmentTypes are the sniffed content types accepted for upload:
+// photos and scans of receipts, and PDF invoices.
+var allowedAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// ErrAttachmentNotFound is returned when an attachment does not exist or does
+// not belong to the requested transaction.
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// ErrAttachmentEmpty is returned when uploading a zero-byte file.
+var ErrAttachmentEmpty = errors.New("attachment is empty")
+
+// ErrAttachmentTooLarge is returned when a file exceeds MaxAttachmentSize.
+var ErrAttachmentTooLarge = errors.New("attachment is too large")
+
+// ErrAttachmentTypeNotAllowed is returned when a file's content is not an
+// accepted image or PDF, whatever its name or declared type says.
+var ErrAttachmentTypeNotAllowed = errors.New("attachment type is not allowed")
+
+// ErrAttachmentLimitReached is returned when a transaction already holds
+// MaxAttachmentsPerTransaction files.
+var ErrAttachmentLimitReached = errors.New("transaction has too many attachments")
+
+type AttachmentService struct {
+	attachmentRepo  repository.TransactionAttachmentRepository
+	transactionRepo repository.TransactionRepository
+	uploadStore     storage.Store
+	stores          map[string]storage.Store
+}
+
+// NewAttachmentService stores new uploads in uploadStore. Extra stores are
+// only read from and cleaned up, so attachments written before the upload
+// backend was switched keep working.
+func NewAttachmentService(
+	attachmentRepo repository.TransactionAttachmentRepository,
+	transactionRepo repository.TransactionRepository,
+	uploadStore storage.Store,
+	stores ...storage.Store,
+) *AttachmentService {
+	byBackend := map[string]storage.Store{uploadStore.Backend(): uploadStore}
+	for _, st := range stores {
+		if _, ok := byBackend[st.Backend()]; !ok {
+			byBackend[st.Backend()] = st
+		}
+	}
+	return &AttachmentService{
+		attachmentRepo:  attachmentRepo,
+		transactionRepo: transactionRepo,
+		uploadStore:     uploadStore,
+		stores:          byBackend,
+	}
+}
+
+type UploadAttachmentInput struct {
+	TransactionID string
+	ActorID       string
+	Filename      string
+	Data          []byte
+}
+
+// Upload validates and stores a file against a transaction. The content type
+// is sniffed from the bytes rather than trusted from the client.
+func (s *AttachmentService) Upload(input UploadAttachmentInput) (*model.TransactionAttachment, error) {
+	if input.TransactionID == "" {
+		return nil, fmt.Errorf("transaction id is required")
+	}
+	if len(input.Data) == 0 {
+		return nil, ErrAttachmentEmpty
+	}
+	if len(input.Data) > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	contentType := sniffAttachmentType(input.Data)
+	if !allowedAttachmentTypes[contentType] {
+		return nil, ErrAttachmentTypeNotAllowed
+	}
+
+	if _, err := s.transactionRepo.GetByID(input.TransactionID); err != nil {
+		return nil, fmt.Errorf("failed to load transaction: %w", err)
+	}
+	count, err := s.attachmentRepo.CountByTransaction(input.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count attachments: %w", err)
+	}
+	if count >= MaxAttachmentsPerTransaction {
+		return nil, ErrAttachmentLimitReached
+	}
+
+	key, err := s.uploadStore.Put(input.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	var uploadedBy *string
+	if input.ActorID != "" {
+		uploadedBy = &input.ActorID
+	}
+	att := &model.TransactionAttachment{
+		ID:             uuid.NewString(),
+		TransactionID:  input.TransactionID,
+		Filename:       cleanAttachmentFilename(input.Filename),
+		ContentType:    contentType,
+		SizeBytes:      int64(len(input.Data)),
+		StorageBackend: s.uploadStore.Backend(),
+		StorageKey:     key,
+		UploadedBy:     uploadedBy,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.attachmentRepo.Create(att); err != nil {
+		if delErr := s.uploadStore.Delete(key); delErr != nil {
+			slog.Error("failed to remove orphaned attachment blob", "error", delErr, "backend", att.StorageBackend, "key", key)
+		}
+		return nil, fmt.Errorf("failed to save attachment: %w", err)
+	}
+	return att, nil
+}
+
+// List returns a transaction's attachments, oldest first.
+func (s *AttachmentService) List(transactionID string) ([]*model.TransactionAttachment, error) {
+	atts, err := s.attachmentRepo.ListByTransaction(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	return atts, nil
+}
+
+// Get returns an attachment, verifying it belongs to the transaction.
+func (s *AttachmentService) Get(transactionID, attachmentID string) (*model.TransactionAttachment, error) {
+	att, err := s.attachmentRepo.ByID(attachmentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrAttachmentNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, fmt.Errorf("failed to load attachment: %w", err)
+	}
+	if att.TransactionID != transactionID {
+		return nil, ErrAttachmentNotFound
+	}
+	return att, nil
+}
+
+// Open returns the attachment's contents. Callers must close the reader.
+func (s *AttachmentService) Open(att *model.TransactionAttachment) (io.ReadCloser, error) {
+	st, ok := s.stores[att.StorageBackend]
+	if !ok {
+		return nil, fmt.Errorf("no store configured for backend %q", att.StorageBackend)
+	}
+	rc, err := st.Open(att.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, fmt.Errorf("failed to open attachment: %w", err)
+	}
+	return rc, nil
+}
+
+// Delete removes an attachment and its stored bytes.
+func (s *AttachmentService) Delete(transactionID, attachmentID string) error {
+	att, err := s.Get(transactionID, attachmentID)
+	if err != nil {
+		return err
+	}
+	if err := s.attachmentRepo.Delete(att.ID); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	s.PurgeDeleted()
+	return nil
+}
+
+// PurgeDeleted removes the stored bytes of attachments whose rows are gone —
+// deleted directly or cascaded away with their transaction, account, space or
+// owner. Failures are logged and left queued for the next pass. Returns the
+// number of blobs removed. Safe to call on a nil receiver.
+func (s *AttachmentService) PurgeDeleted() int {
+	if s == nil {
+		return 0
+	}
+	pending, err := s.attachmentRepo.PendingBlobDeletions(attachmentPurgeBatch)
+	if err != nil {
+		slog.Error("failed to list attachment blob deletions", "error", err)
+		return 0
+	}
+	purged := 0
+	for _, p := range pending {
+		st, ok := s.stores[p.StorageBackend]
+		if !ok {
+			slog.Error("no store configured for queued attachment blob", "backend", p.StorageBackend, "key", p.StorageKey)
+			continue
+		}
+		if err := st.Delete(p.StorageKey); err != nil {
+			slog.Error("failed to delete attachment blob", "error", err, "backend", p.StorageBackend, "key", p.StorageKey)
+			continue
+		}
+		if err := s.attachmentRepo.ClearBlobDeletion(p.ID); err != nil {
+			slog.Error("failed to clear attachment blob deletion", "error", err, "id", p.ID)
+			continue
+		}
+		purged++
+	}
+	return purged
+}
+
+func sniffAttachmentType(data []byte) string {
+	ct := http.DetectContentType(data)
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	return strings.TrimSpace(ct)
+}
+
+// cleanAttachmentFilename keeps the base name of an uploaded file, minus
+// control characters, capped to a sane length.
+func cleanAttachmentFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if runes := []rune(name); len(runes) > 200 {
+		name = string(runes[:200])
+	}
+	return name
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"io"
+	"testing"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/storage"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// minimalPDF is enough for content sniffing to report application/pdf.
+var minimalPDF = []byte("%PDF-1.4\n1 0 obj<<>>endobj\ntrailer<<>>\n%%EOF\n")
+
+type attachmentFixture struct {
+	svc     *AttachmentService
+	repo    repository.TransactionAttachmentRepository
+	store   *storage.LocalStore
+	user    *model.User
+	account *model.Account
+	txn     *model.Transaction
+}
+
+func newAttachmentFixture(t *testing.T, dbi testutil.DBInfo) *attachmentFixture {
+	t.Helper()
+
+	store, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	repo := repository.NewTransactionAttachmentRepository(dbi.DB)
+	svc := NewAttachmentService(repo, repository.NewTransactionRepository(dbi.DB), store)
+
+	user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
+	space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+	account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+	txn := testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Laptop", model.TransactionTypeWithdrawal, decimal.RequireFromString("1200"))
+
+	return &attachmentFixture{svc: svc, repo: repo, store: store, user: user, account: account, txn: txn}
+}
+
+func TestAttachmentService_UploadAndOpen(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newAttachmentFixture(t, dbi)
+
+		att, err := f.svc.Upload(UploadAttachmentInput{
+			TransactionID: f.txn.ID,
+			ActorID:       f.user.ID,
+			Filename:      `C:\scans\receipt.pdf`,
+			Data:          minimalPDF,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "receipt.pdf", att.Filename)
+		assert.Equal(t, "application/pdf", att.ContentType)
+		assert.Equal(t, storage.BackendLocal, att.StorageBackend)
+
+		got, err := f.svc.Get(f.txn.ID, att.ID)
+		require.NoError(t, err)
+		rc, err := f.svc.Open(got)
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		assert.Equal(t, minimalPDF, data)
+
+		_, err = f.svc.Get("other-transaction", att.ID)
+		assert.ErrorIs(t, err, ErrAttachmentNotFound)
+	})
+}
+
+func TestAttachmentService_UploadRejectsDisallowedContent(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newAttachmentFixture(t, dbi)
+
+		_, err := f.svc.Upload(UploadAttachmentInput{
+			TransactionID: f.txn.ID,
+			Filename:      "receipt.pdf",
+			Data:          []byte("<html><script>alert(1)</script></html>"),
+		})
+		assert.ErrorIs(t, err, ErrAttachmentTypeNotAllowed, "the name doesn't decide the type")
+
+		_, err = f.svc.Upload(UploadAttachmentInput{
+			TransactionID: f.txn.ID,
+			Filename:      "huge.pdf",
+			Data:          append(append([]byte{}, minimalPDF...), make([]byte, MaxAttachmentSize)...),
+		})
+		assert.ErrorIs(t, err, ErrAttachmentTooLarge)
+
+		atts, err := f.svc.List(f.txn.ID)
+		require.NoError(t, err)
+		assert.Empty(t, atts)
+	})
+}
+
+func TestAttachmentService_PurgesFilesWhenTransactionIsDeleted(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newAttachmentFixture(t, dbi)
+
+		att, err := f.svc.Upload(UploadAttachmentInput{TransactionID: f.txn.ID, Filename: "r.pdf", Data: minimalPDF})
+		require.NoError(t, err)
+
+		_, err = dbi.DB.Exec(`DELETE FROM transactions WHERE id = $1;`, f.txn.ID)
+		require.NoError(t, err)
+
+		pending, err := f.repo.PendingBlobDeletions(10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1, "the cascade queues the stored file")
+
+		assert.Equal(t, 1, f.svc.PurgeDeleted())
+		_, err = f.store.Open(att.StorageKey)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		pending, err = f.repo.PendingBlobDeletions(10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+}
+
+func TestCleanAttachmentFilename(t *testing.T) {
+	assert.Equal(t, "receipt.pdf", cleanAttachmentFilename("../../receipt.pdf"))
+	assert.Equal(t, "scan.png", cleanAttachmentFilename(`C:\Users\me\scan.png`))
+	assert.Equal(t, "ab.jpg", cleanAttachmentFilename("a\"b\x00.jpg"))
+	assert.Equal(t, "attachment", cleanAttachmentFilename("  "))
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrCategorizationRuleNotFound is returned when a rule does not exist or does
+// not belong to the requested account.
+var ErrCategorizationRuleNotFound = errors.New("categorization rule not found")
+
+// ErrRuleWithoutConditions is returned when saving a rule that sets no title,
+// amount or type condition and so would match every transaction.
+var ErrRuleWithoutConditions = errors.New("rule needs at least one condition")
+
+// ErrRuleInvalidPattern is returned when a regex rule's pattern does not
+// compile.
+var ErrRuleInvalidPattern = errors.New("rule pattern is not a valid regular expression")
+
+// ErrRuleInvalidAmountRange is returned when a rule's minimum amount is above
+// its maximum, or either bound is negative.
+var ErrRuleInvalidAmountRange = errors.New("rule amount range is invalid")
+
+const maxRuleRenameLen = 120
+
+// CategorizationRuleService manages per-account categorization rules and
+// applies them to transactions that arrive without a category.
+type CategorizationRuleService struct {
+	repo            repository.CategorizationRuleRepository
+	categoryRepo    repository.CategoryRepository
+	transactionRepo repository.TransactionRepository
+	auditSvc        *TransactionAuditLogService
+}
+
+func NewCategorizationRuleService(
+	repo repository.CategorizationRuleRepository,
+	categoryRepo repository.CategoryRepository,
+	transactionRepo repository.TransactionRepository,
+) *CategorizationRuleService {
+	return &CategorizationRuleService{
+		repo:            repo,
+		categoryRepo:    categoryRepo,
+		transactionRepo: transactionRepo,
+	}
+}
+
+// SetAuditLogger wires the transaction audit log so bulk categorization is
+// recorded against each transaction it changes.
+func (s *CategorizationRuleService) SetAuditLogger(audit *TransactionAuditLogService) {
+	s.auditSvc = audit
+}
+
+type CategorizationRuleInput struct {
+	AccountID    string
+	CategoryID   string
+	Priority     int
+	TitleMatch   model.RuleTitleMatch
+	TitlePattern string
+	MinAmount    *decimal.Decimal
+	MaxAmount    *decimal.Decimal
+	// TransactionType restricts the rule to bills or deposits. Empty matches
+	// both.
+	TransactionType model.TransactionType
+	// RenameTo, when not empty, replaces the title of matched transactions.
+	RenameTo string
+}
+
+// List returns the account's rules in evaluation order.
+func (s *CategorizationRuleService) List(accountID string) ([]*model.CategorizationRule, error) {
+	rules, err := s.repo.ListByAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categorization rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Get returns a rule, verifying it belongs to the account.
+func (s *CategorizationRuleService) Get(accountID, ruleID string) (*model.CategorizationRule, error) {
+	rule, err := s.repo.ByID(ruleID)
+	if err != nil {
+		if errors.Is(err, repository.ErrCategorizationRuleNotFound) {
+			return nil, ErrCategorizationRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to load categorization rule: %w", err)
+	}
+	if rule.AccountID != accountID {
+		return nil, ErrCategorizationRuleNotFound
+	}
+	return rule, nil
+}
+
+func (s *CategorizationRuleService) Create(input CategorizationRuleInput) (*model.CategorizationRule, error) {
+	now := time.Now()
+	rule := &model.CategorizationRule{
+		ID:        uuid.NewString(),
+		AccountID: input.AccountID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.fill(rule, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(rule); err != nil {
+		return nil, fmt.Errorf("failed to create categorization rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (s *CategorizationRuleService) Update(ruleID string, input CategorizationRuleInput) (*model.CategorizationRule, error) {
+	rule, err := s.Get(input.AccountID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fill(rule, input); err != nil {
+		return nil, err
+	}
+	rule.UpdatedAt = time.Now()
+	if err := s.repo.Update(rule); err != nil {
+		if errors.Is(err, repository.ErrCategorizationRuleNotFound) {
+			return nil, ErrCategorizationRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to update categorization rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (s *CategorizationRuleService) Delete(accountID, ruleID string) error {
+	if _, err := s.Get(accountID, ruleID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ruleID); err != nil {
+		return fmt.Errorf("failed to delete categorization rule: %w", err)
+	}
+	return nil
+}
+
+// fill validates the input and copies it onto the rule.
+func (s *CategorizationRuleService) fill(rule *model.CategorizationRule, input CategorizationRuleInput) error {
+	if input.AccountID == "" {
+		return fmt.Errorf("account id is required")
+	}
+	cat, err := s.categoryRepo.ByID(strings.TrimSpace(input.CategoryID))
+	if err != nil {
+		return fmt.Errorf("failed to load category: %w", err)
+	}
+	if cat == nil || cat.AccountID != input.AccountID {
+		return ErrCategoryNotFound
+	}
+
+	match := input.TitleMatch
+	if match == "" {
+		match = model.RuleTitleMatchContains
+	}
+	pattern := strings.TrimSpace(input.TitlePattern)
+	switch match {
+	case model.RuleTitleMatchContains:
+	case model.RuleTitleMatchRegex:
+		if _, err := compileRulePattern(pattern); err != nil {
+			return ErrRuleInvalidPattern
+		}
+	default:
+		return fmt.Errorf("unsupported title match: %s", match)
+	}
+
+	if (input.MinAmount != nil && input.MinAmount.IsNegative()) ||
+		(input.MaxAmount != nil && input.MaxAmount.IsNegative()) ||
+		(input.MinAmount != nil && input.MaxAmount != nil && input.MinAmount.GreaterThan(*input.MaxAmount)) {
+		return ErrRuleInvalidAmountRange
+	}
+
+	var txType *model.TransactionType
+	switch input.TransactionType {
+	case "":
+	case model.TransactionTypeDeposit, model.TransactionTypeWithdrawal:
+		t := input.TransactionType
+		txType = &t
+	default:
+		return fmt.Errorf("unsupported transaction type: %s", input.TransactionType)
+	}
+
+	if pattern == "" && input.MinAmount == nil && input.MaxAmount == nil && txType == nil {
+		return ErrRuleWithoutConditions
+	}
+
+	var renameTo *string
+	if r := strings.TrimSpace(input.RenameTo); r != "" {
+		if len(r) > maxRuleRenameLen {
+			return fmt.Errorf("new title must be at most %d characters", maxRuleRenameLen)
+		}
+		renameTo = &r
+	}
+
+	rule.CategoryID = cat.ID
+	rule.Priority = input.Priority
+	rule.TitleMatch = match
+	rule.TitlePattern = pattern
+	rule.MinAmount = input.MinAmount
+	rule.MaxAmount = input.MaxAmount
+	rule.TransactionType = txType
+	rule.RenameTo = renameTo
+	return nil
+}
+
+func compileRulePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// RuleMatch is the outcome of a rule hit: the rule, and the title the
+// transaction should carry (rewritten when the rule says so).
+type RuleMatch struct {
+	Rule  *model.CategorizationRule
+	Title string
+}
+
+// RuleSet is an account's rules, loaded and compiled once so a batch of
+// transactions can be matched without going back to the database.
+type RuleSet struct {
+	rules   []*model.CategorizationRule
+	regexps map[string]*regexp.Regexp
+}
+
+// RuleSet loads the account's rules. A nil service yields an empty set, so
+// callers that were never wired with rules simply get no matches.
+func (s *CategorizationRuleService) RuleSet(accountID string) (*RuleSet, error) {
+	set := &RuleSet{regexps: map[string]*regexp.Regexp{}}
+	if s == nil {
+		return set, nil
+	}
+	rules, err := s.repo.ListByAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categorization rules: %w", err)
+	}
+	for _, rule := range rules {
+		if rule.TitleMatch == model.RuleTitleMatchRegex && rule.TitlePattern != "" {
+			re, err := compileRulePattern(rule.TitlePattern)
+			if err != nil {
+				// Saved patterns are validated; one that no longer compiles
+				// is skipped rather than blocking every new transaction.
+				continue
+			}
+			set.regexps[rule.ID] = re
+		}
+		set.rules = append(set.rules, rule)
+	}
+	return set, nil
+}
+
+// Match returns the first rule, in priority order, that the transaction
+// details satisfy, or nil when none does.
+func (rs *RuleSet) Match(title string, amount decimal.Decimal, txType model.TransactionType) *RuleMatch {
+	for _, rule := range rs.rules {
+		if !rs.matches(rule, title, amount, txType) {
+			continue
+		}
+		m := &RuleMatch{Rule: rule, Title: title}
+		if rule.RenameTo != nil {
+			m.Title = *rule.RenameTo
+		}
+		return m
+	}
+	return nil
+}
+
+func (rs *RuleSet) matches(rule *model.CategorizationRule, title string, amount decimal.Decimal, txType model.TransactionType) bool {
+	if rule.TransactionType != nil && *rule.TransactionType != txType {
+		return false
+	}
+	if rule.MinAmount != nil && amount.LessThan(*rule.MinAmount) {
+		return false
+	}
+	if rule.MaxAmount != nil && amount.GreaterThan(*rule.MaxAmount) {
+		return false
+	}
+	if rule.TitlePattern == "" {
+		return true
+	}
+	if rule.TitleMatch == model.RuleTitleMatchRegex {
+		re, ok := rs.regexps[rule.ID]
+		return ok && re.MatchString(title)
+	}
+	return strings.Contains(strings.ToLower(title), strings.ToLower(rule.TitlePattern))
+}
+
+// RuleApplication is a rule hit on an existing uncategorized transaction.
+type RuleApplication struct {
+	Transaction *model.Transaction
+	Match       *RuleMatch
+}
+
+// Preview reports which of the account's uncategorized transactions the rules
+// would categorize, without changing anything.
+func (s *CategorizationRuleService) Preview(accountID string) ([]RuleApplication, error) {
+	set, err := s.RuleSet(accountID)
+	if err != nil {
+		return nil, err
+	}
+	txns, err := s.transactionRepo.ListUncategorized(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list uncategorized transactions: %w", err)
+	}
+	apps := []RuleApplication{}
+	for _, t := range txns {
+		if m := set.Match(t.Title, t.Value, t.Type); m != nil {
+			apps = append(apps, RuleApplication{Transaction: t, Match: m})
+		}
+	}
+	return apps, nil
+}
+
+// Apply categorizes the account's uncategorized transactions with its rules,
+// recording an edit on each transaction it changes. Returns how many were
+// categorized.
+func (s *CategorizationRuleService) Apply(accountID, actorID string) (int, error) {
+	apps, err := s.Preview(accountID)
+	if err != nil {
+		return 0, err
+	}
+	if len(apps) == 0 {
+		return 0, nil
+	}
+
+	updates := make([]repository.CategorizationUpdate, 0, len(apps))
+	byID := make(map[string]RuleApplication, len(apps))
+	for _, a := range apps {
+		updates = append(updates, repository.CategorizationUpdate{
+			TransactionID: a.Transaction.ID,
+			CategoryID:    a.Match.Rule.CategoryID,
+			Title:         a.Match.Title,
+		})
+		byID[a.Transaction.ID] = a
+	}
+	applied, err := s.transactionRepo.CategorizeAtomic(updates, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply categorization rules: %w", err)
+	}
+
+	for _, id := range applied {
+		a := byID[id]
+		changes := map[string]any{
+			"category_id": map[string]any{"old": nil, "new": a.Match.Rule.CategoryID},
+		}
+		if a.Match.Title != a.Transaction.Title {
+			changes["title"] = map[string]any{"old": a.Transaction.Title, "new": a.Match.Title}
+		}
+		s.auditSvc.Record(TransactionRecordOptions{
+			TransactionID: id,
+			ActorID:       actorID,
+			Action:        model.TransactionAuditActionEdited,
+			Metadata: map[string]any{
+				"account_id":             a.Transaction.AccountID,
+				"transaction_type":       string(a.Transaction.Type),
+				"categorization_rule_id": a.Match.Rule.ID,
+				"changes":                changes,
+			},
+		})
+	}
+	return len(applied), nil
+}
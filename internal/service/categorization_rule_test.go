@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ruleFixture struct {
+	svc     *CategorizationRuleService
+	txnSvc  *TransactionService
+	txns    repository.TransactionRepository
+	txAudit repository.TransactionAuditLogRepository
+	user    *model.User
+	account *model.Account
+	subs    *model.Category
+	bills   *model.Category
+}
+
+func newRuleFixture(t *testing.T, dbi testutil.DBInfo) *ruleFixture {
+	t.Helper()
+
+	txnRepo := repository.NewTransactionRepository(dbi.DB)
+	categoryRepo := repository.NewCategoryRepository(dbi.DB)
+	auditRepo := repository.NewTransactionAuditLogRepository(dbi.DB)
+	auditSvc := NewTransactionAuditLogService(auditRepo)
+
+	accountSvc := NewAccountService(repository.NewAccountRepository(dbi.DB))
+	txnSvc := NewTransactionService(txnRepo, categoryRepo, repository.NewTagRepository(dbi.DB), accountSvc)
+	txnSvc.SetAuditLogger(auditSvc)
+	svc := NewCategorizationRuleService(repository.NewCategorizationRuleRepository(dbi.DB), categoryRepo, txnRepo)
+	svc.SetAuditLogger(auditSvc)
+	txnSvc.SetCategorizationRuleService(svc)
+
+	user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
+	space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+	account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+
+	return &ruleFixture{
+		svc:     svc,
+		txnSvc:  txnSvc,
+		txns:    txnRepo,
+		txAudit: auditRepo,
+		user:    user,
+		account: account,
+		subs:    testutil.CreateTestCategory(t, dbi.DB, account.ID, "Subscriptions"),
+		bills:   testutil.CreateTestCategory(t, dbi.DB, account.ID, "Bills"),
+	}
+}
+
+func TestCategorizationRuleService_PayBillUsesFirstMatchingRule(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newRuleFixture(t, dbi)
+		withdrawal := model.TransactionTypeWithdrawal
+		_, err := f.svc.Create(CategorizationRuleInput{
+			AccountID:       f.account.ID,
+			CategoryID:      f.bills.ID,
+			Priority:        20,
+			TransactionType: withdrawal,
+		})
+		require.NoError(t, err)
+		rule, err := f.svc.Create(CategorizationRuleInput{
+			AccountID:    f.account.ID,
+			CategoryID:   f.subs.ID,
+			Priority:     10,
+			TitleMatch:   model.RuleTitleMatchRegex,
+			TitlePattern: `^netflix\b`,
+			RenameTo:     "Netflix",
+		})
+		require.NoError(t, err)
+
+		txn, err := f.txnSvc.PayBill(PayBillInput{
+			AccountID:  f.account.ID,
+			Title:      "NETFLIX.COM 866-579",
+			Amount:     decimal.RequireFromString("16.49"),
+			OccurredAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			ActorID:    f.user.ID,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Netflix", txn.Title)
+		cat, err := f.txns.GetCategoryID(txn.ID)
+		require.NoError(t, err)
+		require.NotNil(t, cat)
+		assert.Equal(t, f.subs.ID, *cat)
+
+		logs, err := f.txAudit.ListByTransaction(txn.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		var meta map[string]any
+		require.NoError(t, json.Unmarshal(logs[0].Metadata, &meta))
+		assert.Equal(t, rule.ID, meta["categorization_rule_id"])
+		assert.Equal(t, "NETFLIX.COM 866-579", meta["original_title"])
+
+		manual, err := f.txnSvc.PayBill(PayBillInput{
+			AccountID:  f.account.ID,
+			Title:      "Netflix",
+			Amount:     decimal.RequireFromString("16.49"),
+			OccurredAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			CategoryID: f.bills.ID,
+		})
+		require.NoError(t, err)
+		cat, err = f.txns.GetCategoryID(manual.ID)
+		require.NoError(t, err)
+		assert.Equal(t, f.bills.ID, *cat, "an explicit category wins over the rules")
+	})
+}
+
+func TestCategorizationRuleService_PreviewAndApply(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newRuleFixture(t, dbi)
+		spotify := testutil.CreateTestTransaction(t, dbi.DB, f.account.ID, "Spotify Premium", model.TransactionTypeWithdrawal, decimal.RequireFromString("11.99"))
+		testutil.CreateTestTransaction(t, dbi.DB, f.account.ID, "Groceries", model.TransactionTypeWithdrawal, decimal.RequireFromString("80"))
+		huge := testutil.CreateTestTransaction(t, dbi.DB, f.account.ID, "Spotify gift cards", model.TransactionTypeWithdrawal, decimal.RequireFromString("500"))
+
+		limit := decimal.RequireFromString("50")
+		rule, err := f.svc.Create(CategorizationRuleInput{
+			AccountID:    f.account.ID,
+			CategoryID:   f.subs.ID,
+			TitlePattern: "spotify",
+			MaxAmount:    &limit,
+		})
+		require.NoError(t, err)
+
+		apps, err := f.svc.Preview(f.account.ID)
+		require.NoError(t, err)
+		require.Len(t, apps, 1)
+		assert.Equal(t, spotify.ID, apps[0].Transaction.ID)
+		cat, err := f.txns.GetCategoryID(spotify.ID)
+		require.NoError(t, err)
+		assert.Nil(t, cat, "preview changes nothing")
+
+		n, err := f.svc.Apply(f.account.ID, f.user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		cat, err = f.txns.GetCategoryID(spotify.ID)
+		require.NoError(t, err)
+		require.NotNil(t, cat)
+		assert.Equal(t, f.subs.ID, *cat)
+		cat, err = f.txns.GetCategoryID(huge.ID)
+		require.NoError(t, err)
+		assert.Nil(t, cat)
+
+		logs, err := f.txAudit.ListByTransaction(spotify.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, model.TransactionAuditActionEdited, logs[0].Action)
+		var meta map[string]any
+		require.NoError(t, json.Unmarshal(logs[0].Metadata, &meta))
+		assert.Equal(t, rule.ID, meta["categorization_rule_id"])
+
+		n, err = f.svc.Apply(f.account.ID, f.user.ID)
+		require.NoError(t, err)
+		assert.Zero(t, n, "categorized transactions are not touched again")
+	})
+}
+
+func TestCategorizationRuleService_CreateValidation(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newRuleFixture(t, dbi)
+
+		_, err := f.svc.Create(CategorizationRuleInput{AccountID: f.account.ID, CategoryID: f.subs.ID})
+		assert.ErrorIs(t, err, ErrRuleWithoutConditions)
+
+		_, err = f.svc.Create(CategorizationRuleInput{
+			AccountID:    f.account.ID,
+			CategoryID:   f.subs.ID,
+			TitleMatch:   model.RuleTitleMatchRegex,
+			TitlePattern: "(unclosed",
+		})
+		assert.ErrorIs(t, err, ErrRuleInvalidPattern)
+
+		lo, hi := decimal.RequireFromString("20"), decimal.RequireFromString("10")
+		_, err = f.svc.Create(CategorizationRuleInput{
+			AccountID:  f.account.ID,
+			CategoryID: f.subs.ID,
+			MinAmount:  &lo,
+			MaxAmount:  &hi,
+		})
+		assert.ErrorIs(t, err, ErrRuleInvalidAmountRange)
+
+		other := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Savings")
+		_, err = f.svc.Create(CategorizationRuleInput{
+			AccountID:    other.ID,
+			CategoryID:   f.subs.ID,
+			TitlePattern: "x",
+		})
+		assert.ErrorIs(t, err, ErrCategoryNotFound)
+	})
+}
@@ -19,16 +19,58 @@ var ErrCategoryNameTaken = errors.New("a category with this name already exists"
 // belong to the requested account.
 var ErrCategoryNotFound = errors.New("category not found")
 
-const maxCategoryNameLen = 60
+// ErrCategoryTooDeep is returned when nesting a category would make its tree
+// deeper than model.MaxCategoryDepth.
+var ErrCategoryTooDeep = fmt.Errorf("categories can only be nested %d levels deep", model.MaxCategoryDepth)
+
+// ErrCategoryCycle is returned when moving a category under itself or one of
+// its own subcategories.
+var ErrCategoryCycle = errors.New("a category can't be moved under itself or its subcategories")
+
+// ErrCategoryMergeIntoSelf is returned when merging a category into itself.
+var ErrCategoryMergeIntoSelf = errors.New("a category can't be merged into itself")
+
+// ErrCopyFromSameAccount is returned when copying an account's categories
+// into itself.
+var ErrCopyFromSameAccount = errors.New("pick a different account to copy categories from")
+
+// ErrTooManyToRecategorize is returned when a bulk re-categorization matches
+// more than maxRecategorize transactions.
+var ErrTooManyToRecategorize = fmt.Errorf("narrow the filter to at most %d transactions", maxRecategorize)
+
+const (
+	maxCategoryNameLen = 60
+	maxRecategorize    = 1000
+)
 
 // CategoryService manages the per-account, user-created categories used to tag
 // bills and deposits.
 type CategoryService struct {
-	repo repository.CategoryRepository
+	repo            repository.CategoryRepository
+	accountRepo     repository.AccountRepository
+	transactionRepo repository.TransactionRepository
+	auditSvc        *SpaceAuditLogService
+	txAuditSvc      *TransactionAuditLogService
 }
 
-func NewCategoryService(repo repository.CategoryRepository) *CategoryService {
-	return &CategoryService{repo: repo}
+func NewCategoryService(
+	repo repository.CategoryRepository,
+	accountRepo repository.AccountRepository,
+	transactionRepo repository.TransactionRepository,
+) *CategoryService {
+	return &CategoryService{repo: repo, accountRepo: accountRepo, transactionRepo: transactionRepo}
+}
+
+// SetAuditLogger wires the space audit log so reorganizing categories is
+// recorded.
+func (s *CategoryService) SetAuditLogger(audit *SpaceAuditLogService) {
+	s.auditSvc = audit
+}
+
+// SetTransactionAuditLogger wires the transaction audit log so bulk
+// re-categorization is recorded against each transaction it changes.
+func (s *CategoryService) SetTransactionAuditLogger(audit *TransactionAuditLogService) {
+	s.txAuditSvc = audit
 }
 
 // ListByAccount returns the account's categories ordered by name.
@@ -40,6 +82,16 @@ func (s *CategoryService) ListByAccount(accountID string) ([]*model.Category, er
 	return cats, nil
 }
 
+// Tree returns the account's categories with each parent followed by its
+// children, siblings ordered by name.
+func (s *CategoryService) Tree(accountID string) ([]model.CategoryNode, error) {
+	cats, err := s.ListByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return model.CategoryTree(cats), nil
+}
+
 // Get returns a single category, verifying it belongs to the account. Returns
 // ErrCategoryNotFound otherwise.
 func (s *CategoryService) Get(accountID, categoryID string) (*model.Category, error) {
@@ -53,25 +105,45 @@ func (s *CategoryService) Get(accountID, categoryID string) (*model.Category, er
 	return cat, nil
 }
 
-// Create adds a category to the account. Names are trimmed and must be unique
-// within the account (case-insensitive).
+// Create adds a top-level category to the account. Names are trimmed and must
+// be unique within the account (case-insensitive).
 func (s *CategoryService) Create(accountID, name, description string) (*model.Category, error) {
-	name = strings.TrimSpace(name)
-	if name == "" {
-		return nil, fmt.Errorf("name is required")
-	}
-	if len(name) > maxCategoryNameLen {
-		return nil, fmt.Errorf("name must be at most %d characters", maxCategoryNameLen)
+	return s.CreateChild(accountID, "", name, description)
+}
+
+// CreateChild adds a category nested under parentID, or a top-level one when
+// parentID is empty. The parent must belong to the same account and have
+// room for another level below it.
+func (s *CategoryService) CreateChild(accountID, parentID, name, description string) (*model.Category, error) {
+	name, err := validCategoryName(name)
+	if err != nil {
+		return nil, err
 	}
 
 	existing, err := s.repo.ListByAccount(accountID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load categories: %w", err)
 	}
-	for _, c := range existing {
-		if strings.EqualFold(strings.TrimSpace(c.Name), name) {
-			return nil, ErrCategoryNameTaken
+	if categoryNameTaken(existing, name, "") {
+		return nil, ErrCategoryNameTaken
+	}
+
+	var parent *string
+	if parentID != "" {
+		found := false
+		for _, c := range existing {
+			if c.ID == parentID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrCategoryNotFound
+		}
+		if len(model.CategoryAncestors(existing)[parentID])+2 > model.MaxCategoryDepth {
+			return nil, ErrCategoryTooDeep
 		}
+		parent = &parentID
 	}
 
 	var desc *string
@@ -83,6 +155,7 @@ func (s *CategoryService) Create(accountID, name, description string) (*model.Ca
 	cat := &model.Category{
 		ID:          uuid.NewString(),
 		AccountID:   accountID,
+		ParentID:    parent,
 		Name:        name,
 		Description: desc,
 		CreatedAt:   now,
@@ -91,8 +164,7 @@ func (s *CategoryService) Create(accountID, name, description string) (*model.Ca
 	if err := s.repo.Create(cat); err != nil {
 		// The (account_id, name) unique index is the backstop against a race
 		// between the check above and the insert.
-		msg := strings.ToLower(err.Error())
-		if strings.Contains(msg, "duplicate key") || strings.Contains(msg, "unique") {
+		if isUniqueViolation(err) {
 			return nil, ErrCategoryNameTaken
 		}
 		return nil, fmt.Errorf("failed to create category: %w", err)
@@ -100,7 +172,472 @@ func (s *CategoryService) Create(accountID, name, description string) (*model.Ca
 	return cat, nil
 }
 
-// Delete removes a category owned by the account. Its transaction links cascade.
+func validCategoryName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if len(name) > maxCategoryNameLen {
+		return "", fmt.Errorf("name must be at most %d characters", maxCategoryNameLen)
+	}
+	return name, nil
+}
+
+// categoryNameTaken reports whether another category than exceptID already
+// uses name, compared case-insensitively.
+func categoryNameTaken(categories []*model.Category, name, exceptID string) bool {
+	for _, c := range categories {
+		if c.ID != exceptID && strings.EqualFold(strings.TrimSpace(c.Name), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCategoryTree verifies the account's categories still form a tree no
+// deeper than model.MaxCategoryDepth once each category in parents has been
+// given the parent it maps to (nil for top level) and removed is gone.
+func checkCategoryTree(categories []*model.Category, parents map[string]*string, removed string) error {
+	parentOf := make(map[string]string, len(categories))
+	for _, c := range categories {
+		if c.ID == removed {
+			continue
+		}
+		p := c.ParentID
+		if moved, ok := parents[c.ID]; ok {
+			p = moved
+		}
+		if p != nil {
+			parentOf[c.ID] = *p
+		}
+	}
+	tooDeep := false
+	for id := range parentOf {
+		depth := 1
+		for p, ok := parentOf[id]; ok; p, ok = parentOf[p] {
+			if p == id || depth > len(parentOf) {
+				return ErrCategoryCycle
+			}
+			depth++
+		}
+		if depth > model.MaxCategoryDepth {
+			tooDeep = true
+		}
+	}
+	if tooDeep {
+		return ErrCategoryTooDeep
+	}
+	return nil
+}
+
+// spaceIDOf returns the space an account belongs to, for audit entries.
+func (s *CategoryService) spaceIDOf(accountID string) (string, error) {
+	account, err := s.accountRepo.ByID(accountID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load account: %w", err)
+	}
+	return account.SpaceID, nil
+}
+
+// Rename changes a category's name, keeping every transaction linked to it.
+// The new name must be unique within the account (case-insensitive).
+func (s *CategoryService) Rename(accountID, categoryID, name, actorID string) (*model.Category, error) {
+	cat, err := s.Get(accountID, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	name, err = validCategoryName(name)
+	if err != nil {
+		return nil, err
+	}
+	if name == cat.Name {
+		return cat, nil
+	}
+	existing, err := s.repo.ListByAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+	if categoryNameTaken(existing, name, cat.ID) {
+		return nil, ErrCategoryNameTaken
+	}
+	spaceID, err := s.spaceIDOf(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldName := cat.Name
+	cat.Name = name
+	cat.UpdatedAt = time.Now()
+	if err := s.repo.Update(cat); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrCategoryNameTaken
+		}
+		return nil, fmt.Errorf("failed to rename category: %w", err)
+	}
+
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionCategoryRenamed,
+		Metadata: map[string]any{
+			"account_id":  accountID,
+			"category_id": cat.ID,
+			"old_name":    oldName,
+			"new_name":    cat.Name,
+		},
+	})
+	return cat, nil
+}
+
+// Move re-homes a category and everything beneath it under parentID, or to
+// the top level when parentID is empty. The parent must belong to the same
+// account, must not be the category or one of its subcategories, and the
+// resulting tree must fit within model.MaxCategoryDepth.
+func (s *CategoryService) Move(accountID, categoryID, parentID, actorID string) (*model.Category, error) {
+	existing, err := s.repo.ListByAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+	byID := make(map[string]*model.Category, len(existing))
+	for _, c := range existing {
+		byID[c.ID] = c
+	}
+	cat, ok := byID[categoryID]
+	if !ok {
+		return nil, ErrCategoryNotFound
+	}
+
+	var parent *string
+	if parentID != "" {
+		if _, ok := byID[parentID]; !ok {
+			return nil, ErrCategoryNotFound
+		}
+		parent = &parentID
+	}
+	oldParentID := ""
+	if cat.ParentID != nil {
+		oldParentID = *cat.ParentID
+	}
+	if oldParentID == parentID {
+		return cat, nil
+	}
+	if err := checkCategoryTree(existing, map[string]*string{cat.ID: parent}, ""); err != nil {
+		return nil, err
+	}
+	spaceID, err := s.spaceIDOf(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	cat.ParentID = parent
+	cat.UpdatedAt = time.Now()
+	if err := s.repo.Update(cat); err != nil {
+		return nil, fmt.Errorf("failed to move category: %w", err)
+	}
+
+	parentName := func(id string) string {
+		if c, ok := byID[id]; ok {
+			return c.Name
+		}
+		return ""
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionCategoryMoved,
+		Metadata: map[string]any{
+			"account_id":  accountID,
+			"category_id": cat.ID,
+			"name":        cat.Name,
+			"old_parent":  parentName(oldParentID),
+			"new_parent":  parentName(parentID),
+		},
+	})
+	return cat, nil
+}
+
+// Merge folds sourceID into targetID: every transaction and categorization
+// rule using the source moves to the target, the source's subcategories move
+// under the target, and the source is deleted. Returns how many transactions
+// were moved.
+func (s *CategoryService) Merge(accountID, sourceID, targetID, actorID string) (int, error) {
+	if sourceID == targetID {
+		return 0, ErrCategoryMergeIntoSelf
+	}
+	existing, err := s.repo.ListByAccount(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load categories: %w", err)
+	}
+	var source, target *model.Category
+	for _, c := range existing {
+		switch c.ID {
+		case sourceID:
+			source = c
+		case targetID:
+			target = c
+		}
+	}
+	if source == nil || target == nil {
+		return 0, ErrCategoryNotFound
+	}
+
+	parents := map[string]*string{}
+	for _, c := range existing {
+		if c.ParentID != nil && *c.ParentID == sourceID {
+			parents[c.ID] = &targetID
+		}
+	}
+	if target.ParentID != nil && *target.ParentID == sourceID {
+		parents[targetID] = source.ParentID
+	}
+	if err := checkCategoryTree(existing, parents, sourceID); err != nil {
+		return 0, err
+	}
+	spaceID, err := s.spaceIDOf(accountID)
+	if err != nil {
+		return 0, err
+	}
+
+	moved, err := s.repo.Merge(sourceID, targetID, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to merge categories: %w", err)
+	}
+
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionCategoryMerged,
+		Metadata: map[string]any{
+			"account_id":        accountID,
+			"source_name":       source.Name,
+			"target_id":         target.ID,
+			"target_name":       target.Name,
+			"transaction_count": len(moved),
+		},
+	})
+	return len(moved), nil
+}
+
+type RecategorizeInput struct {
+	AccountID string
+	Filter    model.TransactionFilter
+	// CategoryID is the category every matching transaction ends up with.
+	// Empty leaves them uncategorized.
+	CategoryID string
+	ActorID    string
+}
+
+// Recategorize gives every bill and deposit on the account that matches the
+// filter a single category for its whole value, replacing any splits.
+// Transfers and reconciled transactions are left alone. Returns how many
+// transactions changed.
+func (s *CategoryService) Recategorize(in RecategorizeInput) (int, error) {
+	var target *model.Category
+	if in.CategoryID != "" {
+		cat, err := s.Get(in.AccountID, in.CategoryID)
+		if err != nil {
+			return 0, err
+		}
+		target = cat
+	}
+
+	txns, err := s.transactionRepo.ListByAccountFiltered(in.AccountID, in.Filter, maxRecategorize+1, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	if len(txns) > maxRecategorize {
+		return 0, ErrTooManyToRecategorize
+	}
+	ids := make([]string, len(txns))
+	for i, t := range txns {
+		ids[i] = t.ID
+	}
+	transfers, err := s.transactionRepo.TransferIDsIn(ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up transfers: %w", err)
+	}
+
+	type change struct {
+		txn   *model.Transaction
+		oldID string
+	}
+	changes := []change{}
+	for _, t := range txns {
+		if transfers[t.ID] || t.IsReconciled() {
+			continue
+		}
+		splits, err := s.transactionRepo.GetSplits(t.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load categories: %w", err)
+		}
+		if len(splits) == 0 && in.CategoryID == "" ||
+			len(splits) == 1 && splits[0].CategoryID == in.CategoryID {
+			continue
+		}
+		oldID := ""
+		if len(splits) > 0 {
+			oldID = splits[0].CategoryID
+		}
+		changes = append(changes, change{txn: t, oldID: oldID})
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	spaceID, err := s.spaceIDOf(in.AccountID)
+	if err != nil {
+		return 0, err
+	}
+
+	changedIDs := make([]string, len(changes))
+	for i, c := range changes {
+		changedIDs[i] = c.txn.ID
+	}
+	if err := s.transactionRepo.RecategorizeAtomic(changedIDs, in.CategoryID, time.Now()); err != nil {
+		return 0, fmt.Errorf("failed to re-categorize transactions: %w", err)
+	}
+
+	for _, c := range changes {
+		s.txAuditSvc.Record(TransactionRecordOptions{
+			TransactionID: c.txn.ID,
+			ActorID:       in.ActorID,
+			Action:        model.TransactionAuditActionEdited,
+			Metadata: map[string]any{
+				"account_id":       c.txn.AccountID,
+				"transaction_type": string(c.txn.Type),
+				"changes": map[string]any{
+					"category_id": map[string]any{"old": c.oldID, "new": in.CategoryID},
+				},
+			},
+		})
+	}
+	name := ""
+	if target != nil {
+		name = target.Name
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: in.ActorID,
+		Action:  model.SpaceAuditActionTransactionsRecategorized,
+		Metadata: map[string]any{
+			"account_id":        in.AccountID,
+			"category_id":       in.CategoryID,
+			"category_name":     name,
+			"transaction_count": len(changes),
+		},
+	})
+	return len(changes), nil
+}
+
+// Import adds the categories in source to the account, keeping their
+// nesting. A category whose name the account already uses (case-insensitive)
+// is skipped, and anything nested under it is added beneath the existing one.
+// A category that would end up deeper than model.MaxCategoryDepth is added
+// at the top level instead. from names where the categories came from, for
+// the audit log. Returns how many categories were added.
+func (s *CategoryService) Import(accountID string, source []*model.Category, from, actorID string) (int, error) {
+	existing, err := s.repo.ListByAccount(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load categories: %w", err)
+	}
+	idByName := make(map[string]string, len(existing))
+	depth := make(map[string]int, len(existing))
+	ancestors := model.CategoryAncestors(existing)
+	for _, c := range existing {
+		idByName[strings.ToLower(strings.TrimSpace(c.Name))] = c.ID
+		depth[c.ID] = len(ancestors[c.ID]) + 1
+	}
+
+	now := time.Now()
+	// targetOf maps a source category onto the account's category it became
+	// or matched.
+	targetOf := make(map[string]string, len(source))
+	var created []*model.Category
+	for _, n := range model.CategoryTree(source) {
+		name := strings.TrimSpace(n.Name)
+		key := strings.ToLower(name)
+		if id, ok := idByName[key]; ok {
+			targetOf[n.ID] = id
+			continue
+		}
+		var parent *string
+		d := 1
+		if n.ParentID != nil {
+			if id, ok := targetOf[*n.ParentID]; ok && depth[id] < model.MaxCategoryDepth {
+				parent = &id
+				d = depth[id] + 1
+			}
+		}
+		cat := &model.Category{
+			ID:          uuid.NewString(),
+			AccountID:   accountID,
+			ParentID:    parent,
+			Name:        name,
+			Description: n.Description,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		created = append(created, cat)
+		idByName[key] = cat.ID
+		targetOf[n.ID] = cat.ID
+		depth[cat.ID] = d
+	}
+	if len(created) == 0 {
+		return 0, nil
+	}
+	spaceID, err := s.spaceIDOf(accountID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.repo.CreateMany(created); err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrCategoryNameTaken
+		}
+		return 0, fmt.Errorf("failed to create categories: %w", err)
+	}
+
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionCategoriesImported,
+		Metadata: map[string]any{
+			"account_id":     accountID,
+			"from":           from,
+			"category_count": len(created),
+		},
+	})
+	return len(created), nil
+}
+
+// CopyFrom adds another account's categories to this one through Import.
+// Both accounts must be in the same space.
+func (s *CategoryService) CopyFrom(accountID, sourceAccountID, actorID string) (int, error) {
+	if accountID == sourceAccountID {
+		return 0, ErrCopyFromSameAccount
+	}
+	account, err := s.accountRepo.ByID(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load account: %w", err)
+	}
+	sourceAccount, err := s.accountRepo.ByID(sourceAccountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load account: %w", err)
+	}
+	if sourceAccount.SpaceID != account.SpaceID {
+		return 0, repository.ErrAccountNotFound
+	}
+	source, err := s.repo.ListByAccount(sourceAccountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load categories: %w", err)
+	}
+	return s.Import(accountID, source, sourceAccount.Name, actorID)
+}
+
+// Delete removes a category owned by the account. Its children move up to
+// take its place under its own parent. Transactions tagged only with it
+// become uncategorized; a split transaction's share in it becomes
+// uncategorized too, unless a single split is left, which then covers the
+// whole transaction.
 func (s *CategoryService) Delete(accountID, categoryID string) error {
 	if _, err := s.Get(accountID, categoryID); err != nil {
 		return err
@@ -110,3 +647,16 @@ func (s *CategoryService) Delete(accountID, categoryID string) error {
 	}
 	return nil
 }
+
+// DeleteWithChildren removes a category owned by the account along with every
+// category beneath it. Their transactions and split shares become
+// uncategorized the same way Delete leaves them.
+func (s *CategoryService) DeleteWithChildren(accountID, categoryID string) error {
+	if _, err := s.Get(accountID, categoryID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteTree(categoryID); err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	return nil
+}
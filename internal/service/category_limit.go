@@ -0,0 +1,403 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrCategoryLimitInPast is returned when changing a limit from a month that
+// is already over. Past months keep the limit they had.
+var ErrCategoryLimitInPast = errors.New("limits can only change from the current month on")
+
+// ErrInvalidCategoryLimit is returned when a limit is zero or negative.
+var ErrInvalidCategoryLimit = errors.New("a limit must be greater than zero")
+
+// CategoryLimitService manages monthly spending limits on an account's
+// categories and tracks each month's spending against them.
+type CategoryLimitService struct {
+	repo            repository.CategoryLimitRepository
+	categoryRepo    repository.CategoryRepository
+	transactionRepo repository.TransactionRepository
+	accountService  *AccountService
+	auditSvc        *SpaceAuditLogService
+}
+
+func NewCategoryLimitService(
+	repo repository.CategoryLimitRepository,
+	categoryRepo repository.CategoryRepository,
+	transactionRepo repository.TransactionRepository,
+	accountService *AccountService,
+) *CategoryLimitService {
+	return &CategoryLimitService{
+		repo:            repo,
+		categoryRepo:    categoryRepo,
+		transactionRepo: transactionRepo,
+		accountService:  accountService,
+	}
+}
+
+// SetAuditLogger wires the space audit log so limit changes are recorded.
+func (s *CategoryLimitService) SetAuditLogger(audit *SpaceAuditLogService) {
+	s.auditSvc = audit
+}
+
+// CategoryBudgetLine is one limited category in a month.
+type CategoryBudgetLine struct {
+	Category *model.Category
+	// Depth is the category's nesting level, 0 for a top-level category.
+	Depth int
+	Month *model.CategoryMonth
+}
+
+// CategoryBudget is an account's budget-vs-actual for one month, with its
+// lines in category tree order.
+type CategoryBudget struct {
+	Month time.Time
+	// Closed is set for finished months, whose numbers no longer change.
+	Closed bool
+	Lines  []CategoryBudgetLine
+}
+
+// Over returns the lines that went past what was available.
+func (b *CategoryBudget) Over() []CategoryBudgetLine {
+	var over []CategoryBudgetLine
+	for _, l := range b.Lines {
+		if l.Month.Over() {
+			over = append(over, l)
+		}
+	}
+	return over
+}
+
+type SetCategoryLimitInput struct {
+	AccountID  string
+	CategoryID string
+	// From is the first month the limit applies to. Earlier months keep
+	// whatever limit they had.
+	From time.Time
+	// Amount is the monthly limit. Nil removes the category's limit from
+	// From on.
+	Amount   *decimal.Decimal
+	Rollover bool
+	ActorID  string
+}
+
+// SetLimit changes a category's monthly limit from the given month on.
+func (s *CategoryLimitService) SetLimit(input SetCategoryLimitInput) (*model.CategoryLimit, error) {
+	if input.Amount != nil && !input.Amount.IsPositive() {
+		return nil, ErrInvalidCategoryLimit
+	}
+	from := model.MonthStart(input.From)
+	if from.Before(model.MonthStart(time.Now())) {
+		return nil, ErrCategoryLimitInPast
+	}
+	account, err := s.accountService.GetAccount(input.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	cat, err := s.categoryRepo.ByID(input.CategoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category: %w", err)
+	}
+	if cat == nil || cat.AccountID != input.AccountID {
+		return nil, ErrCategoryNotFound
+	}
+
+	now := time.Now()
+	limit := &model.CategoryLimit{
+		ID:             uuid.NewString(),
+		AccountID:      input.AccountID,
+		CategoryID:     input.CategoryID,
+		EffectiveMonth: from,
+		Amount:         input.Amount,
+		Rollover:       input.Rollover && input.Amount != nil,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.repo.Set(limit); err != nil {
+		return nil, fmt.Errorf("failed to set category limit: %w", err)
+	}
+
+	amount := ""
+	if input.Amount != nil {
+		amount = s.accountService.currencyOf(account).Fixed(*input.Amount)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: account.SpaceID,
+		ActorID: input.ActorID,
+		Action:  model.SpaceAuditActionCategoryLimitSet,
+		Metadata: map[string]any{
+			"account_id":    input.AccountID,
+			"category_id":   cat.ID,
+			"category_name": cat.Name,
+			"amount":        amount,
+			"currency":      account.Currency,
+			"rollover":      limit.Rollover,
+			"from":          from.Format("2006-01"),
+		},
+	})
+	return limit, nil
+}
+
+// Limits returns the account's limits in effect for the given month, keyed
+// by category ID. Categories without one are left out.
+func (s *CategoryLimitService) Limits(accountID string, month time.Time) (map[string]*model.CategoryLimit, error) {
+	limits, err := s.repo.ByAccountID(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category limits: %w", err)
+	}
+	month = model.MonthStart(month)
+	current := map[string]*model.CategoryLimit{}
+	// Limits come ordered by effective month, so the last one reached wins.
+	for _, l := range limits {
+		if model.MonthStart(l.EffectiveMonth).After(month) {
+			continue
+		}
+		if l.Amount == nil {
+			delete(current, l.CategoryID)
+			continue
+		}
+		current[l.CategoryID] = l
+	}
+	return current, nil
+}
+
+// Upcoming returns the account's limit changes that start after the given
+// month, soonest first.
+func (s *CategoryLimitService) Upcoming(accountID string, month time.Time) ([]*model.CategoryLimit, error) {
+	limits, err := s.repo.ByAccountID(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category limits: %w", err)
+	}
+	month = model.MonthStart(month)
+	var upcoming []*model.CategoryLimit
+	for _, l := range limits {
+		if model.MonthStart(l.EffectiveMonth).After(month) {
+			upcoming = append(upcoming, l)
+		}
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		return upcoming[i].EffectiveMonth.Before(upcoming[j].EffectiveMonth)
+	})
+	return upcoming, nil
+}
+
+// Budget returns the account's budget-vs-actual for the month holding the
+// given time. Finished months come from their closing record when there is
+// one; everything else is worked out from the account's bills.
+func (s *CategoryLimitService) Budget(accountID string, month time.Time) (*CategoryBudget, error) {
+	month = model.MonthStart(month)
+	months, cats, err := s.monthsThrough(accountID, month)
+	if err != nil {
+		return nil, err
+	}
+	return budgetFor(month, months[month], cats), nil
+}
+
+// History returns how the account's finished months closed, newest first,
+// going back at most the given number of months.
+func (s *CategoryLimitService) History(accountID string, months int) ([]*CategoryBudget, error) {
+	to := model.MonthStart(time.Now()).AddDate(0, -1, 0)
+	from := to.AddDate(0, -(months - 1), 0)
+	closed, err := s.repo.ClosedMonths(accountID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load closed months: %w", err)
+	}
+	if len(closed) == 0 {
+		return nil, nil
+	}
+	cats, err := s.categoryRepo.ListByAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+	byMonth := map[time.Time][]*model.CategoryMonth{}
+	var order []time.Time
+	for _, m := range closed {
+		key := model.MonthStart(m.Month)
+		if _, ok := byMonth[key]; !ok {
+			order = append(order, key)
+		}
+		byMonth[key] = append(byMonth[key], m)
+	}
+	history := make([]*CategoryBudget, 0, len(order))
+	for _, key := range order {
+		history = append(history, budgetFor(key, byMonth[key], cats))
+	}
+	return history, nil
+}
+
+// CloseMonths records every finished month of the account that has no
+// closing record yet. Returns how many category months were closed.
+func (s *CategoryLimitService) CloseMonths(accountID string, now time.Time) (int, error) {
+	last := model.MonthStart(now).AddDate(0, -1, 0)
+	months, _, err := s.monthsThrough(accountID, last)
+	if err != nil {
+		return 0, err
+	}
+	closedAt := now
+	var open []*model.CategoryMonth
+	for _, ms := range months {
+		for _, m := range ms {
+			if m.ClosedAt == nil {
+				m.ClosedAt = &closedAt
+				open = append(open, m)
+			}
+		}
+	}
+	if err := s.repo.CloseMonths(open); err != nil {
+		return 0, fmt.Errorf("failed to close months: %w", err)
+	}
+	return len(open), nil
+}
+
+// CloseAll runs CloseMonths for every account with a limit. An account that
+// fails is logged and skipped. Returns how many category months were closed.
+func (s *CategoryLimitService) CloseAll(now time.Time) (int, error) {
+	ids, err := s.repo.AccountIDs()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load accounts with limits: %w", err)
+	}
+	closed := 0
+	for _, id := range ids {
+		n, err := s.CloseMonths(id, now)
+		if err != nil {
+			slog.Error("closing category limit months failed", "error", err, "account_id", id)
+			continue
+		}
+		closed += n
+	}
+	return closed, nil
+}
+
+// monthsThrough works out every limited category's months from the
+// account's first limit through the given month, keyed by month. Closed
+// months are taken as recorded so what they carried over stays put.
+func (s *CategoryLimitService) monthsThrough(accountID string, through time.Time) (map[time.Time][]*model.CategoryMonth, []*model.Category, error) {
+	limits, err := s.repo.ByAccountID(accountID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load category limits: %w", err)
+	}
+	cats, err := s.categoryRepo.ListByAccount(accountID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+	months := map[time.Time][]*model.CategoryMonth{}
+	if len(limits) == 0 {
+		return months, cats, nil
+	}
+
+	byCategory := map[string][]*model.CategoryLimit{}
+	start := through
+	for _, l := range limits {
+		byCategory[l.CategoryID] = append(byCategory[l.CategoryID], l)
+		if m := model.MonthStart(l.EffectiveMonth); m.Before(start) {
+			start = m
+		}
+	}
+
+	closed, err := s.repo.ClosedMonths(accountID, start, through)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load closed months: %w", err)
+	}
+	closedByKey := map[string]*model.CategoryMonth{}
+	for _, m := range closed {
+		if m.CategoryID == nil {
+			continue
+		}
+		closedByKey[*m.CategoryID+"|"+model.MonthStart(m.Month).Format("2006-01")] = m
+	}
+	names := make(map[string]string, len(cats))
+	for _, c := range cats {
+		names[c.ID] = c.Name
+	}
+
+	// Spending in a subcategory counts against its ancestors' limits too.
+	end := through.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	rows, err := s.transactionRepo.SumByCategoryBucket(accountID, model.TransactionTypeWithdrawal, start, end, "month", false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to aggregate spending: %w", err)
+	}
+	ancestors := model.CategoryAncestors(cats)
+	spent := map[string]decimal.Decimal{}
+	for _, row := range rows {
+		if row.CategoryID == nil {
+			continue
+		}
+		month := model.MonthStart(row.Bucket).Format("2006-01")
+		for _, id := range append([]string{*row.CategoryID}, ancestors[*row.CategoryID]...) {
+			key := id + "|" + month
+			spent[key] = spent[key].Add(row.Total)
+		}
+	}
+
+	for categoryID, versions := range byCategory {
+		carry := decimal.Zero
+		next := 0
+		var current *model.CategoryLimit
+		for month := start; !month.After(through); month = month.AddDate(0, 1, 0) {
+			for next < len(versions) && !model.MonthStart(versions[next].EffectiveMonth).After(month) {
+				current = versions[next]
+				next++
+			}
+			key := categoryID + "|" + month.Format("2006-01")
+			m, ok := closedByKey[key]
+			if !ok {
+				if current == nil || current.Amount == nil {
+					carry = decimal.Zero
+					continue
+				}
+				m = &model.CategoryMonth{
+					AccountID:    accountID,
+					CategoryID:   &categoryID,
+					CategoryName: names[categoryID],
+					Month:        month,
+					Limit:        *current.Amount,
+					CarriedIn:    carry,
+					Spent:        spent[key],
+					Rollover:     current.Rollover,
+				}
+			}
+			months[month] = append(months[month], m)
+			carry = m.CarriedOut()
+		}
+	}
+	return months, cats, nil
+}
+
+// budgetFor arranges a month's lines in category tree order. Lines of
+// deleted categories come last, under the name they were closed with.
+func budgetFor(month time.Time, lines []*model.CategoryMonth, cats []*model.Category) *CategoryBudget {
+	budget := &CategoryBudget{
+		Month:  month,
+		Closed: month.Before(model.MonthStart(time.Now())),
+	}
+	byCategory := make(map[string]*model.CategoryMonth, len(lines))
+	var deleted []*model.CategoryMonth
+	for _, m := range lines {
+		if m.CategoryID == nil {
+			deleted = append(deleted, m)
+			continue
+		}
+		byCategory[*m.CategoryID] = m
+	}
+	for _, n := range model.CategoryTree(cats) {
+		if m, ok := byCategory[n.ID]; ok {
+			budget.Lines = append(budget.Lines, CategoryBudgetLine{Category: n.Category, Depth: n.Depth, Month: m})
+		}
+	}
+	for _, m := range deleted {
+		budget.Lines = append(budget.Lines, CategoryBudgetLine{
+			Category: &model.Category{AccountID: m.AccountID, Name: m.CategoryName},
+			Month:    m,
+		})
+	}
+	return budget
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCategoryLimitFixture(t *testing.T, dbi testutil.DBInfo) (*txnFixture, *CategoryLimitService, repository.CategoryLimitRepository) {
+	t.Helper()
+	f := newTxnFixture(t, dbi)
+	repo := repository.NewCategoryLimitRepository(dbi.DB)
+	svc := NewCategoryLimitService(repo, repository.NewCategoryRepository(dbi.DB), repository.NewTransactionRepository(dbi.DB), NewAccountService(f.accounts))
+	_, err := f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Seed", Amount: decimal.NewFromInt(10000), OccurredAt: time.Now().AddDate(0, -6, 0), ActorID: f.user.ID})
+	require.NoError(t, err)
+	return f, svc, repo
+}
+
+// setPastLimit stores a limit directly; SetLimit refuses months that are over.
+func setPastLimit(t *testing.T, repo repository.CategoryLimitRepository, accountID, categoryID string, from time.Time, amount int64, rollover bool) {
+	t.Helper()
+	a := decimal.NewFromInt(amount)
+	require.NoError(t, repo.Set(&model.CategoryLimit{
+		ID: categoryID + from.Format("2006-01"), AccountID: accountID, CategoryID: categoryID,
+		EffectiveMonth: from, Amount: &a, Rollover: rollover, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}))
+}
+
+func TestCategoryLimitService_SetLimit(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc, _ := newCategoryLimitFixture(t, dbi)
+		food := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Food")
+		thisMonth := model.MonthStart(time.Now())
+		amount := decimal.NewFromInt(300)
+
+		_, err := svc.SetLimit(SetCategoryLimitInput{AccountID: f.account.ID, CategoryID: food.ID, From: thisMonth.AddDate(0, -1, 0), Amount: &amount})
+		assert.ErrorIs(t, err, ErrCategoryLimitInPast)
+
+		zero := decimal.Zero
+		_, err = svc.SetLimit(SetCategoryLimitInput{AccountID: f.account.ID, CategoryID: food.ID, From: thisMonth, Amount: &zero})
+		assert.ErrorIs(t, err, ErrInvalidCategoryLimit)
+
+		other := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Other")
+		_, err = svc.SetLimit(SetCategoryLimitInput{AccountID: other.ID, CategoryID: food.ID, From: thisMonth, Amount: &amount})
+		assert.ErrorIs(t, err, ErrCategoryNotFound)
+
+		_, err = svc.SetLimit(SetCategoryLimitInput{AccountID: f.account.ID, CategoryID: food.ID, From: thisMonth, Amount: &amount})
+		require.NoError(t, err)
+
+		// A change from next month leaves this month alone.
+		lower := decimal.NewFromInt(200)
+		_, err = svc.SetLimit(SetCategoryLimitInput{AccountID: f.account.ID, CategoryID: food.ID, From: thisMonth.AddDate(0, 1, 0), Amount: &lower})
+		require.NoError(t, err)
+		_, err = svc.SetLimit(SetCategoryLimitInput{AccountID: f.account.ID, CategoryID: food.ID, From: thisMonth.AddDate(0, 2, 0)})
+		require.NoError(t, err)
+
+		limits, err := svc.Limits(f.account.ID, thisMonth)
+		require.NoError(t, err)
+		require.Contains(t, limits, food.ID)
+		assert.True(t, amount.Equal(*limits[food.ID].Amount))
+
+		limits, err = svc.Limits(f.account.ID, thisMonth.AddDate(0, 1, 0))
+		require.NoError(t, err)
+		assert.True(t, lower.Equal(*limits[food.ID].Amount))
+
+		limits, err = svc.Limits(f.account.ID, thisMonth.AddDate(0, 2, 0))
+		require.NoError(t, err)
+		assert.NotContains(t, limits, food.ID, "removed from two months out")
+
+		upcoming, err := svc.Upcoming(f.account.ID, thisMonth)
+		require.NoError(t, err)
+		assert.Len(t, upcoming, 2)
+	})
+}
+
+func TestCategoryLimitService_BudgetRolloverAndClose(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc, repo := newCategoryLimitFixture(t, dbi)
+		cats := NewCategoryService(repository.NewCategoryRepository(dbi.DB), f.accounts, repository.NewTransactionRepository(dbi.DB))
+		food, err := cats.Create(f.account.ID, "Food", "")
+		require.NoError(t, err)
+		groceries, err := cats.CreateChild(f.account.ID, food.ID, "Groceries", "")
+		require.NoError(t, err)
+
+		thisMonth := model.MonthStart(time.Now())
+		twoAgo := thisMonth.AddDate(0, -2, 0)
+		lastMonth := thisMonth.AddDate(0, -1, 0)
+		setPastLimit(t, repo, f.account.ID, food.ID, twoAgo, 100, true)
+		// Raised last month; the month before keeps its 100.
+		setPastLimit(t, repo, f.account.ID, food.ID, lastMonth, 150, true)
+
+		pay := func(amount int64, cat string, when time.Time) {
+			_, err := f.svc.PayBill(PayBillInput{AccountID: f.account.ID, Title: "Bill", Amount: decimal.NewFromInt(amount), OccurredAt: when, CategoryID: cat, ActorID: f.user.ID})
+			require.NoError(t, err)
+		}
+		pay(60, food.ID, twoAgo.AddDate(0, 0, 14))
+		pay(200, groceries.ID, lastMonth.AddDate(0, 0, 14)) // counts against Food
+
+		b, err := svc.Budget(f.account.ID, twoAgo)
+		require.NoError(t, err)
+		require.Len(t, b.Lines, 1)
+		assert.True(t, b.Closed)
+		assert.True(t, decimal.NewFromInt(100).Equal(b.Lines[0].Month.Limit))
+		assert.True(t, decimal.NewFromInt(40).Equal(b.Lines[0].Month.CarriedOut()))
+
+		b, err = svc.Budget(f.account.ID, lastMonth)
+		require.NoError(t, err)
+		require.Len(t, b.Lines, 1)
+		m := b.Lines[0].Month
+		assert.True(t, decimal.NewFromInt(40).Equal(m.CarriedIn))
+		assert.True(t, decimal.NewFromInt(190).Equal(m.Available()))
+		assert.True(t, decimal.NewFromInt(200).Equal(m.Spent), "subcategory spending rolls up")
+		assert.True(t, m.Over())
+
+		b, err = svc.Budget(f.account.ID, thisMonth)
+		require.NoError(t, err)
+		require.Len(t, b.Lines, 1)
+		assert.False(t, b.Closed)
+		assert.True(t, decimal.NewFromInt(-10).Equal(b.Lines[0].Month.CarriedIn), "overspending comes off this month")
+		assert.True(t, decimal.NewFromInt(140).Equal(b.Lines[0].Month.Available()))
+
+		n, err := svc.CloseMonths(f.account.ID, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		n, err = svc.CloseMonths(f.account.ID, time.Now())
+		require.NoError(t, err)
+		assert.Zero(t, n, "closed months are recorded once")
+
+		// A backdated bill doesn't rewrite a closed month.
+		pay(500, food.ID, lastMonth.AddDate(0, 0, 20))
+		b, err = svc.Budget(f.account.ID, lastMonth)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(200).Equal(b.Lines[0].Month.Spent))
+		require.NotNil(t, b.Lines[0].Month.ClosedAt)
+
+		history, err := svc.History(f.account.ID, 12)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.True(t, lastMonth.Equal(history[0].Month), "newest first")
+		assert.Len(t, history[0].Over(), 1)
+	})
+}
+
+func TestCategoryLimitService_ClosedMonthsOutliveCategories(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc, repo := newCategoryLimitFixture(t, dbi)
+		cats := NewCategoryService(repository.NewCategoryRepository(dbi.DB), f.accounts, repository.NewTransactionRepository(dbi.DB))
+		food, err := cats.Create(f.account.ID, "Food", "")
+		require.NoError(t, err)
+		snacks, err := cats.Create(f.account.ID, "Snacks", "")
+		require.NoError(t, err)
+		fun, err := cats.Create(f.account.ID, "Fun", "")
+		require.NoError(t, err)
+
+		thisMonth := model.MonthStart(time.Now())
+		lastMonth := thisMonth.AddDate(0, -1, 0)
+		setPastLimit(t, repo, f.account.ID, food.ID, thisMonth, 300, false)
+		setPastLimit(t, repo, f.account.ID, snacks.ID, lastMonth, 50, false)
+		setPastLimit(t, repo, f.account.ID, fun.ID, lastMonth, 80, false)
+		_, err = svc.CloseMonths(f.account.ID, time.Now())
+		require.NoError(t, err)
+
+		// Snacks' closed month and its limit until Food's own move to Food.
+		_, err = cats.Merge(f.account.ID, snacks.ID, food.ID, f.user.ID)
+		require.NoError(t, err)
+		b, err := svc.Budget(f.account.ID, lastMonth)
+		require.NoError(t, err)
+		require.Len(t, b.Lines, 2)
+		assert.Equal(t, "Food", b.Lines[0].Category.Name)
+		assert.True(t, decimal.NewFromInt(50).Equal(b.Lines[0].Month.Limit))
+		limits, err := svc.Limits(f.account.ID, thisMonth)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(300).Equal(*limits[food.ID].Amount), "Food's own limit takes over")
+
+		// Deleting Fun keeps its closed month under its name.
+		require.NoError(t, cats.Delete(f.account.ID, fun.ID))
+		history, err := svc.History(f.account.ID, 1)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.Len(t, history[0].Lines, 2)
+		deleted := history[0].Lines[1]
+		assert.Equal(t, "Fun", deleted.Category.Name)
+		assert.Nil(t, deleted.Month.CategoryID)
+		assert.True(t, decimal.NewFromInt(80).Equal(deleted.Month.Limit))
+	})
+}
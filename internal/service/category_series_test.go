@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"git.juancwu.dev/juancwu/budgit/internal/testutil"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -87,3 +88,136 @@ func TestTransactionService_CategoryTimeSeries(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestTransactionService_CategoryTimeSeries_AttributesSplits(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		accountID := f.account.ID
+
+		food := testutil.CreateTestCategory(t, dbi.DB, accountID, "Food")
+		home := testutil.CreateTestCategory(t, dbi.DB, accountID, "Home")
+
+		jan := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+		_, err := f.svc.Deposit(DepositInput{AccountID: accountID, Title: "Seed", Amount: decimal.NewFromInt(1000), OccurredAt: jan, ActorID: f.user.ID})
+		require.NoError(t, err)
+
+		bill, err := f.svc.PayBill(PayBillInput{AccountID: accountID, Title: "Market", Amount: decimal.NewFromInt(90), OccurredAt: jan, CategoryID: food.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = f.svc.UpdateBill(UpdateBillInput{
+			TransactionID: bill.ID, Title: "Market", Amount: decimal.NewFromInt(90), OccurredAt: jan,
+			Splits: []model.CategorySplit{
+				{CategoryID: food.ID, Amount: decimal.NewFromInt(60)},
+				{CategoryID: home.ID, Amount: decimal.NewFromInt(30)},
+			},
+			ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		ts, err := f.svc.CategoryTimeSeries(CategorySeriesInput{
+			AccountID: accountID, Type: model.TransactionTypeWithdrawal,
+			From:        time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:          time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+			Granularity: "month", IncludeUncategorized: true,
+		})
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(90).Equal(ts.Total), "a split bill is counted once overall")
+		require.Len(t, ts.Series, 2)
+		assert.Equal(t, "Food", ts.Series[0].CategoryName)
+		assert.True(t, decimal.NewFromInt(60).Equal(ts.Series[0].Total))
+		assert.Equal(t, "Home", ts.Series[1].CategoryName)
+		assert.True(t, decimal.NewFromInt(30).Equal(ts.Series[1].Total))
+	})
+}
+
+func TestTransactionService_CategoryTimeSeries_RollUpAndDrillDown(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		accountID := f.account.ID
+
+		categories := NewCategoryService(repository.NewCategoryRepository(dbi.DB), repository.NewAccountRepository(dbi.DB), repository.NewTransactionRepository(dbi.DB))
+		food, err := categories.Create(accountID, "Food", "")
+		require.NoError(t, err)
+		groceries, err := categories.CreateChild(accountID, food.ID, "Groceries", "")
+		require.NoError(t, err)
+		produce, err := categories.CreateChild(accountID, groceries.ID, "Produce", "")
+		require.NoError(t, err)
+		restaurants, err := categories.CreateChild(accountID, food.ID, "Restaurants", "")
+		require.NoError(t, err)
+		rent, err := categories.Create(accountID, "Rent", "")
+		require.NoError(t, err)
+
+		jan := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+		_, err = f.svc.Deposit(DepositInput{AccountID: accountID, Title: "Seed", Amount: decimal.NewFromInt(10000), OccurredAt: jan, ActorID: f.user.ID})
+		require.NoError(t, err)
+		pay := func(amount int64, cat string) {
+			_, err := f.svc.PayBill(PayBillInput{AccountID: accountID, Title: "Bill", Amount: decimal.NewFromInt(amount), OccurredAt: jan, CategoryID: cat, ActorID: f.user.ID})
+			require.NoError(t, err)
+		}
+		pay(100, groceries.ID)
+		pay(40, produce.ID)
+		pay(60, restaurants.ID)
+		pay(10, food.ID)
+		pay(1000, rent.ID)
+
+		in := CategorySeriesInput{
+			AccountID: accountID, Type: model.TransactionTypeWithdrawal,
+			From:        time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:          time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+			Granularity: "month",
+		}
+		totals := func(ts *model.CategoryTimeSeries) map[string]string {
+			m := map[string]string{}
+			for _, s := range ts.Series {
+				m[s.CategoryName] = s.Total.String()
+			}
+			return m
+		}
+
+		ts, err := f.svc.CategoryTimeSeries(in)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"Groceries": "100", "Produce": "40", "Restaurants": "60", "Food": "10", "Rent": "1000"}, totals(ts))
+
+		in.RollUp = true
+		ts, err = f.svc.CategoryTimeSeries(in)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"Food": "210", "Rent": "1000"}, totals(ts))
+		assert.True(t, ts.Series[1].HasChildren, "Food can be drilled into")
+
+		in.ParentID = food.ID
+		ts, err = f.svc.CategoryTimeSeries(in)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"Groceries": "140", "Restaurants": "60", "Food (not in a subcategory)": "10"}, totals(ts))
+		assert.True(t, decimal.NewFromInt(210).Equal(ts.Total))
+
+		in.ParentID = "does-not-exist"
+		_, err = f.svc.CategoryTimeSeries(in)
+		assert.ErrorIs(t, err, ErrCategoryNotFound)
+	})
+}
+
+func TestTransactionService_CategoryTimeSeries_ConvertsFromPostedCurrency(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		rates := NewExchangeRateService(repository.NewExchangeRateRepository(dbi.DB), repository.NewTransactionRepository(dbi.DB))
+		f.svc.SetExchangeRateService(rates)
+		food := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Food")
+
+		before := time.Now().AddDate(0, 0, -5)
+		_, err := f.svc.PayBill(PayBillInput{AccountID: f.account.ID, Title: "Market", Amount: decimal.NewFromInt(100), OccurredAt: before, CategoryID: food.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		require.NoError(t, NewAccountService(f.accounts).ChangeCurrency(f.account.ID, "USD", decimal.RequireFromString("0.75"), f.user.ID))
+		_, err = f.svc.PayBill(PayBillInput{AccountID: f.account.ID, Title: "Market", Amount: decimal.NewFromInt(10), OccurredAt: time.Now(), CategoryID: food.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = rates.SetRate(SetRateInput{SpaceID: f.account.SpaceID, Base: "USD", Quote: "CAD", Date: time.Now().AddDate(0, 0, -10), Rate: decimal.RequireFromString("1.4")})
+		require.NoError(t, err)
+
+		from := model.MonthStart(before.AddDate(0, -1, 0))
+		to := time.Now().Add(time.Hour)
+		ts, err := f.svc.CategoryTimeSeries(CategorySeriesInput{
+			AccountID: f.account.ID, Type: model.TransactionTypeWithdrawal,
+			From: from, To: to, Granularity: "year", Currency: "CAD",
+		})
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(114).Equal(ts.Total), "the 100 CAD bill stays 100 CAD and the 10 USD bill is 14 CAD: %s", ts.Total)
+	})
+}
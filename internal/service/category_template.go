@@ -0,0 +1,348 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrCategoryTemplateNotFound is returned when a template, or an item in
+// one, does not exist or does not belong to the requested space.
+var ErrCategoryTemplateNotFound = errors.New("category template not found")
+
+// ErrCategoryTemplateNameTaken is returned when the space already has a
+// template with the same name.
+var ErrCategoryTemplateNameTaken = errors.New("a template with this name already exists")
+
+// ErrCategoryTemplateBuiltIn is returned when editing or deleting the
+// built-in default template.
+var ErrCategoryTemplateBuiltIn = errors.New("the default template can't be changed")
+
+// CategoryTemplateService manages the category templates a space seeds its
+// accounts with, alongside the built-in default template.
+type CategoryTemplateService struct {
+	repo        repository.CategoryTemplateRepository
+	accountRepo repository.AccountRepository
+	categories  *CategoryService
+}
+
+func NewCategoryTemplateService(
+	repo repository.CategoryTemplateRepository,
+	accountRepo repository.AccountRepository,
+	categories *CategoryService,
+) *CategoryTemplateService {
+	return &CategoryTemplateService{repo: repo, accountRepo: accountRepo, categories: categories}
+}
+
+// CategoryTemplateSet is a template with its categories, each parent
+// followed by its children. A node's ID is the template item's ID.
+type CategoryTemplateSet struct {
+	Template   *model.CategoryTemplate
+	BuiltIn    bool
+	Categories []model.CategoryNode
+}
+
+// templateCategories turns template items into categories so they can be
+// arranged with model.CategoryTree and copied with CategoryService.Import.
+func templateCategories(items []*model.CategoryTemplateItem) []*model.Category {
+	cats := make([]*model.Category, len(items))
+	for i, item := range items {
+		cats[i] = &model.Category{
+			ID:          item.ID,
+			ParentID:    item.ParentID,
+			Name:        item.Name,
+			Description: item.Description,
+			CreatedAt:   item.CreatedAt,
+		}
+	}
+	return cats
+}
+
+func defaultCategoryTemplate(spaceID string) *model.CategoryTemplate {
+	return &model.CategoryTemplate{
+		ID:      model.DefaultCategoryTemplateID,
+		SpaceID: spaceID,
+		Name:    model.DefaultCategoryTemplateName,
+	}
+}
+
+// List returns the built-in default template followed by the space's own,
+// ordered by name.
+func (s *CategoryTemplateService) List(spaceID string) ([]*model.CategoryTemplate, error) {
+	templates, err := s.repo.BySpaceID(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list category templates: %w", err)
+	}
+	return append([]*model.CategoryTemplate{defaultCategoryTemplate(spaceID)}, templates...), nil
+}
+
+// Sets is List with each template's categories.
+func (s *CategoryTemplateService) Sets(spaceID string) ([]CategoryTemplateSet, error) {
+	templates, err := s.repo.BySpaceID(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list category templates: %w", err)
+	}
+	items, err := s.repo.ItemsBySpaceID(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category template items: %w", err)
+	}
+	byTemplate := map[string][]*model.CategoryTemplateItem{}
+	for _, item := range items {
+		byTemplate[item.TemplateID] = append(byTemplate[item.TemplateID], item)
+	}
+
+	sets := []CategoryTemplateSet{{
+		Template:   defaultCategoryTemplate(spaceID),
+		BuiltIn:    true,
+		Categories: model.CategoryTree(templateCategories(model.DefaultCategoryTemplateItems())),
+	}}
+	for _, t := range templates {
+		sets = append(sets, CategoryTemplateSet{
+			Template:   t,
+			Categories: model.CategoryTree(templateCategories(byTemplate[t.ID])),
+		})
+	}
+	return sets, nil
+}
+
+// Get returns a template, verifying it belongs to the space. The built-in
+// default template belongs to every space.
+func (s *CategoryTemplateService) Get(spaceID, templateID string) (*model.CategoryTemplate, error) {
+	if templateID == model.DefaultCategoryTemplateID {
+		return defaultCategoryTemplate(spaceID), nil
+	}
+	t, err := s.repo.ByID(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category template: %w", err)
+	}
+	if t == nil || t.SpaceID != spaceID {
+		return nil, ErrCategoryTemplateNotFound
+	}
+	return t, nil
+}
+
+// Categories returns the template's categories, ready for
+// CategoryService.Import.
+func (s *CategoryTemplateService) Categories(spaceID, templateID string) ([]*model.Category, error) {
+	if templateID == model.DefaultCategoryTemplateID {
+		return templateCategories(model.DefaultCategoryTemplateItems()), nil
+	}
+	if _, err := s.Get(spaceID, templateID); err != nil {
+		return nil, err
+	}
+	items, err := s.repo.Items(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category template items: %w", err)
+	}
+	return templateCategories(items), nil
+}
+
+// checkTemplateName validates a new template's name and makes sure no other
+// template in the space, the built-in one included, uses it.
+func (s *CategoryTemplateService) checkTemplateName(spaceID, name string) (string, error) {
+	name, err := validCategoryName(name)
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(name, model.DefaultCategoryTemplateName) {
+		return "", ErrCategoryTemplateNameTaken
+	}
+	templates, err := s.repo.BySpaceID(spaceID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list category templates: %w", err)
+	}
+	for _, t := range templates {
+		if strings.EqualFold(t.Name, name) {
+			return "", ErrCategoryTemplateNameTaken
+		}
+	}
+	return name, nil
+}
+
+// Create adds an empty template to the space.
+func (s *CategoryTemplateService) Create(spaceID, name string) (*model.CategoryTemplate, error) {
+	return s.create(spaceID, name, nil)
+}
+
+// SaveFromAccount adds a template holding a copy of the account's
+// categories, nesting included.
+func (s *CategoryTemplateService) SaveFromAccount(spaceID, accountID, name string) (*model.CategoryTemplate, error) {
+	account, err := s.accountRepo.ByID(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	if account.SpaceID != spaceID {
+		return nil, repository.ErrAccountNotFound
+	}
+	cats, err := s.categories.ListByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.create(spaceID, name, cats)
+}
+
+func (s *CategoryTemplateService) create(spaceID, name string, cats []*model.Category) (*model.CategoryTemplate, error) {
+	name, err := s.checkTemplateName(spaceID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	t := &model.CategoryTemplate{
+		ID:        uuid.NewString(),
+		SpaceID:   spaceID,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	itemIDs := make(map[string]string, len(cats))
+	seen := make(map[string]bool, len(cats))
+	items := make([]*model.CategoryTemplateItem, 0, len(cats))
+	for _, n := range model.CategoryTree(cats) {
+		// Names only differing in case would trip the template's unique index.
+		key := strings.ToLower(strings.TrimSpace(n.Name))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		item := &model.CategoryTemplateItem{
+			ID:          uuid.NewString(),
+			TemplateID:  t.ID,
+			Name:        n.Name,
+			Description: n.Description,
+			CreatedAt:   now,
+		}
+		if n.ParentID != nil {
+			if id, ok := itemIDs[*n.ParentID]; ok {
+				item.ParentID = &id
+			}
+		}
+		itemIDs[n.ID] = item.ID
+		items = append(items, item)
+	}
+	if err := s.repo.Create(t, items); err != nil {
+		// The (space_id, lower(name)) unique index is the backstop against a
+		// race between the check above and the insert.
+		if isUniqueViolation(err) {
+			return nil, ErrCategoryTemplateNameTaken
+		}
+		return nil, fmt.Errorf("failed to create category template: %w", err)
+	}
+	return t, nil
+}
+
+// Delete removes one of the space's templates. Accounts already seeded from
+// it keep their categories.
+func (s *CategoryTemplateService) Delete(spaceID, templateID string) error {
+	if templateID == model.DefaultCategoryTemplateID {
+		return ErrCategoryTemplateBuiltIn
+	}
+	if _, err := s.Get(spaceID, templateID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(templateID); err != nil {
+		return fmt.Errorf("failed to delete category template: %w", err)
+	}
+	return nil
+}
+
+// AddItem adds a category to a template, nested under parentID or at the top
+// level when parentID is empty. Names follow the same rules as an account's
+// categories: unique within the template and no deeper than
+// model.MaxCategoryDepth.
+func (s *CategoryTemplateService) AddItem(spaceID, templateID, parentID, name string) (*model.CategoryTemplateItem, error) {
+	if templateID == model.DefaultCategoryTemplateID {
+		return nil, ErrCategoryTemplateBuiltIn
+	}
+	existing, err := s.Categories(spaceID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	name, err = validCategoryName(name)
+	if err != nil {
+		return nil, err
+	}
+	if categoryNameTaken(existing, name, "") {
+		return nil, ErrCategoryNameTaken
+	}
+
+	item := &model.CategoryTemplateItem{
+		ID:         uuid.NewString(),
+		TemplateID: templateID,
+		Name:       name,
+		CreatedAt:  time.Now(),
+	}
+	if parentID != "" {
+		if !templateHasItem(existing, parentID) {
+			return nil, ErrCategoryNotFound
+		}
+		if len(model.CategoryAncestors(existing)[parentID])+2 > model.MaxCategoryDepth {
+			return nil, ErrCategoryTooDeep
+		}
+		item.ParentID = &parentID
+	}
+	if err := s.repo.AddItem(item); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrCategoryNameTaken
+		}
+		return nil, fmt.Errorf("failed to add category to template: %w", err)
+	}
+	return item, nil
+}
+
+func templateHasItem(cats []*model.Category, id string) bool {
+	for _, c := range cats {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteItem removes a category from a template along with everything nested
+// beneath it.
+func (s *CategoryTemplateService) DeleteItem(spaceID, templateID, itemID string) error {
+	if templateID == model.DefaultCategoryTemplateID {
+		return ErrCategoryTemplateBuiltIn
+	}
+	if _, err := s.Get(spaceID, templateID); err != nil {
+		return err
+	}
+	item, err := s.repo.ItemByID(itemID)
+	if err != nil {
+		return fmt.Errorf("failed to load category template item: %w", err)
+	}
+	if item == nil || item.TemplateID != templateID {
+		return ErrCategoryTemplateNotFound
+	}
+	if err := s.repo.DeleteItem(itemID); err != nil {
+		return fmt.Errorf("failed to remove category from template: %w", err)
+	}
+	return nil
+}
+
+// Apply adds the template's categories to one of the space's accounts,
+// skipping names the account already has. Returns how many were added.
+func (s *CategoryTemplateService) Apply(spaceID, templateID, accountID, actorID string) (int, error) {
+	account, err := s.accountRepo.ByID(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load account: %w", err)
+	}
+	if account.SpaceID != spaceID {
+		return 0, repository.ErrAccountNotFound
+	}
+	t, err := s.Get(spaceID, templateID)
+	if err != nil {
+		return 0, err
+	}
+	cats, err := s.Categories(spaceID, templateID)
+	if err != nil {
+		return 0, err
+	}
+	return s.categories.Import(accountID, cats, t.Name, actorID)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCategoryTemplateFixture(t *testing.T, dbi testutil.DBInfo) (*CategoryTemplateService, *CategoryService, *model.Space) {
+	t.Helper()
+	accountRepo := repository.NewAccountRepository(dbi.DB)
+	categories := NewCategoryService(repository.NewCategoryRepository(dbi.DB), accountRepo, repository.NewTransactionRepository(dbi.DB))
+	svc := NewCategoryTemplateService(repository.NewCategoryTemplateRepository(dbi.DB), accountRepo, categories)
+	user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
+	space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+	return svc, categories, space
+}
+
+func TestCategoryTemplateService_ApplyDefault(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc, categories, space := newCategoryTemplateFixture(t, dbi)
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Acct")
+
+		// An existing category with a matching name is kept, not duplicated.
+		_, err := categories.Create(account.ID, "housing", "")
+		require.NoError(t, err)
+
+		added, err := svc.Apply(space.ID, model.DefaultCategoryTemplateID, account.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, len(model.DefaultCategoryTemplateItems())-1, added)
+
+		cats, err := categories.ListByAccount(account.ID)
+		require.NoError(t, err)
+		assert.Len(t, cats, len(model.DefaultCategoryTemplateItems()))
+
+		// Applying again adds nothing.
+		added, err = svc.Apply(space.ID, model.DefaultCategoryTemplateID, account.ID, "")
+		require.NoError(t, err)
+		assert.Zero(t, added)
+	})
+}
+
+func TestCategoryTemplateService_SaveFromAccountAndApply(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc, categories, space := newCategoryTemplateFixture(t, dbi)
+		source := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Source")
+		target := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Target")
+
+		food, err := categories.Create(source.ID, "Food", "")
+		require.NoError(t, err)
+		_, err = categories.CreateChild(source.ID, food.ID, "Groceries", "")
+		require.NoError(t, err)
+		_, err = categories.Create(source.ID, "Rent", "")
+		require.NoError(t, err)
+
+		tmpl, err := svc.SaveFromAccount(space.ID, source.ID, "Household")
+		require.NoError(t, err)
+
+		_, err = svc.Create(space.ID, "household")
+		assert.ErrorIs(t, err, ErrCategoryTemplateNameTaken, "names are case-insensitive")
+		_, err = svc.Create(space.ID, "Default")
+		assert.ErrorIs(t, err, ErrCategoryTemplateNameTaken, "the built-in name is reserved")
+
+		// The target already has Food; Groceries lands beneath it.
+		targetFood, err := categories.Create(target.ID, "FOOD", "")
+		require.NoError(t, err)
+
+		added, err := svc.Apply(space.ID, tmpl.ID, target.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, 2, added, "Groceries and Rent")
+
+		cats, err := categories.ListByAccount(target.ID)
+		require.NoError(t, err)
+		byName := map[string]*model.Category{}
+		for _, c := range cats {
+			byName[c.Name] = c
+		}
+		require.Contains(t, byName, "Groceries")
+		require.NotNil(t, byName["Groceries"].ParentID)
+		assert.Equal(t, targetFood.ID, *byName["Groceries"].ParentID)
+		assert.Nil(t, byName["Rent"].ParentID)
+
+		// Templates from another space are out of reach.
+		other := testutil.CreateTestSpace(t, dbi.DB, space.OwnerID, "Other")
+		_, err = svc.Get(other.ID, tmpl.ID)
+		assert.ErrorIs(t, err, ErrCategoryTemplateNotFound)
+	})
+}
+
+func TestCategoryTemplateService_Items(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc, _, space := newCategoryTemplateFixture(t, dbi)
+
+		_, err := svc.AddItem(space.ID, model.DefaultCategoryTemplateID, "", "Pets")
+		assert.ErrorIs(t, err, ErrCategoryTemplateBuiltIn)
+
+		tmpl, err := svc.Create(space.ID, "Travel")
+		require.NoError(t, err)
+		trips, err := svc.AddItem(space.ID, tmpl.ID, "", "Trips")
+		require.NoError(t, err)
+		_, err = svc.AddItem(space.ID, tmpl.ID, trips.ID, "Flights")
+		require.NoError(t, err)
+		_, err = svc.AddItem(space.ID, tmpl.ID, "", "trips")
+		assert.ErrorIs(t, err, ErrCategoryNameTaken)
+
+		// Removing a parent takes its children with it.
+		require.NoError(t, svc.DeleteItem(space.ID, tmpl.ID, trips.ID))
+		cats, err := svc.Categories(space.ID, tmpl.ID)
+		require.NoError(t, err)
+		assert.Empty(t, cats)
+
+		require.NoError(t, svc.Delete(space.ID, tmpl.ID))
+		assert.ErrorIs(t, svc.Delete(space.ID, model.DefaultCategoryTemplateID), ErrCategoryTemplateBuiltIn)
+
+		templates, err := svc.List(space.ID)
+		require.NoError(t, err)
+		require.Len(t, templates, 1)
+		assert.Equal(t, model.DefaultCategoryTemplateID, templates[0].ID)
+	})
+}
+
+func TestCategoryService_CopyFrom(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		_, categories, space := newCategoryTemplateFixture(t, dbi)
+		source := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Source")
+		target := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Target")
+
+		_, err := categories.Create(source.ID, "Fuel", "")
+		require.NoError(t, err)
+
+		_, err = categories.CopyFrom(source.ID, source.ID, "")
+		assert.ErrorIs(t, err, ErrCopyFromSameAccount)
+
+		added, err := categories.CopyFrom(target.ID, source.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+
+		other := testutil.CreateTestSpace(t, dbi.DB, space.OwnerID, "Other")
+		foreign := testutil.CreateTestAccount(t, dbi.DB, other.ID, "Foreign")
+		_, err = categories.CopyFrom(foreign.ID, source.ID, "")
+		assert.ErrorIs(t, err, repository.ErrAccountNotFound)
+	})
+}
+
+func TestAccountService_CreateAccountWithTemplate(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		templates, categories, space := newCategoryTemplateFixture(t, dbi)
+		accounts := NewAccountService(repository.NewAccountRepository(dbi.DB))
+		accounts.SetCategoryTemplateService(templates)
+
+		account, err := accounts.CreateAccount(CreateAccountInput{
+			SpaceID: space.ID, Name: "Checking", CategoryTemplateID: model.DefaultCategoryTemplateID,
+		})
+		require.NoError(t, err)
+		cats, err := categories.ListByAccount(account.ID)
+		require.NoError(t, err)
+		assert.Len(t, cats, len(model.DefaultCategoryTemplateItems()))
+
+		bare, err := accounts.CreateAccount(CreateAccountInput{SpaceID: space.ID, Name: "Bare"})
+		require.NoError(t, err)
+		cats, err = categories.ListByAccount(bare.ID)
+		require.NoError(t, err)
+		assert.Empty(t, cats)
+
+		_, err = accounts.CreateAccount(CreateAccountInput{SpaceID: space.ID, Name: "Missing", CategoryTemplateID: "nope"})
+		assert.ErrorIs(t, err, ErrCategoryTemplateNotFound)
+	})
+}
+
+func TestTransactionService_SpaceCategoryTimeSeries(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		second := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Second")
+
+		jan := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+		foodA := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Food")
+		foodB := testutil.CreateTestCategory(t, dbi.DB, second.ID, "food")
+
+		for _, p := range []struct {
+			account, category string
+			amount            int64
+		}{{f.account.ID, foodA.ID, 100}, {second.ID, foodB.ID, 40}, {second.ID, "", 5}} {
+			_, err := f.svc.Deposit(DepositInput{AccountID: p.account, Title: "Seed", Amount: decimal.NewFromInt(1000), OccurredAt: jan, ActorID: f.user.ID})
+			require.NoError(t, err)
+			_, err = f.svc.PayBill(PayBillInput{AccountID: p.account, Title: "Bill", Amount: decimal.NewFromInt(p.amount), OccurredAt: jan, CategoryID: p.category, ActorID: f.user.ID})
+			require.NoError(t, err)
+		}
+
+		ts, err := f.svc.SpaceCategoryTimeSeries(SpaceCategorySeriesInput{
+			SpaceID: f.account.SpaceID, Type: model.TransactionTypeWithdrawal,
+			From: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+			Granularity: "month", IncludeUncategorized: true, Currency: "CAD",
+		})
+		require.NoError(t, err)
+		require.Len(t, ts.Series, 2, "Food across both accounts, and uncategorized")
+		assert.True(t, decimal.NewFromInt(140).Equal(ts.Series[0].Total), "Food matched by name")
+		assert.True(t, decimal.NewFromInt(5).Equal(ts.Series[1].Total))
+		assert.Empty(t, ts.Skipped)
+	})
+}
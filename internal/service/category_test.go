@@ -3,16 +3,19 @@ package service
 import (
 	"errors"
 	"testing"
+	"time"
 
+	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func newCategoryFixture(t *testing.T, dbi testutil.DBInfo) (*CategoryService, string) {
 	t.Helper()
-	svc := NewCategoryService(repository.NewCategoryRepository(dbi.DB))
+	svc := NewCategoryService(repository.NewCategoryRepository(dbi.DB), repository.NewAccountRepository(dbi.DB), repository.NewTransactionRepository(dbi.DB))
 	user := testutil.CreateTestUser(t, dbi.DB, "cat@example.com", nil)
 	space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
 	account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Acct")
@@ -106,3 +109,262 @@ func TestCategoryService_Get_UnknownID(t *testing.T) {
 		assert.True(t, errors.Is(err, ErrCategoryNotFound))
 	})
 }
+
+func TestCategoryService_CreateChild_DepthLimit(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc, accountID := newCategoryFixture(t, dbi)
+
+		food, err := svc.Create(accountID, "Food", "")
+		require.NoError(t, err)
+		groceries, err := svc.CreateChild(accountID, food.ID, "Groceries", "")
+		require.NoError(t, err)
+		require.NotNil(t, groceries.ParentID)
+		assert.Equal(t, food.ID, *groceries.ParentID)
+		produce, err := svc.CreateChild(accountID, groceries.ID, "Produce", "")
+		require.NoError(t, err)
+
+		_, err = svc.CreateChild(accountID, produce.ID, "Apples", "")
+		assert.ErrorIs(t, err, ErrCategoryTooDeep)
+		_, err = svc.CreateChild(accountID, "does-not-exist", "Apples", "")
+		assert.ErrorIs(t, err, ErrCategoryNotFound)
+
+		tree, err := svc.Tree(accountID)
+		require.NoError(t, err)
+		require.Len(t, tree, 3)
+		assert.Equal(t, "Food", tree[0].Name)
+		assert.True(t, tree[0].HasChildren)
+		assert.Equal(t, 2, tree[2].Depth)
+	})
+}
+
+func TestCategoryService_DeleteParent(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc, accountID := newCategoryFixture(t, dbi)
+
+		food, err := svc.Create(accountID, "Food", "")
+		require.NoError(t, err)
+		groceries, err := svc.CreateChild(accountID, food.ID, "Groceries", "")
+		require.NoError(t, err)
+		produce, err := svc.CreateChild(accountID, groceries.ID, "Produce", "")
+		require.NoError(t, err)
+
+		// Produce moves up under Food.
+		require.NoError(t, svc.Delete(accountID, groceries.ID))
+		got, err := svc.Get(accountID, produce.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got.ParentID)
+		assert.Equal(t, food.ID, *got.ParentID)
+
+		require.NoError(t, svc.DeleteWithChildren(accountID, food.ID))
+		cats, err := svc.ListByAccount(accountID)
+		require.NoError(t, err)
+		assert.Empty(t, cats)
+	})
+}
+
+func TestCategoryService_Delete_TwoWaySplit(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		accountID := f.account.ID
+		txns := repository.NewTransactionRepository(dbi.DB)
+		svc := NewCategoryService(repository.NewCategoryRepository(dbi.DB), f.accounts, txns)
+
+		food, err := svc.Create(accountID, "Food", "")
+		require.NoError(t, err)
+		dining, err := svc.Create(accountID, "Dining", "")
+		require.NoError(t, err)
+
+		now := time.Now()
+		market, err := f.svc.PayBill(PayBillInput{AccountID: accountID, Title: "Market", Amount: decimal.NewFromInt(90), OccurredAt: now, CategoryID: food.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = f.svc.UpdateBill(UpdateBillInput{
+			TransactionID: market.ID, Title: "Market", Amount: decimal.NewFromInt(90), OccurredAt: now,
+			Splits: []model.CategorySplit{
+				{CategoryID: food.ID, Amount: decimal.NewFromInt(60)},
+				{CategoryID: dining.ID, Amount: decimal.NewFromInt(30)},
+			},
+			ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		lunch, err := f.svc.PayBill(PayBillInput{AccountID: accountID, Title: "Lunch", Amount: decimal.NewFromInt(20), OccurredAt: now, CategoryID: dining.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+
+		require.NoError(t, svc.Delete(accountID, dining.ID))
+
+		splits, err := txns.GetSplits(market.ID)
+		require.NoError(t, err)
+		require.Len(t, splits, 1)
+		assert.Equal(t, food.ID, splits[0].CategoryID)
+		assert.True(t, decimal.NewFromInt(90).Equal(splits[0].Amount), "the lone split covers the whole bill again")
+
+		splits, err = txns.GetSplits(lunch.ID)
+		require.NoError(t, err)
+		assert.Empty(t, splits)
+	})
+}
+
+func TestCategoryService_DeleteWithChildren_SplitShareBecomesUncategorized(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		accountID := f.account.ID
+		txns := repository.NewTransactionRepository(dbi.DB)
+		svc := NewCategoryService(repository.NewCategoryRepository(dbi.DB), f.accounts, txns)
+
+		food, err := svc.Create(accountID, "Food", "")
+		require.NoError(t, err)
+		home, err := svc.Create(accountID, "Home", "")
+		require.NoError(t, err)
+		dining, err := svc.Create(accountID, "Dining", "")
+		require.NoError(t, err)
+		takeout, err := svc.CreateChild(accountID, dining.ID, "Takeout", "")
+		require.NoError(t, err)
+
+		day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+		market, err := f.svc.PayBill(PayBillInput{AccountID: accountID, Title: "Market", Amount: decimal.NewFromInt(100), OccurredAt: day, CategoryID: food.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = f.svc.UpdateBill(UpdateBillInput{
+			TransactionID: market.ID, Title: "Market", Amount: decimal.NewFromInt(100), OccurredAt: day,
+			Splits: []model.CategorySplit{
+				{CategoryID: food.ID, Amount: decimal.NewFromInt(50)},
+				{CategoryID: home.ID, Amount: decimal.NewFromInt(30)},
+				{CategoryID: takeout.ID, Amount: decimal.NewFromInt(20)},
+			},
+			ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, svc.DeleteWithChildren(accountID, dining.ID))
+
+		splits, err := txns.GetSplits(market.ID)
+		require.NoError(t, err)
+		require.Len(t, splits, 2, "the other splits keep their amounts")
+
+		rows, err := txns.SumByCategoryBucket(accountID, model.TransactionTypeWithdrawal, day.AddDate(0, 0, -1), day.AddDate(0, 0, 1), "month", true)
+		require.NoError(t, err)
+		totals := map[string]decimal.Decimal{}
+		for _, r := range rows {
+			key := ""
+			if r.CategoryID != nil {
+				key = *r.CategoryID
+			}
+			totals[key] = totals[key].Add(r.Total)
+		}
+		assert.True(t, decimal.NewFromInt(50).Equal(totals[food.ID]))
+		assert.True(t, decimal.NewFromInt(30).Equal(totals[home.ID]))
+		assert.True(t, decimal.NewFromInt(20).Equal(totals[""]), "the deleted share is reported as uncategorized")
+	})
+}
+
+func TestCategoryService_RenameAndMove(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc, accountID := newCategoryFixture(t, dbi)
+
+		food, err := svc.Create(accountID, "Food", "")
+		require.NoError(t, err)
+		groceries, err := svc.CreateChild(accountID, food.ID, "Grocereis", "")
+		require.NoError(t, err)
+		produce, err := svc.CreateChild(accountID, groceries.ID, "Produce", "")
+		require.NoError(t, err)
+		rent, err := svc.Create(accountID, "Rent", "")
+		require.NoError(t, err)
+
+		renamed, err := svc.Rename(accountID, groceries.ID, " Groceries ", "")
+		require.NoError(t, err)
+		assert.Equal(t, "Groceries", renamed.Name)
+		_, err = svc.Rename(accountID, groceries.ID, "rent", "")
+		assert.ErrorIs(t, err, ErrCategoryNameTaken)
+
+		_, err = svc.Move(accountID, food.ID, produce.ID, "")
+		assert.ErrorIs(t, err, ErrCategoryCycle)
+		_, err = svc.Move(accountID, rent.ID, produce.ID, "")
+		assert.ErrorIs(t, err, ErrCategoryTooDeep)
+
+		// Groceries takes Produce along.
+		moved, err := svc.Move(accountID, groceries.ID, rent.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, rent.ID, *moved.ParentID)
+		tree, err := svc.Tree(accountID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Food", "Rent", "Groceries", "Produce"}, []string{tree[0].Name, tree[1].Name, tree[2].Name, tree[3].Name})
+		assert.Equal(t, 2, tree[3].Depth)
+
+		_, err = svc.Move(accountID, rent.ID, produce.ID, "")
+		assert.ErrorIs(t, err, ErrCategoryCycle)
+	})
+}
+
+func TestCategoryService_MergeAndRecategorize(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		accountID := f.account.ID
+		svc := NewCategoryService(repository.NewCategoryRepository(dbi.DB), f.accounts, repository.NewTransactionRepository(dbi.DB))
+		svc.SetAuditLogger(NewSpaceAuditLogService(repository.NewSpaceAuditLogRepository(dbi.DB)))
+		txns := repository.NewTransactionRepository(dbi.DB)
+
+		food, err := svc.Create(accountID, "Food", "")
+		require.NoError(t, err)
+		dining, err := svc.Create(accountID, "Dining", "")
+		require.NoError(t, err)
+		takeout, err := svc.CreateChild(accountID, dining.ID, "Takeout", "")
+		require.NoError(t, err)
+
+		jan := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+		_, err = f.svc.Deposit(DepositInput{AccountID: accountID, Title: "Seed", Amount: decimal.NewFromInt(1000), OccurredAt: jan, ActorID: f.user.ID})
+		require.NoError(t, err)
+		lunch, err := f.svc.PayBill(PayBillInput{AccountID: accountID, Title: "Lunch", Amount: decimal.NewFromInt(20), OccurredAt: jan, CategoryID: dining.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		market, err := f.svc.PayBill(PayBillInput{AccountID: accountID, Title: "Market", Amount: decimal.NewFromInt(90), OccurredAt: jan, CategoryID: food.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = f.svc.UpdateBill(UpdateBillInput{
+			TransactionID: market.ID, Title: "Market", Amount: decimal.NewFromInt(90), OccurredAt: jan,
+			Splits: []model.CategorySplit{
+				{CategoryID: food.ID, Amount: decimal.NewFromInt(60)},
+				{CategoryID: dining.ID, Amount: decimal.NewFromInt(30)},
+			},
+			ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		_, err = svc.Merge(accountID, dining.ID, dining.ID, f.user.ID)
+		assert.ErrorIs(t, err, ErrCategoryMergeIntoSelf)
+
+		n, err := svc.Merge(accountID, dining.ID, food.ID, f.user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		splits, err := txns.GetSplits(lunch.ID)
+		require.NoError(t, err)
+		require.Len(t, splits, 1)
+		assert.Equal(t, food.ID, splits[0].CategoryID)
+		splits, err = txns.GetSplits(market.ID)
+		require.NoError(t, err)
+		require.Len(t, splits, 1, "the two splits fold into one")
+		assert.True(t, decimal.NewFromInt(90).Equal(splits[0].Amount))
+
+		_, err = svc.Get(accountID, dining.ID)
+		assert.ErrorIs(t, err, ErrCategoryNotFound)
+		moved, err := svc.Get(accountID, takeout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, food.ID, *moved.ParentID)
+
+		// Re-categorize everything titled "Lunch" into Takeout.
+		n, err = svc.Recategorize(RecategorizeInput{AccountID: accountID, Filter: model.TransactionFilter{Title: "lunch"}, CategoryID: takeout.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		n, err = svc.Recategorize(RecategorizeInput{AccountID: accountID, Filter: model.TransactionFilter{Title: "lunch"}, CategoryID: takeout.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 0, n, "already in Takeout")
+		catID, err := txns.GetCategoryID(lunch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, takeout.ID, *catID)
+
+		logs, err := repository.NewSpaceAuditLogRepository(dbi.DB).ListBySpace(f.account.SpaceID, 10, 0)
+		require.NoError(t, err)
+		actions := []model.SpaceAuditAction{}
+		for _, l := range logs {
+			actions = append(actions, l.Action)
+		}
+		assert.Contains(t, actions, model.SpaceAuditActionCategoryMerged)
+		assert.Contains(t, actions, model.SpaceAuditActionTransactionsRecategorized)
+	})
+}
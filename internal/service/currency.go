@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrDuplicateCurrency is returned when a space already has a currency with
+// the code being added.
+var ErrDuplicateCurrency = errors.New("space already has this currency")
+
+// ErrSpaceCurrencyNotFound is returned when a space currency doesn't exist or
+// belongs to another space.
+var ErrSpaceCurrencyNotFound = errors.New("space currency not found")
+
+// ErrCurrencyInUse is returned when a space currency is still held by an
+// account or used as the reporting currency.
+var ErrCurrencyInUse = errors.New("currency is in use")
+
+// CurrencyService manages the currencies a space can use: the ISO 4217 list
+// plus the space's own.
+type CurrencyService struct {
+	currencyRepo repository.SpaceCurrencyRepository
+	spaceRepo    repository.SpaceRepository
+	accountRepo  repository.AccountRepository
+	auditSvc     *SpaceAuditLogService
+}
+
+func NewCurrencyService(currencyRepo repository.SpaceCurrencyRepository, spaceRepo repository.SpaceRepository, accountRepo repository.AccountRepository) *CurrencyService {
+	return &CurrencyService{currencyRepo: currencyRepo, spaceRepo: spaceRepo, accountRepo: accountRepo}
+}
+
+// SetAuditLogger wires the audit log service after construction.
+func (s *CurrencyService) SetAuditLogger(audit *SpaceAuditLogService) {
+	s.auditSvc = audit
+}
+
+// Registry returns the currencies the space can use. A nil service knows the
+// ISO list only, so callers that weren't wired with one keep working.
+func (s *CurrencyService) Registry(spaceID string) (*currency.Registry, error) {
+	if s == nil {
+		return nil, nil
+	}
+	rows, err := s.currencyRepo.BySpaceID(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load space currencies: %w", err)
+	}
+	custom := make([]currency.Currency, len(rows))
+	for i, row := range rows {
+		custom[i] = toCurrency(row)
+	}
+	return currency.NewRegistry(custom...), nil
+}
+
+// Lookup returns the currency with the given code if the space can use it.
+func (s *CurrencyService) Lookup(spaceID, code string) (currency.Currency, error) {
+	registry, err := s.Registry(spaceID)
+	if err != nil {
+		return currency.Currency{}, err
+	}
+	c, ok := registry.Lookup(code)
+	if !ok {
+		return currency.Currency{}, ErrUnsupportedCurrency
+	}
+	return c, nil
+}
+
+// Get returns the currency with the given code for rounding and display. It
+// never fails: if the space's currencies can't be loaded, or the code is
+// unknown, it falls back to the ISO list and then to two decimals.
+func (s *CurrencyService) Get(spaceID, code string) currency.Currency {
+	registry, err := s.Registry(spaceID)
+	if err != nil {
+		slog.Warn("failed to load space currencies", "error", err, "space_id", spaceID)
+	}
+	return registry.Get(code)
+}
+
+// List returns the space's own currencies.
+func (s *CurrencyService) List(spaceID string) ([]*model.SpaceCurrency, error) {
+	rows, err := s.currencyRepo.BySpaceID(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list space currencies: %w", err)
+	}
+	return rows, nil
+}
+
+type AddCurrencyInput struct {
+	SpaceID    string
+	Code       string
+	Name       string
+	Symbol     string
+	MinorUnits int32
+	ActorID    string
+}
+
+// AddCurrency defines a currency for the space. The code must not be an ISO
+// 4217 code; the currency.Err* errors describe what is wrong with the input.
+func (s *CurrencyService) AddCurrency(in AddCurrencyInput) (*model.SpaceCurrency, error) {
+	c, err := currency.NewCustom(in.Code, in.Name, in.Symbol, in.MinorUnits)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	row := &model.SpaceCurrency{
+		ID:         uuid.NewString(),
+		SpaceID:    in.SpaceID,
+		Code:       c.Code,
+		Name:       c.Name,
+		Symbol:     c.Symbol,
+		MinorUnits: c.MinorUnits,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.currencyRepo.Create(row); err != nil {
+		if errors.Is(err, repository.ErrDuplicateSpaceCurrency) {
+			return nil, ErrDuplicateCurrency
+		}
+		return nil, fmt.Errorf("failed to add currency: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: in.SpaceID,
+		ActorID: in.ActorID,
+		Action:  model.SpaceAuditActionCurrencyAdded,
+		Metadata: map[string]any{
+			"code":        row.Code,
+			"name":        row.Name,
+			"minor_units": row.MinorUnits,
+		},
+	})
+	return row, nil
+}
+
+// RemoveCurrency deletes one of the space's own currencies. A currency an
+// account (archived ones included) is held in, or the space reports in, has
+// to stay.
+func (s *CurrencyService) RemoveCurrency(spaceID, id, actorID string) error {
+	row, err := s.currencyRepo.ByID(id)
+	if errors.Is(err, repository.ErrSpaceCurrencyNotFound) || (err == nil && row.SpaceID != spaceID) {
+		return ErrSpaceCurrencyNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load space currency: %w", err)
+	}
+
+	space, err := s.spaceRepo.ByID(spaceID)
+	if err != nil {
+		return fmt.Errorf("failed to load space: %w", err)
+	}
+	if space.ReportingCurrency == row.Code {
+		return ErrCurrencyInUse
+	}
+	accounts, err := s.accountRepo.BySpaceID(spaceID)
+	if err != nil {
+		return fmt.Errorf("failed to load accounts: %w", err)
+	}
+	for _, a := range accounts {
+		if a.Currency == row.Code {
+			return ErrCurrencyInUse
+		}
+	}
+
+	if err := s.currencyRepo.Delete(id); err != nil {
+		return fmt.Errorf("failed to remove currency: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionCurrencyRemoved,
+		Metadata: map[string]any{
+			"code": row.Code,
+			"name": row.Name,
+		},
+	})
+	return nil
+}
+
+func toCurrency(row *model.SpaceCurrency) currency.Currency {
+	return currency.Currency{
+		Code:       row.Code,
+		Name:       row.Name,
+		Symbol:     row.Symbol,
+		MinorUnits: row.MinorUnits,
+		Custom:     true,
+	}
+}
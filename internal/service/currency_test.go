@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrencyService_CustomCurrencies(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc := NewCurrencyService(
+			repository.NewSpaceCurrencyRepository(dbi.DB),
+			repository.NewSpaceRepository(dbi.DB),
+			repository.NewAccountRepository(dbi.DB),
+		)
+
+		user := testutil.CreateTestUser(t, dbi.DB, "currencies@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		other := testutil.CreateTestSpace(t, dbi.DB, user.ID, "Other")
+
+		btc, err := svc.AddCurrency(AddCurrencyInput{SpaceID: space.ID, Code: "btc", Name: "Bitcoin", Symbol: "₿", MinorUnits: 8, ActorID: user.ID})
+		require.NoError(t, err)
+		assert.Equal(t, "BTC", btc.Code)
+
+		_, err = svc.AddCurrency(AddCurrencyInput{SpaceID: space.ID, Code: "BTC", MinorUnits: 8})
+		assert.ErrorIs(t, err, ErrDuplicateCurrency)
+		_, err = svc.AddCurrency(AddCurrencyInput{SpaceID: space.ID, Code: "JPY", MinorUnits: 0})
+		assert.ErrorIs(t, err, currency.ErrISOCode)
+
+		c, err := svc.Lookup(space.ID, "BTC")
+		require.NoError(t, err)
+		assert.Equal(t, int32(8), c.MinorUnits)
+		assert.True(t, c.Round(decimal.RequireFromString("0.123456789")).Equal(decimal.RequireFromString("0.12345679")))
+
+		// Other spaces don't see it.
+		_, err = svc.Lookup(other.ID, "BTC")
+		assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+		assert.ErrorIs(t, svc.RemoveCurrency(other.ID, btc.ID, user.ID), ErrSpaceCurrencyNotFound)
+
+		// A currency an account is held in stays.
+		wallet := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Wallet")
+		_, err = dbi.DB.Exec(`UPDATE accounts SET currency = 'BTC' WHERE id = $1`, wallet.ID)
+		require.NoError(t, err)
+		assert.ErrorIs(t, svc.RemoveCurrency(space.ID, btc.ID, user.ID), ErrCurrencyInUse)
+
+		_, err = dbi.DB.Exec(`UPDATE accounts SET currency = 'CAD' WHERE id = $1`, wallet.ID)
+		require.NoError(t, err)
+		require.NoError(t, svc.RemoveCurrency(space.ID, btc.ID, user.ID))
+		rows, err := svc.List(space.ID)
+		require.NoError(t, err)
+		assert.Empty(t, rows)
+	})
+}
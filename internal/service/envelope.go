@@ -0,0 +1,570 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+// ErrEnvelopeBudgetingOff is returned when using the envelope budget of a
+// space that hasn't turned envelope budgeting on.
+var ErrEnvelopeBudgetingOff = errors.New("envelope budgeting is off for this space")
+
+// ErrEnvelopeMonthBeforeStart is returned for a month before the space's
+// envelope budget started.
+var ErrEnvelopeMonthBeforeStart = errors.New("the envelope budget starts after this month")
+
+// ErrEnvelopeNotFound is returned for an envelope that matches none of the
+// space's categories or investment and loan accounts.
+var ErrEnvelopeNotFound = errors.New("envelope not found")
+
+// ErrInvalidEnvelopeAssignment is returned when assigning a negative amount.
+var ErrInvalidEnvelopeAssignment = errors.New("an assignment can't be negative")
+
+// ErrInvalidEnvelopeMove is returned when moving nothing, a negative amount,
+// or money into the envelope it came from.
+var ErrInvalidEnvelopeMove = errors.New("move a positive amount between two different envelopes")
+
+// uncategorizedEnvelope holds spending without a category.
+const uncategorizedEnvelope = ""
+
+// EnvelopeService runs a space's zero-based envelope budget. Every category
+// is an envelope, matched by name across the space's accounts like the space
+// category report, and so is every investment or loan account. Each month
+// the money that came in is assigned to envelopes, bills in a category are
+// paid out of its envelope, transfers into an investment or loan are paid
+// out of that account's envelope, and what is left carries into the next
+// month. Nothing is entered twice: income and spending come from the
+// accounts' transactions.
+type EnvelopeService struct {
+	repo           repository.EnvelopeRepository
+	spaceRepo      repository.SpaceRepository
+	categoryRepo   repository.CategoryRepository
+	accountService *AccountService
+	txService      *TransactionService
+	rateSvc        *ExchangeRateService
+	auditSvc       *SpaceAuditLogService
+}
+
+func NewEnvelopeService(
+	repo repository.EnvelopeRepository,
+	spaceRepo repository.SpaceRepository,
+	categoryRepo repository.CategoryRepository,
+	accountService *AccountService,
+	txService *TransactionService,
+	rateSvc *ExchangeRateService,
+) *EnvelopeService {
+	return &EnvelopeService{
+		repo:           repo,
+		spaceRepo:      spaceRepo,
+		categoryRepo:   categoryRepo,
+		accountService: accountService,
+		txService:      txService,
+		rateSvc:        rateSvc,
+	}
+}
+
+// SetAuditLogger wires the space audit log so envelope changes are recorded.
+func (s *EnvelopeService) SetAuditLogger(audit *SpaceAuditLogService) {
+	s.auditSvc = audit
+}
+
+// EnvelopeBudget is a space's envelope budget for one month, in the space's
+// reporting currency.
+type EnvelopeBudget struct {
+	Month time.Time
+	// Since is the budget's first month.
+	Since    time.Time
+	Currency string
+	// Opening is what the budget's accounts held when it started, less what
+	// was owed on them.
+	Opening decimal.Decimal
+	// Income is the month's deposits. All income goes to ToBeAssigned.
+	Income decimal.Decimal
+	// ToBeAssigned is the money not yet in an envelope: the opening balance
+	// and all income through the month, less everything assigned through the
+	// month and earlier months' overspending.
+	ToBeAssigned decimal.Decimal
+	// Envelopes are sorted by name, uncategorized spending last.
+	Envelopes []*model.EnvelopeMonth
+	// Skipped names the accounts left out for lack of an exchange rate.
+	Skipped []string
+}
+
+// Overspent returns the envelopes that spent more than they held.
+func (b *EnvelopeBudget) Overspent() []*model.EnvelopeMonth {
+	var over []*model.EnvelopeMonth
+	for _, e := range b.Envelopes {
+		if e.Overspent() {
+			over = append(over, e)
+		}
+	}
+	return over
+}
+
+// Assigned is the total assigned to envelopes in the month.
+func (b *EnvelopeBudget) Assigned() decimal.Decimal {
+	total := decimal.Zero
+	for _, e := range b.Envelopes {
+		total = total.Add(e.Assigned)
+	}
+	return total
+}
+
+// Activity is the month's total activity across envelopes.
+func (b *EnvelopeBudget) Activity() decimal.Decimal {
+	total := decimal.Zero
+	for _, e := range b.Envelopes {
+		total = total.Add(e.Activity)
+	}
+	return total
+}
+
+// Available is what the envelopes hold between them.
+func (b *EnvelopeBudget) Available() decimal.Decimal {
+	total := decimal.Zero
+	for _, e := range b.Envelopes {
+		total = total.Add(e.Available())
+	}
+	return total
+}
+
+// Envelope returns the envelope with the given key, or nil.
+func (b *EnvelopeBudget) Envelope(key string) *model.EnvelopeMonth {
+	for _, e := range b.Envelopes {
+		if e.Envelope == key {
+			return e
+		}
+	}
+	return nil
+}
+
+// Enable turns envelope budgeting on for the space from the current month.
+// Turning it on again keeps the month it started.
+func (s *EnvelopeService) Enable(spaceID, actorID string) error {
+	space, err := s.spaceRepo.ByID(spaceID)
+	if err != nil {
+		return err
+	}
+	if space.EnvelopeBudgetSince != nil {
+		return nil
+	}
+	since := model.MonthStart(time.Now())
+	if err := s.spaceRepo.SetEnvelopeBudgetSince(spaceID, &since); err != nil {
+		return fmt.Errorf("failed to enable envelope budgeting: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionEnvelopeBudgetingEnabled,
+		Metadata: map[string]any{
+			"since": since.Format("2006-01"),
+		},
+	})
+	return nil
+}
+
+// Disable turns envelope budgeting off. Assignments are kept, but turning it
+// back on starts a new budget from that month.
+func (s *EnvelopeService) Disable(spaceID, actorID string) error {
+	space, err := s.spaceRepo.ByID(spaceID)
+	if err != nil {
+		return err
+	}
+	if space.EnvelopeBudgetSince == nil {
+		return nil
+	}
+	if err := s.spaceRepo.SetEnvelopeBudgetSince(spaceID, nil); err != nil {
+		return fmt.Errorf("failed to disable envelope budgeting: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionEnvelopeBudgetingDisabled,
+		Metadata: map[string]any{
+			"since": space.EnvelopeBudgetSince.Format("2006-01"),
+		},
+	})
+	return nil
+}
+
+type AssignEnvelopeInput struct {
+	SpaceID  string
+	Envelope string
+	Month    time.Time
+	Amount   decimal.Decimal
+	ActorID  string
+}
+
+// Assign sets what is assigned to an envelope for a month, replacing what
+// was assigned before.
+func (s *EnvelopeService) Assign(in AssignEnvelopeInput) error {
+	if in.Amount.IsNegative() {
+		return ErrInvalidEnvelopeAssignment
+	}
+	space, month, err := s.budgetMonth(in.SpaceID, in.Month)
+	if err != nil {
+		return err
+	}
+	names, err := s.envelopeNames(in.SpaceID)
+	if err != nil {
+		return err
+	}
+	name, ok := names[in.Envelope]
+	if !ok {
+		return ErrEnvelopeNotFound
+	}
+	err = s.repo.Assign(&model.EnvelopeAssignment{
+		SpaceID:   space.ID,
+		Envelope:  in.Envelope,
+		Month:     month,
+		Amount:    in.Amount,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to assign money: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: space.ID,
+		ActorID: in.ActorID,
+		Action:  model.SpaceAuditActionEnvelopeAssigned,
+		Metadata: map[string]any{
+			"envelope": name,
+			"month":    month.Format("2006-01"),
+			"amount":   in.Amount.String(),
+			"currency": space.ReportingCurrency,
+		},
+	})
+	return nil
+}
+
+type MoveEnvelopeMoneyInput struct {
+	SpaceID string
+	From    string
+	To      string
+	Month   time.Time
+	Amount  decimal.Decimal
+	ActorID string
+}
+
+// Move takes money assigned to one envelope for a month and assigns it to
+// another. Moving more than the envelope holds leaves it overspent. Money
+// can be moved out of an envelope whose categories are gone, but not into
+// one.
+func (s *EnvelopeService) Move(in MoveEnvelopeMoneyInput) error {
+	if !in.Amount.IsPositive() || in.From == in.To {
+		return ErrInvalidEnvelopeMove
+	}
+	space, month, err := s.budgetMonth(in.SpaceID, in.Month)
+	if err != nil {
+		return err
+	}
+	names, err := s.envelopeNames(in.SpaceID)
+	if err != nil {
+		return err
+	}
+	fromName, ok := names[in.From]
+	if !ok {
+		// An envelope whose categories are gone can still be emptied.
+		held, err := s.holdsAssignments(space, in.From, month)
+		if err != nil {
+			return err
+		}
+		if !held {
+			return ErrEnvelopeNotFound
+		}
+		fromName = in.From
+	}
+	toName, ok := names[in.To]
+	if !ok {
+		return ErrEnvelopeNotFound
+	}
+	if err := s.repo.Move(space.ID, in.From, in.To, month, in.Amount); err != nil {
+		return fmt.Errorf("failed to move money: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: space.ID,
+		ActorID: in.ActorID,
+		Action:  model.SpaceAuditActionEnvelopeMoneyMoved,
+		Metadata: map[string]any{
+			"from":     fromName,
+			"to":       toName,
+			"month":    month.Format("2006-01"),
+			"amount":   in.Amount.String(),
+			"currency": space.ReportingCurrency,
+		},
+	})
+	return nil
+}
+
+// holdsAssignments reports whether anything was assigned to the envelope
+// from the start of the space's budget through month, which is what keeps
+// an envelope in view after its categories are gone (see Budget).
+func (s *EnvelopeService) holdsAssignments(space *model.Space, envelope string, month time.Time) (bool, error) {
+	assignments, err := s.repo.Assignments(space.ID, model.MonthStart(*space.EnvelopeBudgetSince), month)
+	if err != nil {
+		return false, fmt.Errorf("failed to load assignments: %w", err)
+	}
+	for _, a := range assignments {
+		if a.Envelope == envelope {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// budgetMonth loads a space with envelope budgeting on and checks the month
+// is part of its budget.
+func (s *EnvelopeService) budgetMonth(spaceID string, month time.Time) (*model.Space, time.Time, error) {
+	space, err := s.spaceRepo.ByID(spaceID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if space.EnvelopeBudgetSince == nil {
+		return nil, time.Time{}, ErrEnvelopeBudgetingOff
+	}
+	month = model.MonthStart(month)
+	if month.Before(model.MonthStart(*space.EnvelopeBudgetSince)) {
+		return nil, time.Time{}, ErrEnvelopeMonthBeforeStart
+	}
+	return space, month, nil
+}
+
+// envelopeAccount reports whether an account's money is budgeted in
+// envelopes. Investments and loans are saved or owed, not spent.
+func envelopeAccount(a *model.Account) bool {
+	return !a.IsInvestment && a.Kind != model.AccountKindLoan
+}
+
+// envelopeKey is the envelope a category or investment or loan account with
+// this name belongs to.
+func envelopeKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// envelopeNames maps each of the space's envelopes to the name it is shown
+// as: the first spelling of the category or account name found.
+func (s *EnvelopeService) envelopeNames(spaceID string) (map[string]string, error) {
+	accounts, err := s.accountService.GetAccountsForSpace(spaceID)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{uncategorizedEnvelope: "Uncategorized"}
+	for _, a := range accounts {
+		if !envelopeAccount(a) {
+			if _, ok := names[envelopeKey(a.Name)]; !ok {
+				names[envelopeKey(a.Name)] = strings.TrimSpace(a.Name)
+			}
+			continue
+		}
+		cats, err := s.categoryRepo.ListByAccount(a.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load categories: %w", err)
+		}
+		for _, c := range cats {
+			if _, ok := names[envelopeKey(c.Name)]; !ok {
+				names[envelopeKey(c.Name)] = strings.TrimSpace(c.Name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// Budget works out the space's envelope budget for a month, replaying every
+// month since the budget started so balances carry forward. A transfer
+// between two budgeted accounts moves money without spending it, but one
+// that crosses into an investment or loan account is activity in that
+// account's envelope, and one coming back out of it is income.
+func (s *EnvelopeService) Budget(spaceID string, month time.Time) (*EnvelopeBudget, error) {
+	space, month, err := s.budgetMonth(spaceID, month)
+	if err != nil {
+		return nil, err
+	}
+	since := model.MonthStart(*space.EnvelopeBudgetSince)
+	monthIndex := func(t time.Time) int {
+		return (t.Year()-since.Year())*12 + int(t.Month()) - int(since.Month())
+	}
+	n := monthIndex(month) + 1
+	end := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
+
+	budget := &EnvelopeBudget{
+		Month:        month,
+		Since:        since,
+		Currency:     space.ReportingCurrency,
+		Opening:      decimal.Zero,
+		Income:       decimal.Zero,
+		ToBeAssigned: decimal.Zero,
+	}
+
+	accounts, err := s.accountService.GetAccountsForSpace(space.ID)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{uncategorizedEnvelope: "Uncategorized"}
+	outside := map[string]string{}
+	for _, a := range accounts {
+		if !envelopeAccount(a) {
+			outside[a.ID] = envelopeKey(a.Name)
+			if _, ok := names[envelopeKey(a.Name)]; !ok {
+				names[envelopeKey(a.Name)] = strings.TrimSpace(a.Name)
+			}
+		}
+	}
+	spent := make([]map[string]decimal.Decimal, n)
+	for i := range spent {
+		spent[i] = map[string]decimal.Decimal{}
+	}
+	income := make([]decimal.Decimal, n)
+	var budgeted []*model.Account
+	for _, a := range accounts {
+		if !envelopeAccount(a) {
+			continue
+		}
+		convert := func(txType model.TransactionType) ([]repository.CategoryBucketRow, error) {
+			return s.txService.convertedCategoryRows(CategorySeriesInput{
+				AccountID:            a.ID,
+				Type:                 txType,
+				From:                 since,
+				To:                   end,
+				IncludeUncategorized: true,
+				Currency:             space.ReportingCurrency,
+			})
+		}
+		bills, err := convert(model.TransactionTypeWithdrawal)
+		if errors.Is(err, ErrNoExchangeRate) {
+			budget.Skipped = append(budget.Skipped, a.Name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		deposits, err := convert(model.TransactionTypeDeposit)
+		if err != nil {
+			return nil, err
+		}
+		transfers, err := s.txService.convertedTransferRows(a, since, end, space.ReportingCurrency)
+		if err != nil {
+			return nil, err
+		}
+		budgeted = append(budgeted, a)
+
+		cats, err := s.categoryRepo.ListByAccount(a.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load categories: %w", err)
+		}
+		keyByID := make(map[string]string, len(cats))
+		for _, c := range cats {
+			key := envelopeKey(c.Name)
+			keyByID[c.ID] = key
+			if _, ok := names[key]; !ok {
+				names[key] = strings.TrimSpace(c.Name)
+			}
+		}
+		for _, row := range bills {
+			i := monthIndex(row.Bucket)
+			if i < 0 || i >= n {
+				continue
+			}
+			key := uncategorizedEnvelope
+			if row.CategoryID != nil {
+				key = keyByID[*row.CategoryID]
+			}
+			spent[i][key] = spent[i][key].Add(row.Total)
+		}
+		for _, row := range deposits {
+			if i := monthIndex(row.Bucket); i >= 0 && i < n {
+				income[i] = income[i].Add(row.Total)
+			}
+		}
+		for _, row := range transfers {
+			key, ok := outside[row.CounterpartAccountID]
+			i := monthIndex(row.Bucket)
+			if !ok || i < 0 || i >= n {
+				continue
+			}
+			if row.Type == model.TransactionTypeWithdrawal {
+				spent[i][key] = spent[i][key].Add(row.Total)
+			} else {
+				income[i] = income[i].Add(row.Total)
+			}
+		}
+	}
+
+	if len(budgeted) > 0 {
+		opening, err := s.rateSvc.ConvertedTotals(space.ID, budgeted, space.ReportingCurrency, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load opening balances: %w", err)
+		}
+		budget.Opening = opening.Assets.Sub(opening.Liabilities)
+	}
+
+	assignments, err := s.repo.Assignments(space.ID, since, month)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load assignments: %w", err)
+	}
+	assigned := make([]map[string]decimal.Decimal, n)
+	for i := range assigned {
+		assigned[i] = map[string]decimal.Decimal{}
+	}
+	for _, a := range assignments {
+		assigned[monthIndex(a.Month)][a.Envelope] = a.Amount
+		if _, ok := names[a.Envelope]; !ok {
+			// The envelope's categories are gone; keep what it holds in view.
+			names[a.Envelope] = a.Envelope
+		}
+	}
+
+	keys := make([]string, 0, len(names))
+	for k := range names {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == uncategorizedEnvelope || keys[j] == uncategorizedEnvelope {
+			return keys[j] == uncategorizedEnvelope && keys[i] != uncategorizedEnvelope
+		}
+		return keys[i] < keys[j]
+	})
+
+	toAssign := budget.Opening
+	carry := map[string]decimal.Decimal{}
+	for i := 0; i < n; i++ {
+		toAssign = toAssign.Add(income[i])
+		var months []*model.EnvelopeMonth
+		for _, k := range keys {
+			m := &model.EnvelopeMonth{
+				Envelope:  k,
+				Name:      names[k],
+				CarriedIn: carry[k],
+				Assigned:  assigned[i][k],
+				Activity:  spent[i][k].Neg(),
+			}
+			toAssign = toAssign.Sub(m.Assigned)
+			if i < n-1 {
+				// Overspending is covered by next month's money to assign.
+				if m.Overspent() {
+					toAssign = toAssign.Add(m.Available())
+				}
+				carry[k] = m.CarriedOut()
+			}
+			months = append(months, m)
+		}
+		if i == n-1 {
+			budget.Envelopes = months
+			budget.Income = income[i]
+		}
+	}
+	budget.ToBeAssigned = toAssign
+
+	// Uncategorized only shows once something lands in it.
+	if u := budget.Envelope(uncategorizedEnvelope); u != nil &&
+		u.CarriedIn.IsZero() && u.Assigned.IsZero() && u.Activity.IsZero() {
+		budget.Envelopes = budget.Envelopes[:len(budget.Envelopes)-1]
+	}
+	return budget, nil
+}
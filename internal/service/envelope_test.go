@@ -0,0 +1,206 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEnvelopeFixture(t *testing.T, dbi testutil.DBInfo) (*txnFixture, *EnvelopeService, repository.SpaceRepository) {
+	t.Helper()
+	f := newTxnFixture(t, dbi)
+	txnRepo := repository.NewTransactionRepository(dbi.DB)
+	spaceRepo := repository.NewSpaceRepository(dbi.DB)
+	svc := NewEnvelopeService(
+		repository.NewEnvelopeRepository(dbi.DB),
+		spaceRepo,
+		repository.NewCategoryRepository(dbi.DB),
+		NewAccountService(f.accounts),
+		f.svc,
+		NewExchangeRateService(repository.NewExchangeRateRepository(dbi.DB), txnRepo),
+	)
+	return f, svc, spaceRepo
+}
+
+func TestEnvelopeService_EnableAndDisable(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc, spaceRepo := newEnvelopeFixture(t, dbi)
+		spaceID := f.account.SpaceID
+
+		_, err := svc.Budget(spaceID, time.Now())
+		assert.ErrorIs(t, err, ErrEnvelopeBudgetingOff)
+
+		require.NoError(t, svc.Enable(spaceID, f.user.ID))
+		space, err := spaceRepo.ByID(spaceID)
+		require.NoError(t, err)
+		require.NotNil(t, space.EnvelopeBudgetSince)
+		assert.True(t, model.MonthStart(time.Now()).Equal(model.MonthStart(*space.EnvelopeBudgetSince)))
+
+		_, err = svc.Budget(spaceID, time.Now())
+		require.NoError(t, err)
+
+		require.NoError(t, svc.Disable(spaceID, f.user.ID))
+		_, err = svc.Budget(spaceID, time.Now())
+		assert.ErrorIs(t, err, ErrEnvelopeBudgetingOff)
+	})
+}
+
+func TestEnvelopeService_BudgetCarriesForward(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc, spaceRepo := newEnvelopeFixture(t, dbi)
+		spaceID := f.account.SpaceID
+		food := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Food")
+		rent := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Rent")
+
+		thisMonth := model.MonthStart(time.Now())
+		twoAgo := thisMonth.AddDate(0, -2, 0)
+		lastMonth := thisMonth.AddDate(0, -1, 0)
+		require.NoError(t, spaceRepo.SetEnvelopeBudgetSince(spaceID, &twoAgo))
+
+		deposit := func(amount int64, when time.Time) {
+			_, err := f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(amount), OccurredAt: when, ActorID: f.user.ID})
+			require.NoError(t, err)
+		}
+		pay := func(amount int64, cat string, when time.Time) {
+			_, err := f.svc.PayBill(PayBillInput{AccountID: f.account.ID, Title: "Bill", Amount: decimal.NewFromInt(amount), OccurredAt: when, CategoryID: cat, ActorID: f.user.ID})
+			require.NoError(t, err)
+		}
+		assign := func(envelope string, month time.Time, amount int64) {
+			require.NoError(t, svc.Assign(AssignEnvelopeInput{SpaceID: spaceID, Envelope: envelope, Month: month, Amount: decimal.NewFromInt(amount), ActorID: f.user.ID}))
+		}
+
+		deposit(1000, twoAgo.AddDate(0, -1, 0)) // before the budget: the opening balance
+		deposit(2000, twoAgo.AddDate(0, 0, 1))
+		assign("food", twoAgo, 300)
+		assign("rent", twoAgo, 1500)
+		pay(350, food.ID, twoAgo.AddDate(0, 0, 10))
+		pay(1500, rent.ID, twoAgo.AddDate(0, 0, 2))
+		assign("food", lastMonth, 200)
+		pay(100, food.ID, lastMonth.AddDate(0, 0, 10))
+
+		err := svc.Assign(AssignEnvelopeInput{SpaceID: spaceID, Envelope: "food", Month: twoAgo.AddDate(0, -1, 0), Amount: decimal.NewFromInt(1)})
+		assert.ErrorIs(t, err, ErrEnvelopeMonthBeforeStart)
+		err = svc.Assign(AssignEnvelopeInput{SpaceID: spaceID, Envelope: "travel", Month: lastMonth, Amount: decimal.NewFromInt(1)})
+		assert.ErrorIs(t, err, ErrEnvelopeNotFound)
+
+		b, err := svc.Budget(spaceID, twoAgo)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(1000).Equal(b.Opening))
+		assert.True(t, decimal.NewFromInt(2000).Equal(b.Income))
+		assert.True(t, decimal.NewFromInt(1200).Equal(b.ToBeAssigned))
+		require.Len(t, b.Overspent(), 1)
+		assert.Equal(t, "Food", b.Overspent()[0].Name)
+
+		b, err = svc.Budget(spaceID, lastMonth)
+		require.NoError(t, err)
+		fm := b.Envelope("food")
+		require.NotNil(t, fm)
+		assert.True(t, fm.CarriedIn.IsZero(), "overspending isn't carried")
+		assert.True(t, decimal.NewFromInt(100).Equal(fm.Available()))
+		assert.True(t, decimal.NewFromInt(950).Equal(b.ToBeAssigned), "last month's overspending comes out of the money to assign")
+
+		require.NoError(t, svc.Move(MoveEnvelopeMoneyInput{SpaceID: spaceID, From: "food", To: "rent", Month: lastMonth, Amount: decimal.NewFromInt(50), ActorID: f.user.ID}))
+		err = svc.Move(MoveEnvelopeMoneyInput{SpaceID: spaceID, From: "food", To: "food", Month: lastMonth, Amount: decimal.NewFromInt(50)})
+		assert.ErrorIs(t, err, ErrInvalidEnvelopeMove)
+
+		b, err = svc.Budget(spaceID, lastMonth)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(50).Equal(b.Envelope("food").Available()))
+		assert.True(t, decimal.NewFromInt(50).Equal(b.Envelope("rent").Available()))
+		assert.True(t, decimal.NewFromInt(950).Equal(b.ToBeAssigned), "moving money doesn't change what's left to assign")
+
+		b, err = svc.Budget(spaceID, thisMonth)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(50).Equal(b.Envelope("food").CarriedIn))
+		assert.True(t, decimal.NewFromInt(50).Equal(b.Envelope("rent").CarriedIn))
+		assert.Nil(t, b.Envelope(""), "no uncategorized spending")
+	})
+}
+
+func TestEnvelopeService_BudgetCountsTransfersLeavingTheBudget(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc, spaceRepo := newEnvelopeFixture(t, dbi)
+		spaceID := f.account.SpaceID
+		savings := testutil.CreateTestAccount(t, dbi.DB, spaceID, "Savings")
+		brokerage := testutil.CreateTestAccount(t, dbi.DB, spaceID, "Brokerage")
+		_, err := dbi.DB.Exec(`UPDATE accounts SET is_investment = true WHERE id = $1`, brokerage.ID)
+		require.NoError(t, err)
+
+		month := model.MonthStart(time.Now())
+		require.NoError(t, spaceRepo.SetEnvelopeBudgetSince(spaceID, &month))
+
+		_, err = f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(1000), OccurredAt: month.AddDate(0, 0, 1), ActorID: f.user.ID})
+		require.NoError(t, err)
+		transfer := func(from, to string, amount int64) {
+			_, err := f.svc.Transfer(TransferInput{SourceAccountID: from, DestAccountID: to, Title: "Move", Amount: decimal.NewFromInt(amount), OccurredAt: month.AddDate(0, 0, 2), ActorID: f.user.ID})
+			require.NoError(t, err)
+		}
+		transfer(f.account.ID, savings.ID, 300)
+		transfer(f.account.ID, brokerage.ID, 400)
+		transfer(brokerage.ID, savings.ID, 50)
+		require.NoError(t, svc.Assign(AssignEnvelopeInput{SpaceID: spaceID, Envelope: "brokerage", Month: month, Amount: decimal.NewFromInt(400), ActorID: f.user.ID}))
+
+		b, err := svc.Budget(spaceID, month)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(1050).Equal(b.Income), "money back out of the investment is income; the move into savings isn't")
+		e := b.Envelope("brokerage")
+		require.NotNil(t, e)
+		assert.Equal(t, "Brokerage", e.Name)
+		assert.True(t, decimal.NewFromInt(-400).Equal(e.Activity))
+		assert.True(t, e.Available().IsZero())
+		assert.Nil(t, b.Envelope("savings"), "savings is budgeted, not an envelope")
+		assert.True(t, decimal.NewFromInt(650).Equal(b.ToBeAssigned))
+	})
+}
+
+func TestEnvelopeService_AssignmentsFollowRenamedAndMergedCategories(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f, svc, spaceRepo := newEnvelopeFixture(t, dbi)
+		spaceID := f.account.SpaceID
+		cats := NewCategoryService(repository.NewCategoryRepository(dbi.DB), f.accounts, repository.NewTransactionRepository(dbi.DB))
+		food := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Food")
+		dining := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Dining")
+		testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Rent")
+
+		month := model.MonthStart(time.Now())
+		require.NoError(t, spaceRepo.SetEnvelopeBudgetSince(spaceID, &month))
+		assign := func(envelope string, amount int64) {
+			require.NoError(t, svc.Assign(AssignEnvelopeInput{SpaceID: spaceID, Envelope: envelope, Month: month, Amount: decimal.NewFromInt(amount), ActorID: f.user.ID}))
+		}
+		assign("food", 300)
+		assign("dining", 100)
+
+		_, err := cats.Rename(f.account.ID, food.ID, "Groceries", f.user.ID)
+		require.NoError(t, err)
+		b, err := svc.Budget(spaceID, month)
+		require.NoError(t, err)
+		assert.Nil(t, b.Envelope("food"))
+		require.NotNil(t, b.Envelope("groceries"))
+		assert.True(t, decimal.NewFromInt(300).Equal(b.Envelope("groceries").Assigned))
+
+		_, err = cats.Merge(f.account.ID, dining.ID, food.ID, f.user.ID)
+		require.NoError(t, err)
+		b, err = svc.Budget(spaceID, month)
+		require.NoError(t, err)
+		assert.Nil(t, b.Envelope("dining"))
+		assert.True(t, decimal.NewFromInt(400).Equal(b.Envelope("groceries").Assigned))
+
+		// An envelope left behind by a deleted category can be emptied.
+		travel := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Travel")
+		assign("travel", 50)
+		require.NoError(t, cats.Delete(f.account.ID, travel.ID))
+		require.NoError(t, svc.Move(MoveEnvelopeMoneyInput{SpaceID: spaceID, From: "travel", To: "rent", Month: month, Amount: decimal.NewFromInt(50), ActorID: f.user.ID}))
+		err = svc.Move(MoveEnvelopeMoneyInput{SpaceID: spaceID, From: "rent", To: "travel", Month: month, Amount: decimal.NewFromInt(10), ActorID: f.user.ID})
+		assert.ErrorIs(t, err, ErrEnvelopeNotFound)
+		b, err = svc.Budget(spaceID, month)
+		require.NoError(t, err)
+		assert.True(t, b.Envelope("travel").Available().IsZero())
+		assert.True(t, decimal.NewFromInt(50).Equal(b.Envelope("rent").Available()))
+	})
+}
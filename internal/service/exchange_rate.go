@@ -0,0 +1,388 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/fxrates"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrUnsupportedCurrency is returned for a currency code budgit doesn't list.
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// ErrSameCurrency is returned for a rate between a currency and itself.
+var ErrSameCurrency = errors.New("base and quote currencies must differ")
+
+// ErrInvalidExchangeRate is returned for a rate that isn't positive.
+var ErrInvalidExchangeRate = errors.New("exchange rate must be greater than zero")
+
+// ErrExchangeRateNotFound is returned when a rate doesn't exist in the space.
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+// ErrUnrecognizedRateFile is returned when an uploaded file is neither an ECB
+// reference-rate XML file nor a Bank of Canada CSV file.
+var ErrUnrecognizedRateFile = errors.New("unrecognized exchange rate file")
+
+// ErrNoExchangeRate is returned when the space has no rate, direct or
+// through another currency, between two currencies.
+var ErrNoExchangeRate = errors.New("no exchange rate between these currencies")
+
+// ExchangeRateService keeps the space's dated exchange rates and converts
+// amounts into the space's reporting currency with them.
+type ExchangeRateService struct {
+	rateRepo        repository.ExchangeRateRepository
+	transactionRepo repository.TransactionRepository
+	currencySvc     *CurrencyService
+}
+
+func NewExchangeRateService(rateRepo repository.ExchangeRateRepository, transactionRepo repository.TransactionRepository) *ExchangeRateService {
+	return &ExchangeRateService{rateRepo: rateRepo, transactionRepo: transactionRepo}
+}
+
+// SetCurrencyService wires the space currency registry so rates can be kept
+// for a space's own currencies.
+func (s *ExchangeRateService) SetCurrencyService(currencies *CurrencyService) {
+	s.currencySvc = currencies
+}
+
+type SetRateInput struct {
+	SpaceID string
+	Base    string
+	Quote   string
+	Date    time.Time
+	// Rate is how many units of Quote one unit of Base is worth.
+	Rate decimal.Decimal
+}
+
+// SetRate stores a rate typed in by hand, replacing whatever rate the space
+// had for that pair and day.
+func (s *ExchangeRateService) SetRate(in SetRateInput) (*model.ExchangeRate, error) {
+	base, quote := currency.Normalize(in.Base), currency.Normalize(in.Quote)
+	registry, err := s.currencySvc.Registry(in.SpaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !registry.IsValid(base) || !registry.IsValid(quote) {
+		return nil, ErrUnsupportedCurrency
+	}
+	if base == quote {
+		return nil, ErrSameCurrency
+	}
+	if !in.Rate.IsPositive() {
+		return nil, ErrInvalidExchangeRate
+	}
+	if in.Date.IsZero() {
+		return nil, fmt.Errorf("date is required")
+	}
+	rate := newExchangeRate(in.SpaceID, base, quote, in.Date, in.Rate, model.ExchangeRateSourceManual)
+	if _, err := s.rateRepo.Upsert([]*model.ExchangeRate{rate}); err != nil {
+		return nil, fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+	return rate, nil
+}
+
+// Record remembers the rate a transfer or currency change used. Like audit
+// logging, a failure is logged and never fails the action that used the
+// rate, and a nil receiver is a no-op.
+func (s *ExchangeRateService) Record(spaceID, base, quote string, on time.Time, rate decimal.Decimal, source model.ExchangeRateSource) {
+	if s == nil || base == quote || !rate.IsPositive() {
+		return
+	}
+	r := newExchangeRate(spaceID, base, quote, on, rate, source)
+	if _, err := s.rateRepo.Upsert([]*model.ExchangeRate{r}); err != nil {
+		slog.Error("failed to record exchange rate",
+			"error", err,
+			"space_id", spaceID,
+			"pair", base+"/"+quote,
+			"source", source,
+		)
+	}
+}
+
+// RateImportResult summarizes an imported rate file.
+type RateImportResult struct {
+	Source model.ExchangeRateSource
+	// Read is how many rates the file held, Stored how many were saved.
+	// Rates for currencies budgit doesn't list are skipped, as are days the
+	// space already has its own rate for.
+	Read   int
+	Stored int
+}
+
+// Import stores the rates in an ECB euro reference-rate XML file or a Bank of
+// Canada CSV file.
+func (s *ExchangeRateService) Import(spaceID string, data []byte) (*RateImportResult, error) {
+	format, parsed, err := fxrates.Parse(data)
+	if err != nil {
+		if format == "" {
+			return nil, ErrUnrecognizedRateFile
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnrecognizedRateFile, err)
+	}
+	source := model.ExchangeRateSourceECB
+	if format == fxrates.FormatBankOfCanada {
+		source = model.ExchangeRateSourceBankOfCanada
+	}
+
+	rates := make([]*model.ExchangeRate, 0, len(parsed))
+	for _, p := range parsed {
+		if !currency.IsValid(p.Base) || !currency.IsValid(p.Quote) || p.Base == p.Quote {
+			continue
+		}
+		rates = append(rates, newExchangeRate(spaceID, p.Base, p.Quote, p.Date, p.Rate, source))
+	}
+	stored, err := s.rateRepo.Upsert(rates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save exchange rates: %w", err)
+	}
+	return &RateImportResult{Source: source, Read: len(parsed), Stored: stored}, nil
+}
+
+func (s *ExchangeRateService) List(spaceID string, limit, offset int) ([]*model.ExchangeRate, error) {
+	rates, err := s.rateRepo.List(spaceID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+func (s *ExchangeRateService) Count(spaceID string) (int, error) {
+	n, err := s.rateRepo.Count(spaceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count exchange rates: %w", err)
+	}
+	return n, nil
+}
+
+// Delete removes one of the space's rates.
+func (s *ExchangeRateService) Delete(spaceID, id string) error {
+	rate, err := s.rateRepo.ByID(id)
+	if errors.Is(err, repository.ErrExchangeRateNotFound) || (err == nil && rate.SpaceID != spaceID) {
+		return ErrExchangeRateNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load exchange rate: %w", err)
+	}
+	if err := s.rateRepo.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete exchange rate: %w", err)
+	}
+	return nil
+}
+
+// Table loads the space's rates that can convert between the given
+// currencies.
+func (s *ExchangeRateService) Table(spaceID string, currencies ...string) (*RateTable, error) {
+	rates, err := s.rateRepo.ForCurrencies(spaceID, currencies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+	return NewRateTable(rates), nil
+}
+
+// ConvertedTotals is the space's position in one currency.
+type ConvertedTotals struct {
+	model.AccountTotals
+	// Missing lists the account currencies the space has no rate for. Their
+	// accounts are left out of the totals.
+	Missing []string
+}
+
+// ConvertedTotals adds up the accounts in the target currency as of just
+// before the given time. Money in another currency is converted one day at a
+// time: each day's postings at the rate effective on that day, in the
+// currency they were posted in, so an account that changed currency counts
+// its older money in the currency it was held in. An account with money in a
+// currency the space has no rate for is left out.
+func (s *ExchangeRateService) ConvertedTotals(spaceID string, accounts []*model.Account, target string, before time.Time) (*ConvertedTotals, error) {
+	ids := make([]string, len(accounts))
+	for i, a := range accounts {
+		ids[i] = a.ID
+	}
+	days, err := s.transactionRepo.DailyNetByAccountCurrency(ids, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load daily balances: %w", err)
+	}
+	held := make(map[string][]string, len(accounts))
+	currencies := []string{target}
+	for _, a := range accounts {
+		held[a.ID] = []string{a.Currency}
+		currencies = append(currencies, a.Currency)
+	}
+	for _, d := range days {
+		if !slices.Contains(held[d.AccountID], d.Currency) {
+			held[d.AccountID] = append(held[d.AccountID], d.Currency)
+			currencies = append(currencies, d.Currency)
+		}
+	}
+	table, err := s.Table(spaceID, currencies...)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]decimal.Decimal, len(accounts))
+	for _, d := range days {
+		amount, ok := table.Convert(d.Net, d.Currency, target, d.Day)
+		if !ok {
+			continue
+		}
+		balances[d.AccountID] = balances[d.AccountID].Add(amount)
+	}
+
+	result := &ConvertedTotals{AccountTotals: model.AccountTotals{Currency: target}}
+	missing := map[string]bool{}
+	for _, a := range accounts {
+		convertible := true
+		for _, c := range held[a.ID] {
+			if table.Has(c, target) {
+				continue
+			}
+			convertible = false
+			if !missing[c] {
+				missing[c] = true
+				result.Missing = append(result.Missing, c)
+			}
+		}
+		if !convertible {
+			continue
+		}
+		if a.IsLiability() {
+			result.Liabilities = result.Liabilities.Add(balances[a.ID].Neg())
+		} else {
+			result.Assets = result.Assets.Add(balances[a.ID])
+		}
+	}
+	return result, nil
+}
+
+func newExchangeRate(spaceID, base, quote string, on time.Time, rate decimal.Decimal, source model.ExchangeRateSource) *model.ExchangeRate {
+	now := time.Now()
+	return &model.ExchangeRate{
+		ID:            uuid.NewString(),
+		SpaceID:       spaceID,
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		RateDate:      rateDay(on),
+		Rate:          rate,
+		Source:        source,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// rateDay is the calendar day a rate applies to.
+func rateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+type ratePoint struct {
+	day  time.Time
+	rate decimal.Decimal
+}
+
+// RateTable answers what one currency was worth in another on a given day.
+// The rate effective on a day is the latest one on or before it; days before
+// the first known rate use the first one. Pairs without a direct rate go
+// through a third currency, e.g. USD to CAD through EUR with ECB rates.
+type RateTable struct {
+	series     map[string][]ratePoint
+	currencies []string
+}
+
+func NewRateTable(rates []*model.ExchangeRate) *RateTable {
+	t := &RateTable{series: map[string][]ratePoint{}}
+	seen := map[string]bool{}
+	for _, r := range rates {
+		key := pairKey(r.BaseCurrency, r.QuoteCurrency)
+		t.series[key] = append(t.series[key], ratePoint{day: rateDay(r.RateDate), rate: r.Rate})
+		for _, c := range []string{r.BaseCurrency, r.QuoteCurrency} {
+			if !seen[c] {
+				seen[c] = true
+				t.currencies = append(t.currencies, c)
+			}
+		}
+	}
+	for _, points := range t.series {
+		sort.Slice(points, func(i, j int) bool { return points[i].day.Before(points[j].day) })
+	}
+	sort.Strings(t.currencies)
+	return t
+}
+
+func pairKey(base, quote string) string {
+	return base + "/" + quote
+}
+
+// Has reports whether the table can convert from one currency to another.
+func (t *RateTable) Has(from, to string) bool {
+	_, ok := t.Rate(from, to, time.Now())
+	return ok
+}
+
+// Convert turns amount in one currency into another at the rate effective on
+// the given day.
+func (t *RateTable) Convert(amount decimal.Decimal, from, to string, on time.Time) (decimal.Decimal, bool) {
+	rate, ok := t.Rate(from, to, on)
+	if !ok {
+		return decimal.Zero, false
+	}
+	return amount.Mul(rate), true
+}
+
+// Rate is how many units of to one unit of from was worth on the given day.
+// Among the direct rate, the inverse rate and the paths through a third
+// currency, the one whose rates are closest to the day wins.
+func (t *RateTable) Rate(from, to string, on time.Time) (decimal.Decimal, bool) {
+	if from == to {
+		return decimal.NewFromInt(1), true
+	}
+	day := rateDay(on)
+	rate, gap, ok := t.hop(from, to, day)
+	for _, via := range t.currencies {
+		if via == from || via == to {
+			continue
+		}
+		first, gap1, ok1 := t.hop(from, via, day)
+		if !ok1 {
+			continue
+		}
+		second, gap2, ok2 := t.hop(via, to, day)
+		if !ok2 {
+			continue
+		}
+		if g := max(gap1, gap2); !ok || g < gap {
+			rate, gap, ok = first.Mul(second), g, true
+		}
+	}
+	return rate, ok
+}
+
+// hop is the direct or inverse rate from one currency to another, with how
+// far from the day it was set.
+func (t *RateTable) hop(from, to string, day time.Time) (decimal.Decimal, time.Duration, bool) {
+	rate, gap, ok := effective(t.series[pairKey(from, to)], day)
+	if inv, invGap, invOK := effective(t.series[pairKey(to, from)], day); invOK && (!ok || invGap < gap) {
+		rate, gap, ok = decimal.NewFromInt(1).Div(inv), invGap, true
+	}
+	return rate, gap, ok
+}
+
+func effective(points []ratePoint, day time.Time) (decimal.Decimal, time.Duration, bool) {
+	if len(points) == 0 {
+		return decimal.Zero, 0, false
+	}
+	i := sort.Search(len(points), func(i int) bool { return points[i].day.After(day) })
+	if i == 0 {
+		return points[0].rate, points[0].day.Sub(day), true
+	}
+	p := points[i-1]
+	return p.rate, day.Sub(p.day), true
+}
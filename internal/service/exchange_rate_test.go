@@ -0,0 +1,156 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRate(base, quote, day, rate string) *model.ExchangeRate {
+	d, _ := time.Parse("2006-01-02", day)
+	return &model.ExchangeRate{BaseCurrency: base, QuoteCurrency: quote, RateDate: d, Rate: decimal.RequireFromString(rate)}
+}
+
+func TestRateTable_Rate(t *testing.T) {
+	table := NewRateTable([]*model.ExchangeRate{
+		testRate("USD", "CAD", "2024-01-02", "1.30"),
+		testRate("USD", "CAD", "2024-02-01", "1.40"),
+		testRate("EUR", "USD", "2024-01-02", "1.10"),
+	})
+	on := func(day string) time.Time {
+		d, _ := time.Parse("2006-01-02", day)
+		return d
+	}
+
+	// The latest rate on or before the day applies.
+	rate, ok := table.Rate("USD", "CAD", on("2024-01-31"))
+	require.True(t, ok)
+	assert.True(t, rate.Equal(decimal.RequireFromString("1.30")), rate.String())
+
+	rate, ok = table.Rate("USD", "CAD", on("2024-03-01"))
+	require.True(t, ok)
+	assert.True(t, rate.Equal(decimal.RequireFromString("1.40")), rate.String())
+
+	// Before the first rate, the earliest one is used.
+	rate, ok = table.Rate("USD", "CAD", on("2023-06-01"))
+	require.True(t, ok)
+	assert.True(t, rate.Equal(decimal.RequireFromString("1.30")), rate.String())
+
+	// The inverse pair works.
+	amount, ok := table.Convert(decimal.NewFromInt(130), "CAD", "USD", on("2024-01-15"))
+	require.True(t, ok)
+	assert.True(t, amount.Round(2).Equal(decimal.NewFromInt(100)), amount.String())
+
+	// EUR to CAD goes through USD.
+	rate, ok = table.Rate("EUR", "CAD", on("2024-01-15"))
+	require.True(t, ok)
+	assert.True(t, rate.Equal(decimal.RequireFromString("1.43")), rate.String())
+
+	_, ok = table.Rate("GBP", "CAD", on("2024-01-15"))
+	assert.False(t, ok)
+	assert.False(t, table.Has("GBP", "CAD"))
+}
+
+func TestExchangeRateService_ImportKeepsSpaceRates(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		rateRepo := repository.NewExchangeRateRepository(dbi.DB)
+		svc := NewExchangeRateService(rateRepo, repository.NewTransactionRepository(dbi.DB))
+
+		user := testutil.CreateTestUser(t, dbi.DB, "rates-import@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+
+		day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+		_, err := svc.SetRate(SetRateInput{SpaceID: space.ID, Base: "EUR", Quote: "USD", Date: day, Rate: decimal.RequireFromString("1.2")})
+		require.NoError(t, err)
+
+		xml := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2024-01-02">
+			<Cube currency="USD" rate="1.0956"/>
+			<Cube currency="CAD" rate="1.4565"/>
+			<Cube currency="XYZ" rate="9.9"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`)
+		result, err := svc.Import(space.ID, xml)
+		require.NoError(t, err)
+		assert.Equal(t, model.ExchangeRateSourceECB, result.Source)
+		assert.Equal(t, 3, result.Read)
+		assert.Equal(t, 1, result.Stored)
+
+		table, err := svc.Table(space.ID, "EUR", "USD", "CAD")
+		require.NoError(t, err)
+		rate, ok := table.Rate("EUR", "USD", day)
+		require.True(t, ok)
+		assert.True(t, rate.Equal(decimal.RequireFromString("1.2")), rate.String())
+		rate, ok = table.Rate("EUR", "CAD", day)
+		require.True(t, ok)
+		assert.True(t, rate.Equal(decimal.RequireFromString("1.4565")), rate.String())
+
+		_, err = svc.Import(space.ID, []byte("not a rate file"))
+		assert.ErrorIs(t, err, ErrUnrecognizedRateFile)
+	})
+}
+
+func TestExchangeRateService_ConvertedTotals(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		rateRepo := repository.NewExchangeRateRepository(dbi.DB)
+		svc := NewExchangeRateService(rateRepo, repository.NewTransactionRepository(dbi.DB))
+
+		user := testutil.CreateTestUser(t, dbi.DB, "rates-totals@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		cad := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+		usd := testutil.CreateTestAccount(t, dbi.DB, space.ID, "US savings")
+		eur := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Euro")
+		_, err := dbi.DB.Exec(`UPDATE accounts SET currency = 'USD' WHERE id = $1`, usd.ID)
+		require.NoError(t, err)
+		usd.Currency = "USD"
+		_, err = dbi.DB.Exec(`UPDATE accounts SET currency = 'EUR' WHERE id = $1`, eur.ID)
+		require.NoError(t, err)
+		eur.Currency = "EUR"
+
+		testutil.CreateTestTransaction(t, dbi.DB, cad.ID, "Pay", model.TransactionTypeDeposit, decimal.NewFromInt(100))
+		testutil.CreateTestTransaction(t, dbi.DB, usd.ID, "Pay", model.TransactionTypeDeposit, decimal.NewFromInt(50))
+		testutil.CreateTestTransaction(t, dbi.DB, eur.ID, "Pay", model.TransactionTypeDeposit, decimal.NewFromInt(10))
+
+		_, err = svc.SetRate(SetRateInput{SpaceID: space.ID, Base: "USD", Quote: "CAD", Date: time.Now().AddDate(0, 0, -10), Rate: decimal.RequireFromString("1.5")})
+		require.NoError(t, err)
+
+		totals, err := svc.ConvertedTotals(space.ID, []*model.Account{cad, usd, eur}, "CAD", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, "CAD", totals.Currency)
+		assert.True(t, totals.Assets.Equal(decimal.NewFromInt(175)), totals.Assets.String())
+		assert.Equal(t, []string{"EUR"}, totals.Missing)
+	})
+}
+
+func TestExchangeRateService_ConvertedTotals_AfterCurrencyChange(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		svc := NewExchangeRateService(repository.NewExchangeRateRepository(dbi.DB), repository.NewTransactionRepository(dbi.DB))
+		spaceID := f.account.SpaceID
+
+		_, err := f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(200), OccurredAt: time.Now().AddDate(0, 0, -5), ActorID: f.user.ID})
+		require.NoError(t, err)
+		require.NoError(t, NewAccountService(f.accounts).ChangeCurrency(f.account.ID, "USD", decimal.RequireFromString("0.75"), f.user.ID))
+		_, err = svc.SetRate(SetRateInput{SpaceID: spaceID, Base: "USD", Quote: "CAD", Date: time.Now().AddDate(0, 0, -10), Rate: decimal.RequireFromString("1.4")})
+		require.NoError(t, err)
+		account, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+
+		totals, err := svc.ConvertedTotals(spaceID, []*model.Account{account}, "CAD", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.True(t, totals.Assets.Equal(decimal.NewFromInt(210)), "the 150 USD held now, not the 200 CAD deposit read as USD: %s", totals.Assets)
+
+		totals, err = svc.ConvertedTotals(spaceID, []*model.Account{account}, "CAD", time.Now().AddDate(0, 0, -1))
+		require.NoError(t, err)
+		assert.True(t, totals.Assets.Equal(decimal.NewFromInt(200)), "before the change the deposit is still in CAD: %s", totals.Assets)
+	})
+}
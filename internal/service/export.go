@@ -0,0 +1,315 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/ofx"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+)
+
+// ErrUnsupportedExportFormat is returned for an export format other than the
+// ones listed in ExportFormats.
+var ErrUnsupportedExportFormat = errors.New("unsupported export format")
+
+// ExportFormat is the file format of a transaction export.
+type ExportFormat string
+
+const (
+	ExportFormatCSV   ExportFormat = "csv"
+	ExportFormatJSONL ExportFormat = "jsonl"
+	ExportFormatOFX   ExportFormat = "ofx"
+)
+
+// ExportFormats lists the supported formats in the order they are offered.
+var ExportFormats = []ExportFormat{ExportFormatCSV, ExportFormatJSONL, ExportFormatOFX}
+
+// ParseExportFormat validates a user-supplied format name.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	for _, f := range ExportFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", ErrUnsupportedExportFormat
+}
+
+// ContentType is the MIME type to serve the export with.
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatJSONL:
+		return "application/x-ndjson"
+	case ExportFormatOFX:
+		return "application/x-ofx"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Extension is the file extension for a downloaded export, without the dot.
+func (f ExportFormat) Extension() string {
+	return string(f)
+}
+
+var exportCSVHeader = []string{
+	"date", "account", "currency", "type", "title", "description", "amount",
+	"balance", "category", "transfer_account", "transfer_pair_id", "transaction_id",
+}
+
+// exportJSONRecord is one line of a JSON lines export. Amount is signed and
+// encoded as a string so no precision is lost; Balance is the account's
+// running balance after the transaction, encoded the same way.
+type exportJSONRecord struct {
+	ID          string              `json:"id"`
+	Date        string              `json:"date"`
+	AccountID   string              `json:"account_id"`
+	AccountName string              `json:"account_name"`
+	Currency    string              `json:"currency"`
+	Type        string              `json:"type"`
+	Title       string              `json:"title"`
+	Description *string             `json:"description"`
+	Amount      string              `json:"amount"`
+	Balance     string              `json:"balance"`
+	Category    *string             `json:"category"`
+	Transfer    *exportJSONTransfer `json:"transfer"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+type exportJSONTransfer struct {
+	PairID      string `json:"pair_id"`
+	AccountID   string `json:"account_id"`
+	AccountName string `json:"account_name"`
+}
+
+// ExportService streams transactions out of budgit. Rows flow straight from
+// the database cursor to the writer, so exports of any size use constant
+// memory.
+type ExportService struct {
+	transactionRepo repository.TransactionRepository
+	accountService  *AccountService
+}
+
+func NewExportService(transactionRepo repository.TransactionRepository, accountService *AccountService) *ExportService {
+	return &ExportService{
+		transactionRepo: transactionRepo,
+		accountService:  accountService,
+	}
+}
+
+// ExportAccount writes the account's transactions matching the filter to w,
+// oldest first.
+func (s *ExportService) ExportAccount(w io.Writer, accountID string, format ExportFormat, filter model.TransactionFilter) error {
+	account, err := s.accountService.GetAccount(accountID)
+	if err != nil {
+		return fmt.Errorf("failed to load account: %w", err)
+	}
+	if format == ExportFormatOFX {
+		wr := ofx.NewWriter(w)
+		if err := s.writeOFXStatement(wr, account, filter); err != nil {
+			return err
+		}
+		return wr.Close()
+	}
+	return s.writeRows(w, format, account.SpaceID, func(fn func(*model.TransactionExportRow) error) error {
+		return s.transactionRepo.ExportByAccount(account.ID, filter, fn)
+	})
+}
+
+// ExportSpace writes every account's transactions matching the filter to w.
+// CSV and JSON lines interleave accounts chronologically; OFX, which is
+// structured per account, writes one statement per account.
+func (s *ExportService) ExportSpace(w io.Writer, spaceID string, format ExportFormat, filter model.TransactionFilter) error {
+	if format == ExportFormatOFX {
+		accounts, err := s.accountService.GetAccountsForSpace(spaceID)
+		if err != nil {
+			return fmt.Errorf("failed to load accounts: %w", err)
+		}
+		wr := ofx.NewWriter(w)
+		for _, account := range accounts {
+			if err := s.writeOFXStatement(wr, account, filter); err != nil {
+				return err
+			}
+		}
+		return wr.Close()
+	}
+	return s.writeRows(w, format, spaceID, func(fn func(*model.TransactionExportRow) error) error {
+		return s.transactionRepo.ExportBySpace(spaceID, filter, fn)
+	})
+}
+
+// writeRows writes the streamed rows as CSV or JSON lines, each amount to the
+// minor units of its account's currency in the space.
+func (s *ExportService) writeRows(w io.Writer, format ExportFormat, spaceID string, stream func(func(*model.TransactionExportRow) error) error) error {
+	registry, err := s.accountService.currencySvc.Registry(spaceID)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case ExportFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportCSVHeader); err != nil {
+			return err
+		}
+		err := stream(func(row *model.TransactionExportRow) error {
+			if err := cw.Write(exportCSVRecord(row, registry.Get(row.Currency))); err != nil {
+				return err
+			}
+			cw.Flush()
+			return cw.Error()
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export transactions: %w", err)
+		}
+		cw.Flush()
+		return cw.Error()
+	case ExportFormatJSONL:
+		enc := json.NewEncoder(w)
+		err := stream(func(row *model.TransactionExportRow) error {
+			return enc.Encode(exportJSONLine(row, registry.Get(row.Currency)))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export transactions: %w", err)
+		}
+		return nil
+	default:
+		return ErrUnsupportedExportFormat
+	}
+}
+
+// writeOFXStatement writes one account as an OFX statement. The statement is
+// opened on the first row so its start date can come from the data when the
+// filter leaves it open; the end date is the filter's, or today.
+func (s *ExportService) writeOFXStatement(wr *ofx.Writer, account *model.Account, filter model.TransactionFilter) error {
+	info := ofx.StatementInfo{
+		Currency:  s.accountService.currencyOf(account),
+		AccountID: account.ID,
+		End:       time.Now(),
+	}
+	if filter.DateFrom != nil {
+		info.Start = *filter.DateFrom
+	}
+	if filter.DateTo != nil {
+		info.End = *filter.DateTo
+		balance, err := s.transactionRepo.BalanceAsOf(account.ID, *filter.DateTo)
+		if err != nil {
+			return fmt.Errorf("failed to compute closing balance: %w", err)
+		}
+		info.LedgerBalance = &balance
+		info.LedgerBalanceAsOf = *filter.DateTo
+	} else {
+		// The stored balance is only the statement's closing balance when the
+		// export runs up to today.
+		balance := account.Balance
+		info.LedgerBalance = &balance
+		info.LedgerBalanceAsOf = time.Now()
+	}
+
+	begun := false
+	begin := func() error {
+		if begun {
+			return nil
+		}
+		begun = true
+		return wr.Begin(info)
+	}
+	err := s.transactionRepo.ExportByAccount(account.ID, filter, func(row *model.TransactionExportRow) error {
+		if !begun && info.Start.IsZero() {
+			info.Start = row.OccurredAt
+		}
+		if err := begin(); err != nil {
+			return err
+		}
+		return wr.WriteTransaction(exportOFXTransaction(row))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export transactions: %w", err)
+	}
+	if err := begin(); err != nil {
+		return err
+	}
+	return wr.End()
+}
+
+func exportCSVRecord(row *model.TransactionExportRow, cur currency.Currency) []string {
+	transferAccount, transferPair := "", ""
+	if row.TransferPairID != nil {
+		transferPair = *row.TransferPairID
+		transferAccount = ptrOrEmpty(row.TransferAccountName)
+	}
+	return []string{
+		row.OccurredAt.Format("2006-01-02"),
+		row.AccountName,
+		row.Currency,
+		string(row.Type),
+		row.Title,
+		ptrOrEmpty(row.Description),
+		cur.Fixed(row.SignedValue()),
+		runningBalance(row, cur),
+		ptrOrEmpty(row.CategoryName),
+		transferAccount,
+		transferPair,
+		row.ID,
+	}
+}
+
+// runningBalance formats the row's running balance. Every export query sets
+// it; the empty string only guards against one that doesn't.
+func runningBalance(row *model.TransactionExportRow, cur currency.Currency) string {
+	if row.RunningBalance == nil {
+		return ""
+	}
+	return cur.Fixed(*row.RunningBalance)
+}
+
+func exportJSONLine(row *model.TransactionExportRow, cur currency.Currency) exportJSONRecord {
+	rec := exportJSONRecord{
+		ID:          row.ID,
+		Date:        row.OccurredAt.Format("2006-01-02"),
+		AccountID:   row.AccountID,
+		AccountName: row.AccountName,
+		Currency:    row.Currency,
+		Type:        string(row.Type),
+		Title:       row.Title,
+		Description: row.Description,
+		Amount:      cur.Fixed(row.SignedValue()),
+		Balance:     runningBalance(row, cur),
+		Category:    row.CategoryName,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}
+	if row.TransferPairID != nil {
+		rec.Transfer = &exportJSONTransfer{
+			PairID:      *row.TransferPairID,
+			AccountID:   ptrOrEmpty(row.TransferAccountID),
+			AccountName: ptrOrEmpty(row.TransferAccountName),
+		}
+	}
+	return rec
+}
+
+// exportOFXTransaction maps a row to an OFX record. The bank's FITID is kept
+// for imported rows so a round trip through another tool stays idempotent;
+// everything else uses the budgit transaction ID.
+func exportOFXTransaction(row *model.TransactionExportRow) ofx.Transaction {
+	t := ofx.Transaction{
+		FITID:  row.ID,
+		Posted: row.OccurredAt,
+		Amount: row.SignedValue(),
+		Name:   row.Title,
+		Memo:   ptrOrEmpty(row.Description),
+	}
+	if row.FITID != nil && *row.FITID != "" {
+		t.FITID = *row.FITID
+	}
+	if row.TransferPairID != nil {
+		t.Type = "XFER"
+	}
+	return t
+}
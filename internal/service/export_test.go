@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/ofx"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExportFormat(t *testing.T) {
+	f, err := ParseExportFormat("jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, ExportFormatJSONL, f)
+
+	_, err = ParseExportFormat("xlsx")
+	assert.ErrorIs(t, err, ErrUnsupportedExportFormat)
+}
+
+func TestExportRecords_UseCurrencyMinorUnits(t *testing.T) {
+	balance := decimal.NewFromInt(8800)
+	row := &model.TransactionExportRow{
+		Transaction: model.Transaction{
+			Value: decimal.NewFromInt(1200), Type: model.TransactionTypeWithdrawal, RunningBalance: &balance,
+		},
+		Currency: "JPY",
+	}
+	jpy := currency.Get("JPY")
+
+	record := exportCSVRecord(row, jpy)
+	assert.Equal(t, "-1200", record[6])
+	assert.Equal(t, "8800", record[7])
+
+	line := exportJSONLine(row, jpy)
+	assert.Equal(t, "-1200", line.Amount)
+	assert.Equal(t, "8800", line.Balance)
+}
+
+// seedExport creates a categorized bill on the fixture account and a transfer
+// to a second account in the same space.
+func seedExport(t *testing.T, dbi testutil.DBInfo, f *txnFixture) (savings *model.Account) {
+	t.Helper()
+	groceries := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Groceries")
+	savings = testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Savings")
+
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	_, err := f.svc.Deposit(DepositInput{AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(500), OccurredAt: day})
+	require.NoError(t, err)
+	_, err = f.svc.PayBill(PayBillInput{
+		AccountID: f.account.ID, Title: "Market, \"fresh\"", Amount: decimal.RequireFromString("42.10"),
+		OccurredAt: day.AddDate(0, 0, 1), CategoryID: groceries.ID,
+	})
+	require.NoError(t, err)
+	_, err = f.svc.Transfer(TransferInput{
+		SourceAccountID: f.account.ID, DestAccountID: savings.ID, Title: "Stash",
+		Amount: decimal.NewFromInt(100), OccurredAt: day.AddDate(0, 0, 2),
+	})
+	require.NoError(t, err)
+	return savings
+}
+
+func TestExportService_ExportAccount_CSV(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		seedExport(t, dbi, f)
+		svc := NewExportService(repository.NewTransactionRepository(dbi.DB), NewAccountService(repository.NewAccountRepository(dbi.DB)))
+
+		var buf bytes.Buffer
+		require.NoError(t, svc.ExportAccount(&buf, f.account.ID, ExportFormatCSV, model.TransactionFilter{}))
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+		assert.Equal(t, exportCSVHeader, records[0])
+
+		bill := records[2]
+		assert.Equal(t, "2024-05-02", bill[0])
+		assert.Equal(t, "CAD", bill[2])
+		assert.Equal(t, "Market, \"fresh\"", bill[4])
+		assert.Equal(t, "-42.10", bill[6])
+		assert.Equal(t, "457.90", bill[7])
+		assert.Equal(t, "Groceries", bill[8])
+
+		transfer := records[3]
+		assert.Equal(t, "357.90", transfer[7])
+		assert.Equal(t, "Savings", transfer[9])
+		assert.NotEmpty(t, transfer[10])
+
+		// The filter narrows the export exactly like the transactions page.
+		buf.Reset()
+		require.NoError(t, svc.ExportAccount(&buf, f.account.ID, ExportFormatCSV, model.TransactionFilter{Title: "market"}))
+		records, err = csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "457.90", records[1][7], "balances still count rows the filter hides")
+	})
+}
+
+func TestExportService_ExportSpace_JSONLines(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		savings := seedExport(t, dbi, f)
+		svc := NewExportService(repository.NewTransactionRepository(dbi.DB), NewAccountService(repository.NewAccountRepository(dbi.DB)))
+
+		var buf bytes.Buffer
+		require.NoError(t, svc.ExportSpace(&buf, f.account.SpaceID, ExportFormatJSONL, model.TransactionFilter{}))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 4)
+
+		var pairs []exportJSONRecord
+		for _, line := range lines {
+			var rec exportJSONRecord
+			require.NoError(t, json.Unmarshal([]byte(line), &rec))
+			if rec.Transfer != nil {
+				pairs = append(pairs, rec)
+			}
+		}
+		require.Len(t, pairs, 2)
+		assert.Equal(t, pairs[0].ID, pairs[1].Transfer.PairID)
+		assert.Equal(t, pairs[1].ID, pairs[0].Transfer.PairID)
+		for _, p := range pairs {
+			if p.AccountID == savings.ID {
+				assert.Equal(t, "100.00", p.Amount)
+				assert.Equal(t, "100.00", p.Balance)
+				assert.Equal(t, f.account.ID, p.Transfer.AccountID)
+			}
+		}
+	})
+}
+
+func TestExportService_ExportAccount_OFXRoundTrips(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		seedExport(t, dbi, f)
+		svc := NewExportService(repository.NewTransactionRepository(dbi.DB), NewAccountService(repository.NewAccountRepository(dbi.DB)))
+
+		var buf bytes.Buffer
+		require.NoError(t, svc.ExportAccount(&buf, f.account.ID, ExportFormatOFX, model.TransactionFilter{}))
+
+		stmt, err := ofx.Parse(buf.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "CAD", stmt.Currency)
+		require.Len(t, stmt.Transactions, 3)
+		assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), stmt.Start)
+		assert.Equal(t, "XFER", stmt.Transactions[2].Type)
+
+		acct, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stmt.LedgerBalance)
+		assert.True(t, acct.Balance.Equal(*stmt.LedgerBalance))
+
+		// With an end date the closing balance is the balance on that day.
+		buf.Reset()
+		end := time.Date(2024, 5, 2, 23, 59, 59, 0, time.UTC)
+		require.NoError(t, svc.ExportAccount(&buf, f.account.ID, ExportFormatOFX, model.TransactionFilter{DateTo: &end}))
+		stmt, err = ofx.Parse(buf.Bytes())
+		require.NoError(t, err)
+		require.NotNil(t, stmt.LedgerBalance)
+		assert.Equal(t, "457.9", stmt.LedgerBalance.String())
+	})
+}
@@ -0,0 +1,787 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/ofx"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+// ErrImportNoRows is returned when a commit is attempted with no valid,
+// selected rows left to import.
+var ErrImportNoRows = errors.New("import has no rows to commit")
+
+// ErrImportBatchRolledBack is returned when rolling back a batch that has
+// already been rolled back.
+var ErrImportBatchRolledBack = errors.New("import batch has already been rolled back")
+
+// ErrImportBatchNotFound is returned when a batch does not exist or does not
+// belong to the requested account.
+var ErrImportBatchNotFound = errors.New("import batch not found")
+
+// ErrImportCurrencyMismatch is returned when a statement's currency differs
+// from the account it is being imported into.
+var ErrImportCurrencyMismatch = errors.New("statement currency does not match account currency")
+
+// maxImportRows caps a single import so a runaway file can't hold a SQL
+// transaction open indefinitely.
+const maxImportRows = 5000
+
+// ImportDateFormat is a date layout the importer accepts, paired with the label
+// shown in the column mapping form.
+type ImportDateFormat struct {
+	Layout string
+	Label  string
+}
+
+// ImportDateFormats lists the date layouts offered when mapping a CSV. The
+// non-ISO layouts use unpadded month/day so both "1/5/2024" and "01/05/2024"
+// parse.
+var ImportDateFormats = []ImportDateFormat{
+	{Layout: "2006-01-02", Label: "YYYY-MM-DD"},
+	{Layout: "1/2/2006", Label: "MM/DD/YYYY"},
+	{Layout: "2/1/2006", Label: "DD/MM/YYYY"},
+	{Layout: "2006/1/2", Label: "YYYY/MM/DD"},
+	{Layout: "20060102", Label: "YYYYMMDD"},
+	{Layout: "Jan 2, 2006", Label: "Mon D, YYYY"},
+}
+
+func isImportDateLayout(layout string) bool {
+	for _, f := range ImportDateFormats {
+		if f.Layout == layout {
+			return true
+		}
+	}
+	return false
+}
+
+// CSVFile is a parsed CSV statement: the header row and the data rows beneath
+// it. Blank lines are dropped.
+type CSVFile struct {
+	Header []string
+	Rows   [][]string
+	// Lines holds the 1-based source line each row started on.
+	Lines []int
+}
+
+// ParseCSV reads a CSV statement. The first non-blank row is treated as the
+// header. Comma is the default delimiter; semicolon-delimited files (common in
+// European bank exports) are detected from the header.
+func ParseCSV(data []byte) (*CSVFile, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	delimiter := ','
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		delimiter = ';'
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	file := &CSVFile{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if file.Header == nil {
+			for i := range record {
+				record[i] = strings.TrimSpace(record[i])
+			}
+			file.Header = record
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		file.Rows = append(file.Rows, record)
+		file.Lines = append(file.Lines, line)
+		if len(file.Rows) > maxImportRows {
+			return nil, fmt.Errorf("file has more than %d rows", maxImportRows)
+		}
+	}
+	if len(file.Rows) == 0 {
+		return nil, fmt.Errorf("file has no data rows")
+	}
+	return file, nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// CSVMapping maps statement columns (by zero-based index) to transaction
+// fields. Unmapped fields are -1. Either Amount or at least one of
+// Debit/Credit must be mapped.
+type CSVMapping struct {
+	Date        int
+	Title       int
+	Amount      int
+	Debit       int
+	Credit      int
+	Description int
+	Category    int
+	DateFormat  string
+	// InvertAmount flips the sign of the single Amount column, for statements
+	// that list charges as positive numbers (most credit cards).
+	InvertAmount bool
+}
+
+// GuessCSVMapping proposes a mapping from common bank header names. Users can
+// correct it on the mapping form; nothing is assumed beyond header text.
+func GuessCSVMapping(header []string) CSVMapping {
+	m := CSVMapping{
+		Date: -1, Title: -1, Amount: -1, Debit: -1, Credit: -1, Description: -1, Category: -1,
+		DateFormat: ImportDateFormats[0].Layout,
+	}
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(h))
+		switch {
+		case m.Date == -1 && strings.Contains(name, "date"):
+			m.Date = i
+		case m.Debit == -1 && (strings.Contains(name, "debit") || strings.Contains(name, "withdrawal")):
+			m.Debit = i
+		case m.Credit == -1 && (strings.Contains(name, "credit") || strings.Contains(name, "deposit")):
+			m.Credit = i
+		case m.Amount == -1 && strings.Contains(name, "amount"):
+			m.Amount = i
+		case m.Category == -1 && strings.Contains(name, "category"):
+			m.Category = i
+		case m.Title == -1 && (name == "title" || name == "payee" || name == "merchant" || name == "name" || name == "description"):
+			m.Title = i
+		case m.Description == -1 && (strings.Contains(name, "memo") || strings.Contains(name, "note") || strings.Contains(name, "description")):
+			m.Description = i
+		}
+	}
+	if m.Amount != -1 {
+		m.Debit, m.Credit = -1, -1
+	}
+	return m
+}
+
+// Validate checks that the mapping references real columns and covers the
+// required fields. Errors are user-facing.
+func (m CSVMapping) Validate(columns int) error {
+	inRange := func(i int) bool { return i >= -1 && i < columns }
+	for _, i := range []int{m.Date, m.Title, m.Amount, m.Debit, m.Credit, m.Description, m.Category} {
+		if !inRange(i) {
+			return fmt.Errorf("mapping references a column that does not exist")
+		}
+	}
+	if m.Date == -1 {
+		return fmt.Errorf("date column is required")
+	}
+	if m.Title == -1 {
+		return fmt.Errorf("title column is required")
+	}
+	if m.Amount == -1 && m.Debit == -1 && m.Credit == -1 {
+		return fmt.Errorf("an amount column or debit/credit columns are required")
+	}
+	if !isImportDateLayout(m.DateFormat) {
+		return fmt.Errorf("unsupported date format")
+	}
+	return nil
+}
+
+// ImportRow is one statement line after mapping. Amount is always positive;
+// the sign lives in Type. Err is set when the line can't be imported.
+type ImportRow struct {
+	// Line is the 1-based line number in the source file, so it matches what
+	// the user sees in a spreadsheet. It also identifies the row when the user
+	// deselects rows in the preview.
+	Line         int
+	OccurredAt   time.Time
+	Title        string
+	Description  string
+	Amount       decimal.Decimal
+	Type         model.TransactionType
+	CategoryName string
+	CategoryID   *string
+	// FITID is the bank's transaction ID, set for OFX rows.
+	FITID string
+	// Duplicate is true when an existing transaction on the account has the
+	// same date, type, amount and title.
+	Duplicate bool
+	// AlreadyImported is true when the row's FITID is already recorded on the
+	// account. Such rows are never imported again.
+	AlreadyImported bool
+	Err             string
+}
+
+func (r ImportRow) Valid() bool { return r.Err == "" }
+
+// ImportPreview is the parsed, mapped and duplicate-checked view of a
+// statement, shown before anything is written.
+type ImportPreview struct {
+	Rows            []ImportRow
+	ValidCount      int
+	Invalid         int
+	Duplicates      int
+	AlreadyImported int
+	Deposits        decimal.Decimal
+	Withdrawals     decimal.Decimal
+
+	// AccountBalance is the account's stored balance at preview time.
+	AccountBalance decimal.Decimal
+	// StatementBalance is the statement's closing (LEDGERBAL) balance, nil
+	// when the source doesn't report one.
+	StatementBalance     *decimal.Decimal
+	StatementBalanceAsOf *time.Time
+}
+
+// ProjectedBalance is the account balance after importing every valid row
+// not flagged as a duplicate, the default selection.
+func (p *ImportPreview) ProjectedBalance() decimal.Decimal {
+	balance := p.AccountBalance
+	for _, r := range p.Rows {
+		if !r.Valid() || r.Duplicate {
+			continue
+		}
+		if r.Type == model.TransactionTypeDeposit {
+			balance = balance.Add(r.Amount)
+		} else {
+			balance = balance.Sub(r.Amount)
+		}
+	}
+	return balance
+}
+
+// BalanceDrift is the statement balance minus the projected balance. A
+// non-zero drift means budgit and the bank disagree about the account. ok is
+// false when the statement carries no balance.
+func (p *ImportPreview) BalanceDrift() (drift decimal.Decimal, ok bool) {
+	if p.StatementBalance == nil {
+		return decimal.Zero, false
+	}
+	return p.StatementBalance.Sub(p.ProjectedBalance()), true
+}
+
+// ImportService turns bank statements into transactions. Every committed
+// import is recorded as a batch so it can be reviewed or rolled back. Writes go
+// through TransactionService so imported rows are validated and audited the
+// same way as manual entry.
+type ImportService struct {
+	batchRepo          repository.ImportBatchRepository
+	transactionRepo    repository.TransactionRepository
+	categoryRepo       repository.CategoryRepository
+	accountService     *AccountService
+	transactionService *TransactionService
+}
+
+func NewImportService(
+	batchRepo repository.ImportBatchRepository,
+	transactionRepo repository.TransactionRepository,
+	categoryRepo repository.CategoryRepository,
+	accountService *AccountService,
+	transactionService *TransactionService,
+) *ImportService {
+	return &ImportService{
+		batchRepo:          batchRepo,
+		transactionRepo:    transactionRepo,
+		categoryRepo:       categoryRepo,
+		accountService:     accountService,
+		transactionService: transactionService,
+	}
+}
+
+// PreviewCSV maps every row of the file, resolves category names against the
+// account's categories, and flags likely duplicates. Nothing is written.
+func (s *ImportService) PreviewCSV(accountID string, file *CSVFile, mapping CSVMapping) (*ImportPreview, error) {
+	if file == nil {
+		return nil, fmt.Errorf("file is required")
+	}
+	if err := mapping.Validate(len(file.Header)); err != nil {
+		return nil, err
+	}
+
+	account, err := s.accountService.GetAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	cur := s.accountService.currencyOf(account)
+	categoryIDs, err := s.categoryIDsByName(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]ImportRow, 0, len(file.Rows))
+	for i, record := range file.Rows {
+		row := mapCSVRecord(record, mapping, cur)
+		row.Line = i + 2
+		if i < len(file.Lines) {
+			row.Line = file.Lines[i]
+		}
+		if row.CategoryName != "" {
+			if id, ok := categoryIDs[strings.ToLower(row.CategoryName)]; ok {
+				row.CategoryID = &id
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if err := s.markDuplicates(accountID, cur, rows); err != nil {
+		return nil, err
+	}
+	return summarizeImportRows(rows), nil
+}
+
+// categoryIDsByName maps the account's lower-cased category names to IDs.
+func (s *ImportService) categoryIDsByName(accountID string) (map[string]string, error) {
+	categories, err := s.categoryRepo.ListByAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+	ids := make(map[string]string, len(categories))
+	for _, c := range categories {
+		ids[strings.ToLower(c.Name)] = c.ID
+	}
+	return ids, nil
+}
+
+func mapCSVRecord(record []string, m CSVMapping, cur currency.Currency) ImportRow {
+	cell := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := ImportRow{
+		Title:        cell(m.Title),
+		Description:  cell(m.Description),
+		CategoryName: cell(m.Category),
+	}
+
+	occurredAt, err := parseImportDate(cell(m.Date), m.DateFormat)
+	if err != nil {
+		row.Err = "Invalid date."
+		return row
+	}
+	row.OccurredAt = occurredAt
+
+	if row.Title == "" {
+		row.Err = "Title is empty."
+		return row
+	}
+
+	var signed decimal.Decimal
+	if m.Amount != -1 {
+		amt, err := parseImportAmount(cell(m.Amount))
+		if err != nil {
+			row.Err = "Invalid amount."
+			return row
+		}
+		if m.InvertAmount {
+			amt = amt.Neg()
+		}
+		signed = amt
+	} else {
+		debit, debitErr := parseImportAmount(cell(m.Debit))
+		credit, creditErr := parseImportAmount(cell(m.Credit))
+		if debitErr != nil || creditErr != nil {
+			row.Err = "Invalid amount."
+			return row
+		}
+		signed = credit.Abs().Sub(debit.Abs())
+	}
+
+	if signed.IsZero() {
+		row.Err = "Amount is zero."
+		return row
+	}
+	if !cur.Fits(signed) {
+		row.Err = fmt.Sprintf("Amount has more than %d decimal places.", cur.MinorUnits)
+		return row
+	}
+	row.Amount = cur.Round(signed.Abs())
+	if signed.IsNegative() {
+		row.Type = model.TransactionTypeWithdrawal
+	} else {
+		row.Type = model.TransactionTypeDeposit
+	}
+	return row
+}
+
+// parseImportDate parses a statement date with the chosen layout. Cells that
+// carry a trailing time ("2024-01-05 00:00:00", "2024-01-05T00:00:00") are
+// retried with the date part only.
+func parseImportDate(value, layout string) (time.Time, error) {
+	t, err := time.Parse(layout, value)
+	if err == nil {
+		return t, nil
+	}
+	if before, _, ok := strings.Cut(value, "T"); ok && layout != "Jan 2, 2006" {
+		if t, err2 := time.Parse(layout, before); err2 == nil {
+			return t, nil
+		}
+	}
+	if before, _, ok := strings.Cut(value, " "); ok && layout != "Jan 2, 2006" {
+		if t, err2 := time.Parse(layout, before); err2 == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseImportAmount parses a statement amount. Currency symbols, thousands
+// separators and surrounding whitespace are ignored; parentheses and a
+// trailing minus mark negatives. An empty cell is zero so debit/credit pairs
+// can leave one side blank.
+func parseImportAmount(value string) (decimal.Decimal, error) {
+	v := strings.TrimSpace(value)
+	if v == "" {
+		return decimal.Zero, nil
+	}
+	negative := false
+	if strings.HasPrefix(v, "(") && strings.HasSuffix(v, ")") {
+		negative = true
+		v = v[1 : len(v)-1]
+	}
+	if strings.HasSuffix(v, "-") {
+		negative = true
+		v = strings.TrimSuffix(v, "-")
+	}
+	// A lone comma followed by one or two digits is a decimal comma ("3,20");
+	// otherwise commas are thousands separators ("1,234").
+	if i := strings.LastIndex(v, ","); i != -1 && !strings.Contains(v, ".") {
+		if frac := len(v) - i - 1; frac == 1 || frac == 2 {
+			v = v[:i] + "." + v[i+1:]
+		}
+	}
+	v = strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == '-', r == '+':
+			return r
+		}
+		return -1
+	}, v)
+	if v == "" {
+		return decimal.Zero, fmt.Errorf("invalid amount %q", value)
+	}
+	amt, err := decimal.NewFromString(v)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if negative {
+		amt = amt.Neg()
+	}
+	return amt, nil
+}
+
+// importDuplicateKey identifies a transaction for duplicate detection: same
+// calendar day, direction, amount in the account's currency, and
+// (case-insensitive) title.
+func importDuplicateKey(cur currency.Currency, occurredAt time.Time, txType model.TransactionType, amount decimal.Decimal, title string) string {
+	return occurredAt.Format("2006-01-02") + "|" + string(txType) + "|" + cur.Fixed(amount) + "|" + strings.ToLower(strings.TrimSpace(title))
+}
+
+// markDuplicates flags rows that match an existing transaction on the account.
+// Matches are consumed one-for-one, so two identical coffees on the same day
+// in the file only count as duplicates if the account already has two.
+func (s *ImportService) markDuplicates(accountID string, cur currency.Currency, rows []ImportRow) error {
+	var from, to time.Time
+	for _, r := range rows {
+		if !r.Valid() {
+			continue
+		}
+		if from.IsZero() || r.OccurredAt.Before(from) {
+			from = r.OccurredAt
+		}
+		if to.IsZero() || r.OccurredAt.After(to) {
+			to = r.OccurredAt
+		}
+	}
+	if from.IsZero() {
+		return nil
+	}
+
+	existing, err := s.transactionRepo.ListByAccountBetween(accountID, from, to.Add(24*time.Hour-time.Nanosecond))
+	if err != nil {
+		return fmt.Errorf("failed to load existing transactions: %w", err)
+	}
+	seen := make(map[string]int, len(existing))
+	for _, t := range existing {
+		seen[importDuplicateKey(cur, t.OccurredAt, t.Type, t.Value, t.Title)]++
+	}
+	for i := range rows {
+		if !rows[i].Valid() {
+			continue
+		}
+		key := importDuplicateKey(cur, rows[i].OccurredAt, rows[i].Type, rows[i].Amount, rows[i].Title)
+		if seen[key] > 0 {
+			rows[i].Duplicate = true
+			seen[key]--
+		}
+	}
+	return nil
+}
+
+func summarizeImportRows(rows []ImportRow) *ImportPreview {
+	p := &ImportPreview{Rows: rows, Deposits: decimal.Zero, Withdrawals: decimal.Zero}
+	for _, r := range rows {
+		if r.AlreadyImported {
+			p.AlreadyImported++
+			continue
+		}
+		if !r.Valid() {
+			p.Invalid++
+			continue
+		}
+		p.ValidCount++
+		if r.Duplicate {
+			p.Duplicates++
+		}
+		if r.Type == model.TransactionTypeDeposit {
+			p.Deposits = p.Deposits.Add(r.Amount)
+		} else {
+			p.Withdrawals = p.Withdrawals.Add(r.Amount)
+		}
+	}
+	return p
+}
+
+type CommitCSVImportInput struct {
+	AccountID string
+	ActorID   string
+	Filename  string
+	File      *CSVFile
+	Mapping   CSVMapping
+	// Skip holds source line numbers the user excluded in the preview
+	// (typically the flagged duplicates).
+	Skip map[int]bool
+}
+
+// CommitCSV re-runs the preview against the current account state and imports
+// every valid, non-skipped row as one batch. The account balance is adjusted
+// once for the net of the batch.
+func (s *ImportService) CommitCSV(input CommitCSVImportInput) (*model.ImportBatch, error) {
+	if input.AccountID == "" {
+		return nil, fmt.Errorf("account id is required")
+	}
+	preview, err := s.PreviewCSV(input.AccountID, input.File, input.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]ImportRow, 0, len(preview.Rows))
+	for _, r := range preview.Rows {
+		if r.Valid() && !input.Skip[r.Line] {
+			rows = append(rows, r)
+		}
+	}
+	return s.transactionService.ImportTransactions(ImportTransactionsInput{
+		AccountID: input.AccountID,
+		ActorID:   input.ActorID,
+		Source:    model.ImportSourceCSV,
+		Filename:  input.Filename,
+		Rows:      rows,
+	})
+}
+
+// PreviewOFX maps every STMTTRN record of an OFX statement to a row. Rows
+// whose FITID is already on the account are marked AlreadyImported and can't
+// be selected; the remaining rows are checked for likely duplicates of
+// manually entered transactions. Nothing is written.
+func (s *ImportService) PreviewOFX(accountID string, stmt *ofx.Statement) (*ImportPreview, error) {
+	if stmt == nil {
+		return nil, fmt.Errorf("statement is required")
+	}
+	if len(stmt.Transactions) > maxImportRows {
+		return nil, fmt.Errorf("file has more than %d rows", maxImportRows)
+	}
+
+	account, err := s.accountService.GetAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	if stmt.Currency != "" && account.Currency != "" && !strings.EqualFold(stmt.Currency, account.Currency) {
+		return nil, ErrImportCurrencyMismatch
+	}
+	cur := s.accountService.currencyOf(account)
+
+	fitids := make([]string, 0, len(stmt.Transactions))
+	for _, t := range stmt.Transactions {
+		fitids = append(fitids, t.FITID)
+	}
+	existing, err := s.transactionRepo.ExistingFITIDs(account.ID, fitids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load imported transaction ids: %w", err)
+	}
+
+	rows := make([]ImportRow, 0, len(stmt.Transactions))
+	inFile := make(map[string]bool, len(stmt.Transactions))
+	for i, t := range stmt.Transactions {
+		row := mapOFXTransaction(t, cur)
+		row.Line = i + 1
+		switch {
+		case existing[t.FITID]:
+			row.AlreadyImported = true
+			row.Err = "Already imported."
+		case inFile[t.FITID] && row.Err == "":
+			row.Err = "Repeats an earlier transaction in this file."
+		}
+		inFile[t.FITID] = true
+		rows = append(rows, row)
+	}
+
+	if err := s.markDuplicates(account.ID, cur, rows); err != nil {
+		return nil, err
+	}
+	preview := summarizeImportRows(rows)
+	preview.AccountBalance = account.Balance
+	if stmt.LedgerBalance != nil {
+		balance := *stmt.LedgerBalance
+		preview.StatementBalance = &balance
+		if !stmt.LedgerBalanceAsOf.IsZero() {
+			asOf := stmt.LedgerBalanceAsOf
+			preview.StatementBalanceAsOf = &asOf
+		}
+	}
+	return preview, nil
+}
+
+func mapOFXTransaction(t ofx.Transaction, cur currency.Currency) ImportRow {
+	row := ImportRow{
+		OccurredAt: t.Posted,
+		Title:      strings.TrimSpace(t.Name),
+		FITID:      t.FITID,
+	}
+	memo := strings.TrimSpace(t.Memo)
+	switch {
+	case row.Title == "" && memo != "":
+		row.Title = memo
+	case memo != "" && !strings.EqualFold(memo, row.Title):
+		row.Description = memo
+	}
+	if row.Title == "" && t.CheckNo != "" {
+		row.Title = "Cheque #" + t.CheckNo
+	}
+	if row.Title == "" {
+		row.Err = "Title is empty."
+		return row
+	}
+
+	if t.Amount.IsZero() {
+		row.Err = "Amount is zero."
+		return row
+	}
+	if !cur.Fits(t.Amount) {
+		row.Err = fmt.Sprintf("Amount has more than %d decimal places.", cur.MinorUnits)
+		return row
+	}
+	row.Amount = cur.Round(t.Amount.Abs())
+	if t.Amount.IsNegative() {
+		row.Type = model.TransactionTypeWithdrawal
+	} else {
+		row.Type = model.TransactionTypeDeposit
+	}
+	return row
+}
+
+type CommitOFXImportInput struct {
+	AccountID string
+	ActorID   string
+	Filename  string
+	Statement *ofx.Statement
+	// Skip holds row numbers the user excluded in the preview.
+	Skip map[int]bool
+}
+
+// CommitOFX re-runs the preview against the current account state and imports
+// every valid, non-skipped row as one batch, storing each row's FITID so the
+// same statement can be imported again without creating duplicates. The
+// statement's ledger balance is kept on the batch for later comparison.
+func (s *ImportService) CommitOFX(input CommitOFXImportInput) (*model.ImportBatch, error) {
+	if input.AccountID == "" {
+		return nil, fmt.Errorf("account id is required")
+	}
+	preview, err := s.PreviewOFX(input.AccountID, input.Statement)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]ImportRow, 0, len(preview.Rows))
+	for _, r := range preview.Rows {
+		if r.Valid() && !input.Skip[r.Line] {
+			rows = append(rows, r)
+		}
+	}
+	return s.transactionService.ImportTransactions(ImportTransactionsInput{
+		AccountID:            input.AccountID,
+		ActorID:              input.ActorID,
+		Source:               model.ImportSourceOFX,
+		Filename:             input.Filename,
+		StatementBalance:     preview.StatementBalance,
+		StatementBalanceAsOf: preview.StatementBalanceAsOf,
+		Rows:                 rows,
+	})
+}
+
+// ListBatches returns the account's most recent import batches, newest first.
+func (s *ImportService) ListBatches(accountID string, limit int) ([]*model.ImportBatch, error) {
+	batches, err := s.batchRepo.ListByAccount(accountID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import batches: %w", err)
+	}
+	return batches, nil
+}
+
+// GetBatch returns a batch, verifying it belongs to the account.
+func (s *ImportService) GetBatch(accountID, batchID string) (*model.ImportBatch, error) {
+	batch, err := s.batchRepo.ByID(batchID)
+	if err != nil {
+		if errors.Is(err, repository.ErrImportBatchNotFound) {
+			return nil, ErrImportBatchNotFound
+		}
+		return nil, fmt.Errorf("failed to load import batch: %w", err)
+	}
+	if batch.AccountID != accountID {
+		return nil, ErrImportBatchNotFound
+	}
+	return batch, nil
+}
+
+// BatchTransactions returns the batch's transactions that still exist.
+func (s *ImportService) BatchTransactions(batchID string) ([]*model.Transaction, error) {
+	txns, err := s.transactionRepo.ListByImportBatch(batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch transactions: %w", err)
+	}
+	return txns, nil
+}
+
+// RollbackBatch deletes every transaction the batch created that still exists
+// and reverses their effect on the balance in one step. Returns the number of
+// transactions removed.
+func (s *ImportService) RollbackBatch(accountID, batchID, actorID string) (int, error) {
+	batch, err := s.GetBatch(accountID, batchID)
+	if err != nil {
+		return 0, err
+	}
+	return s.transactionService.RollbackImport(batch, actorID)
+}
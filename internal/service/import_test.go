@@ -0,0 +1,308 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/ofx"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type importFixture struct {
+	svc      *ImportService
+	txns     repository.TransactionRepository
+	txAudit  repository.TransactionAuditLogRepository
+	accounts repository.AccountRepository
+	user     *model.User
+	account  *model.Account
+}
+
+func newImportFixture(t *testing.T, dbi testutil.DBInfo) *importFixture {
+	t.Helper()
+
+	txnRepo := repository.NewTransactionRepository(dbi.DB)
+	categoryRepo := repository.NewCategoryRepository(dbi.DB)
+	accountRepo := repository.NewAccountRepository(dbi.DB)
+	auditRepo := repository.NewTransactionAuditLogRepository(dbi.DB)
+
+	accountSvc := NewAccountService(accountRepo)
+	txnSvc := NewTransactionService(txnRepo, categoryRepo, repository.NewTagRepository(dbi.DB), accountSvc)
+	txnSvc.SetAuditLogger(NewTransactionAuditLogService(auditRepo))
+	svc := NewImportService(repository.NewImportBatchRepository(dbi.DB), txnRepo, categoryRepo, accountSvc, txnSvc)
+
+	user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
+	space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+	account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+
+	return &importFixture{
+		svc:      svc,
+		txns:     txnRepo,
+		txAudit:  auditRepo,
+		accounts: accountRepo,
+		user:     user,
+		account:  account,
+	}
+}
+
+func TestParseCSV_SkipsBlankLinesAndTracksLineNumbers(t *testing.T) {
+	file, err := ParseCSV([]byte("\xef\xbb\xbfDate,Description,Amount\n2024-01-05,Coffee,-4.50\n\n2024-01-06,Pay,100\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Date", "Description", "Amount"}, file.Header)
+	require.Len(t, file.Rows, 2)
+	assert.Equal(t, []int{2, 4}, file.Lines)
+}
+
+func TestParseCSV_DetectsSemicolonDelimiter(t *testing.T) {
+	file, err := ParseCSV([]byte("Date;Payee;Amount\n05/01/2024;Bakery;\"-3,20\"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Date", "Payee", "Amount"}, file.Header)
+	assert.Equal(t, "Bakery", file.Rows[0][1])
+}
+
+func TestParseCSV_RejectsHeaderOnly(t *testing.T) {
+	_, err := ParseCSV([]byte("Date,Description,Amount\n"))
+	assert.Error(t, err)
+}
+
+func TestGuessCSVMapping_DebitCreditColumns(t *testing.T) {
+	m := GuessCSVMapping([]string{"Transaction Date", "Description", "Withdrawals", "Deposits", "Balance"})
+	assert.Equal(t, 0, m.Date)
+	assert.Equal(t, 1, m.Title)
+	assert.Equal(t, 2, m.Debit)
+	assert.Equal(t, 3, m.Credit)
+	assert.Equal(t, -1, m.Amount)
+	assert.NoError(t, m.Validate(5))
+}
+
+func TestParseImportAmount(t *testing.T) {
+	cases := map[string]string{
+		"":           "0",
+		"12.34":      "12.34",
+		"-12.34":     "-12.34",
+		"$1,234.50":  "1234.5",
+		"(45.00)":    "-45",
+		"45.00-":     "-45",
+		" CA$ 9.99 ": "9.99",
+		"-3,20":      "-3.2",
+		"1,234":      "1234",
+	}
+	for in, want := range cases {
+		got, err := parseImportAmount(in)
+		require.NoError(t, err, in)
+		assert.True(t, decimal.RequireFromString(want).Equal(got), "%q: got %s", in, got)
+	}
+	_, err := parseImportAmount("n/a")
+	assert.Error(t, err)
+}
+
+func TestImportService_PreviewCSV_FlagsDuplicatesAndErrors(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newImportFixture(t, dbi)
+		testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Dining")
+
+		existing := testutil.CreateTestTransaction(t, dbi.DB, f.account.ID, "Coffee", model.TransactionTypeWithdrawal, decimal.RequireFromString("4.50"))
+		day := existing.OccurredAt.Format("2006-01-02")
+
+		file, err := ParseCSV([]byte("Date,Description,Amount,Category\n" +
+			day + ",coffee,-4.50,Dining\n" +
+			day + ",Coffee,-4.50,Dining\n" +
+			day + ",Paycheque,1500,Unknown\n" +
+			"not-a-date,Broken,1,\n"))
+		require.NoError(t, err)
+
+		mapping := GuessCSVMapping(file.Header)
+		preview, err := f.svc.PreviewCSV(f.account.ID, file, mapping)
+		require.NoError(t, err)
+		require.Len(t, preview.Rows, 4)
+
+		// Only one of the two identical rows matches the single existing coffee.
+		assert.True(t, preview.Rows[0].Duplicate)
+		assert.False(t, preview.Rows[1].Duplicate)
+		assert.NotNil(t, preview.Rows[0].CategoryID)
+
+		assert.Equal(t, model.TransactionTypeDeposit, preview.Rows[2].Type)
+		assert.Nil(t, preview.Rows[2].CategoryID, "unknown category names stay uncategorized")
+
+		assert.False(t, preview.Rows[3].Valid())
+		assert.Equal(t, 3, preview.ValidCount)
+		assert.Equal(t, 1, preview.Invalid)
+		assert.Equal(t, 1, preview.Duplicates)
+		assert.True(t, decimal.RequireFromString("1500").Equal(preview.Deposits))
+		assert.True(t, decimal.RequireFromString("9").Equal(preview.Withdrawals))
+	})
+}
+
+func TestImportService_CommitCSV_AdjustsBalanceOnceAndAuditsBatch(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newImportFixture(t, dbi)
+
+		file, err := ParseCSV([]byte("Date,Payee,Debit,Credit\n" +
+			"2024-03-01,Hydro,80.00,\n" +
+			"2024-03-02,Salary,,2000.00\n" +
+			"2024-03-03,Skip me,5.00,\n"))
+		require.NoError(t, err)
+
+		batch, err := f.svc.CommitCSV(CommitCSVImportInput{
+			AccountID: f.account.ID,
+			ActorID:   f.user.ID,
+			Filename:  "march.csv",
+			File:      file,
+			Mapping:   GuessCSVMapping(file.Header),
+			Skip:      map[int]bool{4: true},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, batch.RowCount)
+		assert.Equal(t, model.ImportSourceCSV, batch.Source)
+
+		acct, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.RequireFromString("1920").Equal(acct.Balance))
+
+		txns, err := f.svc.BatchTransactions(batch.ID)
+		require.NoError(t, err)
+		require.Len(t, txns, 2)
+		assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), txns[0].OccurredAt.UTC())
+
+		logs, err := f.txAudit.ListByTransaction(txns[0].ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, model.TransactionAuditActionCreated, logs[0].Action)
+		var meta map[string]any
+		require.NoError(t, json.Unmarshal(logs[0].Metadata, &meta))
+		assert.Equal(t, batch.ID, meta["import_batch_id"])
+		assert.Equal(t, "80.00", meta["amount"])
+	})
+}
+
+func TestImportService_CommitCSV_NothingSelected(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newImportFixture(t, dbi)
+
+		file, err := ParseCSV([]byte("Date,Title,Amount\n2024-03-01,Hydro,-80\n"))
+		require.NoError(t, err)
+
+		_, err = f.svc.CommitCSV(CommitCSVImportInput{
+			AccountID: f.account.ID,
+			File:      file,
+			Mapping:   GuessCSVMapping(file.Header),
+			Skip:      map[int]bool{2: true},
+		})
+		assert.ErrorIs(t, err, ErrImportNoRows)
+	})
+}
+
+func TestImportService_RollbackBatch_RestoresBalance(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newImportFixture(t, dbi)
+
+		file, err := ParseCSV([]byte("Date,Title,Amount\n2024-03-01,Hydro,-80\n2024-03-02,Refund,20\n"))
+		require.NoError(t, err)
+		batch, err := f.svc.CommitCSV(CommitCSVImportInput{
+			AccountID: f.account.ID, ActorID: f.user.ID, File: file, Mapping: GuessCSVMapping(file.Header),
+		})
+		require.NoError(t, err)
+		txns, err := f.svc.BatchTransactions(batch.ID)
+		require.NoError(t, err)
+
+		removed, err := f.svc.RollbackBatch(f.account.ID, batch.ID, f.user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, removed)
+
+		acct, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.Zero.Equal(acct.Balance))
+
+		logs, err := f.txAudit.ListByTransaction(txns[0].ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 2)
+		assert.Equal(t, model.TransactionAuditActionDeleted, logs[0].Action)
+
+		_, err = f.svc.RollbackBatch(f.account.ID, batch.ID, f.user.ID)
+		assert.ErrorIs(t, err, ErrImportBatchRolledBack)
+	})
+}
+
+func ofxStatement(balance string, txns ...ofx.Transaction) *ofx.Statement {
+	b := decimal.RequireFromString(balance)
+	return &ofx.Statement{
+		Currency:          "CAD",
+		LedgerBalance:     &b,
+		LedgerBalanceAsOf: time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC),
+		Transactions:      txns,
+	}
+}
+
+func ofxTxn(fitid, day, amount, name string) ofx.Transaction {
+	posted, _ := time.Parse("2006-01-02", day)
+	return ofx.Transaction{FITID: fitid, Posted: posted, Amount: decimal.RequireFromString(amount), Name: name}
+}
+
+func TestImportService_CommitOFX_ReimportSkipsKnownFITIDs(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newImportFixture(t, dbi)
+
+		april := ofxStatement("1950",
+			ofxTxn("A1", "2024-04-01", "-50.00", "Hydro"),
+			ofxTxn("A2", "2024-04-15", "2000.00", "Payroll"),
+		)
+		batch, err := f.svc.CommitOFX(CommitOFXImportInput{AccountID: f.account.ID, ActorID: f.user.ID, Statement: april})
+		require.NoError(t, err)
+		assert.Equal(t, 2, batch.RowCount)
+		assert.Equal(t, model.ImportSourceOFX, batch.Source)
+		drift, ok := batch.BalanceDrift()
+		require.True(t, ok)
+		assert.True(t, drift.IsZero(), "drift %s", drift)
+
+		// The next statement overlaps the first one by a transaction.
+		overlap := ofxStatement("1930",
+			ofxTxn("A2", "2024-04-15", "2000.00", "Payroll"),
+			ofxTxn("A3", "2024-04-20", "-20.00", "Coffee"),
+		)
+		preview, err := f.svc.PreviewOFX(f.account.ID, overlap)
+		require.NoError(t, err)
+		assert.True(t, preview.Rows[0].AlreadyImported)
+		assert.False(t, preview.Rows[0].Valid())
+		assert.Equal(t, 1, preview.AlreadyImported)
+		assert.Equal(t, 0, preview.Invalid)
+		assert.Equal(t, 1, preview.ValidCount)
+
+		_, err = f.svc.CommitOFX(CommitOFXImportInput{AccountID: f.account.ID, ActorID: f.user.ID, Statement: overlap})
+		require.NoError(t, err)
+
+		acct, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.RequireFromString("1930").Equal(acct.Balance))
+		count, err := f.txns.CountByAccount(f.account.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		_, err = f.svc.CommitOFX(CommitOFXImportInput{AccountID: f.account.ID, Statement: overlap})
+		assert.ErrorIs(t, err, ErrImportNoRows)
+	})
+}
+
+func TestImportService_PreviewOFX_ReportsLedgerDrift(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newImportFixture(t, dbi)
+		testutil.CreateTestTransaction(t, dbi.DB, f.account.ID, "Cash", model.TransactionTypeDeposit, decimal.RequireFromString("10"))
+
+		preview, err := f.svc.PreviewOFX(f.account.ID, ofxStatement("100", ofxTxn("B1", "2024-04-02", "95.00", "Deposit")))
+		require.NoError(t, err)
+		assert.True(t, decimal.RequireFromString("95").Equal(preview.ProjectedBalance().Sub(preview.AccountBalance)))
+		drift, ok := preview.BalanceDrift()
+		require.True(t, ok)
+		assert.True(t, preview.StatementBalance.Sub(preview.ProjectedBalance()).Equal(drift))
+		require.NotNil(t, preview.StatementBalanceAsOf)
+
+		usd := ofxStatement("0", ofxTxn("B2", "2024-04-02", "1.00", "X"))
+		usd.Currency = "USD"
+		_, err = f.svc.PreviewOFX(f.account.ID, usd)
+		assert.ErrorIs(t, err, ErrImportCurrencyMismatch)
+	})
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/model"
@@ -19,6 +20,7 @@ type InvestmentService struct {
 	roomRepo    repository.InvestmentContributionRoomRepository
 	holdingRepo repository.InvestmentHoldingRepository
 	tradeRepo   repository.InvestmentTradeRepository
+	priceRepo   repository.InvestmentPriceRepository
 	txRepo      repository.TransactionRepository
 }
 
@@ -27,6 +29,7 @@ func NewInvestmentService(
 	roomRepo repository.InvestmentContributionRoomRepository,
 	holdingRepo repository.InvestmentHoldingRepository,
 	tradeRepo repository.InvestmentTradeRepository,
+	priceRepo repository.InvestmentPriceRepository,
 	txRepo repository.TransactionRepository,
 ) *InvestmentService {
 	return &InvestmentService{
@@ -34,6 +37,7 @@ func NewInvestmentService(
 		roomRepo:    roomRepo,
 		holdingRepo: holdingRepo,
 		tradeRepo:   tradeRepo,
+		priceRepo:   priceRepo,
 		txRepo:      txRepo,
 	}
 }
@@ -276,6 +280,48 @@ func (s *InvestmentService) ListTrades(holdingID string) ([]*model.InvestmentTra
 	return s.tradeRepo.ByHoldingID(holdingID)
 }
 
+// ---------- Prices ----------
+
+// RecordPrice stores the holding's market price per unit on the given day,
+// replacing a price already recorded for that day.
+func (s *InvestmentService) RecordPrice(holdingID string, on time.Time, price decimal.Decimal) (*model.InvestmentPrice, error) {
+	if holdingID == "" {
+		return nil, fmt.Errorf("holding id is required")
+	}
+	if price.IsNegative() {
+		return nil, fmt.Errorf("price cannot be negative")
+	}
+	if on.IsZero() {
+		on = time.Now()
+	}
+	now := time.Now()
+	p := &model.InvestmentPrice{
+		HoldingID: holdingID,
+		PriceDate: rateDay(on),
+		Price:     price,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.priceRepo.Upsert(p); err != nil {
+		return nil, fmt.Errorf("failed to record price: %w", err)
+	}
+	return p, nil
+}
+
+// ListPrices returns the holding's recorded prices, newest day first.
+func (s *InvestmentService) ListPrices(holdingID string) ([]*model.InvestmentPrice, error) {
+	prices, err := s.priceRepo.ByHoldingID(holdingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prices: %w", err)
+	}
+	slices.Reverse(prices)
+	return prices, nil
+}
+
+func (s *InvestmentService) DeletePrice(holdingID string, on time.Time) error {
+	return s.priceRepo.Delete(holdingID, rateDay(on))
+}
+
 // HoldingPositions returns the derived position for every holding in the
 // account. Positions are computed by replaying each trade in chronological
 // order, maintaining a running weighted-average cost basis. Each sell reduces
@@ -314,9 +360,15 @@ func (s *InvestmentService) holdingPosition(h model.InvestmentHolding) (model.Ho
 	if err != nil {
 		return model.HoldingPosition{}, fmt.Errorf("failed to load trades: %w", err)
 	}
+	prices, err := s.priceRepo.ByHoldingID(h.ID)
+	if err != nil {
+		return model.HoldingPosition{}, fmt.Errorf("failed to load prices: %w", err)
+	}
 	pos := model.HoldingPosition{Holding: h}
-	qty := decimal.Zero
-	avgCost := decimal.Zero
+	if len(prices) > 0 {
+		pos.LatestPrice = prices[len(prices)-1]
+	}
+	var l lot
 	for _, t := range trades {
 		fees := decimal.Zero
 		if t.Fees != nil {
@@ -325,31 +377,67 @@ func (s *InvestmentService) holdingPosition(h model.InvestmentHolding) (model.Ho
 		pos.TotalFees = pos.TotalFees.Add(fees)
 		switch t.Type {
 		case model.InvestmentTradeTypeBuy:
-			newQty := qty.Add(t.Quantity)
-			if newQty.IsPositive() {
-				// weighted average including fees in cost basis
-				newCost := qty.Mul(avgCost).Add(t.Quantity.Mul(t.PricePerUnit)).Add(fees)
-				avgCost = newCost.Div(newQty)
-			}
-			qty = newQty
 			pos.TotalBuyQty = pos.TotalBuyQty.Add(t.Quantity)
 			price := t.PricePerUnit
 			pos.LastBuyPrice = &price
 		case model.InvestmentTradeTypeSell:
-			realized := t.PricePerUnit.Sub(avgCost).Mul(t.Quantity).Sub(fees)
+			realized := t.PricePerUnit.Sub(l.avgCost).Mul(t.Quantity).Sub(fees)
 			pos.RealizedPL = pos.RealizedPL.Add(realized)
-			qty = qty.Sub(t.Quantity)
 			pos.TotalSellQty = pos.TotalSellQty.Add(t.Quantity)
 			price := t.PricePerUnit
 			pos.LastSellPrice = &price
-			if !qty.IsPositive() {
-				qty = decimal.Zero
-				avgCost = decimal.Zero
-			}
 		}
+		l.apply(t)
 	}
-	pos.Quantity = qty
-	pos.AvgCost = avgCost
-	pos.CostBasis = qty.Mul(avgCost)
+	pos.Quantity = l.qty
+	pos.AvgCost = l.avgCost
+	pos.CostBasis = l.costBasis()
 	return pos, nil
 }
+
+// lot is a holding's running quantity and weighted-average cost while its
+// trades are replayed in order. Fees on buys go into the cost basis.
+type lot struct {
+	qty     decimal.Decimal
+	avgCost decimal.Decimal
+}
+
+func (l *lot) apply(t *model.InvestmentTrade) {
+	fees := decimal.Zero
+	if t.Fees != nil {
+		fees = *t.Fees
+	}
+	switch t.Type {
+	case model.InvestmentTradeTypeBuy:
+		newQty := l.qty.Add(t.Quantity)
+		if newQty.IsPositive() {
+			newCost := l.qty.Mul(l.avgCost).Add(t.Quantity.Mul(t.PricePerUnit)).Add(fees)
+			l.avgCost = newCost.Div(newQty)
+		}
+		l.qty = newQty
+	case model.InvestmentTradeTypeSell:
+		l.qty = l.qty.Sub(t.Quantity)
+		if !l.qty.IsPositive() {
+			l.qty = decimal.Zero
+			l.avgCost = decimal.Zero
+		}
+	}
+}
+
+func (l *lot) costBasis() decimal.Decimal {
+	return l.qty.Mul(l.avgCost)
+}
+
+// tradeCash is what the trade did to the account's uninvested cash: a buy
+// spends the cost plus fees, a sell brings in the proceeds less fees.
+func tradeCash(t *model.InvestmentTrade) decimal.Decimal {
+	fees := decimal.Zero
+	if t.Fees != nil {
+		fees = *t.Fees
+	}
+	amount := t.Quantity.Mul(t.PricePerUnit)
+	if t.Type == model.InvestmentTradeTypeSell {
+		return amount.Sub(fees)
+	}
+	return amount.Add(fees).Neg()
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+// TrialBalance lists the balance of every ledger account in one currency as
+// of the end of AsOf's day. Because every entry balances in each currency,
+// TotalDebit equals TotalCredit.
+type TrialBalance struct {
+	AsOf        time.Time
+	Currency    string
+	Lines       []*model.LedgerBalance
+	TotalDebit  decimal.Decimal
+	TotalCredit decimal.Decimal
+}
+
+// Balanced reports whether debits and credits agree.
+func (tb *TrialBalance) Balanced() bool {
+	return tb.TotalDebit.Equal(tb.TotalCredit)
+}
+
+// BalanceSheet is the space's position in one currency as of the end of
+// AsOf's day. Income and expenses to date close into RetainedEarnings, so
+// TotalAssets equals TotalLiabilities plus TotalEquity. Amounts are signed
+// the way each section normally reads: positive assets, positive
+// liabilities.
+type BalanceSheet struct {
+	AsOf             time.Time
+	Currency         string
+	Assets           []*model.LedgerBalance
+	Liabilities      []*model.LedgerBalance
+	Equity           []*model.LedgerBalance
+	RetainedEarnings decimal.Decimal
+	TotalAssets      decimal.Decimal
+	TotalLiabilities decimal.Decimal
+	TotalEquity      decimal.Decimal
+}
+
+// LedgerService reports on the double-entry ledger kept underneath accounts
+// and transactions. Every entry balances in each currency on its own, with
+// conversions booked through the space's Currency exchange account, so the
+// reports come one per currency rather than adding different currencies
+// together.
+type LedgerService struct {
+	ledgerRepo repository.LedgerRepository
+}
+
+func NewLedgerService(ledgerRepo repository.LedgerRepository) *LedgerService {
+	return &LedgerService{ledgerRepo: ledgerRepo}
+}
+
+// balances returns the space's ledger balances as of the end of asOf's day,
+// grouped by currency in code order.
+func (s *LedgerService) balances(spaceID string, asOf time.Time) ([][]*model.LedgerBalance, error) {
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, asOf.Location())
+	balances, err := s.ledgerRepo.Balances(spaceID, day.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ledger balances: %w", err)
+	}
+	var groups [][]*model.LedgerBalance
+	for i, b := range balances {
+		if i == 0 || b.Currency != balances[i-1].Currency {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], b)
+	}
+	return groups, nil
+}
+
+// TrialBalances returns the space's trial balance in each currency it holds
+// as of the end of asOf's day, in currency code order.
+func (s *LedgerService) TrialBalances(spaceID string, asOf time.Time) ([]*TrialBalance, error) {
+	groups, err := s.balances(spaceID, asOf)
+	if err != nil {
+		return nil, err
+	}
+	trials := make([]*TrialBalance, 0, len(groups))
+	for _, balances := range groups {
+		tb := &TrialBalance{AsOf: asOf, Currency: balances[0].Currency, Lines: balances}
+		for _, b := range balances {
+			tb.TotalDebit = tb.TotalDebit.Add(b.Debit())
+			tb.TotalCredit = tb.TotalCredit.Add(b.Credit())
+		}
+		trials = append(trials, tb)
+	}
+	return trials, nil
+}
+
+// BalanceSheets returns the space's balance sheet in each currency it holds
+// as of the end of asOf's day, in currency code order.
+func (s *LedgerService) BalanceSheets(spaceID string, asOf time.Time) ([]*BalanceSheet, error) {
+	groups, err := s.balances(spaceID, asOf)
+	if err != nil {
+		return nil, err
+	}
+	sheets := make([]*BalanceSheet, 0, len(groups))
+	for _, balances := range groups {
+		bs := &BalanceSheet{AsOf: asOf, Currency: balances[0].Currency}
+		for _, b := range balances {
+			switch b.Kind {
+			case model.LedgerAccountKindAsset:
+				bs.Assets = append(bs.Assets, b)
+				bs.TotalAssets = bs.TotalAssets.Add(b.Natural())
+			case model.LedgerAccountKindLiability:
+				bs.Liabilities = append(bs.Liabilities, b)
+				bs.TotalLiabilities = bs.TotalLiabilities.Add(b.Natural())
+			case model.LedgerAccountKindEquity:
+				bs.Equity = append(bs.Equity, b)
+				bs.TotalEquity = bs.TotalEquity.Add(b.Natural())
+			default:
+				// Income is credit-normal and expenses debit-normal, so their
+				// credit-positive sum is the net earnings.
+				bs.RetainedEarnings = bs.RetainedEarnings.Sub(b.Balance)
+			}
+		}
+		bs.TotalEquity = bs.TotalEquity.Add(bs.RetainedEarnings)
+		sheets = append(sheets, bs)
+	}
+	return sheets, nil
+}
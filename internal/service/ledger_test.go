@@ -0,0 +1,165 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ledgerLine(t *testing.T, lines []*model.LedgerBalance, name string) *model.LedgerBalance {
+	t.Helper()
+	for _, l := range lines {
+		if l.Name == name {
+			return l
+		}
+	}
+	t.Fatalf("no ledger line named %q", name)
+	return nil
+}
+
+func TestLedgerService_PostsBalancedEntries(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		ledger := NewLedgerService(repository.NewLedgerRepository(dbi.DB))
+		savings := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Savings")
+		now := time.Now()
+
+		_, err := f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "Paycheck", Amount: decimal.NewFromInt(1000), OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		rent, err := f.svc.PayBill(PayBillInput{
+			AccountID: f.account.ID, Title: "Rent", Amount: decimal.NewFromInt(400), OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		_, err = f.svc.Transfer(TransferInput{
+			SourceAccountID: f.account.ID, DestAccountID: savings.ID, Title: "Save", Amount: decimal.NewFromInt(100),
+			OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		coffee, err := f.svc.PayBill(PayBillInput{
+			AccountID: f.account.ID, Title: "Coffee", Amount: decimal.NewFromInt(5), OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		_, err = f.svc.DeleteTransaction(DeleteTransactionInput{TransactionID: coffee.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		_, err = f.svc.UpdateBill(UpdateBillInput{
+			TransactionID: rent.ID, Title: "Rent", Amount: decimal.NewFromInt(450),
+			OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		trials, err := ledger.TrialBalances(f.account.SpaceID, now)
+		require.NoError(t, err)
+		require.Len(t, trials, 1)
+		tb := trials[0]
+		assert.True(t, tb.Balanced(), "debits %s, credits %s", tb.TotalDebit, tb.TotalCredit)
+		assert.True(t, decimal.NewFromInt(1000).Equal(tb.TotalDebit), "transfers don't touch income or expenses")
+		assert.True(t, decimal.NewFromInt(450).Equal(ledgerLine(t, tb.Lines, "Acct").Debit()))
+		assert.True(t, decimal.NewFromInt(100).Equal(ledgerLine(t, tb.Lines, "Savings").Debit()))
+		assert.True(t, decimal.NewFromInt(1000).Equal(ledgerLine(t, tb.Lines, "Income").Credit()))
+		assert.True(t, decimal.NewFromInt(450).Equal(ledgerLine(t, tb.Lines, "Expenses").Debit()))
+
+		sheets, err := ledger.BalanceSheets(f.account.SpaceID, now)
+		require.NoError(t, err)
+		require.Len(t, sheets, 1)
+		bs := sheets[0]
+		assert.True(t, decimal.NewFromInt(550).Equal(bs.TotalAssets))
+		assert.True(t, decimal.NewFromInt(550).Equal(bs.RetainedEarnings))
+		assert.True(t, bs.TotalAssets.Equal(bs.TotalLiabilities.Add(bs.TotalEquity)))
+
+		before, err := ledger.TrialBalances(f.account.SpaceID, now.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		require.Len(t, before, 1)
+		assert.True(t, before[0].TotalDebit.IsZero(), "entries after the as-of date are left out")
+
+		drift, err := NewAccountService(f.accounts).BalanceDriftForSpace(f.account.SpaceID)
+		require.NoError(t, err)
+		assert.Empty(t, drift, "stored balances match the postings")
+	})
+}
+
+func TestLedgerService_DeletingTransferAccountRebalances(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		ledger := NewLedgerService(repository.NewLedgerRepository(dbi.DB))
+		savings := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Savings")
+		now := time.Now()
+
+		_, err := f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "Paycheck", Amount: decimal.NewFromInt(300), OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		_, err = f.svc.Transfer(TransferInput{
+			SourceAccountID: f.account.ID, DestAccountID: savings.ID, Title: "Save", Amount: decimal.NewFromInt(100),
+			OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, f.accounts.Delete(savings.ID))
+
+		trials, err := ledger.TrialBalances(f.account.SpaceID, now)
+		require.NoError(t, err)
+		require.Len(t, trials, 1)
+		tb := trials[0]
+		assert.True(t, tb.Balanced(), "debits %s, credits %s", tb.TotalDebit, tb.TotalCredit)
+		assert.True(t, decimal.NewFromInt(200).Equal(ledgerLine(t, tb.Lines, "Acct").Debit()))
+		assert.True(t, decimal.NewFromInt(100).Equal(ledgerLine(t, tb.Lines, "Expenses").Debit()),
+			"the surviving half reads as a bill")
+	})
+}
+
+func TestLedgerService_BalancesEachCurrency(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		ledger := NewLedgerService(repository.NewLedgerRepository(dbi.DB))
+		usd := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Dollars")
+		_, err := dbi.DB.Exec(`UPDATE accounts SET currency = 'USD' WHERE id = $1`, usd.ID)
+		require.NoError(t, err)
+		now := time.Now()
+
+		_, err = f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "Paycheck", Amount: decimal.NewFromInt(500), OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		_, err = f.svc.Transfer(TransferInput{
+			SourceAccountID: f.account.ID, DestAccountID: usd.ID, Title: "Convert", Amount: decimal.NewFromInt(100),
+			ConversionRate: decimal.RequireFromString("0.75"), OccurredAt: now, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		trials, err := ledger.TrialBalances(f.account.SpaceID, now)
+		require.NoError(t, err)
+		require.Len(t, trials, 2)
+		cad, dollars := trials[0], trials[1]
+		assert.Equal(t, "CAD", cad.Currency)
+		assert.Equal(t, "USD", dollars.Currency)
+		for _, tb := range trials {
+			assert.True(t, tb.Balanced(), "%s debits %s, credits %s", tb.Currency, tb.TotalDebit, tb.TotalCredit)
+			for _, l := range tb.Lines {
+				assert.NotEqual(t, "Expenses", l.Name, "a conversion is not an expense")
+			}
+		}
+		assert.True(t, decimal.NewFromInt(500).Equal(ledgerLine(t, cad.Lines, "Income").Credit()))
+		assert.True(t, decimal.NewFromInt(100).Equal(ledgerLine(t, cad.Lines, "Currency exchange").Debit()))
+		assert.True(t, decimal.NewFromInt(75).Equal(ledgerLine(t, dollars.Lines, "Dollars").Debit()))
+		assert.True(t, decimal.NewFromInt(75).Equal(ledgerLine(t, dollars.Lines, "Currency exchange").Credit()))
+
+		require.NoError(t, NewAccountService(f.accounts).ChangeCurrency(f.account.ID, "USD", decimal.RequireFromString("0.75"), f.user.ID))
+
+		trials, err = ledger.TrialBalances(f.account.SpaceID, now.Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, trials, 2)
+		for _, tb := range trials {
+			assert.True(t, tb.Balanced(), "%s debits %s, credits %s", tb.Currency, tb.TotalDebit, tb.TotalCredit)
+		}
+		assert.True(t, decimal.NewFromInt(300).Equal(ledgerLine(t, trials[1].Lines, "Acct").Debit()),
+			"the converted balance moves to the new currency")
+	})
+}
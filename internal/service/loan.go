@@ -0,0 +1,535 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrNotALoan is returned when a loan operation is given an account that has
+// no loan terms.
+var ErrNotALoan = errors.New("account is not a loan")
+
+// ErrPrepaymentExceedsBalance is returned when a prepayment would pay more
+// than is owed on the loan.
+var ErrPrepaymentExceedsBalance = errors.New("prepayment is more than what is owed")
+
+// ErrLoanPaymentChanged is returned when editing the amount or date of a
+// transfer into a loan. The payment charged interest for the time since the
+// previous one, so it has to be undone and recorded again instead.
+var ErrLoanPaymentChanged = errors.New("a loan payment's amount and date can't be edited")
+
+// maxAmortizationRows caps a schedule so a payment that barely covers the
+// interest can't produce an endless one: 50 years of weekly payments.
+const maxAmortizationRows = 50 * 52
+
+// LoanService keeps the terms of loan accounts, works out their amortization
+// and charges interest when a payment is transferred in.
+type LoanService struct {
+	loanRepo       repository.LoanRepository
+	accountService *AccountService
+	txService      *TransactionService
+	recurringSvc   *RecurringEventService
+	auditSvc       *SpaceAuditLogService
+}
+
+func NewLoanService(
+	loanRepo repository.LoanRepository,
+	accountService *AccountService,
+	txService *TransactionService,
+) *LoanService {
+	return &LoanService{
+		loanRepo:       loanRepo,
+		accountService: accountService,
+		txService:      txService,
+	}
+}
+
+// SetRecurringEventService wires the recurring events used to schedule loan
+// payments. Wired after construction because the recurring event service
+// depends on the transaction service, which depends on this one.
+func (s *LoanService) SetRecurringEventService(recurring *RecurringEventService) {
+	s.recurringSvc = recurring
+}
+
+// SetAuditLogger wires the audit log service after construction.
+func (s *LoanService) SetAuditLogger(audit *SpaceAuditLogService) {
+	s.auditSvc = audit
+}
+
+type SetUpLoanInput struct {
+	AccountID string
+	Principal decimal.Decimal
+	// AnnualRate is the nominal yearly rate as a percentage.
+	AnnualRate         decimal.Decimal
+	Compounding        model.LoanCompounding
+	PaymentFrequency   model.LoanPaymentFrequency
+	AmortizationMonths int
+	TermMonths         *int
+	StartDate          time.Time
+	// RecordPrincipal draws the principal on the account when the loan is
+	// first set up: as a transfer to DisburseToAccountID when set, otherwise
+	// as a purchase on the loan itself. Ignored when updating terms.
+	RecordPrincipal     bool
+	DisburseToAccountID string
+	ActorID             string
+}
+
+// SetUpLoan turns an account into a loan with the given terms, or updates the
+// terms of an account that already is one. A loan can't carry savings goals
+// or be an investment account.
+func (s *LoanService) SetUpLoan(input SetUpLoanInput) (*model.LoanTerms, error) {
+	if input.AccountID == "" {
+		return nil, fmt.Errorf("account id is required")
+	}
+	if !input.Principal.IsPositive() {
+		return nil, fmt.Errorf("principal must be greater than zero")
+	}
+	if input.AnnualRate.IsNegative() {
+		return nil, fmt.Errorf("interest rate cannot be negative")
+	}
+	if !model.IsValidLoanCompounding(string(input.Compounding)) {
+		return nil, fmt.Errorf("invalid compounding: %s", input.Compounding)
+	}
+	if !model.IsValidLoanPaymentFrequency(string(input.PaymentFrequency)) {
+		return nil, fmt.Errorf("invalid payment frequency: %s", input.PaymentFrequency)
+	}
+	if input.AmortizationMonths < 1 {
+		return nil, fmt.Errorf("amortization must be at least one month")
+	}
+	if input.TermMonths != nil && *input.TermMonths < 1 {
+		return nil, fmt.Errorf("term must be at least one month")
+	}
+	if input.StartDate.IsZero() {
+		return nil, fmt.Errorf("start date is required")
+	}
+
+	account, err := s.accountService.GetAccount(input.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	if account.IsInvestment {
+		return nil, fmt.Errorf("an investment account can't be a loan")
+	}
+	hasAllocations, err := s.accountService.hasAllocations(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	if hasAllocations {
+		return nil, ErrAccountHasAllocations
+	}
+
+	existing, err := s.loanRepo.TermsByAccountID(account.ID)
+	if err != nil && !errors.Is(err, repository.ErrLoanTermsNotFound) {
+		return nil, fmt.Errorf("failed to load loan terms: %w", err)
+	}
+
+	now := time.Now()
+	start := input.StartDate
+	terms := &model.LoanTerms{
+		AccountID:          account.ID,
+		Principal:          input.Principal,
+		AnnualRate:         input.AnnualRate,
+		Compounding:        input.Compounding,
+		PaymentFrequency:   input.PaymentFrequency,
+		AmortizationMonths: input.AmortizationMonths,
+		TermMonths:         input.TermMonths,
+		StartDate:          time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if existing != nil {
+		terms.CreatedAt = existing.CreatedAt
+	}
+	if err := s.loanRepo.SetTerms(terms); err != nil {
+		return nil, fmt.Errorf("failed to save loan terms: %w", err)
+	}
+
+	if existing == nil && input.RecordPrincipal {
+		if err := s.drawPrincipal(account, terms, input.DisburseToAccountID, input.ActorID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: account.SpaceID,
+		ActorID: input.ActorID,
+		Action:  model.SpaceAuditActionLoanTermsSet,
+		Metadata: map[string]any{
+			"account_id":        account.ID,
+			"account_name":      account.Name,
+			"old_kind":          string(account.Kind),
+			"principal":         s.accountService.currencyOf(account).Fixed(terms.Principal),
+			"annual_rate":       terms.AnnualRate.String(),
+			"compounding":       string(terms.Compounding),
+			"payment_frequency": string(terms.PaymentFrequency),
+		},
+	})
+	return terms, nil
+}
+
+// drawPrincipal records the money borrowed, dated when the loan started.
+func (s *LoanService) drawPrincipal(account *model.Account, terms *model.LoanTerms, disburseTo, actorID string) error {
+	title := account.Name + " principal"
+	if disburseTo != "" {
+		if _, err := s.txService.Transfer(TransferInput{
+			SourceAccountID: account.ID,
+			DestAccountID:   disburseTo,
+			Title:           title,
+			Amount:          terms.Principal,
+			OccurredAt:      terms.StartDate,
+			ActorID:         actorID,
+		}); err != nil {
+			return fmt.Errorf("failed to record principal: %w", err)
+		}
+		return nil
+	}
+	if _, err := s.txService.PayBill(PayBillInput{
+		AccountID:  account.ID,
+		Title:      title,
+		Amount:     terms.Principal,
+		OccurredAt: terms.StartDate,
+		ActorID:    actorID,
+	}); err != nil {
+		return fmt.Errorf("failed to record principal: %w", err)
+	}
+	return nil
+}
+
+// Terms returns the account's loan terms, or ErrNotALoan.
+func (s *LoanService) Terms(accountID string) (*model.LoanTerms, error) {
+	terms, err := s.loanRepo.TermsByAccountID(accountID)
+	if errors.Is(err, repository.ErrLoanTermsNotFound) {
+		return nil, ErrNotALoan
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load loan terms: %w", err)
+	}
+	return terms, nil
+}
+
+// Summary reports what is left on the loan, the interest paid so far and
+// the schedules from the start of the loan and from now.
+func (s *LoanService) Summary(account *model.Account, now time.Time) (*model.LoanSummary, error) {
+	terms, err := s.Terms(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	splits, err := s.loanRepo.Splits(account.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load loan payments: %w", err)
+	}
+	cur := s.accountService.currencyOf(account)
+	summary := &model.LoanSummary{
+		Terms:     terms,
+		Remaining: account.Owed(),
+		Original:  Amortize(terms, cur, terms.Principal, terms.FirstPaymentDate()),
+		Projected: Amortize(terms, cur, account.Owed(), nextPaymentDue(terms, now)),
+		Payments:  splits,
+	}
+	for _, split := range splits {
+		summary.InterestPaid = summary.InterestPaid.Add(split.Interest)
+		summary.PrincipalPaid = summary.PrincipalPaid.Add(split.Principal)
+	}
+	if end := terms.TermEnd(); end != nil {
+		balance := summary.Original.BalanceAfter(terms.Principal, *end)
+		summary.TermBalance = &balance
+	}
+	return summary, nil
+}
+
+// Prepayment compares paying off what is owed at the regular payment with
+// first paying amount extra today. The regular payment stays the same, so a
+// prepayment shortens the loan rather than lowering the payment.
+func (s *LoanService) Prepayment(account *model.Account, amount decimal.Decimal, now time.Time) (*model.PrepaymentEffect, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be greater than zero")
+	}
+	terms, err := s.Terms(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := account.Owed()
+	if amount.GreaterThan(remaining) {
+		return nil, ErrPrepaymentExceedsBalance
+	}
+	next := nextPaymentDue(terms, now)
+	cur := s.accountService.currencyOf(account)
+	effect := &model.PrepaymentEffect{
+		Amount:          amount,
+		RemainingBefore: remaining,
+		Without:         Amortize(terms, cur, remaining, next),
+		With:            Amortize(terms, cur, remaining.Sub(amount), next),
+	}
+	effect.InterestSaved = effect.Without.TotalInterest.Sub(effect.With.TotalInterest)
+	effect.PaymentsSaved = len(effect.Without.Rows) - len(effect.With.Rows)
+	effect.PayoffWithout = effect.Without.PayoffDate()
+	effect.PayoffWith = effect.With.PayoffDate()
+	if effect.PayoffWith.IsZero() {
+		effect.PayoffWith = now
+	}
+	return effect, nil
+}
+
+type ScheduleLoanPaymentsInput struct {
+	AccountID     string
+	FromAccountID string
+	Timezone      string
+	// FireHour and FireMinute are when on the due date the payment is made.
+	FireHour   int
+	FireMinute int
+	Now        time.Time
+}
+
+// SchedulePayments creates recurring transfers of the regular payment from
+// FromAccountID into the loan, starting at the next payment due. Semi-monthly
+// loans get two monthly transfers, half a month apart, created together.
+func (s *LoanService) SchedulePayments(input ScheduleLoanPaymentsInput) ([]*model.RecurringEvent, error) {
+	if s.recurringSvc == nil {
+		return nil, fmt.Errorf("recurring events are not available")
+	}
+	account, err := s.accountService.GetAccount(input.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	terms, err := s.Terms(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.accountService.GetAccount(input.FromAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source account: %w", err)
+	}
+	if from.SpaceID != account.SpaceID {
+		return nil, fmt.Errorf("source account is not in this space")
+	}
+	if from.Currency != account.Currency {
+		return nil, fmt.Errorf("scheduled payments must come from an account in %s", account.Currency)
+	}
+
+	base := CreateRecurringEventInput{
+		SpaceID:         account.SpaceID,
+		Kind:            model.RecurringEventKindTransfer,
+		SourceAccountID: from.ID,
+		DestAccountID:   account.ID,
+		Title:           account.Name + " payment",
+		Amount:          RegularPayment(terms, s.accountService.currencyOf(account)),
+		IntervalCount:   1,
+		FireHour:        input.FireHour,
+		FireMinute:      input.FireMinute,
+		Timezone:        input.Timezone,
+	}
+	first := nextPaymentDue(terms, input.Now)
+	starts := []time.Time{first}
+	switch terms.PaymentFrequency {
+	case model.LoanPaymentMonthly:
+		base.Frequency = model.RecurringFrequencyMonthly
+	case model.LoanPaymentSemiMonthly:
+		base.Frequency = model.RecurringFrequencyMonthly
+		starts = append(starts, terms.NextPaymentDate(first))
+	case model.LoanPaymentBiweekly, model.LoanPaymentAcceleratedBiweekly:
+		base.Frequency = model.RecurringFrequencyWeekly
+		base.IntervalCount = 2
+	case model.LoanPaymentWeekly, model.LoanPaymentAcceleratedWeekly:
+		base.Frequency = model.RecurringFrequencyWeekly
+	}
+
+	// Monthly events stay on the loan's own due days: the start dates may
+	// sit on a short month's last day instead.
+	days := terms.PaymentFrequency.DueDays(terms.StartDate.Day())
+	inputs := make([]CreateRecurringEventInput, len(starts))
+	for i, start := range starts {
+		in := base
+		in.StartDate = start
+		if in.Frequency == model.RecurringFrequencyMonthly {
+			day := days[0]
+			if len(days) > 1 && start.Day() > days[0] {
+				day = days[1]
+			}
+			in.DayOfMonth = &day
+		} else {
+			dow := int(start.Weekday())
+			in.DayOfWeek = &dow
+		}
+		inputs[i] = in
+	}
+	events, err := s.recurringSvc.CreateMany(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule payments: %w", err)
+	}
+	return events, nil
+}
+
+// paymentInterest is the interest charge a payment into the loan at at
+// brings with it, or nil when nothing accrued. Interest accrues on what was
+// owed just before the payment, from the previous payment (or the start of
+// the loan) at the loan's periodic rate, with part periods pro-rated by day.
+func (s *LoanService) paymentInterest(account *model.Account, at, now time.Time) (*model.Transaction, error) {
+	terms, err := s.Terms(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.txService.BalanceAsOf(account.ID, at)
+	if err != nil {
+		return nil, err
+	}
+	owed := balance.Neg()
+	if !owed.IsPositive() {
+		return nil, nil
+	}
+	since := terms.StartDate
+	last, err := s.loanRepo.LastPaymentAt(account.ID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load last loan payment: %w", err)
+	}
+	if last != nil && last.After(since) {
+		since = *last
+	}
+	periods := paymentPeriodsBetween(terms.PaymentFrequency, since, at)
+	if !periods.IsPositive() {
+		return nil, nil
+	}
+	growth := decimal.NewFromInt(1).Add(periodicRate(terms)).Pow(periods)
+	interest := s.accountService.currencyOf(account).Round(owed.Mul(growth.Sub(decimal.NewFromInt(1))))
+	if !interest.IsPositive() {
+		return nil, nil
+	}
+	return &model.Transaction{
+		ID:         uuid.NewString(),
+		Value:      interest,
+		Type:       model.TransactionTypeWithdrawal,
+		AccountID:  account.ID,
+		Title:      account.Name + " interest",
+		Status:     model.TransactionStatusPending,
+		OccurredAt: at,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// ----- Amortization math -----
+
+// periodicRate is the interest rate of one payment period: the nominal
+// annual rate j compounded m times a year, converted to p payments a year as
+// (1 + j/m)^(m/p) - 1. For a Canadian mortgage at 5% paid monthly that is
+// (1.025)^(1/6) - 1, a little under 5%/12.
+func periodicRate(terms *model.LoanTerms) decimal.Decimal {
+	return periodicRateFor(terms.AnnualRate, terms.Compounding, terms.PaymentFrequency.PerYear())
+}
+
+func periodicRateFor(annualRate decimal.Decimal, compounding model.LoanCompounding, paymentsPerYear int) decimal.Decimal {
+	if annualRate.IsZero() {
+		return decimal.Zero
+	}
+	m := decimal.NewFromInt(int64(compounding.PerYear()))
+	p := decimal.NewFromInt(int64(paymentsPerYear))
+	perCompounding := annualRate.Div(decimal.NewFromInt(100)).Div(m)
+	// Twelve places is far below a cent on any balance and keeps the
+	// fractional powers taken from it cheap.
+	return decimal.NewFromInt(1).Add(perCompounding).Pow(m.Div(p)).Sub(decimal.NewFromInt(1)).Round(12)
+}
+
+// RegularPayment is the payment that pays the principal off over the
+// amortization, rounded up to the loan currency's minor units. Accelerated
+// frequencies pay a fraction of the monthly payment instead, which pays the
+// loan off sooner.
+func RegularPayment(terms *model.LoanTerms, cur currency.Currency) decimal.Decimal {
+	perYear := terms.PaymentFrequency.PerYear()
+	divisor := terms.PaymentFrequency.MonthlyDivisor()
+	if divisor > 0 {
+		perYear = 12
+	}
+	n := decimal.NewFromInt(int64(terms.AmortizationMonths * perYear)).Div(decimal.NewFromInt(12)).Round(0)
+	if n.LessThan(decimal.NewFromInt(1)) {
+		n = decimal.NewFromInt(1)
+	}
+	rate := periodicRateFor(terms.AnnualRate, terms.Compounding, perYear)
+	var payment decimal.Decimal
+	if rate.IsZero() {
+		payment = terms.Principal.Div(n)
+	} else {
+		discount := decimal.NewFromInt(1).Sub(decimal.NewFromInt(1).Add(rate).Pow(n.Neg()))
+		payment = terms.Principal.Mul(rate).Div(discount)
+	}
+	if divisor > 0 {
+		payment = payment.Div(decimal.NewFromInt(int64(divisor)))
+	}
+	return payment.RoundUp(cur.MinorUnits)
+}
+
+// Amortize lays out the regular payments that pay opening off, the first one
+// due on firstDue, in amounts of the loan's currency. Each payment covers the
+// period's interest first; the last one is whatever is left.
+func Amortize(terms *model.LoanTerms, cur currency.Currency, opening decimal.Decimal, firstDue time.Time) *model.AmortizationSchedule {
+	schedule := &model.AmortizationSchedule{Payment: RegularPayment(terms, cur)}
+	rate := periodicRate(terms)
+	balance := opening
+	due := firstDue
+	for i := 1; balance.IsPositive() && i <= maxAmortizationRows; i++ {
+		interest := cur.Round(balance.Mul(rate))
+		payment := schedule.Payment
+		if !payment.GreaterThan(interest) {
+			// The payment no longer covers the interest; the loan would
+			// never be paid off.
+			break
+		}
+		principal := payment.Sub(interest)
+		if principal.GreaterThan(balance) {
+			principal = balance
+			payment = balance.Add(interest)
+		}
+		balance = balance.Sub(principal)
+		schedule.Rows = append(schedule.Rows, &model.AmortizationRow{
+			Number:    i,
+			DueDate:   due,
+			Payment:   payment,
+			Interest:  interest,
+			Principal: principal,
+			Balance:   balance,
+		})
+		schedule.TotalInterest = schedule.TotalInterest.Add(interest)
+		due = terms.NextPaymentDate(due)
+	}
+	return schedule
+}
+
+// nextPaymentDue is the first scheduled due date after the day of now.
+func nextPaymentDue(terms *model.LoanTerms, now time.Time) time.Time {
+	today := dateOf(now)
+	due := terms.FirstPaymentDate()
+	for !due.After(today) {
+		due = terms.NextPaymentDate(due)
+	}
+	return due
+}
+
+// paymentPeriodsBetween counts the payment periods from one day to another:
+// whole periods stepped on the calendar, plus the part of the last one that
+// has elapsed, by day.
+func paymentPeriodsBetween(freq model.LoanPaymentFrequency, from, to time.Time) decimal.Decimal {
+	from, to = dateOf(from), dateOf(to)
+	day := from.Day()
+	whole := int64(0)
+	for {
+		next := freq.Next(from, day)
+		if next.After(to) {
+			elapsed := to.Sub(from).Hours() / 24
+			length := next.Sub(from).Hours() / 24
+			part := decimal.NewFromFloat(elapsed).Div(decimal.NewFromFloat(length))
+			return decimal.NewFromInt(whole).Add(part)
+		}
+		from = next
+		whole++
+	}
+}
+
+// dateOf is the calendar day of t, as midnight UTC.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
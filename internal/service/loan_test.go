@@ -0,0 +1,346 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loanTerms(principal, rate string, compounding model.LoanCompounding, freq model.LoanPaymentFrequency, months int) *model.LoanTerms {
+	return &model.LoanTerms{
+		Principal:          decimal.RequireFromString(principal),
+		AnnualRate:         decimal.RequireFromString(rate),
+		Compounding:        compounding,
+		PaymentFrequency:   freq,
+		AmortizationMonths: months,
+		StartDate:          time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+var cad = currency.Get("CAD")
+
+func TestRegularPayment(t *testing.T) {
+	t.Run("monthly compounding", func(t *testing.T) {
+		terms := loanTerms("100000", "6", model.LoanCompoundingMonthly, model.LoanPaymentMonthly, 360)
+		assert.Equal(t, "599.56", RegularPayment(terms, cad).StringFixed(2))
+	})
+
+	t.Run("canadian mortgage compounds semi-annually", func(t *testing.T) {
+		terms := loanTerms("500000", "5", model.LoanCompoundingSemiAnnual, model.LoanPaymentMonthly, 300)
+		assert.Equal(t, "0.004123915465", periodicRate(terms).String())
+		assert.Equal(t, "2908.03", RegularPayment(terms, cad).StringFixed(2))
+	})
+
+	t.Run("accelerated bi-weekly pays half the monthly payment", func(t *testing.T) {
+		monthly := loanTerms("500000", "5", model.LoanCompoundingSemiAnnual, model.LoanPaymentMonthly, 300)
+		accelerated := loanTerms("500000", "5", model.LoanCompoundingSemiAnnual, model.LoanPaymentAcceleratedBiweekly, 300)
+		assert.Equal(t, "1454.02", RegularPayment(accelerated, cad).StringFixed(2))
+		assert.True(t, RegularPayment(accelerated, cad).Mul(decimal.NewFromInt(2)).GreaterThanOrEqual(RegularPayment(monthly, cad)))
+	})
+
+	t.Run("zero rate", func(t *testing.T) {
+		terms := loanTerms("1200", "0", model.LoanCompoundingMonthly, model.LoanPaymentMonthly, 12)
+		assert.Equal(t, "100.00", RegularPayment(terms, cad).StringFixed(2))
+	})
+
+	t.Run("rounds up to the currency's minor units", func(t *testing.T) {
+		terms := loanTerms("100000", "6", model.LoanCompoundingMonthly, model.LoanPaymentMonthly, 360)
+		assert.Equal(t, "600", RegularPayment(terms, currency.Get("JPY")).String())
+	})
+}
+
+func TestAmortize(t *testing.T) {
+	t.Run("pays the principal off over the amortization", func(t *testing.T) {
+		terms := loanTerms("100000", "6", model.LoanCompoundingMonthly, model.LoanPaymentMonthly, 360)
+		schedule := Amortize(terms, cad, terms.Principal, terms.FirstPaymentDate())
+
+		require.Len(t, schedule.Rows, 360)
+		assert.True(t, schedule.Rows[359].Balance.IsZero())
+		assert.Equal(t, "500.00", schedule.Rows[0].Interest.StringFixed(2))
+		assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), schedule.Rows[0].DueDate)
+
+		principal := decimal.Zero
+		for _, row := range schedule.Rows {
+			principal = principal.Add(row.Principal)
+		}
+		assert.True(t, principal.Equal(terms.Principal))
+	})
+
+	t.Run("accelerated payments pay the loan off sooner", func(t *testing.T) {
+		regular := loanTerms("500000", "5", model.LoanCompoundingSemiAnnual, model.LoanPaymentBiweekly, 300)
+		accelerated := loanTerms("500000", "5", model.LoanCompoundingSemiAnnual, model.LoanPaymentAcceleratedBiweekly, 300)
+
+		r := Amortize(regular, cad, regular.Principal, regular.FirstPaymentDate())
+		a := Amortize(accelerated, cad, accelerated.Principal, accelerated.FirstPaymentDate())
+		assert.Less(t, len(a.Rows), len(r.Rows))
+		assert.True(t, a.TotalInterest.LessThan(r.TotalInterest))
+	})
+
+	t.Run("stops when the payment can't cover the interest", func(t *testing.T) {
+		terms := loanTerms("1000", "12", model.LoanCompoundingMonthly, model.LoanPaymentMonthly, 12)
+		schedule := Amortize(terms, cad, decimal.NewFromInt(1000000), terms.FirstPaymentDate())
+		assert.Empty(t, schedule.Rows)
+	})
+
+	t.Run("rounds interest to the currency's minor units", func(t *testing.T) {
+		terms := loanTerms("100000", "5", model.LoanCompoundingMonthly, model.LoanPaymentMonthly, 360)
+		schedule := Amortize(terms, currency.Get("JPY"), terms.Principal, terms.FirstPaymentDate())
+		require.NotEmpty(t, schedule.Rows)
+		for _, row := range schedule.Rows[:12] {
+			assert.True(t, row.Interest.Equal(row.Interest.Round(0)), "interest %s has decimals", row.Interest)
+		}
+	})
+}
+
+func TestPaymentPeriodsBetween(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "1", paymentPeriodsBetween(model.LoanPaymentMonthly, from, time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)).String())
+	assert.Equal(t, "2.5", paymentPeriodsBetween(model.LoanPaymentBiweekly, from, from.AddDate(0, 0, 35)).String())
+	assert.True(t, paymentPeriodsBetween(model.LoanPaymentMonthly, from, from).IsZero())
+}
+
+func TestLoanPaymentDueDates(t *testing.T) {
+	tests := []struct {
+		name  string
+		freq  model.LoanPaymentFrequency
+		start time.Time
+		want  []string
+	}{
+		{"semi-monthly from the 1st", model.LoanPaymentSemiMonthly, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2026-01-16", "2026-02-01", "2026-02-16", "2026-03-01"}},
+		{"semi-monthly from the 20th", model.LoanPaymentSemiMonthly, time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC),
+			[]string{"2026-02-05", "2026-02-20", "2026-03-05", "2026-03-20"}},
+		{"semi-monthly from the 31st", model.LoanPaymentSemiMonthly, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+			[]string{"2026-02-16", "2026-02-28", "2026-03-16", "2026-03-31", "2026-04-16", "2026-04-30", "2026-05-16", "2026-05-31"}},
+		{"monthly from the 31st", model.LoanPaymentMonthly, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+			[]string{"2026-02-28", "2026-03-31", "2026-04-30", "2026-05-31"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms := &model.LoanTerms{PaymentFrequency: tt.freq, StartDate: tt.start}
+			var got []string
+			for due := terms.FirstPaymentDate(); len(got) < len(tt.want); due = terms.NextPaymentDate(due) {
+				got = append(got, due.Format("2006-01-02"))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type loanFixture struct {
+	svc       *LoanService
+	txnSvc    *TransactionService
+	recurring *RecurringEventService
+	accounts  *AccountService
+	user      *model.User
+	chequing  *model.Account
+	loan      *model.Account
+}
+
+func newLoanFixture(t *testing.T, dbi testutil.DBInfo) *loanFixture {
+	t.Helper()
+
+	accountSvc := NewAccountService(repository.NewAccountRepository(dbi.DB))
+	accountSvc.SetAllocationRepository(repository.NewAllocationRepository(dbi.DB))
+	txnSvc := NewTransactionService(repository.NewTransactionRepository(dbi.DB), repository.NewCategoryRepository(dbi.DB), repository.NewTagRepository(dbi.DB), accountSvc)
+	recurring := NewRecurringEventService(repository.NewRecurringEventRepository(dbi.DB), txnSvc, accountSvc)
+	svc := NewLoanService(repository.NewLoanRepository(dbi.DB), accountSvc, txnSvc)
+	svc.SetRecurringEventService(recurring)
+	txnSvc.SetLoanService(svc)
+
+	user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
+	space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+
+	return &loanFixture{
+		svc:       svc,
+		txnSvc:    txnSvc,
+		recurring: recurring,
+		accounts:  accountSvc,
+		user:      user,
+		chequing:  testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing"),
+		loan:      testutil.CreateTestAccount(t, dbi.DB, space.ID, "Car loan"),
+	}
+}
+
+// setUp turns the fixture's loan account into a one-year loan of 12,000 at
+// 12% compounded monthly, drawn as a purchase on January 1st.
+func (f *loanFixture) setUp(t *testing.T) {
+	t.Helper()
+	_, err := f.svc.SetUpLoan(SetUpLoanInput{
+		AccountID:          f.loan.ID,
+		Principal:          decimal.NewFromInt(12000),
+		AnnualRate:         decimal.NewFromInt(12),
+		Compounding:        model.LoanCompoundingMonthly,
+		PaymentFrequency:   model.LoanPaymentMonthly,
+		AmortizationMonths: 12,
+		StartDate:          time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		RecordPrincipal:    true,
+		ActorID:            f.user.ID,
+	})
+	require.NoError(t, err)
+}
+
+func (f *loanFixture) balance(t *testing.T, accountID string) decimal.Decimal {
+	t.Helper()
+	account, err := f.accounts.GetAccount(accountID)
+	require.NoError(t, err)
+	return account.Balance
+}
+
+func TestLoanService_SetUpLoan(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newLoanFixture(t, dbi)
+		f.setUp(t)
+
+		account, err := f.accounts.GetAccount(f.loan.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.AccountKindLoan, account.Kind)
+		assert.Equal(t, "-12000.00", account.Balance.StringFixed(2))
+
+		err = f.accounts.SetKind(f.loan.ID, model.AccountKindCash, CreditTerms{}, f.user.ID)
+		assert.ErrorIs(t, err, ErrLoanAccountKind)
+	})
+}
+
+func TestLoanService_TransferSplitsInterest(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newLoanFixture(t, dbi)
+		f.setUp(t)
+
+		res, err := f.txnSvc.Transfer(TransferInput{
+			SourceAccountID: f.chequing.ID,
+			DestAccountID:   f.loan.ID,
+			Title:           "Car payment",
+			Amount:          decimal.NewFromInt(1000),
+			OccurredAt:      time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			ActorID:         f.user.ID,
+		})
+		require.NoError(t, err)
+
+		// A month at 1% on 12,000 owed.
+		assert.Equal(t, "-11120.00", f.balance(t, f.loan.ID).StringFixed(2))
+		account, err := f.accounts.GetAccount(f.loan.ID)
+		require.NoError(t, err)
+		summary, err := f.svc.Summary(account, time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, summary.Payments, 1)
+		assert.Equal(t, "120.00", summary.InterestPaid.StringFixed(2))
+		assert.Equal(t, "880.00", summary.PrincipalPaid.StringFixed(2))
+		assert.Equal(t, "11120.00", summary.Remaining.StringFixed(2))
+
+		// The interest was charged for the month before the payment, so the
+		// payment can't move or change size under it.
+		edit := UpdateTransferInput{
+			TransactionID: res.Deposit.ID,
+			Title:         "Car payment",
+			Amount:        decimal.NewFromInt(1500),
+			OccurredAt:    time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			ActorID:       f.user.ID,
+		}
+		_, err = f.txnSvc.UpdateTransfer(edit)
+		assert.ErrorIs(t, err, ErrLoanPaymentChanged)
+		edit.Amount = decimal.NewFromInt(1000)
+		edit.OccurredAt = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		_, err = f.txnSvc.UpdateTransfer(edit)
+		assert.ErrorIs(t, err, ErrLoanPaymentChanged)
+		edit.OccurredAt = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+		edit.Title = "February car payment"
+		_, err = f.txnSvc.UpdateTransfer(edit)
+		require.NoError(t, err)
+		assert.Equal(t, "-11120.00", f.balance(t, f.loan.ID).StringFixed(2))
+
+		_, err = f.txnSvc.UndoTransfer(UndoTransferInput{TransactionID: res.Deposit.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		assert.Equal(t, "-12000.00", f.balance(t, f.loan.ID).StringFixed(2))
+		assert.True(t, f.balance(t, f.chequing.ID).IsZero())
+	})
+}
+
+func TestLoanService_Prepayment(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newLoanFixture(t, dbi)
+		f.setUp(t)
+		account, err := f.accounts.GetAccount(f.loan.ID)
+		require.NoError(t, err)
+		now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+		effect, err := f.svc.Prepayment(account, decimal.NewFromInt(3000), now)
+		require.NoError(t, err)
+		assert.Len(t, effect.Without.Rows, 12)
+		assert.Greater(t, effect.PaymentsSaved, 0)
+		assert.True(t, effect.InterestSaved.IsPositive())
+		assert.True(t, effect.PayoffWith.Before(effect.PayoffWithout))
+
+		_, err = f.svc.Prepayment(account, decimal.NewFromInt(20000), now)
+		assert.ErrorIs(t, err, ErrPrepaymentExceedsBalance)
+	})
+}
+
+func TestLoanService_SchedulePayments(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newLoanFixture(t, dbi)
+		f.setUp(t)
+
+		events, err := f.svc.SchedulePayments(ScheduleLoanPaymentsInput{
+			AccountID:     f.loan.ID,
+			FromAccountID: f.chequing.ID,
+			Timezone:      "UTC",
+			FireHour:      9,
+			Now:           time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		ev := events[0]
+		assert.Equal(t, model.RecurringEventKindTransfer, ev.Kind)
+		require.NotNil(t, ev.DestAccountID)
+		assert.Equal(t, f.loan.ID, *ev.DestAccountID)
+		assert.Equal(t, f.chequing.ID, ev.SourceAccountID)
+
+		// Firing the event pays the loan, interest and all.
+		require.NoError(t, f.recurring.ProcessDue(time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)))
+		assert.Equal(t, ev.Amount.Neg().StringFixed(2), f.balance(t, f.chequing.ID).StringFixed(2))
+		owed := decimal.NewFromInt(12120).Sub(ev.Amount)
+		assert.Equal(t, owed.Neg().StringFixed(2), f.balance(t, f.loan.ID).StringFixed(2))
+	})
+}
+
+func TestLoanService_SchedulePayments_SemiMonthlyKeepsDueDays(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newLoanFixture(t, dbi)
+		_, err := f.svc.SetUpLoan(SetUpLoanInput{
+			AccountID:          f.loan.ID,
+			Principal:          decimal.NewFromInt(12000),
+			AnnualRate:         decimal.NewFromInt(12),
+			Compounding:        model.LoanCompoundingMonthly,
+			PaymentFrequency:   model.LoanPaymentSemiMonthly,
+			AmortizationMonths: 12,
+			StartDate:          time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC),
+			ActorID:            f.user.ID,
+		})
+		require.NoError(t, err)
+
+		// The next payment is due on February 28th, standing in for the 30th.
+		events, err := f.svc.SchedulePayments(ScheduleLoanPaymentsInput{
+			AccountID:     f.loan.ID,
+			FromAccountID: f.chequing.ID,
+			Timezone:      "UTC",
+			FireHour:      9,
+			Now:           time.Date(2026, 2, 20, 0, 0, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.NotNil(t, events[0].DayOfMonth)
+		require.NotNil(t, events[1].DayOfMonth)
+		assert.Equal(t, 30, *events[0].DayOfMonth)
+		assert.Equal(t, 15, *events[1].DayOfMonth)
+		assert.Equal(t, time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC), events[0].NextRunAt)
+		assert.Equal(t, time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC), events[1].NextRunAt)
+	})
+}
@@ -0,0 +1,456 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+// NetWorthService keeps a daily snapshot of every account and adds them up
+// into a user's net worth across all of the spaces they belong to.
+type NetWorthService struct {
+	accountRepo     repository.AccountRepository
+	snapshotRepo    repository.AccountSnapshotRepository
+	transactionRepo repository.TransactionRepository
+	holdingRepo     repository.InvestmentHoldingRepository
+	tradeRepo       repository.InvestmentTradeRepository
+	priceRepo       repository.InvestmentPriceRepository
+	spaceRepo       repository.SpaceRepository
+	rates           *ExchangeRateService
+	currencySvc     *CurrencyService
+}
+
+func NewNetWorthService(
+	accountRepo repository.AccountRepository,
+	snapshotRepo repository.AccountSnapshotRepository,
+	transactionRepo repository.TransactionRepository,
+	holdingRepo repository.InvestmentHoldingRepository,
+	tradeRepo repository.InvestmentTradeRepository,
+	priceRepo repository.InvestmentPriceRepository,
+	spaceRepo repository.SpaceRepository,
+	rates *ExchangeRateService,
+) *NetWorthService {
+	return &NetWorthService{
+		accountRepo:     accountRepo,
+		snapshotRepo:    snapshotRepo,
+		transactionRepo: transactionRepo,
+		holdingRepo:     holdingRepo,
+		tradeRepo:       tradeRepo,
+		priceRepo:       priceRepo,
+		spaceRepo:       spaceRepo,
+		rates:           rates,
+	}
+}
+
+// SetCurrencyService wires the space currency registry so snapshot values
+// are rounded to the account currency's decimals.
+func (s *NetWorthService) SetCurrencyService(currencies *CurrencyService) {
+	s.currencySvc = currencies
+}
+
+// SnapshotAll brings the snapshots of every account that has no snapshot
+// for the given day yet up to that day; see SnapshotAccount. An account that
+// fails is logged and skipped. Returns how many accounts were snapshotted.
+func (s *NetWorthService) SnapshotAll(today time.Time) (int, error) {
+	day := rateDay(today)
+	accounts, err := s.accountRepo.All()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load accounts: %w", err)
+	}
+	latest, err := s.snapshotRepo.LatestDates()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load snapshot dates: %w", err)
+	}
+	done := 0
+	for _, a := range accounts {
+		if last, ok := latest[a.ID]; ok && !rateDay(last).Before(day) {
+			continue
+		}
+		if err := s.SnapshotAccount(a, day); err != nil {
+			slog.Error("account snapshot failed", "error", err, "account_id", a.ID)
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+// SnapshotAccount keeps one snapshot per day of the account from the day it
+// was opened, or its first transaction or trade if earlier, through the
+// given day. The history is replayed and compared with the saved snapshots,
+// and only the days from the first one that is missing or changed are
+// written: usually just the new day, but everything after a backdated
+// transaction, trade or price.
+func (s *NetWorthService) SnapshotAccount(account *model.Account, through time.Time) error {
+	through = rateDay(through)
+	v, err := s.valuation(account, through)
+	if err != nil {
+		return err
+	}
+	saved, err := s.snapshotRepo.ByAccountIDs([]string{account.ID}, time.Time{}, through)
+	if err != nil {
+		return fmt.Errorf("failed to load snapshots: %w", err)
+	}
+	cur := s.currencySvc.Get(account.SpaceID, account.Currency)
+	now := time.Now()
+	start := v.start(account)
+	// Snapshots from before the account's history now starts are stale.
+	var from time.Time
+	if len(saved) > 0 && rateDay(saved[0].SnapshotDate).Before(start) {
+		from = rateDay(saved[0].SnapshotDate)
+	}
+	var snapshots []*model.AccountSnapshot
+	i := 0
+	for day := start; !day.After(through); day = day.AddDate(0, 0, 1) {
+		v.advance(day)
+		snap := &model.AccountSnapshot{
+			AccountID:    account.ID,
+			SnapshotDate: day,
+			Balance:      v.balance,
+			Value:        cur.Round(v.value()),
+			CreatedAt:    now,
+		}
+		for ; i < len(saved) && rateDay(saved[i].SnapshotDate).Before(day); i++ {
+		}
+		if from.IsZero() {
+			if i < len(saved) && rateDay(saved[i].SnapshotDate).Equal(day) &&
+				saved[i].Balance.Equal(snap.Balance) && saved[i].Value.Equal(snap.Value) {
+				continue
+			}
+			from = day
+		}
+		snapshots = append(snapshots, snap)
+	}
+	if from.IsZero() {
+		return nil
+	}
+	if err := s.snapshotRepo.ReplaceFrom(account.ID, from, snapshots); err != nil {
+		return fmt.Errorf("failed to save snapshots: %w", err)
+	}
+	return nil
+}
+
+// Value is what the account adds to net worth right now, in its own
+// currency. See model.AccountSnapshot.
+func (s *NetWorthService) Value(account *model.Account) (decimal.Decimal, error) {
+	if !account.IsInvestment {
+		return account.Balance, nil
+	}
+	today := rateDay(time.Now())
+	v, err := s.valuation(account, today)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	v.advance(today)
+	return s.currencySvc.Get(account.SpaceID, account.Currency).Round(v.value()), nil
+}
+
+// valuation loads everything needed to value the account on any day
+// through the given one.
+func (s *NetWorthService) valuation(account *model.Account, through time.Time) (*valuation, error) {
+	nets, err := s.transactionRepo.DailyNetByAccounts([]string{account.ID}, through.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load daily balances: %w", err)
+	}
+	v := &valuation{
+		nets:       nets,
+		investment: account.IsInvestment,
+		prices:     map[string][]*model.InvestmentPrice{},
+		lots:       map[string]*lot{},
+		price:      map[string]decimal.Decimal{},
+		next:       map[string]int{},
+	}
+	if !account.IsInvestment {
+		return v, nil
+	}
+	holdings, err := s.holdingRepo.ByAccountID(account.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load holdings: %w", err)
+	}
+	for _, h := range holdings {
+		trades, err := s.tradeRepo.ByHoldingID(h.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trades: %w", err)
+		}
+		v.trades = append(v.trades, trades...)
+		prices, err := s.priceRepo.ByHoldingID(h.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load prices: %w", err)
+		}
+		v.prices[h.ID] = prices
+		v.lots[h.ID] = &lot{}
+	}
+	sort.SliceStable(v.trades, func(i, j int) bool {
+		return v.trades[i].OccurredAt.Before(v.trades[j].OccurredAt)
+	})
+	return v, nil
+}
+
+// valuation replays an account's transactions, and for an investment
+// account its trades and prices, one day at a time.
+type valuation struct {
+	nets       []repository.AccountDayNet
+	trades     []*model.InvestmentTrade
+	prices     map[string][]*model.InvestmentPrice
+	investment bool
+
+	balance   decimal.Decimal
+	tradeCash decimal.Decimal
+	lots      map[string]*lot
+	price     map[string]decimal.Decimal
+	netIdx    int
+	tradeIdx  int
+	next      map[string]int
+}
+
+// start is the first day the account has a value: the day it was opened,
+// or its first transaction or trade if that came earlier.
+func (v *valuation) start(account *model.Account) time.Time {
+	start := rateDay(account.CreatedAt)
+	if len(v.nets) > 0 && rateDay(v.nets[0].Day).Before(start) {
+		start = rateDay(v.nets[0].Day)
+	}
+	if len(v.trades) > 0 && rateDay(v.trades[0].OccurredAt).Before(start) {
+		start = rateDay(v.trades[0].OccurredAt)
+	}
+	return start
+}
+
+// advance applies everything that happened through the end of day. Days
+// must be passed in order.
+func (v *valuation) advance(day time.Time) {
+	for ; v.netIdx < len(v.nets) && !rateDay(v.nets[v.netIdx].Day).After(day); v.netIdx++ {
+		v.balance = v.balance.Add(v.nets[v.netIdx].Net)
+	}
+	for ; v.tradeIdx < len(v.trades) && !rateDay(v.trades[v.tradeIdx].OccurredAt).After(day); v.tradeIdx++ {
+		t := v.trades[v.tradeIdx]
+		v.lots[t.HoldingID].apply(t)
+		v.tradeCash = v.tradeCash.Add(tradeCash(t))
+	}
+	for id, prices := range v.prices {
+		i := v.next[id]
+		for ; i < len(prices) && !rateDay(prices[i].PriceDate).After(day); i++ {
+			v.price[id] = prices[i].Price
+		}
+		v.next[id] = i
+	}
+}
+
+// value is what the account adds to net worth as of the last advanced day.
+// An investment account counts its uninvested cash plus each holding at its
+// latest market price, or at cost basis before any price was recorded.
+func (v *valuation) value() decimal.Decimal {
+	if !v.investment {
+		return v.balance
+	}
+	// Trades recorded without the deposits that paid for them would leave
+	// cash below zero and cancel out the holdings they bought.
+	value := decimal.Max(v.balance.Add(v.tradeCash), decimal.Zero)
+	for id, l := range v.lots {
+		if price, ok := v.price[id]; ok {
+			value = value.Add(l.qty.Mul(price))
+		} else {
+			value = value.Add(l.costBasis())
+		}
+	}
+	return value
+}
+
+// NetWorthSeries is one line of the net worth trend: the total, a space or
+// an account, with one value per day of the report.
+type NetWorthSeries struct {
+	ID     string
+	Name   string
+	Values []decimal.Decimal
+}
+
+// NetWorthAccount is one account's part of the latest net worth.
+type NetWorthAccount struct {
+	Account   *model.Account
+	SpaceName string
+	// Value is in the account's currency. Converted is in the report's
+	// currency, or nil when the space has no rate between the two.
+	Value     decimal.Decimal
+	Converted *decimal.Decimal
+}
+
+// MissingRate is a currency one of the user's spaces can't convert into the
+// report's currency. The accounts held in it are left out.
+type MissingRate struct {
+	SpaceID   string
+	SpaceName string
+	Currency  string
+}
+
+// NetWorthReport is a user's net worth across all of their spaces in one
+// currency, from the first day in Days to the last.
+type NetWorthReport struct {
+	Currency string
+	Days     []time.Time
+	Total    NetWorthSeries
+	Spaces   []NetWorthSeries
+	Accounts []NetWorthSeries
+	// Latest splits the last day's total into assets and liabilities.
+	Latest  model.AccountTotals
+	Rows    []NetWorthAccount
+	Missing []MissingRate
+}
+
+// Report adds up the user's accounts in the target currency on each sample
+// day between from and to; see NetWorthDays. A zero from starts at the
+// user's oldest snapshot. Past days come from the snapshots, carrying the
+// last one forward over days the worker hasn't reached yet; today is valued
+// live. Each space converts at the rate effective on each day, from its own
+// rates.
+func (s *NetWorthService) Report(userID, target string, from, to time.Time) (*NetWorthReport, error) {
+	to = rateDay(to)
+	spaces, err := s.spaceRepo.ByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spaces: %w", err)
+	}
+	accounts, err := s.accountRepo.ByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load accounts: %w", err)
+	}
+	ids := make([]string, len(accounts))
+	for i, a := range accounts {
+		ids[i] = a.ID
+	}
+	snapshots, err := s.snapshotRepo.ByAccountIDs(ids, rateDay(from), to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshots: %w", err)
+	}
+	if from.IsZero() {
+		from = to
+		for _, snap := range snapshots {
+			if snap.SnapshotDate.Before(from) {
+				from = rateDay(snap.SnapshotDate)
+			}
+		}
+	}
+	days := NetWorthDays(from, to)
+	byAccount := map[string][]*model.AccountSnapshot{}
+	for _, snap := range snapshots {
+		byAccount[snap.AccountID] = append(byAccount[snap.AccountID], snap)
+	}
+
+	report := &NetWorthReport{
+		Currency: target,
+		Days:     days,
+		Total:    NetWorthSeries{ID: "total", Name: "Net worth", Values: zeros(len(days))},
+		Latest:   model.AccountTotals{Currency: target},
+	}
+	today := rateDay(time.Now())
+	for _, space := range spaces {
+		var members []*model.Account
+		currencies := []string{target}
+		for _, a := range accounts {
+			if a.SpaceID == space.ID {
+				members = append(members, a)
+				currencies = append(currencies, a.Currency)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		table, err := s.rates.Table(space.ID, currencies...)
+		if err != nil {
+			return nil, err
+		}
+		spaceSeries := NetWorthSeries{ID: space.ID, Name: space.Name, Values: zeros(len(days))}
+		missing := map[string]bool{}
+		for _, a := range members {
+			values := sampleSnapshots(byAccount[a.ID], days)
+			if days[len(days)-1].Equal(today) {
+				live, err := s.Value(a)
+				if err != nil {
+					return nil, err
+				}
+				values[len(values)-1] = live
+			}
+			row := NetWorthAccount{Account: a, SpaceName: space.Name, Value: values[len(values)-1]}
+			report.Rows = append(report.Rows, row)
+			if !table.Has(a.Currency, target) {
+				if !missing[a.Currency] {
+					missing[a.Currency] = true
+					report.Missing = append(report.Missing, MissingRate{SpaceID: space.ID, SpaceName: space.Name, Currency: a.Currency})
+				}
+				continue
+			}
+			accountSeries := NetWorthSeries{ID: a.ID, Name: a.Name + " (" + space.Name + ")", Values: make([]decimal.Decimal, len(days))}
+			for i, day := range days {
+				converted, _ := table.Convert(values[i], a.Currency, target, day)
+				accountSeries.Values[i] = converted
+				spaceSeries.Values[i] = spaceSeries.Values[i].Add(converted)
+				report.Total.Values[i] = report.Total.Values[i].Add(converted)
+			}
+			latest := accountSeries.Values[len(days)-1]
+			report.Rows[len(report.Rows)-1].Converted = &latest
+			if a.IsLiability() {
+				report.Latest.Liabilities = report.Latest.Liabilities.Add(latest.Neg())
+			} else {
+				report.Latest.Assets = report.Latest.Assets.Add(latest)
+			}
+			report.Accounts = append(report.Accounts, accountSeries)
+		}
+		report.Spaces = append(report.Spaces, spaceSeries)
+	}
+	return report, nil
+}
+
+// sampleSnapshots picks the account's value on each day from its snapshots,
+// ordered by day: the snapshot of that day, or the latest one before it.
+// Days before the first snapshot are zero.
+func sampleSnapshots(snapshots []*model.AccountSnapshot, days []time.Time) []decimal.Decimal {
+	values := zeros(len(days))
+	i := 0
+	current := decimal.Zero
+	for d, day := range days {
+		for ; i < len(snapshots) && !rateDay(snapshots[i].SnapshotDate).After(day); i++ {
+			current = snapshots[i].Value
+		}
+		values[d] = current
+	}
+	return values
+}
+
+func zeros(n int) []decimal.Decimal {
+	values := make([]decimal.Decimal, n)
+	for i := range values {
+		values[i] = decimal.Zero
+	}
+	return values
+}
+
+// NetWorthDays picks the days a net worth trend from one day to another is
+// drawn with, always starting on the first day and ending on the last: every
+// day for up to three months, every week for up to two years, and the end of
+// every month beyond that.
+func NetWorthDays(from, to time.Time) []time.Time {
+	from, to = rateDay(from), rateDay(to)
+	if from.After(to) {
+		from = to
+	}
+	step := func(t time.Time) time.Time { return t.AddDate(0, 0, -1) }
+	switch span := to.Sub(from); {
+	case span > 2*366*24*time.Hour:
+		step = func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 0, 0, 0, 0, 0, time.UTC) }
+	case span > 92*24*time.Hour:
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, -7) }
+	}
+	var days []time.Time
+	for day := to; !day.Before(from); day = step(day) {
+		days = append(days, day)
+	}
+	if last := days[len(days)-1]; last.After(from) {
+		days = append(days, from)
+	}
+	slices.Reverse(days)
+	return days
+}
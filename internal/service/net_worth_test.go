@@ -0,0 +1,147 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetWorthDays(t *testing.T) {
+	to := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	days := NetWorthDays(to.AddDate(0, 0, -10), to)
+	assert.Len(t, days, 11)
+
+	// Weekly, but still starting on the first day.
+	days = NetWorthDays(to.AddDate(-1, 0, 0), to)
+	assert.Equal(t, to.AddDate(-1, 0, 0), days[0])
+	assert.Equal(t, to.AddDate(0, 0, -7), days[len(days)-2])
+	assert.Equal(t, to, days[len(days)-1])
+
+	// Month ends.
+	days = NetWorthDays(to.AddDate(-5, 0, 0), to)
+	assert.Equal(t, time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC), days[len(days)-2])
+	assert.Equal(t, time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC), days[len(days)-3])
+	assert.Equal(t, to, days[len(days)-1])
+}
+
+func TestNetWorthService_SnapshotsAndReport(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		txRepo := repository.NewTransactionRepository(dbi.DB)
+		accountRepo := repository.NewAccountRepository(dbi.DB)
+		holdingRepo := repository.NewInvestmentHoldingRepository(dbi.DB)
+		tradeRepo := repository.NewInvestmentTradeRepository(dbi.DB)
+		priceRepo := repository.NewInvestmentPriceRepository(dbi.DB)
+		snapshotRepo := repository.NewAccountSnapshotRepository(dbi.DB)
+		rates := NewExchangeRateService(repository.NewExchangeRateRepository(dbi.DB), txRepo)
+		investments := NewInvestmentService(accountRepo, repository.NewInvestmentContributionRoomRepository(dbi.DB), holdingRepo, tradeRepo, priceRepo, txRepo)
+		svc := NewNetWorthService(accountRepo, snapshotRepo, txRepo, holdingRepo, tradeRepo, priceRepo, repository.NewSpaceRepository(dbi.DB), rates)
+
+		user := testutil.CreateTestUser(t, dbi.DB, "net-worth@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+		today := rateDay(time.Now())
+		daysAgo := func(n int) time.Time { return today.AddDate(0, 0, -n).Add(12 * time.Hour) }
+		exec := func(query string, args ...any) {
+			t.Helper()
+			_, err := dbi.DB.Exec(query, args...)
+			require.NoError(t, err)
+		}
+
+		chequing := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+		pay := testutil.CreateTestTransaction(t, dbi.DB, chequing.ID, "Pay", model.TransactionTypeDeposit, decimal.NewFromInt(100))
+		exec(`UPDATE transactions SET occurred_at = $1 WHERE id = $2`, daysAgo(10), pay.ID)
+		exec(`UPDATE accounts SET balance = 100 WHERE id = $1`, chequing.ID)
+
+		card := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Card")
+		charge := testutil.CreateTestTransaction(t, dbi.DB, card.ID, "Groceries", model.TransactionTypeWithdrawal, decimal.NewFromInt(40))
+		exec(`UPDATE transactions SET occurred_at = $1 WHERE id = $2`, daysAgo(3), charge.ID)
+		exec(`UPDATE accounts SET kind = 'credit_card', balance = -40 WHERE id = $1`, card.ID)
+
+		brokerage := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Brokerage")
+		contribution := testutil.CreateTestTransaction(t, dbi.DB, brokerage.ID, "Contribution", model.TransactionTypeDeposit, decimal.NewFromInt(1000))
+		exec(`UPDATE transactions SET occurred_at = $1 WHERE id = $2`, daysAgo(20), contribution.ID)
+		exec(`UPDATE accounts SET is_investment = TRUE, balance = 1000 WHERE id = $1`, brokerage.ID)
+		holding, err := investments.CreateHolding(brokerage.ID, "VEQT", "")
+		require.NoError(t, err)
+		fees := decimal.NewFromInt(5)
+		_, err = investments.RecordTrade(RecordTradeInput{
+			HoldingID:    holding.ID,
+			Type:         model.InvestmentTradeTypeBuy,
+			Quantity:     decimal.NewFromInt(10),
+			PricePerUnit: decimal.NewFromInt(50),
+			Fees:         &fees,
+			OccurredAt:   daysAgo(15),
+		})
+		require.NoError(t, err)
+		_, err = investments.RecordPrice(holding.ID, daysAgo(5), decimal.NewFromInt(60))
+		require.NoError(t, err)
+
+		n, err := svc.SnapshotAll(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+		// Nothing left to do until tomorrow.
+		n, err = svc.SnapshotAll(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		snapshots, err := snapshotRepo.ByAccountIDs([]string{brokerage.ID}, daysAgo(30), today)
+		require.NoError(t, err)
+		require.NotEmpty(t, snapshots)
+		assert.Equal(t, rateDay(daysAgo(20)), rateDay(snapshots[0].SnapshotDate))
+		values := map[string]decimal.Decimal{}
+		for _, s := range snapshots {
+			values[s.SnapshotDate.Format("2006-01-02")] = s.Value
+		}
+		valueOn := func(n int) decimal.Decimal { return values[daysAgo(n).Format("2006-01-02")] }
+		// Cash only, then 495 cash plus 505 at cost, then the holding at 60.
+		assert.True(t, valueOn(20).Equal(decimal.NewFromInt(1000)), valueOn(20).String())
+		assert.True(t, valueOn(15).Equal(decimal.NewFromInt(1000)), valueOn(15).String())
+		assert.True(t, valueOn(5).Equal(decimal.NewFromInt(1095)), valueOn(5).String())
+
+		report, err := svc.Report(user.ID, "CAD", daysAgo(30), time.Now())
+		require.NoError(t, err)
+		require.Len(t, report.Days, 31)
+		total := report.Total.Values
+		assert.True(t, total[0].IsZero(), total[0].String())
+		assert.True(t, total[18].Equal(decimal.NewFromInt(1000)), total[18].String())
+		assert.True(t, total[30].Equal(decimal.NewFromInt(1155)), total[30].String())
+		assert.True(t, report.Latest.Assets.Equal(decimal.NewFromInt(1195)), report.Latest.Assets.String())
+		assert.True(t, report.Latest.Liabilities.Equal(decimal.NewFromInt(40)), report.Latest.Liabilities.String())
+		assert.Len(t, report.Spaces, 1)
+		assert.Len(t, report.Accounts, 3)
+		assert.Empty(t, report.Missing)
+
+		// The next day only appends, except where a backdated transaction
+		// changed the history.
+		before, err := snapshotRepo.ByAccountIDs([]string{chequing.ID, card.ID}, daysAgo(30), today)
+		require.NoError(t, err)
+		written := map[string]time.Time{}
+		for _, s := range before {
+			written[s.AccountID+s.SnapshotDate.Format("2006-01-02")] = s.CreatedAt
+		}
+		refund := testutil.CreateTestTransaction(t, dbi.DB, chequing.ID, "Refund", model.TransactionTypeDeposit, decimal.NewFromInt(25))
+		exec(`UPDATE transactions SET occurred_at = $1 WHERE id = $2`, daysAgo(7), refund.ID)
+		n, err = svc.SnapshotAll(today.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+
+		after, err := snapshotRepo.ByAccountIDs([]string{chequing.ID, card.ID}, daysAgo(30), today.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, after, len(before)+2)
+		for _, s := range after {
+			key := s.AccountID + s.SnapshotDate.Format("2006-01-02")
+			rewritten := !s.CreatedAt.Equal(written[key])
+			changed := s.AccountID == chequing.ID && !rateDay(s.SnapshotDate).Before(rateDay(daysAgo(7)))
+			assert.Equal(t, changed || rateDay(s.SnapshotDate).After(today), rewritten, key)
+			if changed {
+				assert.True(t, s.Value.Equal(decimal.NewFromInt(125)), s.Value.String())
+			}
+		}
+	})
+}
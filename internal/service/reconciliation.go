@@ -0,0 +1,284 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrReconciliationInProgress is returned when starting a reconciliation on an
+// account that already has one open.
+var ErrReconciliationInProgress = errors.New("a reconciliation is already in progress for this account")
+
+// ErrReconciliationNotFound is returned when a reconciliation does not exist,
+// does not belong to the requested account, or is no longer open.
+var ErrReconciliationNotFound = errors.New("reconciliation not found")
+
+// ErrReconciliationUnbalanced is returned when finalizing a reconciliation whose
+// cleared balance does not match the statement balance.
+var ErrReconciliationUnbalanced = errors.New("cleared balance does not match the statement balance")
+
+// ErrTransactionOutsideStatement is returned when ticking off a transaction
+// that belongs to another account or occurred after the statement date.
+var ErrTransactionOutsideStatement = errors.New("transaction is not covered by this statement")
+
+type ReconciliationService struct {
+	reconciliationRepo repository.ReconciliationRepository
+	transactionRepo    repository.TransactionRepository
+	accountService     *AccountService
+	auditSvc           *SpaceAuditLogService
+}
+
+func NewReconciliationService(
+	reconciliationRepo repository.ReconciliationRepository,
+	transactionRepo repository.TransactionRepository,
+	accountService *AccountService,
+) *ReconciliationService {
+	return &ReconciliationService{
+		reconciliationRepo: reconciliationRepo,
+		transactionRepo:    transactionRepo,
+		accountService:     accountService,
+	}
+}
+
+// SetAuditLogger wires the audit log service after construction.
+func (s *ReconciliationService) SetAuditLogger(audit *SpaceAuditLogService) {
+	s.auditSvc = audit
+}
+
+type StartReconciliationInput struct {
+	AccountID        string
+	ActorID          string
+	StatementDate    time.Time
+	StatementBalance decimal.Decimal
+}
+
+// Start opens a reconciliation against a statement. An account can only have
+// one reconciliation open at a time.
+func (s *ReconciliationService) Start(input StartReconciliationInput) (*model.Reconciliation, error) {
+	if input.AccountID == "" {
+		return nil, fmt.Errorf("account id is required")
+	}
+	if input.StatementDate.IsZero() {
+		return nil, fmt.Errorf("statement date is required")
+	}
+
+	if _, err := s.reconciliationRepo.OpenByAccount(input.AccountID); err == nil {
+		return nil, ErrReconciliationInProgress
+	} else if !errors.Is(err, repository.ErrReconciliationNotFound) {
+		return nil, fmt.Errorf("failed to check open reconciliation: %w", err)
+	}
+
+	var actorID *string
+	if input.ActorID != "" {
+		actorID = &input.ActorID
+	}
+	rec := &model.Reconciliation{
+		ID:               uuid.NewString(),
+		AccountID:        input.AccountID,
+		ActorID:          actorID,
+		StatementDate:    input.StatementDate,
+		StatementBalance: input.StatementBalance,
+		CreatedAt:        time.Now(),
+	}
+	if err := s.reconciliationRepo.Create(rec); err != nil {
+		return nil, fmt.Errorf("failed to start reconciliation: %w", err)
+	}
+	return rec, nil
+}
+
+// Open returns the account's in-progress reconciliation, or nil when there is
+// none.
+func (s *ReconciliationService) Open(accountID string) (*model.Reconciliation, error) {
+	rec, err := s.reconciliationRepo.OpenByAccount(accountID)
+	if err != nil {
+		if errors.Is(err, repository.ErrReconciliationNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load open reconciliation: %w", err)
+	}
+	return rec, nil
+}
+
+// Get returns a reconciliation, verifying it belongs to the account.
+func (s *ReconciliationService) Get(accountID, reconciliationID string) (*model.Reconciliation, error) {
+	rec, err := s.reconciliationRepo.ByID(reconciliationID)
+	if err != nil {
+		if errors.Is(err, repository.ErrReconciliationNotFound) {
+			return nil, ErrReconciliationNotFound
+		}
+		return nil, fmt.Errorf("failed to load reconciliation: %w", err)
+	}
+	if rec.AccountID != accountID {
+		return nil, ErrReconciliationNotFound
+	}
+	return rec, nil
+}
+
+// History returns the account's finalized reconciliations, latest statement
+// first.
+func (s *ReconciliationService) History(accountID string, limit int) ([]*model.Reconciliation, error) {
+	recs, err := s.reconciliationRepo.ListFinalizedByAccount(accountID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliations: %w", err)
+	}
+	return recs, nil
+}
+
+// ReconciliationWorksheet is the state of an open reconciliation: the
+// transactions still to tick off and how far the cleared balance is from the
+// statement.
+type ReconciliationWorksheet struct {
+	Reconciliation *model.Reconciliation
+	// Transactions are the pending and cleared transactions up to the
+	// statement date, oldest first.
+	Transactions []*model.Transaction
+	// ReconciledBalance is the signed total of every transaction already locked
+	// by earlier reconciliations.
+	ReconciledBalance decimal.Decimal
+	// ClearedTotal is the signed total of the transactions ticked off so far.
+	ClearedTotal decimal.Decimal
+	// ClearedBalance is ReconciledBalance plus ClearedTotal: what the statement
+	// should show if every tick is right.
+	ClearedBalance decimal.Decimal
+	// Difference is the statement balance minus ClearedBalance.
+	Difference decimal.Decimal
+}
+
+// Balanced reports whether the cleared balance matches the statement.
+func (w *ReconciliationWorksheet) Balanced() bool {
+	return w.Difference.IsZero()
+}
+
+// Worksheet computes the running state of a reconciliation.
+func (s *ReconciliationService) Worksheet(rec *model.Reconciliation) (*ReconciliationWorksheet, error) {
+	through := rec.ClearedThrough()
+	txns, err := s.transactionRepo.ListUnreconciledBefore(rec.AccountID, through)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unreconciled transactions: %w", err)
+	}
+	reconciled, cleared, err := s.transactionRepo.SumReconciliation(rec.AccountID, through)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum reconciliation: %w", err)
+	}
+	clearedBalance := reconciled.Add(cleared)
+	return &ReconciliationWorksheet{
+		Reconciliation:    rec,
+		Transactions:      txns,
+		ReconciledBalance: reconciled,
+		ClearedTotal:      cleared,
+		ClearedBalance:    clearedBalance,
+		Difference:        rec.StatementBalance.Sub(clearedBalance),
+	}, nil
+}
+
+// openReconciliation loads a reconciliation that must still be in progress.
+func (s *ReconciliationService) openReconciliation(accountID, reconciliationID string) (*model.Reconciliation, error) {
+	rec, err := s.Get(accountID, reconciliationID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.IsFinalized() {
+		return nil, ErrReconciliationNotFound
+	}
+	return rec, nil
+}
+
+// SetCleared ticks a transaction off (cleared) or back to pending within an
+// open reconciliation. The transaction must belong to the account, fall on or
+// before the statement date, and not already be reconciled.
+func (s *ReconciliationService) SetCleared(accountID, reconciliationID, transactionID string, cleared bool) (*model.Transaction, error) {
+	rec, err := s.openReconciliation(accountID, reconciliationID)
+	if err != nil {
+		return nil, err
+	}
+
+	txn, err := s.transactionRepo.GetByID(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transaction: %w", err)
+	}
+	if txn.AccountID != accountID || !txn.OccurredAt.Before(rec.ClearedThrough()) {
+		return nil, ErrTransactionOutsideStatement
+	}
+	if txn.IsReconciled() {
+		return nil, ErrTransactionReconciled
+	}
+
+	status := model.TransactionStatusPending
+	if cleared {
+		status = model.TransactionStatusCleared
+	}
+	if txn.Status == status {
+		return txn, nil
+	}
+	if err := s.transactionRepo.SetStatus(txn.ID, status); err != nil {
+		return nil, fmt.Errorf("failed to update transaction status: %w", err)
+	}
+	txn.Status = status
+	return txn, nil
+}
+
+// Finalize completes a balanced reconciliation: every cleared transaction up
+// to the statement date becomes reconciled and is locked against edits.
+func (s *ReconciliationService) Finalize(accountID, reconciliationID, actorID string) (*model.Reconciliation, error) {
+	rec, err := s.openReconciliation(accountID, reconciliationID)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.accountService.GetAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+
+	sheet, err := s.Worksheet(rec)
+	if err != nil {
+		return nil, err
+	}
+	if !sheet.Balanced() {
+		return nil, ErrReconciliationUnbalanced
+	}
+
+	now := time.Now()
+	count, err := s.transactionRepo.ReconcileAtomic(rec, now)
+	if err != nil {
+		if errors.Is(err, repository.ErrReconciliationNotFound) {
+			return nil, ErrReconciliationNotFound
+		}
+		return nil, fmt.Errorf("failed to finalize reconciliation: %w", err)
+	}
+	rec.TransactionCount = count
+	rec.FinalizedAt = &now
+
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: account.SpaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionAccountReconciled,
+		Metadata: map[string]any{
+			"account_id":        account.ID,
+			"account_name":      account.Name,
+			"reconciliation_id": rec.ID,
+			"statement_date":    rec.StatementDate.Format("2006-01-02"),
+			"statement_balance": s.accountService.currencyOf(account).Fixed(rec.StatementBalance),
+			"transaction_count": count,
+		},
+	})
+	return rec, nil
+}
+
+// Cancel discards an open reconciliation. Transactions ticked off stay cleared
+// so the work carries over to the next attempt.
+func (s *ReconciliationService) Cancel(accountID, reconciliationID string) error {
+	rec, err := s.openReconciliation(accountID, reconciliationID)
+	if err != nil {
+		return err
+	}
+	if err := s.reconciliationRepo.DeleteOpen(rec.ID); err != nil {
+		return fmt.Errorf("failed to cancel reconciliation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reconciliationFixture struct {
+	svc        *ReconciliationService
+	txnSvc     *TransactionService
+	txns       repository.TransactionRepository
+	spaceAudit repository.SpaceAuditLogRepository
+	user       *model.User
+	space      *model.Space
+	account    *model.Account
+}
+
+func newReconciliationFixture(t *testing.T, dbi testutil.DBInfo) *reconciliationFixture {
+	t.Helper()
+
+	txnRepo := repository.NewTransactionRepository(dbi.DB)
+	spaceAuditRepo := repository.NewSpaceAuditLogRepository(dbi.DB)
+
+	accountSvc := NewAccountService(repository.NewAccountRepository(dbi.DB))
+	txnSvc := NewTransactionService(txnRepo, repository.NewCategoryRepository(dbi.DB), repository.NewTagRepository(dbi.DB), accountSvc)
+	txnSvc.SetAuditLogger(NewTransactionAuditLogService(repository.NewTransactionAuditLogRepository(dbi.DB)))
+	svc := NewReconciliationService(repository.NewReconciliationRepository(dbi.DB), txnRepo, accountSvc)
+	svc.SetAuditLogger(NewSpaceAuditLogService(spaceAuditRepo))
+
+	user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
+	space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+	account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+
+	return &reconciliationFixture{
+		svc:        svc,
+		txnSvc:     txnSvc,
+		txns:       txnRepo,
+		spaceAudit: spaceAuditRepo,
+		user:       user,
+		space:      space,
+		account:    account,
+	}
+}
+
+func (f *reconciliationFixture) deposit(t *testing.T, title, amount string, on time.Time) *model.Transaction {
+	t.Helper()
+	txn, err := f.txnSvc.Deposit(DepositInput{
+		AccountID:  f.account.ID,
+		Title:      title,
+		Amount:     decimal.RequireFromString(amount),
+		OccurredAt: on,
+		ActorID:    f.user.ID,
+	})
+	require.NoError(t, err)
+	return txn
+}
+
+func (f *reconciliationFixture) bill(t *testing.T, title, amount string, on time.Time) *model.Transaction {
+	t.Helper()
+	txn, err := f.txnSvc.PayBill(PayBillInput{
+		AccountID:  f.account.ID,
+		Title:      title,
+		Amount:     decimal.RequireFromString(amount),
+		OccurredAt: on,
+		ActorID:    f.user.ID,
+	})
+	require.NoError(t, err)
+	return txn
+}
+
+func TestReconciliationService_FinalizeLocksClearedTransactions(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newReconciliationFixture(t, dbi)
+		jan := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+		pay := f.deposit(t, "Paycheque", "1000", jan)
+		rent := f.bill(t, "Rent", "400", jan.AddDate(0, 0, 5))
+		later := f.bill(t, "Groceries", "50", jan.AddDate(0, 1, 0))
+
+		rec, err := f.svc.Start(StartReconciliationInput{
+			AccountID:        f.account.ID,
+			ActorID:          f.user.ID,
+			StatementDate:    time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			StatementBalance: decimal.RequireFromString("600"),
+		})
+		require.NoError(t, err)
+
+		_, err = f.svc.Start(StartReconciliationInput{
+			AccountID:     f.account.ID,
+			StatementDate: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		})
+		assert.ErrorIs(t, err, ErrReconciliationInProgress)
+
+		sheet, err := f.svc.Worksheet(rec)
+		require.NoError(t, err)
+		require.Len(t, sheet.Transactions, 2, "transactions after the statement date are left out")
+
+		_, err = f.svc.SetCleared(f.account.ID, rec.ID, later.ID, true)
+		assert.ErrorIs(t, err, ErrTransactionOutsideStatement)
+
+		_, err = f.svc.SetCleared(f.account.ID, rec.ID, pay.ID, true)
+		require.NoError(t, err)
+		_, err = f.svc.Finalize(f.account.ID, rec.ID, f.user.ID)
+		assert.ErrorIs(t, err, ErrReconciliationUnbalanced)
+
+		_, err = f.svc.SetCleared(f.account.ID, rec.ID, rent.ID, true)
+		require.NoError(t, err)
+		sheet, err = f.svc.Worksheet(rec)
+		require.NoError(t, err)
+		assert.True(t, sheet.Balanced(), "difference: %s", sheet.Difference)
+
+		done, err := f.svc.Finalize(f.account.ID, rec.ID, f.user.ID)
+		require.NoError(t, err)
+		assert.True(t, done.IsFinalized())
+		assert.Equal(t, 2, done.TransactionCount)
+
+		got, err := f.txns.GetByID(rent.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.TransactionStatusReconciled, got.Status)
+		got, err = f.txns.GetByID(later.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.TransactionStatusPending, got.Status)
+
+		_, err = f.txnSvc.UpdateBill(UpdateBillInput{
+			TransactionID: rent.ID,
+			Title:         "Rent",
+			Amount:        decimal.RequireFromString("450"),
+			OccurredAt:    rent.OccurredAt,
+		})
+		assert.ErrorIs(t, err, ErrTransactionReconciled)
+		_, err = f.txnSvc.DeleteTransaction(DeleteTransactionInput{TransactionID: pay.ID})
+		assert.ErrorIs(t, err, ErrTransactionReconciled)
+
+		history, err := f.svc.History(f.account.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+
+		logs, err := f.spaceAudit.ListBySpace(f.space.ID, 10, 0)
+		require.NoError(t, err)
+		require.NotEmpty(t, logs)
+		assert.Equal(t, model.SpaceAuditActionAccountReconciled, logs[0].Action)
+	})
+}
+
+func TestReconciliationService_CancelKeepsTicks(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newReconciliationFixture(t, dbi)
+		pay := f.deposit(t, "Paycheque", "1000", time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC))
+
+		rec, err := f.svc.Start(StartReconciliationInput{
+			AccountID:        f.account.ID,
+			StatementDate:    time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			StatementBalance: decimal.RequireFromString("1000"),
+		})
+		require.NoError(t, err)
+		_, err = f.svc.SetCleared(f.account.ID, rec.ID, pay.ID, true)
+		require.NoError(t, err)
+
+		require.NoError(t, f.svc.Cancel(f.account.ID, rec.ID))
+		open, err := f.svc.Open(f.account.ID)
+		require.NoError(t, err)
+		assert.Nil(t, open)
+
+		got, err := f.txns.GetByID(pay.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.TransactionStatusCleared, got.Status)
+	})
+}
+
+func TestTransactionService_UnlockTransaction(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newReconciliationFixture(t, dbi)
+		rent := f.bill(t, "Rent", "400", time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, f.txns.SetStatus(rent.ID, model.TransactionStatusReconciled))
+
+		unlocked, err := f.txnSvc.UnlockTransaction(UnlockTransactionInput{TransactionID: rent.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+		assert.Equal(t, model.TransactionStatusCleared, unlocked.Status)
+
+		_, err = f.txnSvc.UpdateBill(UpdateBillInput{
+			TransactionID: rent.ID,
+			Title:         "Rent",
+			Amount:        decimal.RequireFromString("450"),
+			OccurredAt:    rent.OccurredAt,
+		})
+		assert.NoError(t, err)
+	})
+}
@@ -36,9 +36,12 @@ type CreateRecurringEventInput struct {
 	SpaceID         string
 	Kind            model.RecurringEventKind
 	SourceAccountID string
-	Title           string
-	Amount          decimal.Decimal
-	Description     string
+	// DestAccountID is the account a transfer pays into. Required for
+	// transfers and ignored otherwise.
+	DestAccountID string
+	Title         string
+	Amount        decimal.Decimal
+	Description   string
 
 	Frequency     model.RecurringFrequency
 	IntervalCount int
@@ -60,9 +63,46 @@ type CreateRecurringEventInput struct {
 }
 
 func (s *RecurringEventService) Create(input CreateRecurringEventInput) (*model.RecurringEvent, error) {
+	ev, err := s.newEvent(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ev); err != nil {
+		return nil, fmt.Errorf("failed to create recurring event: %w", err)
+	}
+	return ev, nil
+}
+
+// CreateMany creates several events together: if any of them is invalid or
+// fails to save, none are created.
+func (s *RecurringEventService) CreateMany(inputs []CreateRecurringEventInput) ([]*model.RecurringEvent, error) {
+	events := make([]*model.RecurringEvent, len(inputs))
+	for i, input := range inputs {
+		ev, err := s.newEvent(input)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = ev
+	}
+	if err := s.repo.CreateMany(events); err != nil {
+		return nil, fmt.Errorf("failed to create recurring events: %w", err)
+	}
+	return events, nil
+}
+
+// newEvent validates input and builds the event it describes, with its
+// first run worked out.
+func (s *RecurringEventService) newEvent(input CreateRecurringEventInput) (*model.RecurringEvent, error) {
 	if err := validateRule(input.Kind, input.SourceAccountID, input.Frequency, input.IntervalCount, input.DayOfWeek, input.DayOfMonth, input.MonthOfYear, input.FireHour, input.FireMinute, input.Timezone); err != nil {
 		return nil, err
 	}
+	dest, err := transferDest(input.Kind, input.SourceAccountID, input.DestAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireOpen(input.SourceAccountID, dest); err != nil {
+		return nil, err
+	}
 	title := strings.TrimSpace(input.Title)
 	if title == "" {
 		return nil, fmt.Errorf("title is required")
@@ -98,6 +138,7 @@ func (s *RecurringEventService) Create(input CreateRecurringEventInput) (*model.
 		SpaceID:          input.SpaceID,
 		Kind:             input.Kind,
 		SourceAccountID:  input.SourceAccountID,
+		DestAccountID:    dest,
 		Title:            title,
 		Amount:           input.Amount,
 		Description:      description,
@@ -115,10 +156,6 @@ func (s *RecurringEventService) Create(input CreateRecurringEventInput) (*model.
 		CreatedAt:        now,
 		UpdatedAt:        now,
 	}
-
-	if err := s.repo.Create(ev); err != nil {
-		return nil, fmt.Errorf("failed to create recurring event: %w", err)
-	}
 	return ev, nil
 }
 
@@ -126,6 +163,7 @@ type UpdateRecurringEventInput struct {
 	ID              string
 	Kind            model.RecurringEventKind
 	SourceAccountID string
+	DestAccountID   string
 	Title           string
 	Amount          decimal.Decimal
 	Description     string
@@ -150,6 +188,13 @@ func (s *RecurringEventService) Update(input UpdateRecurringEventInput) (*model.
 	if err := validateRule(input.Kind, input.SourceAccountID, input.Frequency, input.IntervalCount, input.DayOfWeek, input.DayOfMonth, input.MonthOfYear, input.FireHour, input.FireMinute, input.Timezone); err != nil {
 		return nil, err
 	}
+	dest, err := transferDest(input.Kind, input.SourceAccountID, input.DestAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireOpen(input.SourceAccountID, dest); err != nil {
+		return nil, err
+	}
 	title := strings.TrimSpace(input.Title)
 	if title == "" {
 		return nil, fmt.Errorf("title is required")
@@ -184,6 +229,7 @@ func (s *RecurringEventService) Update(input UpdateRecurringEventInput) (*model.
 
 	existing.Kind = input.Kind
 	existing.SourceAccountID = input.SourceAccountID
+	existing.DestAccountID = dest
 	existing.Title = title
 	existing.Amount = input.Amount
 	existing.Description = description
@@ -208,10 +254,40 @@ func (s *RecurringEventService) Delete(id string) error {
 	return s.repo.Delete(id)
 }
 
+// SetPaused pauses or resumes an event. An event on an archived account
+// can't be resumed.
 func (s *RecurringEventService) SetPaused(id string, paused bool) error {
+	if !paused {
+		ev, err := s.repo.ByID(id)
+		if err != nil {
+			return err
+		}
+		if err := s.requireOpen(ev.SourceAccountID, ev.DestAccountID); err != nil {
+			return err
+		}
+	}
 	return s.repo.SetPaused(id, paused)
 }
 
+// requireOpen returns ErrAccountArchived when an event would move money in
+// or out of an archived account.
+func (s *RecurringEventService) requireOpen(sourceID string, destID *string) error {
+	ids := []string{sourceID}
+	if destID != nil {
+		ids = append(ids, *destID)
+	}
+	for _, id := range ids {
+		account, err := s.accountService.GetAccount(id)
+		if err != nil {
+			return fmt.Errorf("failed to load account: %w", err)
+		}
+		if account.IsArchived() {
+			return ErrAccountArchived
+		}
+	}
+	return nil
+}
+
 func (s *RecurringEventService) Get(id string) (*model.RecurringEvent, error) {
 	return s.repo.ByID(id)
 }
@@ -288,15 +364,45 @@ func (s *RecurringEventService) materialize(ev *model.RecurringEvent) error {
 			Description: desc,
 		})
 		return err
+	case model.RecurringEventKindTransfer:
+		if ev.DestAccountID == nil {
+			return fmt.Errorf("transfer has no destination account")
+		}
+		// A transfer between currencies needs a rate, which a schedule
+		// can't know in advance; those are only scheduled within a currency.
+		_, err := s.txService.Transfer(TransferInput{
+			SourceAccountID: ev.SourceAccountID,
+			DestAccountID:   *ev.DestAccountID,
+			Title:           ev.Title,
+			Amount:          ev.Amount,
+			OccurredAt:      ev.NextRunAt,
+			Description:     desc,
+		})
+		return err
 	}
 	return fmt.Errorf("unknown recurring event kind: %s", ev.Kind)
 }
 
 // ----- Recurrence math -----
 
+// transferDest returns the destination of a transfer event, or nil for the
+// other kinds.
+func transferDest(kind model.RecurringEventKind, src, dest string) (*string, error) {
+	if kind != model.RecurringEventKindTransfer {
+		return nil, nil
+	}
+	if dest == "" {
+		return nil, fmt.Errorf("destination account is required")
+	}
+	if dest == src {
+		return nil, fmt.Errorf("source and destination must differ")
+	}
+	return &dest, nil
+}
+
 func validateRule(kind model.RecurringEventKind, src string, freq model.RecurringFrequency, interval int, dow, dom, moy *int, hour, minute int, tz string) error {
 	switch kind {
-	case model.RecurringEventKindBill, model.RecurringEventKindFund:
+	case model.RecurringEventKindBill, model.RecurringEventKindFund, model.RecurringEventKindTransfer:
 		// ok
 	default:
 		return fmt.Errorf("invalid kind: %s", kind)
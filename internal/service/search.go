@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+)
+
+// SearchPage is one page of search results.
+type SearchPage struct {
+	Results []*model.TransactionSearchResult
+	// Page is the 1-based page actually returned; a page past the end is
+	// clamped to the last one.
+	Page       int
+	TotalPages int
+	TotalCount int
+}
+
+// SearchService finds transactions across accounts: every account in a space,
+// or every account in every space a user belongs to.
+type SearchService struct {
+	transactionRepo repository.TransactionRepository
+	categoryRepo    repository.CategoryRepository
+}
+
+func NewSearchService(transactionRepo repository.TransactionRepository, categoryRepo repository.CategoryRepository) *SearchService {
+	return &SearchService{
+		transactionRepo: transactionRepo,
+		categoryRepo:    categoryRepo,
+	}
+}
+
+// SearchSpace returns a page of the space's transactions matching the filter,
+// newest first.
+func (s *SearchService) SearchSpace(spaceID string, filter model.TransactionFilter, page, perPage int) (*SearchPage, error) {
+	return paginateSearch(page, perPage,
+		func() (int, error) { return s.transactionRepo.CountBySpaceFiltered(spaceID, filter) },
+		func(limit, offset int) ([]*model.TransactionSearchResult, error) {
+			return s.transactionRepo.SearchBySpace(spaceID, filter, limit, offset)
+		},
+	)
+}
+
+// SearchMember is SearchSpace across every space the user is a member of.
+func (s *SearchService) SearchMember(userID string, filter model.TransactionFilter, page, perPage int) (*SearchPage, error) {
+	return paginateSearch(page, perPage,
+		func() (int, error) { return s.transactionRepo.CountByMemberFiltered(userID, filter) },
+		func(limit, offset int) ([]*model.TransactionSearchResult, error) {
+			return s.transactionRepo.SearchByMember(userID, filter, limit, offset)
+		},
+	)
+}
+
+// SpaceCategoryNames lists the category names used by the space's accounts,
+// for the category filter.
+func (s *SearchService) SpaceCategoryNames(spaceID string) ([]string, error) {
+	names, err := s.categoryRepo.ListNamesBySpace(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list category names: %w", err)
+	}
+	return names, nil
+}
+
+// MemberCategoryNames is SpaceCategoryNames across the user's spaces.
+func (s *SearchService) MemberCategoryNames(userID string) ([]string, error) {
+	names, err := s.categoryRepo.ListNamesByMember(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list category names: %w", err)
+	}
+	return names, nil
+}
+
+func paginateSearch(
+	page, perPage int,
+	count func() (int, error),
+	list func(limit, offset int) ([]*model.TransactionSearchResult, error),
+) (*SearchPage, error) {
+	total, err := count()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count search results: %w", err)
+	}
+	totalPages := (total + perPage - 1) / perPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	results, err := list(perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transactions: %w", err)
+	}
+	return &SearchPage{
+		Results:    results,
+		Page:       page,
+		TotalPages: totalPages,
+		TotalCount: total,
+	}, nil
+}
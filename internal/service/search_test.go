@@ -0,0 +1,97 @@
+package service
+
+import (
+	"testing"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchService_SearchSpace(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc := NewSearchService(repository.NewTransactionRepository(dbi.DB), repository.NewCategoryRepository(dbi.DB))
+		user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "Home")
+		chequing := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+		card := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Credit card")
+
+		amazon := testutil.CreateTestTransaction(t, dbi.DB, card.ID, "AMZN Mktp CA", model.TransactionTypeWithdrawal, decimal.RequireFromString("42.10"))
+		netflix := testutil.CreateTestTransaction(t, dbi.DB, chequing.ID, "NETFLIX.COM", model.TransactionTypeWithdrawal, decimal.RequireFromString("16.49"))
+		rent := testutil.CreateTestTransaction(t, dbi.DB, chequing.ID, "E-transfer", model.TransactionTypeWithdrawal, decimal.RequireFromString("1800"))
+		_, err := dbi.DB.Exec(`UPDATE transactions SET description = 'May rent for the apartment' WHERE id = $1`, rent.ID)
+		require.NoError(t, err)
+		payroll := testutil.CreateTestTransaction(t, dbi.DB, chequing.ID, "Payroll", model.TransactionTypeDeposit, decimal.RequireFromString("2500"))
+		subs := testutil.CreateTestCategory(t, dbi.DB, chequing.ID, "Subscriptions")
+		_, err = dbi.DB.Exec(`INSERT INTO transaction_categories (transaction_id, category_id) VALUES ($1, $2)`, netflix.ID, subs.ID)
+		require.NoError(t, err)
+
+		other := testutil.CreateTestSpace(t, dbi.DB, testutil.CreateTestUser(t, dbi.DB, t.Name()+"-other@example.com", nil).ID, "Other")
+		testutil.CreateTestTransaction(t, dbi.DB, testutil.CreateTestAccount(t, dbi.DB, other.ID, "Chequing").ID, "AMZN Mktp US", model.TransactionTypeWithdrawal, decimal.RequireFromString("5"))
+
+		ids := func(filter model.TransactionFilter) []string {
+			t.Helper()
+			page, err := svc.SearchSpace(space.ID, filter, 1, 25)
+			require.NoError(t, err)
+			out := make([]string, len(page.Results))
+			for i, r := range page.Results {
+				out[i] = r.ID
+			}
+			return out
+		}
+
+		assert.Equal(t, []string{amazon.ID}, ids(model.TransactionFilter{Query: "amzn"}), "partial merchant names match, other spaces don't")
+		assert.Equal(t, []string{rent.ID}, ids(model.TransactionFilter{Query: "rent apartment"}), "descriptions are searched")
+		assert.Equal(t, []string{netflix.ID}, ids(model.TransactionFilter{Query: "netflx"}), "trigram similarity catches typos")
+		assert.Equal(t, []string{payroll.ID}, ids(model.TransactionFilter{Type: model.TransactionTypeDeposit}))
+		assert.Equal(t, []string{netflix.ID}, ids(model.TransactionFilter{CategoryName: "subscriptions"}))
+		assert.Empty(t, ids(model.TransactionFilter{Query: "100%_off"}), "LIKE wildcards match literally")
+
+		page, err := svc.SearchSpace(space.ID, model.TransactionFilter{Query: "amzn"}, 1, 25)
+		require.NoError(t, err)
+		require.Len(t, page.Results, 1)
+		assert.Equal(t, "Credit card", page.Results[0].AccountName)
+		assert.Equal(t, card.Currency, page.Results[0].Currency)
+		assert.Equal(t, "Home", page.Results[0].SpaceName)
+
+		names, err := svc.SpaceCategoryNames(space.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Subscriptions"}, names)
+	})
+}
+
+func TestSearchService_PaginationIsStable(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc := NewSearchService(repository.NewTransactionRepository(dbi.DB), repository.NewCategoryRepository(dbi.DB))
+		user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "Home")
+		account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Chequing")
+		for range 5 {
+			testutil.CreateTestTransaction(t, dbi.DB, account.ID, "Coffee", model.TransactionTypeWithdrawal, decimal.RequireFromString("4"))
+		}
+		// Identical timestamps leave only the id to order by.
+		_, err := dbi.DB.Exec(`UPDATE transactions SET occurred_at = '2024-05-01', created_at = '2024-05-01' WHERE account_id = $1`, account.ID)
+		require.NoError(t, err)
+
+		filter := model.TransactionFilter{Query: "coffee"}
+		seen := map[string]bool{}
+		for p := 1; p <= 3; p++ {
+			page, err := svc.SearchMember(user.ID, filter, p, 2)
+			require.NoError(t, err)
+			assert.Equal(t, 5, page.TotalCount)
+			assert.Equal(t, 3, page.TotalPages)
+			for _, r := range page.Results {
+				assert.False(t, seen[r.ID], "pages overlap")
+				seen[r.ID] = true
+			}
+		}
+		assert.Len(t, seen, 5)
+
+		last, err := svc.SearchMember(user.ID, filter, 99, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 3, last.Page, "pages past the end clamp to the last one")
+	})
+}
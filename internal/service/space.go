@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"github.com/google/uuid"
@@ -12,8 +13,9 @@ import (
 const DefaultSpaceName = "My Space"
 
 type SpaceService struct {
-	spaceRepo repository.SpaceRepository
-	auditSvc  *SpaceAuditLogService
+	spaceRepo   repository.SpaceRepository
+	auditSvc    *SpaceAuditLogService
+	currencySvc *CurrencyService
 }
 
 func NewSpaceService(spaceRepo repository.SpaceRepository) *SpaceService {
@@ -29,6 +31,12 @@ func (s *SpaceService) SetAuditLogger(audit *SpaceAuditLogService) {
 	s.auditSvc = audit
 }
 
+// SetCurrencyService wires the space currency registry so a space can report
+// in one of its own currencies.
+func (s *SpaceService) SetCurrencyService(currencies *CurrencyService) {
+	s.currencySvc = currencies
+}
+
 // CreateSpace creates a new space and sets the owner.
 func (s *SpaceService) CreateSpace(name string, ownerID string) (*model.Space, error) {
 	if name == "" {
@@ -36,11 +44,12 @@ func (s *SpaceService) CreateSpace(name string, ownerID string) (*model.Space, e
 	}
 
 	space := &model.Space{
-		ID:        uuid.NewString(),
-		Name:      name,
-		OwnerID:   ownerID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:                uuid.NewString(),
+		Name:              name,
+		OwnerID:           ownerID,
+		ReportingCurrency: currency.Default,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
 	}
 
 	err := s.spaceRepo.Create(space)
@@ -146,6 +155,35 @@ func (s *SpaceService) UpdateSpaceName(spaceID, name, actorID string) error {
 	return nil
 }
 
+// SetReportingCurrency changes the currency the space's totals and reports
+// are converted into.
+func (s *SpaceService) SetReportingCurrency(spaceID, code, actorID string) error {
+	code = currency.Normalize(code)
+	if _, err := s.currencySvc.Lookup(spaceID, code); err != nil {
+		return err
+	}
+	current, err := s.spaceRepo.ByID(spaceID)
+	if err != nil {
+		return err
+	}
+	if current.ReportingCurrency == code {
+		return nil
+	}
+	if err := s.spaceRepo.SetReportingCurrency(spaceID, code); err != nil {
+		return fmt.Errorf("failed to set reporting currency: %w", err)
+	}
+	s.auditSvc.Record(RecordOptions{
+		SpaceID: spaceID,
+		ActorID: actorID,
+		Action:  model.SpaceAuditActionReportingCurrencyChanged,
+		Metadata: map[string]any{
+			"old_currency": current.ReportingCurrency,
+			"new_currency": code,
+		},
+	})
+	return nil
+}
+
 // DeleteSpace permanently deletes a space and all its associated data.
 func (s *SpaceService) DeleteSpace(spaceID, actorID string) error {
 	current, err := s.spaceRepo.ByID(spaceID)
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrTagNameTaken is returned when a tag with the same name already exists in
+// the space.
+var ErrTagNameTaken = errors.New("a tag with this name already exists")
+
+// ErrTagNotFound is returned when a tag does not exist or does not belong to
+// the requested space.
+var ErrTagNotFound = errors.New("tag not found")
+
+// ErrTagMergeIntoSelf is returned when a tag is merged into itself.
+var ErrTagMergeIntoSelf = errors.New("cannot merge a tag into itself")
+
+const maxTagNameLen = 40
+
+// TagService manages the space-wide tags attached to transactions. Unlike
+// categories, which belong to one account, a tag can label transactions across
+// every account in the space, and a transaction can carry any number of them.
+type TagService struct {
+	repo repository.TagRepository
+}
+
+func NewTagService(repo repository.TagRepository) *TagService {
+	return &TagService{repo: repo}
+}
+
+// List returns the space's tags ordered by name.
+func (s *TagService) List(spaceID string) ([]*model.Tag, error) {
+	tags, err := s.repo.ListBySpace(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return tags, nil
+}
+
+// Get returns a single tag, verifying it belongs to the space. Returns
+// ErrTagNotFound otherwise.
+func (s *TagService) Get(spaceID, tagID string) (*model.Tag, error) {
+	tag, err := s.repo.ByID(tagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tag: %w", err)
+	}
+	if tag == nil || tag.SpaceID != spaceID {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
+}
+
+// UsageCounts returns how many transactions carry each of the space's tags,
+// keyed by tag ID.
+func (s *TagService) UsageCounts(spaceID string) (map[string]int, error) {
+	counts, err := s.repo.CountTransactionsBySpace(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tag usage: %w", err)
+	}
+	return counts, nil
+}
+
+// ListByTransaction returns the tags on a transaction ordered by name.
+func (s *TagService) ListByTransaction(transactionID string) ([]*model.Tag, error) {
+	tags, err := s.repo.ListByTransaction(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	return tags, nil
+}
+
+// ListByTransactions returns the tags of each transaction keyed by its ID.
+func (s *TagService) ListByTransactions(transactionIDs []string) (map[string][]*model.Tag, error) {
+	tags, err := s.repo.ListByTransactions(transactionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	return tags, nil
+}
+
+// Create adds a tag to the space. Names are trimmed and must be unique within
+// the space (case-insensitive).
+func (s *TagService) Create(spaceID, name string) (*model.Tag, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.ListBySpace(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	if findTagByName(existing, name) != nil {
+		return nil, ErrTagNameTaken
+	}
+	return s.create(spaceID, name)
+}
+
+// EnsureByNames resolves tag names to the space's tags, creating any that do
+// not exist yet. This is what the tag inputs on transaction forms submit.
+// Blank and repeated names are ignored; the result follows the input order.
+func (s *TagService) EnsureByNames(spaceID string, names []string) ([]*model.Tag, error) {
+	existing, err := s.repo.ListBySpace(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	var out []*model.Tag
+	seen := map[string]bool{}
+	for _, raw := range names {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		name, err := normalizeTagName(raw)
+		if err != nil {
+			return nil, err
+		}
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+
+		tag := findTagByName(existing, name)
+		if tag == nil {
+			tag, err = s.create(spaceID, name)
+			if errors.Is(err, ErrTagNameTaken) {
+				// Created concurrently; pick up the winner.
+				tag, err = s.findByName(spaceID, name)
+			}
+			if err != nil {
+				return nil, err
+			}
+			existing = append(existing, tag)
+		}
+		out = append(out, tag)
+	}
+	return out, nil
+}
+
+// Rename changes a tag's name, keeping names unique within the space.
+func (s *TagService) Rename(spaceID, tagID, name string) (*model.Tag, error) {
+	tag, err := s.Get(spaceID, tagID)
+	if err != nil {
+		return nil, err
+	}
+	name, err = normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.ListBySpace(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	if other := findTagByName(existing, name); other != nil && other.ID != tag.ID {
+		return nil, ErrTagNameTaken
+	}
+
+	tag.Name = name
+	tag.UpdatedAt = time.Now()
+	if err := s.repo.Rename(tag.ID, tag.Name, tag.UpdatedAt); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrTagNameTaken
+		}
+		return nil, fmt.Errorf("failed to rename tag: %w", err)
+	}
+	return tag, nil
+}
+
+// Merge folds source into target: every transaction tagged with source is
+// tagged with target instead, and source is deleted. Both must belong to the
+// space.
+func (s *TagService) Merge(spaceID, sourceID, targetID string) error {
+	if sourceID == targetID {
+		return ErrTagMergeIntoSelf
+	}
+	if _, err := s.Get(spaceID, sourceID); err != nil {
+		return err
+	}
+	if _, err := s.Get(spaceID, targetID); err != nil {
+		return err
+	}
+	if err := s.repo.Merge(sourceID, targetID); err != nil {
+		return fmt.Errorf("failed to merge tags: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a tag from the space. Transactions keep everything else; only
+// the tag's links are removed.
+func (s *TagService) Delete(spaceID, tagID string) error {
+	if _, err := s.Get(spaceID, tagID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(tagID); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return nil
+}
+
+// TagSeriesInput parameterizes a tag-over-time report for an account.
+type TagSeriesInput struct {
+	AccountID   string
+	Type        model.TransactionType // withdrawal (spending) or deposit (income)
+	From        time.Time
+	To          time.Time
+	Granularity string // "day", "month", or "year"
+}
+
+// TagTimeSeries is the tag counterpart to CategoryTimeSeries: one zero-filled
+// series per tag with activity in the range, ordered largest-total first.
+// Unlike categories, tags overlap, so series totals can exceed Total.
+func (s *TagService) TagTimeSeries(in TagSeriesInput) (*model.TagTimeSeries, error) {
+	if in.AccountID == "" {
+		return nil, fmt.Errorf("account id is required")
+	}
+	if !validSeriesGranularities[in.Granularity] {
+		return nil, fmt.Errorf("invalid granularity")
+	}
+	if in.To.Before(in.From) {
+		return nil, fmt.Errorf("end date must be on or after start date")
+	}
+
+	buckets := generateBuckets(in.From, in.To, in.Granularity)
+	if len(buckets) > maxSeriesBuckets {
+		return nil, fmt.Errorf("date range is too large for %s granularity", in.Granularity)
+	}
+	indexByKey := make(map[string]int, len(buckets))
+	for i, b := range buckets {
+		indexByKey[bucketKey(b, in.Granularity)] = i
+	}
+
+	rows, err := s.repo.SumByTagBucket(in.AccountID, in.Type, in.From, in.To, in.Granularity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+	}
+	total, err := s.repo.SumTagged(in.AccountID, in.Type, in.From, in.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+	}
+
+	ids := make([]string, 0, len(rows))
+	seen := map[string]bool{}
+	for _, row := range rows {
+		if !seen[row.TagID] {
+			seen[row.TagID] = true
+			ids = append(ids, row.TagID)
+		}
+	}
+	tags, err := s.repo.ByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	nameByID := make(map[string]string, len(tags))
+	for _, t := range tags {
+		nameByID[t.ID] = t.Name
+	}
+
+	byID := map[string]*model.TagSeriesData{}
+	order := []string{}
+	for _, row := range rows {
+		idx, ok := indexByKey[bucketKey(row.Bucket, in.Granularity)]
+		if !ok {
+			continue
+		}
+		series, exists := byID[row.TagID]
+		if !exists {
+			values := make([]decimal.Decimal, len(buckets))
+			for i := range values {
+				values[i] = decimal.Zero
+			}
+			series = &model.TagSeriesData{TagID: row.TagID, TagName: nameByID[row.TagID], Values: values}
+			byID[row.TagID] = series
+			order = append(order, row.TagID)
+		}
+		series.Values[idx] = series.Values[idx].Add(row.Total)
+		series.Total = series.Total.Add(row.Total)
+	}
+
+	result := &model.TagTimeSeries{Buckets: buckets, Total: total}
+	for _, id := range order {
+		result.Series = append(result.Series, *byID[id])
+	}
+	sort.SliceStable(result.Series, func(i, j int) bool {
+		return result.Series[i].Total.GreaterThan(result.Series[j].Total)
+	})
+	return result, nil
+}
+
+func (s *TagService) create(spaceID, name string) (*model.Tag, error) {
+	now := time.Now()
+	tag := &model.Tag{
+		ID:        uuid.NewString(),
+		Name:      name,
+		SpaceID:   spaceID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.Create(tag); err != nil {
+		// The (space_id, lower(name)) unique index is the backstop against a
+		// race between the name check and the insert.
+		if isUniqueViolation(err) {
+			return nil, ErrTagNameTaken
+		}
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+	return tag, nil
+}
+
+func (s *TagService) findByName(spaceID, name string) (*model.Tag, error) {
+	tags, err := s.repo.ListBySpace(spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	if tag := findTagByName(tags, name); tag != nil {
+		return tag, nil
+	}
+	return nil, ErrTagNotFound
+}
+
+func normalizeTagName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if len(name) > maxTagNameLen {
+		return "", fmt.Errorf("name must be at most %d characters", maxTagNameLen)
+	}
+	return name, nil
+}
+
+func findTagByName(tags []*model.Tag, name string) *model.Tag {
+	for _, t := range tags {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "duplicate key") || strings.Contains(msg, "unique")
+}
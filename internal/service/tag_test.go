@@ -0,0 +1,189 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"git.juancwu.dev/juancwu/budgit/internal/model"
+	"git.juancwu.dev/juancwu/budgit/internal/repository"
+	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagService_CreateRenameDelete(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc := NewTagService(repository.NewTagRepository(dbi.DB))
+		user := testutil.CreateTestUser(t, dbi.DB, "tags@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+
+		trip, err := svc.Create(space.ID, "  Summer   trip ")
+		require.NoError(t, err)
+		assert.Equal(t, "Summer trip", trip.Name, "whitespace is collapsed")
+
+		_, err = svc.Create(space.ID, "summer TRIP")
+		assert.ErrorIs(t, err, ErrTagNameTaken)
+		_, err = svc.Create(space.ID, "  ")
+		assert.Error(t, err)
+
+		work, err := svc.Create(space.ID, "Work")
+		require.NoError(t, err)
+		_, err = svc.Rename(space.ID, work.ID, "summer trip")
+		assert.ErrorIs(t, err, ErrTagNameTaken)
+
+		// Renaming to a different case of its own name is allowed.
+		renamed, err := svc.Rename(space.ID, trip.ID, "Summer Trip")
+		require.NoError(t, err)
+		assert.Equal(t, "Summer Trip", renamed.Name)
+
+		other := testutil.CreateTestSpace(t, dbi.DB, user.ID, "Other")
+		assert.ErrorIs(t, svc.Delete(other.ID, work.ID), ErrTagNotFound, "tags are scoped to their space")
+		require.NoError(t, svc.Delete(space.ID, work.ID))
+
+		tags, err := svc.List(space.ID)
+		require.NoError(t, err)
+		require.Len(t, tags, 1)
+		assert.Equal(t, trip.ID, tags[0].ID)
+	})
+}
+
+func TestTagService_EnsureByNames_ReusesAndCreates(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		svc := NewTagService(repository.NewTagRepository(dbi.DB))
+		user := testutil.CreateTestUser(t, dbi.DB, "ensure-tags@example.com", nil)
+		space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
+
+		existing, err := svc.Create(space.ID, "Groceries")
+		require.NoError(t, err)
+
+		tags, err := svc.EnsureByNames(space.ID, []string{"groceries", "Costco", "", "costco"})
+		require.NoError(t, err)
+		require.Len(t, tags, 2)
+		assert.Equal(t, existing.ID, tags[0].ID, "matches existing tags case-insensitively")
+		assert.Equal(t, "Costco", tags[1].Name)
+
+		all, err := svc.List(space.ID)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+}
+
+func TestTransactionService_Tags_AssignDiffAndMerge(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		tags := NewTagService(repository.NewTagRepository(dbi.DB))
+
+		trip, err := tags.Create(f.account.SpaceID, "Trip")
+		require.NoError(t, err)
+		food, err := tags.Create(f.account.SpaceID, "Food")
+		require.NoError(t, err)
+
+		txn, err := f.svc.PayBill(PayBillInput{
+			AccountID:  f.account.ID,
+			Title:      "Dinner",
+			Amount:     decimal.NewFromInt(80),
+			OccurredAt: time.Now(),
+			TagIDs:     []string{trip.ID, trip.ID},
+			ActorID:    f.user.ID,
+		})
+		require.NoError(t, err)
+		onTxn, err := tags.ListByTransaction(txn.ID)
+		require.NoError(t, err)
+		require.Len(t, onTxn, 1)
+
+		_, err = f.svc.UpdateBill(UpdateBillInput{
+			TransactionID: txn.ID,
+			Title:         "Dinner",
+			Amount:        decimal.NewFromInt(80),
+			OccurredAt:    txn.OccurredAt,
+			TagIDs:        []string{food.ID, trip.ID},
+			ActorID:       f.user.ID,
+		})
+		require.NoError(t, err)
+
+		logs, err := f.txAudit.ListByTransaction(txn.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 2)
+		var meta struct {
+			Changes map[string]map[string]string `json:"changes"`
+		}
+		require.NoError(t, json.Unmarshal(logs[0].Metadata, &meta))
+		assert.Equal(t, map[string]string{"old": "Trip", "new": "Food, Trip"}, meta.Changes["tags"])
+
+		// Merging leaves the transaction with a single tag.
+		require.NoError(t, tags.Merge(f.account.SpaceID, trip.ID, food.ID))
+		onTxn, err = tags.ListByTransaction(txn.ID)
+		require.NoError(t, err)
+		require.Len(t, onTxn, 1)
+		assert.Equal(t, food.ID, onTxn[0].ID)
+		assert.ErrorIs(t, tags.Merge(f.account.SpaceID, food.ID, food.ID), ErrTagMergeIntoSelf)
+	})
+}
+
+func TestTransactionService_Tags_RejectsOtherSpace(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		tags := NewTagService(repository.NewTagRepository(dbi.DB))
+
+		otherSpace := testutil.CreateTestSpace(t, dbi.DB, f.user.ID, "Other")
+		foreign, err := tags.Create(otherSpace.ID, "Foreign")
+		require.NoError(t, err)
+
+		_, err = f.svc.Deposit(DepositInput{
+			AccountID:  f.account.ID,
+			Title:      "Paycheck",
+			Amount:     decimal.NewFromInt(10),
+			OccurredAt: time.Now(),
+			TagIDs:     []string{foreign.ID},
+			ActorID:    f.user.ID,
+		})
+		assert.Error(t, err)
+
+		updated, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		assert.True(t, updated.Balance.IsZero(), "nothing is written when a tag is rejected")
+	})
+}
+
+func TestTagService_TagTimeSeries_CountsOverlapOnce(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		tags := NewTagService(repository.NewTagRepository(dbi.DB))
+
+		trip, err := tags.Create(f.account.SpaceID, "Trip")
+		require.NoError(t, err)
+		food, err := tags.Create(f.account.SpaceID, "Food")
+		require.NoError(t, err)
+
+		jan := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+		feb := time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)
+		pay := func(amount int64, at time.Time, tagIDs ...string) {
+			_, err := f.svc.PayBill(PayBillInput{
+				AccountID: f.account.ID, Title: "x", Amount: decimal.NewFromInt(amount),
+				OccurredAt: at, TagIDs: tagIDs, ActorID: f.user.ID,
+			})
+			require.NoError(t, err)
+		}
+		pay(100, jan, trip.ID, food.ID)
+		pay(30, feb, food.ID)
+		pay(999, feb) // untagged
+
+		series, err := tags.TagTimeSeries(TagSeriesInput{
+			AccountID:   f.account.ID,
+			Type:        model.TransactionTypeWithdrawal,
+			From:        time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:          time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC),
+			Granularity: "month",
+		})
+		require.NoError(t, err)
+		require.Len(t, series.Buckets, 2)
+		require.Len(t, series.Series, 2)
+		assert.Equal(t, "Food", series.Series[0].TagName, "largest first")
+		assert.True(t, decimal.NewFromInt(130).Equal(series.Series[0].Total))
+		assert.True(t, decimal.NewFromInt(30).Equal(series.Series[0].Values[1]))
+		assert.True(t, decimal.NewFromInt(100).Equal(series.Series[1].Total))
+		assert.True(t, decimal.NewFromInt(130).Equal(series.Total), "the doubly-tagged bill counts once")
+	})
+}
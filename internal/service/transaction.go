@@ -3,10 +3,12 @@ package service
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"github.com/google/uuid"
@@ -16,32 +18,52 @@ import (
 // ErrTransactionPartOfTransfer is returned when an operation that mutates a
 // single transaction (edit, delete) is attempted on one half of a transfer.
 // Transfers must be edited as a pair or not at all to keep both sides in sync;
-// callers should surface a user-facing message and offer to undo the transfer
-// instead.
+// callers should surface a user-facing message and offer UpdateTransfer or
+// UndoTransfer instead.
 var ErrTransactionPartOfTransfer = errors.New("transaction is part of a transfer")
 
 // ErrTransferExceedsAvailable is returned when a transfer would move more
 // funds out of the source than its available balance (balance minus
-// allocations). Bills can still overdraft, but transfers must respect what
-// has already been allocated to other purposes.
+// allocations, or the unused credit of a credit account). Bills can still
+// overdraft, but transfers must respect what has already been allocated to
+// other purposes.
 var ErrTransferExceedsAvailable = errors.New("transfer amount exceeds available balance")
 
+// ErrNotATransfer is returned when a transfer operation is given a
+// transaction that is not one half of a transfer pair.
+var ErrNotATransfer = errors.New("transaction is not part of a transfer")
+
+// ErrSplitsDoNotSum is returned when a transaction's category splits do not add
+// up to its amount.
+var ErrSplitsDoNotSum = errors.New("category splits must add up to the transaction amount")
+
+// ErrTransactionReconciled is returned when editing or deleting a transaction
+// that a finalized reconciliation has locked. Callers should offer
+// UnlockTransaction first.
+var ErrTransactionReconciled = errors.New("transaction is reconciled")
+
 type TransactionService struct {
 	transactionRepo   repository.TransactionRepository
 	categoryRepo      repository.CategoryRepository
+	tagRepo           repository.TagRepository
 	accountService    *AccountService
 	allocationService *AllocationService
+	rulesSvc          *CategorizationRuleService
+	loanService       *LoanService
+	rateSvc           *ExchangeRateService
 	auditSvc          *TransactionAuditLogService
 }
 
 func NewTransactionService(
 	transactionRepo repository.TransactionRepository,
 	categoryRepo repository.CategoryRepository,
+	tagRepo repository.TagRepository,
 	accountService *AccountService,
 ) *TransactionService {
 	return &TransactionService{
 		transactionRepo: transactionRepo,
 		categoryRepo:    categoryRepo,
+		tagRepo:         tagRepo,
 		accountService:  accountService,
 	}
 }
@@ -58,6 +80,24 @@ func (s *TransactionService) SetAllocationService(alloc *AllocationService) {
 	s.allocationService = alloc
 }
 
+// SetLoanService wires the loan service so a transfer into a loan account
+// charges the interest accrued since the previous payment.
+func (s *TransactionService) SetLoanService(loans *LoanService) {
+	s.loanService = loans
+}
+
+// SetExchangeRateService wires the rate store so cross-currency transfers
+// remember their rate and reports can convert into the reporting currency.
+func (s *TransactionService) SetExchangeRateService(rates *ExchangeRateService) {
+	s.rateSvc = rates
+}
+
+// SetCategorizationRuleService wires the rules used to categorize bills,
+// deposits and imported rows that arrive without a category.
+func (s *TransactionService) SetCategorizationRuleService(rules *CategorizationRuleService) {
+	s.rulesSvc = rules
+}
+
 type PayBillInput struct {
 	AccountID   string
 	Title       string
@@ -65,7 +105,9 @@ type PayBillInput struct {
 	OccurredAt  time.Time
 	Description string
 	CategoryID  string
-	ActorID     string
+	// TagIDs are tags from the account's space to attach.
+	TagIDs  []string
+	ActorID string
 }
 
 func (s *TransactionService) PayBill(input PayBillInput) (*model.Transaction, error) {
@@ -87,19 +129,30 @@ func (s *TransactionService) PayBill(input PayBillInput) (*model.Transaction, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to load account: %w", err)
 	}
-
-	newBalance := account.Balance.Sub(input.Amount)
+	if account.IsArchived() {
+		return nil, ErrAccountArchived
+	}
 
 	now := time.Now()
 	var description *string
 	if d := strings.TrimSpace(input.Description); d != "" {
 		description = &d
 	}
-	var categoryID *string
-	if c := strings.TrimSpace(input.CategoryID); c != "" {
-		categoryID = &c
+	categoryID := input.CategoryID
+	ruleMatch, err := s.matchRule(account.ID, categoryID, title, input.Amount, model.TransactionTypeWithdrawal)
+	if err != nil {
+		return nil, err
+	}
+	if ruleMatch != nil {
+		categoryID = ruleMatch.Rule.CategoryID
+		title = ruleMatch.Title
+	}
+	splits, err := s.resolveSplits(categoryID, nil, input.Amount, account.ID)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.validateCategoryForAccount(categoryID, account.ID); err != nil {
+	tags, err := s.validateTagsForSpace(input.TagIDs, account.SpaceID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -110,25 +163,28 @@ func (s *TransactionService) PayBill(input PayBillInput) (*model.Transaction, er
 		AccountID:   input.AccountID,
 		Title:       title,
 		Description: description,
+		Status:      model.TransactionStatusPending,
 		OccurredAt:  input.OccurredAt,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
 
-	if err := s.transactionRepo.CreateBillAtomic(txn, newBalance, categoryID); err != nil {
+	entry := model.TransactionEntry(account.SpaceID, txn, account.Currency)
+	if err := s.transactionRepo.CreateBillAtomic(txn, entry, splits, tagIDs(tags)); err != nil {
 		return nil, fmt.Errorf("failed to create bill transaction: %w", err)
 	}
+	cur := s.accountService.currencyOf(account)
 
 	s.auditSvc.Record(TransactionRecordOptions{
 		TransactionID: txn.ID,
 		ActorID:       input.ActorID,
 		Action:        model.TransactionAuditActionCreated,
-		Metadata: map[string]any{
+		Metadata: withRuleMatch(withTagNames(map[string]any{
 			"account_id":       txn.AccountID,
 			"transaction_type": string(model.TransactionTypeWithdrawal),
 			"title":            txn.Title,
-			"amount":           txn.Value.StringFixedBank(2),
-		},
+			"amount":           cur.Fixed(txn.Value),
+		}, tags), ruleMatch, strings.TrimSpace(input.Title)),
 	})
 
 	return txn, nil
@@ -141,7 +197,9 @@ type DepositInput struct {
 	OccurredAt  time.Time
 	Description string
 	CategoryID  string
-	ActorID     string
+	// TagIDs are tags from the account's space to attach.
+	TagIDs  []string
+	ActorID string
 }
 
 func (s *TransactionService) Deposit(input DepositInput) (*model.Transaction, error) {
@@ -163,19 +221,30 @@ func (s *TransactionService) Deposit(input DepositInput) (*model.Transaction, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to load account: %w", err)
 	}
-
-	newBalance := account.Balance.Add(input.Amount)
+	if account.IsArchived() {
+		return nil, ErrAccountArchived
+	}
 
 	now := time.Now()
 	var description *string
 	if d := strings.TrimSpace(input.Description); d != "" {
 		description = &d
 	}
-	var categoryID *string
-	if c := strings.TrimSpace(input.CategoryID); c != "" {
-		categoryID = &c
+	categoryID := input.CategoryID
+	ruleMatch, err := s.matchRule(account.ID, categoryID, title, input.Amount, model.TransactionTypeDeposit)
+	if err != nil {
+		return nil, err
+	}
+	if ruleMatch != nil {
+		categoryID = ruleMatch.Rule.CategoryID
+		title = ruleMatch.Title
+	}
+	splits, err := s.resolveSplits(categoryID, nil, input.Amount, account.ID)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.validateCategoryForAccount(categoryID, account.ID); err != nil {
+	tags, err := s.validateTagsForSpace(input.TagIDs, account.SpaceID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -186,25 +255,28 @@ func (s *TransactionService) Deposit(input DepositInput) (*model.Transaction, er
 		AccountID:   input.AccountID,
 		Title:       title,
 		Description: description,
+		Status:      model.TransactionStatusPending,
 		OccurredAt:  input.OccurredAt,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
 
-	if err := s.transactionRepo.CreateDepositAtomic(txn, newBalance, categoryID); err != nil {
+	entry := model.TransactionEntry(account.SpaceID, txn, account.Currency)
+	if err := s.transactionRepo.CreateDepositAtomic(txn, entry, splits, tagIDs(tags)); err != nil {
 		return nil, fmt.Errorf("failed to create deposit transaction: %w", err)
 	}
+	cur := s.accountService.currencyOf(account)
 
 	s.auditSvc.Record(TransactionRecordOptions{
 		TransactionID: txn.ID,
 		ActorID:       input.ActorID,
 		Action:        model.TransactionAuditActionCreated,
-		Metadata: map[string]any{
+		Metadata: withRuleMatch(withTagNames(map[string]any{
 			"account_id":       txn.AccountID,
 			"transaction_type": string(model.TransactionTypeDeposit),
 			"title":            txn.Title,
-			"amount":           txn.Value.StringFixedBank(2),
-		},
+			"amount":           cur.Fixed(txn.Value),
+		}, tags), ruleMatch, strings.TrimSpace(input.Title)),
 	})
 
 	return txn, nil
@@ -221,7 +293,9 @@ type TransferInput struct {
 	ConversionRate decimal.Decimal
 	OccurredAt     time.Time
 	Description    string
-	ActorID        string
+	// TagIDs are attached to both halves of the transfer.
+	TagIDs  []string
+	ActorID string
 }
 
 // TransferResult is what the service returns after a successful transfer — both
@@ -264,22 +338,24 @@ func (s *TransactionService) Transfer(input TransferInput) (*TransferResult, err
 	if err != nil {
 		return nil, fmt.Errorf("failed to load destination account: %w", err)
 	}
+	if source.IsArchived() || dest.IsArchived() {
+		return nil, ErrAccountArchived
+	}
 
 	// Transfers must respect allocations on the source. A transfer is the user
 	// committing funds elsewhere — if the unallocated cash isn't there, the
 	// transfer can't happen. (Bills are still allowed to overdraft.)
-	if s.allocationService != nil {
-		summary, err := s.allocationService.SummaryForAccount(source.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load source allocations: %w", err)
-		}
-		if input.Amount.GreaterThan(summary.Available) {
-			return nil, ErrTransferExceedsAvailable
-		}
+	available, limited, err := s.availableToTransfer(source)
+	if err != nil {
+		return nil, err
+	}
+	if limited && input.Amount.GreaterThan(available) {
+		return nil, ErrTransferExceedsAvailable
 	}
 
 	// Cross-currency transfers require a conversion rate; same-currency
 	// transfers ignore it (or, for symmetry, accept rate=1).
+	sourceCur, destCur := s.accountService.currencyOf(source), s.accountService.currencyOf(dest)
 	destAmount := input.Amount
 	rate := decimal.NewFromInt(1)
 	if source.Currency != dest.Currency {
@@ -287,7 +363,15 @@ func (s *TransactionService) Transfer(input TransferInput) (*TransferResult, err
 			return nil, fmt.Errorf("conversion rate is required when transferring between accounts of different currencies")
 		}
 		rate = input.ConversionRate
-		destAmount = input.Amount.Mul(rate).Round(2)
+		destAmount = destCur.Round(input.Amount.Mul(rate))
+	}
+
+	tags, err := s.validateTagsForSpace(input.TagIDs, source.SpaceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 && dest.SpaceID != source.SpaceID {
+		return nil, fmt.Errorf("invalid tag")
 	}
 
 	now := time.Now()
@@ -303,6 +387,7 @@ func (s *TransactionService) Transfer(input TransferInput) (*TransferResult, err
 		AccountID:   source.ID,
 		Title:       title,
 		Description: description,
+		Status:      model.TransactionStatusPending,
 		OccurredAt:  input.OccurredAt,
 		CreatedAt:   now,
 		UpdatedAt:   now,
@@ -314,17 +399,50 @@ func (s *TransactionService) Transfer(input TransferInput) (*TransferResult, err
 		AccountID:   dest.ID,
 		Title:       title,
 		Description: description,
+		Status:      model.TransactionStatusPending,
 		OccurredAt:  input.OccurredAt,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
 
-	sourceNewBalance := source.Balance.Sub(input.Amount)
-	destNewBalance := dest.Balance.Add(destAmount)
+	entry := model.TransferEntry(dest.SpaceID, withdrawal, source.Currency, deposit, dest.Currency)
 
-	if err := s.transactionRepo.TransferAtomic(withdrawal, deposit, sourceNewBalance, destNewBalance); err != nil {
+	// A transfer into a loan is a payment: the interest accrued since the
+	// previous one is charged on the loan first, so only the rest of the
+	// payment goes to the principal.
+	var interest *model.Transaction
+	if dest.Kind == model.AccountKindLoan && s.loanService != nil {
+		interest, err = s.loanService.paymentInterest(dest, input.OccurredAt, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute loan interest: %w", err)
+		}
+		var interestEntry *model.JournalEntry
+		if interest != nil {
+			interestEntry = model.TransactionEntry(dest.SpaceID, interest, dest.Currency)
+		}
+		if err := s.transactionRepo.LoanPaymentAtomic(withdrawal, deposit, entry, interest, interestEntry, tagIDs(tags)); err != nil {
+			return nil, fmt.Errorf("failed to record transfer: %w", err)
+		}
+	} else if err := s.transactionRepo.TransferAtomic(withdrawal, deposit, entry, tagIDs(tags)); err != nil {
 		return nil, fmt.Errorf("failed to record transfer: %w", err)
 	}
+	if source.Currency != dest.Currency {
+		s.rateSvc.Record(source.SpaceID, source.Currency, dest.Currency, input.OccurredAt, rate, model.ExchangeRateSourceTransfer)
+	}
+	if interest != nil {
+		s.auditSvc.Record(TransactionRecordOptions{
+			TransactionID: interest.ID,
+			ActorID:       input.ActorID,
+			Action:        model.TransactionAuditActionCreated,
+			Metadata: map[string]any{
+				"account_id":       interest.AccountID,
+				"transaction_type": string(interest.Type),
+				"title":            interest.Title,
+				"amount":           destCur.Fixed(interest.Value),
+				"loan_payment_id":  deposit.ID,
+			},
+		})
+	}
 
 	// Audit each side. Metadata captures the role and the other half so the
 	// activity feed can render "Transferred to/from <other account>" without a
@@ -333,11 +451,11 @@ func (s *TransactionService) Transfer(input TransferInput) (*TransferResult, err
 		TransactionID: withdrawal.ID,
 		ActorID:       input.ActorID,
 		Action:        model.TransactionAuditActionCreated,
-		Metadata: map[string]any{
+		Metadata: withTagNames(map[string]any{
 			"account_id":          withdrawal.AccountID,
 			"transaction_type":    string(withdrawal.Type),
 			"title":               withdrawal.Title,
-			"amount":              withdrawal.Value.StringFixedBank(2),
+			"amount":              sourceCur.Fixed(withdrawal.Value),
 			"transfer_role":       "source",
 			"transfer_pair_id":    deposit.ID,
 			"transfer_other_acct": deposit.AccountID,
@@ -345,18 +463,18 @@ func (s *TransactionService) Transfer(input TransferInput) (*TransferResult, err
 			"source_currency":     source.Currency,
 			"dest_currency":       dest.Currency,
 			"conversion_rate":     rate.String(),
-			"dest_amount":         destAmount.StringFixedBank(2),
-		},
+			"dest_amount":         destCur.Fixed(destAmount),
+		}, tags),
 	})
 	s.auditSvc.Record(TransactionRecordOptions{
 		TransactionID: deposit.ID,
 		ActorID:       input.ActorID,
 		Action:        model.TransactionAuditActionCreated,
-		Metadata: map[string]any{
+		Metadata: withTagNames(map[string]any{
 			"account_id":          deposit.AccountID,
 			"transaction_type":    string(deposit.Type),
 			"title":               deposit.Title,
-			"amount":              deposit.Value.StringFixedBank(2),
+			"amount":              destCur.Fixed(deposit.Value),
 			"transfer_role":       "destination",
 			"transfer_pair_id":    withdrawal.ID,
 			"transfer_other_acct": withdrawal.AccountID,
@@ -364,8 +482,8 @@ func (s *TransactionService) Transfer(input TransferInput) (*TransferResult, err
 			"source_currency":     source.Currency,
 			"dest_currency":       dest.Currency,
 			"conversion_rate":     rate.String(),
-			"source_amount":       input.Amount.StringFixedBank(2),
-		},
+			"source_amount":       sourceCur.Fixed(input.Amount),
+		}, tags),
 	})
 
 	return &TransferResult{Withdrawal: withdrawal, Deposit: deposit}, nil
@@ -394,6 +512,278 @@ func (s *TransactionService) GetRelatedTransactionID(transactionID string) (stri
 	return *id, nil
 }
 
+// GetTransferPair returns both halves of the transfer the given transaction
+// belongs to. Either half may be passed. Returns ErrNotATransfer for a
+// standalone bill or deposit.
+func (s *TransactionService) GetTransferPair(transactionID string) (*TransferResult, error) {
+	if transactionID == "" {
+		return nil, fmt.Errorf("transaction id is required")
+	}
+	txn, err := s.transactionRepo.GetByID(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transaction: %w", err)
+	}
+	relatedID, err := s.transactionRepo.GetRelatedID(txn.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check transfer linkage: %w", err)
+	}
+	if relatedID == nil {
+		return nil, ErrNotATransfer
+	}
+	related, err := s.transactionRepo.GetByID(*relatedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load related transaction: %w", err)
+	}
+	if txn.Type == model.TransactionTypeWithdrawal {
+		return &TransferResult{Withdrawal: txn, Deposit: related}, nil
+	}
+	return &TransferResult{Withdrawal: related, Deposit: txn}, nil
+}
+
+type UpdateTransferInput struct {
+	// TransactionID may be either half of the transfer.
+	TransactionID string
+	Title         string
+	// Amount is what leaves the source account.
+	Amount decimal.Decimal
+	// ConversionRate converts one unit of the source currency into the
+	// destination currency. Required when the accounts' currencies differ;
+	// ignored otherwise.
+	ConversionRate decimal.Decimal
+	OccurredAt     time.Time
+	Description    string
+	ActorID        string
+}
+
+// UpdateTransfer edits both halves of a transfer together: the withdrawal
+// takes the new amount, the deposit the converted amount, and both account
+// balances move by the difference in one database transaction. Raising the
+// amount must still fit within the source's available (unallocated) balance.
+// A loan payment's amount and date are fixed (see ErrLoanPaymentChanged).
+func (s *TransactionService) UpdateTransfer(input UpdateTransferInput) (*TransferResult, error) {
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+	if !input.Amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be greater than zero")
+	}
+	if input.OccurredAt.IsZero() {
+		return nil, fmt.Errorf("date is required")
+	}
+
+	pair, err := s.GetTransferPair(input.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	withdrawal, deposit := pair.Withdrawal, pair.Deposit
+	if withdrawal.IsReconciled() || deposit.IsReconciled() {
+		return nil, ErrTransactionReconciled
+	}
+
+	source, err := s.accountService.GetAccount(withdrawal.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source account: %w", err)
+	}
+	dest, err := s.accountService.GetAccount(deposit.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load destination account: %w", err)
+	}
+
+	// Only an increase can break the allocation constraint; the current
+	// amount is already out of the source, so it counts as available again.
+	if input.Amount.GreaterThan(withdrawal.Value) {
+		available, limited, err := s.availableToTransfer(source)
+		if err != nil {
+			return nil, err
+		}
+		if limited && input.Amount.GreaterThan(available.Add(withdrawal.Value)) {
+			return nil, ErrTransferExceedsAvailable
+		}
+	}
+
+	sourceCur, destCur := s.accountService.currencyOf(source), s.accountService.currencyOf(dest)
+	destAmount := input.Amount
+	rate := decimal.NewFromInt(1)
+	if source.Currency != dest.Currency {
+		if !input.ConversionRate.IsPositive() {
+			return nil, fmt.Errorf("conversion rate is required when transferring between accounts of different currencies")
+		}
+		rate = input.ConversionRate
+		destAmount = destCur.Round(input.Amount.Mul(rate))
+	}
+	if dest.Kind == model.AccountKindLoan && s.loanService != nil &&
+		(!input.Amount.Equal(withdrawal.Value) || !destAmount.Equal(deposit.Value) || !input.OccurredAt.Equal(deposit.OccurredAt)) {
+		return nil, ErrLoanPaymentChanged
+	}
+
+	var description *string
+	if d := strings.TrimSpace(input.Description); d != "" {
+		description = &d
+	}
+
+	withdrawalChanges := diffTransactionFields(withdrawal, title, input.Amount, input.OccurredAt, description, sourceCur)
+	depositChanges := diffTransactionFields(deposit, title, destAmount, input.OccurredAt, description, destCur)
+	if len(withdrawalChanges) == 0 && len(depositChanges) == 0 {
+		return pair, nil
+	}
+
+	now := time.Now()
+	for _, t := range []*model.Transaction{withdrawal, deposit} {
+		t.Title = title
+		t.Description = description
+		t.OccurredAt = input.OccurredAt
+		t.UpdatedAt = now
+	}
+	withdrawal.Value = input.Amount
+	deposit.Value = destAmount
+
+	from, err := s.postingCurrency(withdrawal, source)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.postingCurrency(deposit, dest)
+	if err != nil {
+		return nil, err
+	}
+	entry := model.TransferEntry(dest.SpaceID, withdrawal, from, deposit, to)
+	if err := s.transactionRepo.UpdateTransferAtomic(withdrawal, deposit, entry); err != nil {
+		return nil, fmt.Errorf("failed to update transfer: %w", err)
+	}
+	if source.Currency != dest.Currency {
+		s.rateSvc.Record(source.SpaceID, source.Currency, dest.Currency, input.OccurredAt, rate, model.ExchangeRateSourceTransfer)
+	}
+
+	// One edit entry per side, each pointing at the other, so either account's
+	// activity feed shows the change even when only the other side's amount
+	// moved (a new conversion rate).
+	s.auditSvc.Record(TransactionRecordOptions{
+		TransactionID: withdrawal.ID,
+		ActorID:       input.ActorID,
+		Action:        model.TransactionAuditActionEdited,
+		Metadata: map[string]any{
+			"account_id":          withdrawal.AccountID,
+			"transaction_type":    string(withdrawal.Type),
+			"changes":             withdrawalChanges,
+			"transfer_role":       "source",
+			"transfer_pair_id":    deposit.ID,
+			"transfer_other_acct": deposit.AccountID,
+			"transfer_other_name": dest.Name,
+			"conversion_rate":     rate.String(),
+			"dest_amount":         destCur.Fixed(destAmount),
+		},
+	})
+	s.auditSvc.Record(TransactionRecordOptions{
+		TransactionID: deposit.ID,
+		ActorID:       input.ActorID,
+		Action:        model.TransactionAuditActionEdited,
+		Metadata: map[string]any{
+			"account_id":          deposit.AccountID,
+			"transaction_type":    string(deposit.Type),
+			"changes":             depositChanges,
+			"transfer_role":       "destination",
+			"transfer_pair_id":    withdrawal.ID,
+			"transfer_other_acct": withdrawal.AccountID,
+			"transfer_other_name": source.Name,
+			"conversion_rate":     rate.String(),
+			"source_amount":       sourceCur.Fixed(input.Amount),
+		},
+	})
+
+	return &TransferResult{Withdrawal: withdrawal, Deposit: deposit}, nil
+}
+
+// postingCurrency is the currency an edit reposts t in: the one it was first
+// posted in, so editing a transaction recorded before its account changed
+// currency doesn't restate it in the new one, or the account's own for a
+// transaction recorded before the ledger existed.
+func (s *TransactionService) postingCurrency(t *model.Transaction, account *model.Account) (string, error) {
+	posted, err := s.transactionRepo.PostedCurrency(t.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load posted currency: %w", err)
+	}
+	if posted == "" {
+		return account.Currency, nil
+	}
+	return posted, nil
+}
+
+type UndoTransferInput struct {
+	// TransactionID may be either half of the transfer.
+	TransactionID string
+	ActorID       string
+}
+
+// UndoTransfer deletes both halves of a transfer, crediting the source and
+// debiting the destination in one database transaction. Undoing moves money
+// back out of the destination, so it must fit within the destination's
+// available (unallocated) balance, the same rule a transfer follows.
+func (s *TransactionService) UndoTransfer(input UndoTransferInput) (*TransferResult, error) {
+	pair, err := s.GetTransferPair(input.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	withdrawal, deposit := pair.Withdrawal, pair.Deposit
+	if withdrawal.IsReconciled() || deposit.IsReconciled() {
+		return nil, ErrTransactionReconciled
+	}
+
+	source, err := s.accountService.GetAccount(withdrawal.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source account: %w", err)
+	}
+	dest, err := s.accountService.GetAccount(deposit.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load destination account: %w", err)
+	}
+
+	available, limited, err := s.availableToTransfer(dest)
+	if err != nil {
+		return nil, err
+	}
+	if limited && deposit.Value.GreaterThan(available) {
+		return nil, ErrTransferExceedsAvailable
+	}
+
+	if err := s.transactionRepo.UndoTransferAtomic(withdrawal, deposit); err != nil {
+		return nil, fmt.Errorf("failed to undo transfer: %w", err)
+	}
+	sourceCur, destCur := s.accountService.currencyOf(source), s.accountService.currencyOf(dest)
+
+	s.auditSvc.Record(TransactionRecordOptions{
+		TransactionID: withdrawal.ID,
+		ActorID:       input.ActorID,
+		Action:        model.TransactionAuditActionDeleted,
+		Metadata: map[string]any{
+			"account_id":          withdrawal.AccountID,
+			"transaction_type":    string(withdrawal.Type),
+			"title":               withdrawal.Title,
+			"amount":              sourceCur.Fixed(withdrawal.Value),
+			"transfer_role":       "source",
+			"transfer_pair_id":    deposit.ID,
+			"transfer_other_acct": deposit.AccountID,
+			"transfer_other_name": dest.Name,
+		},
+	})
+	s.auditSvc.Record(TransactionRecordOptions{
+		TransactionID: deposit.ID,
+		ActorID:       input.ActorID,
+		Action:        model.TransactionAuditActionDeleted,
+		Metadata: map[string]any{
+			"account_id":          deposit.AccountID,
+			"transaction_type":    string(deposit.Type),
+			"title":               deposit.Title,
+			"amount":              destCur.Fixed(deposit.Value),
+			"transfer_role":       "destination",
+			"transfer_pair_id":    withdrawal.ID,
+			"transfer_other_acct": withdrawal.AccountID,
+			"transfer_other_name": source.Name,
+		},
+	})
+
+	return pair, nil
+}
+
 type UpdateBillInput struct {
 	TransactionID string
 	Title         string
@@ -401,7 +791,12 @@ type UpdateBillInput struct {
 	OccurredAt    time.Time
 	Description   string
 	CategoryID    string
-	ActorID       string
+	// Splits, when given, divides Amount across several categories and takes
+	// precedence over CategoryID. The split amounts must add up to Amount.
+	Splits []model.CategorySplit
+	// TagIDs replaces the transaction's tags; empty clears them.
+	TagIDs  []string
+	ActorID string
 }
 
 func (s *TransactionService) UpdateBill(input UpdateBillInput) (*model.Transaction, error) {
@@ -426,6 +821,9 @@ func (s *TransactionService) UpdateBill(input UpdateBillInput) (*model.Transacti
 	if existing.Type != model.TransactionTypeWithdrawal {
 		return nil, fmt.Errorf("transaction is not a bill")
 	}
+	if existing.IsReconciled() {
+		return nil, ErrTransactionReconciled
+	}
 	if related, err := s.transactionRepo.GetRelatedID(existing.ID); err != nil {
 		return nil, fmt.Errorf("failed to check transfer linkage: %w", err)
 	} else if related != nil {
@@ -437,27 +835,34 @@ func (s *TransactionService) UpdateBill(input UpdateBillInput) (*model.Transacti
 		return nil, fmt.Errorf("failed to load account: %w", err)
 	}
 
-	newBalance := account.Balance.Add(existing.Value).Sub(input.Amount)
-
 	var description *string
 	if d := strings.TrimSpace(input.Description); d != "" {
 		description = &d
 	}
-	var categoryID *string
-	if c := strings.TrimSpace(input.CategoryID); c != "" {
-		categoryID = &c
+	splits, err := s.resolveSplits(input.CategoryID, input.Splits, input.Amount, account.ID)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.validateCategoryForAccount(categoryID, account.ID); err != nil {
+	tags, err := s.validateTagsForSpace(input.TagIDs, account.SpaceID)
+	if err != nil {
 		return nil, err
 	}
 
-	oldCategoryID, _ := s.transactionRepo.GetCategoryID(input.TransactionID)
-	changes := diffTransactionFields(existing, title, input.Amount, input.OccurredAt, description)
-	if !ptrEq(oldCategoryID, categoryID) {
-		changes["category_id"] = map[string]any{
-			"old": ptrOrEmpty(oldCategoryID),
-			"new": ptrOrEmpty(categoryID),
-		}
+	oldSplits, err := s.transactionRepo.GetSplits(input.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category splits: %w", err)
+	}
+	cur := s.accountService.currencyOf(account)
+	changes := diffTransactionFields(existing, title, input.Amount, input.OccurredAt, description, cur)
+	if err := s.diffSplits(changes, oldSplits, splits, account.ID, cur); err != nil {
+		return nil, err
+	}
+	oldTags, err := s.tagRepo.ListByTransaction(input.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	if oldNames, newNames := joinTagNames(oldTags), joinTagNames(tags); oldNames != newNames {
+		changes["tags"] = map[string]any{"old": oldNames, "new": newNames}
 	}
 
 	existing.Value = input.Amount
@@ -466,7 +871,12 @@ func (s *TransactionService) UpdateBill(input UpdateBillInput) (*model.Transacti
 	existing.OccurredAt = input.OccurredAt
 	existing.UpdatedAt = time.Now()
 
-	if err := s.transactionRepo.UpdateBillAtomic(existing, newBalance, categoryID); err != nil {
+	posted, err := s.postingCurrency(existing, account)
+	if err != nil {
+		return nil, err
+	}
+	entry := model.TransactionEntry(account.SpaceID, existing, posted)
+	if err := s.transactionRepo.UpdateBillAtomic(existing, entry, splits, tagIDs(tags)); err != nil {
 		return nil, fmt.Errorf("failed to update bill transaction: %w", err)
 	}
 	if len(changes) > 0 {
@@ -491,7 +901,12 @@ type UpdateDepositInput struct {
 	OccurredAt    time.Time
 	Description   string
 	CategoryID    string
-	ActorID       string
+	// Splits, when given, divides Amount across several categories and takes
+	// precedence over CategoryID. The split amounts must add up to Amount.
+	Splits []model.CategorySplit
+	// TagIDs replaces the transaction's tags; empty clears them.
+	TagIDs  []string
+	ActorID string
 }
 
 func (s *TransactionService) UpdateDeposit(input UpdateDepositInput) (*model.Transaction, error) {
@@ -516,6 +931,9 @@ func (s *TransactionService) UpdateDeposit(input UpdateDepositInput) (*model.Tra
 	if existing.Type != model.TransactionTypeDeposit {
 		return nil, fmt.Errorf("transaction is not a deposit")
 	}
+	if existing.IsReconciled() {
+		return nil, ErrTransactionReconciled
+	}
 	if related, err := s.transactionRepo.GetRelatedID(existing.ID); err != nil {
 		return nil, fmt.Errorf("failed to check transfer linkage: %w", err)
 	} else if related != nil {
@@ -527,36 +945,48 @@ func (s *TransactionService) UpdateDeposit(input UpdateDepositInput) (*model.Tra
 		return nil, fmt.Errorf("failed to load account: %w", err)
 	}
 
-	newBalance := account.Balance.Sub(existing.Value).Add(input.Amount)
-
 	var description *string
 	if d := strings.TrimSpace(input.Description); d != "" {
 		description = &d
 	}
-	var categoryID *string
-	if c := strings.TrimSpace(input.CategoryID); c != "" {
-		categoryID = &c
+	splits, err := s.resolveSplits(input.CategoryID, input.Splits, input.Amount, account.ID)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.validateCategoryForAccount(categoryID, account.ID); err != nil {
+	tags, err := s.validateTagsForSpace(input.TagIDs, account.SpaceID)
+	if err != nil {
 		return nil, err
 	}
 
-	oldCategoryID, _ := s.transactionRepo.GetCategoryID(input.TransactionID)
-	changes := diffTransactionFields(existing, title, input.Amount, input.OccurredAt, description)
-	if !ptrEq(oldCategoryID, categoryID) {
-		changes["category_id"] = map[string]any{
-			"old": ptrOrEmpty(oldCategoryID),
-			"new": ptrOrEmpty(categoryID),
-		}
+	oldSplits, err := s.transactionRepo.GetSplits(input.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category splits: %w", err)
 	}
-
+	cur := s.accountService.currencyOf(account)
+	changes := diffTransactionFields(existing, title, input.Amount, input.OccurredAt, description, cur)
+	if err := s.diffSplits(changes, oldSplits, splits, account.ID, cur); err != nil {
+		return nil, err
+	}
+	oldTags, err := s.tagRepo.ListByTransaction(input.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	if oldNames, newNames := joinTagNames(oldTags), joinTagNames(tags); oldNames != newNames {
+		changes["tags"] = map[string]any{"old": oldNames, "new": newNames}
+	}
+
 	existing.Value = input.Amount
 	existing.Title = title
 	existing.Description = description
 	existing.OccurredAt = input.OccurredAt
 	existing.UpdatedAt = time.Now()
 
-	if err := s.transactionRepo.UpdateDepositAtomic(existing, newBalance, categoryID); err != nil {
+	posted, err := s.postingCurrency(existing, account)
+	if err != nil {
+		return nil, err
+	}
+	entry := model.TransactionEntry(account.SpaceID, existing, posted)
+	if err := s.transactionRepo.UpdateDepositAtomic(existing, entry, splits, tagIDs(tags)); err != nil {
 		return nil, fmt.Errorf("failed to update deposit transaction: %w", err)
 	}
 	if len(changes) > 0 {
@@ -580,9 +1010,10 @@ type DeleteTransactionInput struct {
 }
 
 // DeleteTransaction removes a standalone bill or deposit. Transfers are
-// rejected with ErrTransactionPartOfTransfer — they must be undone via the
-// transfer flow so both halves stay consistent. Deleting a bill credits the
-// account; deleting a deposit debits it.
+// rejected with ErrTransactionPartOfTransfer — they must be undone with
+// UndoTransfer so both halves stay consistent — and reconciled transactions
+// with ErrTransactionReconciled. Deleting a bill credits the account; deleting
+// a deposit debits it.
 func (s *TransactionService) DeleteTransaction(input DeleteTransactionInput) (*model.Transaction, error) {
 	if input.TransactionID == "" {
 		return nil, fmt.Errorf("transaction id is required")
@@ -592,57 +1023,280 @@ func (s *TransactionService) DeleteTransaction(input DeleteTransactionInput) (*m
 	if err != nil {
 		return nil, fmt.Errorf("failed to load transaction: %w", err)
 	}
+	if existing.IsReconciled() {
+		return nil, ErrTransactionReconciled
+	}
 	if related, err := s.transactionRepo.GetRelatedID(existing.ID); err != nil {
 		return nil, fmt.Errorf("failed to check transfer linkage: %w", err)
 	} else if related != nil {
 		return nil, ErrTransactionPartOfTransfer
 	}
 
-	account, err := s.accountService.GetAccount(existing.AccountID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load account: %w", err)
+	if err := s.transactionRepo.DeleteAtomic(existing.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete transaction: %w", err)
 	}
 
-	var newBalance decimal.Decimal
-	switch existing.Type {
-	case model.TransactionTypeWithdrawal:
-		newBalance = account.Balance.Add(existing.Value)
-	case model.TransactionTypeDeposit:
-		newBalance = account.Balance.Sub(existing.Value)
-	default:
-		return nil, fmt.Errorf("unsupported transaction type: %s", existing.Type)
+	s.auditSvc.Record(TransactionRecordOptions{
+		TransactionID: existing.ID,
+		ActorID:       input.ActorID,
+		Action:        model.TransactionAuditActionDeleted,
+		Metadata: map[string]any{
+			"account_id":       existing.AccountID,
+			"transaction_type": string(existing.Type),
+			"title":            existing.Title,
+			"amount":           s.currencyFor(existing.AccountID).Fixed(existing.Value),
+		},
+	})
+
+	return existing, nil
+}
+
+type UnlockTransactionInput struct {
+	TransactionID string
+	ActorID       string
+}
+
+// UnlockTransaction releases a reconciled transaction so it can be edited or
+// deleted again. It drops back to cleared: it was on the statement, but the
+// finalized reconciliation no longer vouches for it.
+func (s *TransactionService) UnlockTransaction(input UnlockTransactionInput) (*model.Transaction, error) {
+	if input.TransactionID == "" {
+		return nil, fmt.Errorf("transaction id is required")
 	}
 
-	if err := s.transactionRepo.DeleteAtomic(existing.ID, existing.AccountID, newBalance); err != nil {
-		return nil, fmt.Errorf("failed to delete transaction: %w", err)
+	existing, err := s.transactionRepo.GetByID(input.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transaction: %w", err)
+	}
+	if !existing.IsReconciled() {
+		return existing, nil
+	}
+
+	if err := s.transactionRepo.SetStatus(existing.ID, model.TransactionStatusCleared); err != nil {
+		return nil, fmt.Errorf("failed to unlock transaction: %w", err)
 	}
 
 	s.auditSvc.Record(TransactionRecordOptions{
 		TransactionID: existing.ID,
 		ActorID:       input.ActorID,
-		Action:        model.TransactionAuditActionDeleted,
+		Action:        model.TransactionAuditActionEdited,
 		Metadata: map[string]any{
 			"account_id":       existing.AccountID,
 			"transaction_type": string(existing.Type),
-			"title":            existing.Title,
-			"amount":           existing.Value.StringFixedBank(2),
+			"changes": map[string]any{
+				"status": map[string]any{
+					"old": string(model.TransactionStatusReconciled),
+					"new": string(model.TransactionStatusCleared),
+				},
+			},
 		},
 	})
 
+	existing.Status = model.TransactionStatusCleared
 	return existing, nil
 }
 
+type ImportTransactionsInput struct {
+	AccountID string
+	ActorID   string
+	Source    model.ImportSource
+	Filename  string
+	// StatementBalance is the closing balance the statement reported, if any.
+	StatementBalance     *decimal.Decimal
+	StatementBalanceAsOf *time.Time
+	Rows                 []ImportRow
+}
+
+// ImportTransactions creates one bill or deposit per row as a single import
+// batch. Rows go through the same category check, categorization rules and
+// audit trail as manual entry; the account balance is written once for the net
+// of the batch.
+func (s *TransactionService) ImportTransactions(input ImportTransactionsInput) (*model.ImportBatch, error) {
+	if input.AccountID == "" {
+		return nil, fmt.Errorf("account id is required")
+	}
+	if len(input.Rows) == 0 {
+		return nil, ErrImportNoRows
+	}
+
+	account, err := s.accountService.GetAccount(input.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	if account.IsArchived() {
+		return nil, ErrAccountArchived
+	}
+
+	rules, err := s.rulesSvc.RuleSet(account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	checked := map[string]bool{}
+	for _, r := range input.Rows {
+		if r.CategoryID == nil || checked[*r.CategoryID] {
+			continue
+		}
+		if err := s.validateCategoryForAccount(r.CategoryID, account.ID); err != nil {
+			return nil, err
+		}
+		checked[*r.CategoryID] = true
+	}
+
+	now := time.Now()
+	cur := s.accountService.currencyOf(account)
+	batch := &model.ImportBatch{
+		ID:                   uuid.NewString(),
+		AccountID:            account.ID,
+		Source:               input.Source,
+		Filename:             strings.TrimSpace(input.Filename),
+		RowCount:             len(input.Rows),
+		CreatedAt:            now,
+		StatementBalance:     input.StatementBalance,
+		StatementBalanceAsOf: input.StatementBalanceAsOf,
+	}
+	if input.ActorID != "" {
+		batch.ActorID = &input.ActorID
+	}
+
+	imported := make([]repository.ImportedTransaction, 0, len(input.Rows))
+	matches := make([]*RuleMatch, 0, len(input.Rows))
+	for _, r := range input.Rows {
+		if !r.Amount.IsPositive() {
+			return nil, fmt.Errorf("amount must be greater than zero")
+		}
+		var description *string
+		if d := strings.TrimSpace(r.Description); d != "" {
+			description = &d
+		}
+		title := r.Title
+		categoryID := r.CategoryID
+		var match *RuleMatch
+		if categoryID == nil || *categoryID == "" {
+			if match = rules.Match(r.Title, r.Amount, r.Type); match != nil {
+				title = match.Title
+				categoryID = &match.Rule.CategoryID
+			}
+		}
+		txn := &model.Transaction{
+			ID:          uuid.NewString(),
+			Value:       r.Amount,
+			Type:        r.Type,
+			AccountID:   account.ID,
+			Title:       title,
+			Description: description,
+			Status:      model.TransactionStatusPending,
+			OccurredAt:  r.OccurredAt,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if r.Type != model.TransactionTypeDeposit && r.Type != model.TransactionTypeWithdrawal {
+			return nil, fmt.Errorf("unsupported transaction type: %s", r.Type)
+		}
+		it := repository.ImportedTransaction{
+			Transaction: txn,
+			Entry:       model.TransactionEntry(account.SpaceID, txn, account.Currency),
+			CategoryID:  categoryID,
+		}
+		if r.FITID != "" {
+			fitid := r.FITID
+			it.FITID = &fitid
+		}
+		imported = append(imported, it)
+		matches = append(matches, match)
+	}
+	if err := s.transactionRepo.ImportAtomic(batch, imported); err != nil {
+		return nil, fmt.Errorf("failed to import transactions: %w", err)
+	}
+
+	for i, it := range imported {
+		txn := it.Transaction
+		metadata := withRuleMatch(map[string]any{
+			"account_id":       txn.AccountID,
+			"transaction_type": string(txn.Type),
+			"title":            txn.Title,
+			"amount":           cur.Fixed(txn.Value),
+			"import_batch_id":  batch.ID,
+			"import_source":    string(batch.Source),
+		}, matches[i], input.Rows[i].Title)
+		if it.FITID != nil {
+			metadata["fitid"] = *it.FITID
+		}
+		s.auditSvc.Record(TransactionRecordOptions{
+			TransactionID: txn.ID,
+			ActorID:       input.ActorID,
+			Action:        model.TransactionAuditActionCreated,
+			Metadata:      metadata,
+		})
+	}
+
+	return batch, nil
+}
+
+// RollbackImport deletes every transaction the batch created that still
+// exists and reverses their effect on the balance in one step. Transactions
+// edited since the import are reversed at their current value. A batch with
+// reconciled transactions is rejected with ErrTransactionReconciled. Returns
+// the number of transactions removed.
+func (s *TransactionService) RollbackImport(batch *model.ImportBatch, actorID string) (int, error) {
+	if batch.IsRolledBack() {
+		return 0, ErrImportBatchRolledBack
+	}
+
+	txns, err := s.transactionRepo.ListByImportBatch(batch.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list batch transactions: %w", err)
+	}
+	for _, t := range txns {
+		if t.IsReconciled() {
+			return 0, ErrTransactionReconciled
+		}
+	}
+
+	if err := s.transactionRepo.RollbackImportAtomic(batch.ID, batch.AccountID, time.Now()); err != nil {
+		return 0, fmt.Errorf("failed to roll back import: %w", err)
+	}
+
+	cur := s.currencyFor(batch.AccountID)
+	for _, t := range txns {
+		s.auditSvc.Record(TransactionRecordOptions{
+			TransactionID: t.ID,
+			ActorID:       actorID,
+			Action:        model.TransactionAuditActionDeleted,
+			Metadata: map[string]any{
+				"account_id":       t.AccountID,
+				"transaction_type": string(t.Type),
+				"title":            t.Title,
+				"amount":           cur.Fixed(t.Value),
+				"import_batch_id":  batch.ID,
+			},
+		})
+	}
+
+	return len(txns), nil
+}
+
+// currencyFor returns the currency of the account with the given id, or
+// plain two decimals if the account can't be loaded.
+func (s *TransactionService) currencyFor(accountID string) currency.Currency {
+	account, err := s.accountService.GetAccount(accountID)
+	if err != nil {
+		return currency.Currency{MinorUnits: 2}
+	}
+	return s.accountService.currencyOf(account)
+}
+
 // diffTransactionFields returns a map of field name to {old, new} for fields whose
 // new value differs from the existing transaction.
-func diffTransactionFields(existing *model.Transaction, newTitle string, newAmount decimal.Decimal, newOccurredAt time.Time, newDescription *string) map[string]any {
+func diffTransactionFields(existing *model.Transaction, newTitle string, newAmount decimal.Decimal, newOccurredAt time.Time, newDescription *string, cur currency.Currency) map[string]any {
 	changes := map[string]any{}
 	if existing.Title != newTitle {
 		changes["title"] = map[string]any{"old": existing.Title, "new": newTitle}
 	}
 	if !existing.Value.Equal(newAmount) {
 		changes["amount"] = map[string]any{
-			"old": existing.Value.StringFixedBank(2),
-			"new": newAmount.StringFixedBank(2),
+			"old": cur.Fixed(existing.Value),
+			"new": cur.Fixed(newAmount),
 		}
 	}
 	if !existing.OccurredAt.Equal(newOccurredAt) {
@@ -670,10 +1324,6 @@ func ptrStringEq(a, b *string) bool {
 	return *a == *b
 }
 
-func ptrEq(a, b *string) bool {
-	return ptrStringEq(a, b)
-}
-
 func ptrOrEmpty(p *string) string {
 	if p == nil {
 		return ""
@@ -700,6 +1350,16 @@ func (s *TransactionService) GetTransactionCategoryID(transactionID string) (str
 	return *id, nil
 }
 
+// GetTransactionSplits returns the transaction's category splits, largest
+// first. An uncategorized transaction has none.
+func (s *TransactionService) GetTransactionSplits(transactionID string) ([]model.CategorySplit, error) {
+	splits, err := s.transactionRepo.GetSplits(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load category splits: %w", err)
+	}
+	return splits, nil
+}
+
 func (s *TransactionService) ListByAccount(accountID string, limit, offset int) ([]*model.Transaction, error) {
 	if limit <= 0 {
 		limit = 25
@@ -722,6 +1382,128 @@ func (s *TransactionService) CountByAccount(accountID string) (int, error) {
 	return count, nil
 }
 
+// BalanceAsOf returns what the account's balance was at the given time: the
+// sum of every transaction that occurred at or before it, including ones
+// entered later with an earlier date. Transfer halves count at the amount
+// that landed in this account, after any conversion.
+func (s *TransactionService) BalanceAsOf(accountID string, at time.Time) (decimal.Decimal, error) {
+	balance, err := s.transactionRepo.BalanceAsOf(accountID, at)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to compute balance: %w", err)
+	}
+	return balance, nil
+}
+
+// availableToTransfer is how much a transfer may take out of the account:
+// the unallocated balance of a cash account, or the unused credit of a
+// liability. limited is false when nothing caps it, which is the case for a
+// liability without a credit limit or when no allocation service is wired.
+func (s *TransactionService) availableToTransfer(account *model.Account) (available decimal.Decimal, limited bool, err error) {
+	if account.IsLiability() {
+		if credit := account.AvailableCredit(); credit != nil {
+			return *credit, true, nil
+		}
+		return decimal.Zero, false, nil
+	}
+	if s.allocationService == nil {
+		return decimal.Zero, false, nil
+	}
+	summary, err := s.allocationService.SummaryForAccount(account.ID)
+	if err != nil {
+		return decimal.Zero, false, fmt.Errorf("failed to load allocations: %w", err)
+	}
+	return summary.Available, true, nil
+}
+
+// StatementCycles returns the billing cycles of a liability account with a
+// statement closing day, newest first. The first is the open cycle, closing
+// on or after now, with what has been charged and paid so far; the rest are
+// the count cycles that closed before it. The opening balance of each cycle
+// is the statement balance of the one before, and backdated entries move the
+// balances of the cycles they fall in.
+func (s *TransactionService) StatementCycles(account *model.Account, now time.Time, count int) ([]*model.StatementCycle, error) {
+	if !account.IsLiability() || account.StatementClosingDay == nil {
+		return nil, fmt.Errorf("account has no statement cycle")
+	}
+	closingDay := *account.StatementClosingDay
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	closing := dayOfMonth(today.Year(), today.Month(), closingDay)
+	if closing.Before(today) {
+		closing = dayOfMonth(today.Year(), today.Month()+1, closingDay)
+	}
+
+	// Walk back from the open cycle; each cycle's opening balance is the
+	// owed amount at the end of the previous closing date.
+	cycles := make([]*model.StatementCycle, 0, count+1)
+	owedAtClose, err := s.owedAsOf(account.ID, closing)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i <= count; i++ {
+		prev := dayOfMonth(closing.Year(), closing.Month()-1, closingDay)
+		opening, err := s.owedAsOf(account.ID, prev)
+		if err != nil {
+			return nil, err
+		}
+		charges, err := s.transactionRepo.SumByAccountTypeBetween(account.ID, model.TransactionTypeWithdrawal, endOfDay(prev), endOfDay(closing))
+		if err != nil {
+			return nil, fmt.Errorf("failed to total charges: %w", err)
+		}
+		cycle := &model.StatementCycle{
+			Start:            prev.AddDate(0, 0, 1),
+			ClosingDate:      closing,
+			OpeningBalance:   opening,
+			Charges:          charges,
+			Payments:         opening.Add(charges).Sub(owedAtClose),
+			StatementBalance: owedAtClose,
+		}
+		if account.PaymentDueDay != nil {
+			due := paymentDueDate(closing, closingDay, *account.PaymentDueDay)
+			cycle.DueDate = &due
+		}
+		cycles = append(cycles, cycle)
+		closing, owedAtClose = prev, opening
+	}
+	return cycles, nil
+}
+
+// owedAsOf is what was owed on a liability account at the end of day.
+func (s *TransactionService) owedAsOf(accountID string, day time.Time) (decimal.Decimal, error) {
+	balance, err := s.BalanceAsOf(accountID, endOfDay(day))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return balance.Neg(), nil
+}
+
+// dayOfMonth returns the given day of a month, clamped to the month's last
+// day so a closing day of 31 falls on Feb 28. month may be out of range and
+// is normalized like time.Date does.
+func dayOfMonth(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// paymentDueDate is the due date of the statement closing on closing. A due
+// day after the closing day falls in the same month; otherwise it falls in
+// the next.
+func paymentDueDate(closing time.Time, closingDay, dueDay int) time.Time {
+	if dueDay > closingDay {
+		return dayOfMonth(closing.Year(), closing.Month(), dueDay)
+	}
+	return dayOfMonth(closing.Year(), closing.Month()+1, dueDay)
+}
+
+// endOfDay is the last instant of day, matching how date filters include a
+// whole day.
+func endOfDay(day time.Time) time.Time {
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
 func (s *TransactionService) ListByAccountFiltered(accountID string, filter model.TransactionFilter, limit, offset int) ([]*model.Transaction, error) {
 	if limit <= 0 {
 		limit = 25
@@ -761,6 +1543,189 @@ func (s *TransactionService) validateCategoryForAccount(categoryID *string, acco
 	return nil
 }
 
+// resolveSplits turns a transaction's category input into the splits to store.
+// Without explicit splits, a non-empty categoryID becomes a single split for
+// the whole amount. Explicit splits need distinct categories from the account,
+// positive amounts, and a sum equal to amount.
+func (s *TransactionService) resolveSplits(categoryID string, splits []model.CategorySplit, amount decimal.Decimal, accountID string) ([]model.CategorySplit, error) {
+	if len(splits) == 0 {
+		c := strings.TrimSpace(categoryID)
+		if c == "" {
+			return nil, nil
+		}
+		if err := s.validateCategoryForAccount(&c, accountID); err != nil {
+			return nil, err
+		}
+		return []model.CategorySplit{{CategoryID: c, Amount: amount}}, nil
+	}
+
+	cur := s.currencyFor(accountID)
+	out := make([]model.CategorySplit, 0, len(splits))
+	seen := make(map[string]bool, len(splits))
+	sum := decimal.Zero
+	for _, split := range splits {
+		c := strings.TrimSpace(split.CategoryID)
+		if c == "" || seen[c] {
+			return nil, fmt.Errorf("each split needs a different category")
+		}
+		if !split.Amount.IsPositive() {
+			return nil, fmt.Errorf("split amounts must be greater than zero")
+		}
+		if !cur.Fits(split.Amount) {
+			return nil, fmt.Errorf("split amounts can have at most %d decimal places", cur.MinorUnits)
+		}
+		if err := s.validateCategoryForAccount(&c, accountID); err != nil {
+			return nil, err
+		}
+		seen[c] = true
+		sum = sum.Add(split.Amount)
+		out = append(out, model.CategorySplit{CategoryID: c, Amount: split.Amount})
+	}
+	if !sum.Equal(amount) {
+		return nil, ErrSplitsDoNotSum
+	}
+	return out, nil
+}
+
+// diffSplits adds an edit's category change to its audit diff. While neither
+// side is split the change stays a plain category_id diff; once either side
+// is, both are recorded as "Name amount" lists.
+func (s *TransactionService) diffSplits(changes map[string]any, oldSplits, newSplits []model.CategorySplit, accountID string, cur currency.Currency) error {
+	if len(oldSplits) <= 1 && len(newSplits) <= 1 {
+		oldID, newID := "", ""
+		if len(oldSplits) == 1 {
+			oldID = oldSplits[0].CategoryID
+		}
+		if len(newSplits) == 1 {
+			newID = newSplits[0].CategoryID
+		}
+		if oldID != newID {
+			changes["category_id"] = map[string]any{"old": oldID, "new": newID}
+		}
+		return nil
+	}
+
+	cats, err := s.categoryRepo.ListByAccount(accountID)
+	if err != nil {
+		return fmt.Errorf("failed to load categories: %w", err)
+	}
+	nameByID := make(map[string]string, len(cats))
+	for _, c := range cats {
+		nameByID[c.ID] = c.Name
+	}
+	oldStr, newStr := formatSplits(oldSplits, nameByID, cur), formatSplits(newSplits, nameByID, cur)
+	if oldStr != newStr {
+		changes["splits"] = map[string]any{"old": oldStr, "new": newStr}
+	}
+	return nil
+}
+
+// formatSplits renders splits for the audit log, largest first so the same
+// set always compares equal.
+func formatSplits(splits []model.CategorySplit, nameByID map[string]string, cur currency.Currency) string {
+	sorted := append([]model.CategorySplit(nil), splits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Amount.Equal(sorted[j].Amount) {
+			return sorted[i].Amount.GreaterThan(sorted[j].Amount)
+		}
+		return sorted[i].CategoryID < sorted[j].CategoryID
+	})
+	parts := make([]string, len(sorted))
+	for i, split := range sorted {
+		name, ok := nameByID[split.CategoryID]
+		if !ok {
+			name = "Unknown"
+		}
+		parts[i] = fmt.Sprintf("%s %s", name, cur.Fixed(split.Amount))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// validateTagsForSpace loads the given tags, dropping duplicates, and ensures
+// every one exists in the space. Tags are space-wide, so a transaction on any
+// of the space's accounts may carry them.
+func (s *TransactionService) validateTagsForSpace(ids []string, spaceID string) ([]*model.Tag, error) {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+	tags, err := s.tagRepo.ByIDs(unique)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	if len(tags) != len(unique) {
+		return nil, fmt.Errorf("invalid tag")
+	}
+	for _, t := range tags {
+		if t.SpaceID != spaceID {
+			return nil, fmt.Errorf("invalid tag")
+		}
+	}
+	return tags, nil
+}
+
+func tagIDs(tags []*model.Tag) []string {
+	ids := make([]string, len(tags))
+	for i, t := range tags {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// joinTagNames renders a tag set for the audit log. Names are sorted so the
+// same set always compares equal.
+func joinTagNames(tags []*model.Tag) string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// withTagNames adds the tag names to a created-entry's audit metadata when
+// there are any.
+// matchRule looks up the categorization rule for a new transaction entered
+// without a category. Returns nil when a category was given or no rule matches.
+func (s *TransactionService) matchRule(accountID, categoryID, title string, amount decimal.Decimal, txType model.TransactionType) (*RuleMatch, error) {
+	if strings.TrimSpace(categoryID) != "" {
+		return nil, nil
+	}
+	set, err := s.rulesSvc.RuleSet(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return set.Match(title, amount, txType), nil
+}
+
+// withRuleMatch notes in created-transaction audit metadata that a rule picked
+// the category, so automatic categorization can be told apart from manual.
+func withRuleMatch(meta map[string]any, m *RuleMatch, originalTitle string) map[string]any {
+	if m == nil {
+		return meta
+	}
+	meta["categorization_rule_id"] = m.Rule.ID
+	meta["category_id"] = m.Rule.CategoryID
+	if m.Title != originalTitle {
+		meta["original_title"] = originalTitle
+	}
+	return meta
+}
+
+func withTagNames(meta map[string]any, tags []*model.Tag) map[string]any {
+	if len(tags) > 0 {
+		meta["tags"] = joinTagNames(tags)
+	}
+	return meta
+}
+
 // maxSeriesBuckets caps how many time buckets a single report can span, a guard
 // against a pathological range (e.g. daily granularity over centuries) building
 // an enormous, unreadable chart.
@@ -776,6 +1741,18 @@ type CategorySeriesInput struct {
 	To                   time.Time
 	Granularity          string // "day", "month", or "year"
 	IncludeUncategorized bool
+	// Currency converts the totals into another currency, each day's
+	// transactions at the rate effective that day. Empty keeps the account's
+	// own currency.
+	Currency string
+	// RollUp reports each category under its top-level ancestor instead of
+	// on its own.
+	RollUp bool
+	// ParentID drills into one category: each of its children becomes a
+	// series with that child's own subcategories rolled in, and transactions
+	// tagged with the parent itself get a series of their own. Anything
+	// outside the category is left out. RollUp is ignored.
+	ParentID string
 }
 
 // CategoryTimeSeries aggregates an account's transactions into a stacked
@@ -802,9 +1779,20 @@ func (s *TransactionService) CategoryTimeSeries(in CategorySeriesInput) (*model.
 		indexByKey[bucketKey(b, in.Granularity)] = i
 	}
 
-	rows, err := s.transactionRepo.SumByCategoryBucket(in.AccountID, in.Type, in.From, in.To, in.Granularity, in.IncludeUncategorized)
-	if err != nil {
-		return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+	var (
+		rows []repository.CategoryBucketRow
+		err  error
+	)
+	if in.Currency != "" {
+		rows, err = s.convertedCategoryRows(in)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rows, err = s.transactionRepo.SumByCategoryBucket(in.AccountID, in.Type, in.From, in.To, in.Granularity, in.IncludeUncategorized)
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+		}
 	}
 
 	cats, err := s.categoryRepo.ListByAccount(in.AccountID)
@@ -812,8 +1800,41 @@ func (s *TransactionService) CategoryTimeSeries(in CategorySeriesInput) (*model.
 		return nil, fmt.Errorf("failed to load categories: %w", err)
 	}
 	nameByID := make(map[string]string, len(cats))
+	hasChildren := map[string]bool{}
 	for _, c := range cats {
 		nameByID[c.ID] = c.Name
+		if c.ParentID != nil {
+			hasChildren[*c.ParentID] = true
+		}
+	}
+	if _, ok := nameByID[in.ParentID]; in.ParentID != "" && !ok {
+		return nil, ErrCategoryNotFound
+	}
+
+	// seriesFor maps a row's category onto the category whose series it
+	// counts toward, or false when it falls outside a drill-down.
+	ancestors := model.CategoryAncestors(cats)
+	seriesFor := func(catID string) (string, bool) {
+		if in.ParentID != "" {
+			if catID == in.ParentID {
+				return catID, true
+			}
+			path := ancestors[catID]
+			for i, id := range path {
+				if id != in.ParentID {
+					continue
+				}
+				if i == 0 {
+					return catID, true
+				}
+				return path[i-1], true
+			}
+			return "", false
+		}
+		if path := ancestors[catID]; in.RollUp && len(path) > 0 {
+			return path[len(path)-1], true
+		}
+		return catID, true
 	}
 
 	// Accumulate values per series (keyed by category ID; "" = uncategorized),
@@ -830,6 +1851,10 @@ func (s *TransactionService) CategoryTimeSeries(in CategorySeriesInput) (*model.
 		if row.CategoryID != nil {
 			catID = *row.CategoryID
 		}
+		catID, ok = seriesFor(catID)
+		if !ok {
+			continue
+		}
 		series, exists := byKey[catID]
 		if !exists {
 			name := "Uncategorized"
@@ -840,11 +1865,16 @@ func (s *TransactionService) CategoryTimeSeries(in CategorySeriesInput) (*model.
 					name = "Unknown"
 				}
 			}
+			drillable := hasChildren[catID]
+			if catID != "" && catID == in.ParentID {
+				name += " (not in a subcategory)"
+				drillable = false
+			}
 			values := make([]decimal.Decimal, len(buckets))
 			for i := range values {
 				values[i] = decimal.Zero
 			}
-			series = &model.CategorySeriesData{CategoryID: catID, CategoryName: name, Values: values}
+			series = &model.CategorySeriesData{CategoryID: catID, CategoryName: name, HasChildren: drillable, Values: values}
 			byKey[catID] = series
 			order = append(order, catID)
 		}
@@ -853,7 +1883,7 @@ func (s *TransactionService) CategoryTimeSeries(in CategorySeriesInput) (*model.
 		grand = grand.Add(row.Total)
 	}
 
-	result := &model.CategoryTimeSeries{Buckets: buckets, Total: grand}
+	result := &model.CategoryTimeSeries{Buckets: buckets, Total: grand, Currency: in.Currency}
 	for _, k := range order {
 		result.Series = append(result.Series, *byKey[k])
 	}
@@ -863,6 +1893,206 @@ func (s *TransactionService) CategoryTimeSeries(in CategorySeriesInput) (*model.
 	return result, nil
 }
 
+// SpaceCategorySeriesInput parameterizes a category-over-time report across
+// every account in a space.
+type SpaceCategorySeriesInput struct {
+	SpaceID              string
+	Type                 model.TransactionType
+	From                 time.Time
+	To                   time.Time
+	Granularity          string
+	IncludeUncategorized bool
+	// Currency is what every account's totals are converted into, each day's
+	// transactions at the rate effective that day.
+	Currency string
+	// RollUp reports each category under its top-level ancestor.
+	RollUp bool
+}
+
+// SpaceCategoryTimeSeries is CategoryTimeSeries across a space's accounts.
+// Each account keeps its own categories, so they are matched by name,
+// compared case-insensitively: "Food" in one account and "food" in another
+// make one series, keyed by the lowercase name. Accounts without a rate into
+// in.Currency are left out and listed in Skipped.
+func (s *TransactionService) SpaceCategoryTimeSeries(in SpaceCategorySeriesInput) (*model.CategoryTimeSeries, error) {
+	if in.SpaceID == "" {
+		return nil, fmt.Errorf("space id is required")
+	}
+	if in.Currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
+	if !validSeriesGranularities[in.Granularity] {
+		return nil, fmt.Errorf("invalid granularity")
+	}
+	if in.To.Before(in.From) {
+		return nil, fmt.Errorf("end date must be on or after start date")
+	}
+
+	buckets := generateBuckets(in.From, in.To, in.Granularity)
+	if len(buckets) > maxSeriesBuckets {
+		return nil, fmt.Errorf("date range is too large for %s granularity", in.Granularity)
+	}
+	indexByKey := make(map[string]int, len(buckets))
+	for i, b := range buckets {
+		indexByKey[bucketKey(b, in.Granularity)] = i
+	}
+
+	accounts, err := s.accountService.GetAccountsForSpace(in.SpaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.CategoryTimeSeries{Buckets: buckets, Total: decimal.Zero, Currency: in.Currency}
+	byKey := map[string]*model.CategorySeriesData{}
+	order := []string{}
+	for _, account := range accounts {
+		rows, err := s.convertedCategoryRows(CategorySeriesInput{
+			AccountID:            account.ID,
+			Type:                 in.Type,
+			From:                 in.From,
+			To:                   in.To,
+			IncludeUncategorized: in.IncludeUncategorized,
+			Currency:             in.Currency,
+		})
+		if errors.Is(err, ErrNoExchangeRate) {
+			result.Skipped = append(result.Skipped, account.Name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		cats, err := s.categoryRepo.ListByAccount(account.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load categories: %w", err)
+		}
+		nameByID := make(map[string]string, len(cats))
+		for _, c := range cats {
+			nameByID[c.ID] = strings.TrimSpace(c.Name)
+		}
+		ancestors := model.CategoryAncestors(cats)
+
+		for _, row := range rows {
+			idx, ok := indexByKey[bucketKey(row.Bucket, in.Granularity)]
+			if !ok {
+				continue
+			}
+			key, name := "", "Uncategorized"
+			if row.CategoryID != nil {
+				catID := *row.CategoryID
+				if path := ancestors[catID]; in.RollUp && len(path) > 0 {
+					catID = path[len(path)-1]
+				}
+				name = "Unknown"
+				if n, ok := nameByID[catID]; ok {
+					name = n
+				}
+				key = strings.ToLower(name)
+			}
+			series, exists := byKey[key]
+			if !exists {
+				values := make([]decimal.Decimal, len(buckets))
+				for i := range values {
+					values[i] = decimal.Zero
+				}
+				series = &model.CategorySeriesData{CategoryID: key, CategoryName: name, Values: values}
+				byKey[key] = series
+				order = append(order, key)
+			}
+			series.Values[idx] = series.Values[idx].Add(row.Total)
+			series.Total = series.Total.Add(row.Total)
+			result.Total = result.Total.Add(row.Total)
+		}
+	}
+
+	for _, k := range order {
+		result.Series = append(result.Series, *byKey[k])
+	}
+	sort.SliceStable(result.Series, func(i, j int) bool {
+		return result.Series[i].Total.GreaterThan(result.Series[j].Total)
+	})
+	return result, nil
+}
+
+// convertedCategoryRows totals the account's transactions per day,
+// category and posted currency, converts each day at its own rate into
+// in.Currency, and leaves the rows for CategoryTimeSeries to gather into its
+// buckets. Transactions recorded before the account changed currency are
+// converted from the currency they were posted in.
+func (s *TransactionService) convertedCategoryRows(in CategorySeriesInput) ([]repository.CategoryBucketRow, error) {
+	account, err := s.accountService.GetAccount(in.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account: %w", err)
+	}
+	rows, err := s.transactionRepo.SumByCategoryBucket(in.AccountID, in.Type, in.From, in.To, "day", in.IncludeUncategorized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+	}
+	posted := make([]string, len(rows))
+	for i, row := range rows {
+		posted[i] = row.Currency
+	}
+	table, err := s.postedRates(account.SpaceID, in.Currency, posted)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Total, _ = table.Convert(rows[i].Total, rows[i].Currency, in.Currency, rows[i].Bucket)
+		rows[i].Currency = in.Currency
+	}
+	return rows, nil
+}
+
+// convertedTransferRows is SumTransfersByCounterpart by day for one
+// account, converted into the given currency at each day's rate from the
+// currency each half was posted in.
+func (s *TransactionService) convertedTransferRows(account *model.Account, from, to time.Time, currency string) ([]repository.TransferBucketRow, error) {
+	rows, err := s.transactionRepo.SumTransfersByCounterpart(account.ID, from, to, "day")
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate transfers: %w", err)
+	}
+	posted := make([]string, len(rows))
+	for i, row := range rows {
+		posted[i] = row.Currency
+	}
+	table, err := s.postedRates(account.SpaceID, currency, posted)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Total, _ = table.Convert(rows[i].Total, rows[i].Currency, currency, rows[i].Bucket)
+		rows[i].Currency = currency
+	}
+	return rows, nil
+}
+
+// postedRates loads the rates that convert amounts posted in any of the
+// given currencies into target, failing with ErrNoExchangeRate when one of
+// them has none.
+func (s *TransactionService) postedRates(spaceID, target string, posted []string) (*RateTable, error) {
+	currencies := []string{target}
+	for _, c := range posted {
+		if !slices.Contains(currencies, c) {
+			currencies = append(currencies, c)
+		}
+	}
+	if len(currencies) == 1 {
+		return NewRateTable(nil), nil
+	}
+	if s.rateSvc == nil {
+		return nil, ErrNoExchangeRate
+	}
+	table, err := s.rateSvc.Table(spaceID, currencies...)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range currencies[1:] {
+		if !table.Has(c, target) {
+			return nil, ErrNoExchangeRate
+		}
+	}
+	return table, nil
+}
+
 // bucketKey returns a canonical string for the period containing t at the given
 // granularity, used to align DB-returned buckets with the generated axis without
 // relying on time.Time equality across locations.
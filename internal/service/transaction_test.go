@@ -2,12 +2,14 @@ package service
 
 import (
 	"encoding/json"
+	"sync"
 	"testing"
 	"time"
 
 	"git.juancwu.dev/juancwu/budgit/internal/model"
 	"git.juancwu.dev/juancwu/budgit/internal/repository"
 	"git.juancwu.dev/juancwu/budgit/internal/testutil"
+	"github.com/jmoiron/sqlx"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,24 +27,32 @@ type txnFixture struct {
 
 func newTxnFixture(t *testing.T, dbi testutil.DBInfo) *txnFixture {
 	t.Helper()
+	return newTxnFixtureOn(t, dbi.DB)
+}
+
+// newTxnFixtureOn is newTxnFixture over an arbitrary handle, such as a
+// multi-connection pool from testutil.DBInfo.Pool.
+func newTxnFixtureOn(t *testing.T, db *sqlx.DB) *txnFixture {
+	t.Helper()
 
-	txnRepo := repository.NewTransactionRepository(dbi.DB)
-	categoryRepo := repository.NewCategoryRepository(dbi.DB)
-	accountRepo := repository.NewAccountRepository(dbi.DB)
-	allocationRepo := repository.NewAllocationRepository(dbi.DB)
-	auditRepo := repository.NewTransactionAuditLogRepository(dbi.DB)
+	txnRepo := repository.NewTransactionRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	tagRepo := repository.NewTagRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
+	allocationRepo := repository.NewAllocationRepository(db)
+	auditRepo := repository.NewTransactionAuditLogRepository(db)
 
 	accountSvc := NewAccountService(accountRepo)
 	accountSvc.SetAllocationRepository(allocationRepo)
 	allocationSvc := NewAllocationService(allocationRepo, accountSvc)
 	auditSvc := NewTransactionAuditLogService(auditRepo)
-	svc := NewTransactionService(txnRepo, categoryRepo, accountSvc)
+	svc := NewTransactionService(txnRepo, categoryRepo, tagRepo, accountSvc)
 	svc.SetAuditLogger(auditSvc)
 	svc.SetAllocationService(allocationSvc)
 
-	user := testutil.CreateTestUser(t, dbi.DB, t.Name()+"@example.com", nil)
-	space := testutil.CreateTestSpace(t, dbi.DB, user.ID, "S")
-	account := testutil.CreateTestAccount(t, dbi.DB, space.ID, "Acct")
+	user := testutil.CreateTestUser(t, db, t.Name()+"@example.com", nil)
+	space := testutil.CreateTestSpace(t, db, user.ID, "S")
+	account := testutil.CreateTestAccount(t, db, space.ID, "Acct")
 
 	return &txnFixture{
 		svc:      svc,
@@ -846,3 +856,348 @@ func TestTransactionService_Deposit_RejectsForeignCategory(t *testing.T) {
 		assert.Error(t, err, "a category from another account must be rejected")
 	})
 }
+
+func TestTransactionService_UpdateBill_SplitsAcrossCategories(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+
+		groceries := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Groceries")
+		household := testutil.CreateTestCategory(t, dbi.DB, f.account.ID, "Household")
+
+		_, err := f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "seed", Amount: decimal.NewFromInt(200), OccurredAt: time.Now(), ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		bill, err := f.svc.PayBill(PayBillInput{
+			AccountID: f.account.ID, Title: "Costco", Amount: decimal.NewFromInt(60), OccurredAt: time.Now(), CategoryID: groceries.ID, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		// An untouched single category reads back as one split for the whole value.
+		splits, err := f.svc.GetTransactionSplits(bill.ID)
+		require.NoError(t, err)
+		require.Len(t, splits, 1)
+		assert.Equal(t, groceries.ID, splits[0].CategoryID)
+		assert.True(t, decimal.NewFromInt(60).Equal(splits[0].Amount))
+
+		// Splits that don't add up to the amount are rejected.
+		_, err = f.svc.UpdateBill(UpdateBillInput{
+			TransactionID: bill.ID,
+			Title:         "Costco",
+			Amount:        decimal.NewFromInt(60),
+			OccurredAt:    bill.OccurredAt,
+			Splits: []model.CategorySplit{
+				{CategoryID: groceries.ID, Amount: decimal.NewFromInt(40)},
+				{CategoryID: household.ID, Amount: decimal.NewFromInt(10)},
+			},
+			ActorID: f.user.ID,
+		})
+		assert.ErrorIs(t, err, ErrSplitsDoNotSum)
+
+		_, err = f.svc.UpdateBill(UpdateBillInput{
+			TransactionID: bill.ID,
+			Title:         "Costco",
+			Amount:        decimal.NewFromInt(60),
+			OccurredAt:    bill.OccurredAt,
+			Splits: []model.CategorySplit{
+				{CategoryID: household.ID, Amount: decimal.NewFromInt(20)},
+				{CategoryID: groceries.ID, Amount: decimal.NewFromInt(40)},
+			},
+			ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		// Largest share first.
+		splits, err = f.svc.GetTransactionSplits(bill.ID)
+		require.NoError(t, err)
+		require.Len(t, splits, 2)
+		assert.Equal(t, groceries.ID, splits[0].CategoryID)
+		assert.True(t, decimal.NewFromInt(40).Equal(splits[0].Amount))
+		assert.Equal(t, household.ID, splits[1].CategoryID)
+		assert.True(t, decimal.NewFromInt(20).Equal(splits[1].Amount))
+
+		logs, err := f.txAudit.ListByTransaction(bill.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, logs, 2, "the rejected edit must not be logged")
+		var meta struct {
+			Changes map[string]map[string]any `json:"changes"`
+		}
+		require.NoError(t, json.Unmarshal(logs[0].Metadata, &meta))
+		assert.NotContains(t, meta.Changes, "category_id")
+		require.Contains(t, meta.Changes, "splits")
+		assert.Equal(t, "Groceries 60.00", meta.Changes["splits"]["old"])
+		assert.Equal(t, "Groceries 40.00, Household 20.00", meta.Changes["splits"]["new"])
+	})
+}
+
+func TestTransactionService_UpdateTransfer_MovesBothSidesAndPairsAudit(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		dest := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Savings")
+
+		_, err := f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "seed", Amount: decimal.NewFromInt(100), OccurredAt: time.Now(), ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		result, err := f.svc.Transfer(TransferInput{
+			SourceAccountID: f.account.ID, DestAccountID: dest.ID,
+			Title: "Move", Amount: decimal.NewFromInt(30), OccurredAt: time.Now(), ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		// Editing from the destination half works the same as from the source.
+		updated, err := f.svc.UpdateTransfer(UpdateTransferInput{
+			TransactionID: result.Deposit.ID,
+			Title:         "Move more",
+			Amount:        decimal.NewFromInt(70),
+			OccurredAt:    result.Withdrawal.OccurredAt,
+			ActorID:       f.user.ID,
+		})
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(70).Equal(updated.Withdrawal.Value))
+		assert.True(t, decimal.NewFromInt(70).Equal(updated.Deposit.Value))
+
+		src, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(30).Equal(src.Balance))
+		dst, err := f.accounts.ByID(dest.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(70).Equal(dst.Balance))
+
+		wlogs, err := f.txAudit.ListByTransaction(result.Withdrawal.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, wlogs, 2)
+		assert.Equal(t, model.TransactionAuditActionEdited, wlogs[0].Action)
+		var wmeta struct {
+			TransferPairID string                    `json:"transfer_pair_id"`
+			Changes        map[string]map[string]any `json:"changes"`
+		}
+		require.NoError(t, json.Unmarshal(wlogs[0].Metadata, &wmeta))
+		assert.Equal(t, result.Deposit.ID, wmeta.TransferPairID)
+		assert.Equal(t, "30.00", wmeta.Changes["amount"]["old"])
+		assert.Equal(t, "70.00", wmeta.Changes["amount"]["new"])
+
+		dlogs, err := f.txAudit.ListByTransaction(result.Deposit.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, dlogs, 2)
+		var dmeta map[string]any
+		require.NoError(t, json.Unmarshal(dlogs[0].Metadata, &dmeta))
+		assert.Equal(t, result.Withdrawal.ID, dmeta["transfer_pair_id"])
+
+		// Raising past what the source has available is refused.
+		_, err = f.svc.UpdateTransfer(UpdateTransferInput{
+			TransactionID: result.Withdrawal.ID,
+			Title:         "Move more",
+			Amount:        decimal.NewFromInt(101),
+			OccurredAt:    result.Withdrawal.OccurredAt,
+			ActorID:       f.user.ID,
+		})
+		require.ErrorIs(t, err, ErrTransferExceedsAvailable)
+	})
+}
+
+func TestTransactionService_UpdateTransfer_RejectsStandalone(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+
+		dep, err := f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "Paycheck", Amount: decimal.NewFromInt(10), OccurredAt: time.Now(), ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		_, err = f.svc.UpdateTransfer(UpdateTransferInput{
+			TransactionID: dep.ID, Title: "Paycheck", Amount: decimal.NewFromInt(10), OccurredAt: dep.OccurredAt, ActorID: f.user.ID,
+		})
+		assert.ErrorIs(t, err, ErrNotATransfer)
+		_, err = f.svc.UndoTransfer(UndoTransferInput{TransactionID: dep.ID, ActorID: f.user.ID})
+		assert.ErrorIs(t, err, ErrNotATransfer)
+	})
+}
+
+func TestTransactionService_UndoTransfer_RestoresBalances(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		dest := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "Savings")
+
+		_, err := f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "seed", Amount: decimal.NewFromInt(100), OccurredAt: time.Now(), ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		result, err := f.svc.Transfer(TransferInput{
+			SourceAccountID: f.account.ID, DestAccountID: dest.ID,
+			Title: "Move", Amount: decimal.NewFromInt(40), OccurredAt: time.Now(), ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		_, err = f.svc.UndoTransfer(UndoTransferInput{TransactionID: result.Withdrawal.ID, ActorID: f.user.ID})
+		require.NoError(t, err)
+
+		src, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(100).Equal(src.Balance))
+		dst, err := f.accounts.ByID(dest.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.Zero.Equal(dst.Balance))
+
+		_, err = f.svc.GetTransaction(result.Withdrawal.ID)
+		assert.Error(t, err, "withdrawal half should be gone")
+		_, err = f.svc.GetTransaction(result.Deposit.ID)
+		assert.Error(t, err, "deposit half should be gone")
+
+		// Each side's deletion is logged and points at the other.
+		for _, pair := range [][2]string{
+			{result.Withdrawal.ID, result.Deposit.ID},
+			{result.Deposit.ID, result.Withdrawal.ID},
+		} {
+			logs, err := f.txAudit.ListByTransaction(pair[0], 10, 0)
+			require.NoError(t, err)
+			require.Len(t, logs, 2)
+			assert.Equal(t, model.TransactionAuditActionDeleted, logs[0].Action)
+			var meta map[string]any
+			require.NoError(t, json.Unmarshal(logs[0].Metadata, &meta))
+			assert.Equal(t, pair[1], meta["transfer_pair_id"])
+		}
+	})
+}
+
+func TestTransactionService_ConcurrentWritesDoNotLoseUpdates(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		const workers = 8
+		pool := dbi.Pool(t, workers)
+		f := newTxnFixtureOn(t, pool)
+		savings := testutil.CreateTestAccount(t, pool, f.account.SpaceID, "Savings")
+		accounts := NewAccountService(repository.NewAccountRepository(pool))
+
+		for _, id := range []string{f.account.ID, savings.ID} {
+			_, err := f.svc.Deposit(DepositInput{
+				AccountID: id, Title: "seed", Amount: decimal.NewFromInt(1000),
+				OccurredAt: time.Now(), ActorID: f.user.ID,
+			})
+			require.NoError(t, err)
+		}
+
+		// Each worker deposits 10, pays 3, and transfers 5 in alternating
+		// directions so the two accounts are locked in both orders.
+		var wg sync.WaitGroup
+		errs := make(chan error, workers*3)
+		for i := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				src, dst := f.account.ID, savings.ID
+				if i%2 == 1 {
+					src, dst = dst, src
+				}
+				_, err := f.svc.Deposit(DepositInput{
+					AccountID: f.account.ID, Title: "in", Amount: decimal.NewFromInt(10),
+					OccurredAt: time.Now(), ActorID: f.user.ID,
+				})
+				errs <- err
+				_, err = f.svc.PayBill(PayBillInput{
+					AccountID: f.account.ID, Title: "out", Amount: decimal.NewFromInt(3),
+					OccurredAt: time.Now(), ActorID: f.user.ID,
+				})
+				errs <- err
+				_, err = f.svc.Transfer(TransferInput{
+					SourceAccountID: src, DestAccountID: dst, Title: "move", Amount: decimal.NewFromInt(5),
+					OccurredAt: time.Now(), ActorID: f.user.ID,
+				})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		// Transfers cancel out with an even number of workers.
+		acct, err := f.accounts.ByID(f.account.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(1000+workers*7).Equal(acct.Balance), "got %s", acct.Balance)
+		sav, err := f.accounts.ByID(savings.ID)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(1000).Equal(sav.Balance), "got %s", sav.Balance)
+
+		drift, err := accounts.CheckBalances()
+		require.NoError(t, err)
+		assert.Empty(t, drift)
+	})
+}
+
+func TestTransactionService_RunningBalance_RespectsBackdatedEntries(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		_, err := f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(500), OccurredAt: day, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		_, err = f.svc.PayBill(PayBillInput{
+			AccountID: f.account.ID, Title: "Rent", Amount: decimal.NewFromInt(200), OccurredAt: day.AddDate(0, 0, 2), ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		// Entered last but dated between the two.
+		_, err = f.svc.PayBill(PayBillInput{
+			AccountID: f.account.ID, Title: "Coffee", Amount: decimal.NewFromInt(5), OccurredAt: day.AddDate(0, 0, 1), ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		txns, err := f.svc.ListByAccount(f.account.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, txns, 3)
+		want := map[string]string{"Rent": "295", "Coffee": "495", "Pay": "500"}
+		for i, title := range []string{"Rent", "Coffee", "Pay"} {
+			assert.Equal(t, title, txns[i].Title)
+			require.NotNil(t, txns[i].RunningBalance)
+			assert.Equal(t, want[title], txns[i].RunningBalance.String())
+		}
+
+		filtered, err := f.svc.ListByAccountFiltered(f.account.ID, model.TransactionFilter{Title: "rent"}, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, filtered, 1)
+		assert.Equal(t, "295", filtered[0].RunningBalance.String(), "hidden rows still count")
+
+		balance, err := f.svc.BalanceAsOf(f.account.ID, day.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		assert.Equal(t, "495", balance.String())
+		balance, err = f.svc.BalanceAsOf(f.account.ID, day.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		assert.True(t, balance.IsZero())
+	})
+}
+
+func TestTransactionService_RunningBalance_UsesConvertedTransferAmount(t *testing.T) {
+	testutil.ForEachDB(t, func(t *testing.T, dbi testutil.DBInfo) {
+		f := newTxnFixture(t, dbi)
+		usd := testutil.CreateTestAccount(t, dbi.DB, f.account.SpaceID, "USD")
+		_, err := dbi.DB.Exec(`UPDATE accounts SET currency = 'USD' WHERE id = $1`, usd.ID)
+		require.NoError(t, err)
+		day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		_, err = f.svc.Deposit(DepositInput{
+			AccountID: f.account.ID, Title: "Pay", Amount: decimal.NewFromInt(500), OccurredAt: day, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+		_, err = f.svc.Transfer(TransferInput{
+			SourceAccountID: f.account.ID, DestAccountID: usd.ID, Title: "Convert", Amount: decimal.NewFromInt(100),
+			ConversionRate: decimal.RequireFromString("0.75"), OccurredAt: day, ActorID: f.user.ID,
+		})
+		require.NoError(t, err)
+
+		src, err := f.svc.ListByAccount(f.account.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, src, 2)
+		assert.Equal(t, "400", src[0].RunningBalance.String())
+
+		dst, err := f.svc.ListByAccount(usd.ID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, dst, 1)
+		assert.Equal(t, "75", dst[0].RunningBalance.String())
+
+		balance, err := f.svc.BalanceAsOf(usd.ID, day)
+		require.NoError(t, err)
+		assert.Equal(t, "75", balance.String())
+	})
+}
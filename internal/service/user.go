@@ -31,6 +31,9 @@ type UserService struct {
 	// worker up immediately after enqueueing a new request, instead of
 	// waiting for the next periodic tick.
 	triggerDeletion chan<- struct{}
+	// attachmentSvc, when set, removes the stored files of attachments that a
+	// completed deletion cascaded away.
+	attachmentSvc *AttachmentService
 }
 
 func NewUserService(
@@ -47,6 +50,12 @@ func NewUserService(
 	}
 }
 
+// SetAttachmentService wires the attachment service after construction so
+// executed deletions purge the files of the user's attachments right away.
+func (s *UserService) SetAttachmentService(attachments *AttachmentService) {
+	s.attachmentSvc = attachments
+}
+
 // GetDeletionRequest fetches a deletion request by ID. Returns
 // repository.ErrAccountDeletionRequestNotFound when the ID is unknown.
 func (s *UserService) GetDeletionRequest(id string) (*model.AccountDeletionRequest, error) {
@@ -177,6 +186,7 @@ func (s *UserService) ProcessPendingDeletions() int {
 			continue
 		}
 		slog.Info("account deletion completed", "user_id", req.UserID, "request_id", req.ID, "attempt", req.Attempts)
+		s.attachmentSvc.PurgeDeleted()
 		processed++
 	}
 }
@@ -208,7 +218,9 @@ func (s *UserService) executeDeletion(req *model.AccountDeletionRequest) error {
 		}
 
 		// Cascades accounts, transactions, allocations, recurring events,
-		// tags, members, and pending invitations on each space.
+		// tags, members, and pending invitations on each space. Transaction
+		// attachments go with their transactions; a trigger queues their
+		// stored files, which are purged once this commits.
 		result, err := tx.Exec(`DELETE FROM spaces WHERE owner_id = $1;`, req.UserID)
 		if err != nil {
 			return fmt.Errorf("delete owned spaces: %w", err)
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore_PutOpenDelete(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	key, err := store.Put([]byte("receipt"))
+	require.NoError(t, err)
+
+	rc, err := store.Open(key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "receipt", string(data))
+
+	require.NoError(t, store.Delete(key))
+	_, err = store.Open(key)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, store.Delete(key), "deleting twice is not an error")
+}
+
+func TestLocalStore_RejectsForeignKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = store.Open("../../etc/passwd")
+	assert.Error(t, err)
+	assert.Error(t, store.Delete("../secret"))
+}
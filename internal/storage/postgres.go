@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// BackendPostgres is the backend name of PostgresStore.
+const BackendPostgres = "postgres"
+
+// PostgresStore keeps objects as Postgres large objects, so attachments live
+// in the database and travel with its backups. Keys are large object OIDs.
+type PostgresStore struct {
+	db *sqlx.DB
+}
+
+func NewPostgresStore(db *sqlx.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Backend() string {
+	return BackendPostgres
+}
+
+func (s *PostgresStore) Put(data []byte) (string, error) {
+	var oid int64
+	if err := s.db.Get(&oid, `SELECT lo_from_bytea(0, $1)::bigint;`, data); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(oid, 10), nil
+}
+
+// Open reads the whole object. Attachments are capped well below the size
+// where streaming through the large object API would matter.
+func (s *PostgresStore) Open(key string) (io.ReadCloser, error) {
+	oid, err := parseOID(key)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = s.db.Get(&data, `
+		SELECT lo_get(oid) FROM pg_largeobject_metadata WHERE oid = $1::bigint::oid;
+	`, oid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *PostgresStore) Delete(key string) error {
+	oid, err := parseOID(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		SELECT lo_unlink(oid) FROM pg_largeobject_metadata WHERE oid = $1::bigint::oid;
+	`, oid)
+	return err
+}
+
+func parseOID(key string) (int64, error) {
+	oid, err := strconv.ParseUint(key, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid storage key %q", key)
+	}
+	return int64(oid), nil
+}
@@ -0,0 +1,26 @@
+// Package storage holds the byte stores behind transaction attachments. Each
+// store is identified by a backend name that is saved alongside the key it
+// returns, so attachments written to one backend stay readable after the
+// configured upload backend changes.
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a key does not exist in the store.
+var ErrNotFound = errors.New("stored object not found")
+
+// Store saves opaque blobs and hands back a key to retrieve them.
+type Store interface {
+	// Backend is the stable name persisted with every key the store returns.
+	Backend() string
+	// Put saves data and returns the key to read it back.
+	Put(data []byte) (string, error)
+	// Open returns a reader over the object. Callers must close it.
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the object. Deleting a missing key is not an error so
+	// cleanup can be retried safely.
+	Delete(key string) error
+}
@@ -57,15 +57,16 @@ func CreateTestSpace(t *testing.T, db *sqlx.DB, ownerID, name string) *model.Spa
 	t.Helper()
 	now := time.Now()
 	space := &model.Space{
-		ID:        uuid.NewString(),
-		Name:      name,
-		OwnerID:   ownerID,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:                uuid.NewString(),
+		Name:              name,
+		OwnerID:           ownerID,
+		ReportingCurrency: "CAD",
+		CreatedAt:         now,
+		UpdatedAt:         now,
 	}
 	_, err := db.Exec(
-		`INSERT INTO spaces (id, name, owner_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)`,
-		space.ID, space.Name, space.OwnerID, space.CreatedAt, space.UpdatedAt,
+		`INSERT INTO spaces (id, name, owner_id, reporting_currency, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		space.ID, space.Name, space.OwnerID, space.ReportingCurrency, space.CreatedAt, space.UpdatedAt,
 	)
 	if err != nil {
 		t.Fatalf("CreateTestSpace (space): %v", err)
@@ -134,6 +135,7 @@ func CreateTestTransaction(t *testing.T, db *sqlx.DB, accountID, title string, t
 		Type:       txnType,
 		AccountID:  accountID,
 		Title:      title,
+		Status:     model.TransactionStatusPending,
 		OccurredAt: now,
 		CreatedAt:  now,
 		UpdatedAt:  now,
@@ -145,9 +147,46 @@ func CreateTestTransaction(t *testing.T, db *sqlx.DB, accountID, title string, t
 	if err != nil {
 		t.Fatalf("CreateTestTransaction: %v", err)
 	}
+	postTestEntry(t, db, txn)
 	return txn
 }
 
+// postTestEntry records txn in the ledger the way the repository does: its
+// signed value on the account's asset ledger account in the account's
+// currency, balanced against the space's Income or Expenses account.
+func postTestEntry(t *testing.T, db *sqlx.DB, txn *model.Transaction) {
+	t.Helper()
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("CreateTestTransaction (ledger): %v", err)
+		}
+	}
+	key, name, kind := model.LedgerSystemKeyExpenses, "Expenses", model.LedgerAccountKindExpense
+	if txn.Type == model.TransactionTypeDeposit {
+		key, name, kind = model.LedgerSystemKeyIncome, "Income", model.LedgerAccountKindIncome
+	}
+	var account struct {
+		SpaceID  string `db:"space_id"`
+		Currency string `db:"currency"`
+	}
+	if err := db.Get(&account, `SELECT space_id, currency FROM accounts WHERE id = $1`, txn.AccountID); err != nil {
+		t.Fatalf("CreateTestTransaction (space): %v", err)
+	}
+	spaceID, currency := account.SpaceID, account.Currency
+	entryID := uuid.NewString()
+
+	exec(`INSERT INTO ledger_accounts (id, space_id, account_id, name, kind, currency) SELECT $1, space_id, id, name, 'asset', currency FROM accounts WHERE id = $2 ON CONFLICT (account_id, currency) DO NOTHING`,
+		uuid.NewString(), txn.AccountID)
+	exec(`INSERT INTO ledger_accounts (id, space_id, system_key, name, kind, currency) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (space_id, system_key, currency) DO NOTHING`,
+		uuid.NewString(), spaceID, key, name, kind, currency)
+	exec(`INSERT INTO journal_entries (id, space_id, occurred_at) VALUES ($1, $2, $3)`, entryID, spaceID, txn.OccurredAt)
+	exec(`INSERT INTO postings (id, entry_id, ledger_account_id, transaction_id, amount, currency) SELECT $1, $2, id, $3, $4, currency FROM ledger_accounts WHERE account_id = $5 AND currency = $6`,
+		uuid.NewString(), entryID, txn.ID, txn.SignedValue(), txn.AccountID, currency)
+	exec(`INSERT INTO postings (id, entry_id, ledger_account_id, transaction_id, amount, currency) SELECT $1, $2, id, $3, $4, currency FROM ledger_accounts WHERE space_id = $5 AND system_key = $6 AND currency = $7`,
+		uuid.NewString(), entryID, txn.ID, txn.SignedValue().Neg(), spaceID, key, currency)
+}
+
 // CreateTestToken inserts a token directly into the database.
 func CreateTestToken(t *testing.T, db *sqlx.DB, userID, tokenType, tokenString string, expiresAt time.Time) *model.Token {
 	t.Helper()
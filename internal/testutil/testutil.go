@@ -2,6 +2,7 @@ package testutil
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
@@ -13,6 +14,23 @@ import (
 // DBInfo holds a test database connection.
 type DBInfo struct {
 	DB *sqlx.DB
+	// poolURL connects straight into the test's schema; see Pool.
+	poolURL string
+}
+
+// Pool opens a second connection pool on the test's schema that allows up to
+// n connections at once. DB is limited to a single connection, so tests that
+// need real concurrency (racing writers, lock ordering) use this instead. The
+// pool is closed on cleanup.
+func (d DBInfo) Pool(t *testing.T, n int) *sqlx.DB {
+	t.Helper()
+	pool, err := sqlx.Connect("pgx", d.poolURL)
+	if err != nil {
+		t.Fatalf("failed to open pool: %v", err)
+	}
+	pool.SetMaxOpenConns(n)
+	t.Cleanup(func() { pool.Close() })
+	return pool
 }
 
 // ForEachDB runs the test function against PostgreSQL. Skips when
@@ -82,5 +100,15 @@ func newPostgresDB(t *testing.T, baseURL string) DBInfo {
 		t.Fatalf("failed to run postgres migrations: %v", err)
 	}
 
-	return DBInfo{DB: pgDB}
+	// search_path as a startup parameter applies to every connection a pool
+	// opens, unlike the session-level SET above.
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		t.Fatalf("failed to parse postgres url: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", fmt.Sprintf("%q", schema))
+	u.RawQuery = q.Encode()
+
+	return DBInfo{DB: pgDB, poolURL: u.String()}
 }
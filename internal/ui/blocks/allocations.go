@@ -1,13 +1,16 @@
 package blocks
 
-import "github.com/shopspring/decimal"
+import (
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"github.com/shopspring/decimal"
+)
 
 func decimalHundred() decimal.Decimal { return decimal.NewFromInt(100) }
 func decimalZero() decimal.Decimal    { return decimal.Zero }
 
-func targetDisplay(t *decimal.Decimal) string {
+func targetDisplay(t *decimal.Decimal, cur currency.Currency) string {
 	if t == nil {
 		return ""
 	}
-	return t.StringFixedBank(2)
+	return cur.Fixed(*t)
 }
@@ -15,6 +15,8 @@ func kindLabel(v string) string {
 		return "Bill (withdrawal)"
 	case string(model.RecurringEventKindFund):
 		return "Fund (deposit)"
+	case string(model.RecurringEventKindTransfer):
+		return "Transfer"
 	}
 	return ""
 }
@@ -12,6 +12,13 @@ func accountLabel(ev *model.RecurringEvent, accountByID map[string]string) strin
 	if src == "" {
 		src = ev.SourceAccountID
 	}
+	if ev.DestAccountID != nil {
+		dest := accountByID[*ev.DestAccountID]
+		if dest == "" {
+			dest = *ev.DestAccountID
+		}
+		return src + " → " + dest
+	}
 	return src
 }
 
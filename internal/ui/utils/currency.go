@@ -1,6 +1,13 @@
 package utils
 
-import "strings"
+import (
+	"context"
+	"strings"
+
+	"git.juancwu.dev/juancwu/budgit/internal/ctxkeys"
+	"git.juancwu.dev/juancwu/budgit/internal/misc/currency"
+	"github.com/shopspring/decimal"
+)
 
 func FormatDecimalWithThousands(numStr string) (string, error) {
 	// Split into integer and decimal parts
@@ -33,3 +40,16 @@ func FormatDecimalWithThousands(numStr string) (string, error) {
 	}
 	return formatted, nil
 }
+
+// Money writes amount in the currency with the given code, using the
+// request's space currencies and locale: "$1,234.50", "¥1,235",
+// "₿0.00150000".
+func Money(ctx context.Context, amount decimal.Decimal, code string) string {
+	return ctxkeys.Currencies(ctx).Get(code).FormatIn(amount, ctxkeys.Locale(ctx))
+}
+
+// Currency returns the currency with the given code from the request's space
+// registry, for rounding and form placeholders.
+func Currency(ctx context.Context, code string) currency.Currency {
+	return ctxkeys.Currencies(ctx).Get(code)
+}